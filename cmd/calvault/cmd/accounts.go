@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/salman1993/calvault/internal/oauth"
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var accountsJSON bool
+
+var accountsCmd = &cobra.Command{
+	Use:     "accounts",
+	Aliases: []string{"list-accounts"},
+	Short:   "List accounts with token and sync status",
+	Long: `List every account in the archive with its provider, OAuth token state,
+calendar and event counts, and the outcome of its most recent sync.
+
+Token states are checked offline: "valid" means a refresh token (or an
+unexpired access token) is saved, "expired" and "missing" mean the account
+needs 'calvault auth refresh' to sign in again. Imported ICS calendars have
+no token.
+
+Examples:
+  calvault accounts
+  calvault accounts --json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		accounts, err := s.ListAccountSummaries()
+		if err != nil {
+			return err
+		}
+
+		if accountsJSON {
+			entries := make([]accountJSONEntry, 0, len(accounts))
+			for _, a := range accounts {
+				entries = append(entries, newAccountJSONEntry(a))
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(entries)
+		}
+
+		if len(accounts) == 0 {
+			out.Println("No accounts. Run 'calvault add-account <email>' to add one.")
+			return nil
+		}
+
+		headers := []string{"Account", "Provider", "Token", "Calendars", "Events", "Last sync", "Status"}
+		right := []int{3, 4}
+		if cfg.Team.Enabled() {
+			headers = append([]string{"Owner"}, headers...)
+			right = []int{4, 5}
+		}
+		t := render.NewTable(headers...).AlignRight(right...)
+		for _, a := range accounts {
+			lastSync := out.Muted("never")
+			if !a.LastSync.IsZero() {
+				lastSync = out.DateTime(a.LastSync.Local())
+			}
+			cells := []string{out.Accent(a.Identifier), a.SourceType, styleTokenState(accountTokenState(a.SourceType, a.Identifier)),
+				out.Number(a.Calendars), out.Number(a.Events), lastSync, styleSyncStatus(a.LastStatus, a.LastError)}
+			if cfg.Team.Enabled() {
+				cells = append([]string{teamMemberLabel(a.Owner)}, cells...)
+			}
+			t.Row(cells...)
+		}
+		out.Table(t)
+		return nil
+	},
+}
+
+// accountJSONEntry is the JSON shape of an account.
+type accountJSONEntry struct {
+	Account    string `json:"account"`
+	Provider   string `json:"provider"`
+	Owner      string `json:"owner,omitempty"`
+	Token      string `json:"token"`
+	Calendars  int64  `json:"calendars"`
+	Events     int64  `json:"events"`
+	LastSync   string `json:"last_sync,omitempty"`
+	LastStatus string `json:"last_status,omitempty"`
+	LastError  string `json:"last_error,omitempty"`
+}
+
+func newAccountJSONEntry(a *store.AccountSummary) accountJSONEntry {
+	e := accountJSONEntry{
+		Account:    a.Identifier,
+		Provider:   a.SourceType,
+		Owner:      a.Owner,
+		Token:      accountTokenState(a.SourceType, a.Identifier),
+		Calendars:  a.Calendars,
+		Events:     a.Events,
+		LastStatus: a.LastStatus,
+		LastError:  a.LastError,
+	}
+	if !a.LastSync.IsZero() {
+		e.LastSync = a.LastSync.Format(time.RFC3339)
+	}
+	return e
+}
+
+// accountTokenState returns the OAuth token state for an account, or "-"
+// for sources without tokens.
+func accountTokenState(sourceType, identifier string) string {
+	dir := tokensDirFor(sourceType)
+	if dir == "" {
+		return "-"
+	}
+	return oauth.CheckToken(dir, identifier)
+}
+
+func styleTokenState(state string) string {
+	switch state {
+	case oauth.TokenValid:
+		return out.Good(state)
+	case "-":
+		return out.Muted(state)
+	default:
+		return out.Bad(state)
+	}
+}
+
+func styleSyncStatus(status, errMsg string) string {
+	switch status {
+	case "":
+		return out.Muted("-")
+	case "completed":
+		return out.Good(status)
+	case "failed":
+		if errMsg != "" {
+			return out.Bad(status + ": " + oneLine(errMsg, 40))
+		}
+		return out.Bad(status)
+	default:
+		return out.Warn(status)
+	}
+}
+
+func init() {
+	accountsCmd.Flags().BoolVar(&accountsJSON, "json", false, "Output as JSON")
+	rootCmd.AddCommand(accountsCmd)
+}
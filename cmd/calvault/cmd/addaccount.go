@@ -10,26 +10,39 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var headless bool
+var (
+	headless        bool
+	accountProvider string
+)
 
 var addAccountCmd = &cobra.Command{
 	Use:   "add-account <email>",
-	Short: "Add a Google account via OAuth",
-	Long: `Add a Google account by completing the OAuth2 authorization flow.
+	Short: "Add a Google or Microsoft account via OAuth",
+	Long: `Add an account by completing the OAuth2 authorization flow.
 
 By default, opens a browser for authorization. Use --headless for environments
 without a display (e.g., SSH sessions) to use device code flow instead.
 
+Use --provider microsoft for Microsoft 365 / Outlook.com accounts; this
+requires [microsoft] client_id in config.toml.
+
 Example:
   calvault add-account you@gmail.com
-  calvault add-account you@gmail.com --headless`,
+  calvault add-account you@gmail.com --headless
+  calvault add-account you@company.com --provider microsoft`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		email := args[0]
 
-		// Validate config
-		if cfg.OAuth.ClientSecrets == "" {
-			return errOAuthNotConfigured()
+		sourceType, err := providerSourceType(accountProvider)
+		if err != nil {
+			return err
+		}
+
+		// Create OAuth manager (validates config)
+		oauthMgr, err := newOAuthManager(sourceType)
+		if err != nil {
+			return err
 		}
 
 		// Ensure directories exist
@@ -49,16 +62,10 @@ Example:
 			return fmt.Errorf("init schema: %w", err)
 		}
 
-		// Create OAuth manager
-		oauthMgr, err := oauth.NewManager(cfg.OAuth.ClientSecrets, cfg.TokensDir(), logger)
-		if err != nil {
-			return wrapOAuthError(fmt.Errorf("create oauth manager: %w", err))
-		}
-
 		// Check if already authorized
 		if oauthMgr.HasToken(email) {
 			fmt.Printf("Account %s is already authorized.\n", email)
-			fmt.Println("To re-authorize, delete the token file and try again.")
+			fmt.Println("To re-authorize, run: calvault auth refresh", email)
 			return nil
 		}
 
@@ -75,10 +82,15 @@ Example:
 		}
 
 		// Create source record in database
-		_, err = s.GetOrCreateSource(email)
+		src, err := s.GetOrCreateSourceOfType(sourceType, email)
 		if err != nil {
 			return fmt.Errorf("create source: %w", err)
 		}
+		if cfg.Team.Enabled() {
+			if err := s.ClaimSource(src.ID, cfg.Team.Member); err != nil {
+				return err
+			}
+		}
 
 		fmt.Printf("\nAccount %s authorized successfully!\n", email)
 		fmt.Println("You can now run: calvault sync", email)
@@ -87,7 +99,39 @@ Example:
 	},
 }
 
+// providerSourceType maps a --provider value to a source type.
+func providerSourceType(provider string) (string, error) {
+	switch provider {
+	case "", "google":
+		return store.SourceTypeGoogle, nil
+	case "microsoft", "outlook":
+		return store.SourceTypeMicrosoft, nil
+	default:
+		return "", fmt.Errorf("unknown provider %q (expected google or microsoft)", provider)
+	}
+}
+
+// newOAuthManager creates the OAuth manager for a source type.
+func newOAuthManager(sourceType string) (*oauth.Manager, error) {
+	if sourceType == store.SourceTypeMicrosoft {
+		if cfg.Microsoft.ClientID == "" {
+			return nil, errMicrosoftNotConfigured()
+		}
+		return oauth.NewMicrosoftManager(cfg.Microsoft.ClientID, cfg.Microsoft.Tenant, cfg.MicrosoftTokensDir(), logger), nil
+	}
+
+	if cfg.OAuth.ClientSecrets == "" {
+		return nil, errOAuthNotConfigured()
+	}
+	mgr, err := oauth.NewManager(cfg.OAuth.ClientSecrets, cfg.TokensDir(), logger)
+	if err != nil {
+		return nil, wrapOAuthError(fmt.Errorf("create oauth manager: %w", err))
+	}
+	return mgr, nil
+}
+
 func init() {
 	addAccountCmd.Flags().BoolVar(&headless, "headless", false, "Use device code flow for headless environments")
+	addAccountCmd.Flags().StringVar(&accountProvider, "provider", "google", "Account provider: google or microsoft")
 	rootCmd.AddCommand(addAccountCmd)
 }
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/salman1993/calvault/internal/agenda"
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	agendaDate string
+	agendaDays int
+)
+
+var agendaCmd = &cobra.Command{
+	Use:   "agenda",
+	Short: "Show events day by day across all accounts",
+	Long: `Print the events of one or more days, grouped by day, across all accounts.
+
+Recurring events stored as a series are expanded into their occurrences for
+the days shown, with moved or cancelled occurrences taken into account.
+Days start at local midnight; all-day events appear on their own date.
+
+Examples:
+  calvault agenda                          # Today
+  calvault agenda --days 7                 # This week from today
+  calvault agenda --date 2024-03-04 --days 5`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if agendaDays < 1 {
+			return fmt.Errorf("--days must be at least 1")
+		}
+		now := time.Now()
+		first := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		if agendaDate != "" {
+			var err error
+			if first, err = time.ParseInLocation("2006-01-02", agendaDate, time.Local); err != nil {
+				return fmt.Errorf("invalid --date %q (expected YYYY-MM-DD)", agendaDate)
+			}
+		}
+		end := first.AddDate(0, 0, agendaDays)
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		items, err := agenda.Days(s, first, end)
+		if err != nil {
+			return err
+		}
+		sources, err := s.ListSources()
+		if err != nil {
+			return err
+		}
+		accounts := make(map[int64]string, len(sources))
+		for _, src := range sources {
+			accounts[src.ID] = src.Identifier
+		}
+
+		byDay := map[string][]*agenda.Item{}
+		for _, it := range items {
+			key := it.Day().Format("2006-01-02")
+			byDay[key] = append(byDay[key], it)
+		}
+		if len(byDay) == 0 {
+			if agendaDays == 1 {
+				out.Printf("No events on %s.\n", out.Date(first))
+			} else {
+				out.Printf("No events from %s to %s.\n", out.Date(first), out.Date(end.AddDate(0, 0, -1)))
+			}
+			return nil
+		}
+
+		for day := first; day.Before(end); day = day.AddDate(0, 0, 1) {
+			dayItems := byDay[day.Format("2006-01-02")]
+			if len(dayItems) == 0 {
+				continue
+			}
+			out.Println(out.Heading(day.Weekday().String() + " " + out.Date(day)))
+			t := render.NewTable("Time", "Event", "Account")
+			if len(sources) < 2 {
+				t = render.NewTable("Time", "Event")
+			}
+			for _, it := range dayItems {
+				title := out.Accent(it.Event.Summary)
+				if it.Event.Summary == "" {
+					title = out.Muted("(no title)")
+				}
+				if it.Event.Status == "tentative" {
+					title += " " + out.Warn("(tentative)")
+				}
+				if it.Event.Location != "" {
+					title += out.Muted(" @ " + oneLine(it.Event.Location, 40))
+				}
+				t.Row(agendaTime(it), title, out.Muted(accounts[it.Event.SourceID]))
+			}
+			out.Table(t)
+			out.Println()
+		}
+		return nil
+	},
+}
+
+func agendaTime(it *agenda.Item) string {
+	switch {
+	case it.Event.AllDay:
+		return "All day"
+	case it.End.IsZero():
+		return out.Time(it.Start.Local())
+	default:
+		return out.Time(it.Start.Local()) + "-" + out.Time(it.End.Local())
+	}
+}
+
+func init() {
+	agendaCmd.Flags().StringVar(&agendaDate, "date", "", "First day to show, YYYY-MM-DD (default today)")
+	agendaCmd.Flags().IntVar(&agendaDays, "days", 1, "Number of days to show")
+	rootCmd.AddCommand(agendaCmd)
+}
@@ -0,0 +1,744 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/agenda"
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var analyzeSince string
+
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze",
+	Short: "Analyze patterns in the calendar archive",
+	Long: `Run canned analyses over the calendar archive.
+
+Each subcommand prints a human-readable report. For ad-hoc questions,
+use 'calvault query' instead.`,
+}
+
+var analyzeDurationsCmd = &cobra.Command{
+	Use:   "durations",
+	Short: "Show the distribution of meeting durations",
+	Long: `Show how long meetings last, split by recurring vs ad-hoc events.
+
+Reports a bucketed distribution (15/30/60/90+ minutes), median and p90
+durations, and a month-by-month trend. All-day and cancelled events are
+excluded.
+
+Examples:
+  calvault analyze durations
+  calvault analyze durations --since 2024-01-01`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		since, err := parseDateFlag(analyzeSince)
+		if err != nil {
+			return err
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		report, err := s.GetDurationReport(since)
+		if err != nil {
+			return fmt.Errorf("get duration report: %w", err)
+		}
+
+		out.Title("Meeting Durations")
+		buckets := render.NewTable("Bucket", "Recurring", "Ad-hoc").AlignRight(1, 2)
+		for _, b := range report.Buckets {
+			buckets.Row(b.Label, out.Number(int64(b.Recurring)), out.Number(int64(b.AdHoc)))
+		}
+		out.Table(buckets)
+
+		out.Println()
+		summary := render.NewTable("", "Events", "Hours", "Median", "P90").AlignRight(1, 2, 3, 4)
+		addDurationSummary(summary, "Recurring", report.Recurring)
+		addDurationSummary(summary, "Ad-hoc", report.AdHoc)
+		out.Table(summary)
+
+		if len(report.Trend) > 0 {
+			out.Println()
+			out.Println(out.Heading("Monthly Trend (median minutes)"))
+			trend := render.NewTable("Month", "Recurring", "Ad-hoc").AlignRight(1, 2)
+			for _, t := range report.Trend {
+				trend.Row(t.Month, formatTrendCell(t.Recurring), formatTrendCell(t.AdHoc))
+			}
+			out.Table(trend)
+		}
+
+		return nil
+	},
+}
+
+var analyzeTeamCmd = &cobra.Command{
+	Use:   "team",
+	Short: "Compare meeting load across team members",
+	Long: `Show each team member's meeting load in a team vault: meetings and
+hours across the accounts they own, and hours per week.
+
+Meetings count as in the analytics tables: timed events that weren't
+cancelled or declined. Private calendars and private events are left out, so
+members can compare load without exposing them. Defaults to the last four
+weeks.
+
+Examples:
+  calvault analyze team
+  calvault analyze team --since 2024-01-01`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		until := time.Now()
+		since := until.AddDate(0, 0, -28)
+		if analyzeSince != "" {
+			var err error
+			if since, err = parseDateFlag(analyzeSince); err != nil {
+				return err
+			}
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		loads, err := s.GetTeamLoad(since, until)
+		if err != nil {
+			return err
+		}
+		if len(loads) == 0 {
+			out.Println("No accounts. Run 'calvault add-account <email>' to add one.")
+			return nil
+		}
+
+		weeks := until.Sub(since).Hours() / (24 * 7)
+		out.Title("Team Meeting Load")
+		out.Println(out.Muted(fmt.Sprintf("%s to %s", out.Date(since), out.Date(until))))
+		out.Println()
+		t := render.NewTable("Member", "Accounts", "Meetings", "Hours", "Hours/week").AlignRight(1, 2, 3, 4)
+		for _, l := range loads {
+			t.Row(teamMemberLabel(l.Owner), out.Number(int64(l.Accounts)), out.Number(int64(l.Meetings)),
+				fmt.Sprintf("%.1f", l.Hours), fmt.Sprintf("%.1f", l.Hours/weeks))
+		}
+		out.Table(t)
+		return nil
+	},
+}
+
+var analyzeCapacityCmd = &cobra.Command{
+	Use:   "capacity",
+	Short: "Report team meeting capacity with anonymized group figures",
+	Long: `Report a team vault's meeting load for capacity planning: total meeting
+hours, hours per member per week and meeting-free day adherence, for the
+team and for each group in [team.groups], and a matrix of the hours groups
+spend in meetings with each other.
+
+Adherence is the share of member-days on [team] meeting_free_days that had
+no meetings; without meeting_free_days it is the share of working days
+([work_hours]) without meetings. A meeting is shared between groups when
+members of both have it on their calendars.
+
+Figures never cover fewer members than [team] min_group_size (default 3):
+smaller groups are hidden, so the report can't expose one person's
+calendar. Meetings count as in 'analyze team', leaving out private
+calendars and events, and accounts not assigned to a member. Defaults to
+the last four weeks.
+
+Examples:
+  calvault analyze capacity
+  calvault analyze capacity --since 2024-01-01`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		until := workDay(time.Now()).AddDate(0, 0, 1)
+		since := until.AddDate(0, 0, -28)
+		if analyzeSince != "" {
+			var err error
+			if since, err = parseWorkDateFlag(analyzeSince); err != nil {
+				return err
+			}
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		freeDays, freeLabel := cfg.Team.MeetingFreeWeekdays(), "Meeting-free days kept"
+		if len(cfg.Team.MeetingFreeDays) == 0 {
+			freeDays, freeLabel = cfg.WorkHours.Weekdays(), "Meeting-free working days"
+		}
+		report, err := s.GetTeamCapacity(since, until, store.CapacityOptions{
+			GroupOf:         cfg.Team.GroupOf,
+			MeetingFreeDays: freeDays,
+			MinGroupSize:    cfg.Team.MinGroupSize,
+		})
+		if err != nil {
+			return err
+		}
+		if report.Total.Members == 0 {
+			out.Println("No team members. Set [team] member in config.toml, or assign accounts with 'calvault team assign'.")
+			return nil
+		}
+
+		weeks := until.Sub(since).Hours() / (24 * 7)
+		out.Title("Team Capacity")
+		out.Println(out.Muted(fmt.Sprintf("%s to %s", out.Date(since), out.Date(until.AddDate(0, 0, -1)))))
+		out.Println()
+		if report.Total.Hidden {
+			out.Println(out.Muted(fmt.Sprintf("Hidden: the team has fewer members (%d) than min_group_size (%d).",
+				report.Total.Members, cfg.Team.MinGroupSize)))
+			return nil
+		}
+		out.KeyValues(
+			"Members", out.Number(int64(report.Total.Members)),
+			"Meetings", out.Number(int64(report.Total.Meetings)),
+			"Meeting hours", fmt.Sprintf("%.1f", report.Total.Hours),
+			"Hours/member/week", capacityPerMember(&report.Total, weeks),
+			freeLabel, capacityAdherence(&report.Total),
+		)
+
+		if len(report.Groups) < 2 {
+			return nil
+		}
+		out.Println()
+		out.Println(out.Heading("By Group"))
+		t := render.NewTable("Group", "Members", "Meetings", "Hours", "Hours/member/week", "Meeting-free").AlignRight(1, 2, 3, 4, 5)
+		var visible []string
+		for _, g := range report.Groups {
+			name := out.Accent(capacityGroupLabel(g.Group))
+			if g.Hidden {
+				t.Row(name, out.Number(int64(g.Members)), out.Muted("hidden"), "", "", "")
+				continue
+			}
+			visible = append(visible, g.Group)
+			t.Row(name, out.Number(int64(g.Members)), out.Number(int64(g.Meetings)), fmt.Sprintf("%.1f", g.Hours),
+				capacityPerMember(g, weeks), capacityAdherence(g))
+		}
+		out.Table(t)
+
+		if len(visible) < 2 {
+			return nil
+		}
+		out.Println()
+		out.Println(out.Heading("Cross-Group Meeting Hours"))
+		headers := []string{""}
+		for _, g := range visible {
+			headers = append(headers, capacityGroupLabel(g))
+		}
+		matrix := render.NewTable(headers...)
+		for i := range visible {
+			matrix.AlignRight(i + 1)
+		}
+		for _, a := range visible {
+			row := []string{out.Accent(capacityGroupLabel(a))}
+			for _, b := range visible {
+				pair := [2]string{a, b}
+				if b < a {
+					pair = [2]string{b, a}
+				}
+				if hours := report.Shared[pair]; hours > 0 {
+					row = append(row, fmt.Sprintf("%.1f", hours))
+				} else {
+					row = append(row, out.Muted("-"))
+				}
+			}
+			matrix.Row(row...)
+		}
+		out.Table(matrix)
+		return nil
+	},
+}
+
+// capacityGroupLabel names a capacity report group.
+func capacityGroupLabel(group string) string {
+	if group == "" {
+		return "(no group)"
+	}
+	return group
+}
+
+// capacityPerMember formats a group's meeting hours per member per week.
+func capacityPerMember(g *store.GroupCapacity, weeks float64) string {
+	return fmt.Sprintf("%.1f", g.Hours/float64(g.Members)/weeks)
+}
+
+// capacityAdherence formats a group's meeting-free day adherence.
+func capacityAdherence(g *store.GroupCapacity) string {
+	if g.Days == 0 {
+		return out.Muted("-")
+	}
+	return fmt.Sprintf("%.0f%% of %s", 100*g.Adherence(), out.Number(int64(g.Days)))
+}
+
+var analyzeRooms []string
+
+var analyzeRoomsCmd = &cobra.Command{
+	Use:   "rooms",
+	Short: "Show meeting room and resource utilization",
+	Long: `Report how synced room and resource calendars are used: bookings,
+booked hours, booking rate, likely no-shows and peak hours.
+
+Google resource calendars (*@resource.calendar.google.com) are picked up
+automatically; use --room to include other calendars, matched by ID or name
+(glob), such as Microsoft 365 room mailboxes shared with you.
+
+The booking rate is the share of working hours ([work_hours] in
+config.toml; Monday to Friday, 09:00-17:00 by default) that were booked. A booking counts as a no-show
+when its organizer declined it but the room stayed reserved. Peak hours
+are the hours of the day with the most bookings. Defaults to the last four
+weeks.
+
+Examples:
+  calvault analyze rooms
+  calvault analyze rooms --since 2024-01-01 --room "Board*"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, p := range analyzeRooms {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid --room pattern %q: %w", p, err)
+			}
+		}
+		until := workDay(time.Now()).AddDate(0, 0, 1)
+		since := until.AddDate(0, 0, -28)
+		if analyzeSince != "" {
+			var err error
+			if since, err = parseWorkDateFlag(analyzeSince); err != nil {
+				return err
+			}
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		rooms, err := s.GetRoomReport(since, until, workingHours(), isRoomCalendar)
+		if err != nil {
+			return err
+		}
+		if len(rooms) == 0 {
+			out.Println("No room or resource calendars. Sync a room's calendar, or pick calendars with --room.")
+			return nil
+		}
+
+		out.Title("Room Utilization")
+		out.Println(out.Muted(fmt.Sprintf("%s to %s", out.Date(since), out.Date(until.AddDate(0, 0, -1)))))
+		out.Println()
+		t := render.NewTable("Room", "Bookings", "Hours", "Booked", "No-shows", "Peak hours").AlignRight(1, 2, 3, 4)
+		for _, r := range rooms {
+			noShows := out.Muted("0")
+			if r.NoShows > 0 {
+				noShows = out.Warn(fmt.Sprintf("%d (%.0f%%)", r.NoShows, 100*float64(r.NoShows)/float64(r.Bookings)))
+			}
+			var peak []string
+			for _, h := range r.PeakHours(3) {
+				peak = append(peak, out.Time(time.Date(2000, 1, 1, h, 0, 0, 0, cfg.WorkHours.Location())))
+			}
+			t.Row(out.Accent(r.Name), out.Number(int64(r.Bookings)), fmt.Sprintf("%.1f", r.BookedHours),
+				fmt.Sprintf("%.0f%%", 100*r.BookingRate), noShows, strings.Join(peak, ", "))
+		}
+		out.Table(t)
+		return nil
+	},
+}
+
+// isRoomCalendar reports whether a calendar is a Google resource calendar
+// or matches a --room pattern.
+func isRoomCalendar(id, name string) bool {
+	if strings.HasSuffix(id, "@resource.calendar.google.com") {
+		return true
+	}
+	for _, p := range analyzeRooms {
+		if ok, _ := path.Match(p, id); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+var analyzeInterviewsCmd = &cobra.Command{
+	Use:   "interviews",
+	Short: "Tally interview load per week and per interviewer",
+	Long: `Report how many interviews took place each week and who conducted them.
+
+Interviews are meetings whose title or attendees match the [interviews]
+rules in config.toml. Titles containing "interview" or "phone screen" match
+by default. Interviewers are attendees who didn't decline, from the
+account's email domain unless interviewer_domains is set:
+
+  [interviews]
+  titles = ["*interview*", "*onsite*", "Debrief:*"]
+  attendees = ["*@resources.greenhouse.io"]   # ATS scheduling addresses
+  interviewer_domains = ["company.com"]
+
+An interview synced from several accounts counts once. Defaults to the last
+12 weeks.
+
+Examples:
+  calvault analyze interviews
+  calvault analyze interviews --since 2024-01-01`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		now := time.Now()
+		until := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1)
+		since := out.Locale().StartOfWeek(now).AddDate(0, 0, -7*11)
+		if analyzeSince != "" {
+			var err error
+			if since, err = parseDateFlag(analyzeSince); err != nil {
+				return err
+			}
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		report, err := s.GetInterviewReport(since, until, out.Locale().WeekStart, store.InterviewRules{
+			IsInterview:   cfg.Interviews.IsInterview,
+			IsInterviewer: cfg.Interviews.IsInterviewer,
+		})
+		if err != nil {
+			return err
+		}
+
+		out.Title("Interview Activity")
+		out.Println(out.Muted(fmt.Sprintf("%s to %s", out.Date(since), out.Date(until.AddDate(0, 0, -1)))))
+		out.Println()
+		if report.Interviews == 0 {
+			out.Println("No interviews found. Adjust the [interviews] rules in config.toml to match your calendar.")
+			return nil
+		}
+		out.KeyValues(
+			"Interviews", out.Number(int64(report.Interviews)),
+			"Hours", fmt.Sprintf("%.1f", report.Hours),
+		)
+
+		out.Println()
+		out.Println(out.Heading("By Week"))
+		weeks := render.NewTable("Week of", "Interviews", "Hours").AlignRight(1, 2)
+		for _, w := range report.Weeks {
+			weeks.Row(out.Date(w.Start), out.Number(int64(w.Interviews)), fmt.Sprintf("%.1f", w.Hours))
+		}
+		out.Table(weeks)
+
+		if len(report.Interviewers) > 0 {
+			out.Println()
+			out.Println(out.Heading("By Interviewer"))
+			people := render.NewTable("Interviewer", "Interviews", "Hours", "Last").AlignRight(1, 2)
+			for _, l := range report.Interviewers {
+				name := out.Accent(l.Email)
+				if l.Name != "" {
+					name = out.Accent(l.Name) + out.Muted(" <"+l.Email+">")
+				}
+				people.Row(name, out.Number(int64(l.Interviews)), fmt.Sprintf("%.1f", l.Hours), out.Date(l.Last.Local()))
+			}
+			out.Table(people)
+		}
+		return nil
+	},
+}
+
+var analyzeOffHoursCmd = &cobra.Command{
+	Use:   "off-hours",
+	Short: "Show meetings outside working hours and on days off",
+	Long: `Quantify the off-hours meeting burden: meetings on days off, and
+meetings on working days that start early or run late, trended by month and
+grouped by organizer and by the event's time zone.
+
+Working hours come from [work_hours] in config.toml (Monday to Friday,
+09:00-17:00 local time by default):
+
+  [work_hours]
+  start = "08:30"
+  end = "17:30"
+  days = ["mon", "tue", "wed", "thu", "fri"]
+  timezone = "Europe/Berlin"
+
+Meetings count as in the analytics tables: timed events that weren't
+cancelled or declined. Hours are the time spent outside working hours.
+Defaults to the last six months.
+
+Examples:
+  calvault analyze off-hours
+  calvault analyze off-hours --since 2024-01-01`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		today := workDay(time.Now())
+		until := today.AddDate(0, 0, 1)
+		since := time.Date(today.Year(), today.Month()-5, 1, 0, 0, 0, 0, today.Location())
+		if analyzeSince != "" {
+			var err error
+			if since, err = parseWorkDateFlag(analyzeSince); err != nil {
+				return err
+			}
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		report, err := s.GetOffHoursReport(since, until, workingHours())
+		if err != nil {
+			return err
+		}
+
+		out.Title("Off-Hours Meetings")
+		start, end := cfg.WorkHours.Span()
+		midnight := time.Date(2000, 1, 1, 0, 0, 0, 0, cfg.WorkHours.Location())
+		period := fmt.Sprintf("%s to %s, working hours %s-%s",
+			out.Date(since), out.Date(today), out.Time(midnight.Add(start)), out.Time(midnight.Add(end)))
+		if cfg.WorkHours.Timezone != "" {
+			period += " " + cfg.WorkHours.Timezone
+		}
+		out.Println(out.Muted(period))
+		out.Println()
+		if report.Total.Meetings == 0 {
+			out.Println("No meetings in this period.")
+			return nil
+		}
+		out.KeyValues(
+			"Meetings", out.Number(int64(report.Total.Meetings)),
+			"Off-hours", fmt.Sprintf("%s (early or late on working days)", out.Number(int64(report.Total.OffHours))),
+			"Days off", out.Number(int64(report.Total.Weekend)),
+			"Share", offHoursShare(&report.Total),
+			"Hours", fmt.Sprintf("%.1f outside working hours", report.Total.Hours),
+		)
+
+		out.Println()
+		out.Println(out.Heading("By Month"))
+		months := render.NewTable("Month", "Meetings", "Off-hours", "Days off", "Share", "Hours").AlignRight(1, 2, 3, 4, 5)
+		for _, m := range report.Months {
+			months.Row(m.Key, out.Number(int64(m.Meetings)), out.Number(int64(m.OffHours)), out.Number(int64(m.Weekend)),
+				offHoursShare(m), fmt.Sprintf("%.1f", m.Hours))
+		}
+		out.Table(months)
+
+		groups := []struct {
+			title, column, unknown string
+			tallies                []*store.OffHoursTally
+		}{
+			{"By Organizer", "Organizer", "(no organizer)", report.Organizers},
+			{"By Time Zone", "Time zone", "(unknown)", report.Timezones},
+		}
+		for _, g := range groups {
+			if len(g.tallies) == 0 {
+				continue
+			}
+			out.Println()
+			out.Println(out.Heading(g.title))
+			t := render.NewTable(g.column, "Off-hours", "Days off", "Hours").AlignRight(1, 2, 3)
+			for _, tally := range g.tallies[:min(len(g.tallies), 10)] {
+				key := out.Accent(tally.Key)
+				if tally.Key == "" {
+					key = out.Muted(g.unknown)
+				}
+				t.Row(key, out.Number(int64(tally.OffHours)), out.Number(int64(tally.Weekend)), fmt.Sprintf("%.1f", tally.Hours))
+			}
+			out.Table(t)
+			if len(g.tallies) > 10 {
+				out.Println(out.Muted(fmt.Sprintf("  ... and %d more", len(g.tallies)-10)))
+			}
+		}
+		return nil
+	},
+}
+
+// offHoursShare formats the share of meetings outside working hours.
+func offHoursShare(t *store.OffHoursTally) string {
+	share := fmt.Sprintf("%.0f%%", 100*float64(t.Outside())/float64(t.Meetings))
+	if t.Outside() > 0 {
+		return out.Warn(share)
+	}
+	return share
+}
+
+// workingHours returns the [work_hours] config for the store.
+func workingHours() store.WorkingHours {
+	start, end := cfg.WorkHours.Span()
+	return store.WorkingHours{Start: start, End: end, Days: cfg.WorkHours.Weekdays()}
+}
+
+// workDay returns midnight of t's day in the working hours time zone.
+func workDay(t time.Time) time.Time {
+	t = t.In(cfg.WorkHours.Location())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// parseWorkDateFlag parses a YYYY-MM-DD date flag in the working hours
+// time zone.
+func parseWorkDateFlag(value string) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02", value, cfg.WorkHours.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD)", value)
+	}
+	return t, nil
+}
+
+var analyzeRebuild bool
+
+var analyzeRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Refresh the materialized analytics tables",
+	Long: `Refresh the pre-aggregated analytics tables (daily_meeting_minutes and
+person_meeting_counts) and rebuild event_instances, the expanded
+occurrences of recurring series.
+
+These tables are updated incrementally after every sync and import, so this
+command is only needed after manual database edits. Use --rebuild to
+recompute them from scratch.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		var result *store.AnalyticsRefresh
+		if analyzeRebuild {
+			result, err = s.RebuildAnalyticsTables()
+		} else {
+			result, err = s.RefreshAnalyticsTables()
+		}
+		if err != nil {
+			return fmt.Errorf("refresh analytics tables: %w", err)
+		}
+
+		mode := "Incremental refresh"
+		if result.FullRebuild {
+			mode = "Full rebuild"
+		}
+		out.Printf("%s complete: %d days, %d people updated\n", out.Good(mode), result.DaysUpdated, result.PeopleUpdated)
+
+		if cfg.Query.InstanceHorizonDays > 0 {
+			n, err := expandInstancesJob(s)
+			if err != nil {
+				return err
+			}
+			out.Printf("Expanded %d occurrences of recurring series\n", n)
+		}
+		return nil
+	},
+}
+
+// refreshDerivedTables brings the analytics tables and event_instances up
+// to date after a sync or import. Failures are only logged, as the synced
+// data itself is stored.
+func refreshDerivedTables(s *store.Store) {
+	if _, err := s.RefreshAnalyticsTables(); err != nil {
+		logger.Warn("failed to refresh analytics tables", "error", err)
+	}
+	if cfg.Query.InstanceHorizonDays > 0 {
+		if _, err := rebuildInstances(s); err != nil {
+			logger.Warn("failed to rebuild event instances", "error", err)
+		}
+	}
+}
+
+// rebuildInstances expands recurring series into event_instances up to
+// [query] instance_horizon_days from now.
+func rebuildInstances(s *store.Store) (int, error) {
+	n, err := agenda.RebuildInstances(s, time.Now().AddDate(0, 0, cfg.Query.InstanceHorizonDays))
+	if err != nil {
+		return 0, fmt.Errorf("rebuild event instances: %w", err)
+	}
+	return n, nil
+}
+
+// expandInstancesJob rebuilds event_instances as a job, so an expansion
+// cut short shows up in 'calvault jobs'.
+func expandInstancesJob(s *store.Store) (int, error) {
+	job := startJob(s, "expand", fmt.Sprintf("expand recurring series %d days ahead", cfg.Query.InstanceHorizonDays),
+		map[string]string{})
+	n, err := rebuildInstances(s)
+	job.add(int64(n))
+	job.finish(err)
+	return n, err
+}
+
+// addDurationSummary adds one row to the duration summary table.
+func addDurationSummary(t *render.Table, label string, d store.DurationSummary) {
+	t.Row(label, out.Number(int64(d.Count)), fmt.Sprintf("%.1f", d.TotalMinutes/60),
+		fmt.Sprintf("%.0fm", d.MedianMinutes), fmt.Sprintf("%.0fm", d.P90Minutes))
+}
+
+// formatTrendCell formats a monthly median with its event count.
+func formatTrendCell(d store.DurationSummary) string {
+	if d.Count == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0fm (%d)", d.MedianMinutes, d.Count)
+}
+
+// parseDateFlag parses a YYYY-MM-DD date flag. An empty value returns the zero time.
+func parseDateFlag(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD)", value)
+	}
+	return t, nil
+}
+
+func init() {
+	analyzeDurationsCmd.Flags().StringVar(&analyzeSince, "since", "", "Only include events starting on or after this date (YYYY-MM-DD)")
+	analyzeTeamCmd.Flags().StringVar(&analyzeSince, "since", "", "Only include meetings starting on or after this date (YYYY-MM-DD, default 4 weeks ago)")
+	analyzeCapacityCmd.Flags().StringVar(&analyzeSince, "since", "", "Only include meetings starting on or after this date (YYYY-MM-DD, default 4 weeks ago)")
+	analyzeRoomsCmd.Flags().StringVar(&analyzeSince, "since", "", "Only include bookings starting on or after this date (YYYY-MM-DD, default 4 weeks ago)")
+	analyzeRoomsCmd.Flags().StringArrayVar(&analyzeRooms, "room", nil, "Also treat calendars matching this ID or name (glob) as rooms (repeatable)")
+	analyzeInterviewsCmd.Flags().StringVar(&analyzeSince, "since", "", "Only include interviews starting on or after this date (YYYY-MM-DD, default 12 weeks ago)")
+	analyzeOffHoursCmd.Flags().StringVar(&analyzeSince, "since", "", "Only include meetings starting on or after this date (YYYY-MM-DD, default 6 months ago)")
+	analyzeRefreshCmd.Flags().BoolVar(&analyzeRebuild, "rebuild", false, "Recompute all analytics tables from scratch")
+	analyzeCmd.AddCommand(analyzeDurationsCmd)
+	analyzeCmd.AddCommand(analyzeTeamCmd)
+	analyzeCmd.AddCommand(analyzeCapacityCmd)
+	analyzeCmd.AddCommand(analyzeInterviewsCmd)
+	analyzeCmd.AddCommand(analyzeOffHoursCmd)
+	analyzeCmd.AddCommand(analyzeRoomsCmd)
+	analyzeCmd.AddCommand(analyzeRefreshCmd)
+	rootCmd.AddCommand(analyzeCmd)
+}
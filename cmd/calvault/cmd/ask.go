@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/salman1993/calvault/internal/ask"
+	"github.com/salman1993/calvault/internal/query"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	askRows bool
+	askJSON bool
+)
+
+var askCmd = &cobra.Command{
+	Use:   "ask <question>",
+	Short: "Answer a question about your calendar in plain language",
+	Long: `Answer a question in plain language: a language model writes SQL for it
+from the database schema, the SQL runs read-only like 'calvault query', and
+the model phrases the answer from the result. The SQL is printed with the
+answer so you can check it.
+
+The model is any chat completions endpoint speaking the OpenAI API,
+configured in config.toml. The question, the schema and the query result
+are sent to it; use a local model server to keep your calendar on your
+machine:
+
+  [llm]
+  endpoint = "http://localhost:11434/v1"   # Ollama
+  model = "llama3.1"
+  # api_key_env = "OPENAI_API_KEY"         # Environment variable with the key
+
+Queries obey the [query] settings (account scope, allowlist) and are
+recorded in the audit log.
+
+Examples:
+  calvault ask "how many times did I meet my dermatologist last year?"
+  calvault ask --rows "who did I meet most in March?"`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		question := strings.TrimSpace(args[0])
+		if question == "" {
+			return fmt.Errorf("empty question")
+		}
+		if !cfg.LLM.Enabled() {
+			return fmt.Errorf("no language model configured - set [llm] endpoint and model in %s", cfg.File)
+		}
+		if cfg.Query.AllowlistOnly {
+			return query.ErrNotAllowlisted
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		objects, err := s.SchemaObjects()
+		_ = s.Close()
+		if err != nil {
+			return fmt.Errorf("read schema: %w", err)
+		}
+
+		registry, err := buildNamedQueryRegistry()
+		if err != nil {
+			return err
+		}
+		executor, closeExecutor, err := openExecutor(registry)
+		if err != nil {
+			return err
+		}
+		defer closeExecutor()
+
+		client := &ask.Client{
+			Endpoint: cfg.LLM.Endpoint,
+			Model:    cfg.LLM.Model,
+			APIKey:   cfg.LLM.APIKey(),
+		}
+		answer, err := ask.Ask(cmd.Context(), client, executor, ask.Schema(objects), question)
+		if err != nil {
+			if answer != nil && answer.SQL != "" && !askJSON {
+				out.Println(out.Muted("SQL tried:"))
+				out.Println(out.Muted(indentLines(answer.SQL, "  ")))
+			}
+			return err
+		}
+
+		if askJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(struct {
+				Question string             `json:"question"`
+				Answer   string             `json:"answer"`
+				SQL      string             `json:"sql"`
+				Result   *query.QueryResult `json:"result"`
+			}{answer.Question, answer.Text, answer.SQL, answer.Result})
+		}
+
+		out.Println(answer.Text)
+		if askRows {
+			out.Println()
+			writeQueryTable(answer.Result)
+		}
+		out.Println()
+		out.Println(out.Muted("SQL:"))
+		out.Println(out.Muted(indentLines(answer.SQL, "  ")))
+		return nil
+	},
+}
+
+// indentLines prefixes every line of s with indent.
+func indentLines(s, indent string) string {
+	return indent + strings.ReplaceAll(s, "\n", "\n"+indent)
+}
+
+func init() {
+	askCmd.Flags().BoolVar(&askRows, "rows", false, "Also print the query result as a table")
+	askCmd.Flags().BoolVar(&askJSON, "json", false, "Output the question, answer, SQL and result as JSON")
+	askCmd.Flags().BoolVar(&queryNoCache, "no-cache", false, "Run queries even if cached results are available")
+	askCmd.Flags().StringVar(&queryCaller, "caller", defaultCaller(), "Caller name recorded in the audit log (env: CALVAULT_CALLER)")
+	rootCmd.AddCommand(askCmd)
+}
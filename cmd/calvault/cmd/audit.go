@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/query"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditLimit  int
+	auditCaller string
+	auditJSON   bool
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show the log of executed queries",
+	Long: `Show queries that were run against the archive through 'calvault query',
+most recent first.
+
+Every query is recorded with its SQL, caller, duration, and row count, so
+you can review exactly what an LLM agent asked of your data. Set the caller
+name with --caller on the query command or the CALVAULT_CALLER environment
+variable.
+
+Examples:
+  calvault audit
+  calvault audit --caller claude --limit 50
+  calvault audit --json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := openAuditStore()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = s.Close() }()
+
+		entries, err := s.ListQueryAudit(store.AuditFilter{
+			Caller: auditCaller,
+			Limit:  auditLimit,
+		})
+		if err != nil {
+			return fmt.Errorf("list audit log: %w", err)
+		}
+
+		if auditJSON {
+			out := make([]auditJSONEntry, 0, len(entries))
+			for _, e := range entries {
+				out = append(out, auditJSONEntry{
+					ExecutedAt: e.ExecutedAt.Format(time.RFC3339),
+					Caller:     e.Caller,
+					QueryName:  e.QueryName,
+					SQL:        e.SQL,
+					Params:     json.RawMessage(e.Params),
+					DurationMS: e.Duration.Milliseconds(),
+					RowCount:   e.RowCount,
+					Error:      e.ErrorMessage,
+				})
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(out)
+		}
+
+		if len(entries) == 0 {
+			out.Println("No queries recorded.")
+			return nil
+		}
+
+		for _, e := range entries {
+			status := out.Number(int64(e.RowCount)) + " rows"
+			if e.ErrorMessage != "" {
+				status = out.Bad("error: " + e.ErrorMessage)
+			}
+			name := ""
+			if e.QueryName != "" {
+				name = " [" + out.Accent(e.QueryName) + "]"
+			}
+			out.Printf("%s  %-12s %6dms  %s%s\n",
+				out.Muted(out.Timestamp(e.ExecutedAt.Local())), e.Caller,
+				e.Duration.Milliseconds(), status, name)
+			out.Printf("    %s\n", out.Muted(oneLine(e.SQL, 120)))
+		}
+
+		return nil
+	},
+}
+
+// auditJSONEntry is the JSON shape of an audit log entry.
+type auditJSONEntry struct {
+	ExecutedAt string          `json:"executed_at"`
+	Caller     string          `json:"caller"`
+	QueryName  string          `json:"query_name,omitempty"`
+	SQL        string          `json:"sql"`
+	Params     json.RawMessage `json:"params,omitempty"`
+	DurationMS int64           `json:"duration_ms"`
+	RowCount   int             `json:"row_count"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// storeAuditLogger adapts the store to the query.AuditLogger interface.
+type storeAuditLogger struct {
+	store *store.Store
+}
+
+func (l *storeAuditLogger) LogQuery(entry query.AuditEntry) error {
+	a := &store.QueryAudit{
+		Caller:    entry.Caller,
+		QueryName: entry.QueryName,
+		SQL:       entry.SQL,
+		Duration:  entry.Duration,
+		RowCount:  entry.RowCount,
+	}
+	if len(entry.Params) > 0 {
+		data, err := json.Marshal(entry.Params)
+		if err != nil {
+			return err
+		}
+		a.Params = string(data)
+	}
+	if entry.Err != nil {
+		a.ErrorMessage = entry.Err.Error()
+	}
+	return l.store.RecordQueryAudit(a)
+}
+
+// openAuditStore opens the database for reading or writing the audit log.
+func openAuditStore() (*store.Store, error) {
+	s, err := store.Open(cfg.DatabasePath())
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	if err := s.InitSchema(); err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("init schema: %w", err)
+	}
+	return s, nil
+}
+
+// defaultCaller returns the caller name for the audit log.
+func defaultCaller() string {
+	if c := os.Getenv("CALVAULT_CALLER"); c != "" {
+		return c
+	}
+	return "cli"
+}
+
+// oneLine collapses whitespace and truncates s to at most max characters.
+func oneLine(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > max {
+		return s[:max-3] + "..."
+	}
+	return s
+}
+
+func init() {
+	auditCmd.Flags().IntVarP(&auditLimit, "limit", "n", 20, "Maximum number of entries to show (0 for all)")
+	auditCmd.Flags().StringVar(&auditCaller, "caller", "", "Only show queries from this caller")
+	auditCmd.Flags().BoolVar(&auditJSON, "json", false, "Output as JSON")
+	rootCmd.AddCommand(auditCmd)
+}
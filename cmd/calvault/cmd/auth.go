@@ -0,0 +1,422 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/oauth"
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+var (
+	authStatusJSON      bool
+	authStatusOffline   bool
+	authRevokeLocal     bool
+	authRefreshHeadless bool
+)
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Inspect, revoke and renew account OAuth tokens",
+}
+
+var authStatusCmd = &cobra.Command{
+	Use:   "status [account]",
+	Short: "Check each account's token, expiry, scopes and refresh",
+	Long: `Check the OAuth token of every Google and Microsoft account, or of one
+account: whether it is saved and refreshable, when its access token
+expires, which scopes it carries, and whether the provider still accepts
+it.
+
+The last check refreshes the token the way a sync would, so a revoked or
+expired grant (invalid_grant) shows up here rather than halfway through a
+sync; the refreshed token is saved. --offline skips it and only reads the
+saved token.
+
+Examples:
+  calvault auth status
+  calvault auth status you@gmail.com --offline
+  calvault auth status --json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		sources, err := s.ListSources()
+		if err != nil {
+			return fmt.Errorf("list sources: %w", err)
+		}
+		var accounts []*store.Source
+		for _, src := range sources {
+			if tokensDirFor(src.SourceType) == "" {
+				continue
+			}
+			if len(args) == 1 && !strings.EqualFold(src.Identifier, args[0]) {
+				continue
+			}
+			accounts = append(accounts, src)
+		}
+		if len(args) == 1 && len(accounts) == 0 {
+			return fmt.Errorf("account %q not found (or has no token, like imported calendars)", args[0])
+		}
+
+		managers := oauthManagers{}
+		statuses := make([]*authStatus, 0, len(accounts))
+		for _, src := range accounts {
+			statuses = append(statuses, checkAuth(cmd.Context(), managers, src, !authStatusOffline))
+		}
+
+		if authStatusJSON {
+			entries := make([]authStatusJSONEntry, 0, len(statuses))
+			for _, st := range statuses {
+				entries = append(entries, st.json())
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(entries)
+		}
+
+		if len(statuses) == 0 {
+			out.Println("No accounts with tokens. Run 'calvault add-account <email>' to add one.")
+			return nil
+		}
+		t := render.NewTable("Account", "Provider", "Token", "Expires", "Scopes", "Refresh")
+		for _, st := range statuses {
+			expires := out.Muted("-")
+			if !st.info.Expiry.IsZero() {
+				expires = out.DateTime(st.info.Expiry.Local())
+				if st.info.Expiry.Before(time.Now()) {
+					expires = out.Muted(expires)
+				}
+			}
+			scopes := out.Good(fmt.Sprintf("%d granted", len(st.scopes)))
+			if len(st.missing) > 0 {
+				scopes = out.Bad("missing " + strings.Join(st.missing, ", "))
+			} else if len(st.scopes) == 0 {
+				scopes = out.Muted("unknown")
+			}
+			t.Row(out.Accent(st.src.Identifier), st.src.SourceType, styleTokenState(st.info.State), expires, scopes, st.styledRefresh())
+		}
+		out.Table(t)
+
+		for _, st := range statuses {
+			if hint := st.hint(); hint != "" {
+				out.Printf("%s: %s\n", st.src.Identifier, hint)
+			}
+		}
+		return nil
+	},
+}
+
+var authRevokeCmd = &cobra.Command{
+	Use:   "revoke <email>",
+	Short: "Revoke an account's access and delete its saved token",
+	Long: `Revoke calvault's access to an account at the provider and delete the
+saved OAuth token. The archived calendars and events are kept; run
+'calvault auth refresh' to authorize the account again, or
+'calvault remove-account' to delete its data too.
+
+Google revokes the whole grant. Microsoft has no API to revoke a token, so
+only the saved token is deleted; remove calvault from the account's app
+permissions to end its access.
+
+If the provider can't be reached the token is kept, so the revoke can be
+retried; --local deletes it without contacting the provider.
+
+Examples:
+  calvault auth revoke old@gmail.com
+  calvault auth revoke old@gmail.com --local`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		src, err := tokenAccount(args[0])
+		if err != nil {
+			return err
+		}
+		dir := tokensDirFor(src.SourceType)
+		if oauth.CheckToken(dir, src.Identifier) == oauth.TokenMissing {
+			return fmt.Errorf("no saved token for %s", src.Identifier)
+		}
+
+		if !authRevokeLocal {
+			mgr, err := newOAuthManager(src.SourceType)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
+			err = mgr.Revoke(ctx, src.Identifier)
+			cancel()
+			switch {
+			case err == nil:
+				out.Printf("%s calvault's access to %s\n", out.Good("Revoked"), out.Accent(src.Identifier))
+			case errors.Is(err, oauth.ErrTokenInvalid):
+				out.Println(out.Muted("The provider no longer accepted the token: it was already revoked or had expired."))
+			case errors.Is(err, oauth.ErrRevokeUnsupported):
+				out.Println("Microsoft can't revoke tokens by API; to end calvault's access, remove it under the")
+				out.Println("account's app permissions (https://account.live.com/consent/Manage, or https://myapps.microsoft.com for work accounts).")
+			default:
+				return fmt.Errorf("%w; the saved token is kept - retry, or use --local to only delete it", err)
+			}
+		}
+
+		if err := oauth.DeleteToken(dir, src.Identifier); err != nil {
+			return fmt.Errorf("delete token: %w", err)
+		}
+		logger.Info("revoked token", "account", src.Identifier, "local", authRevokeLocal)
+		out.Printf("%s the saved token of %s\n", out.Good("Deleted"), out.Accent(src.Identifier))
+		out.Printf("Its archive is kept. Run 'calvault auth refresh %s' to authorize it again.\n", src.Identifier)
+		return nil
+	},
+}
+
+var authRefreshCmd = &cobra.Command{
+	Use:   "refresh <email>",
+	Short: "Sign in to an account again, replacing its saved token",
+	Long: `Run the OAuth sign-in flow for an account that was already added,
+replacing its saved token: after the grant was revoked or expired
+(invalid_grant), to grant scopes the token lacks, or after 'calvault auth
+revoke'. The old token is kept if the sign-in fails.
+
+Use --headless for the device code flow, as with add-account.
+
+Examples:
+  calvault auth refresh you@gmail.com
+  calvault auth refresh you@gmail.com --headless`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		src, err := tokenAccount(args[0])
+		if err != nil {
+			return err
+		}
+		mgr, err := newOAuthManager(src.SourceType)
+		if err != nil {
+			return err
+		}
+
+		if authRefreshHeadless {
+			out.Println("Starting device code flow...")
+		} else {
+			out.Println("Starting browser authorization...")
+		}
+		if err := mgr.Authorize(cmd.Context(), src.Identifier, authRefreshHeadless); err != nil {
+			return fmt.Errorf("authorization failed: %w", err)
+		}
+
+		logger.Info("re-authorized account", "account", src.Identifier)
+		out.Printf("\n%s %s\n", out.Good("Re-authorized"), out.Accent(src.Identifier))
+		return nil
+	},
+}
+
+// tokenAccount returns the stored account with an OAuth token named email.
+func tokenAccount(email string) (*store.Source, error) {
+	s, err := store.Open(cfg.DatabasePath())
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err := s.InitSchema(); err != nil {
+		return nil, fmt.Errorf("init schema: %w", err)
+	}
+	src, err := s.GetSourceByIdentifier(email)
+	if err != nil {
+		return nil, fmt.Errorf("get source: %w", err)
+	}
+	if src == nil {
+		return nil, fmt.Errorf("account %q not found - run 'calvault add-account %s' to add it", email, email)
+	}
+	if tokensDirFor(src.SourceType) == "" {
+		return nil, fmt.Errorf("account %q has no OAuth token (imported calendars don't)", email)
+	}
+	return src, nil
+}
+
+// authStatus is the token health of one account.
+type authStatus struct {
+	src     *store.Source
+	info    *oauth.TokenInfo
+	scopes  []string // Granted, as reported by a refresh, else as saved
+	missing []string // Required scopes not among scopes
+
+	refreshed    bool
+	refreshErr   error
+	refreshSkip  string // Why no refresh was attempted
+	unconfigured bool   // The provider isn't set up in config.toml
+}
+
+// checkAuth inspects an account's saved token and, if refresh is set and
+// the token can be refreshed, refreshes it.
+func checkAuth(ctx context.Context, managers oauthManagers, src *store.Source, refresh bool) *authStatus {
+	st := &authStatus{src: src, info: oauth.InspectToken(tokensDirFor(src.SourceType), src.Identifier)}
+	st.scopes = st.info.Scopes
+
+	mgr, err := managers.get(src.SourceType)
+	switch {
+	case err != nil:
+		st.unconfigured = true
+		st.refreshSkip = "provider not configured"
+	case st.info.State != oauth.TokenValid:
+		st.refreshSkip = "no usable token"
+	case !st.info.HasRefresh:
+		st.refreshSkip = "no refresh token"
+	case !refresh:
+		st.refreshSkip = "offline"
+	default:
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		granted, err := mgr.Refresh(ctx, src.Identifier)
+		cancel()
+		if err != nil {
+			st.refreshErr = err
+		} else {
+			st.refreshed = true
+			st.info = oauth.InspectToken(tokensDirFor(src.SourceType), src.Identifier)
+			if len(granted) > 0 {
+				st.scopes = granted
+			}
+		}
+	}
+
+	if mgr != nil && len(st.scopes) > 0 {
+		st.missing = missingScopes(mgr.RequiredScopes(), st.scopes)
+	}
+	return st
+}
+
+// missingScopes returns the required scopes that aren't granted. Providers
+// may report scopes as full URIs (https://graph.microsoft.com/Calendars.Read)
+// and leave out ones that only shape the grant, such as offline_access.
+func missingScopes(required, granted []string) []string {
+	var missing []string
+	for _, r := range required {
+		if r == "offline_access" || r == "openid" {
+			continue
+		}
+		found := false
+		for _, g := range granted {
+			if strings.EqualFold(g, r) || strings.HasSuffix(strings.ToLower(g), "/"+strings.ToLower(r)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+// refreshState returns "ok", "failed" or "skipped".
+func (st *authStatus) refreshState() string {
+	switch {
+	case st.refreshed:
+		return "ok"
+	case st.refreshErr != nil:
+		return "failed"
+	default:
+		return "skipped"
+	}
+}
+
+// refreshError returns why the refresh failed: the provider's error code
+// if it gave one.
+func (st *authStatus) refreshError() string {
+	var re *oauth2.RetrieveError
+	switch {
+	case st.refreshErr == nil:
+		return ""
+	case errors.As(st.refreshErr, &re) && re.ErrorCode != "":
+		return re.ErrorCode
+	default:
+		return st.refreshErr.Error()
+	}
+}
+
+func (st *authStatus) styledRefresh() string {
+	switch st.refreshState() {
+	case "ok":
+		return out.Good("ok")
+	case "failed":
+		return out.Bad("failed: " + oneLine(st.refreshError(), 40))
+	default:
+		return out.Muted(st.refreshSkip)
+	}
+}
+
+// hint suggests a fix for an unhealthy account, or returns "".
+func (st *authStatus) hint() string {
+	var re *oauth2.RetrieveError
+	switch {
+	case st.unconfigured && st.src.SourceType == store.SourceTypeMicrosoft:
+		return "Microsoft accounts aren't configured; set [microsoft] client_id in config.toml"
+	case st.unconfigured:
+		return "Google accounts aren't configured; set [oauth] client_secrets in config.toml"
+	case st.info.State == oauth.TokenMissing || st.info.State == oauth.TokenExpired || st.info.State == oauth.TokenUnreadable:
+		return "run 'calvault auth refresh " + st.src.Identifier + "' to sign in again"
+	case errors.As(st.refreshErr, &re) && re.ErrorCode == "invalid_grant":
+		return "the grant was revoked or has expired; run 'calvault auth refresh " + st.src.Identifier + "' to sign in again"
+	case st.refreshErr != nil:
+		return "refresh failed: " + st.refreshErr.Error()
+	case len(st.missing) > 0:
+		return "the token lacks scopes syncing needs; run 'calvault auth refresh " + st.src.Identifier + "' and grant calendar access"
+	}
+	return ""
+}
+
+// authStatusJSONEntry is the JSON shape of an account's token health.
+type authStatusJSONEntry struct {
+	Account       string   `json:"account"`
+	Provider      string   `json:"provider"`
+	Token         string   `json:"token"`
+	Expiry        string   `json:"expiry,omitempty"`
+	RefreshToken  bool     `json:"refresh_token"`
+	Scopes        []string `json:"scopes"`
+	MissingScopes []string `json:"missing_scopes,omitempty"`
+	Refresh       string   `json:"refresh"` // ok, failed or skipped
+	RefreshError  string   `json:"refresh_error,omitempty"`
+	Hint          string   `json:"hint,omitempty"`
+}
+
+func (st *authStatus) json() authStatusJSONEntry {
+	e := authStatusJSONEntry{
+		Account:       st.src.Identifier,
+		Provider:      st.src.SourceType,
+		Token:         st.info.State,
+		RefreshToken:  st.info.HasRefresh,
+		Scopes:        st.scopes,
+		MissingScopes: st.missing,
+		Refresh:       st.refreshState(),
+		RefreshError:  st.refreshError(),
+		Hint:          st.hint(),
+	}
+	if e.Scopes == nil {
+		e.Scopes = []string{}
+	}
+	if !st.info.Expiry.IsZero() {
+		e.Expiry = st.info.Expiry.Format(time.RFC3339)
+	}
+	return e
+}
+
+func init() {
+	authStatusCmd.Flags().BoolVar(&authStatusJSON, "json", false, "Output as JSON")
+	authStatusCmd.Flags().BoolVar(&authStatusOffline, "offline", false, "Only read saved tokens; don't try a refresh")
+	authRevokeCmd.Flags().BoolVar(&authRevokeLocal, "local", false, "Only delete the saved token; don't contact the provider")
+	authRefreshCmd.Flags().BoolVar(&authRefreshHeadless, "headless", false, "Use device code flow for headless environments")
+	authCmd.AddCommand(authStatusCmd, authRevokeCmd, authRefreshCmd)
+	rootCmd.AddCommand(authCmd)
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	backupGzip      bool
+	backupTimestamp bool
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup <dest>",
+	Short: "Write a consistent copy of the database",
+	Long: `Write a copy of the database to a file, or into a directory as
+calvault-<timestamp>.db.
+
+The copy is made with SQLite's VACUUM INTO, so it is consistent and
+compacted even while a sync or the daemon is writing: it holds the data as
+of the moment the backup started. It is checked with SQLite's quick_check
+before it is moved into place, and is only readable by you. An existing
+file is never overwritten.
+
+--gzip compresses the copy (adding .gz to the name; a name ending in .gz
+implies it), and --timestamp adds the time to a file name, for backups kept
+side by side. Restore a copy with 'calvault restore'.
+
+Examples:
+  calvault backup ~/backups/
+  calvault backup ~/backups/calvault.db --gzip --timestamp
+  calvault backup /mnt/nas/calvault.db.gz`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dbPath := cfg.DatabasePath()
+		if _, err := os.Stat(dbPath); err != nil {
+			return fmt.Errorf("no database at %s: %w", dbPath, err)
+		}
+
+		compress := backupGzip || strings.HasSuffix(args[0], ".gz")
+		dest, err := backupPath(args[0], compress, backupTimestamp, time.Now())
+		if err != nil {
+			return err
+		}
+
+		s, err := store.Open(dbPath)
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		start := time.Now()
+		size, err := s.Backup(dest, compress)
+		if err != nil {
+			return fmt.Errorf("backup: %w", err)
+		}
+		logger.Info("backed up database", "to", dest, "bytes", size, "duration", time.Since(start))
+		out.Printf("%s database to %s (%s)\n", out.Good("Backed up"), out.Accent(dest), formatSize(size))
+		return nil
+	},
+}
+
+// backupPath returns the file a backup to dest is written to: dest itself,
+// or calvault-<timestamp>.db in it if dest is a directory (or ends in a
+// separator, creating it). With compress the name ends in .gz; with
+// timestamp a file name gets the time before its extension.
+func backupPath(dest string, compress, timestamp bool, now time.Time) (string, error) {
+	stamp := now.Format("20060102-150405")
+	info, err := os.Stat(dest)
+	isDir := err == nil && info.IsDir()
+	if !isDir && (strings.HasSuffix(dest, "/") || strings.HasSuffix(dest, string(filepath.Separator))) {
+		if err := os.MkdirAll(dest, 0o700); err != nil {
+			return "", fmt.Errorf("create backup directory: %w", err)
+		}
+		isDir = true
+	}
+
+	var path string
+	if isDir {
+		path = filepath.Join(dest, "calvault-"+stamp+".db")
+	} else {
+		path = strings.TrimSuffix(dest, ".gz")
+		if timestamp {
+			ext := filepath.Ext(path)
+			path = strings.TrimSuffix(path, ext) + "-" + stamp + ext
+		}
+	}
+	if compress {
+		path += ".gz"
+	}
+	if _, err := os.Stat(filepath.Dir(path)); err != nil {
+		return "", fmt.Errorf("backup directory: %w", err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("%s already exists", path)
+	}
+	return path, nil
+}
+
+// formatSize formats a byte count for display.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
+
+func init() {
+	backupCmd.Flags().BoolVarP(&backupGzip, "gzip", "z", false, "Compress the copy with gzip")
+	backupCmd.Flags().BoolVar(&backupTimestamp, "timestamp", false, "Add the date and time to the file name")
+	rootCmd.AddCommand(backupCmd)
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/salman1993/calvault/internal/bench"
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchEvents     int
+	benchSeed       int64
+	benchIterations int
+	benchDir        string
+)
+
+var benchCmd = &cobra.Command{
+	Use:    "bench",
+	Short:  "Measure store and sync performance on a demo archive",
+	Hidden: true,
+	Long: `Measure performance on a demo archive (see 'calvault demo'), generated in
+a temporary directory and removed afterwards; your database isn't touched.
+
+  upsert  Events written per second, in transactions of 250 as sync does
+  query   Latency of listing, filtering and searching events, the analytics
+          reports and SQL through the query executor
+  sync    Events a full sync stores per second, from a local fake of the
+          Google Calendar API
+
+The same --events and --seed give the same archive, so results can be
+compared before and after a change. 'go test -bench . ./internal/bench'
+runs the same measurements as Go benchmarks.
+
+Examples:
+  calvault bench
+  calvault bench --events 100000 --iterations 50`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if benchEvents <= 0 {
+			return fmt.Errorf("--events must be positive")
+		}
+		dir := benchDir
+		if dir == "" {
+			tmp, err := os.MkdirTemp("", "calvault-bench-*")
+			if err != nil {
+				return fmt.Errorf("create directory: %w", err)
+			}
+			defer func() { _ = os.RemoveAll(tmp) }()
+			dir = tmp
+		} else if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("create directory: %w", err)
+		}
+
+		out.Printf("Generating a demo archive of %s events in %s...\n\n", out.Number(int64(benchEvents)), dir)
+		t := render.NewTable("Benchmark", "Ops", "Total", "Per op", "P90", "Per second").AlignRight(1, 2, 3, 4, 5)
+		err := bench.Run(cmd.Context(), dir, bench.Options{Events: benchEvents, Seed: benchSeed, Iterations: benchIterations}, func(r bench.Result) {
+			p90 := out.Muted("-")
+			if r.P90 > 0 {
+				p90 = formatBenchDuration(r.P90)
+			}
+			t.Row(r.Name, out.Number(int64(r.Ops)), formatBenchDuration(r.Duration), formatBenchDuration(r.PerOp()),
+				p90, strconv.FormatFloat(r.PerSecond(), 'f', 0, 64)+" "+r.Unit)
+			logger.Debug("benchmark", "name", r.Name, "ops", r.Ops, "duration", r.Duration)
+		})
+		out.Table(t)
+		return err
+	},
+}
+
+// formatBenchDuration rounds a duration to three significant digits.
+func formatBenchDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond / 10).String()
+	}
+}
+
+func init() {
+	benchCmd.Flags().IntVar(&benchEvents, "events", 20000, "Number of events in the demo archive")
+	benchCmd.Flags().Int64Var(&benchSeed, "seed", 1, "Seed of the demo archive")
+	benchCmd.Flags().IntVar(&benchIterations, "iterations", 20, "Runs of each query")
+	benchCmd.Flags().StringVar(&benchDir, "dir", "", "Keep the databases in this directory (default: a temporary one)")
+	rootCmd.AddCommand(benchCmd)
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/salman1993/calvault/internal/changelog"
+	"github.com/spf13/cobra"
+)
+
+var changelogFollow bool
+
+// changelogPollInterval is how often --follow checks the changelog for
+// new records.
+const changelogPollInterval = time.Second
+
+var changelogCmd = &cobra.Command{
+	Use:   "changelog [file]",
+	Short: "Print the sync changelog as JSON lines",
+	Long: `Print the records of the changelog syncs append to as JSON, one per line.
+
+With [sync] changelog set, every sync appends the event changes it makes to
+that file (relative paths are in CALVAULT_HOME):
+
+  [sync]
+  changelog = "changelog.cbor"
+
+The file is a CBOR sequence (RFC 8742) meant for other local tools to read
+directly with any CBOR library, instead of polling the database. Each sync
+writes a "run" record (run, account, kind, time), an "added", "updated" or
+"deleted" record per event change (calendar, event_id and, except for
+deletions, ical_uid, title, start, end, all_day, status), and an "end"
+record with its totals (added, updated, deleted) if it finished. Every
+record carries its run - the run's start in Unix milliseconds - and
+account. Times are CBOR epoch times (tag 1).
+
+This command prints the file, by default the configured one, for scripts
+and for a look at what syncs changed. --follow keeps printing records as
+syncs append them, like tail -f. The file only grows; truncate or rotate it
+once its readers have caught up.
+
+Examples:
+  calvault changelog
+  calvault changelog --follow | jq -c 'select(.type == "added")'`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := cfg.ChangelogPath()
+		if len(args) == 1 {
+			path = args[0]
+		}
+		if path == "" {
+			return fmt.Errorf("no changelog configured; set [sync] changelog in config.toml or pass a file")
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		f, err := os.Open(path)
+		for errors.Is(err, os.ErrNotExist) && changelogFollow {
+			// Wait for the first sync to create it
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(changelogPollInterval):
+			}
+			f, err = os.Open(path)
+		}
+		if err != nil {
+			return fmt.Errorf("open changelog: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+
+		w := bufio.NewWriter(os.Stdout)
+		defer func() { _ = w.Flush() }()
+		var offset int64
+		r := changelog.NewReader(f)
+		for {
+			rec, err := r.Read()
+			if err == nil {
+				if err := writeChangelogRecord(w, rec); err != nil {
+					return err
+				}
+				continue
+			}
+			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return fmt.Errorf("read changelog at byte %d: %w", offset+r.Offset(), err)
+			}
+			if !changelogFollow {
+				if errors.Is(err, io.ErrUnexpectedEOF) {
+					logger.Warn("changelog ends partway through a record", "bytes", offset+r.Offset())
+				}
+				return nil
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(changelogPollInterval):
+			}
+			// Start again after the last whole record
+			offset += r.Offset()
+			if _, err := f.Seek(offset, io.SeekStart); err != nil {
+				return fmt.Errorf("seek changelog: %w", err)
+			}
+			r = changelog.NewReader(f)
+		}
+	},
+}
+
+// writeChangelogRecord writes a record as a JSON object with its fields in
+// file order and times in RFC 3339.
+func writeChangelogRecord(w io.Writer, rec *changelog.Record) error {
+	var pairs []string
+	for _, f := range rec.Fields() {
+		v := f.Value
+		if t, ok := v.(time.Time); ok {
+			v = t.UTC().Format(time.RFC3339)
+		}
+		key, _ := json.Marshal(f.Key)
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		pairs = append(pairs, string(key)+":"+string(value))
+	}
+	_, err := fmt.Fprintf(w, "{%s}\n", strings.Join(pairs, ","))
+	return err
+}
+
+func init() {
+	changelogCmd.Flags().BoolVarP(&changelogFollow, "follow", "f", false, "Keep printing records as syncs append them")
+	rootCmd.AddCommand(changelogCmd)
+}
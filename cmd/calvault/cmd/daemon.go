@@ -0,0 +1,562 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/salman1993/calvault/internal/alert"
+	"github.com/salman1993/calvault/internal/daemon"
+	"github.com/salman1993/calvault/internal/digest"
+	"github.com/salman1993/calvault/internal/metrics"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/salman1993/calvault/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run incremental syncs in the background on a schedule",
+	Long: `Run incremental syncs for every account with an OAuth token, repeating on
+the schedule configured in config.toml:
+
+  [daemon]
+  schedule = "@every 30m"              # default for all accounts
+
+  [daemon.accounts]
+  "you@company.com" = "*/10 8-18 * * 1-5"
+
+Schedules are five-field cron expressions (minute hour day-of-month month
+day-of-week) in local time, @hourly/@daily/@weekly/@monthly, or
+"@every <duration>".
+
+Syncs are skipped during [daemon] quiet_hours (local-time windows such as
+"22:00-07:00") and while paused with 'calvault pause'; the next scheduled
+run after that catches up.
+
+Google accounts' calendar lists are checked for changes every 10 minutes;
+a newly added or subscribed calendar triggers a sync of that account right
+away instead of waiting for its next scheduled run.
+
+With [daemon.push] address set, the daemon also registers Google Calendar
+push notification channels for every calendar and listens for them on
+[daemon.push] listen (default 127.0.0.1:8765). A change triggers an
+incremental sync of that account within seconds. Google only delivers to
+public HTTPS URLs, so address is usually a reverse proxy or tunnel to the
+listen address. Channels last 7 days and are renewed hourly as needed.
+
+With [daemon] metrics_listen set (e.g. "127.0.0.1:9464"), the daemon
+serves Prometheus metrics at /metrics on that address: syncs and their
+duration and last success per account, events added, updated and deleted,
+calendar API requests by status code, and rate limiter waits and how long
+they took.
+
+[[daemon.alert]] rules notify after each sync about upcoming events
+matching their keywords (in titles, locations and descriptions) that the
+sync added, changed or cancelled:
+
+  [[daemon.alert]]
+  name = "dentist"
+  keywords = ["dentist"]
+  on = ["added", "moved"]              # default: added, changed, cancelled
+  webhook = "https://hooks.example.com/calvault"
+  desktop = true
+
+Webhooks receive the alert as a JSON POST; commands get it on stdin and in
+CALVAULT_ALERT_* variables; desktop = true raises a native notification
+(Notification Center on macOS, a toast on Windows, notify-send on Linux).
+"moved" matches changes to an event's start or end. A new rule starts from the events matching when
+the daemon starts, and alerts that fail to send are retried after the next
+sync.
+
+[daemon.digest] sends a daily summary - today's agenda and yesterday's
+meetings, with the hours each day's meetings take - by email, webhook or
+both:
+
+  [daemon.digest]
+  schedule = "0 7 * * 1-5"             # default: 7:00 every day
+  webhook = "https://hooks.slack.com/services/..."
+  template = "~/.calvault/digest.tmpl" # Go text/template; default built in
+
+  [daemon.digest.smtp]
+  host = "smtp.example.com"            # port defaults to 587 (STARTTLS)
+  username = "you@example.com"
+  password_env = "CALVAULT_SMTP_PASSWORD"
+  from = "you@example.com"
+  to = ["you@example.com"]
+
+Webhooks receive JSON with the rendered digest in "text" (which Slack-style
+incoming webhooks post) and its data in "digest".
+
+With [storage] retention set (e.g. "10y"), events that ended longer ago
+than that are deleted daily, as 'calvault purge --older-than' does; they
+stay in the trash for [storage] trash_days.
+
+Only one daemon runs at a time. Each sync holds the same lock as the sync
+command, so a manual sync and a scheduled one never overlap; a scheduled
+sync that finds the lock held is skipped. Stop with Ctrl+C or SIGTERM.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Scheduled syncs print a line per calendar between the logs
+		noProgress = true
+
+		daemonLock, err := daemon.AcquireLock(cfg.DaemonLockPath())
+		if errors.Is(err, daemon.ErrLocked) {
+			return fmt.Errorf("daemon is already running: %w", err)
+		}
+		if err != nil {
+			return err
+		}
+		defer func() { _ = daemonLock.Release() }()
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+		if err := applyStorageSettings(s); err != nil {
+			return err
+		}
+
+		var quiet []daemon.Window
+		for _, spec := range cfg.Daemon.QuietHours {
+			w, err := daemon.ParseWindow(spec)
+			if err != nil {
+				return fmt.Errorf("daemon.quiet_hours: %w", err)
+			}
+			quiet = append(quiet, w)
+		}
+
+		alerts := newAlertChecker(s)
+		if alerts != nil {
+			// Record what new rules match now, so they alert on later changes only
+			if _, err := alerts.Check(cmd.Context()); err != nil {
+				logger.Error("alert check failed", "error", err)
+			}
+			fmt.Printf("Checking %d alert rule(s) after each sync\n", len(cfg.Daemon.Alerts))
+		}
+
+		managers := oauthManagers{}
+		accounts, err := syncableAccounts(s, managers)
+		if err != nil {
+			return err
+		}
+
+		var jobs []daemon.Job
+		for _, src := range accounts {
+			expr := cfg.Daemon.ScheduleFor(src.Identifier)
+			schedule, err := daemon.ParseSchedule(expr)
+			if err != nil {
+				return fmt.Errorf("schedule for %s: %w", src.Identifier, err)
+			}
+			fmt.Printf("Scheduling %s: %s\n", src.Identifier, expr)
+			jobs = append(jobs, daemon.Job{
+				Name:     src.Identifier,
+				Schedule: schedule,
+				Run: func(ctx context.Context) error {
+					if reason := syncHold(quiet, time.Now()); reason != "" {
+						logger.Info("skipping scheduled sync", "account", src.Identifier, "reason", reason)
+						return nil
+					}
+					return runScheduledSync(ctx, s, managers, src, alerts)
+				},
+			})
+		}
+
+		if cfg.Daemon.Digest.Enabled() {
+			job, err := digestJob(s)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Sending a digest: %s\n", cfg.Daemon.Digest.Schedule)
+			jobs = append(jobs, job)
+		}
+
+		if age, ok := cfg.Storage.RetentionAge(); ok {
+			fmt.Printf("Purging events older than %s daily\n", cfg.Storage.Retention)
+			jobs = append(jobs, retentionJob(s, age, cfg.Storage.TrashPeriod()))
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		var push *pushReceiver
+		if cfg.Daemon.Push.Address != "" {
+			push, err = newPushReceiver(ctx, s, managers, accounts)
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, push.renewJob())
+		}
+
+		checker, err := newCalendarChecker(ctx, s, managers, accounts, quiet, push != nil)
+		if err != nil {
+			return err
+		}
+		if checker != nil {
+			jobs = append(jobs, checker.job())
+		}
+
+		runner := daemon.NewRunner(jobs).WithLogger(logger)
+		if checker != nil {
+			checker.runner = runner
+		}
+		if push != nil {
+			if err := push.start(ctx, runner); err != nil {
+				return err
+			}
+		}
+		if cfg.Daemon.MetricsListen != "" {
+			if err := serveMetrics(ctx, cfg.Daemon.MetricsListen); err != nil {
+				return err
+			}
+		}
+
+		err = runner.Run(ctx)
+		if errors.Is(err, context.Canceled) {
+			fmt.Println("\nDaemon stopped.")
+			return nil
+		}
+		return err
+	},
+}
+
+// syncHold returns why scheduled syncs are held at now - a pause or quiet
+// hours - or "" if they may run.
+func syncHold(quiet []daemon.Window, now time.Time) string {
+	until, paused, err := daemon.PausedUntil(cfg.PausePath(), now)
+	switch {
+	case err != nil:
+		logger.Warn("ignoring pause file", "error", err)
+	case paused && until.IsZero():
+		return "paused"
+	case paused:
+		return "paused until " + until.Format(time.RFC3339)
+	}
+	for _, w := range quiet {
+		if w.Contains(now) {
+			return "quiet hours"
+		}
+	}
+	return ""
+}
+
+// runScheduledSync runs one incremental sync for an account under the sync
+// lock, then refreshes the analytics tables, runs the enrichment stages if
+// [enrich] after_sync is set, records the archive's statistics and checks
+// alert rules, if any.
+func runScheduledSync(ctx context.Context, s *store.Store, managers oauthManagers, src *store.Source, alerts *alert.Checker) error {
+	lock, err := acquireSyncLock()
+	if errors.Is(err, daemon.ErrLocked) {
+		logger.Info("skipping scheduled sync", "account", src.Identifier, "reason", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lock.Release() }()
+
+	if err := runSync(ctx, s, newSyncRun(s, false), managers[src.SourceType], src, true, false); err != nil {
+		return err
+	}
+	refreshDerivedTables(s)
+	enrichAfterSync(ctx, s)
+	recordStatsSnapshot(s, "daemon")
+	if alerts != nil {
+		if _, err := alerts.Check(ctx); err != nil {
+			logger.Error("alert check failed", "account", src.Identifier, "error", err)
+		}
+	}
+	return nil
+}
+
+// newAlertChecker returns a checker for the [[daemon.alert]] rules, or nil
+// if there are none.
+func newAlertChecker(s *store.Store) *alert.Checker {
+	if len(cfg.Daemon.Alerts) == 0 {
+		return nil
+	}
+	rules := make([]alert.Rule, 0, len(cfg.Daemon.Alerts))
+	for i := range cfg.Daemon.Alerts {
+		a := &cfg.Daemon.Alerts[i]
+		rules = append(rules, alert.Rule{
+			Name:            a.Name,
+			Keywords:        a.Keywords,
+			IncludeCalendar: a.IncludeCalendar,
+			On:              a.On,
+			Webhook:         a.Webhook,
+			Command:         a.Command,
+			Desktop:         a.Desktop,
+		})
+	}
+	return alert.NewChecker(s, rules).WithLogger(logger)
+}
+
+// digestJob sends the [daemon.digest] on its schedule. The template is
+// parsed up front so a broken one stops the daemon from starting.
+func digestJob(s *store.Store) (daemon.Job, error) {
+	dc := &cfg.Daemon.Digest
+	schedule, err := daemon.ParseSchedule(dc.Schedule)
+	if err != nil {
+		return daemon.Job{}, fmt.Errorf("daemon.digest schedule: %w", err)
+	}
+	tmpl, err := digest.Template(dc.Template)
+	if err != nil {
+		return daemon.Job{}, err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	return daemon.Job{
+		Name:     "send-digest",
+		Schedule: schedule,
+		Run: func(ctx context.Context) error {
+			d, err := digest.Build(s, time.Now())
+			if err != nil {
+				return fmt.Errorf("build digest: %w", err)
+			}
+			text, err := d.Render(tmpl)
+			if err != nil {
+				return err
+			}
+			if dc.Webhook != "" {
+				if err := digest.PostWebhook(ctx, client, dc.Webhook, d, text); err != nil {
+					return fmt.Errorf("post digest: %w", err)
+				}
+			}
+			if dc.SMTP.Host != "" {
+				mail := &digest.Mail{
+					Host:     dc.SMTP.Host,
+					Port:     dc.SMTP.Port,
+					Username: dc.SMTP.Username,
+					Password: dc.SMTP.Password(),
+					From:     dc.SMTP.From,
+					To:       dc.SMTP.To,
+				}
+				if err := mail.Send(d, text); err != nil {
+					return err
+				}
+			}
+			logger.Info("sent digest", "events", len(d.Today.Events))
+			return nil
+		},
+	}, nil
+}
+
+// calendarCheckSchedule is how often the daemon checks Google accounts for
+// newly added calendars.
+const calendarCheckSchedule = "@every 10m"
+
+// calendarChecker watches Google accounts' calendar lists and triggers a
+// sync of an account as soon as a calendar is added to it, rather than at
+// the account's next scheduled sync.
+type calendarChecker struct {
+	syncers map[string]*sync.Syncer // account email -> syncer
+	quiet   []daemon.Window
+	push    bool           // Also renew watch channels for new calendars
+	runner  *daemon.Runner // Set once the runner exists
+}
+
+// newCalendarChecker returns a checker for the Google accounts, or nil if
+// there are none.
+func newCalendarChecker(ctx context.Context, s *store.Store, managers oauthManagers, accounts []*store.Source, quiet []daemon.Window, push bool) (*calendarChecker, error) {
+	c := &calendarChecker{syncers: map[string]*sync.Syncer{}, quiet: quiet, push: push}
+	for _, src := range accounts {
+		if src.SourceType != store.SourceTypeGoogle {
+			continue
+		}
+		client, err := newCalendarClient(ctx, managers[src.SourceType], src.Identifier)
+		if err != nil {
+			return nil, err
+		}
+		c.syncers[src.Identifier] = sync.New(client, s).WithLogger(logger.With("account", src.Identifier))
+	}
+	if len(c.syncers) == 0 {
+		return nil, nil
+	}
+	return c, nil
+}
+
+// check triggers a sync of every account with new calendars. Held syncs
+// leave the list token alone, so the next check still sees the additions.
+func (c *calendarChecker) check(ctx context.Context) error {
+	if reason := syncHold(c.quiet, time.Now()); reason != "" {
+		logger.Info("skipping calendar list check", "reason", reason)
+		return nil
+	}
+	opts := sync.Options{IncludeCalendar: cfg.Sync.IncludeCalendar}
+	var failed, found int
+	for email, syncer := range c.syncers {
+		added, err := syncer.NewCalendars(ctx, email, opts)
+		if err != nil {
+			logger.Error("failed to check calendar list", "account", email, "error", err)
+			failed++
+			continue
+		}
+		for _, cal := range added {
+			logger.Info("found new calendar", "account", email, "calendar", cal.Summary)
+		}
+		if len(added) > 0 {
+			c.runner.Trigger(email)
+			found++
+		}
+	}
+	// Jobs run one at a time, so the triggered syncs store the new
+	// calendars before their channels are created.
+	if found > 0 && c.push {
+		c.runner.Trigger("renew-watch-channels")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d account(s) failed to check calendar list", failed)
+	}
+	return nil
+}
+
+// job checks calendar lists on calendarCheckSchedule.
+func (c *calendarChecker) job() daemon.Job {
+	schedule, _ := daemon.ParseSchedule(calendarCheckSchedule)
+	return daemon.Job{Name: "check-calendar-lists", Schedule: schedule, Run: c.check}
+}
+
+// Push notification channel lifetime and renewal settings.
+const (
+	watchTTL         = 7 * 24 * time.Hour
+	watchRenewBefore = 24 * time.Hour
+)
+
+// pushReceiver manages watch channels for Google accounts and turns
+// notifications into triggered syncs.
+type pushReceiver struct {
+	store    *store.Store
+	syncers  map[string]*sync.Syncer // account email -> syncer
+	accounts map[int64]string        // source ID -> account email
+	opts     sync.WatchOptions
+}
+
+func newPushReceiver(ctx context.Context, s *store.Store, managers oauthManagers, accounts []*store.Source) (*pushReceiver, error) {
+	p := &pushReceiver{
+		store:    s,
+		syncers:  map[string]*sync.Syncer{},
+		accounts: map[int64]string{},
+		opts: sync.WatchOptions{
+			Address:         cfg.Daemon.Push.Address,
+			TTL:             watchTTL,
+			RenewBefore:     watchRenewBefore,
+			IncludeCalendar: cfg.Sync.IncludeCalendar,
+		},
+	}
+	for _, src := range accounts {
+		if src.SourceType != store.SourceTypeGoogle {
+			continue
+		}
+		client, err := newCalendarClient(ctx, managers[src.SourceType], src.Identifier)
+		if err != nil {
+			return nil, err
+		}
+		p.syncers[src.Identifier] = sync.New(client, s).WithLogger(logger.With("account", src.Identifier))
+		p.accounts[src.ID] = src.Identifier
+	}
+	return p, nil
+}
+
+// renew creates or replaces channels that are missing or about to expire.
+func (p *pushReceiver) renew(ctx context.Context) error {
+	var failed int
+	for email, syncer := range p.syncers {
+		created, err := syncer.RenewWatches(ctx, email, p.opts)
+		if err != nil {
+			logger.Error("failed to renew watch channels", "account", email, "error", err)
+			failed++
+			continue
+		}
+		if created > 0 {
+			logger.Info("renewed watch channels", "account", email, "created", created)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d account(s) failed to renew watch channels", failed)
+	}
+	return nil
+}
+
+// renewJob renews channels hourly.
+func (p *pushReceiver) renewJob() daemon.Job {
+	schedule, _ := daemon.ParseSchedule("@hourly")
+	return daemon.Job{Name: "renew-watch-channels", Schedule: schedule, Run: p.renew}
+}
+
+// lookup resolves a channel to the account sync job it triggers.
+func (p *pushReceiver) lookup(channelID string) (string, string, bool) {
+	w, err := p.store.GetWatchChannel(channelID)
+	if err != nil {
+		logger.Error("failed to look up watch channel", "channel", channelID, "error", err)
+		return "", "", false
+	}
+	if w == nil {
+		return "", "", false
+	}
+	email, ok := p.accounts[w.SourceID]
+	return email, w.Token, ok
+}
+
+// start registers channels and serves the webhook until ctx is cancelled.
+func (p *pushReceiver) start(ctx context.Context, runner *daemon.Runner) error {
+	if err := p.renew(ctx); err != nil {
+		logger.Warn("initial watch channel setup incomplete", "error", err)
+	}
+
+	ln, err := net.Listen("tcp", cfg.Daemon.Push.Listen)
+	if err != nil {
+		return fmt.Errorf("listen for push notifications: %w", err)
+	}
+	server := &http.Server{
+		Handler:           daemon.NotificationHandler(p.lookup, runner.Trigger, logger),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("push notification server stopped", "error", err)
+		}
+	}()
+	fmt.Printf("Listening for push notifications on %s (%s)\n", ln.Addr(), p.opts.Address)
+	return nil
+}
+
+// serveMetrics serves Prometheus metrics on addr at /metrics until ctx is
+// cancelled.
+func serveMetrics(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen for metrics scrapes: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Default.Handler())
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("metrics server stopped", "error", err)
+		}
+	}()
+	fmt.Printf("Serving metrics on http://%s/metrics\n", ln.Addr())
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+}
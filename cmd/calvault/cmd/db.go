@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var maintainNoVacuum bool
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Look after the database file",
+}
+
+var dbMaintainCmd = &cobra.Command{
+	Use:   "maintain",
+	Short: "Compact the database and refresh its statistics",
+	Long: `Compact the database file and refresh what SQLite knows about it.
+
+Deleting rows - removing an account, emptying the trash, pruning old
+events - leaves their space in the file for reuse, so the file never
+shrinks on its own. This command:
+
+  - optimizes the full-text search index
+  - runs ANALYZE, so queries pick the best indexes
+  - runs VACUUM, rewriting the file without its unused space
+  - checkpoints the write-ahead log into the database and truncates it
+
+and reports how much disk space was reclaimed.
+
+VACUUM copies the whole database, so it needs about as much free disk
+space as the database takes, and may take a while on a large archive.
+Syncs wait until it is done; --no-vacuum skips it.
+
+Examples:
+  calvault db maintain
+  calvault db maintain --no-vacuum`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dbPath := cfg.DatabasePath()
+		if _, err := os.Stat(dbPath); err != nil {
+			return fmt.Errorf("no database at %s: %w", dbPath, err)
+		}
+
+		// A sync would wait on VACUUM's write lock and could time out
+		lock, err := acquireSyncLock()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = lock.Release() }()
+
+		s, err := store.Open(dbPath)
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		if !maintainNoVacuum {
+			out.Println(out.Muted("Compacting the database; this may take a while..."))
+		}
+		m, err := s.Maintain(dbPath, store.MaintainOptions{Vacuum: !maintainNoVacuum})
+		if err != nil {
+			return fmt.Errorf("maintain: %w", err)
+		}
+		logger.Info("maintained database", "size_before", m.SizeBefore, "size_after", m.SizeAfter, "vacuum", m.Vacuumed)
+
+		t := render.NewTable("Step", "Took").AlignRight(1)
+		for _, step := range m.Steps {
+			t.Row(step.Name, step.Duration.Round(time.Millisecond).String())
+		}
+		out.Table(t)
+		out.Println()
+
+		reclaimed := "nothing"
+		if m.Reclaimed() > 0 {
+			reclaimed = formatSize(m.Reclaimed())
+		}
+		out.KeyValues(
+			"Size before", formatSize(m.SizeBefore),
+			"Size after", formatSize(m.SizeAfter),
+			"Reclaimed", out.Good(reclaimed),
+		)
+		if !m.Vacuumed && m.FreePages > 0 {
+			out.Println()
+			out.Println(out.Muted(fmt.Sprintf("%s of unused space is left in the file; run without --no-vacuum to reclaim it.",
+				formatSize(m.FreePages*m.PageSize))))
+		}
+		return nil
+	},
+}
+
+func init() {
+	dbMaintainCmd.Flags().BoolVar(&maintainNoVacuum, "no-vacuum", false, "Skip VACUUM (only analyze and checkpoint)")
+	dbCmd.AddCommand(dbMaintainCmd)
+	rootCmd.AddCommand(dbCmd)
+}
@@ -0,0 +1,397 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/config"
+	"github.com/salman1993/calvault/internal/oauth"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	doctorOffline bool
+	doctorFull    bool
+)
+
+// doctorConfigErr is why the config failed to load, for doctor to report;
+// it runs on the defaults instead.
+var doctorConfigErr error
+
+// staleSyncTokenAge is how long after a calendar's last sync its sync token
+// is reported as likely expired. Google doesn't document a lifetime, but
+// tokens unused for weeks are regularly refused with 410 Gone.
+const staleSyncTokenAge = 28 * 24 * time.Hour
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the config, database, tokens and connectivity",
+	Long: `Check that calvault is set up to work, and suggest a fix for each problem
+found:
+
+  - the config file parses and validates, with no unknown (misspelled) keys
+  - the home directory is writable and the OAuth client is configured
+  - the database opens, passes SQLite's quick_check (integrity_check with
+    --full), has a schema this calvault supports and no orphaned rows
+  - each account's token is saved, unexpired and refreshable, with the
+    scopes syncing needs
+  - no calendar has an interrupted full sync or a sync token old enough
+    to have expired
+  - Google's (and, with Microsoft accounts, Microsoft's) API can be reached
+
+Checking a token refreshes it the way a sync would; --offline skips that
+and the connectivity check. Exits non-zero if any check fails; warnings
+don't.
+
+Examples:
+  calvault doctor
+  calvault doctor --offline
+  calvault doctor --full`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		d := &doctor{}
+		d.checkConfig()
+		d.checkHome()
+		if s := d.checkDatabase(); s != nil {
+			defer func() { _ = s.Close() }()
+			d.checkAccounts(cmd.Context(), s)
+		}
+		if !doctorOffline {
+			d.checkConnectivity(cmd.Context())
+		}
+		if err := d.report(); err != nil {
+			// The report already describes the problems
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return err
+		}
+		return nil
+	},
+}
+
+// Doctor check outcomes.
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// doctorCheck is the outcome of one check, with a fix if it didn't pass.
+type doctorCheck struct {
+	name   string
+	status string
+	detail string
+	fix    string
+}
+
+// doctor collects check outcomes.
+type doctor struct {
+	checks []doctorCheck
+	msft   bool // Accounts need the Microsoft Graph API
+}
+
+func (d *doctor) ok(name, detail string) {
+	d.checks = append(d.checks, doctorCheck{name: name, status: checkOK, detail: detail})
+}
+
+func (d *doctor) warn(name, detail, fix string) {
+	d.checks = append(d.checks, doctorCheck{name: name, status: checkWarn, detail: detail, fix: fix})
+}
+
+func (d *doctor) fail(name, detail, fix string) {
+	d.checks = append(d.checks, doctorCheck{name: name, status: checkFail, detail: detail, fix: fix})
+}
+
+// checkConfig reports whether the config file loaded and any keys in it
+// that no setting reads.
+func (d *doctor) checkConfig() {
+	if doctorConfigErr != nil {
+		d.fail("Config", doctorConfigErr.Error(), fmt.Sprintf("correct %s; until then every other command refuses to run", cfg.File))
+		return
+	}
+	if _, err := os.Stat(cfg.File); errors.Is(err, os.ErrNotExist) {
+		d.ok("Config", fmt.Sprintf("no %s; using the defaults", cfg.File))
+		return
+	}
+	unknown, err := config.UnknownKeys(cfg.File)
+	if err != nil {
+		d.fail("Config", err.Error(), "correct "+cfg.File)
+		return
+	}
+	if len(unknown) > 0 {
+		d.warn("Config", "unknown keys, ignored: "+strings.Join(unknown, ", "),
+			fmt.Sprintf("correct or remove them in %s (see the README for the settings)", cfg.File))
+		return
+	}
+	d.ok("Config", cfg.File)
+}
+
+// checkHome reports whether the home directory can be written and, if
+// Google accounts are to be added, whether the OAuth client is set up.
+func (d *doctor) checkHome() {
+	if err := os.MkdirAll(cfg.HomeDir, 0755); err != nil {
+		d.fail("Home", err.Error(), "create it, or point CALVAULT_HOME at a writable directory")
+	} else if f, err := os.CreateTemp(cfg.HomeDir, ".calvault-doctor-*"); err != nil {
+		d.fail("Home", fmt.Sprintf("%s is not writable: %v", cfg.HomeDir, err), "fix its permissions, or point CALVAULT_HOME at a writable directory")
+	} else {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		d.ok("Home", cfg.HomeDir)
+	}
+
+	switch secrets := cfg.OAuth.ClientSecrets; {
+	case secrets == "":
+		d.warn("OAuth client", "[oauth] client_secrets is not set", "download an OAuth client (Desktop app) from the Google Cloud console and set its path in config.toml; needed for Google accounts")
+	default:
+		if _, err := os.Stat(secrets); err != nil {
+			d.fail("OAuth client", err.Error(), "set [oauth] client_secrets in config.toml to the client JSON you downloaded")
+		} else {
+			d.ok("OAuth client", secrets)
+		}
+	}
+}
+
+// checkDatabase opens the database and checks its integrity, schema
+// version and foreign keys. It returns the open store, or nil if the
+// database can't be used.
+func (d *doctor) checkDatabase() *store.Store {
+	dbPath := cfg.DatabasePath()
+	info, err := os.Stat(dbPath)
+	if errors.Is(err, os.ErrNotExist) {
+		d.warn("Database", "no database at "+dbPath, "run 'calvault add-account <email>' or 'calvault init-db' to create one")
+		return nil
+	}
+	if err != nil {
+		d.fail("Database", err.Error(), "check the path and permissions of [storage] database")
+		return nil
+	}
+	s, err := store.Open(dbPath)
+	if err != nil {
+		d.fail("Database", err.Error(), "check the path and permissions of [storage] database")
+		return nil
+	}
+
+	problems, err := s.CheckIntegrity(doctorFull)
+	if err != nil || len(problems) > 0 {
+		detail := "corrupt: " + strings.Join(problems, "; ")
+		if err != nil {
+			detail = err.Error()
+		}
+		d.fail("Database", oneLine(detail, 200), "restore the latest backup with 'calvault restore <backup-file>'")
+		_ = s.Close()
+		return nil
+	}
+	version, err := s.StoredSchemaVersion()
+	if err != nil {
+		d.fail("Database", err.Error(), "restore the latest backup with 'calvault restore <backup-file>'")
+		_ = s.Close()
+		return nil
+	}
+	if version > store.SchemaVersion {
+		d.warn("Database", fmt.Sprintf("schema version %d is newer than this calvault's (%d)", version, store.SchemaVersion),
+			"upgrade calvault with 'calvault self-update'; this version may not read everything a newer one stored")
+	}
+	if err := s.InitSchema(); err != nil {
+		d.fail("Database", err.Error(), "restore the latest backup with 'calvault restore <backup-file>'")
+		_ = s.Close()
+		return nil
+	}
+	check := "quick_check"
+	if doctorFull {
+		check = "integrity_check"
+	}
+	if version <= store.SchemaVersion {
+		d.ok("Database", fmt.Sprintf("%s (%s, %s passed)", dbPath, formatSize(info.Size()), check))
+	}
+
+	violations, err := s.ForeignKeyViolations()
+	switch {
+	case err != nil:
+		d.fail("Foreign keys", err.Error(), "")
+	case len(violations) > 0:
+		tables := make([]string, 0, len(violations))
+		for table, n := range violations {
+			tables = append(tables, fmt.Sprintf("%s %d", table, n))
+		}
+		sort.Strings(tables)
+		d.warn("Foreign keys", "rows whose parent is gone: "+strings.Join(tables, ", "), "run 'calvault init-db', which removes orphaned rows")
+	default:
+		d.ok("Foreign keys", "no orphaned rows")
+	}
+	return s
+}
+
+// checkAccounts checks each account's token and the sync state of its
+// calendars.
+func (d *doctor) checkAccounts(ctx context.Context, s *store.Store) {
+	sources, err := s.ListSources()
+	if err != nil {
+		d.fail("Accounts", err.Error(), "")
+		return
+	}
+	if len(sources) == 0 {
+		d.warn("Accounts", "no accounts", "run 'calvault add-account <email>' to add one")
+		return
+	}
+
+	managers := oauthManagers{}
+	for _, src := range sources {
+		if tokensDirFor(src.SourceType) == "" {
+			continue // Imported calendars have no token and aren't synced
+		}
+		if src.SourceType == store.SourceTypeMicrosoft {
+			d.msft = true
+		}
+
+		name := "Token " + src.Identifier
+		st := checkAuth(ctx, managers, src, !doctorOffline)
+		switch {
+		case st.hint() != "":
+			d.fail(name, st.info.State+refreshDetail(st), st.hint())
+		case st.info.State == oauth.TokenValid && !st.info.HasRefresh:
+			d.warn(name, fmt.Sprintf("no refresh token; the access token expires %s", out.DateTime(st.info.Expiry.Local())),
+				"run 'calvault auth refresh "+src.Identifier+"' to get a refreshable token")
+		default:
+			d.ok(name, st.info.State+refreshDetail(st))
+		}
+
+		d.checkSyncState(s, src)
+	}
+}
+
+// refreshDetail describes the outcome of a token check's refresh.
+func refreshDetail(st *authStatus) string {
+	switch st.refreshState() {
+	case "ok":
+		return ", refreshed"
+	case "failed":
+		return ", refresh failed: " + oneLine(st.refreshError(), 60)
+	}
+	return ""
+}
+
+// checkSyncState reports an account's calendars with an interrupted full
+// sync, a sync token old enough to have expired, or no sync yet.
+func (d *doctor) checkSyncState(s *store.Store, src *store.Source) {
+	name := "Sync " + src.Identifier
+	calendars, err := s.GetCalendars(src.ID)
+	if err != nil {
+		d.fail(name, err.Error(), "")
+		return
+	}
+	var interrupted, stale, never []string
+	for _, cal := range calendars {
+		label := cal.Summary
+		if label == "" {
+			label = cal.GoogleCalendarID
+		}
+		switch {
+		case cal.PageToken.Valid && cal.PageToken.String != "":
+			interrupted = append(interrupted, label)
+		case !cal.SyncToken.Valid || cal.SyncToken.String == "":
+			never = append(never, label)
+		case cal.LastSyncedAt.Valid && time.Since(cal.LastSyncedAt.Time) > staleSyncTokenAge:
+			stale = append(stale, fmt.Sprintf("%s (%s)", label, out.Date(cal.LastSyncedAt.Time.Local())))
+		}
+	}
+
+	syncFix := "run 'calvault sync " + src.Identifier + "'"
+	switch {
+	case len(calendars) == 0:
+		d.warn(name, "no calendars synced yet", syncFix)
+	case len(interrupted) > 0:
+		d.warn(name, "full sync interrupted: "+oneLine(strings.Join(interrupted, ", "), 120),
+			syncFix+" (or 'calvault jobs resume <id>') to continue it from where it stopped")
+	case len(stale) > 0:
+		d.warn(name, "sync tokens likely expired, last synced: "+oneLine(strings.Join(stale, ", "), 120),
+			syncFix+"; an expired token makes the next incremental sync a full one, so run the daemon or schedule syncs to keep them fresh")
+	case len(never) > 0:
+		d.warn(name, "never fully synced: "+oneLine(strings.Join(never, ", "), 120), syncFix)
+	default:
+		d.ok(name, fmt.Sprintf("%d calendars up to date", len(calendars)))
+	}
+}
+
+// doctorEndpoints are fetched to check the APIs can be reached; any HTTP
+// response, such as 401 without a token, shows they can.
+var doctorEndpoints = []struct {
+	name, url string
+	msft      bool
+}{
+	{"Google API", "https://www.googleapis.com/calendar/v3/users/me/calendarList", false},
+	{"Microsoft Graph", "https://graph.microsoft.com/v1.0/me", true},
+}
+
+// checkConnectivity checks the calendar APIs the accounts use can be
+// reached. Google's is always checked, since adding an account needs it.
+func (d *doctor) checkConnectivity(ctx context.Context) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	for _, ep := range doctorEndpoints {
+		if ep.msft && !d.msft {
+			continue
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ep.url, nil)
+		if err != nil {
+			d.fail(ep.name, err.Error(), "")
+			continue
+		}
+		start := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			d.fail(ep.name, oneLine(err.Error(), 120), "check the network connection, DNS, and any proxy (HTTPS_PROXY) or firewall")
+			continue
+		}
+		_ = resp.Body.Close()
+		d.ok(ep.name, fmt.Sprintf("reachable (%s)", time.Since(start).Round(time.Millisecond)))
+	}
+}
+
+// report prints the checks and returns an error if any failed.
+func (d *doctor) report() error {
+	width := 0
+	for _, c := range d.checks {
+		width = max(width, len(c.name))
+	}
+	var failed, warned int
+	for _, c := range d.checks {
+		var mark string
+		switch c.status {
+		case checkOK:
+			mark = out.Good("ok  ")
+		case checkWarn:
+			mark = out.Warn("warn")
+			warned++
+		default:
+			mark = out.Bad("FAIL")
+			failed++
+		}
+		out.Printf("%s  %-*s  %s\n", mark, width, c.name, c.detail)
+		if c.fix != "" {
+			out.Printf("%s  %s\n", strings.Repeat(" ", width+6), out.Muted("Fix: "+c.fix))
+		}
+	}
+	out.Println()
+	switch {
+	case failed > 0:
+		out.Printf("%s, %d warning(s)\n", out.Bad(fmt.Sprintf("%d problem(s) found", failed)), warned)
+		return fmt.Errorf("%d check(s) failed", failed)
+	case warned > 0:
+		out.Printf("%s, %d warning(s)\n", out.Good("No problems found"), warned)
+	default:
+		out.Println(out.Good("No problems found"))
+	}
+	return nil
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorOffline, "offline", false, "Skip token refreshes and the connectivity check")
+	doctorCmd.Flags().BoolVar(&doctorFull, "full", false, "Run SQLite's full integrity_check instead of quick_check")
+	rootCmd.AddCommand(doctorCmd)
+}
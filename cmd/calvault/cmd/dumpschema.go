@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var dumpSchemaFormat string
+
+var dumpSchemaCmd = &cobra.Command{
+	Use:   "dump-schema",
+	Short: "Print the database schema as SQL or a Mermaid ER diagram",
+	Long: `Print the live database schema, for building tools on top of the archive.
+
+--format sql (default) prints the CREATE statements for every table, view,
+index and trigger, including user-created indexes. --format mermaid prints
+an entity-relationship diagram of the tables and their foreign keys, which
+renders on GitHub and in most Markdown editors inside a mermaid code block.
+
+Examples:
+  calvault dump-schema > schema.sql
+  calvault dump-schema --format mermaid > schema.mmd`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if dumpSchemaFormat != "sql" && dumpSchemaFormat != "mermaid" {
+			return fmt.Errorf("unsupported format %q (expected sql or mermaid)", dumpSchemaFormat)
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if dumpSchemaFormat == "mermaid" {
+			tables, err := s.Tables()
+			if err != nil {
+				return fmt.Errorf("describe tables: %w", err)
+			}
+			return writeSchemaMermaid(os.Stdout, tables)
+		}
+
+		objects, err := s.SchemaObjects()
+		if err != nil {
+			return fmt.Errorf("read schema: %w", err)
+		}
+		return writeSchemaSQL(os.Stdout, objects)
+	},
+}
+
+// writeSchemaSQL writes each schema object's CREATE statement.
+func writeSchemaSQL(w io.Writer, objects []*store.SchemaObject) error {
+	for _, o := range objects {
+		if _, err := fmt.Fprintf(w, "%s;\n\n", strings.TrimSpace(o.SQL)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSchemaMermaid writes an erDiagram with one entity per table and one
+// relationship per foreign key. A NOT NULL foreign key means exactly one
+// parent (||), a nullable one zero or one (|o).
+func writeSchemaMermaid(w io.Writer, tables []*store.TableInfo) error {
+	var b strings.Builder
+	b.WriteString("erDiagram\n")
+	for _, t := range tables {
+		if t.View {
+			continue
+		}
+		fkColumns := make(map[string]bool)
+		for _, fk := range t.ForeignKeys {
+			fkColumns[fk.From] = true
+		}
+
+		fmt.Fprintf(&b, "    %s {\n", t.Name)
+		for _, c := range t.Columns {
+			var keys []string
+			if c.PrimaryKey {
+				keys = append(keys, "PK")
+			}
+			if fkColumns[c.Name] {
+				keys = append(keys, "FK")
+			}
+			fmt.Fprintf(&b, "        %s %s", mermaidType(c.Type), c.Name)
+			if len(keys) > 0 {
+				fmt.Fprintf(&b, " %s", strings.Join(keys, ", "))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("    }\n")
+	}
+
+	for _, t := range tables {
+		notNull := make(map[string]bool)
+		for _, c := range t.Columns {
+			notNull[c.Name] = c.NotNull
+		}
+		for _, fk := range t.ForeignKeys {
+			parent := "|o"
+			if notNull[fk.From] {
+				parent = "||"
+			}
+			fmt.Fprintf(&b, "    %s %s--o{ %s : %q\n", fk.Table, parent, t.Name, fk.From)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidType turns a declared column type into a Mermaid attribute type,
+// which must be a single word.
+func mermaidType(typ string) string {
+	if typ == "" {
+		return "ANY"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '(' || r == ')' || r == ',' {
+			return '_'
+		}
+		return r
+	}, typ)
+}
+
+func init() {
+	dumpSchemaCmd.Flags().StringVar(&dumpSchemaFormat, "format", "sql", "Output format: sql or mermaid")
+	rootCmd.AddCommand(dumpSchemaCmd)
+}
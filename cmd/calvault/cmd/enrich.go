@@ -0,0 +1,315 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/ask"
+	"github.com/salman1993/calvault/internal/config"
+	"github.com/salman1993/calvault/internal/enrich"
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	enrichStages    []string
+	enrichExtractor string
+	enrichRebuild   bool
+	enrichLimit     int
+)
+
+var enrichCmd = &cobra.Command{
+	Use:   "enrich",
+	Short: "Derive platforms, tags, entities and places from events",
+	Long: `Derive data from events in stages, each storing its results in its own
+tables:
+
+  platform  The meeting platform (meet, zoom, teams, webex, ...,
+            other_video or in_person), from the event's conference,
+            location and description links, into event_platforms
+  tags      Tags from [[enrich.tag]] rules matching the title, description
+            or location, into event_tags
+  entities  The entities events are about - doctors, companies, project
+            codes - into entities (see below)
+  geocode   The coordinates of event locations, from a Nominatim-compatible
+            geocoder, into event_locations
+
+Stages run in the order of [enrich] stages, by default every stage that is
+set up in the order above; --stage runs only the ones named. Each stage
+only processes events that are new or re-synced since its last run; use
+--rebuild to start over, and --limit to spread a large archive over several
+runs. With after_sync, the stages also run after every sync, import and
+daemon sync.
+
+  [enrich]
+  stages = ["platform", "tags", "entities"]
+  after_sync = true
+
+  [[enrich.tag]]
+  tag = "health"
+  pattern = '(?i)dentist|doctor|\bdr\b'
+
+  [enrich.geocode]               # Sends event locations to the service
+  endpoint = "https://nominatim.openstreetmap.org/search"
+
+Entities come from one of two extractors. 'rules' (the default) matches
+regular expressions from config.toml; the first capture group, or the whole
+match, is the entity's name:
+
+  [entities]
+  min_confidence = 0.5        # Drop less certain entities
+
+  [[entities.rule]]
+  kind = "doctor"
+  pattern = 'Dr\.? ([A-Z][a-z]+)'
+  confidence = 0.9
+
+'llm' asks the model configured in [llm] (see 'calvault ask'), sending each
+event's title and description, and stores the confidence it reports.
+
+Examples:
+  calvault enrich
+  calvault enrich --stage platform --stage tags
+  calvault enrich --extractor llm --limit 500
+  calvault query "SELECT platform, COUNT(*) FROM event_platforms GROUP BY platform"
+  calvault query "SELECT name, COUNT(*) FROM entities WHERE kind = 'doctor' GROUP BY name"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if enrichLimit < 0 {
+			return fmt.Errorf("--limit must not be negative")
+		}
+		names, explicit := cfg.Enrich.Stages, len(cfg.Enrich.Stages) > 0
+		switch {
+		case len(enrichStages) > 0:
+			names, explicit = enrichStages, true
+		case enrichExtractor != "":
+			// --extractor alone runs entity extraction, as before stages
+			names, explicit = []string{"entities"}, true
+		}
+		stages, err := newEnrichStages(names, explicit, enrichExtractor)
+		if err != nil {
+			return err
+		}
+		if len(stages) == 0 {
+			return fmt.Errorf("no enrichment stage is set up - see 'calvault enrich --help'")
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		if enrichRebuild {
+			for _, stage := range stages {
+				if err := enrich.Rebuild(s, stage); err != nil {
+					return err
+				}
+			}
+		}
+		return runEnrich(cmd.Context(), s, stages, enrichJobParams(names, explicit, enrichExtractor, enrichLimit), enrichLimit)
+	},
+}
+
+// enrichJobParams are the parameters an enrich job is resumed with.
+func enrichJobParams(names []string, explicit bool, extractor string, limit int) map[string]string {
+	return map[string]string{
+		"stages":    strings.Join(names, ","),
+		"explicit":  strconv.FormatBool(explicit),
+		"extractor": extractor,
+		"limit":     strconv.Itoa(limit),
+	}
+}
+
+// runEnrich runs the stages as a job and prints their summaries.
+func runEnrich(ctx context.Context, s *store.Store, stages []enrich.Enricher, params map[string]string, limit int) error {
+	var names []string
+	var remaining int64
+	for _, stage := range stages {
+		names = append(names, stage.Name())
+		n, err := s.CountEventsToEnrich(stage.Name())
+		if err != nil {
+			return err
+		}
+		if limit > 0 && n > int64(limit) {
+			n = int64(limit)
+		}
+		remaining += n
+	}
+	job := startJob(s, "enrich", "enrich "+strings.Join(names, ", "), params)
+	job.setRemaining(remaining)
+	summaries, err := enrich.RunAll(ctx, s, stages, limit, func(*enrich.Summary) { job.add(1) })
+	job.finish(err)
+	if len(summaries) > 0 {
+		t := render.NewTable("Stage", "Events", "Found", "Failed").AlignRight(1, 2, 3)
+		for _, sum := range summaries {
+			failed := out.Muted("0")
+			if sum.Failed > 0 {
+				failed = out.Bad(strconv.Itoa(sum.Failed))
+			}
+			t.Row(sum.Stage, out.Number(int64(sum.Events)), out.Number(int64(sum.Found)), failed)
+		}
+		out.Table(t)
+		for _, sum := range summaries {
+			if sum.Failed > 0 {
+				out.Println(out.Warn(fmt.Sprintf("%s: %d events failed and will be retried next run; last error: %v", sum.Stage, sum.Failed, sum.LastErr)))
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("enrich: %w", err)
+	}
+
+	for _, stage := range stages {
+		if _, ok := stage.(*enrich.EntityStage); !ok {
+			continue
+		}
+		top, err := s.TopEntities(cfg.Entities.MinConfidence, 10)
+		if err != nil {
+			return err
+		}
+		if len(top) > 0 {
+			out.Println()
+			out.Println(out.Heading("Most Mentioned"))
+			t := render.NewTable("Kind", "Name", "Events").AlignRight(2)
+			for _, c := range top {
+				t.Row(c.Kind, oneLine(c.Name, 40), out.Number(int64(c.Events)))
+			}
+			out.Table(t)
+		}
+		break
+	}
+	return nil
+}
+
+// newEnrichStages builds the named stages, or every stage that is set up
+// if names is empty. Unless explicit, stages that aren't set up are
+// skipped rather than an error. extractor overrides [entities] extractor.
+func newEnrichStages(names []string, explicit bool, extractor string) ([]enrich.Enricher, error) {
+	if len(names) == 0 {
+		names = config.EnrichStages
+	}
+	var stages []enrich.Enricher
+	for _, name := range names {
+		if !slices.Contains(config.EnrichStages, name) {
+			return nil, fmt.Errorf("unknown stage %q (expected %s)", name, strings.Join(config.EnrichStages, ", "))
+		}
+		stage, err := newEnrichStage(name, extractor)
+		if err != nil {
+			if explicit {
+				return nil, err
+			}
+			continue
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
+
+// newEnrichStage builds the named stage from config.
+func newEnrichStage(name, extractor string) (enrich.Enricher, error) {
+	switch name {
+	case "platform":
+		return enrich.PlatformStage{}, nil
+	case "tags":
+		if len(cfg.Enrich.Tags) == 0 {
+			return nil, fmt.Errorf("no [[enrich.tag]] in %s - add tag rules to run the tags stage", cfg.File)
+		}
+		stage := &enrich.TagStage{}
+		for _, t := range cfg.Enrich.Tags {
+			stage.Rules = append(stage.Rules, enrich.TagRule{Tag: t.Tag, Pattern: regexp.MustCompile(t.Pattern)})
+		}
+		return stage, nil
+	case "entities":
+		if extractor == "" {
+			extractor = cfg.Entities.Extractor
+		}
+		ex, err := newExtractor(extractor)
+		if err != nil {
+			return nil, err
+		}
+		return &enrich.EntityStage{Extractor: ex, MinConfidence: cfg.Entities.MinConfidence}, nil
+	case "geocode":
+		if cfg.Enrich.Geocode.Endpoint == "" {
+			return nil, fmt.Errorf("no [enrich.geocode] endpoint in %s - set one to run the geocode stage", cfg.File)
+		}
+		return &enrich.GeocodeStage{Geocoder: &enrich.NominatimGeocoder{
+			Endpoint:  cfg.Enrich.Geocode.Endpoint,
+			UserAgent: "calvault/" + Version,
+			Interval:  time.Second, // Nominatim's usage policy
+		}}, nil
+	}
+	return nil, fmt.Errorf("unknown stage %q", name)
+}
+
+// enrichAfterSync runs the enrichment stages after a sync or import if
+// [enrich] after_sync is set. Failures are only logged, as the synced data
+// itself is stored.
+func enrichAfterSync(ctx context.Context, s *store.Store) {
+	if !cfg.Enrich.AfterSync {
+		return
+	}
+	stages, err := newEnrichStages(cfg.Enrich.Stages, false, "")
+	if err != nil {
+		logger.Warn("failed to set up enrichment", "error", err)
+		return
+	}
+	summaries, err := enrich.RunAll(ctx, s, stages, 0, nil)
+	for _, sum := range summaries {
+		logger.Info("enriched events", "stage", sum.Stage, "events", sum.Events, "found", sum.Found, "failed", sum.Failed)
+		if sum.Failed > 0 {
+			logger.Warn("enrichment failed for some events", "stage", sum.Stage, "failed", sum.Failed, "error", sum.LastErr)
+		}
+	}
+	if err != nil {
+		logger.Warn("failed to enrich events", "error", err)
+	}
+}
+
+// newExtractor builds the named extractor from config.
+func newExtractor(name string) (enrich.Extractor, error) {
+	switch name {
+	case "", "rules":
+		if len(cfg.Entities.Rules) == 0 {
+			return nil, fmt.Errorf("no [[entities.rule]] in %s - add rules or use --extractor llm", cfg.File)
+		}
+		ex := &enrich.RuleExtractor{}
+		for _, r := range cfg.Entities.Rules {
+			confidence := r.Confidence
+			if confidence == 0 {
+				confidence = 1
+			}
+			ex.Rules = append(ex.Rules, enrich.Rule{Kind: r.Kind, Pattern: regexp.MustCompile(r.Pattern), Confidence: confidence})
+		}
+		return ex, nil
+	case "llm":
+		if !cfg.LLM.Enabled() {
+			return nil, fmt.Errorf("no language model configured - set [llm] endpoint and model in %s", cfg.File)
+		}
+		return &enrich.LLMExtractor{Client: &ask.Client{
+			Endpoint: cfg.LLM.Endpoint,
+			Model:    cfg.LLM.Model,
+			APIKey:   cfg.LLM.APIKey(),
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unknown extractor %q (expected rules or llm)", name)
+	}
+}
+
+func init() {
+	enrichCmd.Flags().StringSliceVar(&enrichStages, "stage", nil, "Stage to run: platform, tags, entities or geocode (repeatable; default [enrich] stages)")
+	enrichCmd.Flags().StringVar(&enrichExtractor, "extractor", "", "Entity extractor: rules or llm (default [entities] extractor, else rules); alone, runs only the entities stage")
+	enrichCmd.Flags().BoolVar(&enrichRebuild, "rebuild", false, "Discard what the stages stored and process every event again")
+	enrichCmd.Flags().IntVar(&enrichLimit, "limit", 0, "Process at most this many events (0 for all)")
+	rootCmd.AddCommand(enrichCmd)
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	eventsFrom     string
+	eventsTo       string
+	eventsCalendar string
+	eventsAttendee string
+	eventsLocation string
+	eventsStatus   string
+	eventsLimit    int
+	eventsJSON     bool
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "List events matching filters",
+	Long: `List archived events by date range, calendar, attendee, location and
+status, without writing SQL. Events are listed by start time.
+
+--to is inclusive. --calendar accepts a calendar ID from the database, a
+Google calendar ID, or a calendar name. --attendee and --location match
+substrings, ignoring case; --attendee checks emails and names. --status is
+confirmed, tentative or cancelled.
+
+Examples:
+  calvault events --from 2024-03-01 --to 2024-03-31
+  calvault events --attendee dana@example.com --status confirmed
+  calvault events --calendar Work --location "Room 4" --json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, err := parseDateFlag(eventsFrom)
+		if err != nil {
+			return err
+		}
+		to, err := parseDateFlag(eventsTo)
+		if err != nil {
+			return err
+		}
+		if !to.IsZero() {
+			to = to.AddDate(0, 0, 1)
+		}
+		switch eventsStatus {
+		case "", "confirmed", "tentative", "cancelled":
+		default:
+			return fmt.Errorf("invalid --status %q (expected confirmed, tentative or cancelled)", eventsStatus)
+		}
+		if eventsLimit < 0 {
+			return fmt.Errorf("--limit must not be negative")
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		filter := store.EventFilter{
+			Since:    from,
+			Until:    to,
+			Attendee: eventsAttendee,
+			Location: eventsLocation,
+			Status:   eventsStatus,
+			Limit:    eventsLimit,
+		}
+		if eventsCalendar != "" {
+			cals, err := s.FindCalendars(eventsCalendar)
+			if err != nil {
+				return fmt.Errorf("find calendar: %w", err)
+			}
+			switch len(cals) {
+			case 0:
+				return fmt.Errorf("calendar %q not found", eventsCalendar)
+			case 1:
+			default:
+				return fmt.Errorf("calendar %q is ambiguous (%d matches) - use the numeric calendar ID", eventsCalendar, len(cals))
+			}
+			filter.CalendarID = cals[0].ID
+		}
+
+		events, err := s.ListEvents(filter)
+		if err != nil {
+			return err
+		}
+
+		accounts, calendars, err := sourceNames(s)
+		if err != nil {
+			return err
+		}
+
+		if eventsJSON {
+			entries := make([]eventJSONEntry, 0, len(events))
+			for _, e := range events {
+				attendees, err := s.ListAttendees(e.ID)
+				if err != nil {
+					return err
+				}
+				entry := eventJSONEntry{
+					ID:        e.GoogleEventID,
+					Account:   accounts[e.SourceID],
+					Calendar:  calendars[e.CalendarID],
+					Summary:   e.Summary,
+					AllDay:    e.AllDay,
+					Location:  e.Location,
+					Status:    e.Status,
+					Organizer: e.OrganizerEmail,
+					Attendees: make([]string, 0, len(attendees)),
+				}
+				if e.StartTime.Valid {
+					entry.Start = e.StartTime.Time.Format(time.RFC3339)
+				}
+				if e.EndTime.Valid {
+					entry.End = e.EndTime.Time.Format(time.RFC3339)
+				}
+				for _, a := range attendees {
+					entry.Attendees = append(entry.Attendees, a.Email)
+				}
+				conf, err := s.GetConference(e.ID)
+				if err != nil {
+					return err
+				}
+				if conf != nil {
+					entry.Video = conf.VideoURI
+					if entry.Video == "" {
+						entry.Video = conf.HangoutLink
+					}
+				}
+				entries = append(entries, entry)
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(entries)
+		}
+
+		if len(events) == 0 {
+			out.Println("No matching events.")
+			return nil
+		}
+
+		t := render.NewTable("Start", "Event", "Calendar", "Location", "Status")
+		for _, e := range events {
+			start := ""
+			switch {
+			case !e.StartTime.Valid:
+			case e.AllDay:
+				start = out.Date(e.StartTime.Time.UTC())
+			default:
+				start = out.DateTime(e.StartTime.Time.Local())
+			}
+			title := out.Accent(oneLine(e.Summary, 50))
+			if e.Summary == "" {
+				title = out.Muted("(no title)")
+			}
+			t.Row(start, title, calendars[e.CalendarID], oneLine(e.Location, 30), styleEventStatus(e.Status))
+		}
+		out.Table(t)
+		if eventsLimit > 0 && len(events) == eventsLimit {
+			out.Println(out.Muted(fmt.Sprintf("\nShowing the first %d events; use --limit 0 for all.", eventsLimit)))
+		} else {
+			out.Printf("\n%d event(s)\n", len(events))
+		}
+		return nil
+	},
+}
+
+// eventJSONEntry is the JSON shape of a listed event.
+type eventJSONEntry struct {
+	ID        string   `json:"id"`
+	Account   string   `json:"account"`
+	Calendar  string   `json:"calendar"`
+	Summary   string   `json:"summary"`
+	Start     string   `json:"start,omitempty"`
+	End       string   `json:"end,omitempty"`
+	AllDay    bool     `json:"all_day"`
+	Location  string   `json:"location,omitempty"`
+	Status    string   `json:"status,omitempty"`
+	Organizer string   `json:"organizer,omitempty"`
+	Attendees []string `json:"attendees"`
+	Video     string   `json:"video,omitempty"` // Link to join the event's conference
+}
+
+// sourceNames maps account IDs to identifiers and calendar IDs to names
+// (or Google calendar IDs for calendars without one).
+func sourceNames(s *store.Store) (accounts, calendars map[int64]string, err error) {
+	sources, err := s.ListSources()
+	if err != nil {
+		return nil, nil, err
+	}
+	accounts = make(map[int64]string, len(sources))
+	calendars = map[int64]string{}
+	for _, src := range sources {
+		accounts[src.ID] = src.Identifier
+		cals, err := s.GetCalendars(src.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, c := range cals {
+			calendars[c.ID] = c.Summary
+			if c.Summary == "" {
+				calendars[c.ID] = c.GoogleCalendarID
+			}
+		}
+	}
+	return accounts, calendars, nil
+}
+
+func styleEventStatus(status string) string {
+	switch status {
+	case "", "confirmed":
+		return out.Muted("confirmed")
+	case "cancelled":
+		return out.Bad(status)
+	default:
+		return out.Warn(status)
+	}
+}
+
+func init() {
+	eventsCmd.Flags().StringVar(&eventsFrom, "from", "", "Only events starting on or after this date (YYYY-MM-DD)")
+	eventsCmd.Flags().StringVar(&eventsTo, "to", "", "Only events starting on or before this date (YYYY-MM-DD)")
+	eventsCmd.Flags().StringVar(&eventsCalendar, "calendar", "", "Only events in this calendar (ID or name)")
+	eventsCmd.Flags().StringVar(&eventsAttendee, "attendee", "", "Only events with an attendee whose email or name contains this")
+	eventsCmd.Flags().StringVar(&eventsLocation, "location", "", "Only events whose location contains this")
+	eventsCmd.Flags().StringVar(&eventsStatus, "status", "", "Only events with this status (confirmed, tentative, cancelled)")
+	eventsCmd.Flags().IntVarP(&eventsLimit, "limit", "n", 100, "Maximum number of events (0 = no limit)")
+	eventsCmd.Flags().BoolVar(&eventsJSON, "json", false, "Output as JSON")
+	rootCmd.AddCommand(eventsCmd)
+}
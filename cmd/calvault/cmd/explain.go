@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	explainFile        string
+	explainCreate      bool
+	explainListIndexes bool
+	explainDropIndex   string
+)
+
+var explainSlowCmd = &cobra.Command{
+	Use:   "explain-slow [sql]",
+	Short: "Profile a query and suggest indexes",
+	Long: `Profile a read-only SQL query: print its EXPLAIN QUERY PLAN, run it once
+to time it, and suggest indexes for full table scans and temporary sorts.
+
+Use --create to create the suggested indexes. They are named with a
+user_idx_ prefix and tracked in the user_indexes table, so they can be
+listed and dropped without touching the built-in schema.
+
+SQL is read the same way as 'calvault query' (argument, --file, or stdin).
+
+Examples:
+  calvault explain-slow "SELECT * FROM events WHERE location = 'Gym'"
+  calvault explain-slow -f examples/busiest_days.sql --create
+  calvault explain-slow --list-indexes
+  calvault explain-slow --drop-index user_idx_events_location`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if explainListIndexes || explainDropIndex != "" {
+			return manageUserIndexes()
+		}
+
+		sql, err := readSQLInput(args, explainFile)
+		if err != nil {
+			return err
+		}
+
+		registry, err := buildNamedQueryRegistry()
+		if err != nil {
+			return err
+		}
+		executor, closeExecutor, err := openExecutor(registry)
+		if err != nil {
+			return err
+		}
+		profile, err := executor.Explain(cmd.Context(), sql)
+		closeExecutor()
+		if err != nil {
+			return err
+		}
+
+		out.Title("Query Plan")
+		depth := map[int]int{}
+		for _, step := range profile.Plan {
+			depth[step.ID] = depth[step.Parent] + 1
+			out.Printf("%s%s\n", strings.Repeat("  ", depth[step.ID]), step.Detail)
+		}
+
+		out.Println()
+		out.Printf("Execution: %s, %d rows\n", profile.Duration.Round(time.Microsecond), profile.RowCount)
+
+		out.Println()
+		if len(profile.Suggestions) == 0 {
+			out.Println("No index suggestions.")
+			return nil
+		}
+		out.Title("Suggested Indexes")
+		for _, sug := range profile.Suggestions {
+			out.Printf("  %s(%s)  %s\n", out.Accent(sug.Table), strings.Join(sug.Columns, ", "), out.Muted("-- "+sug.Reason))
+		}
+
+		if !explainCreate {
+			out.Println()
+			out.Println(out.Muted("Run again with --create to create these indexes."))
+			return nil
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		out.Println()
+		for _, sug := range profile.Suggestions {
+			name, err := s.CreateUserIndex(sug.Table, sug.Columns)
+			if err != nil {
+				return fmt.Errorf("create index on %s: %w", sug.Table, err)
+			}
+			out.Printf("%s %s\n", out.Good("Created"), name)
+		}
+		return nil
+	},
+}
+
+// manageUserIndexes handles --list-indexes and --drop-index.
+func manageUserIndexes() error {
+	s, err := store.Open(cfg.DatabasePath())
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		return fmt.Errorf("init schema: %w", err)
+	}
+
+	if explainDropIndex != "" {
+		if err := s.DropUserIndex(explainDropIndex); err != nil {
+			return err
+		}
+		out.Printf("%s %s\n", out.Good("Dropped"), explainDropIndex)
+		return nil
+	}
+
+	indexes, err := s.ListUserIndexes()
+	if err != nil {
+		return err
+	}
+	if len(indexes) == 0 {
+		out.Println("No user indexes.")
+		return nil
+	}
+	t := render.NewTable("Name", "Index", "Created")
+	for _, idx := range indexes {
+		t.Row(idx.Name, fmt.Sprintf("%s(%s)", idx.Table, strings.Join(idx.Columns, ", ")),
+			out.Muted(out.Date(idx.CreatedAt.Local())))
+	}
+	out.Table(t)
+	return nil
+}
+
+func init() {
+	explainSlowCmd.Flags().StringVarP(&explainFile, "file", "f", "", "Read SQL from file")
+	explainSlowCmd.Flags().BoolVar(&explainCreate, "create", false, "Create the suggested indexes")
+	explainSlowCmd.Flags().BoolVar(&explainListIndexes, "list-indexes", false, "List indexes created with --create")
+	explainSlowCmd.Flags().StringVar(&explainDropIndex, "drop-index", "", "Drop a user index by name")
+	rootCmd.AddCommand(explainSlowCmd)
+}
@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/ics"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportOutput         string
+	exportContactsFormat string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export data from the calendar archive",
+	Long: `Export data derived from the calendar archive to portable formats.
+
+Output is written to stdout unless --output is given.`,
+}
+
+var exportContactsCmd = &cobra.Command{
+	Use:   "contacts",
+	Short: "Export a contact list of meeting attendees",
+	Long: `Export unique attendees as a derived address book.
+
+Each contact includes name, email, first/last seen date, and the number of
+meetings shared. Your own attendee entries are excluded. Addresses that
+differ only in case or a +tag, and the aliases of an [[identity]], are one
+contact, named as in the identity.
+
+Examples:
+  calvault export contacts > contacts.csv
+  calvault export contacts --format vcf --output contacts.vcf`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if exportContactsFormat != "csv" && exportContactsFormat != "vcf" {
+			return fmt.Errorf("unsupported format %q (expected csv or vcf)", exportContactsFormat)
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		contacts, err := s.ListContacts()
+		if err != nil {
+			return fmt.Errorf("list contacts: %w", err)
+		}
+
+		return withExportOutput(func(w io.Writer) error {
+			if exportContactsFormat == "vcf" {
+				return writeContactsVCF(w, contacts)
+			}
+			return writeContactsCSV(w, contacts)
+		})
+	},
+}
+
+var (
+	exportICSCalendar string
+	exportICSSince    string
+	exportICSUntil    string
+)
+
+var exportICSCmd = &cobra.Command{
+	Use:   "ics",
+	Short: "Export events to an iCalendar (.ics) file",
+	Long: `Export stored events, including recurrence rules, attendees and
+reminders (as alarms), as a valid iCalendar file that can be imported into other calendar apps or kept
+as a portable backup.
+
+--calendar accepts a calendar ID from the database, a Google calendar ID,
+or a calendar name. Without it, events from all calendars are exported.
+--until is exclusive.
+
+Examples:
+  calvault export ics --calendar primary -o calendar.ics
+  calvault export ics --since 2024-01-01 --until 2025-01-01 > 2024.ics`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		since, err := parseDateFlag(exportICSSince)
+		if err != nil {
+			return err
+		}
+		until, err := parseDateFlag(exportICSUntil)
+		if err != nil {
+			return err
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		opts := ics.ExportOptions{
+			Filter: store.EventFilter{Since: since, Until: until},
+		}
+		if exportICSCalendar != "" {
+			cals, err := s.FindCalendars(exportICSCalendar)
+			if err != nil {
+				return fmt.Errorf("find calendar: %w", err)
+			}
+			switch len(cals) {
+			case 0:
+				return fmt.Errorf("calendar %q not found", exportICSCalendar)
+			case 1:
+			default:
+				return fmt.Errorf("calendar %q is ambiguous (%d matches) - use the numeric calendar ID", exportICSCalendar, len(cals))
+			}
+			cal := cals[0]
+			opts.Filter.CalendarID = cal.ID
+			opts.Name = cal.Summary
+			opts.Timezone = cal.Timezone
+		}
+
+		var count int
+		err = withExportOutput(func(w io.Writer) error {
+			var err error
+			count, err = ics.Export(s, w, opts)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("export ics: %w", err)
+		}
+
+		if exportOutput != "" && exportOutput != "-" {
+			fmt.Printf("Exported %d events to %s\n", count, exportOutput)
+		}
+		return nil
+	},
+}
+
+// withExportOutput calls fn with the writer selected by --output.
+func withExportOutput(fn func(w io.Writer) error) error {
+	if exportOutput == "" || exportOutput == "-" {
+		return fn(os.Stdout)
+	}
+
+	f, err := os.Create(exportOutput)
+	if err != nil {
+		return fmt.Errorf("create output file: %w", err)
+	}
+	if err := fn(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeContactsCSV writes contacts as CSV with a header row.
+func writeContactsCSV(w io.Writer, contacts []*store.Contact) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"name", "email", "first_seen", "last_seen", "meeting_count"}); err != nil {
+		return fmt.Errorf("write csv: %w", err)
+	}
+	for _, c := range contacts {
+		record := []string{
+			c.DisplayName,
+			c.Email,
+			formatDate(c.FirstSeen),
+			formatDate(c.LastSeen),
+			strconv.Itoa(c.MeetingCount),
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("write csv: %w", err)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeContactsVCF writes contacts as vCard 3.0 entries.
+func writeContactsVCF(w io.Writer, contacts []*store.Contact) error {
+	for _, c := range contacts {
+		name := c.DisplayName
+		if name == "" {
+			name = c.Email
+		}
+		note := fmt.Sprintf("%d meetings, first seen %s, last seen %s",
+			c.MeetingCount, formatDate(c.FirstSeen), formatDate(c.LastSeen))
+
+		lines := []string{
+			"BEGIN:VCARD",
+			"VERSION:3.0",
+			"FN:" + escapeVCard(name),
+			"N:" + escapeVCard(name) + ";;;;",
+			"EMAIL;TYPE=INTERNET:" + c.Email,
+			"NOTE:" + escapeVCard(note),
+			"END:VCARD",
+		}
+		if _, err := io.WriteString(w, strings.Join(lines, "\r\n")+"\r\n"); err != nil {
+			return fmt.Errorf("write vcf: %w", err)
+		}
+	}
+	return nil
+}
+
+// formatDate formats a date as YYYY-MM-DD, or empty for the zero time.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// escapeVCard escapes text values per RFC 2426.
+func escapeVCard(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+func init() {
+	exportCmd.PersistentFlags().StringVarP(&exportOutput, "output", "o", "", "Write to file instead of stdout")
+	exportContactsCmd.Flags().StringVar(&exportContactsFormat, "format", "csv", "Output format: csv or vcf")
+	exportICSCmd.Flags().StringVar(&exportICSCalendar, "calendar", "", "Calendar to export (ID, Google calendar ID, or name)")
+	exportICSCmd.Flags().StringVar(&exportICSSince, "since", "", "Only export events starting on or after this date (YYYY-MM-DD)")
+	exportICSCmd.Flags().StringVar(&exportICSUntil, "until", "", "Only export events starting before this date (YYYY-MM-DD)")
+	exportCmd.AddCommand(exportContactsCmd)
+	exportCmd.AddCommand(exportICSCmd)
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var historyJSON bool
+
+var historyCmd = &cobra.Command{
+	Use:   "history <event>",
+	Short: "Show how an event changed over time",
+	Long: `Show the changes syncs and imports made to an event: when it was moved,
+renamed, relocated or cancelled, with the values before and after.
+
+Changes are only recorded while [storage] event_history is on in
+config.toml; without it an update overwrites the event. <event> is a
+numeric event ID or a Google event ID (the id in 'calvault events --json').
+
+Examples:
+  calvault history 42
+  calvault history 4kq1sbu5bh5ffg2me6ukrd3j5c --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		events, err := s.FindEvents(args[0])
+		if err != nil {
+			return fmt.Errorf("find event: %w", err)
+		}
+		switch len(events) {
+		case 0:
+			return fmt.Errorf("event %q not found", args[0])
+		case 1:
+		default:
+			return fmt.Errorf("event %q is ambiguous (%d matches) - use the numeric event ID", args[0], len(events))
+		}
+		event := events[0]
+
+		versions, err := s.GetEventHistory(event.ID)
+		if err != nil {
+			return err
+		}
+		if historyJSON {
+			changes := eventChanges(event, versions, jsonEventTime)
+			entries := make([]historyJSONEntry, 0, len(changes))
+			for _, c := range changes {
+				entries = append(entries, historyJSONEntry{
+					ChangedAt: c.at.Format(time.RFC3339),
+					Field:     c.field,
+					Before:    c.before,
+					After:     c.after,
+				})
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(entries)
+		}
+
+		title := event.Summary
+		if title == "" {
+			title = "(no title)"
+		}
+		out.Title(fmt.Sprintf("History of %s", title))
+		changes := eventChanges(event, versions, eventTime)
+		if len(changes) == 0 {
+			if cfg.Storage.EventHistory {
+				out.Println("No changes recorded.")
+			} else {
+				out.Println("No changes recorded. Set event_history = true under [storage] in config.toml to keep them.")
+			}
+			return nil
+		}
+		t := render.NewTable("Changed", "Field", "Before", "After")
+		for _, c := range changes {
+			t.Row(out.DateTime(c.at.Local()), out.Label(c.field), out.Muted(oneLine(c.before, 40)), oneLine(c.after, 40))
+		}
+		out.Table(t)
+		return nil
+	},
+}
+
+// historyJSONEntry is the JSON shape of one changed field.
+type historyJSONEntry struct {
+	ChangedAt string `json:"changed_at"`
+	Field     string `json:"field"`
+	Before    string `json:"before"`
+	After     string `json:"after"`
+}
+
+// eventChange is a field that changed between two versions of an event.
+type eventChange struct {
+	at                   time.Time
+	field, before, after string
+}
+
+// eventChanges lists the field changes between an event's successive
+// versions, ending with the current one, oldest first. Times are formatted
+// with formatTime.
+func eventChanges(current *store.Event, versions []*store.EventVersion, formatTime func(sql.NullTime, bool) string) []eventChange {
+	var changes []eventChange
+	for i, v := range versions {
+		next := current
+		if i+1 < len(versions) {
+			next = versions[i+1].Event
+		}
+		for _, f := range eventFields(v.Event, next, formatTime) {
+			if f[1] != f[2] {
+				changes = append(changes, eventChange{at: v.ChangedAt, field: f[0], before: f[1], after: f[2]})
+			}
+		}
+	}
+	return changes
+}
+
+// eventFields returns the name and the before and after values of the
+// fields history shows. Descriptions are summarized by length, not shown.
+func eventFields(before, after *store.Event, formatTime func(sql.NullTime, bool) string) [][3]string {
+	text := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return fmt.Sprintf("%d characters", len(s))
+	}
+	descBefore, descAfter := text(before.Description), text(after.Description)
+	if before.Description != after.Description && descBefore == descAfter {
+		descAfter += ", edited"
+	}
+	field := func(name, b, a string) [3]string { return [3]string{name, b, a} }
+	return [][3]string{
+		field("Title", before.Summary, after.Summary),
+		field("Start", formatTime(before.StartTime, before.AllDay), formatTime(after.StartTime, after.AllDay)),
+		field("End", formatTime(before.EndTime, before.AllDay), formatTime(after.EndTime, after.AllDay)),
+		field("Time zone", before.OriginalTimezone, after.OriginalTimezone),
+		field("Location", before.Location, after.Location),
+		field("Status", before.Status, after.Status),
+		field("Visibility", before.Visibility, after.Visibility),
+		field("Organizer", before.OrganizerEmail, after.OrganizerEmail),
+		field("Recurrence", before.RecurrenceRule, after.RecurrenceRule),
+		field("Description", descBefore, descAfter),
+	}
+}
+
+// eventTime formats an event's start or end as events does.
+func eventTime(t sql.NullTime, allDay bool) string {
+	switch {
+	case !t.Valid:
+		return ""
+	case allDay:
+		return out.Date(t.Time.UTC())
+	default:
+		return out.DateTime(t.Time.Local())
+	}
+}
+
+// jsonEventTime formats an event's start or end for JSON: RFC 3339, or
+// YYYY-MM-DD for all-day events.
+func jsonEventTime(t sql.NullTime, allDay bool) string {
+	switch {
+	case !t.Valid:
+		return ""
+	case allDay:
+		return t.Time.UTC().Format("2006-01-02")
+	default:
+		return t.Time.Format(time.RFC3339)
+	}
+}
+
+func init() {
+	historyCmd.Flags().BoolVar(&historyJSON, "json", false, "Output as JSON")
+	rootCmd.AddCommand(historyCmd)
+}
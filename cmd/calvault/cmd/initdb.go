@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -16,7 +17,10 @@ var initDBCmd = &cobra.Command{
 
 This command creates all necessary tables for storing calendars, events,
 attendees, and sync state. It is safe to run multiple times - tables are 
-only created if they don't already exist.`,
+only created if they don't already exist.
+
+Existing databases are migrated to the current schema, and rows left
+without a parent (such as attendees of a deleted event) are removed.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		dbPath := cfg.DatabasePath()
 		logger.Info("initializing database", "path", dbPath)
@@ -36,6 +40,26 @@ only created if they don't already exist.`,
 			return fmt.Errorf("init schema: %w", err)
 		}
 
+		if err := applyStorageSettings(s); err != nil {
+			return err
+		}
+
+		orphans, err := s.CleanupOrphans()
+		if errors.Is(err, store.ErrLegalHold) {
+			logger.Info("skipping orphan cleanup under legal hold")
+			orphans = &store.OrphanCleanup{}
+		} else if err != nil {
+			return fmt.Errorf("clean up orphans: %w", err)
+		}
+		if orphans.Total() > 0 {
+			logger.Info("cleaned up orphaned rows",
+				"calendars", orphans.Calendars,
+				"events", orphans.Events,
+				"attendees", orphans.Attendees,
+				"watch_channels", orphans.WatchChannels,
+				"sync_runs", orphans.SyncRuns)
+		}
+
 		logger.Info("database initialized successfully")
 
 		// Print stats
@@ -44,10 +68,12 @@ only created if they don't already exist.`,
 			return fmt.Errorf("get stats: %w", err)
 		}
 
-		fmt.Printf("Database: %s\n", dbPath)
-		fmt.Printf("  Accounts:   %d\n", stats.AccountCount)
-		fmt.Printf("  Calendars:  %d\n", stats.CalendarCount)
-		fmt.Printf("  Events:     %d\n", stats.EventCount)
+		out.Printf("Database: %s\n", out.Accent(dbPath))
+		out.KeyValues(
+			"Accounts", out.Number(int64(stats.AccountCount)),
+			"Calendars", out.Number(int64(stats.CalendarCount)),
+			"Events", out.Number(int64(stats.EventCount)),
+		)
 
 		return nil
 	},
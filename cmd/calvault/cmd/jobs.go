@@ -0,0 +1,342 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	gosync "sync"
+	"syscall"
+	"time"
+
+	"github.com/salman1993/calvault/internal/daemon"
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/salman1993/calvault/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+var jobsAll bool
+
+var jobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "Show long-running operations and their progress",
+	Long: `Show the long-running operations - full syncs, 'calvault enrich' and
+expanding recurring series with 'calvault analyze refresh' - with how far
+each got.
+
+Progress is saved as a job runs, so a job stopped by Ctrl+C, a crash or a
+restart is listed as interrupted and can be continued with
+'calvault jobs resume <id>'. A resumed full sync continues from the last
+page each calendar stored, and a resumed enrichment from the events its
+stages haven't processed yet.
+
+Examples:
+  calvault jobs
+  calvault jobs --all
+  calvault jobs resume 12`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		if _, err := s.MarkDeadJobs(daemon.ProcessAlive); err != nil {
+			return err
+		}
+		limit := 20
+		if jobsAll {
+			limit = 0
+		}
+		jobs, err := s.ListJobs(limit)
+		if err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			out.Println("No jobs recorded yet.")
+			return nil
+		}
+
+		t := render.NewTable("ID", "Kind", "Description", "Status", "Progress", "Started", "Took").AlignRight(0, 4)
+		var resumable bool
+		for _, job := range jobs {
+			t.Row(strconv.FormatInt(job.ID, 10), job.Kind, oneLine(job.Description, 40), jobStatus(job.Status),
+				jobProgress(job), out.DateTime(job.StartedAt.Local()), jobDuration(job))
+			if job.Status == store.JobInterrupted || job.Status == store.JobFailed {
+				resumable = true
+			}
+		}
+		out.Table(t)
+		for _, job := range jobs {
+			if job.Error != "" && (job.Status == store.JobFailed || job.Status == store.JobInterrupted) {
+				out.Println(out.Muted(fmt.Sprintf("Job %d: %s", job.ID, oneLine(job.Error, 100))))
+			}
+		}
+		if resumable {
+			out.Println()
+			out.Println(out.Muted("Run 'calvault jobs resume <id>' to continue an interrupted or failed job."))
+		}
+		return nil
+	},
+}
+
+var jobsResumeCmd = &cobra.Command{
+	Use:   "resume <id>",
+	Short: "Continue an interrupted or failed job",
+	Long: `Continue an interrupted or failed job where it stopped, as a new job that
+carries on its progress.
+
+Examples:
+  calvault jobs resume 12`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid job ID %q", args[0])
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		if _, err := s.MarkDeadJobs(daemon.ProcessAlive); err != nil {
+			return err
+		}
+		job, err := s.GetJob(id)
+		if err != nil {
+			return err
+		}
+		switch job.Status {
+		case store.JobInterrupted, store.JobFailed:
+		case store.JobRunning:
+			return fmt.Errorf("job %d is still running (pid %d)", job.ID, job.PID)
+		case store.JobResumed:
+			return fmt.Errorf("job %d was already resumed by a later job - see 'calvault jobs'", job.ID)
+		default:
+			return fmt.Errorf("job %d %s; only interrupted or failed jobs can be resumed", job.ID, job.Status)
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sigChan
+			fmt.Println("\nInterrupted. Stopping job...")
+			cancel()
+		}()
+
+		out.Printf("Resuming job %d: %s\n\n", job.ID, job.Description)
+		resumeJobID = job.ID
+		defer func() { resumeJobID = 0 }()
+
+		switch job.Kind {
+		case "sync":
+			return resumeSyncJob(ctx, s, job)
+		case "enrich":
+			return resumeEnrichJob(ctx, s, job)
+		case "expand":
+			n, err := expandInstancesJob(s)
+			if err != nil {
+				return err
+			}
+			out.Printf("Expanded %d occurrences of recurring series\n", n)
+			return nil
+		default:
+			return fmt.Errorf("job %d has unknown kind %q", job.ID, job.Kind)
+		}
+	},
+}
+
+// resumeSyncJob runs the full sync of a job's account - or the one
+// calendar it was limited to - again, which continues from each calendar's
+// stored page token.
+func resumeSyncJob(ctx context.Context, s *store.Store, job *store.Job) error {
+	src, err := s.GetSourceByIdentifier(job.Params["account"])
+	if err != nil {
+		return fmt.Errorf("get account: %w", err)
+	}
+	if src == nil {
+		return fmt.Errorf("account %s no longer exists", job.Params["account"])
+	}
+	mgr, err := oauthManagers{}.get(src.SourceType)
+	if err != nil {
+		return err
+	}
+	lock, err := acquireSyncLock()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lock.Release() }()
+
+	run := newSyncRun(s, false)
+	run.calendar = job.Params["calendar"]
+	if err := runSync(ctx, s, run, mgr, src, false, false); err != nil {
+		return err
+	}
+	refreshDerivedTables(s)
+	recordStatsSnapshot(s, "sync")
+	return nil
+}
+
+// resumeEnrichJob runs a job's enrichment stages again, which continue
+// from the events they haven't processed.
+func resumeEnrichJob(ctx context.Context, s *store.Store, job *store.Job) error {
+	var names []string
+	if job.Params["stages"] != "" {
+		names = strings.Split(job.Params["stages"], ",")
+	}
+	limit, _ := strconv.Atoi(job.Params["limit"])
+	stages, err := newEnrichStages(names, job.Params["explicit"] == "true", job.Params["extractor"])
+	if err != nil {
+		return err
+	}
+	return runEnrich(ctx, s, stages, enrichJobParams(names, job.Params["explicit"] == "true", job.Params["extractor"], limit), limit)
+}
+
+// resumeJobID is the job the next job started resumes, set by
+// 'jobs resume' for the operation it runs.
+var resumeJobID int64
+
+// jobTracker records a long-running operation's progress in the jobs
+// table, saving it at most once a second. A nil tracker does nothing, so
+// an operation runs the same whether or not its job could be recorded.
+type jobTracker struct {
+	s     *store.Store
+	id    int64
+	mu    gosync.Mutex
+	done  int64
+	total int64 // -1 if unknown
+	saved time.Time
+}
+
+// startJob records a job for an operation, resuming resumeJobID if set.
+// Failing to record it is only logged.
+func startJob(s *store.Store, kind, description string, params map[string]string) *jobTracker {
+	resumed := resumeJobID
+	resumeJobID = 0
+	job, err := s.StartJob(kind, description, params, resumed)
+	if err != nil {
+		logger.Warn("failed to record job", "kind", kind, "error", err)
+		return nil
+	}
+	return &jobTracker{s: s, id: job.ID, done: job.Done, total: -1, saved: time.Now()}
+}
+
+// add counts n more items done.
+func (t *jobTracker) add(n int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done += n
+	if time.Since(t.saved) >= time.Second {
+		t.save()
+	}
+}
+
+// setRemaining sets how many more items the job expects to process.
+func (t *jobTracker) setRemaining(n int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total = t.done + n
+	t.save()
+}
+
+// save writes the progress; the caller holds t.mu.
+func (t *jobTracker) save() {
+	if err := t.s.UpdateJobProgress(t.id, t.done, t.total); err != nil {
+		logger.Warn("failed to save job progress", "job", t.id, "error", err)
+	}
+	t.saved = time.Now()
+}
+
+// finish records how the job ended: interrupted if err is a cancelled
+// context or a spent request budget, failed for any other error and
+// completed otherwise.
+func (t *jobTracker) finish(err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := store.JobCompleted
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, sync.ErrBudgetExhausted):
+		status = store.JobInterrupted
+	case err != nil:
+		status = store.JobFailed
+	}
+	if ferr := t.s.FinishJob(t.id, status, t.done, err); ferr != nil {
+		logger.Warn("failed to finish job", "job", t.id, "error", ferr)
+	}
+}
+
+// jobSyncProgress counts the events a sync stores into its job.
+type jobSyncProgress struct {
+	accountProgress
+	job *jobTracker
+}
+
+func (p *jobSyncProgress) OnEvent(eventSummary string) {
+	p.job.add(1)
+}
+
+// jobStatus colors a job status.
+func jobStatus(status string) string {
+	switch status {
+	case store.JobCompleted:
+		return out.Good(status)
+	case store.JobFailed:
+		return out.Bad(status)
+	case store.JobInterrupted:
+		return out.Warn(status)
+	case store.JobRunning:
+		return out.Accent(status)
+	}
+	return out.Muted(status)
+}
+
+// jobProgress formats a job's items done, with its percentage of the
+// total if known.
+func jobProgress(job *store.Job) string {
+	if !job.Total.Valid || job.Total.Int64 <= 0 {
+		return out.Number(job.Done)
+	}
+	pct := float64(job.Done) / float64(job.Total.Int64) * 100
+	return fmt.Sprintf("%s/%s (%.0f%%)", out.Number(job.Done), out.Number(job.Total.Int64), pct)
+}
+
+// jobDuration formats how long a job ran, or has been running.
+func jobDuration(job *store.Job) string {
+	end := time.Now()
+	if job.FinishedAt.Valid {
+		end = job.FinishedAt.Time
+	}
+	return end.Sub(job.StartedAt).Round(time.Second).String()
+}
+
+func init() {
+	jobsCmd.Flags().BoolVar(&jobsAll, "all", false, "Show every job, not only the 20 most recent")
+	jobsCmd.AddCommand(jobsResumeCmd)
+	rootCmd.AddCommand(jobsCmd)
+}
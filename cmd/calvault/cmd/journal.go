@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/salman1993/calvault/internal/journal"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var journalTemplate string
+
+var journalCmd = &cobra.Command{
+	Use:   "journal [date]",
+	Short: "Render a day's events through a template for journaling",
+	Long: `Render one day's events (default today) through a Go text/template and
+print the result, e.g. to start a daily note in a journaling app. Without
+--template, a Markdown outline is printed.
+
+Templates get:
+  .Date     The day (time.Time)
+  .Events   Occurrences, recurring series expanded. Each has .Summary,
+            .Description, .Location, .Status, .Calendar, .Account, .Start,
+            .End, .AllDay, .Recurring, .Declined and .Attendees
+  .People   Attendees other than you, most meetings first, with .Name,
+            .Email and .Meetings; printing one gives "Name <email>"
+  .Hours    Hours in timed events you didn't decline
+
+and the functions date and time, which format a time in the output locale.
+
+Examples:
+  calvault journal
+  calvault journal 2024-06-12 --template templates/day.tmpl >> journal/2024-06-12.md`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		now := time.Now()
+		date := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		if len(args) == 1 {
+			var err error
+			if date, err = parseDateFlag(args[0]); err != nil {
+				return err
+			}
+		}
+
+		name, text := "default", journal.DefaultTemplate
+		if journalTemplate != "" {
+			data, err := os.ReadFile(journalTemplate)
+			if err != nil {
+				return fmt.Errorf("read template: %w", err)
+			}
+			name, text = journalTemplate, string(data)
+		}
+		tmpl, err := journal.Parse(name, text, out.Locale())
+		if err != nil {
+			return fmt.Errorf("parse template: %w", err)
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		day, err := journal.Build(s, date)
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Execute(os.Stdout, day); err != nil {
+			return fmt.Errorf("render template: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	journalCmd.Flags().StringVarP(&journalTemplate, "template", "t", "", "Go text/template file (default: built-in Markdown outline)")
+	rootCmd.AddCommand(journalCmd)
+}
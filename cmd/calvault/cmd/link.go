@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/salman1993/calvault/internal/notes"
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	linkFile     string
+	linkUnlink   bool
+	linkMatchDir string
+	linkDryRun   bool
+)
+
+var linkCmd = &cobra.Command{
+	Use:   "link [event-id]",
+	Short: "Link meeting notes to events",
+	Long: `Link meeting note files to the events they're about, so the archive
+becomes an index into your notes. Links are stored in the event_notes table;
+the files themselves stay where they are.
+
+The event ID is the numeric ID from the events table or a Google event ID
+(the id in 'calvault events --json'). Without --file, the event's linked
+notes are listed.
+
+--match-dir links notes in bulk: every .md, .markdown, .txt or .org file
+under the directory whose name starts with a date, such as
+2024-06-12-standup.md, is linked to the event that day whose title shares
+the most words with the rest of the name ("standup" matches "Daily
+Standup"; occurrences of recurring series count). Notes that are already
+linked, match no event, or match several equally well are left alone.
+
+Examples:
+  calvault link 4182 --file ~/notes/2024-06-12-standup.md
+  calvault link 4182
+  calvault link 4182 --file ~/notes/2024-06-12-standup.md --unlink
+  calvault link --match-dir ~/notes --dry-run`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if linkMatchDir != "" {
+			if len(args) > 0 || linkFile != "" || linkUnlink {
+				return fmt.Errorf("--match-dir can't be combined with an event ID, --file or --unlink")
+			}
+		} else {
+			if len(args) == 0 {
+				return fmt.Errorf("expected an event ID, or --match-dir")
+			}
+			if linkUnlink && linkFile == "" {
+				return fmt.Errorf("--unlink requires --file")
+			}
+			if linkDryRun {
+				return fmt.Errorf("--dry-run only applies to --match-dir")
+			}
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		if linkMatchDir != "" {
+			return matchNotes(s)
+		}
+
+		events, err := s.FindEvents(args[0])
+		if err != nil {
+			return fmt.Errorf("find event: %w", err)
+		}
+		switch len(events) {
+		case 0:
+			return fmt.Errorf("event %q not found", args[0])
+		case 1:
+		default:
+			return fmt.Errorf("event %q is ambiguous (%d matches) - use the numeric event ID", args[0], len(events))
+		}
+		event := events[0]
+
+		if linkFile == "" {
+			return listNotes(s, event)
+		}
+
+		path, err := filepath.Abs(linkFile)
+		if err != nil {
+			return err
+		}
+		if linkUnlink {
+			removed, err := s.UnlinkNote(event.ID, path)
+			if err != nil {
+				return err
+			}
+			if !removed {
+				return fmt.Errorf("%s is not linked to this event", path)
+			}
+			out.Printf("%s %s from %s\n", out.Good("Unlinked"), path, eventLabel(event))
+			return nil
+		}
+
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("note file: %w", err)
+		}
+		created, err := s.LinkNote(&store.EventNote{EventID: event.ID, Path: path})
+		if err != nil {
+			return err
+		}
+		if !created {
+			out.Println(out.Muted(fmt.Sprintf("%s is already linked to %s", path, eventLabel(event))))
+			return nil
+		}
+		out.Printf("%s %s to %s\n", out.Good("Linked"), path, eventLabel(event))
+		return nil
+	},
+}
+
+// listNotes prints the notes linked to an event.
+func listNotes(s *store.Store, event *store.Event) error {
+	linked, err := s.ListNotes(event.ID)
+	if err != nil {
+		return err
+	}
+	out.Title(eventLabel(event))
+	if len(linked) == 0 {
+		out.Println("No notes linked. Use --file to link one.")
+		return nil
+	}
+	t := render.NewTable("Note", "Occurrence", "Linked")
+	for _, n := range linked {
+		path := n.Path
+		if _, err := os.Stat(path); err != nil {
+			path += " " + out.Warn("(missing)")
+		}
+		occurrence := ""
+		if n.Occurrence.Valid {
+			occurrence = out.DateTime(n.Occurrence.Time.Local())
+		}
+		how := "by hand"
+		if n.Matched {
+			how = "by file name"
+		}
+		t.Row(path, occurrence, out.Muted(how))
+	}
+	out.Table(t)
+	return nil
+}
+
+// matchNotes links the notes under --match-dir by date and title.
+func matchNotes(s *store.Store) error {
+	summary, err := notes.Match(s, linkMatchDir, time.Local, linkDryRun)
+	if err != nil {
+		return err
+	}
+	if len(summary.Linked) > 0 {
+		t := render.NewTable("Note", "Event", "Start")
+		for _, l := range summary.Linked {
+			t.Row(filepath.Base(l.Path), out.Accent(oneLine(l.Summary, 50)), out.DateTime(l.Start.Local()))
+		}
+		out.Table(t)
+		out.Println()
+	}
+	verb := "Linked"
+	if linkDryRun {
+		verb = "Would link"
+	}
+	out.Printf("%s %s of %s dated notes\n", out.Good(verb),
+		out.Number(int64(len(summary.Linked))), out.Number(int64(summary.Notes)))
+	out.KeyValues(
+		"Already linked", out.Number(int64(summary.Skipped)),
+		"No matching event", out.Number(int64(summary.Unmatched)),
+		"Ambiguous", out.Number(int64(summary.Ambiguous)),
+	)
+	return nil
+}
+
+// eventLabel shows an event's title and start.
+func eventLabel(e *store.Event) string {
+	title := e.Summary
+	if title == "" {
+		title = "(no title)"
+	}
+	switch {
+	case !e.StartTime.Valid:
+		return title
+	case e.AllDay:
+		return fmt.Sprintf("%s (%s)", title, out.Date(e.StartTime.Time.UTC()))
+	default:
+		return fmt.Sprintf("%s (%s)", title, out.DateTime(e.StartTime.Time.Local()))
+	}
+}
+
+func init() {
+	linkCmd.Flags().StringVar(&linkFile, "file", "", "Note file to link to the event")
+	linkCmd.Flags().BoolVar(&linkUnlink, "unlink", false, "Remove the link to --file instead")
+	linkCmd.Flags().StringVar(&linkMatchDir, "match-dir", "", "Link dated notes under this directory to events by date and title")
+	linkCmd.Flags().BoolVar(&linkDryRun, "dry-run", false, "With --match-dir, show the links without storing them")
+	rootCmd.AddCommand(linkCmd)
+}
@@ -0,0 +1,348 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/salman1993/calvault/internal/config"
+	"github.com/salman1993/calvault/internal/daemon"
+	"github.com/salman1993/calvault/internal/oauth"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateDatabase string
+	migrateKeep     bool
+)
+
+var migrateHomeCmd = &cobra.Command{
+	Use:   "migrate-home [new-home]",
+	Short: "Move the database, tokens and config to a new location",
+	Long: `Move the calvault home directory (database, OAuth tokens, config and any
+other files in it) to a new directory, e.g. on an encrypted disk.
+
+The database is copied with SQLite's VACUUM INTO, so the copy is consistent
+even if the database is in use. Paths in the config file that point into the
+old home are rewritten to the new one. Nothing is removed from the old home
+until everything has been copied; if any step fails, the partial copy is
+removed and the old home is left as it was. Use --keep to leave the old home
+in place after a successful move.
+
+The new home must be empty or not exist. Afterwards, set CALVAULT_HOME to it.
+
+With --database, only the database moves: it is copied to the given path and
+[storage] database is set in the config file, keeping config and tokens in
+the home directory.
+
+Sync and the daemon must not be running.
+
+Examples:
+  calvault migrate-home /Volumes/Vault/calvault
+  calvault migrate-home --database /mnt/encrypted/calvault.db`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if migrateDatabase != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Neither a sync nor the daemon may write while files move
+		syncLock, err := acquireSyncLock()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = syncLock.Release() }()
+		daemonLock, err := daemon.AcquireLock(cfg.DaemonLockPath())
+		if errors.Is(err, daemon.ErrLocked) {
+			return fmt.Errorf("stop the daemon before migrating: %w", err)
+		}
+		if err != nil {
+			return err
+		}
+		defer func() { _ = daemonLock.Release() }()
+
+		if migrateDatabase != "" {
+			return moveDatabase(migrateDatabase)
+		}
+		if err := moveHome(args[0]); err != nil {
+			return err
+		}
+		if !migrateKeep {
+			// The locks live in the old home; remove it once they're gone
+			_ = daemonLock.Release()
+			_ = syncLock.Release()
+			_ = os.Remove(cfg.HomeDir)
+		}
+		return nil
+	},
+}
+
+// moveDatabase copies the database to path and points the config at it.
+func moveDatabase(path string) error {
+	dest, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	old := cfg.DatabasePath()
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("%s already exists", dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o700); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+
+	s, err := store.Open(old)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	err = s.CopyTo(dest)
+	_ = s.Close()
+	if err != nil {
+		return err
+	}
+	if err := config.SetString(cfg.File, "storage", "database", dest); err != nil {
+		_ = os.Remove(dest)
+		return fmt.Errorf("update config: %w", err)
+	}
+	logger.Info("moved database", "from", old, "to", dest)
+
+	if !migrateKeep {
+		removeDatabaseFiles(old)
+	}
+	out.Printf("%s database to %s\n", out.Good("Moved"), out.Accent(dest))
+	out.Println(out.Muted("Updated [storage] database in " + cfg.File))
+	return nil
+}
+
+// moveHome copies the home directory to newHome, rewriting config paths and
+// re-keying tokens, then removes the old copy unless --keep is set.
+func moveHome(newHome string) error {
+	oldHome, err := filepath.Abs(cfg.HomeDir)
+	if err != nil {
+		return err
+	}
+	dest, err := filepath.Abs(newHome)
+	if err != nil {
+		return err
+	}
+	if dest == oldHome || pathWithin(dest, oldHome) {
+		return fmt.Errorf("%s is inside the current home %s", dest, oldHome)
+	}
+	created, err := prepareEmptyDir(dest)
+	if err != nil {
+		return err
+	}
+
+	s, err := store.Open(cfg.DatabasePath())
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+	sources, err := s.ListSources()
+	if err != nil {
+		return err
+	}
+
+	newCfg := *cfg
+	newCfg.HomeDir = dest
+	copied, err := copyHome(s, oldHome, dest, sources, &newCfg)
+	if err != nil {
+		// Roll back: the old home is untouched, so drop the partial copy
+		for _, src := range sources {
+			if dir := tokensDirOf(&newCfg, src.SourceType); dir != "" {
+				_ = oauth.DeleteToken(dir, src.Identifier)
+			}
+		}
+		if created {
+			_ = os.RemoveAll(dest)
+		} else {
+			removeDirContents(dest)
+		}
+		return fmt.Errorf("migrate home: %w", err)
+	}
+	logger.Info("migrated home", "from", oldHome, "to", dest, "files", len(copied))
+
+	if !migrateKeep {
+		_ = s.Close()
+		for _, src := range sources {
+			if dir := tokensDirFor(src.SourceType); dir != "" {
+				if err := oauth.DeleteToken(dir, src.Identifier); err != nil {
+					logger.Warn("remove old token", "account", src.Identifier, "error", err)
+				}
+			}
+		}
+		if pathWithin(cfg.DatabasePath(), oldHome) {
+			removeDatabaseFiles(cfg.DatabasePath())
+		}
+		removeCopied(oldHome, copied)
+	}
+
+	out.Printf("%s %s to %s\n", out.Good("Moved"), oldHome, out.Accent(dest))
+	if !pathWithin(cfg.DatabasePath(), oldHome) {
+		out.Println(out.Muted("The database at " + cfg.DatabasePath() + " was left in place."))
+	}
+	out.Printf("Set CALVAULT_HOME=%s before running calvault again.\n", dest)
+	return nil
+}
+
+// copyHome copies everything in oldHome to dest. It returns the copied
+// paths, relative to oldHome.
+func copyHome(s *store.Store, oldHome, dest string, sources []*store.Source, newCfg *config.Config) ([]string, error) {
+	db := cfg.DatabasePath()
+	dbInHome := pathWithin(db, oldHome)
+	if dbInHome {
+		rel, err := filepath.Rel(oldHome, db)
+		if err != nil {
+			return nil, err
+		}
+		target := filepath.Join(dest, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+			return nil, err
+		}
+		out.Printf("Copying database to %s...\n", target)
+		if err := s.CopyTo(target); err != nil {
+			return nil, err
+		}
+	}
+
+	var copied []string
+	err := filepath.WalkDir(oldHome, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(oldHome, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			copied = append(copied, rel)
+			return os.MkdirAll(filepath.Join(dest, rel), 0o700)
+		case dbInHome && (path == db || path == db+"-wal" || path == db+"-shm"):
+			return nil
+		case strings.HasSuffix(path, ".lock"):
+			return nil
+		case !d.Type().IsRegular():
+			logger.Warn("skipping non-regular file", "path", path)
+			return nil
+		}
+		if err := copyFile(path, filepath.Join(dest, rel)); err != nil {
+			return err
+		}
+		copied = append(copied, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens held outside the home (Windows Credential Manager) are keyed by
+	// path and need copying under the new one; token files were copied above.
+	for _, src := range sources {
+		if from := tokensDirFor(src.SourceType); from != "" {
+			if err := oauth.CopyToken(from, tokensDirOf(newCfg, src.SourceType), src.Identifier); err != nil {
+				return nil, fmt.Errorf("copy token for %s: %w", src.Identifier, err)
+			}
+		}
+	}
+
+	// Point paths in the config at the new home: the copy if the config lives
+	// in the home, otherwise the file itself, as the final step.
+	configFile := cfg.File
+	if pathWithin(configFile, oldHome) {
+		rel, err := filepath.Rel(oldHome, configFile)
+		if err != nil {
+			return nil, err
+		}
+		configFile = filepath.Join(dest, rel)
+	}
+	if err := config.ReplaceDir(configFile, oldHome, dest); err != nil {
+		return nil, fmt.Errorf("update config: %w", err)
+	}
+	return copied, nil
+}
+
+// prepareEmptyDir makes sure dir exists and is empty, reporting whether it
+// was created.
+func prepareEmptyDir(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return false, fmt.Errorf("create %s: %w", dir, err)
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if len(entries) > 0 {
+		return false, fmt.Errorf("%s is not empty", dir)
+	}
+	return false, nil
+}
+
+func copyFile(from, to string) error {
+	info, err := os.Stat(from)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	f, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, in); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// pathWithin reports whether path is inside dir.
+func pathWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func removeDatabaseFiles(db string) {
+	for _, path := range []string{db, db + "-wal", db + "-shm"} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logger.Warn("remove old database", "path", path, "error", err)
+		}
+	}
+}
+
+// removeCopied removes the copied files from oldHome, then directories that
+// are left empty.
+func removeCopied(oldHome string, copied []string) {
+	for i := len(copied) - 1; i >= 0; i-- {
+		path := filepath.Join(oldHome, copied[i])
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logger.Debug("left old file in place", "path", path, "error", err)
+		}
+	}
+}
+
+func removeDirContents(dir string) {
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		_ = os.RemoveAll(filepath.Join(dir, e.Name()))
+	}
+}
+
+func init() {
+	migrateHomeCmd.Flags().StringVar(&migrateDatabase, "database", "", "Move only the database to this path")
+	migrateHomeCmd.Flags().BoolVar(&migrateKeep, "keep", false, "Keep the old files after copying")
+	rootCmd.AddCommand(migrateHomeCmd)
+}
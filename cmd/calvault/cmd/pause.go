@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/salman1993/calvault/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var pauseStatus bool
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause [duration]",
+	Short: "Pause the daemon's scheduled syncs",
+	Long: `Pause the daemon's scheduled and push-triggered syncs, e.g. while on a
+metered hotspot or during heavy work on the same machine. With a duration
+(such as 2h or 45m) syncs resume on their own afterwards; without one they
+stay paused until 'calvault resume'. The pause survives daemon restarts.
+
+Manual 'calvault sync' runs are not affected. For a recurring pause, set
+quiet hours in config.toml:
+
+  [daemon]
+  quiet_hours = ["22:00-07:00"]
+
+Examples:
+  calvault pause 2h
+  calvault pause --status
+  calvault resume`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if pauseStatus {
+			if len(args) > 0 {
+				return fmt.Errorf("--status takes no duration")
+			}
+			until, paused, err := daemon.PausedUntil(cfg.PausePath(), time.Now())
+			if err != nil {
+				return err
+			}
+			switch {
+			case !paused:
+				out.Println("Syncs are not paused.")
+			case until.IsZero():
+				out.Println(out.Warn("Syncs are paused until 'calvault resume'."))
+			default:
+				out.Println(out.Warn(fmt.Sprintf("Syncs are paused until %s.", out.DateTime(until))))
+			}
+			return nil
+		}
+
+		var until time.Time
+		if len(args) == 1 {
+			d, err := time.ParseDuration(args[0])
+			if err != nil || d <= 0 {
+				return fmt.Errorf("invalid duration %q (expected e.g. 2h or 45m)", args[0])
+			}
+			until = time.Now().Add(d)
+		}
+		if err := daemon.Pause(cfg.PausePath(), until); err != nil {
+			return err
+		}
+		if until.IsZero() {
+			out.Println("Syncs paused until 'calvault resume'.")
+		} else {
+			out.Printf("Syncs paused until %s.\n", out.DateTime(until))
+		}
+		return nil
+	},
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume syncs paused with 'calvault pause'",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		paused, err := daemon.Resume(cfg.PausePath())
+		if err != nil {
+			return err
+		}
+		if paused {
+			out.Println("Syncs resumed.")
+		} else {
+			out.Println("Syncs were not paused.")
+		}
+		return nil
+	},
+}
+
+func init() {
+	pauseCmd.Flags().BoolVar(&pauseStatus, "status", false, "Show whether syncs are paused")
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(resumeCmd)
+}
@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	gosync "sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/salman1993/calvault/internal/render"
+)
+
+// noProgress turns off the live sync progress display.
+var noProgress bool
+
+// CLIProgress implements sync.Progress for terminal output. When live, it
+// keeps a progress bar per calendar syncing at the bottom of the terminal,
+// redrawn in place, with counts of events and pages, the rate, and an ETA
+// for calendars whose size is known; otherwise it prints a line as each
+// calendar starts and finishes, which suits CI and log files.
+type CLIProgress struct {
+	f    *os.File // Where output goes: stdout, or stderr under sync --json
+	live bool
+
+	mu        gosync.Mutex
+	calendars []*calendarProgress // Syncing, in the order they started
+	drawn     int                 // Lines of bars on screen
+	drawnAt   time.Time
+}
+
+// calendarProgress is one calendar's progress bar.
+type calendarProgress struct {
+	name                    string
+	expected, events, pages int
+	start                   time.Time
+}
+
+// progressRedrawInterval limits how often bars are redrawn as pages
+// arrive.
+const progressRedrawInterval = 100 * time.Millisecond
+
+// progressBarWidth is the width of a progress bar, in cells.
+const progressBarWidth = 20
+
+// drawing is the live progress display on screen, if any; logs are
+// written around it.
+var drawing atomic.Pointer[CLIProgress]
+
+// newCLIProgress returns a progress display on f, which out also writes
+// to, live unless --no-progress is set or f isn't a terminal.
+func newCLIProgress(f *os.File) *CLIProgress {
+	p := &CLIProgress{f: f}
+	if noProgress || os.Getenv("TERM") == "dumb" {
+		return p
+	}
+	stat, err := f.Stat()
+	p.live = err == nil && stat.Mode()&os.ModeCharDevice != 0
+	return p
+}
+
+func (p *CLIProgress) OnCalendarStart(calendarName string, expectedEvents int) {
+	if !p.live {
+		out.Printf("Syncing: %s\n", out.Accent(calendarName))
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calendars = append(p.calendars, &calendarProgress{name: calendarName, expected: expectedEvents, start: time.Now()})
+	drawing.Store(p)
+	p.redraw()
+}
+
+func (p *CLIProgress) OnPage(calendarName string, events int) {
+	if !p.live {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c := p.find(calendarName); c != nil {
+		c.events += events
+		c.pages++
+	}
+	if time.Since(p.drawnAt) >= progressRedrawInterval {
+		p.redraw()
+	}
+}
+
+func (p *CLIProgress) OnCalendarDone(calendarName string, added, updated, deleted int) {
+	// Calendars sync concurrently, so name the calendar each result is for
+	line := fmt.Sprintf("  %s → %s /%d %s", calendarName,
+		out.Good(fmt.Sprintf("+%d", added)), updated, out.Bad(fmt.Sprintf("-%d", deleted)))
+	if !p.live {
+		out.Println(line)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c := p.find(calendarName); c != nil {
+		if elapsed := time.Since(c.start); elapsed >= time.Second {
+			line += out.Muted(fmt.Sprintf(" in %s, %s pages", elapsed.Round(time.Second), out.Number(int64(c.pages))))
+		}
+		p.remove(c)
+	}
+	p.clear()
+	out.Println(line)
+	p.redraw()
+}
+
+func (p *CLIProgress) OnEvent(eventSummary string) {}
+
+// finish clears the bars of calendars named under prefix that didn't
+// finish - failed, deferred or interrupted ones - once their sync returns.
+func (p *CLIProgress) finish(prefix string) {
+	if !p.live {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calendars = slices.DeleteFunc(p.calendars, func(c *calendarProgress) bool {
+		return strings.HasPrefix(c.name, prefix)
+	})
+	p.redraw()
+	if len(p.calendars) == 0 {
+		drawing.CompareAndSwap(p, nil)
+	}
+}
+
+// print runs fn, which writes to out, with the bars cleared, so output of
+// several lines isn't torn by them or split by other accounts' results.
+func (p *CLIProgress) print(fn func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	fn()
+	if p.live {
+		p.redraw()
+	}
+}
+
+// find returns the bar of the calendar syncing under name.
+func (p *CLIProgress) find(name string) *calendarProgress {
+	for _, c := range p.calendars {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (p *CLIProgress) remove(c *calendarProgress) {
+	for i := range p.calendars {
+		if p.calendars[i] == c {
+			p.calendars = append(p.calendars[:i], p.calendars[i+1:]...)
+			return
+		}
+	}
+}
+
+// clear erases the bars, leaving the cursor where the first one was; the
+// caller holds p.mu.
+func (p *CLIProgress) clear() {
+	if p.drawn > 0 {
+		fmt.Fprintf(p.f, "\x1b[%dA\x1b[J", p.drawn)
+		p.drawn = 0
+	}
+}
+
+// redraw replaces the bars on screen; the caller holds p.mu.
+func (p *CLIProgress) redraw() {
+	p.clear()
+	now := time.Now()
+	var b strings.Builder
+	for _, c := range p.calendars {
+		b.WriteString(c.line(now))
+		b.WriteString("\n")
+	}
+	fmt.Fprint(p.f, b.String())
+	p.drawn = len(p.calendars)
+	p.drawnAt = now
+}
+
+// line formats a calendar's bar: a bar, percentage and ETA if its size is
+// known, its event and page counts, and the rate.
+func (c *calendarProgress) line(now time.Time) string {
+	name := oneLine(c.name, 24)
+	name += strings.Repeat(" ", max(24-render.Width(name), 0))
+	elapsed := now.Sub(c.start).Seconds()
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(c.events) / elapsed
+	}
+
+	line := "  " + name + "  "
+	var parts []string
+	if c.expected > 0 {
+		// Estimates can fall short; hold at 99% until the calendar is done
+		done := min(float64(c.events)/float64(c.expected), 0.99)
+		filled := int(done * progressBarWidth)
+		line += out.Accent(strings.Repeat("█", filled)) + out.Muted(strings.Repeat("░", progressBarWidth-filled)) + "  "
+		parts = append(parts,
+			fmt.Sprintf("%3d%%", int(done*100)),
+			fmt.Sprintf("%s/%s events", out.Number(int64(c.events)), out.Number(int64(c.expected))))
+	} else {
+		parts = append(parts, fmt.Sprintf("%s events", out.Number(int64(c.events))))
+	}
+	parts = append(parts, fmt.Sprintf("%s pages", out.Number(int64(c.pages))))
+	if c.pages > 0 {
+		parts = append(parts, fmt.Sprintf("%.0f/s", rate))
+		if remaining := c.expected - c.events; c.expected > 0 && remaining > 0 && rate > 0 {
+			eta := time.Duration(float64(remaining) / rate * float64(time.Second))
+			parts = append(parts, "ETA "+eta.Round(time.Second).String())
+		}
+	}
+	return line + out.Muted(strings.Join(parts, "  "))
+}
+
+// accountProgress is one account's view of a progress display shared by
+// accounts syncing at once. Its calendars are named with the account, so
+// their bars and results can be told apart; with no account they are
+// shown as they are.
+type accountProgress struct {
+	*CLIProgress
+	account string
+}
+
+func (p accountProgress) name(calendarName string) string {
+	if p.account == "" {
+		return calendarName
+	}
+	return p.account + ": " + calendarName
+}
+
+func (p accountProgress) OnCalendarStart(calendarName string, expectedEvents int) {
+	p.CLIProgress.OnCalendarStart(p.name(calendarName), expectedEvents)
+}
+
+func (p accountProgress) OnPage(calendarName string, events int) {
+	p.CLIProgress.OnPage(p.name(calendarName), events)
+}
+
+func (p accountProgress) OnCalendarDone(calendarName string, added, updated, deleted int) {
+	p.CLIProgress.OnCalendarDone(p.name(calendarName), added, updated, deleted)
+}
+
+// finish clears the bars of the account's calendars that didn't finish.
+func (p accountProgress) finish() {
+	p.CLIProgress.finish(p.name(""))
+}
+
+// logWriter writes logs to stderr, around the live progress display if
+// one is drawn so log lines don't tear its bars.
+type logWriter struct{}
+
+func (logWriter) Write(b []byte) (int, error) {
+	p := drawing.Load()
+	if p == nil {
+		return os.Stderr.Write(b)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	n, err := os.Stderr.Write(b)
+	p.redraw()
+	return n, err
+}
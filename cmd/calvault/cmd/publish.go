@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/salman1993/calvault/internal/publish"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	publishOut   string
+	publishSince string
+	publishTitle string
+)
+
+var publishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "Generate a shareable static page of meeting statistics",
+	Long: `Generate a static HTML page of meeting load over the years, for
+sharing personal-analytics write-ups: meetings, hours and share of working
+hours per month and year, and when in the week and day meetings happen.
+
+The page holds aggregates only - no event titles, attendees, calendars or
+times of individual meetings - and needs no server or scripts; the charts
+are inline SVG. It is written to index.html in --out.
+
+Meetings count as in the analytics tables: timed events that weren't
+cancelled or declined; a meeting held in several of your accounts counts
+once. Working hours come from [work_hours] in config.toml. Defaults to
+everything since the first meeting.
+
+Examples:
+  calvault publish --out site/
+  calvault publish --out site/ --since 2020-01-01 --title "Five years of meetings"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if publishOut == "" {
+			return fmt.Errorf("--out is required")
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		today := workDay(time.Now())
+		var since time.Time
+		if publishSince != "" {
+			if since, err = parseWorkDateFlag(publishSince); err != nil {
+				return err
+			}
+		} else {
+			first, _, err := s.MeetingRange()
+			if err != nil {
+				return err
+			}
+			if first.IsZero() {
+				return fmt.Errorf("no meetings to publish")
+			}
+			first = workDay(first)
+			since = time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, first.Location())
+		}
+
+		stats, err := publish.Build(s, publishTitle, since, today.AddDate(0, 0, 1), workingHours())
+		if err != nil {
+			return err
+		}
+		if err := publish.Write(publishOut, stats, out.Locale()); err != nil {
+			return err
+		}
+
+		out.Printf("%s %s meetings from %s to %s to %s\n", out.Good("Published"),
+			out.Number(int64(stats.Total.Meetings)), out.Date(stats.Since), out.Date(stats.Until),
+			filepath.Join(publishOut, "index.html"))
+		return nil
+	},
+}
+
+func init() {
+	publishCmd.Flags().StringVar(&publishOut, "out", "", "Directory to write the page to (created if needed)")
+	publishCmd.Flags().StringVar(&publishSince, "since", "", "Only include meetings starting on or after this date (YYYY-MM-DD, default the first meeting)")
+	publishCmd.Flags().StringVar(&publishTitle, "title", "Meeting load", "Page title")
+	rootCmd.AddCommand(publishCmd)
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/config"
+	"github.com/salman1993/calvault/internal/daemon"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+// purgeBatchSize is how many events each purge transaction deletes.
+const purgeBatchSize = 1000
+
+var (
+	purgeOlderThan string
+	purgeCalendars []string
+	purgeDryRun    bool
+	purgeForce     bool
+)
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge --older-than <age>",
+	Short: "Delete events that ended longer ago than a given age",
+	Long: `Delete archived events that ended longer ago than --older-than, with their
+attendees and the other rows kept for them. Ages are a number of years
+(y), months (m), weeks (w) or days (d), such as 10y or 18m.
+
+A recurring series is only deleted once every occurrence expanded into
+event_instances ended before the cutoff; series never expanded are kept.
+
+--calendar (repeatable) limits the purge to calendars by ID or name. Events
+are deleted 1000 at a time, each batch in its own transaction, so a sync
+waits at most one batch. The deleted rows stay in the trash for [storage]
+trash_days (default 30), as one operation 'calvault undo' can restore;
+with trash_days = 0 they are deleted outright. You are asked to confirm
+unless --force is given; without a terminal, --force is required.
+--dry-run only counts the events.
+
+With [storage] retention set (e.g. "10y"), the daemon purges events past
+that age daily, into the trash the same way. Refused while the archive is
+under [storage] legal_hold.
+
+Examples:
+  calvault purge --older-than 10y --dry-run
+  calvault purge --older-than 18m --calendar Holidays --force`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if purgeOlderThan == "" {
+			return fmt.Errorf("--older-than is required")
+		}
+		age, err := config.ParseAge(purgeOlderThan)
+		if err != nil {
+			return fmt.Errorf("--older-than: %w", err)
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+		if err := applyStorageSettings(s); err != nil {
+			return err
+		}
+
+		p := store.EventPurge{
+			Before:    age.Before(time.Now()),
+			Calendars: purgeCalendars,
+			Keep:      cfg.Storage.TrashPeriod(),
+			Command:   "purge",
+		}
+		if purgeDryRun || !purgeForce {
+			n, err := s.CountPurgeEvents(p)
+			if err != nil {
+				return err
+			}
+			if purgeDryRun {
+				out.Printf("Would delete %s events that ended before %s.\n", out.Number(n), out.Date(p.Before))
+				return nil
+			}
+			if n == 0 {
+				out.Printf("No events ended before %s.\n", out.Date(p.Before))
+				return nil
+			}
+			ok, err := confirmPurge(n, p.Before, p.Keep)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				out.Println("Aborted.")
+				return nil
+			}
+		}
+
+		lock, err := acquireSyncLock()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = lock.Release() }()
+
+		n, trashID, err := purgeEvents(s, p)
+		if err != nil {
+			return err
+		}
+		out.Printf("%s %s events that ended before %s\n", out.Good("Deleted"), out.Number(n), out.Date(p.Before))
+		if trashID > 0 {
+			out.Println()
+			out.Printf("Kept in the trash until %s. Restore with: calvault undo %d\n",
+				out.Date(time.Now().Add(p.Keep)), trashID)
+		}
+		return nil
+	},
+}
+
+// purgeEvents deletes the events p picks, logging progress after each
+// batch, then refreshes the derived tables if any were deleted. It returns
+// how many were deleted and the trash operation keeping them, if any.
+func purgeEvents(s *store.Store, p store.EventPurge) (int64, int64, error) {
+	n, trashID, err := s.PurgeEvents(p, purgeBatchSize, func(deleted int64) {
+		logger.Debug("purged events", "deleted", deleted)
+	})
+	if n > 0 {
+		logger.Info("purged events", "deleted", n, "before", p.Before.Format(time.DateOnly), "trash_operation", trashID)
+		refreshDerivedTables(s)
+	}
+	if err != nil {
+		return n, trashID, fmt.Errorf("purge events: %w", err)
+	}
+	return n, trashID, nil
+}
+
+// retentionJob purges events past [storage] retention daily into the
+// trash, kept for keep, under the sync lock, and empties expired trash. A
+// run that finds the lock held is skipped.
+func retentionJob(s *store.Store, age config.Age, keep time.Duration) daemon.Job {
+	schedule, _ := daemon.ParseSchedule("@daily")
+	return daemon.Job{
+		Name:     "enforce-retention",
+		Schedule: schedule,
+		Run: func(ctx context.Context) error {
+			lock, err := acquireSyncLock()
+			if errors.Is(err, daemon.ErrLocked) {
+				logger.Info("skipping retention purge", "reason", err)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			defer func() { _ = lock.Release() }()
+
+			if _, err := s.PurgeExpiredTrash(); err != nil {
+				logger.Warn("failed to purge expired trash", "error", err)
+			}
+			_, _, err = purgeEvents(s, store.EventPurge{Before: age.Before(time.Now()), Keep: keep, Command: "retention"})
+			return err
+		},
+	}
+}
+
+// confirmPurge asks before deleting n events, kept in the trash for keep.
+// It refuses rather than guessing when stdin isn't a terminal.
+func confirmPurge(n int64, before time.Time, keep time.Duration) (bool, error) {
+	stat, _ := os.Stdin.Stat()
+	if stat == nil || (stat.Mode()&os.ModeCharDevice) == 0 {
+		return false, fmt.Errorf("refusing to purge events without confirmation; use --force")
+	}
+	undo := "This cannot be undone."
+	if keep > 0 {
+		undo = fmt.Sprintf("They can be restored for %d days with 'calvault undo'.", int(keep.Hours()/24))
+	}
+	fmt.Printf("Delete %s events that ended before %s? %s [y/N] ",
+		out.Number(n), out.Date(before), undo)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+func init() {
+	purgeCmd.Flags().StringVar(&purgeOlderThan, "older-than", "", "Delete events that ended longer ago than this, such as 10y, 18m, 6w or 90d")
+	purgeCmd.Flags().StringArrayVar(&purgeCalendars, "calendar", nil, "Only purge this calendar, by ID or name (repeatable)")
+	purgeCmd.Flags().BoolVar(&purgeDryRun, "dry-run", false, "Count the events without deleting them")
+	purgeCmd.Flags().BoolVarP(&purgeForce, "force", "f", false, "Delete without asking for confirmation")
+	rootCmd.AddCommand(purgeCmd)
+}
@@ -1,82 +1,509 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/salman1993/calvault/internal/query"
+	"github.com/salman1993/calvault/internal/render"
 	"github.com/spf13/cobra"
 )
 
-var queryFile string
+var (
+	queryFile    string
+	queryNamed   string
+	queryParams  []string
+	queryList    bool
+	queryCaller  string
+	queryAccount string
+	queryOwner   string
+	queryFormat  string
+	queryLimit   int
+	queryOffset  int
+	queryNoCache bool
+	queryBatch   string
+	queryAsOf    string
+)
 
 var queryCmd = &cobra.Command{
 	Use:   "query [sql]",
 	Short: "Execute a read-only SQL query",
 	Long: `Execute a SQL query against the calendar database.
 
-Only SELECT statements are allowed. Results are returned as JSON for
-easy parsing by LLMs and scripts.
+Only SELECT statements are allowed, including ones that start with a WITH
+clause (CTEs) and use window functions. Results are returned as JSON for
+easy parsing by LLMs and scripts; --format table renders them for reading
+at a terminal, and csv, tsv and markdown suit pipes and documents.
 
 SQL can be provided as an argument, from a file, or via stdin:
   calvault query "SELECT COUNT(*) FROM events"
   calvault query --file query.sql
   echo "SELECT * FROM events" | calvault query
-  calvault query < query.sql`,
+  calvault query < query.sql
+  calvault query --format table "SELECT summary, start_time FROM events LIMIT 10"
+
+Results stop at query.max_rows rows (default 10000; 0 = no cap) and are
+marked "truncated" when more rows were left. --limit and --offset page
+through a large result:
+  calvault query --limit 100 --offset 200 "SELECT * FROM events ORDER BY start_time"
+
+Placeholders such as :email are bound as SQL parameters from --param,
+so values never need quoting into the SQL. Add a type to the name (string,
+int, float, bool, date) to convert the value; strings are the default:
+  calvault query --param email=boss@corp.com --param since:date=2024-01-01 \
+    "SELECT e.summary FROM events e JOIN attendees a ON a.event_id = e.id
+     WHERE a.email = :email AND e.start_time >= :since"
+
+Named queries registered under [query.named] in config.toml can be run
+with typed parameters. Setting query.allowlist_only = true restricts the
+command to named queries only:
+  calvault query --list-named
+  calvault query --named visits --param term=dermatologist --param since=2024-01-01
+
+Use --account (or query.account in config) to scope the session to one
+account: every table is replaced by a view filtered to that account's rows,
+and explicit main.<table> references are rejected:
+  calvault query --account work@company.com "SELECT COUNT(*) FROM events"
+
+In a team vault ([team] in config), other members' private calendars and
+private events are always hidden, along with the analytics tables for
+their accounts. Use --owner to scope the session to one member's accounts:
+  calvault query --owner alice "SELECT COUNT(*) FROM events"
+
+--batch runs several queries in one invocation, up to four at a time,
+from a JSON file ("-" for stdin) listing them with an optional id, either
+"sql" or "named", and "params". The results come back together, in order,
+as {"results": [...]}; a failing query reports its "error" without
+stopping the others:
+  calvault query --batch - <<'EOF'
+  [{"id": "events", "sql": "SELECT COUNT(*) FROM events"},
+   {"id": "since", "sql": "SELECT COUNT(*) FROM events WHERE start_time >= :d", "params": {"d:date": "2024-01-01"}},
+   {"id": "visits", "named": "visits", "params": {"term": "dentist", "since": "2024-01-01"}}]
+  EOF
+
+--as-of runs the query against events as they were at a past date
+(YYYY-MM-DD, the start of that day) or time (RFC 3339): events created
+later are left out and each event has the details it had then, taken from
+event_history. Only changes stored while [storage] event_history was on
+can be undone, events deleted outright are gone unless legal_hold kept
+them, and other tables - attendees, instances, analytics - are as they
+are now:
+  calvault query --as-of 2023-01-01 --format table \
+    "SELECT summary, start_time FROM events WHERE summary LIKE '%1:1%'"
+
+Results are cached for query.cache_minutes (default 10), so repeating a
+query returns instantly until the archive changes: any sync, import or
+other write invalidates them. Cached results are marked "cached"; use
+--no-cache to always run the query.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var sql string
+		if !slices.Contains(queryFormats, queryFormat) {
+			return fmt.Errorf("unsupported format %q (expected %s)", queryFormat, strings.Join(queryFormats, ", "))
+		}
+		if queryLimit < 0 || queryOffset < 0 {
+			return fmt.Errorf("--limit and --offset must not be negative")
+		}
+		registry, err := buildNamedQueryRegistry()
+		if err != nil {
+			return err
+		}
 
-		switch {
-		case queryFile != "":
-			// Read from file
-			data, err := os.ReadFile(queryFile)
+		if queryList {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(registry.List())
+		}
+
+		if queryBatch != "" {
+			if len(args) > 0 || queryFile != "" || queryNamed != "" || len(queryParams) > 0 {
+				return fmt.Errorf("--batch cannot be combined with SQL input, --named or --param")
+			}
+			if queryFormat != "json" {
+				return fmt.Errorf("--batch only supports --format json")
+			}
+			return runQueryBatch(cmd.Context(), registry, queryBatch)
+		}
+
+		if queryNamed != "" {
+			if len(args) > 0 || queryFile != "" {
+				return fmt.Errorf("--named cannot be combined with SQL input")
+			}
+			params, err := parseParams(queryParams)
 			if err != nil {
-				return fmt.Errorf("read file: %w", err)
+				return err
 			}
-			sql = string(data)
-		case len(args) == 1:
-			sql = args[0]
-		default:
-			// Read from stdin
-			stat, _ := os.Stdin.Stat()
-			if (stat.Mode() & os.ModeCharDevice) != 0 {
-				return fmt.Errorf("no query provided - pass as argument, --file, or pipe to stdin")
+
+			executor, closeExecutor, err := openExecutor(registry)
+			if err != nil {
+				return err
 			}
-			data, err := io.ReadAll(os.Stdin)
+			defer closeExecutor()
+
+			result, err := executor.ExecuteNamed(cmd.Context(), queryNamed, params)
 			if err != nil {
-				return fmt.Errorf("read stdin: %w", err)
+				return err
 			}
-			sql = string(data)
+			return writeQueryResult(result)
 		}
-
-		sql = strings.TrimSpace(sql)
-		if sql == "" {
-			return fmt.Errorf("empty query")
+		params, err := parseParams(queryParams)
+		if err != nil {
+			return err
+		}
+		sql, err := readSQLInput(args, queryFile)
+		if err != nil {
+			return err
 		}
 
-		executor, err := query.NewExecutor(cfg.DatabasePath())
+		executor, closeExecutor, err := openExecutor(registry)
 		if err != nil {
-			return fmt.Errorf("open database: %w", err)
+			return err
 		}
-		defer func() { _ = executor.Close() }()
+		defer closeExecutor()
 
-		result, err := executor.Execute(cmd.Context(), sql)
+		result, err := executor.ExecuteParams(cmd.Context(), sql, params)
 		if err != nil {
 			return err
 		}
 
-		// Output as JSON for LLM consumption
+		return writeQueryResult(result)
+	},
+}
+
+// runQueryBatch runs the queries listed in a batch file ("-" for stdin)
+// and prints their results as one JSON document.
+func runQueryBatch(ctx context.Context, registry *query.Registry, path string) error {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("read batch: %w", err)
+	}
+
+	// Parameter values may be written as JSON numbers or booleans; they
+	// are bound from their text like --param values.
+	var entries []struct {
+		ID     string                 `json:"id"`
+		SQL    string                 `json:"sql"`
+		Named  string                 `json:"named"`
+		Params map[string]interface{} `json:"params"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&entries); err != nil {
+		return fmt.Errorf("parse batch: %w", err)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("batch lists no queries")
+	}
+	queries := make([]query.BatchQuery, len(entries))
+	for i, e := range entries {
+		queries[i] = query.BatchQuery{ID: e.ID, SQL: strings.TrimSpace(e.SQL), Named: e.Named}
+		if len(e.Params) > 0 {
+			queries[i].Params = make(map[string]string, len(e.Params))
+			for k, v := range e.Params {
+				queries[i].Params[k] = fmt.Sprint(v)
+			}
+		}
+	}
+
+	executor, closeExecutor, err := openExecutor(registry)
+	if err != nil {
+		return err
+	}
+	defer closeExecutor()
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Results []*query.BatchResult `json:"results"`
+	}{executor.ExecuteBatch(ctx, queries)})
+}
+
+// readSQLInput reads SQL from the first argument, a file, or stdin.
+func readSQLInput(args []string, file string) (string, error) {
+	var sql string
+
+	switch {
+	case file != "":
+		// Read from file
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("read file: %w", err)
+		}
+		sql = string(data)
+	case len(args) == 1:
+		sql = args[0]
+	default:
+		// Read from stdin
+		stat, _ := os.Stdin.Stat()
+		if (stat.Mode() & os.ModeCharDevice) != 0 {
+			return "", fmt.Errorf("no query provided - pass as argument, --file, or pipe to stdin")
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("read stdin: %w", err)
+		}
+		sql = string(data)
+	}
+
+	sql = strings.TrimSpace(sql)
+	if sql == "" {
+		return "", fmt.Errorf("empty query")
+	}
+	return sql, nil
+}
+
+// parseAsOf parses --as-of: a date, meaning the start of that day in local
+// time, or an RFC 3339 time.
+func parseAsOf(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --as-of %q (expected YYYY-MM-DD or an RFC 3339 time)", value)
+	}
+	return t, nil
+}
+
+// openExecutor opens a read-only executor with named queries and the
+// audit log attached. The returned func closes both connections.
+func openExecutor(registry *query.Registry) (*query.Executor, func(), error) {
+	// Don't let the audit store create a missing database
+	if _, err := os.Stat(cfg.DatabasePath()); err != nil {
+		return nil, nil, fmt.Errorf("open database: %w", err)
+	}
+
+	var asOf time.Time
+	if queryAsOf != "" {
+		var err error
+		if asOf, err = parseAsOf(queryAsOf); err != nil {
+			return nil, nil, err
+		}
+		if !cfg.Storage.EventHistory {
+			logger.Warn("[storage] event_history is off; --as-of can only undo changes stored while it was on")
+		}
+	}
+
+	auditStore, err := openAuditStore()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := []query.ExecutorOption{
+		query.WithNamedQueries(registry),
+		query.WithAuditLog(&storeAuditLogger{store: auditStore}, queryCaller),
+		query.WithMaxRows(cfg.Query.MaxRows),
+		query.WithPage(queryLimit, queryOffset),
+	}
+	if cfg.Query.AllowlistOnly {
+		opts = append(opts, query.WithAllowlistOnly())
+	}
+	if cfg.Query.CacheMinutes > 0 && !queryNoCache {
+		opts = append(opts, query.WithCache(cfg.QueryCacheDir(), time.Duration(cfg.Query.CacheMinutes)*time.Minute))
+	}
+	account := queryAccount
+	if account == "" {
+		account = cfg.Query.Account
+	}
+	if account != "" {
+		opts = append(opts, query.WithSourceScope(account))
+	}
+	if queryOwner != "" {
+		opts = append(opts, query.WithOwnerScope(queryOwner))
+	}
+	if cfg.Team.Enabled() {
+		opts = append(opts, query.WithTeamPrivacy(cfg.Team.Member))
+	}
+	if !asOf.IsZero() {
+		opts = append(opts, query.WithAsOf(asOf))
+	}
+
+	executor, err := query.NewExecutor(cfg.DatabasePath(), opts...)
+	if err != nil {
+		_ = auditStore.Close()
+		return nil, nil, fmt.Errorf("open executor: %w", err)
+	}
+
+	return executor, func() {
+		_ = executor.Close()
+		_ = auditStore.Close()
+	}, nil
+}
+
+// Formats accepted by query --format.
+var queryFormats = []string{"json", "table", "csv", "tsv", "markdown"}
+
+// writeQueryResult prints a query result in the --format format: indented
+// JSON for LLM consumption by default. Other formats note truncated results
+// on stderr, keeping stdout clean for pipes.
+func writeQueryResult(result *query.QueryResult) error {
+	if result.Truncated && queryFormat != "json" {
+		defer fmt.Fprintf(os.Stderr, "Showing %d rows; more are available (next page: --offset %d).\n",
+			result.RowCount, queryOffset+result.RowCount)
+	}
+	switch queryFormat {
+	case "table":
+		writeQueryTable(result)
+		return nil
+	case "csv", "tsv":
+		w := csv.NewWriter(os.Stdout)
+		if queryFormat == "tsv" {
+			w.Comma = '\t'
+		}
+		_ = w.Write(result.Columns)
+		for _, row := range result.Rows {
+			record := make([]string, len(row))
+			for i, v := range row {
+				record[i] = formatQueryValue(v)
+			}
+			_ = w.Write(record)
+		}
+		w.Flush()
+		return w.Error()
+	case "markdown":
+		writeQueryMarkdown(result)
+		return nil
+	default:
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "  ")
 		return enc.Encode(result)
-	},
+	}
+}
+
+// writeQueryTable renders a result as an aligned table, right-aligning
+// numeric columns and truncating long values to one line.
+func writeQueryTable(result *query.QueryResult) {
+	t := render.NewTable(result.Columns...)
+	for i := range result.Columns {
+		numbers, others := 0, 0
+		for _, row := range result.Rows {
+			switch row[i].(type) {
+			case int64, float64:
+				numbers++
+			case nil:
+			default:
+				others++
+			}
+		}
+		if numbers > 0 && others == 0 {
+			t.AlignRight(i)
+		}
+	}
+	for _, row := range result.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			if v == nil {
+				cells[i] = out.Muted("NULL")
+			} else {
+				cells[i] = oneLine(formatQueryValue(v), 80)
+			}
+		}
+		t.Row(cells...)
+	}
+	out.Table(t)
+	out.Println(out.Muted(fmt.Sprintf("(%d rows)", result.RowCount)))
+}
+
+// writeQueryMarkdown renders a result as a GitHub-flavored Markdown table.
+func writeQueryMarkdown(result *query.QueryResult) {
+	cell := func(s string) string {
+		s = strings.ReplaceAll(s, "|", `\|`)
+		return strings.Join(strings.Fields(s), " ")
+	}
+	header := make([]string, len(result.Columns))
+	rule := make([]string, len(result.Columns))
+	for i, c := range result.Columns {
+		header[i] = cell(c)
+		rule[i] = "---"
+	}
+	fmt.Printf("| %s |\n", strings.Join(header, " | "))
+	fmt.Printf("| %s |\n", strings.Join(rule, " | "))
+	for _, row := range result.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = cell(formatQueryValue(v))
+		}
+		fmt.Printf("| %s |\n", strings.Join(cells, " | "))
+	}
+}
+
+// formatQueryValue formats a result value as text; NULL is empty.
+func formatQueryValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// buildNamedQueryRegistry registers the named queries from config.
+func buildNamedQueryRegistry() (*query.Registry, error) {
+	registry := query.NewRegistry()
+	for name, nq := range cfg.Query.Named {
+		q := &query.NamedQuery{
+			Name:        name,
+			Description: nq.Description,
+			SQL:         nq.SQL,
+		}
+		for param, typ := range nq.Params {
+			q.Params = append(q.Params, query.Param{Name: param, Type: query.ParamType(typ)})
+		}
+		sort.Slice(q.Params, func(i, j int) bool { return q.Params[i].Name < q.Params[j].Name })
+
+		if err := registry.Register(q); err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+	}
+	return registry, nil
+}
+
+// parseParams parses repeated key=value flags.
+func parseParams(raw []string) (map[string]string, error) {
+	params := make(map[string]string, len(raw))
+	for _, kv := range raw {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid --param %q (expected name=value)", kv)
+		}
+		params[k] = v
+	}
+	return params, nil
 }
 
 func init() {
 	queryCmd.Flags().StringVarP(&queryFile, "file", "f", "", "Read SQL from file")
+	queryCmd.Flags().StringVar(&queryNamed, "named", "", "Run a named query from config")
+	queryCmd.Flags().StringVar(&queryBatch, "batch", "", `Run the queries listed in a JSON file ("-" for stdin) and print their results together`)
+	queryCmd.Flags().StringArrayVar(&queryParams, "param", nil, "Query parameter as name=value or name:type=value (repeatable)")
+	queryCmd.Flags().BoolVar(&queryList, "list-named", false, "List registered named queries as JSON")
+	queryCmd.Flags().StringVar(&queryAccount, "account", "", "Only expose data from this account (default: query.account in config)")
+	queryCmd.Flags().StringVar(&queryFormat, "format", "json", "Output format: json, table, csv, tsv or markdown")
+	queryCmd.Flags().IntVar(&queryLimit, "limit", 0, "Return at most this many rows (default: up to query.max_rows)")
+	queryCmd.Flags().IntVar(&queryOffset, "offset", 0, "Skip this many rows first")
+	queryCmd.Flags().StringVar(&queryOwner, "owner", "", "Only expose data from accounts owned by this team member")
+	queryCmd.Flags().StringVar(&queryAsOf, "as-of", "", "Query events as they were at this date (YYYY-MM-DD) or RFC 3339 time")
+	queryCmd.Flags().BoolVar(&queryNoCache, "no-cache", false, "Run the query even if a cached result is available")
+	queryCmd.Flags().StringVar(&queryCaller, "caller", defaultCaller(), "Caller name recorded in the audit log (env: CALVAULT_CALLER)")
 	rootCmd.AddCommand(queryCmd)
 }
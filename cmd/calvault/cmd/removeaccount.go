@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/config"
+	"github.com/salman1993/calvault/internal/oauth"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	removeForce bool
+	removePurge bool
+)
+
+var removeAccountCmd = &cobra.Command{
+	Use:   "remove-account <email>",
+	Short: "Remove an account and all of its archived data",
+	Long: `Remove an account from the archive: its calendars, events, attendees,
+sync history and analytics, and its saved OAuth token.
+
+The database rows are deleted in one transaction together with the token,
+so a failure leaves everything in place. You are asked to confirm unless
+--force is given; without a terminal, --force is required.
+
+The removed rows stay in the trash for [storage] trash_days (default 30),
+and 'calvault undo <id>' puts them back; the token is not kept, so add the
+account again to resume syncing. --purge deletes them right away.
+
+Also removes accounts created by 'import ics' (which have no token).
+
+Refused while the archive is under [storage] legal_hold.
+
+Examples:
+  calvault remove-account old@gmail.com
+  calvault remove-account old@gmail.com --force
+  calvault remove-account old@gmail.com --purge`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		email := args[0]
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+		if err := applyStorageSettings(s); err != nil {
+			return err
+		}
+		if held, err := s.LegalHold(); err != nil || held {
+			if err == nil {
+				err = fmt.Errorf("cannot remove %s: %w", email, store.ErrLegalHold)
+			}
+			return err
+		}
+
+		src, err := s.GetSourceByIdentifier(email)
+		if err != nil {
+			return fmt.Errorf("get source: %w", err)
+		}
+		if src == nil {
+			return fmt.Errorf("account %q not found", email)
+		}
+
+		keep := cfg.Storage.TrashPeriod()
+		if removePurge {
+			keep = 0
+		}
+
+		if !removeForce {
+			events, err := s.CountEvents(store.EventFilter{SourceID: src.ID})
+			if err != nil {
+				return err
+			}
+			ok, err := confirmRemoval(src, events, keep)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				out.Println("Aborted.")
+				return nil
+			}
+		}
+
+		// Don't pull the account out from under a running sync
+		lock, err := acquireSyncLock()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = lock.Release() }()
+
+		if _, err := s.PurgeExpiredTrash(); err != nil {
+			logger.Warn("failed to purge expired trash", "error", err)
+		}
+
+		var removed *store.SourceRemoval
+		var trashID int64
+		err = s.InTx(func(tx *store.Tx) error {
+			var err error
+			if keep > 0 {
+				if trashID, err = tx.TrashSource(src.ID, "remove-account", email, keep); err != nil {
+					return err
+				}
+			}
+			if removed, err = tx.DeleteSource(src.ID); err != nil {
+				return err
+			}
+			// Last, so a failure rolls back the database deletes
+			if dir := tokensDirFor(src.SourceType); dir != "" {
+				if err := oauth.DeleteToken(dir, email); err != nil {
+					return fmt.Errorf("delete token: %w", err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("remove account: %w", err)
+		}
+
+		logger.Info("removed account", "account", email, "events", removed.Events)
+		out.Printf("%s %s\n", out.Good("Removed"), out.Accent(email))
+		out.KeyValues(
+			"Calendars", out.Number(removed.Calendars),
+			"Events", out.Number(removed.Events),
+			"Attendees", out.Number(removed.Attendees),
+			"Sync runs", out.Number(removed.SyncRuns),
+			"Watch channels", out.Number(removed.WatchChannels),
+		)
+		if trashID > 0 {
+			out.Println()
+			out.Printf("Kept in the trash until %s. Restore with: calvault undo %d\n",
+				out.Date(time.Now().Add(keep)), trashID)
+		}
+		return nil
+	},
+}
+
+// tokensDirFor returns the OAuth token directory for a source type, or ""
+// for sources without tokens.
+func tokensDirFor(sourceType string) string {
+	return tokensDirOf(cfg, sourceType)
+}
+
+// tokensDirOf is tokensDirFor for a given config.
+func tokensDirOf(c *config.Config, sourceType string) string {
+	switch sourceType {
+	case store.SourceTypeGoogle:
+		return c.TokensDir()
+	case store.SourceTypeMicrosoft:
+		return c.MicrosoftTokensDir()
+	default:
+		return ""
+	}
+}
+
+// confirmRemoval asks before deleting an account's data. It refuses rather
+// than guessing when stdin isn't a terminal.
+func confirmRemoval(src *store.Source, events int64, keep time.Duration) (bool, error) {
+	stat, _ := os.Stdin.Stat()
+	if stat == nil || (stat.Mode()&os.ModeCharDevice) == 0 {
+		return false, fmt.Errorf("refusing to remove %s without confirmation; use --force", src.Identifier)
+	}
+	undo := "This cannot be undone."
+	if keep > 0 {
+		undo = fmt.Sprintf("They can be restored for %d days with 'calvault undo'.", int(keep.Hours()/24))
+	}
+	fmt.Printf("Remove %s and its %s archived events? %s [y/N] ",
+		src.Identifier, out.Number(events), undo)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+func init() {
+	removeAccountCmd.Flags().BoolVarP(&removeForce, "force", "f", false, "Remove without asking for confirmation")
+	removeAccountCmd.Flags().BoolVar(&removePurge, "purge", false, "Delete permanently instead of keeping the rows in the trash")
+	rootCmd.AddCommand(removeAccountCmd)
+}
@@ -0,0 +1,652 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/salman1993/calvault/internal/xlsx"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportSince   string
+	reportLimit   int
+	reportGroupBy string
+	reportFormat  string
+	reportOutput  string
+	reportMinHeld int
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Summarize who and what the calendar archive is about",
+	Long: `Print summary reports over the calendar archive, or with --format xlsx
+write them to an Excel workbook for people who live in spreadsheets.
+
+The workbook has three sheets: Events (every event starting in the range,
+with calendar, account, location, status and organizer), Monthly (meetings,
+hours, average duration and share of working hours per month, as in
+'report load --group-by month') and People (everyone you met with, as in
+'report people'). Defaults to the last twelve months; the workbook is
+written to calvault-report.xlsx unless --output is given ("-" for stdout).
+
+See also 'calvault analyze' for meeting durations, team load and off-hours
+analyses.
+
+Examples:
+  calvault report --format xlsx
+  calvault report --format xlsx --since 2024-01-01 -o 2024.xlsx`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch reportFormat {
+		case "":
+			return cmd.Help()
+		case "xlsx":
+		default:
+			return fmt.Errorf("unsupported format %q (expected xlsx)", reportFormat)
+		}
+		today := workDay(time.Now())
+		until := today.AddDate(0, 0, 1)
+		since := time.Date(today.Year(), today.Month()-11, 1, 0, 0, 0, 0, today.Location())
+		if reportSince != "" {
+			var err error
+			if since, err = parseWorkDateFlag(reportSince); err != nil {
+				return err
+			}
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		wb, err := reportWorkbook(s, since, until)
+		if err != nil {
+			return err
+		}
+
+		if reportOutput == "-" {
+			return wb.Write(os.Stdout)
+		}
+		f, err := os.Create(reportOutput)
+		if err != nil {
+			return fmt.Errorf("create output file: %w", err)
+		}
+		if err := wb.Write(f); err != nil {
+			_ = f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		out.Printf("%s %s events, %s months and %s people to %s\n", out.Good("Wrote"),
+			out.Number(int64(len(wb.Sheets[0].Rows))), out.Number(int64(len(wb.Sheets[1].Rows))),
+			out.Number(int64(len(wb.Sheets[2].Rows))), reportOutput)
+		return nil
+	},
+}
+
+// reportWorkbook builds the Events, Monthly and People sheets for events
+// starting in [since, until).
+func reportWorkbook(s *store.Store, since, until time.Time) (*xlsx.Workbook, error) {
+	wb := &xlsx.Workbook{}
+
+	events, err := s.ListEvents(store.EventFilter{Since: since, Until: until})
+	if err != nil {
+		return nil, err
+	}
+	accounts, calendars, err := sourceNames(s)
+	if err != nil {
+		return nil, err
+	}
+	sheet := wb.AddSheet("Events", "Start", "End", "All day", "Title", "Calendar", "Account", "Location", "Status", "Organizer")
+	for _, e := range events {
+		sheet.Row(eventCell(e.StartTime, e.AllDay), eventCell(e.EndTime, e.AllDay), e.AllDay, e.Summary,
+			calendars[e.CalendarID], accounts[e.SourceID], e.Location, e.Status, e.OrganizerEmail)
+	}
+
+	month := func(day time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+	}
+	periods, err := s.GetLoadReport(since, until, workingHours(), month)
+	if err != nil {
+		return nil, err
+	}
+	sheet = wb.AddSheet("Monthly", "Month", "Meetings", "Hours", "Average minutes", "Working hours in meetings")
+	for _, p := range periods {
+		var average, share interface{}
+		if p.Meetings > 0 {
+			average = p.AverageMinutes()
+		}
+		if p.WorkingHours > 0 {
+			share = xlsx.Percent(p.BusyShare())
+		}
+		sheet.Row(p.Start.Format("2006-01"), p.Meetings, p.Hours, average, share)
+	}
+
+	report, err := s.GetPeopleReport(since, until)
+	if err != nil {
+		return nil, err
+	}
+	sheet = wb.AddSheet("People", "Name", "Email", "Meetings", "Hours", "First met", "Last met")
+	for _, p := range report.People {
+		sheet.Row(p.Name, p.Email, p.Meetings, p.Hours,
+			xlsx.Date(p.FirstSeen.In(since.Location())), xlsx.Date(p.LastSeen.In(since.Location())))
+	}
+	return wb, nil
+}
+
+// eventCell is an event time as a workbook cell: a date for all-day
+// events, else the local time.
+func eventCell(t sql.NullTime, allDay bool) interface{} {
+	switch {
+	case !t.Valid:
+		return nil
+	case allDay:
+		return xlsx.Date(t.Time.UTC())
+	default:
+		return t.Time.Local()
+	}
+}
+
+var reportPeopleCmd = &cobra.Command{
+	Use:   "people",
+	Short: "Show the people you meet with most",
+	Long: `Rank the people you meet with most: meetings and hours shared with each
+attendee, when you last met, and a trend of meetings per month (per quarter
+for ranges over two years), oldest to newest.
+
+Meetings count as in the analytics tables: timed events that weren't
+cancelled or declined. Your own accounts and room or resource calendars are
+left out, and a meeting held in several of your accounts counts once.
+Defaults to the last twelve months.
+
+Examples:
+  calvault report people
+  calvault report people --since 2023-01-01 --limit 50`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if reportLimit < 1 {
+			return fmt.Errorf("--limit must be at least 1")
+		}
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		until := today.AddDate(0, 0, 1)
+		since := time.Date(today.Year()-1, today.Month()+1, 1, 0, 0, 0, 0, time.Local)
+		if reportSince != "" {
+			var err error
+			if since, err = parseDateFlag(reportSince); err != nil {
+				return err
+			}
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		report, err := s.GetPeopleReport(since, until)
+		if err != nil {
+			return err
+		}
+
+		out.Title("Top Collaborators")
+		out.Println(out.Muted(fmt.Sprintf("%s to %s", out.Date(since), out.Date(today))))
+		out.Println()
+		if len(report.People) == 0 {
+			out.Println("No meetings with other people in this period.")
+			return nil
+		}
+
+		trend, step := "Monthly", 1
+		if len(report.Months) > 24 {
+			trend, step = "Quarterly", 3
+		}
+		t := render.NewTable("Person", "Meetings", "Hours", "Last met", trend).AlignRight(1, 2)
+		for _, p := range report.People[:min(len(report.People), reportLimit)] {
+			t.Row(personLabel(p), out.Number(int64(p.Meetings)), fmt.Sprintf("%.1f", p.Hours),
+				out.Date(p.LastSeen.Local()), out.Accent(render.Sparkline(foldPeriods(p.Months, step))))
+		}
+		out.Table(t)
+		if len(report.People) > reportLimit {
+			out.Println(out.Muted(fmt.Sprintf("  ... and %d more (use --limit to see them)", len(report.People)-reportLimit)))
+		}
+		return nil
+	},
+}
+
+var reportLoadCmd = &cobra.Command{
+	Use:   "load",
+	Short: "Show meeting load per week or month",
+	Long: `Show meeting load per week or month: the number of meetings, total
+hours, average duration and the share of working hours spent in meetings.
+
+Meetings count as in the analytics tables: timed events that weren't
+cancelled or declined; a meeting held in several of your accounts counts
+once. For the share, overlapping meetings count once and only time within
+[work_hours] in config.toml (Monday to Friday, 09:00-17:00 by default)
+counts. Weeks start on the locale's first day of the week ([output]
+week_start). Defaults to the last 12 weeks or months.
+
+Examples:
+  calvault report load
+  calvault report load --group-by month --since 2024-01-01`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		today := workDay(time.Now())
+		until := today.AddDate(0, 0, 1)
+		var period func(time.Time) time.Time
+		var since time.Time
+		switch reportGroupBy {
+		case "week":
+			period = out.Locale().StartOfWeek
+			since = period(today).AddDate(0, 0, -7*11)
+		case "month":
+			period = func(day time.Time) time.Time {
+				return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+			}
+			since = period(today).AddDate(0, -11, 0)
+		default:
+			return fmt.Errorf("--group-by must be week or month")
+		}
+		if reportSince != "" {
+			var err error
+			if since, err = parseWorkDateFlag(reportSince); err != nil {
+				return err
+			}
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		periods, err := s.GetLoadReport(since, until, workingHours(), period)
+		if err != nil {
+			return err
+		}
+
+		out.Title("Meeting Load")
+		out.Println(out.Muted(fmt.Sprintf("%s to %s", out.Date(since), out.Date(today))))
+		out.Println()
+		label := "Week of"
+		if reportGroupBy == "month" {
+			label = "Month"
+		}
+		total := &store.LoadPeriod{}
+		t := render.NewTable(label, "Meetings", "Hours", "Average", "Working hours").AlignRight(1, 2, 3, 4)
+		for _, p := range periods {
+			start := out.Date(p.Start)
+			if reportGroupBy == "month" {
+				start = p.Start.Format("2006-01")
+			}
+			t.Row(start, out.Number(int64(p.Meetings)), fmt.Sprintf("%.1f", p.Hours),
+				loadAverage(p), loadShare(p))
+			total.Meetings += p.Meetings
+			total.Hours += p.Hours
+			total.WorkingHours += p.WorkingHours
+			total.BusyHours += p.BusyHours
+		}
+		out.Table(t)
+		out.Println()
+		out.KeyValues(
+			"Meetings", out.Number(int64(total.Meetings)),
+			"Hours", fmt.Sprintf("%.1f", total.Hours),
+			"Average", loadAverage(total),
+			"Working hours", fmt.Sprintf("%s in meetings", loadShare(total)),
+		)
+		return nil
+	},
+}
+
+var reportConflictsCmd = &cobra.Command{
+	Use:   "conflicts",
+	Short: "Find double-booked time",
+	Long: `Find the times when two or more meetings overlap, across all synced
+calendars and accounts, with a summary per week: the number of conflicts,
+hours double-booked and meetings involved. The most recent conflicts are
+listed with the meetings that clash.
+
+Meetings count as in the analytics tables: timed events that weren't
+cancelled or declined; occurrences of recurring series come from
+event_instances. A meeting held in several of your accounts counts once, and
+back-to-back meetings don't conflict. Weeks start on the locale's first day
+of the week ([output] week_start). Defaults to the last 12 weeks.
+
+Examples:
+  calvault report conflicts
+  calvault report conflicts --since 2024-01-01 --limit 50`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if reportLimit < 0 {
+			return fmt.Errorf("--limit must not be negative")
+		}
+		today := workDay(time.Now())
+		until := today.AddDate(0, 0, 1)
+		week := out.Locale().StartOfWeek
+		since := week(today).AddDate(0, 0, -7*11)
+		if reportSince != "" {
+			var err error
+			if since, err = parseWorkDateFlag(reportSince); err != nil {
+				return err
+			}
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		report, err := s.GetConflictReport(since, until, week)
+		if err != nil {
+			return err
+		}
+
+		out.Title("Double Bookings")
+		out.Println(out.Muted(fmt.Sprintf("%s to %s", out.Date(since), out.Date(today))))
+		out.Println()
+		if len(report.Conflicts) == 0 {
+			out.Println(out.Good("No overlapping meetings in this period."))
+			return nil
+		}
+
+		var hours float64
+		weeks := 0
+		t := render.NewTable("Week of", "Conflicts", "Hours", "Meetings").AlignRight(1, 2, 3)
+		for _, p := range report.Periods {
+			conflicts := out.Number(int64(p.Conflicts))
+			if p.Conflicts == 0 {
+				conflicts = out.Muted("0")
+			} else {
+				weeks++
+			}
+			t.Row(out.Date(p.Start), conflicts, fmt.Sprintf("%.1f", p.Hours), out.Number(int64(p.Events)))
+			hours += p.Hours
+		}
+		out.Table(t)
+		out.Println()
+		out.KeyValues(
+			"Conflicts", out.Number(int64(len(report.Conflicts))),
+			"Hours double-booked", fmt.Sprintf("%.1f", hours),
+			"Weeks with conflicts", fmt.Sprintf("%d of %d", weeks, len(report.Periods)),
+		)
+
+		if reportLimit == 0 {
+			return nil
+		}
+		out.Println()
+		out.Println(out.Heading("Most Recent"))
+		t = render.NewTable("When", "Overlap", "Meetings").AlignRight(1)
+		for i := len(report.Conflicts) - 1; i >= 0 && i >= len(report.Conflicts)-reportLimit; i-- {
+			c := report.Conflicts[i]
+			var titles []string
+			for _, e := range c.Events {
+				title := e.Summary
+				if title == "" {
+					title = "(no title)"
+				}
+				titles = append(titles, oneLine(title, 30))
+			}
+			t.Row(out.DateTime(c.Start), fmt.Sprintf("%.0fm", c.End.Sub(c.Start).Minutes()),
+				out.Accent(strings.Join(titles, " / ")))
+		}
+		out.Table(t)
+		if len(report.Conflicts) > reportLimit {
+			out.Println(out.Muted(fmt.Sprintf("  ... and %d earlier (use --limit to see them)", len(report.Conflicts)-reportLimit)))
+		}
+		return nil
+	},
+}
+
+var reportSeriesCmd = &cobra.Command{
+	Use:   "series",
+	Short: "Show how your recurring meetings are doing",
+	Long: `Review every recurring meeting series held in the period, to decide which
+standing meetings to keep: occurrences held and cancelled, hours spent, the
+average number of attendees and how it changed, the share of invitations
+accepted, and a trend of attendees per month, oldest to newest.
+
+Series are listed by hours held, most first, with signals worth a look:
+
+  often cancelled   a quarter or more of the occurrences were cancelled
+  shrinking         attendees fell by a fifth or more (growing: rose)
+  few accept        under half the invitations were accepted
+  you decline       you declined half the occurrences or more
+  not held lately   no occurrence in the last five weeks
+
+Occurrences come from event_instances, and a series held in several of
+your accounts, or split by editing "this and following events", counts
+once. Occurrences that weren't changed individually have the series'
+current attendees and responses, so attendee changes show through edited
+occurrences and split series. Cancellations count the individually
+cancelled occurrences the archive holds. Rooms and resources aren't
+attendees, and all-day series are left out. Defaults to the last six
+months.
+
+Examples:
+  calvault report series
+  calvault report series --since 2024-01-01 --min 8`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if reportLimit < 1 {
+			return fmt.Errorf("--limit must be at least 1")
+		}
+		today := workDay(time.Now())
+		until := today.AddDate(0, 0, 1)
+		since := time.Date(today.Year(), today.Month()-5, 1, 0, 0, 0, 0, today.Location())
+		if reportSince != "" {
+			var err error
+			if since, err = parseWorkDateFlag(reportSince); err != nil {
+				return err
+			}
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		report, err := s.GetSeriesReport(since, until)
+		if err != nil {
+			return err
+		}
+		var series []*store.SeriesHealth
+		for _, h := range report.Series {
+			if h.Held >= reportMinHeld {
+				series = append(series, h)
+			}
+		}
+
+		out.Title("Recurring Meetings")
+		out.Println(out.Muted(fmt.Sprintf("%s to %s", out.Date(since), out.Date(today))))
+		out.Println()
+		if len(series) == 0 {
+			out.Println(fmt.Sprintf("No recurring meetings held %d or more times in this period.", reportMinHeld))
+			return nil
+		}
+
+		recent := today.AddDate(0, 0, -35)
+		t := render.NewTable("Series", "Held", "Hours", "Cancelled", "Attendees", "Accepted", "Trend", "Signals").AlignRight(1, 2, 3, 4, 5)
+		for _, h := range series[:min(len(series), reportLimit)] {
+			title := h.Title
+			if title == "" {
+				title = "(no title)"
+			}
+			accepted := "-"
+			if h.Attendees > 0 {
+				accepted = fmt.Sprintf("%.0f%%", 100*h.AcceptRate())
+			}
+			t.Row(oneLine(title, 36), out.Number(int64(h.Held)), fmt.Sprintf("%.1f", h.Hours),
+				seriesCancelled(h), seriesAttendees(h), accepted,
+				out.Accent(render.Sparkline(seriesTrend(h))), out.Warn(strings.Join(seriesSignals(h, recent), ", ")))
+		}
+		out.Table(t)
+		if len(series) > reportLimit {
+			out.Println(out.Muted(fmt.Sprintf("  ... and %d more (use --limit to see them)", len(series)-reportLimit)))
+		}
+
+		var hours float64
+		for _, h := range series {
+			hours += h.Hours
+		}
+		out.Println()
+		out.KeyValues(
+			"Series", out.Number(int64(len(series))),
+			"Hours", fmt.Sprintf("%.1f", hours),
+		)
+		return nil
+	},
+}
+
+// seriesCancelled formats a series' cancelled occurrences with their share.
+func seriesCancelled(h *store.SeriesHealth) string {
+	if h.Cancelled == 0 {
+		return out.Muted("0")
+	}
+	return fmt.Sprintf("%s (%.0f%%)", out.Number(int64(h.Cancelled)), 100*h.CancelRate())
+}
+
+// seriesAttendees formats a series' average attendee count and how it
+// changed.
+func seriesAttendees(h *store.SeriesHealth) string {
+	average := fmt.Sprintf("%.1f", h.AverageAttendees())
+	switch change := h.AttendeeChange(); {
+	case change >= 0.5:
+		return average + " " + out.Good(fmt.Sprintf("+%.0f", change))
+	case change <= -0.5:
+		return average + " " + out.Bad(fmt.Sprintf("%.0f", change))
+	}
+	return average
+}
+
+// seriesTrend returns a series' average attendees per month, in tenths,
+// for a sparkline; months without occurrences are 0.
+func seriesTrend(h *store.SeriesHealth) []int {
+	trend := make([]int, len(h.Months))
+	for i, m := range h.Months {
+		if m.Held > 0 {
+			trend[i] = 10 * m.Attendees / m.Held
+		}
+	}
+	return trend
+}
+
+// seriesSignals lists what makes a series worth reviewing; recent is when
+// an occurrence should last have been held.
+func seriesSignals(h *store.SeriesHealth, recent time.Time) []string {
+	var signals []string
+	if h.Cancelled >= 2 && h.CancelRate() >= 0.25 {
+		signals = append(signals, "often cancelled")
+	}
+	if change, average := h.AttendeeChange(), h.AverageAttendees(); change <= -1 && change <= -average/5 {
+		signals = append(signals, "shrinking")
+	} else if change >= 1 && change >= average/5 {
+		signals = append(signals, "growing")
+	}
+	if h.Attendees > 0 && h.AcceptRate() < 0.5 {
+		signals = append(signals, "few accept")
+	}
+	if h.DeclineRate() >= 0.5 {
+		signals = append(signals, "you decline")
+	}
+	if h.Last.Before(recent) {
+		signals = append(signals, "not held lately")
+	}
+	return signals
+}
+
+// loadAverage formats a period's average meeting duration.
+func loadAverage(p *store.LoadPeriod) string {
+	if p.Meetings == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0fm", p.AverageMinutes())
+}
+
+// loadShare formats the share of working hours spent in meetings,
+// highlighting periods that are half meetings or more.
+func loadShare(p *store.LoadPeriod) string {
+	if p.WorkingHours == 0 {
+		return "-"
+	}
+	share := fmt.Sprintf("%.0f%%", 100*p.BusyShare())
+	if p.BusyShare() >= 0.5 {
+		return out.Warn(share)
+	}
+	return share
+}
+
+// personLabel shows a person's name with their address, or just the address.
+func personLabel(p *store.PersonTally) string {
+	if p.Name == "" || p.Name == p.Email {
+		return p.Email
+	}
+	return fmt.Sprintf("%s %s", oneLine(p.Name, 30), out.Muted("<"+p.Email+">"))
+}
+
+// foldPeriods sums consecutive runs of n counts, such as months into
+// quarters.
+func foldPeriods(counts []int, n int) []int {
+	if n <= 1 {
+		return counts
+	}
+	folded := make([]int, 0, (len(counts)+n-1)/n)
+	for i := 0; i < len(counts); i += n {
+		sum := 0
+		for _, c := range counts[i:min(i+n, len(counts))] {
+			sum += c
+		}
+		folded = append(folded, sum)
+	}
+	return folded
+}
+
+func init() {
+	reportPeopleCmd.Flags().StringVar(&reportSince, "since", "", "Only include meetings starting on or after this date (YYYY-MM-DD, default 12 months ago)")
+	reportPeopleCmd.Flags().IntVar(&reportLimit, "limit", 20, "Number of people to show")
+	reportLoadCmd.Flags().StringVar(&reportGroupBy, "group-by", "week", "Period to sum meetings by: week or month")
+	reportLoadCmd.Flags().StringVar(&reportSince, "since", "", "Only include meetings starting on or after this date (YYYY-MM-DD, default 12 periods ago)")
+	reportConflictsCmd.Flags().StringVar(&reportSince, "since", "", "Only include meetings starting on or after this date (YYYY-MM-DD, default 12 weeks ago)")
+	reportConflictsCmd.Flags().IntVar(&reportLimit, "limit", 20, "Number of recent conflicts to list (0 for none)")
+	reportCmd.Flags().StringVar(&reportFormat, "format", "", "Write the reports to a file in this format: xlsx")
+	reportCmd.Flags().StringVarP(&reportOutput, "output", "o", "calvault-report.xlsx", "Workbook file (\"-\" for stdout)")
+	reportCmd.Flags().StringVar(&reportSince, "since", "", "Only include events starting on or after this date (YYYY-MM-DD, default 12 months ago)")
+	reportCmd.AddCommand(reportPeopleCmd)
+	reportCmd.AddCommand(reportLoadCmd)
+	reportSeriesCmd.Flags().StringVar(&reportSince, "since", "", "Only include meetings starting on or after this date (YYYY-MM-DD, default 6 months ago)")
+	reportSeriesCmd.Flags().IntVar(&reportLimit, "limit", 20, "Number of series to show")
+	reportSeriesCmd.Flags().IntVar(&reportMinHeld, "min", 3, "Leave out series held fewer times than this in the period")
+	reportCmd.AddCommand(reportConflictsCmd)
+	reportCmd.AddCommand(reportSeriesCmd)
+	rootCmd.AddCommand(reportCmd)
+}
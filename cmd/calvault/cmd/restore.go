@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/salman1993/calvault/internal/daemon"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var restoreForce bool
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <backup-file>",
+	Short: "Replace the database with a backup",
+	Long: `Replace the database with a backup written by 'calvault backup', plain or
+gzip-compressed.
+
+The backup is copied next to the database and checked with SQLite's
+integrity_check before anything is replaced; the copy is then renamed over
+the database in one step, so a failed restore leaves the database as it
+was. The database it replaces is kept as <database>.pre-restore until the
+next restore.
+
+A backup from an older calvault is upgraded to the current schema once
+restored. Restoring over a database with a newer schema than the backup's
+would lose what the newer schema stores, and needs --force; a backup newer
+than this calvault can't be restored until calvault is upgraded.
+
+Sync and the daemon must not be running.
+Refused under [storage] legal_hold, or while the database is still under a
+hold set by an earlier run, as restoring discards what was archived since
+the backup.
+
+Examples:
+  calvault restore ~/backups/calvault-20260101-030000.db.gz
+  calvault restore /mnt/nas/calvault.db --force`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := os.Stat(args[0]); err != nil {
+			return fmt.Errorf("backup: %w", err)
+		}
+		// Replacing the database would discard what was archived since the
+		// backup; store.Restore also refuses a database still under hold
+		if cfg.Storage.LegalHold {
+			return fmt.Errorf("restore: %w", store.ErrLegalHold)
+		}
+
+		// Neither a sync nor the daemon may write while the file is replaced
+		syncLock, err := acquireSyncLock()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = syncLock.Release() }()
+		daemonLock, err := daemon.AcquireLock(cfg.DaemonLockPath())
+		if errors.Is(err, daemon.ErrLocked) {
+			return fmt.Errorf("stop the daemon before restoring: %w", err)
+		}
+		if err != nil {
+			return err
+		}
+		defer func() { _ = daemonLock.Release() }()
+
+		dbPath := cfg.DatabasePath()
+		restored, err := store.Restore(args[0], dbPath, restoreForce)
+		if errors.Is(err, store.ErrNewerSchema) {
+			return fmt.Errorf("restore: %w - restoring would lose data stored by the newer schema; use --force to restore anyway", err)
+		}
+		if err != nil {
+			return fmt.Errorf("restore: %w", err)
+		}
+
+		// Bring a backup from an older calvault up to the current schema
+		s, err := store.Open(dbPath)
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+		if err := applyStorageSettings(s); err != nil {
+			return err
+		}
+		stats, err := s.GetStats()
+		if err != nil {
+			return fmt.Errorf("get stats: %w", err)
+		}
+
+		logger.Info("restored database", "from", args[0], "schema_version", restored.SchemaVersion, "replaced", restored.Replaced)
+		out.Printf("%s %s to %s (%s events)\n", out.Good("Restored"), out.Accent(args[0]), dbPath, out.Number(int64(stats.EventCount)))
+		if restored.SchemaVersion < store.SchemaVersion {
+			out.Printf("Upgraded the backup's schema from version %d to %d.\n", restored.SchemaVersion, store.SchemaVersion)
+		}
+		if restored.Replaced != "" {
+			out.Println(out.Muted(fmt.Sprintf("The replaced database is kept as %s.", restored.Replaced)))
+		}
+		return nil
+	},
+}
+
+func init() {
+	restoreCmd.Flags().BoolVar(&restoreForce, "force", false, "Replace a database with a newer schema than the backup")
+	rootCmd.AddCommand(restoreCmd)
+}
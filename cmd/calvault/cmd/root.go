@@ -7,6 +7,7 @@ import (
 	"os"
 
 	"github.com/salman1993/calvault/internal/config"
+	"github.com/salman1993/calvault/internal/render"
 	"github.com/spf13/cobra"
 )
 
@@ -16,10 +17,15 @@ var (
 	Commit    = "unknown"
 	BuildDate = "unknown"
 
-	cfgFile string
-	verbose bool
-	cfg     *config.Config
-	logger  *slog.Logger
+	cfgFile   string
+	verbose   bool
+	noColor   bool
+	logFormat string
+	cfg       *config.Config
+	logger    *slog.Logger
+
+	// out renders human-readable command output to stdout.
+	out = render.New(os.Stdout, render.NoColor)
 )
 
 var rootCmd = &cobra.Command{
@@ -36,26 +42,92 @@ Archive years of calendar data and answer questions like:
 			return nil
 		}
 
-		// Set up logging
-		level := slog.LevelInfo
-		if verbose {
-			level = slog.LevelDebug
-		}
-		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-			Level: level,
-		}))
-
 		// Load config
 		var err error
 		cfg, err = config.Load(cfgFile)
+		if err != nil && cmd.Name() == "doctor" {
+			// doctor reports a broken config rather than failing on it
+			doctorConfigErr = err
+			cfg, err = config.Default(cfgFile), nil
+		}
 		if err != nil {
 			return fmt.Errorf("load config: %w", err)
 		}
 
+		// Set up logging; --log-format wins over config
+		format := cfg.Output.LogFormat
+		if logFormat != "" {
+			format = logFormat
+		}
+		if logger, err = newLogger(format, verbose); err != nil {
+			return err
+		}
+
+		out = newRenderer(os.Stdout)
 		return nil
 	},
 }
 
+// newRenderer returns a renderer of human-readable output to f, styled if
+// f is a terminal unless [output] color or --no-color (which wins) say
+// otherwise.
+func newRenderer(f *os.File) *render.Renderer {
+	colorMode := cfg.Output.Color
+	if noColor {
+		colorMode = render.ColorNever
+	}
+	theme := render.NoColor
+	if render.ColorEnabled(colorMode, f) {
+		theme = render.DefaultTheme
+	}
+	r := render.New(f, theme)
+	r.SetLocale(outputLocale(cfg.Output))
+	return r
+}
+
+// newLogger returns a logger writing to stderr in the given format, text
+// or json, at debug level if verbose. Logs use the same keys throughout,
+// so shipped JSON logs can be filtered on them: account (email), calendar
+// (name), calendar_id, event_id (events.id), job and error.
+func newLogger(format string, verbose bool) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: slog.LevelInfo}
+	if verbose {
+		opts.Level = slog.LevelDebug
+	}
+	switch format {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(logWriter{}, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(logWriter{}, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q (expected text or json)", format)
+	}
+}
+
+// outputLocale resolves the [output] locale settings, applying overrides on
+// top of the configured or detected locale.
+func outputLocale(c config.OutputConfig) render.Locale {
+	loc := render.DetectLocale()
+	if c.Locale != "" && c.Locale != "auto" {
+		loc = render.LocaleFor(c.Locale)
+	}
+	if c.WeekStart != "" {
+		if day, err := render.ParseWeekday(c.WeekStart); err == nil {
+			loc.WeekStart = day
+		}
+	}
+	switch c.TimeFormat {
+	case "12h":
+		loc.Clock24 = false
+	case "24h":
+		loc.Clock24 = true
+	}
+	if c.DateOrder != "" {
+		loc.DateOrder = c.DateOrder
+	}
+	return loc
+}
+
 func Execute() error {
 	return rootCmd.Execute()
 }
@@ -75,6 +147,22 @@ func errOAuthNotConfigured() error {
 	return fmt.Errorf("OAuth client secrets not configured." + oauthSetupHint)
 }
 
+// microsoftSetupHint is the help text for Microsoft account configuration.
+const microsoftSetupHint = `
+To sync Microsoft 365 / Outlook calendars, register an app in Microsoft Entra ID:
+  1. Go to https://entra.microsoft.com > App registrations > New registration
+  2. Add a "Mobile and desktop applications" platform with redirect URI
+     http://localhost:8089/callback and enable "Allow public client flows"
+  3. Grant the delegated Microsoft Graph permission Calendars.Read
+  4. Add to your config.toml:
+       [microsoft]
+       client_id = "<application (client) ID>"`
+
+// errMicrosoftNotConfigured returns a helpful error when the Microsoft client ID is missing.
+func errMicrosoftNotConfigured() error {
+	return fmt.Errorf("Microsoft client ID not configured." + microsoftSetupHint)
+}
+
 // wrapOAuthError wraps an oauth/client-secrets error with setup instructions.
 func wrapOAuthError(err error) error {
 	if errors.Is(err, os.ErrNotExist) {
@@ -86,4 +174,7 @@ func wrapOAuthError(err error) error {
 func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default: ~/.calvault/config.toml)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "disable colored output (also NO_COLOR env)")
+	rootCmd.PersistentFlags().BoolVar(&noProgress, "no-progress", false, "print a line per calendar instead of live sync progress bars")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "", "log format: text or json (default from [output] log_format, else text)")
 }
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/salman1993/calvault/internal/rpc"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var rpcCmd = &cobra.Command{
+	Use:   "rpc [request]",
+	Short: "Answer a single JSON-RPC request",
+	Long: `Answer one JSON-RPC 2.0 request and print the JSON response, for
+automation tools (Apple Shortcuts, scripts, other programs) that can run a
+command but can't compose SQL.
+
+The request is read from the argument or stdin. The "jsonrpc" version and
+"id" are optional; the id is echoed back. Failures are reported in the
+response's "error" and exit with status 1.
+
+Methods:
+  accounts.list                     Accounts in the vault
+  calendars.list   {account}        Calendars, optionally of one account
+  events.list      {from, to, account, calendar, attendee, location,
+                    status, limit}  Events, filtered as by 'calvault events'
+  events.search    {query, account, limit}
+                                    Full-text search, as by 'calvault search'
+  stats                             Archive statistics
+  query            {sql, params}    Read-only SQL with :name placeholders
+  query.named      {name, params}   A named query from config
+
+Dates are YYYY-MM-DD; "to" is inclusive. events.list returns at most 100
+events unless "limit" is given (0 = no limit). query and query.named obey
+the [query] settings (account scope, allowlist) and the audit log; while
+[query] allowlist_only or account, or a team vault's privacy, is in effect,
+the other methods are refused, as they would read around it.
+
+Examples:
+  calvault rpc '{"method":"events.list","params":{"from":"2024-03-01","attendee":"dana"}}'
+  calvault rpc '{"method":"query","params":{"sql":"SELECT COUNT(*) FROM events WHERE location LIKE :loc","params":{"loc":"%clinic%"}}}'
+  echo '{"id":1,"method":"stats"}' | calvault rpc`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		request, err := readRPCRequest(args)
+		if err != nil {
+			return err
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		registry, err := buildNamedQueryRegistry()
+		if err != nil {
+			return err
+		}
+		executor, closeExecutor, err := openExecutor(registry)
+		if err != nil {
+			return err
+		}
+		defer closeExecutor()
+
+		resp := rpc.NewServer(s, executor).Handle(cmd.Context(), request)
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+		if resp.Error != nil {
+			// The response already describes the failure
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return errors.New(resp.Error.Message)
+		}
+		return nil
+	},
+}
+
+// readRPCRequest reads a request from the first argument or stdin.
+func readRPCRequest(args []string) ([]byte, error) {
+	if len(args) == 1 {
+		return []byte(args[0]), nil
+	}
+	stat, _ := os.Stdin.Stat()
+	if (stat.Mode() & os.ModeCharDevice) != 0 {
+		return nil, fmt.Errorf("no request provided - pass as argument or pipe to stdin")
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("read stdin: %w", err)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return nil, fmt.Errorf("empty request")
+	}
+	return data, nil
+}
+
+func init() {
+	rpcCmd.Flags().BoolVar(&queryNoCache, "no-cache", false, "Run queries even if cached results are available")
+	rpcCmd.Flags().StringVar(&queryCaller, "caller", defaultCaller(), "Caller name recorded in the audit log (env: CALVAULT_CALLER)")
+	rootCmd.AddCommand(rpcCmd)
+}
@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/salman1993/calvault/internal/query"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	schemaJSON    bool
+	schemaSamples int
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Describe the tables for writing queries, e.g. in an LLM prompt",
+	Long: `Describe every table and view - columns, types, foreign keys, indexes and a few
+sample rows - in a compact form meant to be pasted into an LLM prompt, so a
+model can write 'calvault query' SQL against the archive.
+
+Sample rows are read like 'calvault query' does: they obey the [query]
+settings (account scope, team privacy) and are recorded in the audit log.
+With query.allowlist_only set, no samples are shown. Use --samples 0 to
+leave them out, and 'calvault dump-schema' for the raw CREATE statements.
+
+Examples:
+  calvault schema
+  calvault schema --samples 0 | pbcopy
+  calvault schema --json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if schemaSamples < 0 {
+			return fmt.Errorf("--samples must not be negative")
+		}
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		tables, err := s.Tables()
+		_ = s.Close()
+		if err != nil {
+			return fmt.Errorf("describe tables: %w", err)
+		}
+
+		samples := make(map[string]*query.QueryResult)
+		if schemaSamples > 0 && !cfg.Query.AllowlistOnly {
+			registry, err := buildNamedQueryRegistry()
+			if err != nil {
+				return err
+			}
+			executor, closeExecutor, err := openExecutor(registry)
+			if err != nil {
+				return err
+			}
+			defer closeExecutor()
+
+			for _, t := range tables {
+				sql := fmt.Sprintf(`SELECT * FROM "%s" LIMIT %d`, strings.ReplaceAll(t.Name, `"`, `""`), schemaSamples)
+				result, err := executor.Execute(cmd.Context(), sql)
+				if err != nil {
+					return fmt.Errorf("sample %s: %w", t.Name, err)
+				}
+				samples[t.Name] = result
+			}
+		}
+
+		if schemaJSON {
+			return writeSchemaJSON(os.Stdout, tables, samples)
+		}
+		return writeSchemaText(os.Stdout, tables, samples)
+	},
+}
+
+// writeSchemaText writes one block per table: a line per column with its
+// type and constraints, then indexes and tab-separated sample rows.
+func writeSchemaText(w io.Writer, tables []*store.TableInfo, samples map[string]*query.QueryResult) error {
+	var b strings.Builder
+	b.WriteString("SQLite database. Times are ISO 8601 text with a UTC offset; booleans are stored as 0/1.\n")
+	for _, t := range tables {
+		references := make(map[string]store.ForeignKeyInfo)
+		for _, fk := range t.ForeignKeys {
+			references[fk.From] = fk
+		}
+
+		// A composite primary key gets a line of its own
+		var key []string
+		for _, c := range t.Columns {
+			if c.PrimaryKey {
+				key = append(key, c.Name)
+			}
+		}
+
+		kind := "TABLE"
+		if t.View {
+			kind = "VIEW"
+		}
+		fmt.Fprintf(&b, "\n%s %s\n", kind, t.Name)
+		for _, c := range t.Columns {
+			fmt.Fprintf(&b, "  %s", c.Name)
+			if c.Type != "" {
+				fmt.Fprintf(&b, " %s", c.Type)
+			}
+			if c.PrimaryKey && len(key) == 1 {
+				b.WriteString(" PRIMARY KEY")
+			} else if c.NotNull {
+				b.WriteString(" NOT NULL")
+			}
+			if fk, ok := references[c.Name]; ok {
+				fmt.Fprintf(&b, " REFERENCES %s(%s)", fk.Table, fk.To)
+			}
+			b.WriteString("\n")
+		}
+		if len(key) > 1 {
+			fmt.Fprintf(&b, "  PRIMARY KEY (%s)\n", strings.Join(key, ", "))
+		}
+		for _, idx := range t.Indexes {
+			kind := "INDEX"
+			if idx.Unique {
+				kind = "UNIQUE"
+			}
+			fmt.Fprintf(&b, "  %s (%s)\n", kind, strings.Join(idx.Columns, ", "))
+		}
+
+		sample := samples[t.Name]
+		if sample == nil || len(sample.Rows) == 0 {
+			continue
+		}
+		b.WriteString("  Sample rows:\n")
+		fmt.Fprintf(&b, "    %s\n", strings.Join(sample.Columns, "\t"))
+		for _, row := range sample.Rows {
+			cells := make([]string, len(row))
+			for i, v := range row {
+				if v == nil {
+					cells[i] = "NULL"
+				} else {
+					cells[i] = oneLine(formatQueryValue(v), 60)
+				}
+			}
+			fmt.Fprintf(&b, "    %s\n", strings.Join(cells, "\t"))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// schemaTable is a table in schema --json output.
+type schemaTable struct {
+	Name    string         `json:"name"`
+	View    bool           `json:"view,omitempty"`
+	Columns []schemaColumn `json:"columns"`
+	Indexes []schemaIndex  `json:"indexes"`
+	Sample  *schemaSample  `json:"sample,omitempty"`
+}
+
+type schemaColumn struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	NotNull    bool   `json:"not_null"`
+	PrimaryKey bool   `json:"primary_key"`
+	References string `json:"references,omitempty"` // table.column
+}
+
+type schemaIndex struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+}
+
+type schemaSample struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+func writeSchemaJSON(w io.Writer, tables []*store.TableInfo, samples map[string]*query.QueryResult) error {
+	result := make([]schemaTable, 0, len(tables))
+	for _, t := range tables {
+		references := make(map[string]string)
+		for _, fk := range t.ForeignKeys {
+			references[fk.From] = fk.Table + "." + fk.To
+		}
+
+		st := schemaTable{Name: t.Name, View: t.View, Columns: []schemaColumn{}, Indexes: []schemaIndex{}}
+		for _, c := range t.Columns {
+			st.Columns = append(st.Columns, schemaColumn{
+				Name:       c.Name,
+				Type:       c.Type,
+				NotNull:    c.NotNull,
+				PrimaryKey: c.PrimaryKey,
+				References: references[c.Name],
+			})
+		}
+		for _, idx := range t.Indexes {
+			st.Indexes = append(st.Indexes, schemaIndex{Name: idx.Name, Columns: idx.Columns, Unique: idx.Unique})
+		}
+		if sample := samples[t.Name]; sample != nil {
+			st.Sample = &schemaSample{Columns: sample.Columns, Rows: sample.Rows}
+		}
+		result = append(result, st)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Tables []schemaTable `json:"tables"`
+	}{result})
+}
+
+func init() {
+	schemaCmd.Flags().BoolVar(&schemaJSON, "json", false, "Output as JSON")
+	schemaCmd.Flags().IntVar(&schemaSamples, "samples", 3, "Sample rows per table (0 for none)")
+	schemaCmd.Flags().StringVar(&queryCaller, "caller", defaultCaller(), "Caller name recorded in the audit log (env: CALVAULT_CALLER)")
+	rootCmd.AddCommand(schemaCmd)
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	searchLimit   int
+	searchAccount string
+	searchRebuild bool
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Full-text search over event titles, descriptions, and locations",
+	Long: `Search events using the full-text index (SQLite FTS5).
+
+Words are stemmed, so "run" also finds "running". The query supports FTS5
+syntax: "exact phrases", prefix*, OR, NOT, and column filters such as
+location:clinic. Results are ranked by relevance.
+
+Binaries built without FTS5 (see 'just build') fall back to a plain
+substring match.
+
+Examples:
+  calvault search dentist
+  calvault search '"team offsite" OR retreat'
+  calvault search 'location:clinic' --account you@gmail.com`,
+	Args: cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		if searchRebuild {
+			if err := s.RebuildSearchIndex(); err != nil {
+				return err
+			}
+			fmt.Println("Search index rebuilt.")
+			if len(args) == 0 {
+				return nil
+			}
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("no search query given")
+		}
+
+		available, err := s.SearchIndexAvailable()
+		if err != nil {
+			return err
+		}
+		if !available {
+			out.Println(out.Warn("Full-text index unavailable in this build; using substring match."))
+		}
+
+		opts := store.SearchOptions{Limit: searchLimit}
+		if searchAccount != "" {
+			src, err := s.GetSourceByIdentifier(searchAccount)
+			if err != nil {
+				return fmt.Errorf("get source: %w", err)
+			}
+			if src == nil {
+				return fmt.Errorf("account %q not found", searchAccount)
+			}
+			opts.SourceID = src.ID
+		}
+
+		results, err := s.SearchEvents(args[0], opts)
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			out.Println("No matching events.")
+			return nil
+		}
+
+		for _, r := range results {
+			e := r.Event
+			date := "          "
+			if e.StartTime.Valid {
+				date = out.Date(e.StartTime.Time.Local())
+			}
+			out.Printf("%s  %s\n", out.Muted(date), out.Accent(e.Summary))
+			if e.Location != "" {
+				out.Printf("            @ %s\n", e.Location)
+			}
+			if r.Snippet != "" && r.Snippet != e.Summary {
+				out.Printf("            %s\n", out.Muted(oneLine(r.Snippet, 100)))
+			}
+		}
+		out.Printf("\n%d result(s)\n", len(results))
+		return nil
+	},
+}
+
+func init() {
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 50, "Maximum number of results")
+	searchCmd.Flags().StringVar(&searchAccount, "account", "", "Only search this account")
+	searchCmd.Flags().BoolVar(&searchRebuild, "rebuild", false, "Rebuild the full-text index from the events table")
+	rootCmd.AddCommand(searchCmd)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/salman1993/calvault/internal/update"
+	"github.com/spf13/cobra"
+)
+
+var (
+	updateCheckOnly bool
+	updateForce     bool
+	updateInsecure  bool
+)
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Update calvault to the latest release",
+	Long: `Check GitHub for a newer calvault release and install it in place.
+
+The downloaded binary is verified against the release's SHA-256 checksums,
+and the checksums against the release signature. The new binary replaces
+the running one only after verification succeeds. A build without the
+release public key (such as one built from source) refuses to update
+unless --insecure is given, and then verifies checksums only.
+
+Installs managed by a package manager (Homebrew, Nix, Scoop, /usr/bin) are
+not replaced; upgrade them through the package manager instead.
+Development builds are only replaced with --force.
+
+Examples:
+  calvault self-update --check-only
+  calvault self-update`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		client := update.NewClient()
+
+		rel, err := client.Latest(ctx)
+		if err != nil {
+			return err
+		}
+		if !update.Newer(rel.Version, Version) {
+			out.Printf("calvault %s is up to date (latest release: %s).\n", Version, rel.Version)
+			return nil
+		}
+		out.Printf("A new release is available: %s %s\n", out.Accent(rel.Version), out.Muted("(current: "+Version+")"))
+		if rel.URL != "" {
+			out.Println(out.Muted(rel.URL))
+		}
+		if updateCheckOnly {
+			return nil
+		}
+
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("locate executable: %w", err)
+		}
+		if manager := update.ManagedBy(exe); manager != "" {
+			return fmt.Errorf("%s was installed by %s; upgrade it there instead", exe, manager)
+		}
+		if !update.IsRelease(Version) && !updateForce {
+			return fmt.Errorf("this is a development build; use --force to replace it with %s", rel.Version)
+		}
+		client.Insecure = updateInsecure
+		if client.PublicKey == "" && !updateInsecure {
+			return fmt.Errorf("%w; use --insecure to install with checksums only", update.ErrNoPublicKey)
+		}
+
+		out.Printf("Downloading %s...\n", update.AssetName(runtime.GOOS, runtime.GOARCH))
+		bin, err := client.Download(ctx, rel, runtime.GOOS, runtime.GOARCH)
+		if err != nil {
+			return err
+		}
+		if client.PublicKey == "" {
+			out.Println(out.Muted("Verified the checksums only (--insecure); the release signature was not checked."))
+		} else {
+			out.Println(out.Muted("Verified the checksums and the release signature."))
+		}
+		if err := update.Install(exe, bin); err != nil {
+			return err
+		}
+		out.Printf("%s calvault %s installed to %s\n", out.Good("Updated:"), rel.Version, exe)
+		return nil
+	},
+}
+
+func init() {
+	selfUpdateCmd.Flags().BoolVar(&updateCheckOnly, "check-only", false, "Only report whether a newer release exists")
+	selfUpdateCmd.Flags().BoolVar(&updateForce, "force", false, "Replace a development build")
+	selfUpdateCmd.Flags().BoolVar(&updateInsecure, "insecure", false, "Install with checksums only when this build has no release public key")
+	rootCmd.AddCommand(selfUpdateCmd)
+}
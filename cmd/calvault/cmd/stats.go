@@ -1,19 +1,53 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/salman1993/calvault/internal/render"
 	"github.com/salman1993/calvault/internal/store"
 	"github.com/spf13/cobra"
 )
 
+var (
+	statsJSON    bool
+	statsHistory bool
+	statsLimit   int
+)
+
 var statsCmd = &cobra.Command{
 	Use:   "stats",
 	Short: "Show archive statistics",
 	Long: `Display statistics about the calendar archive.
 
-Shows counts of accounts, calendars, events, date range, 
-unique locations, and recurring events.`,
+Shows counts of accounts, calendars, events, events this week, date range,
+unique locations, recurring events, and events flagged for implausible
+dates (before 1900, a century ahead, ending before they start, or
+created in the future). Flagged events are kept but left out of the date
+range and analytics. Dates, numbers and the first day of
+the week follow the [output] locale settings.
+
+--json prints the same figures for scripts and dashboards, with a
+breakdown per account (sync status and token state) and the database's
+size. Its shape is versioned by the top-level "version": fields may be
+added within a version, and renaming, removing or changing the meaning of
+one bumps it. Times are RFC 3339, dates YYYY-MM-DD.
+
+--history charts the archive's growth instead: the counts recorded after
+each sync, daemon sync and import that changed them, with the change from
+one to the next and per-calendar counts. A drop nobody asked for - a
+calendar losing events after a sync - is worth a look.
+
+Examples:
+  calvault stats
+  calvault stats --json | jq '.events.total'
+  calvault stats --history
+  calvault stats --history --limit 0 --json`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		s, err := store.Open(cfg.DatabasePath())
 		if err != nil {
@@ -21,29 +55,301 @@ unique locations, and recurring events.`,
 		}
 		defer func() { _ = s.Close() }()
 
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		if statsHistory {
+			return showStatsHistory(s)
+		}
+
 		stats, err := s.GetStats()
 		if err != nil {
 			return fmt.Errorf("get stats: %w", err)
 		}
 
-		fmt.Println("Calendar Archive Statistics")
-		fmt.Println("===========================")
-		fmt.Printf("  Accounts:         %d\n", stats.AccountCount)
-		fmt.Printf("  Calendars:        %d\n", stats.CalendarCount)
-		fmt.Printf("  Total events:     %d\n", stats.EventCount)
+		weekStart := out.Locale().StartOfWeek(time.Now())
+		thisWeek, err := s.CountEvents(store.EventFilter{Since: weekStart, Until: weekStart.AddDate(0, 0, 7)})
+		if err != nil {
+			return err
+		}
 
+		if statsJSON {
+			return writeStatsJSON(s, stats, weekStart, thisWeek)
+		}
+
+		out.Title("Calendar Archive Statistics")
+		pairs := []string{
+			"Accounts", out.Number(int64(stats.AccountCount)),
+			"Calendars", out.Number(int64(stats.CalendarCount)),
+			"Total events", out.Number(int64(stats.EventCount)),
+		}
 		if stats.EventCount > 0 {
-			fmt.Printf("  Date range:       %s to %s\n",
-				stats.EarliestEvent.Format("2006-01-02"),
-				stats.LatestEvent.Format("2006-01-02"))
-			fmt.Printf("  Unique locations: %d\n", stats.UniqueLocations)
-			fmt.Printf("  Recurring events: %d\n", stats.RecurringCount)
+			pairs = append(pairs,
+				"This week", fmt.Sprintf("%s %s", out.Number(thisWeek),
+					out.Muted(fmt.Sprintf("(from %s %s)", weekStart.Weekday().String()[:3], out.Date(weekStart)))),
+				"Date range", fmt.Sprintf("%s to %s",
+					out.Date(stats.EarliestEvent),
+					out.Date(stats.LatestEvent)),
+				"Unique locations", out.Number(int64(stats.UniqueLocations)),
+				"Recurring events", out.Number(int64(stats.RecurringCount)),
+			)
 		}
+		if flagged := flaggedTotal(stats.Flagged); flagged > 0 {
+			pairs = append(pairs, "Flagged events", out.Warn(fmt.Sprintf("%s %s", out.Number(int64(flagged)), formatFlagged(stats.Flagged))))
+		}
+		out.KeyValues(pairs...)
 
 		return nil
 	},
 }
 
+// statsJSONVersion is the version of the stats --json shape. Fields may be
+// added within a version; renaming, removing or changing the meaning of
+// one bumps it.
+const statsJSONVersion = 1
+
+// statsJSONDoc is the JSON shape of stats --json.
+type statsJSONDoc struct {
+	Version     int                `json:"version"`
+	GeneratedAt string             `json:"generated_at"`
+	Database    statsDatabaseJSON  `json:"database"`
+	Accounts    int                `json:"accounts"`
+	Calendars   int                `json:"calendars"`
+	Events      statsEventsJSON    `json:"events"`
+	ByAccount   []accountJSONEntry `json:"by_account"`
+}
+
+type statsDatabaseJSON struct {
+	Path        string `json:"path"`
+	SizeBytes   int64  `json:"size_bytes"`   // Main file, without the write-ahead log
+	DataVersion int64  `json:"data_version"` // Changes whenever the data does
+}
+
+type statsEventsJSON struct {
+	Total           int            `json:"total"`
+	ThisWeek        int64          `json:"this_week"`
+	WeekStart       string         `json:"week_start"`
+	Earliest        string         `json:"earliest,omitempty"`
+	Latest          string         `json:"latest,omitempty"`
+	UniqueLocations int            `json:"unique_locations"`
+	Recurring       int            `json:"recurring"` // Instances and exceptions of recurring series
+	Flagged         int            `json:"flagged"`
+	FlaggedBy       map[string]int `json:"flagged_by_quality"` // Implausibly dated events by flag
+}
+
+// writeStatsJSON prints the statistics as a statsJSONDoc.
+func writeStatsJSON(s *store.Store, stats *store.Stats, weekStart time.Time, thisWeek int64) error {
+	accounts, err := s.ListAccountSummaries()
+	if err != nil {
+		return err
+	}
+	version, err := s.DataVersion()
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(cfg.DatabasePath())
+	if err != nil {
+		return fmt.Errorf("stat database: %w", err)
+	}
+
+	doc := statsJSONDoc{
+		Version:     statsJSONVersion,
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		Database: statsDatabaseJSON{
+			Path:        cfg.DatabasePath(),
+			SizeBytes:   info.Size(),
+			DataVersion: version,
+		},
+		Accounts:  stats.AccountCount,
+		Calendars: stats.CalendarCount,
+		Events: statsEventsJSON{
+			Total:           stats.EventCount,
+			ThisWeek:        thisWeek,
+			WeekStart:       weekStart.Format("2006-01-02"),
+			UniqueLocations: stats.UniqueLocations,
+			Recurring:       stats.RecurringCount,
+			Flagged:         flaggedTotal(stats.Flagged),
+			FlaggedBy:       stats.Flagged,
+		},
+		ByAccount: make([]accountJSONEntry, 0, len(accounts)),
+	}
+	if !stats.EarliestEvent.IsZero() {
+		doc.Events.Earliest = stats.EarliestEvent.Format(time.RFC3339)
+		doc.Events.Latest = stats.LatestEvent.Format(time.RFC3339)
+	}
+	for _, a := range accounts {
+		doc.ByAccount = append(doc.ByAccount, newAccountJSONEntry(a))
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// flaggedTotal sums event counts by quality flag.
+func flaggedTotal(byQuality map[string]int) int {
+	total := 0
+	for _, n := range byQuality {
+		total += n
+	}
+	return total
+}
+
+// formatFlagged lists event counts by quality flag, e.g.
+// "(end_before_start 2, future_timestamp 1)".
+func formatFlagged(byQuality map[string]int) string {
+	qualities := make([]string, 0, len(byQuality))
+	for q := range byQuality {
+		qualities = append(qualities, q)
+	}
+	sort.Strings(qualities)
+	parts := make([]string, len(qualities))
+	for i, q := range qualities {
+		parts[i] = fmt.Sprintf("%s %d", q, byQuality[q])
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// recordStatsSnapshot records the archive's statistics for stats
+// --history. Failing to is only logged.
+func recordStatsSnapshot(s *store.Store, trigger string) {
+	var size int64
+	if info, err := os.Stat(cfg.DatabasePath()); err == nil {
+		size = info.Size()
+	}
+	if _, err := s.RecordStatsSnapshot(trigger, size); err != nil {
+		logger.Warn("failed to record archive statistics", "error", err)
+	}
+}
+
+// showStatsHistory prints the recorded snapshots: a row each with the
+// change in events from the one before, a sparkline of the event count
+// and each calendar's change over the snapshots shown.
+func showStatsHistory(s *store.Store) error {
+	history, err := s.ListStatsHistory(time.Time{}, statsLimit)
+	if err != nil {
+		return err
+	}
+	if statsJSON {
+		return writeStatsHistoryJSON(history)
+	}
+	if len(history) == 0 {
+		out.Println("No statistics recorded yet; they are recorded after each sync.")
+		return nil
+	}
+
+	out.Title("Archive Growth")
+	t := render.NewTable("Recorded", "Trigger", "Events", "Change", "Calendars", "Accounts", "Database").AlignRight(2, 3, 4, 5, 6)
+	counts := make([]int, len(history))
+	for i, snap := range history {
+		change := ""
+		if i > 0 {
+			change = formatChange(snap.Events - history[i-1].Events)
+		}
+		t.Row(out.DateTime(snap.RecordedAt.Local()), snap.Trigger, out.Number(int64(snap.Events)), change,
+			out.Number(int64(snap.Calendars)), out.Number(int64(snap.Accounts)), formatSize(snap.DBSizeBytes))
+		counts[i] = snap.Events
+	}
+	out.Table(t)
+	if len(history) > 1 {
+		out.Println()
+		out.KeyValues("Events", out.Accent(render.Sparkline(counts)))
+	}
+
+	// Each calendar from its first count shown to its latest
+	first, latest := map[int64]int{}, map[int64]store.CalendarEventCount{}
+	var order []int64
+	for _, snap := range history {
+		for _, c := range snap.ByCalendar {
+			if _, seen := latest[c.CalendarID]; !seen {
+				first[c.CalendarID] = c.Events
+				order = append(order, c.CalendarID)
+			}
+			latest[c.CalendarID] = c
+		}
+	}
+	if len(order) == 0 {
+		return nil
+	}
+	out.Println()
+	current := map[int64]bool{}
+	for _, c := range history[len(history)-1].ByCalendar {
+		current[c.CalendarID] = true
+	}
+	ct := render.NewTable("Account", "Calendar", "First", "Latest", "Change").AlignRight(2, 3, 4)
+	for _, id := range order {
+		c := latest[id]
+		name := oneLine(c.Calendar, 40)
+		if !current[id] {
+			name += out.Muted(" (removed)")
+		}
+		ct.Row(c.Account, name, out.Number(int64(first[id])), out.Number(int64(c.Events)), formatChange(c.Events-first[id]))
+	}
+	out.Table(ct)
+	return nil
+}
+
+// formatChange formats a change in a count, a drop in the bad color.
+func formatChange(n int) string {
+	switch {
+	case n > 0:
+		return out.Good("+" + out.Number(int64(n)))
+	case n < 0:
+		return out.Bad("-" + out.Number(int64(-n)))
+	}
+	return out.Muted("0")
+}
+
+// statsHistoryJSONDoc is the JSON shape of stats --history --json,
+// versioned like statsJSONDoc.
+type statsHistoryJSONDoc struct {
+	Version   int                     `json:"version"`
+	Snapshots []statsSnapshotJSONItem `json:"snapshots"`
+}
+
+type statsSnapshotJSONItem struct {
+	RecordedAt  string                  `json:"recorded_at"`
+	Trigger     string                  `json:"trigger"`
+	Accounts    int                     `json:"accounts"`
+	Calendars   int                     `json:"calendars"`
+	Events      int                     `json:"events"`
+	DBSizeBytes int64                   `json:"db_size_bytes"`
+	ByCalendar  []statsCalendarJSONItem `json:"by_calendar"`
+}
+
+type statsCalendarJSONItem struct {
+	Account  string `json:"account"`
+	Calendar string `json:"calendar"`
+	Events   int    `json:"events"`
+}
+
+// writeStatsHistoryJSON prints snapshots as a statsHistoryJSONDoc.
+func writeStatsHistoryJSON(history []*store.StatsSnapshot) error {
+	doc := statsHistoryJSONDoc{Version: statsJSONVersion, Snapshots: make([]statsSnapshotJSONItem, 0, len(history))}
+	for _, snap := range history {
+		item := statsSnapshotJSONItem{
+			RecordedAt:  snap.RecordedAt.Format(time.RFC3339),
+			Trigger:     snap.Trigger,
+			Accounts:    snap.Accounts,
+			Calendars:   snap.Calendars,
+			Events:      snap.Events,
+			DBSizeBytes: snap.DBSizeBytes,
+			ByCalendar:  make([]statsCalendarJSONItem, 0, len(snap.ByCalendar)),
+		}
+		for _, c := range snap.ByCalendar {
+			item.ByCalendar = append(item.ByCalendar, statsCalendarJSONItem{Account: c.Account, Calendar: c.Calendar, Events: c.Events})
+		}
+		doc.Snapshots = append(doc.Snapshots, item)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
 func init() {
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "Output as JSON with a versioned shape")
+	statsCmd.Flags().BoolVar(&statsHistory, "history", false, "Chart the archive's growth from the statistics recorded after syncs")
+	statsCmd.Flags().IntVar(&statsLimit, "limit", 30, "With --history, show the latest this many snapshots (0 for all)")
 	rootCmd.AddCommand(statsCmd)
 }
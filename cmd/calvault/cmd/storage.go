@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// applyStorageSettings applies the settings kept in the database itself,
+// [storage] event_history and legal_hold and the [[identity]] aliases, so
+// they hold for every writer.
+func applyStorageSettings(s *store.Store) error {
+	if err := s.SetEventHistory(cfg.Storage.EventHistory); err != nil {
+		return err
+	}
+	if err := s.SetLegalHold(cfg.Storage.LegalHold); err != nil {
+		return fmt.Errorf("apply legal hold: %w", err)
+	}
+	identities := make([]store.Identity, len(cfg.Identities))
+	for i, id := range cfg.Identities {
+		identities[i] = store.Identity{Name: id.Name, Emails: id.Emails}
+	}
+	if err := s.SetIdentities(identities); err != nil {
+		return fmt.Errorf("apply identities: %w", err)
+	}
+	return nil
+}
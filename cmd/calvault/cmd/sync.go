@@ -1,41 +1,121 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
+	gosync "sync"
 	"syscall"
 	"time"
 
 	"github.com/salman1993/calvault/internal/calendar"
+	"github.com/salman1993/calvault/internal/changelog"
+	"github.com/salman1993/calvault/internal/daemon"
+	"github.com/salman1993/calvault/internal/graph"
+	"github.com/salman1993/calvault/internal/metrics"
 	"github.com/salman1993/calvault/internal/oauth"
+	"github.com/salman1993/calvault/internal/render"
 	"github.com/salman1993/calvault/internal/store"
 	"github.com/salman1993/calvault/internal/sync"
 	"github.com/spf13/cobra"
 )
 
-var incremental bool
+var (
+	incremental  bool
+	syncEstimate bool
+	syncCalendar string
+	syncDryRun   bool
+	syncJSON     bool
+)
 
 var syncCmd = &cobra.Command{
 	Use:   "sync [email]",
-	Short: "Sync calendar events from Google",
-	Long: `Synchronize calendar events from a Google account.
+	Short: "Sync calendar events from Google or Microsoft 365",
+	Long: `Synchronize calendar events from a Google or Microsoft 365 account.
 
 By default, performs a full sync of all calendars and events.
 Use --incremental to only fetch changes since the last sync (faster).
+Microsoft accounts use Graph delta queries over a window configured by
+[microsoft] past_years/future_years.
+
+Individual calendars can be tuned with [[sync.calendar]] entries in
+config.toml (time window, recurring event expansion, attendee storage, QPS),
+matched by calendar ID or name.
+
+[sync] profile chooses how much of each event is stored: minimal (titles
+and times), standard (also locations, organizers and reminders) or full
+(also descriptions, attendees and conferences; the default). Set it per
+account in [sync.profiles].
+
+With --dry-run, events are fetched and compared with the archive, and the
+counts of events that would be added, updated, or deleted are reported per
+calendar; nothing is written to the database.
+
+With [sync] daily_request_budget set, Google syncs stop once the day's
+requests are spent and pick up from the last stored page on the next run;
+see 'calvault sync-plan' for spreading a large first sync over days.
+
+On a terminal, each calendar syncing shows a progress bar with its events
+and pages so far, the rate, and an ETA when its size is known from earlier
+syncs or a sync plan. --no-progress prints a line as each calendar starts
+and finishes instead, as when output is piped.
 
-If no email is specified, syncs all configured accounts.
+With --json, the results are written to stdout as one JSON document for
+scripts and cron jobs - per account its status (ok, interrupted, deferred
+or failed), totals and a breakdown per calendar - and everything else goes
+to stderr. The exit status is non-zero if any account failed.
+
+With --calendar, only that calendar of the account is synced, matched by
+its Google calendar ID or its name (ignoring case), even if
+include_calendars or exclude_calendars would leave it out. Without
+--incremental this forces a full re-sync of one problematic calendar
+without touching the others.
+
+Every sync except dry runs is recorded; 'calvault sync history' lists
+them with their status, duration, changes and errors.
+
+If no email is specified, syncs all configured accounts, [sync]
+account_concurrency (default 2) at a time. Each has its own client, but
+they share rate_limit_qps and the daily request budget, and their
+calendars are named with the account in the output. With --estimate,
+accounts go one at a time.
 
 Examples:
   calvault sync you@gmail.com              # Full sync
   calvault sync you@gmail.com --incremental # Incremental sync
+  calvault sync you@gmail.com --estimate    # Count events and ask before a full sync
+  calvault sync you@gmail.com --dry-run     # Report changes per calendar without saving
+  calvault sync you@gmail.com --calendar Work # Full re-sync of one calendar
+  calvault sync --incremental --json        # Results as JSON for scripts
   calvault sync                             # Sync all accounts`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// Validate config
-		if cfg.OAuth.ClientSecrets == "" {
-			return errOAuthNotConfigured()
+		if syncEstimate && incremental {
+			return fmt.Errorf("--estimate applies to full syncs and cannot be combined with --incremental")
+		}
+		if syncEstimate && syncDryRun {
+			return fmt.Errorf("--estimate and --dry-run cannot be combined")
+		}
+		if syncCalendar != "" && len(args) == 0 {
+			return fmt.Errorf("--calendar needs the account to sync")
+		}
+		if syncJSON {
+			// Leave stdout to the JSON document
+			out = newRenderer(os.Stderr)
+			kind := "full"
+			if incremental {
+				kind = "incremental"
+			}
+			syncReport = &syncJSONReport{Version: syncJSONVersion, Kind: kind, DryRun: syncDryRun, Accounts: []syncAccountJSON{}}
 		}
 
 		// Open database
@@ -49,35 +129,43 @@ Examples:
 		if err := s.InitSchema(); err != nil {
 			return fmt.Errorf("init schema: %w", err)
 		}
-
-		// Create OAuth manager
-		oauthMgr, err := oauth.NewManager(cfg.OAuth.ClientSecrets, cfg.TokensDir(), logger)
-		if err != nil {
-			return wrapOAuthError(fmt.Errorf("create oauth manager: %w", err))
+		if err := applyStorageSettings(s); err != nil {
+			return err
 		}
 
 		// Determine which accounts to sync
-		var emails []string
+		managers := oauthManagers{}
+		var accounts []*store.Source
 		if len(args) == 1 {
-			emails = []string{args[0]}
-		} else {
-			sources, err := s.ListSources()
+			src, err := s.GetSourceByIdentifier(args[0])
 			if err != nil {
-				return fmt.Errorf("list sources: %w", err)
+				return fmt.Errorf("get source: %w", err)
 			}
-			if len(sources) == 0 {
-				return fmt.Errorf("no accounts configured - run 'add-account' first")
+			if src == nil {
+				// Accounts not yet in the database are Google accounts
+				src = &store.Source{SourceType: store.SourceTypeGoogle, Identifier: args[0]}
 			}
-			for _, src := range sources {
-				if !oauthMgr.HasToken(src.Identifier) {
-					fmt.Printf("Skipping %s (no OAuth token - run 'add-account' first)\n", src.Identifier)
-					continue
-				}
-				emails = append(emails, src.Identifier)
+			if src.SourceType != store.SourceTypeGoogle && src.SourceType != store.SourceTypeMicrosoft {
+				return fmt.Errorf("account %s is an %s source and cannot be synced", src.Identifier, src.SourceType)
+			}
+			if _, err := managers.get(src.SourceType); err != nil {
+				return err
 			}
-			if len(emails) == 0 {
-				return fmt.Errorf("no accounts have valid tokens - run 'add-account' first")
+			accounts = []*store.Source{src}
+		} else {
+			accounts, err = syncableAccounts(s, managers)
+			if err != nil {
+				return err
+			}
+		}
+
+		// A dry run doesn't write, so it can run alongside another sync
+		if !syncDryRun {
+			lock, err := acquireSyncLock()
+			if err != nil {
+				return err
 			}
+			defer func() { _ = lock.Release() }()
 		}
 
 		// Set up context with cancellation
@@ -89,28 +177,29 @@ Examples:
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		go func() {
 			<-sigChan
-			fmt.Println("\nInterrupted. Stopping sync...")
+			out.Println("\nInterrupted. Stopping sync...")
 			cancel()
 		}()
 
-		// Sync each account
-		var syncErrors []string
-		for _, email := range emails {
-			if ctx.Err() != nil {
-				break
-			}
+		syncErrors := syncAccounts(ctx, s, managers, accounts)
 
-			if err := runSync(ctx, s, oauthMgr, email); err != nil {
-				syncErrors = append(syncErrors, fmt.Sprintf("%s: %v", email, err))
-				continue
-			}
+		// Bring analytics tables and event_instances up to date
+		if !syncDryRun {
+			refreshDerivedTables(s)
+			enrichAfterSync(ctx, s)
+			recordStatsSnapshot(s, "sync")
 		}
 
+		if syncReport != nil {
+			if err := syncReport.write(os.Stdout); err != nil {
+				return err
+			}
+		}
 		if len(syncErrors) > 0 {
-			fmt.Println()
-			fmt.Println("Errors:")
+			out.Println()
+			out.Println("Errors:")
 			for _, e := range syncErrors {
-				fmt.Printf("  %s\n", e)
+				out.Printf("  %s\n", e)
 			}
 			return fmt.Errorf("%d account(s) failed to sync", len(syncErrors))
 		}
@@ -119,26 +208,198 @@ Examples:
 	},
 }
 
-func runSync(ctx context.Context, s *store.Store, oauthMgr *oauth.Manager, email string) error {
+// oauthManagers creates OAuth managers per provider on first use.
+type oauthManagers map[string]*oauth.Manager
+
+func (m oauthManagers) get(sourceType string) (*oauth.Manager, error) {
+	if mgr, ok := m[sourceType]; ok {
+		return mgr, nil
+	}
+	mgr, err := newOAuthManager(sourceType)
+	if err != nil {
+		return nil, err
+	}
+	m[sourceType] = mgr
+	return mgr, nil
+}
+
+// syncableAccounts returns the Google and Microsoft accounts that have OAuth
+// tokens, printing why any others are skipped.
+func syncableAccounts(s *store.Store, managers oauthManagers) ([]*store.Source, error) {
+	sources, err := s.ListSources()
+	if err != nil {
+		return nil, fmt.Errorf("list sources: %w", err)
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no accounts configured - run 'add-account' first")
+	}
+
+	var accounts []*store.Source
+	for _, src := range sources {
+		if src.SourceType != store.SourceTypeGoogle && src.SourceType != store.SourceTypeMicrosoft {
+			continue
+		}
+		oauthMgr, err := managers.get(src.SourceType)
+		if err != nil {
+			out.Printf("Skipping %s (%s provider not configured)\n", src.Identifier, src.SourceType)
+			continue
+		}
+		if !oauthMgr.HasToken(src.Identifier) {
+			out.Printf("Skipping %s (no OAuth token - run 'add-account' first)\n", src.Identifier)
+			continue
+		}
+		accounts = append(accounts, src)
+	}
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("no accounts have valid tokens - run 'add-account' first")
+	}
+	return accounts, nil
+}
+
+// acquireSyncLock takes the lock that keeps manual and daemon syncs from
+// running at the same time.
+func acquireSyncLock() (*daemon.Lock, error) {
+	lock, err := daemon.AcquireLock(cfg.SyncLockPath())
+	if errors.Is(err, daemon.ErrLocked) {
+		return nil, fmt.Errorf("another sync is already running: %w", err)
+	}
+	return lock, err
+}
+
+// syncAccounts syncs accounts, up to [sync] account_concurrency at once,
+// and returns a line per account that failed.
+func syncAccounts(ctx context.Context, s *store.Store, managers oauthManagers, accounts []*store.Source) []string {
+	n := min(cfg.Sync.AccountConcurrency, len(accounts))
+	if syncEstimate {
+		// Estimates ask before each sync, so accounts go one at a time
+		n = 1
+	}
+	run := newSyncRun(s, n > 1)
+	run.calendar = syncCalendar
+
+	errs := make([]string, len(accounts))
+	sem := make(chan struct{}, n)
+	var wg gosync.WaitGroup
+	for i, src := range accounts {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			if syncEstimate {
+				proceed, err := runEstimate(ctx, s, run, managers[src.SourceType], src)
+				if err != nil {
+					errs[i] = fmt.Sprintf("%s: %v", src.Identifier, err)
+					return
+				}
+				if !proceed {
+					return
+				}
+			}
+			if err := runSync(ctx, s, run, managers[src.SourceType], src, incremental, syncDryRun); err != nil {
+				errs[i] = fmt.Sprintf("%s: %v", src.Identifier, err)
+				syncReport.add(src.Identifier, "failed", nil, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return slices.DeleteFunc(errs, func(e string) bool { return e == "" })
+}
+
+// syncRun is what the accounts synced by one command share: the progress
+// display, and the rate limiter and daily request budget, so that
+// rate_limit_qps and daily_request_budget cap the run as a whole however
+// many accounts sync at once. Each account still has its own client.
+type syncRun struct {
+	progress    *CLIProgress
+	rateLimiter *calendar.RateLimiter
+	budget      *sync.Budget
+	concurrent  bool   // Accounts sync at once, so output names them
+	calendar    string // Only sync this calendar, by ID or name, if set
+}
+
+func newSyncRun(s *store.Store, concurrent bool) *syncRun {
+	return &syncRun{
+		progress:    newCLIProgress(syncOutput()),
+		rateLimiter: calendar.NewRateLimiter(cfg.Sync.RateLimitQPS, cfg.Sync.RateLimitBurst),
+		budget:      syncBudget(s),
+		concurrent:  concurrent,
+	}
+}
+
+// includeCalendar reports whether a calendar is synced: the one chosen
+// with --calendar, whatever the config says, or else those [sync]
+// includes.
+func (r *syncRun) includeCalendar(id, name string) bool {
+	if r.calendar != "" {
+		return id == r.calendar || strings.EqualFold(name, r.calendar)
+	}
+	return cfg.Sync.IncludeCalendar(id, name)
+}
+
+// accountSyncer is implemented by the per-provider syncers.
+type accountSyncer interface {
+	SyncAccount(ctx context.Context, email string, opts sync.Options) (*sync.Summary, error)
+}
+
+func runSync(ctx context.Context, s *store.Store, run *syncRun, oauthMgr *oauth.Manager, src *store.Source, incremental, dryRun bool) error {
+	email := src.Identifier
 	tokenSource, err := oauthMgr.TokenSource(ctx, email)
 	if err != nil {
 		return fmt.Errorf("get token source: %w (run 'add-account' first)", err)
 	}
 
-	// Create Calendar client
-	rateLimiter := calendar.NewRateLimiter(float64(cfg.Sync.RateLimitQPS))
-	client, err := calendar.NewClient(ctx, tokenSource,
-		calendar.WithLogger(logger),
-		calendar.WithRateLimiter(rateLimiter),
-	)
-	if err != nil {
-		return fmt.Errorf("create calendar client: %w", err)
+	// A full sync of a large archive can take hours, so it runs as a job
+	// that 'calvault jobs resume' can continue
+	var job *jobTracker
+	if !incremental && !dryRun {
+		description, params := "full sync of "+email, map[string]string{"account": email}
+		if run.calendar != "" {
+			description = fmt.Sprintf("full sync of %s (%s)", email, run.calendar)
+			params["calendar"] = run.calendar
+		}
+		job = startJob(s, "sync", description, params)
+	}
+	progress := &jobSyncProgress{accountProgress: accountProgress{CLIProgress: run.progress}, job: job}
+	if run.concurrent {
+		progress.account = email
 	}
 
-	// Create syncer with progress reporter
-	syncer := sync.New(client, s).
-		WithLogger(logger).
-		WithProgress(&CLIProgress{})
+	// Create API client and syncer with progress reporter
+	log := logger.With("account", email)
+	var syncer accountSyncer
+	if src.SourceType == store.SourceTypeMicrosoft {
+		client := graph.NewClient(ctx, tokenSource,
+			graph.WithLogger(log),
+			graph.WithRateLimiter(run.rateLimiter),
+		)
+		now := time.Now().UTC()
+		syncer = sync.NewGraph(client, s).
+			WithLogger(log).
+			WithProgress(progress).
+			WithWindow(now.AddDate(-cfg.Microsoft.PastYears, 0, 0), now.AddDate(cfg.Microsoft.FutureYears, 0, 0))
+	} else {
+		client, err := calendar.NewClient(ctx, tokenSource,
+			calendar.WithLogger(log),
+			calendar.WithRateLimiter(run.rateLimiter),
+		)
+		if err != nil {
+			return fmt.Errorf("create calendar client: %w", err)
+		}
+		syncer = sync.New(client, s).
+			WithLogger(log).
+			WithProgress(progress)
+	}
+
+	// In a team vault, an account is synced by the member who owns it
+	if cfg.Team.Enabled() && !dryRun {
+		if err := s.ClaimSource(src.ID, cfg.Team.Member); err != nil {
+			job.finish(err)
+			return err
+		}
+	}
 
 	// Run sync
 	startTime := time.Now()
@@ -146,30 +407,92 @@ func runSync(ctx context.Context, s *store.Store, oauthMgr *oauth.Manager, email
 	if incremental {
 		syncType = "incremental"
 	}
-	fmt.Printf("Starting %s sync for %s\n\n", syncType, email)
+	if dryRun {
+		syncType = "dry-run " + syncType
+	}
+	run.progress.print(func() { out.Printf("Starting %s sync for %s\n\n", syncType, email) })
 
-	summary, err := syncer.SyncAccount(ctx, email, sync.Options{
-		Incremental: incremental,
-	})
+	opts := sync.Options{
+		Incremental:     incremental,
+		DryRun:          dryRun,
+		Profile:         cfg.Sync.ProfileFor(email),
+		Concurrency:     cfg.Sync.Concurrency,
+		IncludeCalendar: run.includeCalendar,
+		ForCalendar:     calendarSyncOptions,
+	}
+	if src.SourceType == store.SourceTypeGoogle {
+		opts.Budget = run.budget
+	}
+	var changes *changelog.Writer
+	if path := cfg.ChangelogPath(); path != "" && !dryRun {
+		if changes, err = changelog.Open(path, email, syncType); err != nil {
+			job.finish(err)
+			return err
+		}
+		opts.OnChanges = changelogWriter(changes, log)
+	}
+	summary, err := syncer.SyncAccount(ctx, email, opts)
+	progress.finish()
+	if changes != nil {
+		// A run without an end record didn't finish
+		var cerr error
+		if err == nil {
+			cerr = changes.Close(summary.EventsAdded, summary.EventsUpdated, summary.EventsDeleted)
+		} else {
+			cerr = changes.Abort()
+		}
+		if cerr != nil {
+			log.Warn("failed to close changelog", "error", cerr)
+		}
+	}
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err == nil && run.calendar != "" && len(summary.Calendars) == 0 {
+		err = fmt.Errorf("no calendar with ID or name %q", run.calendar)
+	}
+	job.finish(err)
+	if !dryRun {
+		recordSyncMetrics(email, syncType, time.Since(startTime), summary, err)
+		recordSyncRun(ctx, s, email, syncType, startTime, summary, err)
+	}
 	if err != nil {
 		if ctx.Err() != nil {
-			fmt.Println("\nSync interrupted. Run again to continue.")
+			run.progress.print(func() {
+				out.Println("\n" + progress.name("") + "Sync interrupted. Completed pages were kept; run again to continue.")
+			})
+			syncReport.add(email, "interrupted", summary, nil)
+			return nil
+		}
+		if errors.Is(err, sync.ErrBudgetExhausted) {
+			run.progress.print(func() {
+				out.Println(out.Warn(fmt.Sprintf("%sDaily request budget of %s spent; the sync continues tomorrow.", progress.name(""), out.Number(int64(cfg.Sync.DailyRequestBudget)))))
+			})
+			syncReport.add(email, "deferred", summary, nil)
 			return nil
 		}
 		return fmt.Errorf("sync failed: %w", err)
 	}
+	if cfg.Team.Enabled() && !dryRun {
+		if _, err := s.MarkPrivateCalendars(src.ID, cfg.Team.IsPrivateCalendar); err != nil {
+			return err
+		}
+	}
 
-	// Print summary
-	fmt.Println()
-	fmt.Println("Sync complete!")
-	fmt.Printf("  Duration:   %s\n", summary.Duration.Round(time.Second))
-	fmt.Printf("  Calendars:  %d synced\n", summary.CalendarsSynced)
-	fmt.Printf("  Events:     +%d added, ~%d updated, -%d deleted\n",
-		summary.EventsAdded, summary.EventsUpdated, summary.EventsDeleted)
+	// Print summary in one piece, as accounts syncing at once finish
+	// around each other
+	run.progress.print(func() { printSyncSummary(progress.name(""), summary, dryRun) })
+
+	status := "ok"
+	if ctx.Err() != nil {
+		// Calendars that were cut off count as failed in the summary
+		status = "interrupted"
+	}
+	syncReport.add(email, status, summary, nil)
 
 	elapsed := time.Since(startTime)
 	logger.Info("sync completed",
-		"email", email,
+		"account", email,
 		"calendars", summary.CalendarsSynced,
 		"events_added", summary.EventsAdded,
 		"elapsed", elapsed,
@@ -178,22 +501,354 @@ func runSync(ctx context.Context, s *store.Store, oauthMgr *oauth.Manager, email
 	return nil
 }
 
-// CLIProgress implements sync.Progress for terminal output.
-type CLIProgress struct{}
+// printSyncSummary prints an account's sync results. prefix names the
+// account when several sync at once.
+func printSyncSummary(prefix string, summary *sync.Summary, dryRun bool) {
+	calendars := fmt.Sprintf("%d synced", summary.CalendarsSynced)
+	if summary.CalendarsSkipped > 0 {
+		calendars += out.Warn(fmt.Sprintf(", %d excluded by config", summary.CalendarsSkipped))
+	}
+	if summary.CalendarsFailed > 0 {
+		calendars += out.Bad(fmt.Sprintf(", %d failed (see the log; they retry next sync)", summary.CalendarsFailed))
+	}
+	if summary.CalendarsDeferred > 0 {
+		calendars += out.Warn(fmt.Sprintf(", %d deferred (daily request budget spent; they continue tomorrow)", summary.CalendarsDeferred))
+	}
+	verb := ""
+	out.Println()
+	if dryRun {
+		out.Println(out.Warn(prefix + "Dry run complete; nothing was written."))
+		verb = "would be "
+	} else {
+		out.Println(out.Good(prefix + "Sync complete!"))
+	}
+	out.KeyValues(
+		"Duration", summary.Duration.Round(time.Second).String(),
+		"Calendars", calendars,
+		"Events", fmt.Sprintf("%s %sadded, %s %supdated, %s %sdeleted",
+			out.Good("+"+out.Number(int64(summary.EventsAdded))), verb,
+			"~"+out.Number(int64(summary.EventsUpdated)), verb,
+			out.Bad("-"+out.Number(int64(summary.EventsDeleted))), verb),
+	)
+	if summary.EventsMerged > 0 {
+		out.KeyValues("Merged", fmt.Sprintf("%s imported copies of synced events", out.Number(int64(summary.EventsMerged))))
+	}
+	if summary.EventsFlagged > 0 {
+		out.KeyValues("Flagged", out.Warn(fmt.Sprintf("%s events with implausible dates, left out of analytics (see calvault stats)", out.Number(int64(summary.EventsFlagged)))))
+	}
+}
+
+// changelogWriter returns a sync.Options.OnChanges appending each page's
+// changes to a changelog. A failed write is logged rather than failing the
+// sync; the database stays the record.
+func changelogWriter(w *changelog.Writer, log *slog.Logger) func(*sync.PageChanges) {
+	return func(c *sync.PageChanges) {
+		var records []*changelog.Record
+		for _, e := range c.Added {
+			records = append(records, changelog.EventRecord(changelog.TypeAdded, c.CalendarID, e))
+		}
+		for _, e := range c.Updated {
+			records = append(records, changelog.EventRecord(changelog.TypeUpdated, c.CalendarID, e))
+		}
+		for _, id := range c.Deleted {
+			records = append(records, &changelog.Record{Type: changelog.TypeDeleted, Calendar: c.CalendarID, EventID: id})
+		}
+		for _, r := range records {
+			if err := w.Write(r); err != nil {
+				log.Warn("failed to write changelog", "calendar_id", c.CalendarID, "error", err)
+				return
+			}
+		}
+	}
+}
+
+// recordSyncMetrics counts a finished sync in the metrics the daemon
+// serves.
+func recordSyncMetrics(email, kind string, elapsed time.Duration, summary *sync.Summary, err error) {
+	result := "ok"
+	switch {
+	case errors.Is(err, sync.ErrBudgetExhausted):
+		result = "deferred"
+	case err != nil:
+		result = "error"
+	}
+	metrics.Syncs.Inc(email, kind, result)
+	metrics.SyncDuration.Observe(elapsed.Seconds(), email, kind)
+	if summary == nil {
+		return
+	}
+	if err == nil {
+		metrics.LastSuccessfulSync.Set(float64(time.Now().Unix()), email)
+	}
+	metrics.CalendarSyncErrors.Add(float64(summary.CalendarsFailed), email)
+	metrics.EventsUpserted.Add(float64(summary.EventsAdded), email, "added")
+	metrics.EventsUpserted.Add(float64(summary.EventsUpdated), email, "updated")
+	metrics.EventsDeleted.Add(float64(summary.EventsDeleted), email)
+}
+
+// recordSyncRun adds a finished sync to sync_runs for 'calvault sync
+// history'. Failing to record it is logged rather than failing the sync.
+func recordSyncRun(ctx context.Context, s *store.Store, email, kind string, start time.Time, summary *sync.Summary, err error) {
+	src, serr := s.GetSourceByIdentifier(email)
+	if serr != nil || src == nil {
+		// A first sync that failed before creating its account
+		return
+	}
+	run := &store.SyncRun{
+		Kind:        kind,
+		Status:      store.SyncCompleted,
+		StartedAt:   start,
+		CompletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	switch {
+	case ctx.Err() != nil:
+		// Calendars that were cut off count as failed in the summary
+		run.Status = store.SyncInterrupted
+	case errors.Is(err, sync.ErrBudgetExhausted):
+		run.Status = store.SyncDeferred
+	case err != nil:
+		run.Status = store.SyncFailed
+		run.Error = err.Error()
+	case summary.CalendarsFailed > 0:
+		run.Error = fmt.Sprintf("%d calendar(s) failed", summary.CalendarsFailed)
+	}
+	if summary != nil {
+		run.Added, run.Updated, run.Deleted = summary.EventsAdded, summary.EventsUpdated, summary.EventsDeleted
+		if summary.CalendarsDeferred > 0 && err == nil {
+			run.Status = store.SyncDeferred
+		}
+	}
+	if rerr := s.RecordSyncRun(src.ID, run); rerr != nil {
+		logger.Warn("failed to record sync run", "account", email, "error", rerr)
+	}
+}
+
+// syncReport collects each account's results for sync --json; nil
+// otherwise.
+var syncReport *syncJSONReport
+
+// syncOutput returns where sync writes human-readable output: stdout, or
+// stderr with --json.
+func syncOutput() *os.File {
+	if syncReport != nil {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// syncJSONVersion is the version of the sync --json shape, versioned like
+// statsJSONVersion.
+const syncJSONVersion = 1
+
+// syncJSONReport is the JSON shape of sync --json.
+type syncJSONReport struct {
+	mu gosync.Mutex // Accounts can finish at once
+
+	Version  int               `json:"version"`
+	Kind     string            `json:"kind"` // full or incremental
+	DryRun   bool              `json:"dry_run"`
+	Failed   int               `json:"failed"` // Accounts that failed to sync
+	Accounts []syncAccountJSON `json:"accounts"`
+}
+
+// syncAccountJSON is one account's sync results.
+type syncAccountJSON struct {
+	Account           string             `json:"account"`
+	Status            string             `json:"status"` // ok, interrupted, deferred or failed
+	Error             string             `json:"error,omitempty"`
+	DurationSeconds   float64            `json:"duration_seconds"`
+	CalendarsSynced   int                `json:"calendars_synced"`
+	CalendarsExcluded int                `json:"calendars_excluded"`
+	CalendarsFailed   int                `json:"calendars_failed"`
+	CalendarsDeferred int                `json:"calendars_deferred"`
+	EventsAdded       int                `json:"events_added"`
+	EventsUpdated     int                `json:"events_updated"`
+	EventsDeleted     int                `json:"events_deleted"`
+	EventsMerged      int                `json:"events_merged"`
+	EventsFlagged     int                `json:"events_flagged"`
+	Calendars         []syncCalendarJSON `json:"calendars"`
+}
+
+// syncCalendarJSON is one calendar's sync results.
+type syncCalendarJSON struct {
+	ID              string  `json:"id"`
+	Name            string  `json:"name"`
+	Status          string  `json:"status"` // synced, failed or deferred
+	Error           string  `json:"error,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	EventsAdded     int     `json:"events_added"`
+	EventsUpdated   int     `json:"events_updated"`
+	EventsDeleted   int     `json:"events_deleted"`
+	EventsMerged    int     `json:"events_merged"`
+	EventsFlagged   int     `json:"events_flagged"`
+}
+
+// add records an account's results; summary is nil if the sync stopped
+// before any calendar ran. It does nothing without --json.
+func (r *syncJSONReport) add(email, status string, summary *sync.Summary, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a := syncAccountJSON{Account: email, Status: status, Calendars: []syncCalendarJSON{}}
+	if err != nil {
+		a.Error = err.Error()
+		r.Failed++
+	}
+	if summary != nil {
+		a.DurationSeconds = summary.Duration.Seconds()
+		a.CalendarsSynced = summary.CalendarsSynced
+		a.CalendarsExcluded = summary.CalendarsSkipped
+		a.CalendarsFailed = summary.CalendarsFailed
+		a.CalendarsDeferred = summary.CalendarsDeferred
+		a.EventsAdded = summary.EventsAdded
+		a.EventsUpdated = summary.EventsUpdated
+		a.EventsDeleted = summary.EventsDeleted
+		a.EventsMerged = summary.EventsMerged
+		a.EventsFlagged = summary.EventsFlagged
+		for _, c := range summary.Calendars {
+			a.Calendars = append(a.Calendars, syncCalendarJSON{
+				ID:              c.ID,
+				Name:            c.Name,
+				Status:          c.Status,
+				Error:           c.Error,
+				DurationSeconds: c.Duration.Seconds(),
+				EventsAdded:     c.EventsAdded,
+				EventsUpdated:   c.EventsUpdated,
+				EventsDeleted:   c.EventsDeleted,
+				EventsMerged:    c.EventsMerged,
+				EventsFlagged:   c.EventsFlagged,
+			})
+		}
+	}
+	r.Accounts = append(r.Accounts, a)
+}
+
+func (r *syncJSONReport) write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
 
-func (p *CLIProgress) OnCalendarStart(calendarName string) {
-	fmt.Printf("Syncing: %s\n", calendarName)
+// syncBudget returns the [sync] daily_request_budget, or nil if unlimited.
+func syncBudget(s *store.Store) *sync.Budget {
+	if cfg.Sync.DailyRequestBudget == 0 {
+		return nil
+	}
+	return sync.NewBudget(s, cfg.Sync.DailyRequestBudget)
 }
 
-func (p *CLIProgress) OnCalendarDone(calendarName string, added, updated, deleted int) {
-	fmt.Printf("  → +%d /%d -%d\n", added, updated, deleted)
+// calendarSyncOptions applies the first matching [[sync.calendar]] override.
+func calendarSyncOptions(id, name string) sync.CalendarOptions {
+	o := cfg.Sync.CalendarOverride(id, name)
+	if o == nil {
+		return sync.CalendarOptions{}
+	}
+
+	now := time.Now().UTC()
+	opts := sync.CalendarOptions{
+		SingleEvents:  o.SingleEvents,
+		SkipAttendees: o.SkipAttendees,
+	}
+	if o.PastDays > 0 {
+		opts.TimeMin = now.AddDate(0, 0, -o.PastDays)
+	}
+	if o.FutureDays > 0 {
+		opts.TimeMax = now.AddDate(0, 0, o.FutureDays)
+	}
+	if o.RateLimitQPS > 0 {
+		opts.RateLimiter = calendar.NewRateLimiter(o.RateLimitQPS, 0)
+	}
+	logger.Debug("using calendar sync overrides", "calendar", name, "match", o.Match)
+	return opts
 }
 
-func (p *CLIProgress) OnEvent(eventSummary string) {
-	// Could show progress dots or event names if verbose
+// newCalendarClient creates a Google Calendar client for an account using the
+// configured rate limit.
+func newCalendarClient(ctx context.Context, oauthMgr *oauth.Manager, email string) (*calendar.Client, error) {
+	tokenSource, err := oauthMgr.TokenSource(ctx, email)
+	if err != nil {
+		return nil, fmt.Errorf("get token source: %w (run 'add-account' first)", err)
+	}
+	client, err := calendar.NewClient(ctx, tokenSource,
+		calendar.WithLogger(logger.With("account", email)),
+		calendar.WithRateLimiter(calendar.NewRateLimiter(cfg.Sync.RateLimitQPS, cfg.Sync.RateLimitBurst)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create calendar client: %w", err)
+	}
+	return client, nil
+}
+
+// runEstimate counts the events a full sync of a Google account would fetch
+// and prints the estimate. On a terminal it asks whether to continue with the
+// sync; otherwise it reports false so only the estimate runs.
+func runEstimate(ctx context.Context, s *store.Store, run *syncRun, oauthMgr *oauth.Manager, src *store.Source) (bool, error) {
+	if src.SourceType != store.SourceTypeGoogle {
+		out.Printf("Skipping estimate for %s (only supported for Google accounts)\n", src.Identifier)
+		return true, nil
+	}
+
+	est, err := estimateFullSync(ctx, s, oauthMgr, src, run.includeCalendar)
+	if err != nil {
+		return false, err
+	}
+
+	calendars := render.NewTable("Calendar", "Events", "Requests").AlignRight(1, 2)
+	for _, c := range est.Calendars {
+		calendars.Row(oneLine(c.Name, 40), out.Number(int64(c.Events)), out.Number(int64(c.Requests)))
+	}
+	out.Table(calendars)
+	out.Println()
+	out.KeyValues(
+		"Events", out.Number(int64(est.Events)),
+		"API requests", fmt.Sprintf("~%s for the full sync (%s used by this estimate)", out.Number(int64(est.Requests)), out.Number(int64(est.CountRequests))),
+		"Estimated ETA", out.Accent(fmt.Sprintf("~%s at %g QPS", est.ETA(cfg.Sync.RateLimitQPS).Round(time.Second), cfg.Sync.RateLimitQPS)),
+	)
+	out.Println()
+	out.Println(out.Muted("To narrow the window, set past_days/future_days in a [[sync.calendar]] entry."))
+
+	stat, _ := os.Stdin.Stat()
+	if stat == nil || (stat.Mode()&os.ModeCharDevice) == 0 {
+		return false, nil
+	}
+	out.Printf("Start full sync of %s? [y/N] ", src.Identifier)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// estimateFullSync counts the events a full sync of a Google account would
+// fetch from the calendars include picks. The requests it makes count
+// against the daily request budget.
+func estimateFullSync(ctx context.Context, s *store.Store, oauthMgr *oauth.Manager, src *store.Source, include func(id, name string) bool) (*sync.Estimate, error) {
+	client, err := newCalendarClient(ctx, oauthMgr, src.Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	out.Printf("Estimating full sync for %s...\n\n", src.Identifier)
+	est, err := sync.New(client, nil).WithLogger(logger.With("account", src.Identifier)).Estimate(ctx, sync.Options{
+		IncludeCalendar: include,
+		ForCalendar:     calendarSyncOptions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if budget := syncBudget(s); budget != nil {
+		if err := budget.Spend(est.CountRequests); err != nil {
+			logger.Warn("failed to record estimate requests", "error", err)
+		}
+	}
+	return est, nil
 }
 
 func init() {
 	syncCmd.Flags().BoolVar(&incremental, "incremental", false, "Only sync changes since last sync")
+	syncCmd.Flags().BoolVar(&syncEstimate, "estimate", false, "Count events and estimate API requests and duration before a full sync")
+	syncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "Fetch events and report what would change without writing to the database")
+	syncCmd.Flags().StringVar(&syncCalendar, "calendar", "", "Only sync the account's calendar with this ID or name")
+	syncCmd.Flags().BoolVar(&syncJSON, "json", false, "Write the results to stdout as JSON and other output to stderr")
 	rootCmd.AddCommand(syncCmd)
 }
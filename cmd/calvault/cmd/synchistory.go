@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var syncHistoryAll bool
+
+var syncHistoryCmd = &cobra.Command{
+	Use:   "history [email]",
+	Short: "Show past syncs with their status, duration and changes",
+	Long: `Show past syncs and imports, newest first, with their status, how long
+they took and the events they added, updated and deleted. Failed runs are
+listed with their errors below the table.
+
+A sync is interrupted when Ctrl+C or a shutdown stopped it, and deferred
+when [sync] daily_request_budget ran out; both continue on the next run.
+Dry runs aren't recorded.
+
+Examples:
+  calvault sync history
+  calvault sync history you@gmail.com
+  calvault sync history --all`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		var sourceID int64
+		if len(args) == 1 {
+			src, err := s.GetSourceByIdentifier(args[0])
+			if err != nil {
+				return fmt.Errorf("get source: %w", err)
+			}
+			if src == nil {
+				return fmt.Errorf("account %q not found", args[0])
+			}
+			sourceID = src.ID
+		}
+		limit := 20
+		if syncHistoryAll {
+			limit = 0
+		}
+		runs, err := s.ListSyncRuns(sourceID, limit)
+		if err != nil {
+			return err
+		}
+		if len(runs) == 0 {
+			out.Println("No syncs recorded yet.")
+			return nil
+		}
+
+		headers := []string{"ID", "Started"}
+		if sourceID == 0 {
+			headers = append(headers, "Account")
+		}
+		t := render.NewTable(append(headers, "Kind", "Status", "Took", "Events")...).AlignRight(0)
+		for _, r := range runs {
+			row := []string{strconv.FormatInt(r.ID, 10), out.DateTime(r.StartedAt.Local())}
+			if sourceID == 0 {
+				account := r.Account
+				if r.Calendar != "" {
+					account += " (" + r.Calendar + ")"
+				}
+				row = append(row, oneLine(account, 40))
+			}
+			row = append(row, r.Kind, styleSyncStatus(r.Status, ""), syncRunDuration(r),
+				fmt.Sprintf("%s %s %s", out.Good("+"+out.Number(int64(r.Added))),
+					"~"+out.Number(int64(r.Updated)), out.Bad("-"+out.Number(int64(r.Deleted)))))
+			t.Row(row...)
+		}
+		out.Table(t)
+		for _, r := range runs {
+			if r.Error != "" {
+				out.Println(out.Muted(fmt.Sprintf("Run %d: %s", r.ID, oneLine(r.Error, 100))))
+			}
+		}
+		return nil
+	},
+}
+
+// syncRunDuration formats how long a run took, or "-" if it never
+// finished.
+func syncRunDuration(r *store.SyncRun) string {
+	if !r.CompletedAt.Valid {
+		return "-"
+	}
+	return r.Duration().Round(time.Second).String()
+}
+
+func init() {
+	syncHistoryCmd.Flags().BoolVar(&syncHistoryAll, "all", false, "Show every run, not only the 20 most recent")
+	syncCmd.AddCommand(syncHistoryCmd)
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/salman1993/calvault/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+var syncPlanReplan bool
+
+var syncPlanCmd = &cobra.Command{
+	Use:   "sync-plan <email>",
+	Short: "Plan a large first sync across days under a daily request budget",
+	Long: `Plan the first full sync of a large Google account so it fits under a
+daily API request budget, and show how far along it is.
+
+The first run counts each calendar's events (which itself takes about one
+request per 2,500 events) and saves a plan: the primary calendar first, then
+the remaining calendars from smallest to largest. Later runs show progress
+and the day each calendar is expected to finish. Use --replan to count again.
+
+Set the budget in config.toml:
+
+  [sync]
+  daily_request_budget = 50000
+
+Every sync - manual or by the daemon - counts its requests against the
+budget and syncs calendars in plan order. When the budget is spent, full
+syncs stop at their last stored page and resume on the next run after
+midnight, so a daily 'calvault sync' or the daemon works through the plan.
+
+Examples:
+  calvault sync-plan you@company.com
+  calvault sync-plan you@company.com --replan`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		email := args[0]
+
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+
+		src, err := s.GetSourceByIdentifier(email)
+		if err != nil {
+			return fmt.Errorf("get source: %w", err)
+		}
+		if src != nil && src.SourceType != store.SourceTypeGoogle {
+			return fmt.Errorf("account %s is an %s source; sync plans are for Google accounts", email, src.SourceType)
+		}
+		managers := oauthManagers{}
+		oauthMgr, err := managers.get(store.SourceTypeGoogle)
+		if err != nil {
+			return err
+		}
+		if !oauthMgr.HasToken(email) {
+			return fmt.Errorf("no OAuth token for %s - run 'add-account' first", email)
+		}
+		if src == nil {
+			if src, err = s.GetOrCreateSource(email); err != nil {
+				return fmt.Errorf("create source: %w", err)
+			}
+		}
+
+		plan, err := s.GetSyncPlan(src.ID)
+		if err != nil {
+			return err
+		}
+		if len(plan) == 0 || syncPlanReplan {
+			est, err := estimateFullSync(cmd.Context(), s, oauthMgr, src, cfg.Sync.IncludeCalendar)
+			if err != nil {
+				return err
+			}
+			if err := s.ReplaceSyncPlan(src.ID, sync.PlanEntries(est)); err != nil {
+				return err
+			}
+			if plan, err = s.GetSyncPlan(src.ID); err != nil {
+				return err
+			}
+		}
+
+		return printSyncPlan(s, email, plan)
+	},
+}
+
+// printSyncPlan shows each planned calendar's progress and expected finish.
+func printSyncPlan(s *store.Store, email string, plan []*store.SyncPlanEntry) error {
+	budget := syncBudget(s)
+	limit, used := 0, 0
+	if budget != nil {
+		var err error
+		if used, err = budget.Used(); err != nil {
+			return err
+		}
+		limit = budget.Limit()
+	}
+	days := sync.PlanDays(plan, limit, used)
+
+	out.Printf("Sync plan for %s\n\n", out.Accent(email))
+	t := render.NewTable("Calendar", "Events", "Requests", "Progress", "Finishes").AlignRight(1, 2)
+	remaining, last := 0, 0
+	for i, e := range plan {
+		progress, finishes := out.Muted("pending"), planDay(days[i])
+		switch {
+		case e.Done:
+			progress, finishes = out.Good("done"), ""
+		case e.InProgress || e.Requests > 0:
+			progress = fmt.Sprintf("%s requests made", out.Number(int64(e.Requests)))
+		}
+		if !e.Done {
+			last = max(last, days[i])
+		}
+		remaining += e.Remaining()
+		t.Row(oneLine(e.Summary, 40), out.Number(int64(e.EstimatedEvents)), out.Number(int64(e.EstimatedRequests)), progress, finishes)
+	}
+	out.Table(t)
+	out.Println()
+
+	budgetLine := "unlimited (set [sync] daily_request_budget to spread the sync over days)"
+	if limit > 0 {
+		budgetLine = fmt.Sprintf("%s of %s requests used today", out.Number(int64(used)), out.Number(int64(limit)))
+	}
+	complete := "done"
+	if remaining > 0 {
+		complete = planDay(last)
+	}
+	out.KeyValues(
+		"Remaining", fmt.Sprintf("~%s requests", out.Number(int64(remaining))),
+		"Budget", budgetLine,
+		"Complete", out.Accent(complete),
+	)
+	if remaining > 0 {
+		out.Println()
+		out.Println(out.Muted(fmt.Sprintf("Run 'calvault sync %s' daily, or keep the daemon running, to work through the plan.", email)))
+	}
+	return nil
+}
+
+// planDay names the day offset days from today.
+func planDay(days int) string {
+	switch days {
+	case 0:
+		return "today"
+	case 1:
+		return "tomorrow"
+	default:
+		return time.Now().AddDate(0, 0, days).Format("Mon Jan 2")
+	}
+}
+
+func init() {
+	syncPlanCmd.Flags().BoolVar(&syncPlanReplan, "replan", false, "Count events again and replace the plan")
+	rootCmd.AddCommand(syncPlanCmd)
+}
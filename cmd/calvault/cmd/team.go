@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var teamCmd = &cobra.Command{
+	Use:   "team",
+	Short: "Manage account ownership in a team vault",
+	Long: `Manage a team vault: one database that several people sync their
+accounts into.
+
+Team mode is turned on by setting team.member in config.toml to your name.
+Accounts you add or sync are then recorded as yours, and calendars matching
+team.private_calendars are marked private: other members' queries and team
+reports leave them out, along with events marked private.
+
+  [team]
+  member = "alice"
+  private_calendars = ["Personal", "*@group.v.calendar.google.com"]`,
+}
+
+var teamMembersCmd = &cobra.Command{
+	Use:   "members",
+	Short: "List team members and the accounts they own",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := openTeamStore()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = s.Close() }()
+
+		sources, err := s.ListSources()
+		if err != nil {
+			return err
+		}
+		if len(sources) == 0 {
+			out.Println("No accounts. Run 'calvault add-account <email>' to add one.")
+			return nil
+		}
+
+		accounts := map[string][]string{}
+		for _, src := range sources {
+			accounts[src.Owner] = append(accounts[src.Owner], src.Identifier)
+		}
+		members := make([]string, 0, len(accounts))
+		for m := range accounts {
+			members = append(members, m)
+		}
+		sort.Slice(members, func(i, j int) bool {
+			// Unassigned accounts last
+			if (members[i] == "") != (members[j] == "") {
+				return members[j] == ""
+			}
+			return members[i] < members[j]
+		})
+
+		t := render.NewTable("Member", "Accounts")
+		for _, m := range members {
+			label := teamMemberLabel(m)
+			if m != "" && m == cfg.Team.Member {
+				label += out.Muted(" (you)")
+			}
+			t.Row(label, strings.Join(accounts[m], ", "))
+		}
+		out.Table(t)
+		return nil
+	},
+}
+
+var teamAssignCmd = &cobra.Command{
+	Use:   "assign <account> <member>",
+	Short: "Assign an account to a team member",
+	Long: `Record which team member an account belongs to, e.g. for accounts
+added before team mode was turned on. Use 'team unassign' to clear it.
+
+Examples:
+  calvault team assign bob@company.com bob`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setTeamOwner(args[0], args[1])
+	},
+}
+
+var teamUnassignCmd = &cobra.Command{
+	Use:   "unassign <account>",
+	Short: "Clear the team member an account belongs to",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setTeamOwner(args[0], "")
+	},
+}
+
+// setTeamOwner assigns an account to member, or unassigns it if member is "".
+func setTeamOwner(email, member string) error {
+	s, err := openTeamStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = s.Close() }()
+
+	src, err := s.GetSourceByIdentifier(email)
+	if err != nil {
+		return fmt.Errorf("get source: %w", err)
+	}
+	if src == nil {
+		return fmt.Errorf("account %q not found", email)
+	}
+	if err := s.SetSourceOwner(src.ID, member); err != nil {
+		return err
+	}
+
+	if member == "" {
+		out.Printf("Account %s is no longer assigned.\n", out.Accent(email))
+	} else {
+		out.Printf("Account %s now belongs to %s.\n", out.Accent(email), out.Accent(member))
+	}
+	return nil
+}
+
+func openTeamStore() (*store.Store, error) {
+	s, err := store.Open(cfg.DatabasePath())
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	if err := s.InitSchema(); err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("init schema: %w", err)
+	}
+	return s, nil
+}
+
+// teamMemberLabel formats an account owner, showing unassigned accounts muted.
+func teamMemberLabel(owner string) string {
+	if owner == "" {
+		return out.Muted("(unassigned)")
+	}
+	return owner
+}
+
+func init() {
+	teamCmd.AddCommand(teamMembersCmd)
+	teamCmd.AddCommand(teamAssignCmd)
+	teamCmd.AddCommand(teamUnassignCmd)
+	rootCmd.AddCommand(teamCmd)
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo [operation-id]",
+	Short: "Restore data removed by remove-account or purge",
+	Long: `Restore the rows a destructive command removed, from the trash.
+
+remove-account and purge keep the rows they delete in the trash for
+[storage] trash_days (default 30) and print the operation's ID; each daily
+[storage] retention purge is an operation too. Without an ID, undo lists
+the operations still in the trash.
+
+Restoring an account brings back its calendars, events, attendees and sync
+history, but not its OAuth token: run 'calvault add-account' to sync it
+again. An account added again since it was removed must be removed first.
+Restoring a purge brings back its events with their attendees.
+
+Examples:
+  calvault undo
+  calvault undo 3`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := store.Open(cfg.DatabasePath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if err := s.InitSchema(); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+		if err := applyStorageSettings(s); err != nil {
+			return err
+		}
+		if _, err := s.PurgeExpiredTrash(); err != nil {
+			logger.Warn("failed to purge expired trash", "error", err)
+		}
+
+		if len(args) == 0 {
+			ops, err := s.ListTrash()
+			if err != nil {
+				return err
+			}
+			if len(ops) == 0 {
+				out.Println("The trash is empty.")
+				return nil
+			}
+			t := render.NewTable("ID", "Command", "Removed", "Rows", "When", "Expires").AlignRight(0, 3)
+			for _, op := range ops {
+				t.Row(strconv.FormatInt(op.ID, 10), op.Command, out.Accent(op.Description), out.Number(op.Rows),
+					out.DateTime(op.CreatedAt.Local()), out.Date(op.ExpiresAt.Local()))
+			}
+			out.Table(t)
+			return nil
+		}
+
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid operation ID %q", args[0])
+		}
+
+		// Don't restore rows under a running sync
+		lock, err := acquireSyncLock()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = lock.Release() }()
+
+		op, err := s.RestoreTrash(id)
+		if errors.Is(err, store.ErrTrashNotFound) {
+			return fmt.Errorf("operation %d is not in the trash; run 'calvault undo' to list it", id)
+		}
+		if err != nil {
+			return fmt.Errorf("undo: %w", err)
+		}
+		refreshDerivedTables(s)
+
+		logger.Info("restored from trash", "operation", id, "command", op.Command, "rows", op.Rows)
+		out.Printf("%s %s %s (%s rows)\n", out.Good("Restored"), op.Command, out.Accent(op.Description), out.Number(op.Rows))
+		if op.Command == "remove-account" {
+			if src, _ := s.GetSourceByIdentifier(op.Description); src != nil && tokensDirFor(src.SourceType) != "" {
+				out.Println("Its OAuth token isn't restored; add the account again to resume syncing.")
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+}
@@ -0,0 +1,9 @@
+-- My events vs. events from feeds I follow (holidays, sports, shared calendars)
+SELECT
+  COALESCE(c.subscription_kind, 'unknown') as kind,
+  c.summary as calendar,
+  COUNT(e.id) as events
+FROM calendars c
+LEFT JOIN events e ON e.calendar_id = c.id
+GROUP BY c.id
+ORDER BY kind, events DESC;
@@ -0,0 +1,19 @@
+-- Running total of meeting hours, week by week (CTE + window function)
+WITH weekly AS (
+  SELECT
+    strftime('%Y-%W', start_time) AS week,
+    SUM((julianday(end_time) - julianday(start_time)) * 24) AS hours
+  FROM events
+  WHERE start_time IS NOT NULL
+    AND end_time IS NOT NULL
+    AND all_day = FALSE
+    AND COALESCE(status, '') != 'cancelled'
+  GROUP BY week
+)
+SELECT
+  week,
+  ROUND(hours, 1) AS hours,
+  ROUND(SUM(hours) OVER (ORDER BY week), 1) AS running_hours,
+  ROUND(AVG(hours) OVER (ORDER BY week ROWS BETWEEN 3 PRECEDING AND CURRENT ROW), 1) AS avg_4_weeks
+FROM weekly
+ORDER BY week;
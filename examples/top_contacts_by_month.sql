@@ -0,0 +1,24 @@
+-- Top 3 people you met with each month (CTE + RANK window function)
+WITH monthly AS (
+  SELECT
+    strftime('%Y-%m', e.start_time) AS month,
+    lower(a.email) AS email,
+    COUNT(*) AS meetings
+  FROM attendees a
+  JOIN events e ON e.id = a.event_id
+  WHERE a.is_self = FALSE
+    AND e.start_time IS NOT NULL
+  GROUP BY month, lower(a.email)
+),
+ranked AS (
+  SELECT
+    month,
+    email,
+    meetings,
+    RANK() OVER (PARTITION BY month ORDER BY meetings DESC) AS rank
+  FROM monthly
+)
+SELECT month, rank, email, meetings
+FROM ranked
+WHERE rank <= 3
+ORDER BY month DESC, rank;
@@ -7,6 +7,7 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/spf13/cobra v1.8.1
 	golang.org/x/oauth2 v0.21.0
+	golang.org/x/time v0.10.0
 	google.golang.org/api v0.183.0
 )
 
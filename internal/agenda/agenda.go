@@ -0,0 +1,175 @@
+// Package agenda lists the event occurrences in a time window across all
+// accounts, expanding recurring series that are stored as a single event.
+package agenda
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/ics"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// Item is one occurrence in the agenda.
+type Item struct {
+	Event    *store.Event // The stored event; for an expanded occurrence, its series
+	Start    time.Time
+	End      time.Time // Zero if the event has no end time
+	Expanded bool      // Generated from the series' recurrence rule
+}
+
+// List returns the occurrences starting in [from, to), ordered by start.
+// Stored instances and exceptions replace the occurrences they stand for;
+// cancelled events are left out.
+func List(s *store.Store, from, to time.Time) ([]*Item, error) {
+	events, err := s.ListEvents(store.EventFilter{Since: from, Until: to})
+	if err != nil {
+		return nil, err
+	}
+	var items []*Item
+	for _, e := range events {
+		if e.Status == "cancelled" || !e.StartTime.Valid || isSeries(e) {
+			continue
+		}
+		items = append(items, storedItem(e))
+	}
+
+	series, err := s.ListEvents(store.EventFilter{Until: to, SeriesOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range series {
+		if e.Status == "cancelled" || !e.StartTime.Valid {
+			continue
+		}
+		occurrences, err := expand(s, e, from, to)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, occurrences...)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Start.Before(items[j].Start) })
+	return items, nil
+}
+
+// Day returns midnight, local time, of the day the item falls on. All-day
+// events are stored at UTC midnight and keep their calendar date.
+func (it *Item) Day() time.Time {
+	t := it.Start.Local()
+	if it.Event.AllDay {
+		t = it.Start.UTC()
+	}
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// Days returns the occurrences falling on the local days [first, end),
+// where first and end are local midnights.
+func Days(s *store.Store, first, end time.Time) ([]*Item, error) {
+	// All-day events can start on the UTC day before or after a local day
+	items, err := List(s, first.AddDate(0, 0, -1), end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	var days []*Item
+	for _, it := range items {
+		if day := it.Day(); !day.Before(first) && day.Before(end) {
+			days = append(days, it)
+		}
+	}
+	return days, nil
+}
+
+func isSeries(e *store.Event) bool {
+	return e.RecurrenceRule != "" && e.RecurringEventID == ""
+}
+
+func storedItem(e *store.Event) *Item {
+	item := &Item{Event: e, Start: e.StartTime.Time}
+	if e.EndTime.Valid {
+		item.End = e.EndTime.Time
+	}
+	return item
+}
+
+// expand returns a series' occurrences in [from, to) that no stored
+// instance replaces.
+func expand(s *store.Store, series *store.Event, from, to time.Time) ([]*Item, error) {
+	instances, err := s.ListEvents(store.EventFilter{SourceID: series.SourceID, RecurringEventID: series.GoogleEventID})
+	if err != nil {
+		return nil, err
+	}
+	return generate(series, instances, from, to), nil
+}
+
+// generate returns a series' occurrences in [from, to) that none of its
+// stored instances replaces. A rule that can't be parsed leaves just the
+// series' first occurrence.
+func generate(series *store.Event, instances []*store.Event, from, to time.Time) []*Item {
+	rec, err := ics.ParseRecurrence(series.RecurrenceRule)
+	if err != nil {
+		if t := series.StartTime.Time; !t.Before(from) && t.Before(to) {
+			return []*Item{storedItem(series)}
+		}
+		return nil
+	}
+
+	loc := seriesLocation(series)
+	start := series.StartTime.Time.In(loc)
+	var duration time.Duration
+	if series.EndTime.Valid {
+		duration = series.EndTime.Time.Sub(series.StartTime.Time)
+	}
+
+	replaced := map[string]bool{}
+	for _, inst := range instances {
+		replaced[originalStartKey(inst, series.GoogleEventID, loc)] = true
+	}
+
+	var items []*Item
+	for _, t := range rec.Between(start, from, to) {
+		if replaced[t.UTC().Format(time.RFC3339)] || replaced[t.Format("20060102")] {
+			continue
+		}
+		item := &Item{Event: series, Start: t, Expanded: true}
+		if series.EndTime.Valid {
+			item.End = t.Add(duration)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// seriesLocation returns the time zone a series recurs in. All-day events
+// are stored as UTC dates; unknown zones (e.g. Windows names) fall back to
+// UTC.
+func seriesLocation(e *store.Event) *time.Location {
+	if e.AllDay || e.OriginalTimezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(e.OriginalTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// originalStartKey identifies the occurrence an instance replaces: its
+// original start, from the "<series>_<recurrence-id>" IDs Google and ICS
+// import give instances, or else the day the instance starts on.
+func originalStartKey(inst *store.Event, seriesID string, loc *time.Location) string {
+	if suffix, ok := strings.CutPrefix(inst.GoogleEventID, seriesID+"_"); ok {
+		if t, err := time.Parse("20060102T150405Z", suffix); err == nil {
+			return t.Format(time.RFC3339)
+		}
+		if t, err := time.ParseInLocation("20060102T150405", suffix, loc); err == nil {
+			return t.UTC().Format(time.RFC3339)
+		}
+		if _, err := time.Parse("20060102", suffix); err == nil {
+			return suffix
+		}
+	}
+	return inst.StartTime.Time.In(loc).Format("20060102")
+}
@@ -0,0 +1,154 @@
+package agenda
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+func TestList(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	at := func(day, hour int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2024, 3, day, hour, 0, 0, 0, time.UTC), Valid: true}
+	}
+	work, _ := s.GetOrCreateSource("work@example.com")
+	home, _ := s.GetOrCreateSource("home@example.com")
+	workCal, _ := s.UpsertCalendar(work.ID, &store.Calendar{GoogleCalendarID: "primary"})
+	homeCal, _ := s.UpsertCalendar(home.ID, &store.Calendar{GoogleCalendarID: "primary"})
+	events := []*store.Event{
+		// Daily standup from Monday 4 March; Wednesday's moved, Thursday's cancelled
+		{SourceID: work.ID, CalendarID: workCal, GoogleEventID: "standup", Summary: "Standup", StartTime: at(4, 9), EndTime: at(4, 10), RecurrenceRule: "RRULE:FREQ=DAILY;COUNT=10\nEXDATE:20240308T090000Z"},
+		{SourceID: work.ID, CalendarID: workCal, GoogleEventID: "standup_20240306T090000Z", Summary: "Standup (late)", StartTime: at(6, 11), EndTime: at(6, 12), RecurringEventID: "standup"},
+		{SourceID: work.ID, CalendarID: workCal, GoogleEventID: "standup_20240307T090000Z", StartTime: at(7, 9), RecurringEventID: "standup", Status: "cancelled"},
+		{SourceID: home.ID, CalendarID: homeCal, GoogleEventID: "dentist", Summary: "Dentist", StartTime: at(5, 8), EndTime: at(5, 9)},
+		{SourceID: home.ID, CalendarID: homeCal, GoogleEventID: "later", Summary: "Later", StartTime: at(20, 8)},
+		// Outside the window, but its series starts before it
+		{SourceID: home.ID, CalendarID: homeCal, GoogleEventID: "gym", Summary: "Gym", StartTime: sql.NullTime{Time: time.Date(2024, 2, 1, 18, 0, 0, 0, time.UTC), Valid: true}, RecurrenceRule: "RRULE:FREQ=WEEKLY;BYDAY=TU"},
+	}
+	for _, e := range events {
+		if _, err := s.UpsertEvent(e); err != nil {
+			t.Fatalf("upsert %s: %v", e.GoogleEventID, err)
+		}
+	}
+
+	items, err := List(s, time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	want := []struct {
+		summary  string
+		start    string
+		expanded bool
+	}{
+		{"Dentist", "03-05 08:00", false},
+		{"Standup", "03-05 09:00", true},
+		{"Gym", "03-05 18:00", true},
+		{"Standup (late)", "03-06 11:00", false},
+	}
+	if len(items) != len(want) {
+		for _, it := range items {
+			t.Logf("%s %s", it.Event.Summary, it.Start)
+		}
+		t.Fatalf("got %d items, want %d", len(items), len(want))
+	}
+	for i, w := range want {
+		it := items[i]
+		if it.Event.Summary != w.summary || it.Start.UTC().Format("01-02 15:04") != w.start || it.Expanded != w.expanded {
+			t.Errorf("item %d = %s at %s (expanded %v), want %s at %s (expanded %v)",
+				i, it.Event.Summary, it.Start.UTC().Format("01-02 15:04"), it.Expanded, w.summary, w.start, w.expanded)
+		}
+	}
+	if got := items[1].End.Sub(items[1].Start); got != time.Hour {
+		t.Errorf("expanded occurrence lasts %v, want 1h", got)
+	}
+}
+
+func TestRebuildInstances(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	at := func(day, hour int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2024, 3, day, hour, 0, 0, 0, time.UTC), Valid: true}
+	}
+	src, _ := s.GetOrCreateSource("work@example.com")
+	cal, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: "primary"})
+	events := []*store.Event{
+		// Daily standup from Monday 4 March; Wednesday's moved, Thursday's cancelled, Friday's excluded
+		{SourceID: src.ID, CalendarID: cal, GoogleEventID: "standup", Summary: "Standup", StartTime: at(4, 9), EndTime: at(4, 10), RecurrenceRule: "RRULE:FREQ=DAILY;COUNT=10\nEXDATE:20240308T090000Z"},
+		{SourceID: src.ID, CalendarID: cal, GoogleEventID: "standup_20240306T090000Z", Summary: "Standup (late)", StartTime: at(6, 11), EndTime: at(6, 12), RecurringEventID: "standup"},
+		{SourceID: src.ID, CalendarID: cal, GoogleEventID: "standup_20240307T090000Z", StartTime: at(7, 9), RecurringEventID: "standup", Status: "cancelled"},
+		// An instance whose series isn't stored
+		{SourceID: src.ID, CalendarID: cal, GoogleEventID: "review_20240305T150000Z", Summary: "Review", StartTime: at(5, 15), RecurringEventID: "review"},
+		{SourceID: src.ID, CalendarID: cal, GoogleEventID: "lunch", Summary: "Lunch", StartTime: at(5, 12)},
+	}
+	for _, e := range events {
+		if _, err := s.UpsertEvent(e); err != nil {
+			t.Fatalf("upsert %s: %v", e.GoogleEventID, err)
+		}
+	}
+
+	n, err := RebuildInstances(s, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	if n != 5 {
+		t.Errorf("rebuild stored %d instances, want 5", n)
+	}
+
+	rows, err := s.DB().Query(`
+		SELECT i.recurring_event_id, i.series_id IS NOT NULL, e.google_event_id, strftime('%m-%d %H:%M', i.start_time), i.expanded
+		FROM event_instances i JOIN events e ON e.id = i.event_id
+		ORDER BY i.start_time
+	`)
+	if err != nil {
+		t.Fatalf("query instances: %v", err)
+	}
+	defer func() { _ = rows.Close() }()
+	type instance struct {
+		series    string
+		hasSeries bool
+		event     string
+		start     string
+		expanded  bool
+	}
+	want := []instance{
+		{"standup", true, "standup", "03-04 09:00", true},
+		{"standup", true, "standup", "03-05 09:00", true},
+		{"review", false, "review_20240305T150000Z", "03-05 15:00", false},
+		{"standup", true, "standup_20240306T090000Z", "03-06 11:00", false},
+		{"standup", true, "standup", "03-09 09:00", true},
+	}
+	var got []instance
+	for rows.Next() {
+		var i instance
+		if err := rows.Scan(&i.series, &i.hasSeries, &i.event, &i.start, &i.expanded); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		got = append(got, i)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got instances %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("instance %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package agenda
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// RebuildInstances fills event_instances with every occurrence of the
+// stored recurring series that starts before until: the series' own
+// occurrences, leaving out EXDATEs and those replaced by a stored instance,
+// plus the stored instances and exceptions themselves. Cancelled events are
+// left out. It returns the number of occurrences stored.
+func RebuildInstances(s *store.Store, until time.Time) (int, error) {
+	stored, err := s.ListEvents(store.EventFilter{Until: until, InstancesOnly: true})
+	if err != nil {
+		return 0, err
+	}
+	type seriesKey struct {
+		sourceID int64
+		id       string
+	}
+	bySeries := make(map[seriesKey][]*store.Event)
+	for _, inst := range stored {
+		k := seriesKey{inst.SourceID, inst.RecurringEventID}
+		bySeries[k] = append(bySeries[k], inst)
+	}
+
+	series, err := s.ListEvents(store.EventFilter{Until: until, SeriesOnly: true})
+	if err != nil {
+		return 0, err
+	}
+	seriesIDs := make(map[seriesKey]int64, len(series))
+	var rows []*store.EventInstance
+	for _, e := range series {
+		if e.Status == "cancelled" || !e.StartTime.Valid {
+			continue
+		}
+		k := seriesKey{e.SourceID, e.GoogleEventID}
+		seriesIDs[k] = e.ID
+		for _, it := range generate(e, bySeries[k], time.Time{}, until) {
+			rows = append(rows, instanceRow(e, e.ID, it))
+		}
+	}
+
+	for _, inst := range stored {
+		if inst.Status == "cancelled" || !inst.StartTime.Valid {
+			continue
+		}
+		var seriesID int64
+		if id, ok := seriesIDs[seriesKey{inst.SourceID, inst.RecurringEventID}]; ok {
+			seriesID = id
+		}
+		rows = append(rows, instanceRow(inst, seriesID, storedItem(inst)))
+	}
+
+	if err := s.ReplaceEventInstances(rows); err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+// instanceRow turns an occurrence of the series identified by seriesID (0
+// if it isn't stored) into an event_instances row.
+func instanceRow(e *store.Event, seriesID int64, it *Item) *store.EventInstance {
+	recurringID := e.RecurringEventID
+	if recurringID == "" {
+		recurringID = e.GoogleEventID
+	}
+	row := &store.EventInstance{
+		SourceID:         e.SourceID,
+		RecurringEventID: recurringID,
+		SeriesID:         sql.NullInt64{Int64: seriesID, Valid: seriesID != 0},
+		EventID:          it.Event.ID,
+		Start:            sql.NullTime{Time: it.Start.UTC(), Valid: true},
+		AllDay:           e.AllDay,
+		Expanded:         it.Expanded,
+	}
+	if !it.End.IsZero() {
+		row.End = sql.NullTime{Time: it.End.UTC(), Valid: true}
+	}
+	return row
+}
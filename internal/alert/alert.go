@@ -0,0 +1,359 @@
+// Package alert checks keyword rules against the archive after a sync and
+// notifies, by webhook, command or desktop notification, about upcoming events the sync added,
+// changed or cancelled - "tell me when a dentist appointment moves".
+package alert
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// Changes an alert can report.
+const (
+	Added     = "added"
+	Changed   = "changed"
+	Cancelled = "cancelled"
+
+	// Moved is a Rule.On value matching changed events whose start or end
+	// moved.
+	Moved = "moved"
+)
+
+// Rule is an alert rule: which events it watches and where it notifies.
+type Rule struct {
+	Name     string
+	Keywords []string
+
+	// IncludeCalendar reports whether the rule watches a calendar; nil
+	// watches all of them.
+	IncludeCalendar func(id, name string) bool
+
+	// On lists the changes that alert; empty means all of them.
+	On []string
+
+	Webhook string // URL the alert is POSTed to as JSON
+	Command string // Shell command run with the alert in its environment
+	Desktop bool   // Raise a native desktop notification
+}
+
+// alertsOn reports whether the rule alerts about a change.
+func (r *Rule) alertsOn(a *Alert) bool {
+	if len(r.On) == 0 {
+		return true
+	}
+	for _, on := range r.On {
+		if on == a.Change || (on == Moved && a.moved()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Alert is a notification about one event, as sent to webhooks.
+type Alert struct {
+	Rule    string         `json:"rule"`
+	Change  string         `json:"change"` // added, changed or cancelled
+	Event   Event          `json:"event"`
+	Changes []*FieldChange `json:"changes,omitempty"` // For changed events
+}
+
+// Event is the alerted event.
+type Event struct {
+	ID       int64  `json:"id"`
+	Title    string `json:"title"`
+	Start    string `json:"start"` // RFC 3339, or YYYY-MM-DD for all-day events
+	End      string `json:"end"`
+	AllDay   bool   `json:"all_day"`
+	Location string `json:"location,omitempty"`
+	Status   string `json:"status"`
+	Calendar string `json:"calendar"`
+	Account  string `json:"account"`
+}
+
+// moved reports whether the alert is for a changed event whose start or
+// end moved.
+func (a *Alert) moved() bool {
+	if a.Change != Changed {
+		return false
+	}
+	for _, c := range a.Changes {
+		if c.Field == "start" || c.Field == "end" {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldChange is a field of a changed event with its earlier value.
+type FieldChange struct {
+	Field  string `json:"field"` // title, location, start, end or status
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// Checker checks rules against a store and sends their alerts.
+type Checker struct {
+	store  *store.Store
+	rules  []Rule
+	logger *slog.Logger
+	http   *http.Client
+}
+
+// NewChecker creates a checker for rules.
+func NewChecker(s *store.Store, rules []Rule) *Checker {
+	return &Checker{
+		store:  s,
+		rules:  rules,
+		logger: slog.Default(),
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// WithLogger sets the logger.
+func (c *Checker) WithLogger(logger *slog.Logger) *Checker {
+	c.logger = logger
+	return c
+}
+
+// Check compares each rule's matching events with what the rule saw last
+// time and sends an alert for every change it alerts on. A rule's first
+// check only records the events matching then. Alerts that fail to send
+// are retried by the next check. Check returns the alerts sent.
+func (c *Checker) Check(ctx context.Context) ([]*Alert, error) {
+	calendars, err := c.calendars()
+	if err != nil {
+		return nil, err
+	}
+	var sent []*Alert
+	var failed int
+	for i := range c.rules {
+		r := &c.rules[i]
+		alerts, err := c.checkRule(ctx, r, calendars, time.Now())
+		sent = append(sent, alerts...)
+		if err != nil {
+			c.logger.Error("alert rule failed", "rule", r.Name, "error", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return sent, fmt.Errorf("%d alert rule(s) failed", failed)
+	}
+	return sent, nil
+}
+
+// calendarInfo names a calendar for rules and alerts.
+type calendarInfo struct {
+	id, name, account string
+}
+
+// calendars maps stored calendar IDs to their names and accounts.
+func (c *Checker) calendars() (map[int64]calendarInfo, error) {
+	sources, err := c.store.ListSources()
+	if err != nil {
+		return nil, err
+	}
+	calendars := make(map[int64]calendarInfo)
+	for _, src := range sources {
+		cals, err := c.store.GetCalendars(src.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, cal := range cals {
+			calendars[cal.ID] = calendarInfo{id: cal.GoogleCalendarID, name: cal.Summary, account: src.Identifier}
+		}
+	}
+	return calendars, nil
+}
+
+func (c *Checker) checkRule(ctx context.Context, r *Rule, calendars map[int64]calendarInfo, now time.Time) ([]*Alert, error) {
+	first, err := c.store.StartAlertRule(r.Name)
+	if err != nil {
+		return nil, err
+	}
+	seen, err := c.store.GetAlertSeen(r.Name)
+	if err != nil {
+		return nil, err
+	}
+	events, err := c.store.AlertMatches(r.Keywords, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var sent []*Alert
+	var failed int
+	for _, e := range events {
+		cal := calendars[e.CalendarID]
+		if r.IncludeCalendar != nil && !r.IncludeCalendar(cal.id, cal.name) {
+			continue
+		}
+		var alert *Alert
+		if !first {
+			alert = diff(r.Name, seen[e.ID], e)
+		}
+		if alert != nil && r.alertsOn(alert) {
+			alert.Event.Calendar = cal.name
+			alert.Event.Account = cal.account
+			if err := c.send(ctx, r, alert); err != nil {
+				c.logger.Error("failed to send alert", "rule", r.Name, "event_id", e.ID, "error", err)
+				failed++
+				continue
+			}
+			c.logger.Info("sent alert", "rule", r.Name, "change", alert.Change, "event_id", e.ID, "title", e.Summary)
+			sent = append(sent, alert)
+		} else {
+			alert = nil
+		}
+		if err := c.store.RecordAlertSeen(r.Name, e, alert != nil); err != nil {
+			return sent, err
+		}
+	}
+	if failed > 0 {
+		return sent, fmt.Errorf("%d alert(s) failed to send", failed)
+	}
+	return sent, nil
+}
+
+// diff returns the alert for an event given how the rule last saw it, or
+// nil if nothing changed. Events first seen cancelled don't alert.
+func diff(rule string, before *store.AlertSeen, e *store.Event) *Alert {
+	cancelled := e.Status == "cancelled"
+	alert := &Alert{Rule: rule, Event: Event{
+		ID:       e.ID,
+		Title:    e.Summary,
+		Start:    formatTime(e.StartTime, e.AllDay),
+		End:      formatTime(e.EndTime, e.AllDay),
+		AllDay:   e.AllDay,
+		Location: e.Location,
+		Status:   status(e.Status),
+	}}
+	switch {
+	case before == nil && cancelled:
+		return nil
+	case before == nil:
+		alert.Change = Added
+		return alert
+	case cancelled && before.Status != "cancelled":
+		alert.Change = Cancelled
+		return alert
+	}
+
+	fields := [][3]string{
+		{"title", before.Summary, e.Summary},
+		{"location", before.Location, e.Location},
+		{"start", formatTime(before.StartTime, e.AllDay), alert.Event.Start},
+		{"end", formatTime(before.EndTime, e.AllDay), alert.Event.End},
+		{"status", status(before.Status), alert.Event.Status},
+	}
+	for _, f := range fields {
+		if f[1] != f[2] {
+			alert.Changes = append(alert.Changes, &FieldChange{Field: f[0], Before: f[1], After: f[2]})
+		}
+	}
+	if len(alert.Changes) == 0 {
+		return nil
+	}
+	alert.Change = Changed
+	return alert
+}
+
+// status returns an event status, defaulting to confirmed.
+func status(s string) string {
+	if s == "" {
+		return "confirmed"
+	}
+	return s
+}
+
+// formatTime formats an event time: RFC 3339, or YYYY-MM-DD for all-day
+// events.
+func formatTime(t sql.NullTime, allDay bool) string {
+	switch {
+	case !t.Valid:
+		return ""
+	case allDay:
+		return t.Time.UTC().Format("2006-01-02")
+	default:
+		return t.Time.Format(time.RFC3339)
+	}
+}
+
+// send delivers an alert to the rule's webhook, command and desktop.
+func (c *Checker) send(ctx context.Context, r *Rule, a *Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	if r.Webhook != "" {
+		if err := c.post(ctx, r.Webhook, body); err != nil {
+			return err
+		}
+	}
+	if r.Command != "" {
+		if err := runCommand(ctx, r.Command, a, body); err != nil {
+			return err
+		}
+	}
+	if r.Desktop {
+		if err := notifyDesktop(ctx, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// post sends an alert to a webhook.
+func (c *Checker) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// runCommand runs an alert's command through the shell, with the alert as
+// JSON on stdin and its main fields in CALVAULT_ALERT_* variables.
+func runCommand(ctx context.Context, command string, a *Alert, body []byte) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"CALVAULT_ALERT_RULE="+a.Rule,
+		"CALVAULT_ALERT_CHANGE="+a.Change,
+		"CALVAULT_ALERT_TITLE="+a.Event.Title,
+		"CALVAULT_ALERT_START="+a.Event.Start,
+		"CALVAULT_ALERT_END="+a.Event.End,
+		"CALVAULT_ALERT_LOCATION="+a.Event.Location,
+		"CALVAULT_ALERT_CALENDAR="+a.Event.Calendar,
+		fmt.Sprintf("CALVAULT_ALERT_EVENT_ID=%d", a.Event.ID),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("command %q: %w: %s", command, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
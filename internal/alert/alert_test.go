@@ -0,0 +1,228 @@
+package alert
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+func TestChecker(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	var mu sync.Mutex
+	var received []string
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		var a Alert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			t.Errorf("decode alert: %v", err)
+		}
+		line := fmt.Sprintf("%s %s %s", a.Change, a.Event.Title, a.Event.Calendar)
+		for _, c := range a.Changes {
+			line += fmt.Sprintf(" %s:%s->%s", c.Field, c.Before, c.After)
+		}
+		received = append(received, line)
+	}))
+	defer server.Close()
+	take := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		got := strings.Join(received, "; ")
+		received = nil
+		return got
+	}
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: "primary", Summary: "Personal"})
+	workID, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: "work", Summary: "Work"})
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Hour).UTC()
+	at := func(t time.Time) sql.NullTime { return sql.NullTime{Time: t, Valid: true} }
+	put := func(id, summary, status string, cal int64, start time.Time) {
+		t.Helper()
+		_, err := s.UpsertEvent(&store.Event{
+			SourceID: src.ID, CalendarID: cal, GoogleEventID: id, Summary: summary, Status: status,
+			StartTime: at(start), EndTime: at(start.Add(time.Hour)),
+		})
+		if err != nil {
+			t.Fatalf("upsert %s: %v", id, err)
+		}
+	}
+	put("existing", "Dentist checkup", "confirmed", calID, start)
+	put("past", "Dentist", "confirmed", calID, start.AddDate(0, 0, -10))
+
+	c := NewChecker(s, []Rule{{
+		Name:            "dentist",
+		Keywords:        []string{"DENTIST"},
+		IncludeCalendar: func(id, name string) bool { return id != "work" },
+		Webhook:         server.URL,
+	}})
+	check := func() {
+		t.Helper()
+		if _, err := c.Check(context.Background()); err != nil {
+			t.Fatalf("check: %v", err)
+		}
+	}
+
+	// The first check only records what matches
+	check()
+	if got := take(); got != "" {
+		t.Errorf("first check sent %q, want nothing", got)
+	}
+
+	put("new", "Kids to the dentist", "confirmed", calID, start.Add(24*time.Hour))
+	put("work", "Dentist conference", "confirmed", workID, start)
+	put("other", "Standup", "confirmed", calID, start)
+	check()
+	if got, want := take(), "added Kids to the dentist Personal"; got != want {
+		t.Errorf("after adding: %q, want %q", got, want)
+	}
+
+	check()
+	if got := take(); got != "" {
+		t.Errorf("unchanged check sent %q, want nothing", got)
+	}
+
+	moved := start.Add(2 * time.Hour)
+	put("existing", "Dentist checkup", "confirmed", calID, moved)
+	check()
+	want := fmt.Sprintf("changed Dentist checkup Personal start:%s->%s end:%s->%s",
+		start.Format(time.RFC3339), moved.Format(time.RFC3339),
+		start.Add(time.Hour).Format(time.RFC3339), moved.Add(time.Hour).Format(time.RFC3339))
+	if got := take(); got != want {
+		t.Errorf("after moving: %q, want %q", got, want)
+	}
+
+	// Failed alerts are sent again by the next check
+	put("new", "Kids to the dentist", "cancelled", calID, start.Add(24*time.Hour))
+	mu.Lock()
+	fail = true
+	mu.Unlock()
+	if _, err := c.Check(context.Background()); err == nil {
+		t.Error("check with a failing webhook succeeded")
+	}
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	check()
+	if got, want := take(), "cancelled Kids to the dentist Personal"; got != want {
+		t.Errorf("after cancelling: %q, want %q", got, want)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	start := sql.NullTime{Time: time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC), Valid: true}
+	event := &store.Event{ID: 1, Summary: "Dentist", Location: "Main St", StartTime: start, Status: "confirmed"}
+	tests := []struct {
+		name   string
+		before *store.AlertSeen
+		event  *store.Event
+		want   string // change, "" for none
+	}{
+		{"new", nil, event, Added},
+		{"new cancelled", nil, &store.Event{Status: "cancelled"}, ""},
+		{"unchanged", &store.AlertSeen{Summary: "Dentist", Location: "Main St", StartTime: start}, event, ""},
+		{"relocated", &store.AlertSeen{Summary: "Dentist", Location: "High St", StartTime: start}, event, Changed},
+		{"cancelled", &store.AlertSeen{Summary: "Dentist", Status: "confirmed"}, &store.Event{Summary: "Dentist", Status: "cancelled"}, Cancelled},
+		{"still cancelled", &store.AlertSeen{Summary: "Dentist", Status: "cancelled"}, &store.Event{Summary: "Dentist", Status: "cancelled"}, ""},
+	}
+	for _, tt := range tests {
+		got := diff("r", tt.before, tt.event)
+		change := ""
+		if got != nil {
+			change = got.Change
+		}
+		if change != tt.want {
+			t.Errorf("%s: change = %q, want %q", tt.name, change, tt.want)
+		}
+	}
+}
+
+func TestRule_AlertsOnMoved(t *testing.T) {
+	moved := &Alert{Change: Changed, Changes: []*FieldChange{{Field: "start"}, {Field: "end"}}}
+	renamed := &Alert{Change: Changed, Changes: []*FieldChange{{Field: "title"}}}
+	r := &Rule{On: []string{Added, Moved}}
+	if !r.alertsOn(moved) {
+		t.Error("rule on moved doesn't alert on a moved event")
+	}
+	if r.alertsOn(renamed) {
+		t.Error("rule on moved alerts on a renamed event")
+	}
+	if !r.alertsOn(&Alert{Change: Added}) {
+		t.Error("rule on added doesn't alert on an added event")
+	}
+}
+
+func TestDesktopMessage(t *testing.T) {
+	start := time.Date(2024, 3, 7, 9, 0, 0, 0, time.Local)
+	before := time.Date(2024, 3, 5, 9, 0, 0, 0, time.Local)
+	event := Event{Title: "Dentist", Start: start.Format(time.RFC3339), Calendar: "Personal"}
+	tests := []struct {
+		name      string
+		alert     *Alert
+		wantTitle string
+		wantBody  string
+	}{
+		{"added", &Alert{Change: Added, Event: event}, "New event: Dentist", "Thu Mar 7 09:00\nPersonal"},
+		{"moved", &Alert{Change: Changed, Event: event, Changes: []*FieldChange{{Field: "start", Before: before.Format(time.RFC3339)}}},
+			"Moved: Dentist", "Tue Mar 5 09:00 → Thu Mar 7 09:00\nPersonal"},
+		{"relocated", &Alert{Change: Changed, Event: event, Changes: []*FieldChange{{Field: "location"}}},
+			"Changed: Dentist", "Thu Mar 7 09:00\nChanged location\nPersonal"},
+		{"cancelled all-day", &Alert{Change: Cancelled, Event: Event{Title: "Offsite", Start: "2024-03-07", AllDay: true}},
+			"Cancelled: Offsite", "Thu Mar 7"},
+	}
+	for _, tt := range tests {
+		title, body := desktopMessage(tt.alert)
+		if title != tt.wantTitle || body != tt.wantBody {
+			t.Errorf("%s: message = %q, %q; want %q, %q", tt.name, title, body, tt.wantTitle, tt.wantBody)
+		}
+	}
+}
+
+func TestDesktopCommand(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		goos string
+		want []string // Arguments, without the script on Windows
+	}{
+		{"darwin", []string{"osascript", "-e", "on run argv", "-e", "display notification (item 2 of argv) with title (item 1 of argv)", "-e", "end run", "Moved: Dentist", "Thu 'noon'"}},
+		{"linux", []string{"notify-send", "--app-name=calvault", "Moved: Dentist", "Thu 'noon'"}},
+		{"windows", []string{"powershell", "-NoProfile", "-NonInteractive", "-Command"}},
+	}
+	for _, tt := range tests {
+		cmd := desktopCommand(ctx, tt.goos, "Moved: Dentist", "Thu 'noon'")
+		got := cmd.Args
+		if tt.goos == "windows" {
+			got = got[:len(got)-1]
+			env := strings.Join(cmd.Env, "\n")
+			if !strings.Contains(env, "CALVAULT_NOTIFY_TITLE=Moved: Dentist\n") || !strings.Contains(env, "CALVAULT_NOTIFY_BODY=Thu 'noon'") {
+				t.Errorf("windows: title and body missing from the environment")
+			}
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: args = %q, want %q", tt.goos, got, tt.want)
+		}
+	}
+}
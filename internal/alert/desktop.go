@@ -0,0 +1,103 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// windowsToastScript shows a toast with the title and body from the
+// environment, under PowerShell's app ID since unpackaged programs can't
+// register their own.
+const windowsToastScript = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$t = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$x = $t.GetElementsByTagName('text')
+$x.Item(0).AppendChild($t.CreateTextNode($env:CALVAULT_NOTIFY_TITLE)) > $null
+$x.Item(1).AppendChild($t.CreateTextNode($env:CALVAULT_NOTIFY_BODY)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe').Show([Windows.UI.Notifications.ToastNotification]::new($t))`
+
+// desktopCommand returns the command that raises a native notification on
+// goos: osascript on macOS, a PowerShell toast on Windows and notify-send
+// elsewhere. The title and body are passed as arguments or environment,
+// never through a shell, so event titles need no quoting.
+func desktopCommand(ctx context.Context, goos, title, body string) *exec.Cmd {
+	switch goos {
+	case "darwin":
+		return exec.CommandContext(ctx, "osascript",
+			"-e", "on run argv",
+			"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+			"-e", "end run",
+			title, body)
+	case "windows":
+		cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", windowsToastScript)
+		cmd.Env = append(os.Environ(), "CALVAULT_NOTIFY_TITLE="+title, "CALVAULT_NOTIFY_BODY="+body)
+		return cmd
+	default:
+		return exec.CommandContext(ctx, "notify-send", "--app-name=calvault", title, body)
+	}
+}
+
+// notifyDesktop raises a desktop notification for an alert.
+func notifyDesktop(ctx context.Context, a *Alert) error {
+	title, body := desktopMessage(a)
+	cmd := desktopCommand(ctx, runtime.GOOS, title, body)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("desktop notification: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// desktopMessage returns the title and body of an alert's notification:
+// the change and event title, then when it is - where it moved from, for
+// moved events - and its calendar.
+func desktopMessage(a *Alert) (title, body string) {
+	when := displayTime(a.Event.Start, a.Event.AllDay)
+	switch {
+	case a.Change == Added:
+		title = "New event: " + a.Event.Title
+	case a.Change == Cancelled:
+		title = "Cancelled: " + a.Event.Title
+	case a.moved():
+		title = "Moved: " + a.Event.Title
+		for _, c := range a.Changes {
+			if c.Field == "start" {
+				when = displayTime(c.Before, a.Event.AllDay) + " → " + when
+			}
+		}
+	default:
+		title = "Changed: " + a.Event.Title
+	}
+
+	lines := []string{when}
+	if a.Change == Changed && !a.moved() {
+		var fields []string
+		for _, c := range a.Changes {
+			fields = append(fields, c.Field)
+		}
+		lines = append(lines, "Changed "+strings.Join(fields, ", "))
+	}
+	if a.Event.Calendar != "" {
+		lines = append(lines, a.Event.Calendar)
+	}
+	return title, strings.Join(lines, "\n")
+}
+
+// displayTime formats an alert time for people in local time, leaving
+// values it can't parse as they are.
+func displayTime(s string, allDay bool) string {
+	if allDay {
+		if t, err := time.Parse("2006-01-02", s); err == nil {
+			return t.Format("Mon Jan 2")
+		}
+		return s
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.Local().Format("Mon Jan 2 15:04")
+	}
+	return s
+}
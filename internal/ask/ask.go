@@ -0,0 +1,182 @@
+// Package ask answers natural-language questions about the archive: a model
+// writes SQL from the schema, the read-only query executor runs it, and the
+// model phrases the answer from the result.
+package ask
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/query"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+const (
+	// maxAttempts bounds how often a failing query is sent back to the
+	// model for correction, counting the first try.
+	maxAttempts = 2
+
+	// maxResultRows caps the rows shown to the model when phrasing the answer.
+	maxResultRows = 50
+)
+
+// Answer is a question answered from the archive.
+type Answer struct {
+	Question string
+	SQL      string
+	Result   *query.QueryResult
+	Text     string // The model's answer, phrased from Result
+}
+
+// Ask has the model write SQL for question against schema, runs it on exec,
+// and has the model phrase the answer. A query that fails to run is sent
+// back once with its error. On failure the returned answer still holds the
+// last SQL tried, if any.
+func Ask(ctx context.Context, c *Client, exec *query.Executor, schema, question string) (*Answer, error) {
+	answer := &Answer{Question: question}
+	messages := []Message{
+		{Role: "system", Content: sqlPrompt(schema, time.Now())},
+		{Role: "user", Content: question},
+	}
+	for attempt := 1; ; attempt++ {
+		reply, err := c.Complete(ctx, messages)
+		if err != nil {
+			return answer, fmt.Errorf("generate SQL: %w", err)
+		}
+		answer.SQL, err = ExtractSQL(reply)
+		if err == nil {
+			answer.Result, err = exec.Execute(ctx, answer.SQL)
+		}
+		if err == nil {
+			break
+		}
+		if attempt == maxAttempts || ctx.Err() != nil {
+			return answer, err
+		}
+		messages = append(messages,
+			Message{Role: "assistant", Content: reply},
+			Message{Role: "user", Content: fmt.Sprintf("That failed: %v\nReply with a corrected query.", err)},
+		)
+	}
+
+	text, err := c.Complete(ctx, []Message{
+		{Role: "system", Content: answerPrompt},
+		{Role: "user", Content: resultPrompt(question, answer.SQL, answer.Result)},
+	})
+	if err != nil {
+		return answer, fmt.Errorf("phrase answer: %w", err)
+	}
+	answer.Text = strings.TrimSpace(text)
+	return answer, nil
+}
+
+// Schema returns the CREATE statements of the tables and views the model
+// may query. Full-text index tables are left out.
+func Schema(objects []*store.SchemaObject) string {
+	var b strings.Builder
+	for _, o := range objects {
+		if o.Type != "table" && o.Type != "view" {
+			continue
+		}
+		if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(o.SQL)), "CREATE VIRTUAL") {
+			continue
+		}
+		fmt.Fprintf(&b, "%s;\n\n", strings.TrimSpace(o.SQL))
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// sqlFence matches a fenced code block, with or without a language tag.
+var sqlFence = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*\\n(.*?)```")
+
+// ExtractSQL returns the query in a model's reply: the first fenced code
+// block, or the whole reply if it is a bare SELECT or WITH statement.
+func ExtractSQL(reply string) (string, error) {
+	sql := strings.TrimSpace(reply)
+	if m := sqlFence.FindStringSubmatch(reply); m != nil {
+		sql = strings.TrimSpace(m[1])
+	} else {
+		upper := strings.ToUpper(sql)
+		if !strings.HasPrefix(upper, "SELECT") && !strings.HasPrefix(upper, "WITH") {
+			return "", fmt.Errorf("model did not reply with a query: %s", firstLine(reply))
+		}
+	}
+	sql = strings.TrimSpace(strings.TrimRight(sql, "; \n\t"))
+	if sql == "" {
+		return "", fmt.Errorf("model replied with an empty query")
+	}
+	return sql, nil
+}
+
+// sqlPrompt instructs the model to write one query against schema.
+func sqlPrompt(schema string, now time.Time) string {
+	zone, offset := now.Zone()
+	return fmt.Sprintf(`You write SQLite queries against a calendar archive to answer the user's question.
+
+Reply with exactly one read-only query (SELECT, optionally starting with WITH) in a `+"```sql"+` code block, and nothing else. Give result columns clear names.
+
+Today is %s; the local time zone is %s (UTC%s). Times such as events.start_time are ISO 8601 text with a UTC offset: compare them with date() and datetime() values, and use strftime() and julianday() for arithmetic. events.duration_minutes, start_date, start_hour and weekday (0 = Sunday) are precomputed in the event's own time zone; prefer them for grouping by day, hour or weekday. All-day events (all_day) have start_time at midnight UTC of their date: match them by start_date, not by comparing start_time with a local date. The user appears among an event's attendees with is_self set. Match names, titles and locations case-insensitively with LIKE.
+
+Schema:
+
+%s`, now.Format("Monday, 2006-01-02"), zone, formatOffset(offset), schema)
+}
+
+// answerPrompt instructs the model to answer from a query result.
+const answerPrompt = `You answer the user's question about their calendar from the result of a SQL query run to answer it. Reply in one to three plain sentences, using only facts in the result. If the result is empty, say that nothing matched. Don't mention the SQL.`
+
+// resultPrompt shows the model the question, SQL and result as
+// tab-separated rows.
+func resultPrompt(question, sql string, result *query.QueryResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Question: %s\n\nSQL:\n%s\n\n", question, sql)
+	count := fmt.Sprintf("%d rows", len(result.Rows))
+	if result.Truncated {
+		count = fmt.Sprintf("more than %d rows", len(result.Rows))
+	}
+	shown := result.Rows
+	if len(shown) > maxResultRows {
+		shown = shown[:maxResultRows]
+		fmt.Fprintf(&b, "Result (%s, first %d shown):\n", count, maxResultRows)
+	} else {
+		fmt.Fprintf(&b, "Result (%s):\n", count)
+	}
+	b.WriteString(strings.Join(result.Columns, "\t"))
+	b.WriteByte('\n')
+	for _, row := range shown {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			if v == nil {
+				cells[i] = "NULL"
+			} else {
+				cells[i] = strings.Join(strings.Fields(fmt.Sprint(v)), " ")
+			}
+		}
+		b.WriteString(strings.Join(cells, "\t"))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// formatOffset formats a zone offset in seconds as +HH:MM.
+func formatOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign, seconds = '-', -seconds
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	if len(s) > 200 {
+		s = s[:200] + "..."
+	}
+	return s
+}
@@ -0,0 +1,131 @@
+package ask
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/salman1993/calvault/internal/query"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+func TestAsk(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	s, err := store.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: "primary"})
+	for _, id := range []string{"a", "b"} {
+		if _, err := s.UpsertEvent(&store.Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: id, Summary: "Dermatologist"}); err != nil {
+			t.Fatalf("upsert: %v", err)
+		}
+	}
+	objects, err := s.SchemaObjects()
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	schema := Schema(objects)
+	if !strings.Contains(schema, "CREATE TABLE events") || strings.Contains(schema, "CREATE INDEX") {
+		t.Errorf("schema should list tables without indexes:\n%s", schema)
+	}
+
+	exec, err := query.NewExecutor(dbPath)
+	if err != nil {
+		t.Fatalf("new executor: %v", err)
+	}
+	defer func() { _ = exec.Close() }()
+
+	// The model first writes a broken query, then fixes it
+	replies := []string{
+		"```sql\nSELECT COUNT(*) FROM visits\n```",
+		"```sql\nSELECT COUNT(*) AS visits FROM events WHERE summary LIKE '%dermatologist%';\n```",
+		"You saw your dermatologist 2 times.",
+	}
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			http.Error(w, `{"error":{"message":"bad request"}}`, http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Model    string    `json:"model"`
+			Messages []Message `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Model != "test-model" {
+			http.Error(w, "bad body", http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, body.Messages[len(body.Messages)-1].Content)
+		if len(requests) > len(replies) {
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%q}}]}`, replies[len(requests)-1])
+	}))
+	defer srv.Close()
+
+	client := &Client{Endpoint: srv.URL + "/v1/", Model: "test-model", APIKey: "sk-test"}
+	answer, err := Ask(context.Background(), client, exec, schema, "How often did I see my dermatologist?")
+	if err != nil {
+		t.Fatalf("ask: %v", err)
+	}
+	if answer.SQL != "SELECT COUNT(*) AS visits FROM events WHERE summary LIKE '%dermatologist%'" {
+		t.Errorf("sql = %q", answer.SQL)
+	}
+	if answer.Result.RowCount != 1 || answer.Result.Rows[0][0] != int64(2) {
+		t.Errorf("result rows = %v, want [[2]]", answer.Result.Rows)
+	}
+	if answer.Text != "You saw your dermatologist 2 times." {
+		t.Errorf("text = %q", answer.Text)
+	}
+	if len(requests) != 3 || !strings.Contains(requests[1], "That failed") || !strings.Contains(requests[2], "visits\n2\n") {
+		t.Errorf("requests = %q, want question, correction and result", requests)
+	}
+
+	// A query that keeps failing is given up on, keeping the SQL tried
+	requests, replies = nil, []string{"SELECT * FROM nope", "SELECT * FROM still_nope"}
+	answer, err = Ask(context.Background(), client, exec, schema, "?")
+	if err == nil || answer.SQL != "SELECT * FROM still_nope" {
+		t.Errorf("ask = %q, %v; want error after two attempts", answer.SQL, err)
+	}
+
+	client.APIKey = "wrong"
+	if _, err := Ask(context.Background(), client, exec, schema, "?"); err == nil || !strings.Contains(err.Error(), "bad request") {
+		t.Errorf("ask with bad key: %v, want API error message", err)
+	}
+}
+
+func TestExtractSQL(t *testing.T) {
+	tests := []struct {
+		reply   string
+		want    string
+		wantErr bool
+	}{
+		{"```sql\nSELECT 1;\n```", "SELECT 1", false},
+		{"Here you go:\n```\nWITH x AS (SELECT 1) SELECT * FROM x\n```\nThis counts...", "WITH x AS (SELECT 1) SELECT * FROM x", false},
+		{"  select count(*) from events;  ", "select count(*) from events", false},
+		{"I can't answer that from this schema.", "", true},
+		{"```sql\n\n```", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ExtractSQL(tt.reply)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ExtractSQL(%q) error = %v, wantErr %v", tt.reply, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ExtractSQL(%q) = %q, want %q", tt.reply, got, tt.want)
+		}
+	}
+}
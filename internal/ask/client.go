@@ -0,0 +1,86 @@
+package ask
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxResponse caps the size of a completion response.
+const maxResponse = 4 << 20
+
+// Client calls a chat completions endpoint speaking the OpenAI API.
+type Client struct {
+	Endpoint string // API base URL; /chat/completions is appended
+	Model    string
+	APIKey   string       // Sent as a bearer token if set
+	HTTP     *http.Client // http.DefaultClient if nil
+}
+
+// Message is one chat message.
+type Message struct {
+	Role    string `json:"role"` // system, user or assistant
+	Content string `json:"content"`
+}
+
+// Complete returns the model's reply to messages.
+func (c *Client) Complete(ctx context.Context, messages []Message) (string, error) {
+	body, err := json.Marshal(struct {
+		Model       string    `json:"model"`
+		Messages    []Message `json:"messages"`
+		Temperature float64   `json:"temperature"`
+	}{c.Model, messages, 0})
+	if err != nil {
+		return "", err
+	}
+
+	url := strings.TrimRight(c.Endpoint, "/") + "/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+	if err != nil {
+		return "", fmt.Errorf("read completion: %w", err)
+	}
+	var completion struct {
+		Choices []struct {
+			Message Message `json:"message"`
+		} `json:"choices"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	jsonErr := json.Unmarshal(data, &completion)
+	if resp.StatusCode != http.StatusOK {
+		if jsonErr == nil && completion.Error != nil && completion.Error.Message != "" {
+			return "", fmt.Errorf("%s: %s: %s", url, resp.Status, completion.Error.Message)
+		}
+		return "", fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	if jsonErr != nil {
+		return "", fmt.Errorf("decode completion: %w", jsonErr)
+	}
+	if len(completion.Choices) == 0 {
+		return "", fmt.Errorf("completion has no choices")
+	}
+	return completion.Choices[0].Message.Content, nil
+}
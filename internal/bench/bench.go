@@ -0,0 +1,286 @@
+// Package bench measures the store and the sync pipeline on a demo archive
+// (see package demo): how fast events are written, how long typical
+// queries take on a large archive, and how many events a full sync stores
+// per second from a local fake of the Calendar API. The same number of
+// events and seed give the same archive, so runs can be compared before and
+// after a change.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/salman1993/calvault/internal/demo"
+	"github.com/salman1993/calvault/internal/query"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// Options control a benchmark run.
+type Options struct {
+	Events     int   // Events in the demo archive
+	Seed       int64 // Seed of the demo archive
+	Iterations int   // Runs of each query
+}
+
+// Result is one measurement.
+type Result struct {
+	Name     string
+	Ops      int    // Operations timed
+	Unit     string // What the operations are: events, queries
+	Duration time.Duration
+	P90      time.Duration // 90th percentile per operation, if timed separately
+}
+
+// PerOp returns the mean time per operation.
+func (r Result) PerOp() time.Duration {
+	if r.Ops == 0 {
+		return 0
+	}
+	return r.Duration / time.Duration(r.Ops)
+}
+
+// PerSecond returns the operations per second.
+func (r Result) PerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Ops) / r.Duration.Seconds()
+}
+
+// now is the fixed time demo archives are generated at, so a seed always
+// gives the same archive.
+var now = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// Archive is a demo archive to benchmark against.
+type Archive struct {
+	Store     *store.Store
+	Path      string
+	Calendars []*store.Calendar
+	Writes    []*store.EventWrite // The archive's events as written by a sync
+}
+
+// NewArchive generates a demo archive of the given size in dir and loads
+// its events for writing elsewhere.
+func NewArchive(dir string, events int, seed int64) (*Archive, error) {
+	path := filepath.Join(dir, "archive.db")
+	s, err := newStore(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &Archive{Store: s, Path: path}
+	if _, err := demo.Generate(s, demo.Options{Events: events, Seed: seed, Now: now}); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	if _, err := s.RebuildAnalyticsTables(); err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("rebuild analytics tables: %w", err)
+	}
+	if err := a.load(); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// load reads the archive's calendars and events with their attendees and
+// conferences.
+func (a *Archive) load() error {
+	src, err := a.Store.GetSourceByIdentifier(demo.Account)
+	if err != nil {
+		return fmt.Errorf("get demo account: %w", err)
+	}
+	a.Calendars, err = a.Store.GetCalendars(src.ID)
+	if err != nil {
+		return fmt.Errorf("get calendars: %w", err)
+	}
+	events, err := a.Store.ListEvents(store.EventFilter{SourceID: src.ID})
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		attendees, err := a.Store.ListAttendees(e.ID)
+		if err != nil {
+			return err
+		}
+		conference, err := a.Store.GetConference(e.ID)
+		if err != nil {
+			return err
+		}
+		if conference == nil {
+			conference = &store.Conference{}
+		}
+		a.Writes = append(a.Writes, &store.EventWrite{Event: e, Attendees: attendees, Conference: conference})
+	}
+	return nil
+}
+
+// Close closes the archive's database.
+func (a *Archive) Close() error {
+	return a.Store.Close()
+}
+
+// Upsert writes the archive's events into s in batches of batch events,
+// one transaction each, as a sync stores pages.
+func (a *Archive) Upsert(s *store.Store, batch int) (Result, error) {
+	src, err := s.GetOrCreateSource(demo.Account)
+	if err != nil {
+		return Result{}, fmt.Errorf("create source: %w", err)
+	}
+	calIDs := make(map[int64]int64, len(a.Calendars))
+	for _, cal := range a.Calendars {
+		id, err := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: cal.GoogleCalendarID, Summary: cal.Summary})
+		if err != nil {
+			return Result{}, fmt.Errorf("create calendar: %w", err)
+		}
+		calIDs[cal.ID] = id
+	}
+	writes := make([]*store.EventWrite, len(a.Writes))
+	for i, w := range a.Writes {
+		e := *w.Event
+		e.SourceID, e.CalendarID = src.ID, calIDs[w.Event.CalendarID]
+		writes[i] = &store.EventWrite{Event: &e, Attendees: w.Attendees, Conference: w.Conference}
+	}
+
+	start := time.Now()
+	for i := 0; i < len(writes); i += batch {
+		page := writes[i:min(i+batch, len(writes))]
+		err := s.InTx(func(tx *store.Tx) error {
+			_, err := tx.UpsertEvents(src.ID, page)
+			return err
+		})
+		if err != nil {
+			return Result{}, err
+		}
+	}
+	return Result{Name: "upsert", Ops: len(writes), Unit: "events", Duration: time.Since(start)}, nil
+}
+
+// Query is a query benchmarked against the archive.
+type Query struct {
+	Name string
+	Run  func(ctx context.Context, a *Archive) error
+}
+
+// Queries are typical reads on an archive: listing, filtering and
+// searching events, the analytics reports and SQL through the query
+// executor.
+var Queries = []Query{
+	{"list month", func(ctx context.Context, a *Archive) error {
+		_, err := a.Store.ListEvents(store.EventFilter{Since: now.AddDate(0, -1, 0), Until: now})
+		return err
+	}},
+	{"filter attendee", func(ctx context.Context, a *Archive) error {
+		_, err := a.Store.ListEvents(store.EventFilter{Attendee: "chen", Limit: 100})
+		return err
+	}},
+	{"search", func(ctx context.Context, a *Archive) error {
+		_, err := a.Store.SearchEvents("review", store.SearchOptions{Limit: 50})
+		return err
+	}},
+	{"duration report", func(ctx context.Context, a *Archive) error {
+		_, err := a.Store.GetDurationReport(now.AddDate(-1, 0, 0))
+		return err
+	}},
+	{"sql by month", func(ctx context.Context, a *Archive) error {
+		return a.sql(ctx, `SELECT substr(start_time, 1, 7) AS month, COUNT(*), SUM(duration_minutes) FROM events GROUP BY month ORDER BY month`)
+	}},
+	{"sql top attendees", func(ctx context.Context, a *Archive) error {
+		return a.sql(ctx, `SELECT a.email, COUNT(*) AS n FROM attendees a JOIN events e ON e.id = a.event_id WHERE NOT a.is_self GROUP BY a.email ORDER BY n DESC LIMIT 20`)
+	}},
+}
+
+// sql runs a query through the executor 'calvault query' uses.
+func (a *Archive) sql(ctx context.Context, sql string) error {
+	e, err := query.NewExecutor(a.Path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = e.Close() }()
+	_, err = e.Execute(ctx, sql)
+	return err
+}
+
+// RunQuery times iterations runs of q.
+func (a *Archive) RunQuery(ctx context.Context, q Query, iterations int) (Result, error) {
+	times := make([]time.Duration, 0, iterations)
+	var total time.Duration
+	for i := 0; i < iterations; i++ {
+		start := time.Now()
+		if err := q.Run(ctx, a); err != nil {
+			return Result{}, fmt.Errorf("%s: %w", q.Name, err)
+		}
+		d := time.Since(start)
+		times = append(times, d)
+		total += d
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	r := Result{Name: "query " + q.Name, Ops: iterations, Unit: "queries", Duration: total}
+	if len(times) > 0 {
+		r.P90 = times[len(times)*9/10]
+	}
+	return r, nil
+}
+
+// Run generates a demo archive in dir and reports each measurement as it
+// is made: upsert throughput, query latencies and sync throughput.
+func Run(ctx context.Context, dir string, opts Options, report func(Result)) error {
+	if opts.Iterations <= 0 {
+		opts.Iterations = 20
+	}
+	a, err := NewArchive(dir, opts.Events, opts.Seed)
+	if err != nil {
+		return fmt.Errorf("generate archive: %w", err)
+	}
+	defer func() { _ = a.Close() }()
+
+	dst, err := newStore(filepath.Join(dir, "upsert.db"))
+	if err != nil {
+		return err
+	}
+	r, err := a.Upsert(dst, 250)
+	_ = dst.Close()
+	if err != nil {
+		return fmt.Errorf("upsert: %w", err)
+	}
+	report(r)
+
+	for _, q := range Queries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r, err := a.RunQuery(ctx, q, opts.Iterations)
+		if err != nil {
+			return err
+		}
+		report(r)
+	}
+
+	dst, err = newStore(filepath.Join(dir, "sync.db"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dst.Close() }()
+	r, err = a.Sync(ctx, dst)
+	if err != nil {
+		return fmt.Errorf("sync: %w", err)
+	}
+	report(r)
+	return nil
+}
+
+// newStore creates a database at path.
+func newStore(path string) (*store.Store, error) {
+	s, err := store.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.InitSchema(); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return s, nil
+}
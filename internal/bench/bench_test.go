@@ -0,0 +1,149 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	stdsync "sync"
+	"testing"
+)
+
+// benchEvents is the size of the archive the benchmarks run against.
+const benchEvents = 20000
+
+var (
+	archiveOnce stdsync.Once
+	archiveDir  string
+	archive     *Archive
+	archiveErr  error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if archive != nil {
+		_ = archive.Close()
+	}
+	if archiveDir != "" {
+		_ = os.RemoveAll(archiveDir)
+	}
+	os.Exit(code)
+}
+
+// sharedArchive generates the benchmark archive once per test binary.
+func sharedArchive(b *testing.B) *Archive {
+	b.Helper()
+	archiveOnce.Do(func() {
+		archiveDir, archiveErr = os.MkdirTemp("", "calvault-bench-*")
+		if archiveErr != nil {
+			return
+		}
+		archive, archiveErr = NewArchive(archiveDir, benchEvents, 1)
+	})
+	if archiveErr != nil {
+		b.Fatalf("generate archive: %v", archiveErr)
+	}
+	return archive
+}
+
+func TestRun(t *testing.T) {
+	var names []string
+	err := Run(context.Background(), t.TempDir(), Options{Events: 600, Seed: 1, Iterations: 2}, func(r Result) {
+		if r.Ops == 0 || r.Duration <= 0 {
+			t.Errorf("%s: %d ops in %v", r.Name, r.Ops, r.Duration)
+		}
+		names = append(names, r.Name)
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if want := len(Queries) + 2; len(names) != want || names[0] != "upsert" || names[len(names)-1] != "sync" {
+		t.Errorf("results = %v, want upsert, %d queries and sync", names, len(Queries))
+	}
+}
+
+func TestSync(t *testing.T) {
+	a, err := NewArchive(t.TempDir(), 3000, 1)
+	if err != nil {
+		t.Fatalf("generate archive: %v", err)
+	}
+	defer func() { _ = a.Close() }()
+	s, err := newStore(filepath.Join(t.TempDir(), "sync.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+
+	r, err := a.Sync(context.Background(), s)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if r.Ops != 3000 {
+		t.Errorf("synced %d events, want 3000", r.Ops)
+	}
+	var attendees, want int
+	_ = s.DB().QueryRow(`SELECT COUNT(*) FROM attendees`).Scan(&attendees)
+	_ = a.Store.DB().QueryRow(`SELECT COUNT(*) FROM attendees`).Scan(&want)
+	if attendees != want {
+		t.Errorf("synced %d attendees, want %d", attendees, want)
+	}
+}
+
+func BenchmarkUpsert(b *testing.B) {
+	a := sharedArchive(b)
+	b.ResetTimer()
+	var events int
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		s, err := newStore(filepath.Join(b.TempDir(), "upsert.db"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		r, err := a.Upsert(s, 250)
+		if err != nil {
+			b.Fatal(err)
+		}
+		events += r.Ops
+		b.StopTimer()
+		_ = s.Close()
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(events)/b.Elapsed().Seconds(), "events/s")
+}
+
+func BenchmarkQuery(b *testing.B) {
+	a := sharedArchive(b)
+	for _, q := range Queries {
+		b.Run(q.Name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := q.Run(context.Background(), a); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSync(b *testing.B) {
+	a := sharedArchive(b)
+	b.ResetTimer()
+	var events int
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		s, err := newStore(filepath.Join(b.TempDir(), fmt.Sprintf("sync%d.db", i)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		r, err := a.Sync(context.Background(), s)
+		if err != nil {
+			b.Fatal(err)
+		}
+		events += r.Ops
+		b.StopTimer()
+		_ = s.Close()
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(events)/b.Elapsed().Seconds(), "events/s")
+}
@@ -0,0 +1,173 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/calendar"
+	"github.com/salman1993/calvault/internal/demo"
+	"github.com/salman1993/calvault/internal/store"
+	"github.com/salman1993/calvault/internal/sync"
+	"golang.org/x/oauth2"
+	gcalendar "google.golang.org/api/calendar/v3"
+)
+
+// pageSize is the number of events per page the fake API serves, the
+// maximum the Calendar API allows.
+const pageSize = 2500
+
+// Sync runs a full sync of the archive into s from a local fake of the
+// Calendar API, which serves the archive's events as Google would.
+// Building the pages is left out of the time; serving and decoding them
+// is part of it, as it is of a real sync.
+func (a *Archive) Sync(ctx context.Context, s *store.Store) (Result, error) {
+	api := newFakeAPI(a)
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	client, err := calendar.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "bench"}),
+		calendar.WithEndpoint(srv.URL+"/"),
+		calendar.WithRateLimiter(calendar.NewRateLimiter(1e6, 0)),
+		calendar.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	)
+	if err != nil {
+		return Result{}, err
+	}
+	syncer := sync.New(client, s).WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	start := time.Now()
+	summary, err := syncer.SyncAccount(ctx, demo.Account, sync.Options{Concurrency: 1})
+	d := time.Since(start)
+	if err != nil {
+		return Result{}, err
+	}
+	if summary.CalendarsSynced != len(a.Calendars) {
+		return Result{}, fmt.Errorf("synced %d of %d calendars", summary.CalendarsSynced, len(a.Calendars))
+	}
+	return Result{Name: "sync", Ops: summary.EventsAdded + summary.EventsUpdated, Unit: "events", Duration: d}, nil
+}
+
+// fakeAPI serves the calendar list and event pages of an archive.
+type fakeAPI struct {
+	calendars *gcalendar.CalendarList
+	events    map[string][][]byte // Encoded pages by calendar ID
+}
+
+func newFakeAPI(a *Archive) *fakeAPI {
+	api := &fakeAPI{calendars: &gcalendar.CalendarList{NextSyncToken: "list-token"}, events: map[string][][]byte{}}
+	byCalendar := map[int64][]*gcalendar.Event{}
+	for _, w := range a.Writes {
+		byCalendar[w.Event.CalendarID] = append(byCalendar[w.Event.CalendarID], googleEvent(w))
+	}
+	for _, cal := range a.Calendars {
+		api.calendars.Items = append(api.calendars.Items, &gcalendar.CalendarListEntry{
+			Id:              cal.GoogleCalendarID,
+			Summary:         cal.Summary,
+			TimeZone:        cal.Timezone,
+			Primary:         cal.IsPrimary,
+			AccessRole:      cal.AccessRole,
+			ColorId:         cal.ColorID,
+			BackgroundColor: cal.BackgroundColor,
+		})
+		events := byCalendar[cal.ID]
+		for i := 0; i == 0 || i < len(events); i += pageSize {
+			page := &gcalendar.Events{Items: events[i:min(i+pageSize, len(events))]}
+			if i+pageSize < len(events) {
+				page.NextPageToken = strconv.Itoa(i + pageSize)
+			} else {
+				page.NextSyncToken = "sync-token"
+			}
+			data, _ := json.Marshal(page)
+			api.events[cal.GoogleCalendarID] = append(api.events[cal.GoogleCalendarID], data)
+		}
+	}
+	return api
+}
+
+func (api *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	path := r.URL.Path
+	if strings.HasSuffix(path, "/users/me/calendarList") {
+		_ = json.NewEncoder(w).Encode(api.calendars)
+		return
+	}
+	if rest, ok := strings.CutSuffix(path, "/events"); ok {
+		id := rest[strings.LastIndex(rest, "/calendars/")+len("/calendars/"):]
+		pages, ok := api.events[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		offset, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+		_, _ = w.Write(pages[offset/pageSize])
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// googleEvent converts a stored event back to the Calendar API's form.
+func googleEvent(w *store.EventWrite) *gcalendar.Event {
+	e := w.Event
+	ge := &gcalendar.Event{
+		Id:               e.GoogleEventID,
+		ICalUID:          e.ICalUID,
+		Summary:          e.Summary,
+		Description:      e.Description,
+		Location:         e.Location,
+		Status:           e.Status,
+		Visibility:       e.Visibility,
+		ColorId:          e.ColorID,
+		RecurringEventId: e.RecurringEventID,
+		Start:            eventTime(e.StartTime.Time, e.AllDay, e.OriginalTimezone),
+		End:              eventTime(e.EndTime.Time, e.AllDay, e.OriginalTimezone),
+		Organizer:        &gcalendar.EventOrganizer{Email: e.OrganizerEmail, DisplayName: e.OrganizerName},
+		Creator:          &gcalendar.EventCreator{Email: e.CreatorEmail},
+	}
+	if e.RecurrenceRule != "" {
+		ge.Recurrence = strings.Split(e.RecurrenceRule, "\n")
+	}
+	if e.CreatedAt.Valid {
+		ge.Created = e.CreatedAt.Time.Format(time.RFC3339)
+	}
+	if e.UpdatedAt.Valid {
+		ge.Updated = e.UpdatedAt.Time.Format(time.RFC3339)
+	}
+	for _, a := range w.Attendees {
+		ge.Attendees = append(ge.Attendees, &gcalendar.EventAttendee{
+			Email:          a.Email,
+			DisplayName:    a.DisplayName,
+			ResponseStatus: a.ResponseStatus,
+			Organizer:      a.IsOrganizer,
+			Self:           a.IsSelf,
+		})
+	}
+	if c := w.Conference; c != nil && !c.IsEmpty() {
+		ge.HangoutLink = c.HangoutLink
+		ge.ConferenceData = &gcalendar.ConferenceData{
+			ConferenceId:       c.ConferenceID,
+			ConferenceSolution: &gcalendar.ConferenceSolution{Name: c.SolutionName, Key: &gcalendar.ConferenceSolutionKey{Type: c.SolutionType}},
+		}
+		for _, ep := range c.EntryPoints {
+			ge.ConferenceData.EntryPoints = append(ge.ConferenceData.EntryPoints, &gcalendar.EntryPoint{
+				EntryPointType: ep.Type, Uri: ep.URI, Label: ep.Label, MeetingCode: ep.MeetingCode,
+			})
+		}
+	}
+	return ge
+}
+
+// eventTime converts a stored start or end time.
+func eventTime(t time.Time, allDay bool, timezone string) *gcalendar.EventDateTime {
+	if allDay {
+		return &gcalendar.EventDateTime{Date: t.Format("2006-01-02")}
+	}
+	return &gcalendar.EventDateTime{DateTime: t.Format(time.RFC3339), TimeZone: timezone}
+}
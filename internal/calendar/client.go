@@ -5,10 +5,11 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"sync"
 	"time"
 
+	"github.com/salman1993/calvault/internal/metrics"
 	"golang.org/x/oauth2"
+	"golang.org/x/time/rate"
 	gcalendar "google.golang.org/api/calendar/v3"
 	"google.golang.org/api/option"
 )
@@ -18,60 +19,51 @@ type Client struct {
 	service     *gcalendar.Service
 	rateLimiter *RateLimiter
 	logger      *slog.Logger
+	endpoint    string
 }
 
-// RateLimiter implements a simple token bucket rate limiter.
+// RateLimiter paces API requests with a token bucket: requests flow at qps
+// on average, with bursts of up to burst requests after a quiet spell. It is
+// safe for concurrent use, and records time spent waiting in metrics.
 type RateLimiter struct {
-	mu       sync.Mutex
-	qps      float64
-	tokens   float64
-	lastTime time.Time
+	limiter *rate.Limiter
 }
 
-// NewRateLimiter creates a rate limiter with the specified QPS.
-func NewRateLimiter(qps float64) *RateLimiter {
-	return &RateLimiter{
-		qps:      qps,
-		tokens:   qps, // Start with a full bucket
-		lastTime: time.Now(),
+// NewRateLimiter creates a rate limiter with the specified QPS and burst.
+// A burst of 0 allows one second's worth of requests, at least one.
+func NewRateLimiter(qps float64, burst int) *RateLimiter {
+	if burst <= 0 {
+		burst = max(int(qps), 1)
 	}
+	// The bucket starts full
+	return &RateLimiter{limiter: rate.NewLimiter(rate.Limit(qps), burst)}
 }
 
-// Wait blocks until a token is available.
+// Wait blocks until the next request may be made. Each caller reserves a
+// token up front and sleeps until its reservation is due, so concurrent
+// waiters never exceed the rate; a cancelled wait gives its token back.
 func (r *RateLimiter) Wait(ctx context.Context) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	// Refill tokens based on elapsed time
-	now := time.Now()
-	elapsed := now.Sub(r.lastTime).Seconds()
-	r.tokens += elapsed * r.qps
-	if r.tokens > r.qps {
-		r.tokens = r.qps
+	res := r.limiter.Reserve()
+	if !res.OK() {
+		return fmt.Errorf("rate limiter: burst of %d can never be met", r.limiter.Burst())
 	}
-	r.lastTime = now
-
-	// If we have a token, use it
-	if r.tokens >= 1.0 {
-		r.tokens -= 1.0
+	delay := res.Delay()
+	if delay == 0 {
 		return nil
 	}
+	metrics.RateLimitWaits.Inc()
+	metrics.RateLimitWaitSeconds.Add(delay.Seconds())
+	metrics.RateLimitWaitDuration.Observe(delay.Seconds())
 
-	// Wait for a token
-	waitTime := time.Duration((1.0-r.tokens)/r.qps*1000) * time.Millisecond
-	r.mu.Unlock()
-
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
 	select {
-	case <-time.After(waitTime):
+	case <-timer.C:
+		return nil
 	case <-ctx.Done():
-		r.mu.Lock()
+		res.Cancel()
 		return ctx.Err()
 	}
-
-	r.mu.Lock()
-	r.tokens = 0
-	r.lastTime = time.Now()
-	return nil
 }
 
 // ClientOption configures the client.
@@ -91,17 +83,18 @@ func WithRateLimiter(rl *RateLimiter) ClientOption {
 	}
 }
 
-// NewClient creates a new Calendar API client.
-func NewClient(ctx context.Context, tokenSource oauth2.TokenSource, opts ...ClientOption) (*Client, error) {
-	httpClient := oauth2.NewClient(ctx, tokenSource)
-	service, err := gcalendar.NewService(ctx, option.WithHTTPClient(httpClient))
-	if err != nil {
-		return nil, fmt.Errorf("create calendar service: %w", err)
+// WithEndpoint sends requests to another server implementing the Calendar
+// API, such as a fake one in benchmarks.
+func WithEndpoint(url string) ClientOption {
+	return func(c *Client) {
+		c.endpoint = url
 	}
+}
 
+// NewClient creates a new Calendar API client.
+func NewClient(ctx context.Context, tokenSource oauth2.TokenSource, opts ...ClientOption) (*Client, error) {
 	c := &Client{
-		service:     service,
-		rateLimiter: NewRateLimiter(10), // Default 10 QPS
+		rateLimiter: NewRateLimiter(10, 0), // Default 10 QPS
 		logger:      slog.Default(),
 	}
 
@@ -109,6 +102,18 @@ func NewClient(ctx context.Context, tokenSource oauth2.TokenSource, opts ...Clie
 		opt(c)
 	}
 
+	httpClient := oauth2.NewClient(ctx, tokenSource)
+	httpClient.Transport = metrics.Transport("google", httpClient.Transport)
+	serviceOpts := []option.ClientOption{option.WithHTTPClient(httpClient)}
+	if c.endpoint != "" {
+		serviceOpts = append(serviceOpts, option.WithEndpoint(c.endpoint))
+	}
+	service, err := gcalendar.NewService(ctx, serviceOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("create calendar service: %w", err)
+	}
+	c.service = service
+
 	return c, nil
 }
 
@@ -119,6 +124,11 @@ type CalendarEntry struct {
 	Description string
 	TimeZone    string
 	IsPrimary   bool
+	AccessRole  string // owner, writer, reader, freeBusyReader
+	ColorID     string // Google's calendar palette ID
+	Color       string // Background color as shown, e.g. #9fc6e7
+	// DefaultReminders apply to events that use the calendar's defaults
+	DefaultReminders []*gcalendar.EventReminder
 }
 
 // ListCalendars returns all calendars for the authenticated user.
@@ -148,6 +158,11 @@ func (c *Client) ListCalendars(ctx context.Context) ([]*CalendarEntry, error) {
 				Description: entry.Description,
 				TimeZone:    entry.TimeZone,
 				IsPrimary:   entry.Primary,
+				AccessRole:  entry.AccessRole,
+				ColorID:     entry.ColorId,
+				Color:       entry.BackgroundColor,
+
+				DefaultReminders: entry.DefaultReminders,
 			})
 		}
 
@@ -164,6 +179,63 @@ func (c *Client) ListCalendars(ctx context.Context) ([]*CalendarEntry, error) {
 	return calendars, nil
 }
 
+// CalendarChanges holds calendar list entries changed since a sync token.
+type CalendarChanges struct {
+	Calendars     []*CalendarEntry // Added or changed; removed entries are left out
+	NextSyncToken string
+}
+
+// ListCalendarChanges returns the calendar list entries changed since
+// syncToken, or all entries if syncToken is empty, along with the token
+// for the next call.
+func (c *Client) ListCalendarChanges(ctx context.Context, syncToken string) (*CalendarChanges, error) {
+	changes := &CalendarChanges{}
+	pageToken := ""
+
+	for {
+		if err := c.rateLimiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		call := c.service.CalendarList.List().MaxResults(250)
+		if syncToken != "" {
+			call = call.SyncToken(syncToken).ShowDeleted(true)
+		}
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+
+		list, err := call.Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("list calendars: %w", err)
+		}
+
+		for _, entry := range list.Items {
+			if entry.Deleted {
+				continue
+			}
+			changes.Calendars = append(changes.Calendars, &CalendarEntry{
+				ID:          entry.Id,
+				Summary:     entry.Summary,
+				Description: entry.Description,
+				TimeZone:    entry.TimeZone,
+				IsPrimary:   entry.Primary,
+				AccessRole:  entry.AccessRole,
+				ColorID:     entry.ColorId,
+				Color:       entry.BackgroundColor,
+
+				DefaultReminders: entry.DefaultReminders,
+			})
+		}
+
+		pageToken = list.NextPageToken
+		if pageToken == "" {
+			changes.NextSyncToken = list.NextSyncToken
+			return changes, nil
+		}
+	}
+}
+
 // EventsPage represents a page of events.
 type EventsPage struct {
 	Events        []*gcalendar.Event
@@ -226,6 +298,95 @@ func (c *Client) ListEvents(ctx context.Context, calendarID string, opts ListEve
 	}, nil
 }
 
+// CountEvents pages through a calendar fetching only event IDs, using the
+// same page size as ListEvents. It returns the number of events and the
+// number of requests made; SyncToken and PageToken in opts are ignored.
+func (c *Client) CountEvents(ctx context.Context, calendarID string, opts ListEventsOptions) (events, requests int, err error) {
+	pageToken := ""
+	for {
+		if err := c.rateLimiter.Wait(ctx); err != nil {
+			return events, requests, err
+		}
+
+		call := c.service.Events.List(calendarID).
+			ShowDeleted(opts.ShowDeleted).
+			SingleEvents(opts.SingleEvents).
+			MaxResults(2500).
+			Fields("items(id)", "nextPageToken")
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		if !opts.TimeMin.IsZero() {
+			call = call.TimeMin(opts.TimeMin.Format(time.RFC3339))
+		}
+		if !opts.TimeMax.IsZero() {
+			call = call.TimeMax(opts.TimeMax.Format(time.RFC3339))
+		}
+
+		page, err := call.Context(ctx).Do()
+		requests++
+		if err != nil {
+			return events, requests, fmt.Errorf("count events: %w", err)
+		}
+		events += len(page.Items)
+
+		pageToken = page.NextPageToken
+		if pageToken == "" {
+			return events, requests, nil
+		}
+	}
+}
+
+// WatchChannel is a push notification channel created by WatchEvents.
+type WatchChannel struct {
+	ID         string
+	ResourceID string
+	Expiration time.Time
+}
+
+// WatchEvents asks Google to POST to address whenever events in the calendar
+// change. channelID and token are echoed back in the X-Goog-Channel-ID and
+// X-Goog-Channel-Token headers. Google caps ttl (currently at 7 days for
+// events) and may return an earlier expiration.
+func (c *Client) WatchEvents(ctx context.Context, calendarID, channelID, token, address string, ttl time.Duration) (*WatchChannel, error) {
+	if err := c.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	ch, err := c.service.Events.Watch(calendarID, &gcalendar.Channel{
+		Id:      channelID,
+		Type:    "web_hook",
+		Address: address,
+		Token:   token,
+		Params:  map[string]string{"ttl": fmt.Sprintf("%d", int64(ttl.Seconds()))},
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("watch events: %w", err)
+	}
+
+	return &WatchChannel{
+		ID:         ch.Id,
+		ResourceID: ch.ResourceId,
+		Expiration: time.UnixMilli(ch.Expiration),
+	}, nil
+}
+
+// StopChannel stops a push notification channel.
+func (c *Client) StopChannel(ctx context.Context, channelID, resourceID string) error {
+	if err := c.rateLimiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	err := c.service.Channels.Stop(&gcalendar.Channel{
+		Id:         channelID,
+		ResourceId: resourceID,
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("stop channel: %w", err)
+	}
+	return nil
+}
+
 // ListEventsIncremental fetches only changed events using a sync token.
 func (c *Client) ListEventsIncremental(ctx context.Context, calendarID, syncToken string) (*EventsPage, error) {
 	return c.ListEvents(ctx, calendarID, ListEventsOptions{
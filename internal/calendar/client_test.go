@@ -0,0 +1,76 @@
+package calendar
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Concurrent(t *testing.T) {
+	const qps = 20
+	rl := NewRateLimiter(qps, 0)
+
+	// The bucket starts full, so the first qps calls pass immediately and
+	// the next qps calls are spread over one second.
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2*qps; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := rl.Wait(context.Background()); err != nil {
+				t.Errorf("wait: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("%d concurrent waits took %v, want about 1s", 2*qps, elapsed)
+	}
+}
+
+func TestRateLimiter_Cancel(t *testing.T) {
+	rl := NewRateLimiter(1, 0)
+	_ = rl.Wait(context.Background()) // Drain the bucket
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := rl.Wait(ctx); err == nil {
+		t.Error("expected context error")
+	}
+}
+
+func TestRateLimiter_Burst(t *testing.T) {
+	// A burst of 5 at 1 QPS: five calls pass at once, the sixth waits
+	rl := NewRateLimiter(1, 5)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := rl.Wait(context.Background()); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("burst of 5 took %v, want no wait", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := rl.Wait(ctx); err == nil {
+		t.Error("sixth call passed without waiting")
+	}
+}
+
+func TestRateLimiter_Fractional(t *testing.T) {
+	// 0.5 QPS: one call passes, the next waits two seconds
+	rl := NewRateLimiter(0.5, 0)
+	if err := rl.Wait(context.Background()); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := rl.Wait(ctx); err == nil {
+		t.Error("second call passed within a second at 0.5 QPS")
+	}
+}
@@ -0,0 +1,195 @@
+package changelog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// The subset of CBOR (RFC 8949) changelog records use: maps with text keys
+// whose values are integers, text, booleans and epoch times (tag 1).
+
+// CBOR major types.
+const (
+	majorUint   = 0
+	majorNegint = 1
+	majorText   = 3
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+// tagEpoch marks an integer as seconds since the Unix epoch.
+const tagEpoch = 1
+
+// encoder appends CBOR items to a buffer.
+type encoder struct {
+	buf []byte
+}
+
+// head writes an item's initial byte and argument in the shortest form.
+func (e *encoder) head(major byte, n uint64) {
+	switch {
+	case n < 24:
+		e.buf = append(e.buf, major<<5|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, major<<5|24, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, major<<5|25), uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, major<<5|26), uint32(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, major<<5|27), n)
+	}
+}
+
+func (e *encoder) int(n int64) {
+	if n < 0 {
+		e.head(majorNegint, uint64(-1-n))
+		return
+	}
+	e.head(majorUint, uint64(n))
+}
+
+func (e *encoder) text(s string) {
+	e.head(majorText, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.buf = append(e.buf, majorSimple<<5|21)
+	} else {
+		e.buf = append(e.buf, majorSimple<<5|20)
+	}
+}
+
+func (e *encoder) time(t time.Time) {
+	e.head(majorTag, tagEpoch)
+	e.int(t.Unix())
+}
+
+// value encodes one of the types records use.
+func (e *encoder) value(v any) {
+	switch v := v.(type) {
+	case int64:
+		e.int(v)
+	case int:
+		e.int(int64(v))
+	case string:
+		e.text(v)
+	case bool:
+		e.bool(v)
+	case time.Time:
+		e.time(v)
+	default:
+		panic(fmt.Sprintf("changelog: cannot encode %T", v))
+	}
+}
+
+// errMalformed reports data that isn't a record in the subset above.
+var errMalformed = errors.New("malformed changelog record")
+
+// maxItemLength bounds text and map lengths read, so a corrupt length
+// can't exhaust memory.
+const maxItemLength = 1 << 20
+
+// decoder reads CBOR items from a stream.
+type decoder struct {
+	r *bufio.Reader
+}
+
+// head reads an item's major type and argument.
+func (d *decoder) head() (byte, uint64, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	major, info := b>>5, b&0x1f
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, errMalformed // Indefinite lengths aren't used
+	}
+	var arg [8]byte
+	if _, err := io.ReadFull(d.r, arg[8-size:]); err != nil {
+		return 0, 0, unexpected(err)
+	}
+	return major, binary.BigEndian.Uint64(arg[:]), nil
+}
+
+// value reads an integer, text, boolean or epoch time.
+func (d *decoder) value() (any, error) {
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	switch major {
+	case majorUint:
+		if arg > math.MaxInt64 {
+			return nil, errMalformed
+		}
+		return int64(arg), nil
+	case majorNegint:
+		if arg > math.MaxInt64 {
+			return nil, errMalformed
+		}
+		return -1 - int64(arg), nil
+	case majorText:
+		return d.text(arg)
+	case majorTag:
+		if arg != tagEpoch {
+			return nil, errMalformed
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		secs, ok := v.(int64)
+		if !ok {
+			return nil, errMalformed
+		}
+		return time.Unix(secs, 0), nil
+	case majorSimple:
+		switch arg {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		}
+	}
+	return nil, errMalformed
+}
+
+func (d *decoder) text(n uint64) (string, error) {
+	if n > maxItemLength {
+		return "", errMalformed
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return "", unexpected(err)
+	}
+	return string(b), nil
+}
+
+// unexpected turns an EOF inside an item into io.ErrUnexpectedEOF.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
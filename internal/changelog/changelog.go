@@ -0,0 +1,299 @@
+// Package changelog writes an append-only file of the event changes each
+// sync makes, so other local tools can follow the archive by tailing one
+// file instead of polling and diffing the database.
+//
+// The file is a CBOR sequence (RFC 8742): CBOR maps, one per record,
+// written back to back with nothing between them, so any CBOR library can
+// read it and a reader can stop at the end and pick up from there as the
+// file grows. A sync writes a "run" record, an "added", "updated" or
+// "deleted" record per event change as each page is committed, and an
+// "end" record with its totals; a run without an end record was
+// interrupted or failed.
+package changelog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// Record types.
+const (
+	TypeRun     = "run"
+	TypeAdded   = "added"
+	TypeUpdated = "updated"
+	TypeDeleted = "deleted"
+	TypeEnd     = "end"
+)
+
+// Record is one entry in the changelog. Fields that don't apply to a
+// record's type are left out of the file.
+type Record struct {
+	Type    string
+	Run     int64  // The run the record belongs to: its start in Unix milliseconds
+	Account string // Account email
+
+	Kind string    // Run records: full or incremental
+	Time time.Time // Run and end records: when they were written
+
+	Calendar string    // Change records: the provider's calendar ID
+	EventID  string    // The provider's event ID
+	ICalUID  string    // Added and updated records
+	Title    string    // Added and updated records
+	Start    time.Time // Added and updated records, if set
+	End      time.Time // Added and updated records, if set
+	AllDay   bool      // Added and updated records
+	Status   string    // Added and updated records: confirmed, tentative or cancelled
+
+	Added, Updated, Deleted int // End records
+}
+
+// Fields returns the record's keys and values in file order, without the
+// fields its type leaves out.
+func (r *Record) Fields() []Field {
+	fields := []Field{{"type", r.Type}, {"run", r.Run}, {"account", r.Account}}
+	switch r.Type {
+	case TypeRun:
+		fields = append(fields, Field{"kind", r.Kind}, Field{"time", r.Time})
+	case TypeEnd:
+		fields = append(fields, Field{"time", r.Time},
+			Field{"added", r.Added}, Field{"updated", r.Updated}, Field{"deleted", r.Deleted})
+	case TypeDeleted:
+		fields = append(fields, Field{"calendar", r.Calendar}, Field{"event_id", r.EventID})
+	default:
+		fields = append(fields, Field{"calendar", r.Calendar}, Field{"event_id", r.EventID},
+			Field{"ical_uid", r.ICalUID}, Field{"title", r.Title})
+		if !r.Start.IsZero() {
+			fields = append(fields, Field{"start", r.Start})
+		}
+		if !r.End.IsZero() {
+			fields = append(fields, Field{"end", r.End})
+		}
+		fields = append(fields, Field{"all_day", r.AllDay}, Field{"status", r.Status})
+	}
+	return fields
+}
+
+// EventRecord returns the added or updated record of a stored event.
+func EventRecord(recordType, calendarID string, e *store.Event) *Record {
+	r := &Record{
+		Type:     recordType,
+		Calendar: calendarID,
+		EventID:  e.GoogleEventID,
+		ICalUID:  e.ICalUID,
+		Title:    e.Summary,
+		AllDay:   e.AllDay,
+		Status:   e.Status,
+	}
+	if r.Status == "" {
+		r.Status = "confirmed"
+	}
+	if e.StartTime.Valid {
+		r.Start = e.StartTime.Time
+	}
+	if e.EndTime.Valid {
+		r.End = e.EndTime.Time
+	}
+	return r
+}
+
+// Field is a record key and its value: an int64, int, string, bool or
+// time.Time.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Encode returns the record as a CBOR map.
+func (r *Record) Encode() []byte {
+	fields := r.Fields()
+	e := &encoder{}
+	e.head(majorMap, uint64(len(fields)))
+	for _, f := range fields {
+		e.text(f.Key)
+		e.value(f.Value)
+	}
+	return e.buf
+}
+
+// Writer appends records to a changelog file. It is safe for concurrent
+// use; each record is written in one write, so readers never see records
+// interleaved.
+type Writer struct {
+	mu   sync.Mutex
+	f    *os.File
+	run  int64
+	acct string
+}
+
+// Open opens the changelog at path for appending, creating it if needed,
+// and starts a run for account by writing its run record.
+func Open(path, account, kind string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open changelog: %w", err)
+	}
+	now := time.Now()
+	w := &Writer{f: f, run: now.UnixMilli(), acct: account}
+	if err := w.Write(&Record{Type: TypeRun, Kind: kind, Time: now}); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends a record, filling in its run and account.
+func (w *Writer) Write(r *Record) error {
+	r.Run, r.Account = w.run, w.acct
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.f.Write(r.Encode()); err != nil {
+		return fmt.Errorf("write changelog: %w", err)
+	}
+	return nil
+}
+
+// Close writes the run's end record with its totals and closes the file.
+func (w *Writer) Close(added, updated, deleted int) error {
+	err := w.Write(&Record{Type: TypeEnd, Time: time.Now(), Added: added, Updated: updated, Deleted: deleted})
+	if cerr := w.f.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("close changelog: %w", cerr)
+	}
+	return err
+}
+
+// Abort closes the file without an end record, marking the run
+// unfinished.
+func (w *Writer) Abort() error {
+	return w.f.Close()
+}
+
+// Reader reads records from a changelog.
+type Reader struct {
+	d    *decoder
+	read *countingReader
+	end  int64 // Offset of the end of the last record read
+}
+
+// NewReader returns a reader of the records in r.
+func NewReader(r io.Reader) *Reader {
+	read := &countingReader{r: r}
+	return &Reader{d: &decoder{r: bufio.NewReader(read)}, read: read}
+}
+
+// Offset returns how far into r the records read so far end. A reader
+// following a growing file can seek there after a partial record and
+// start a new Reader.
+func (r *Reader) Offset() int64 {
+	return r.end
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Read returns the next record. It returns io.EOF at the end of the
+// changelog and io.ErrUnexpectedEOF if it ends partway through a record,
+// as when a sync is writing one.
+func (r *Reader) Read() (*Record, error) {
+	major, n, err := r.d.head()
+	if err != nil {
+		return nil, err
+	}
+	if major != majorMap || n > maxItemLength {
+		return nil, errMalformed
+	}
+	rec := &Record{}
+	for i := uint64(0); i < n; i++ {
+		major, n, err := r.d.head()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		if major != majorText {
+			return nil, errMalformed
+		}
+		key, err := r.d.text(n)
+		if err != nil {
+			return nil, err
+		}
+		v, err := r.d.value()
+		if err != nil {
+			return nil, err
+		}
+		if err := rec.set(key, v); err != nil {
+			return nil, err
+		}
+	}
+	r.end = r.read.n - int64(r.d.r.Buffered())
+	return rec, nil
+}
+
+// set sets the field under key. Unknown keys are ignored, so records can
+// gain fields.
+func (r *Record) set(key string, v any) error {
+	var ok bool
+	switch key {
+	case "type":
+		r.Type, ok = v.(string)
+	case "run":
+		r.Run, ok = v.(int64)
+	case "account":
+		r.Account, ok = v.(string)
+	case "kind":
+		r.Kind, ok = v.(string)
+	case "time":
+		r.Time, ok = v.(time.Time)
+	case "calendar":
+		r.Calendar, ok = v.(string)
+	case "event_id":
+		r.EventID, ok = v.(string)
+	case "ical_uid":
+		r.ICalUID, ok = v.(string)
+	case "title":
+		r.Title, ok = v.(string)
+	case "start":
+		r.Start, ok = v.(time.Time)
+	case "end":
+		r.End, ok = v.(time.Time)
+	case "all_day":
+		r.AllDay, ok = v.(bool)
+	case "status":
+		r.Status, ok = v.(string)
+	case "added":
+		r.Added, ok = count(v)
+	case "updated":
+		r.Updated, ok = count(v)
+	case "deleted":
+		r.Deleted, ok = count(v)
+	default:
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s has the wrong type", errMalformed, key)
+	}
+	return nil
+}
+
+// IsMalformed reports whether err is from reading something that isn't a
+// changelog.
+func IsMalformed(err error) bool {
+	return errors.Is(err, errMalformed)
+}
+
+func count(v any) (int, bool) {
+	n, ok := v.(int64)
+	return int(n), ok
+}
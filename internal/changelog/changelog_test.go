@@ -0,0 +1,138 @@
+package changelog
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEncoder(t *testing.T) {
+	// Examples from RFC 8949 appendix A
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{0, "00"},
+		{23, "17"},
+		{24, "1818"},
+		{1000, "1903e8"},
+		{1000000, "1a000f4240"},
+		{int64(1000000000000), "1b000000e8d4a51000"},
+		{-1, "20"},
+		{-1000, "3903e7"},
+		{"", "60"},
+		{"IETF", "6449455446"},
+		{"ü", "62c3bc"},
+		{false, "f4"},
+		{true, "f5"},
+		{time.Unix(1363896240, 0), "c11a514b67b0"},
+	}
+	for _, tt := range tests {
+		e := &encoder{}
+		e.value(tt.value)
+		if got := hex.EncodeToString(e.buf); got != tt.want {
+			t.Errorf("encode %v = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWriterReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "changelog.cbor")
+	start := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+
+	w, err := Open(path, "me@example.com", "full")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	changes := []*Record{
+		{Type: TypeAdded, Calendar: "primary", EventID: "ev1", ICalUID: "ev1@google.com", Title: "Standup", Start: start, End: start.Add(time.Hour), Status: "confirmed"},
+		{Type: TypeUpdated, Calendar: "primary", EventID: "ev2", Title: "Offsite", Start: start, AllDay: true, Status: "tentative"},
+		{Type: TypeDeleted, Calendar: "primary", EventID: "ev3"},
+	}
+	for _, r := range changes {
+		if err := w.Write(r); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.Close(1, 1, 1); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	// A second run that didn't finish
+	w, err = Open(path, "me@example.com", "incremental")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := w.Abort(); err != nil {
+		t.Fatalf("abort: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	r := NewReader(bytes.NewReader(data))
+	var got []*Record
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		got = append(got, rec)
+	}
+	if len(got) != 6 {
+		t.Fatalf("read %d records, want 6", len(got))
+	}
+	if r.Offset() != int64(len(data)) {
+		t.Errorf("offset = %d, want %d", r.Offset(), len(data))
+	}
+
+	run, end := got[0], got[4]
+	if run.Type != TypeRun || run.Kind != "full" || run.Account != "me@example.com" || run.Run == 0 {
+		t.Errorf("run record = %+v", run)
+	}
+	if end.Type != TypeEnd || end.Run != run.Run || end.Added != 1 || end.Updated != 1 || end.Deleted != 1 {
+		t.Errorf("end record = %+v", end)
+	}
+	utc := func(t time.Time) time.Time {
+		if t.IsZero() {
+			return time.Time{}
+		}
+		return t.UTC()
+	}
+	for i, want := range changes {
+		rec := got[i+1]
+		rec.Start, rec.End = utc(rec.Start), utc(rec.End)
+		if !reflect.DeepEqual(rec, want) {
+			t.Errorf("record %d = %+v, want %+v", i+1, rec, want)
+		}
+	}
+	if got[5].Type != TypeRun || got[5].Kind != "incremental" {
+		t.Errorf("second run record = %+v", got[5])
+	}
+
+	// A record cut off partway, as while a sync writes it
+	r = NewReader(bytes.NewReader(data[:len(data)-3]))
+	var err2 error
+	for err2 == nil {
+		_, err2 = r.Read()
+	}
+	if !errors.Is(err2, io.ErrUnexpectedEOF) {
+		t.Errorf("partial record error = %v, want unexpected EOF", err2)
+	}
+	if r.Offset() >= int64(len(data)) {
+		t.Errorf("offset after partial record = %d, want before it", r.Offset())
+	}
+
+	if _, err := NewReader(bytes.NewReader([]byte("not cbor"))).Read(); !IsMalformed(err) {
+		t.Errorf("reading text: error = %v, want malformed", err)
+	}
+}
@@ -3,19 +3,304 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
 
 // Config represents the calvault configuration.
 type Config struct {
-	OAuth OAuthConfig `toml:"oauth"`
-	Sync  SyncConfig  `toml:"sync"`
+	OAuth      OAuthConfig      `toml:"oauth"`
+	Microsoft  MicrosoftConfig  `toml:"microsoft"`
+	Sync       SyncConfig       `toml:"sync"`
+	Query      QueryConfig      `toml:"query"`
+	Daemon     DaemonConfig     `toml:"daemon"`
+	Output     OutputConfig     `toml:"output"`
+	Storage    StorageConfig    `toml:"storage"`
+	Team       TeamConfig       `toml:"team"`
+	Interviews InterviewsConfig `toml:"interviews"`
+	WorkHours  WorkHoursConfig  `toml:"work_hours"`
+	LLM        LLMConfig        `toml:"llm"`
+	Entities   EntitiesConfig   `toml:"entities"`
+	Enrich     EnrichConfig     `toml:"enrich"`
+	Identities []IdentityConfig `toml:"identity"`
 
 	// Computed paths (not from config file)
 	HomeDir string `toml:"-"`
+	File    string `toml:"-"` // Config file path, whether or not it exists
+}
+
+// StorageConfig holds where the archive is kept.
+type StorageConfig struct {
+	// Database is the SQLite database path; defaults to calvault.db in the
+	// home directory. Lets the database live on a separate (e.g. encrypted)
+	// volume from the config and tokens.
+	Database string `toml:"database"`
+
+	// EventHistory keeps an event's previous version in event_history
+	// whenever a sync or import changes its details, instead of only
+	// overwriting it.
+	EventHistory bool `toml:"event_history"`
+
+	// TrashDays is how long rows removed by remove-account and purge
+	// (including [storage] retention) stay in the trash, where 'calvault undo' can restore them (default 30; 0 =
+	// delete them right away).
+	TrashDays int `toml:"trash_days"`
+
+	// LegalHold makes the archive immutable for compliance: events are
+	// never deleted - a sync or import that deletes one records a
+	// tombstone in event_tombstones instead - and removing accounts,
+	// emptying the trash and orphan cleanup are refused.
+	LegalHold bool `toml:"legal_hold"`
+
+	// Retention, if set, is how long events are kept, such as "10y" or
+	// "18m": the daemon deletes events that ended longer ago than this,
+	// as 'calvault purge --older-than' does.
+	Retention string `toml:"retention"`
+}
+
+// TrashPeriod returns how long removed rows stay in the trash.
+func (c *StorageConfig) TrashPeriod() time.Duration {
+	return time.Duration(c.TrashDays) * 24 * time.Hour
+}
+
+// RetentionAge returns the retention window, or false if there is none.
+func (c *StorageConfig) RetentionAge() (Age, bool) {
+	if c.Retention == "" {
+		return Age{}, false
+	}
+	age, err := ParseAge(c.Retention)
+	return age, err == nil
+}
+
+// Age is a span of calendar time, as in "10y" or "90d".
+type Age struct {
+	Years, Months, Days int
+}
+
+// ParseAge parses a whole number of years (y), months (m), weeks (w) or
+// days (d), such as "10y" or "6w".
+func ParseAge(s string) (Age, error) {
+	if len(s) < 2 {
+		return Age{}, fmt.Errorf("invalid age %q (expected a number and y, m, w or d, such as 10y)", s)
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return Age{}, fmt.Errorf("invalid age %q (expected a number and y, m, w or d, such as 10y)", s)
+	}
+	switch s[len(s)-1] {
+	case 'y':
+		return Age{Years: n}, nil
+	case 'm':
+		return Age{Months: n}, nil
+	case 'w':
+		return Age{Days: 7 * n}, nil
+	case 'd':
+		return Age{Days: n}, nil
+	}
+	return Age{}, fmt.Errorf("invalid age %q (expected a number and y, m, w or d, such as 10y)", s)
+}
+
+// Before returns the time the age before t.
+func (a Age) Before(t time.Time) time.Time {
+	return t.AddDate(-a.Years, -a.Months, -a.Days)
+}
+
+// TeamConfig sets up a team vault: several people syncing their accounts
+// into one shared database.
+type TeamConfig struct {
+	// Member identifies the person syncing from this machine. Accounts they
+	// sync are recorded as theirs, and their private calendars stay visible
+	// to them. Setting it turns on team mode.
+	Member string `toml:"member"`
+
+	// PrivateCalendars are calendar ID or name patterns (globs as in
+	// path.Match) hidden from other members' queries and team reports.
+	PrivateCalendars []string `toml:"private_calendars"`
+
+	// Groups assigns members to groups by group name, for the per-group
+	// figures and cross-group matrix of 'analyze capacity'.
+	Groups map[string][]string `toml:"groups"`
+
+	// MeetingFreeDays are days of the week the team keeps free of
+	// meetings, e.g. ["wed"]; 'analyze capacity' reports how well they
+	// are kept. Unset, it reports meeting-free working days instead.
+	MeetingFreeDays []string `toml:"meeting_free_days"`
+
+	// MinGroupSize is the fewest members a figure in 'analyze capacity'
+	// may cover (default 3): smaller groups are hidden, so one person's
+	// calendar can't be read off a team report.
+	MinGroupSize int `toml:"min_group_size"`
+}
+
+// Enabled reports whether the database is shared as a team vault.
+func (c *TeamConfig) Enabled() bool {
+	return c.Member != ""
+}
+
+// IsPrivateCalendar reports whether a calendar matches private_calendars.
+func (c *TeamConfig) IsPrivateCalendar(id, name string) bool {
+	return matchAnyCalendar(c.PrivateCalendars, id, name)
+}
+
+// GroupOf returns the group a member is in, or "" if none.
+func (c *TeamConfig) GroupOf(member string) string {
+	for group, members := range c.Groups {
+		if slices.Contains(members, member) {
+			return group
+		}
+	}
+	return ""
+}
+
+// MeetingFreeWeekdays reports which days of the week, indexed by
+// time.Weekday, are meant to be meeting-free.
+func (c *TeamConfig) MeetingFreeWeekdays() [7]bool {
+	var days [7]bool
+	for _, d := range c.MeetingFreeDays {
+		if wd, ok := parseWeekday(d); ok {
+			days[wd] = true
+		}
+	}
+	return days
+}
+
+// InterviewsConfig holds the rules 'analyze interviews' uses to recognize
+// interviews. Patterns are globs as in path.Match, compared ignoring case.
+type InterviewsConfig struct {
+	// Titles match event titles.
+	Titles []string `toml:"titles"`
+
+	// Attendees match attendee emails, such as an applicant tracking
+	// system's scheduling address. An event is an interview if its title
+	// or any attendee matches; matching attendees aren't interviewers.
+	Attendees []string `toml:"attendees"`
+
+	// InterviewerDomains are the email domains of interviewers. Defaults to
+	// the domain of the account an interview was synced from, which leaves
+	// out candidates.
+	InterviewerDomains []string `toml:"interviewer_domains"`
+}
+
+// IsInterview reports whether an event with this title and attendee emails
+// is an interview.
+func (c *InterviewsConfig) IsInterview(title string, attendees []string) bool {
+	if matchAnyFold(c.Titles, title) {
+		return true
+	}
+	for _, a := range attendees {
+		if matchAnyFold(c.Attendees, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsInterviewer reports whether an attendee of an interview synced from
+// account counts as an interviewer.
+func (c *InterviewsConfig) IsInterviewer(email, account string) bool {
+	if matchAnyFold(c.Attendees, email) {
+		return false
+	}
+	domains := c.InterviewerDomains
+	if len(domains) == 0 {
+		domains = []string{emailDomain(account)}
+	}
+	domain := emailDomain(email)
+	for _, d := range domains {
+		if d != "" && strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyFold(patterns []string, s string) bool {
+	s = strings.ToLower(s)
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func emailDomain(email string) string {
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		return email[i+1:]
+	}
+	return ""
+}
+
+// WorkHoursConfig sets the working week used by the off-hours and room
+// reports.
+type WorkHoursConfig struct {
+	Start    string   `toml:"start"`    // Time of day, "09:00"
+	End      string   `toml:"end"`      // Time of day, "17:00"
+	Days     []string `toml:"days"`     // Working days, e.g. ["mon", "tue"]
+	Timezone string   `toml:"timezone"` // IANA name; default local time
+}
+
+// Span returns the start and end of the working day as offsets from
+// midnight.
+func (c *WorkHoursConfig) Span() (start, end time.Duration) {
+	start, _ = parseClock(c.Start)
+	end, _ = parseClock(c.End)
+	return start, end
+}
+
+// Weekdays reports which days of the week, indexed by time.Weekday, are
+// working days.
+func (c *WorkHoursConfig) Weekdays() [7]bool {
+	var days [7]bool
+	for _, d := range c.Days {
+		if wd, ok := parseWeekday(d); ok {
+			days[wd] = true
+		}
+	}
+	return days
+}
+
+// Location returns the time zone working hours are in.
+func (c *WorkHoursConfig) Location() *time.Location {
+	if c.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// parseClock parses a "15:04" time of day.
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// parseWeekday parses a day name or its first three letters.
+func parseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(s)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, true
+		}
+	}
+	return 0, false
 }
 
 // OAuthConfig holds OAuth configuration.
@@ -23,39 +308,424 @@ type OAuthConfig struct {
 	ClientSecrets string `toml:"client_secrets"`
 }
 
+// MicrosoftConfig holds Microsoft 365 / Outlook (Graph API) configuration.
+type MicrosoftConfig struct {
+	ClientID string `toml:"client_id"` // Azure app registration (public client)
+	Tenant   string `toml:"tenant"`    // "common", "organizations", "consumers", or a tenant ID
+
+	// Graph delta sync covers a fixed window around the sync date.
+	PastYears   int `toml:"past_years"`
+	FutureYears int `toml:"future_years"`
+}
+
 // SyncConfig holds sync-related configuration.
 type SyncConfig struct {
-	RateLimitQPS int `toml:"rate_limit_qps"`
+	// RateLimitQPS is how many requests go out per second; fractions pace
+	// slower than one a second (0.5 = one every two seconds).
+	RateLimitQPS float64 `toml:"rate_limit_qps"`
+	// RateLimitBurst is how many requests may go out at once after a
+	// quiet spell (0 = rate_limit_qps, one second's worth, at least 1).
+	RateLimitBurst int `toml:"rate_limit_burst"`
+	Concurrency    int `toml:"concurrency"` // Calendars synced at once per account
+
+	// AccountConcurrency is how many accounts a sync of every account runs
+	// at once. They share rate_limit_qps and the daily request budget.
+	AccountConcurrency int `toml:"account_concurrency"`
+
+	// DailyRequestBudget caps the Google Calendar API requests syncs make
+	// per local day, across accounts (0 = unlimited). Full syncs that run
+	// out resume from their checkpoint on a later day.
+	DailyRequestBudget int `toml:"daily_request_budget"`
+
+	// IncludeCalendars, if set, limits syncing to calendars whose ID or name
+	// matches one of the patterns; ExcludeCalendars skips matching calendars
+	// even if included. Patterns are globs as in path.Match.
+	IncludeCalendars []string `toml:"include_calendars"`
+	ExcludeCalendars []string `toml:"exclude_calendars"`
+
+	// Calendars holds per-calendar overrides ([[sync.calendar]] tables).
+	Calendars []CalendarSyncConfig `toml:"calendar"`
+
+	// Profile chooses which event details syncs store: minimal (titles
+	// and times), standard (also locations, organizers and reminders) or
+	// full (also descriptions, attendees and conferences; the default).
+	// Profiles sets it per account.
+	Profile  string            `toml:"profile"`
+	Profiles map[string]string `toml:"profiles"` // account email -> profile
+
+	// Changelog is a file every sync appends its event changes to, as a
+	// CBOR sequence, for other tools to tail; empty writes none. A relative
+	// path is in CALVAULT_HOME.
+	Changelog string `toml:"changelog"`
+}
+
+// CalendarSyncConfig overrides sync behavior for calendars whose ID or name
+// matches Match. The first matching entry applies.
+type CalendarSyncConfig struct {
+	Match string `toml:"match"` // Calendar ID or name; glob patterns as in path.Match
+
+	// Limit full syncs to a window around the sync date (0 = unbounded).
+	PastDays   int `toml:"past_days"`
+	FutureDays int `toml:"future_days"`
+
+	SingleEvents  bool    `toml:"single_events"`  // Expand recurring events into instances
+	SkipAttendees bool    `toml:"skip_attendees"` // Don't store attendee lists
+	RateLimitQPS  float64 `toml:"rate_limit_qps"` // Further throttle requests for this calendar
+}
+
+// syncProfiles are the valid [sync] profile values.
+var syncProfiles = []string{"minimal", "standard", "full"}
+
+// ProfileFor returns the sync profile for an account.
+func (c *SyncConfig) ProfileFor(account string) string {
+	if p, ok := c.Profiles[account]; ok {
+		return p
+	}
+	return c.Profile
+}
+
+// CalendarOverride returns the first [[sync.calendar]] entry matching the
+// calendar's ID or name, or nil if none does.
+func (c *SyncConfig) CalendarOverride(id, name string) *CalendarSyncConfig {
+	for i := range c.Calendars {
+		o := &c.Calendars[i]
+		if matchCalendar(o.Match, id, name) {
+			return o
+		}
+	}
+	return nil
+}
+
+// IncludeCalendar reports whether a calendar passes the include_calendars
+// and exclude_calendars filters.
+func (c *SyncConfig) IncludeCalendar(id, name string) bool {
+	if len(c.IncludeCalendars) > 0 && !matchAnyCalendar(c.IncludeCalendars, id, name) {
+		return false
+	}
+	return !matchAnyCalendar(c.ExcludeCalendars, id, name)
+}
+
+// matchCalendar reports whether pattern matches a calendar's ID or name.
+func matchCalendar(pattern, id, name string) bool {
+	if ok, _ := path.Match(pattern, id); ok {
+		return true
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+func matchAnyCalendar(patterns []string, id, name string) bool {
+	for _, p := range patterns {
+		if matchCalendar(p, id, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// DaemonConfig holds background sync schedules for `calvault daemon`.
+type DaemonConfig struct {
+	// Schedule is a cron expression (or @every <duration>) applied to
+	// accounts without their own entry in Accounts.
+	Schedule string            `toml:"schedule"`
+	Accounts map[string]string `toml:"accounts"` // account email -> schedule
+
+	// QuietHours are daily local-time windows, written "22:00-07:00",
+	// during which scheduled syncs are skipped.
+	QuietHours []string `toml:"quiet_hours"`
+
+	Push PushConfig `toml:"push"`
+
+	// MetricsListen is the local address the daemon serves Prometheus
+	// metrics on, at /metrics; empty disables them.
+	MetricsListen string `toml:"metrics_listen"`
+
+	// Alerts are [[daemon.alert]] rules checked after every sync.
+	Alerts []AlertConfig `toml:"alert"`
+
+	Digest DigestConfig `toml:"digest"`
+}
+
+// DigestConfig is [daemon.digest]: a daily summary of yesterday's meetings
+// and today's agenda, sent by email, webhook or both.
+type DigestConfig struct {
+	// Schedule is when the digest is sent, as a cron expression (default
+	// 7:00 every day).
+	Schedule string `toml:"schedule"`
+
+	// Template is a Go text/template file the digest is rendered with
+	// instead of the built-in one.
+	Template string `toml:"template"`
+
+	Webhook string     `toml:"webhook"` // URL the digest is POSTed to as JSON
+	SMTP    SMTPConfig `toml:"smtp"`
+}
+
+// Enabled reports whether the digest has somewhere to go.
+func (c *DigestConfig) Enabled() bool {
+	return c.Webhook != "" || c.SMTP.Host != ""
+}
+
+// SMTPConfig is the mail server the digest is sent through. STARTTLS is
+// used when the server offers it.
+type SMTPConfig struct {
+	Host     string   `toml:"host"`
+	Port     int      `toml:"port"` // Default 587
+	Username string   `toml:"username"`
+	From     string   `toml:"from"`
+	To       []string `toml:"to"`
+
+	// PasswordEnv names the environment variable holding the password, so
+	// it stays out of config.toml.
+	PasswordEnv string `toml:"password_env"`
+}
+
+// Password returns the password from the environment, or "" if none is
+// set.
+func (c *SMTPConfig) Password() string {
+	if c.PasswordEnv == "" {
+		return ""
+	}
+	return os.Getenv(c.PasswordEnv)
+}
+
+// AlertConfig is a [[daemon.alert]]: a notification whenever a sync adds,
+// changes or cancels an upcoming event matching Keywords.
+type AlertConfig struct {
+	Name string `toml:"name"` // Identifies the rule; renaming it starts over
+
+	// Keywords are matched in event titles, locations and descriptions,
+	// ignoring case. An event matching any of them alerts.
+	Keywords []string `toml:"keywords"`
+
+	// Calendars, if set, limits the rule to calendars whose ID or name
+	// matches one of the patterns (globs as in path.Match).
+	Calendars []string `toml:"calendars"`
+
+	// On lists the changes that alert: "added", "changed" and "cancelled"
+	// (default all three), or "moved" for changes to start or end times.
+	On []string `toml:"on"`
+
+	Webhook string `toml:"webhook"` // URL the alert is POSTed to as JSON
+	Command string `toml:"command"` // Shell command run with the alert in its environment
+
+	// Desktop raises a native notification on the machine the daemon runs
+	// on: Notification Center on macOS, a toast on Windows and notify-send
+	// on Linux.
+	Desktop bool `toml:"desktop"`
+}
+
+// IncludeCalendar reports whether the rule watches a calendar.
+func (c *AlertConfig) IncludeCalendar(id, name string) bool {
+	return len(c.Calendars) == 0 || matchAnyCalendar(c.Calendars, id, name)
+}
+
+// PushConfig enables Google Calendar push notifications in the daemon.
+// Google only delivers to public HTTPS URLs, so Address is typically a
+// reverse proxy or tunnel forwarding to Listen.
+type PushConfig struct {
+	Address string `toml:"address"` // Public webhook URL; empty disables push
+	Listen  string `toml:"listen"`  // Local address for the webhook receiver
+}
+
+// ScheduleFor returns the sync schedule for an account.
+func (c *DaemonConfig) ScheduleFor(account string) string {
+	if s, ok := c.Accounts[account]; ok {
+		return s
+	}
+	return c.Schedule
+}
+
+// OutputConfig holds terminal output settings.
+type OutputConfig struct {
+	Color string `toml:"color"` // "auto" (default), "always", or "never"
+
+	// Locale picks date, time and number conventions, e.g. "en_GB" or
+	// "de_DE"; "auto" (default) follows LC_ALL, LC_TIME or LANG. The
+	// remaining fields override single conventions of the locale.
+	Locale     string `toml:"locale"`
+	WeekStart  string `toml:"week_start"`  // Day name, e.g. "monday"
+	TimeFormat string `toml:"time_format"` // "12h" or "24h"
+	DateOrder  string `toml:"date_order"`  // "ymd", "dmy", or "mdy"
+
+	// LogFormat is how logs are written to stderr: "text" (default) or
+	// "json", one object per line for log shippers.
+	LogFormat string `toml:"log_format"`
+}
+
+// QueryConfig holds query executor configuration.
+type QueryConfig struct {
+	// AllowlistOnly rejects ad-hoc SQL; only named queries may run.
+	AllowlistOnly bool                        `toml:"allowlist_only"`
+	Named         map[string]NamedQueryConfig `toml:"named"`
+
+	// Account scopes every query to one account's data (sources.identifier).
+	Account string `toml:"account"`
+
+	// MaxRows caps the rows a query returns (default 10000; 0 = no cap).
+	MaxRows int `toml:"max_rows"`
+
+	// InstanceHorizonDays is how far ahead recurring series are expanded
+	// into event_instances (default 365; 0 = don't fill the table).
+	InstanceHorizonDays int `toml:"instance_horizon_days"`
+
+	// CacheMinutes is how long query results are reused while the data is
+	// unchanged (default 10; 0 = no caching).
+	CacheMinutes int `toml:"cache_minutes"`
+}
+
+// IdentityConfig is an [[identity]]: the email addresses of one person,
+// such as their personal and work addresses. Attendee analytics count them
+// as one person, under the first email, and an attendee with any of them
+// is is_self on events of an account whose address is among them.
+type IdentityConfig struct {
+	Name   string   `toml:"name"`
+	Emails []string `toml:"emails"`
+}
+
+// validateIdentities checks that every [[identity]] has an email and that
+// no email is in two of them.
+func validateIdentities(ids []IdentityConfig) error {
+	seen := map[string]int{}
+	for i, id := range ids {
+		if len(id.Emails) == 0 {
+			return fmt.Errorf("identity %d: emails is required", i+1)
+		}
+		for _, email := range id.Emails {
+			key := strings.ToLower(strings.TrimSpace(email))
+			if !strings.Contains(key, "@") {
+				return fmt.Errorf("identity %d: invalid email %q", i+1, email)
+			}
+			if other, ok := seen[key]; ok && other != i {
+				return fmt.Errorf("identity %d: %s is also in identity %d", i+1, email, other+1)
+			}
+			seen[key] = i
+		}
+	}
+	return nil
+}
+
+// EntitiesConfig sets up 'calvault enrich', which extracts entities such as
+// doctors, companies and project codes from event titles and descriptions.
+type EntitiesConfig struct {
+	// Extractor is "rules" (the default) or "llm", which uses [llm].
+	Extractor string             `toml:"extractor"`
+	Rules     []EntityRuleConfig `toml:"rule"`
+
+	// MinConfidence drops entities the extractor is less sure of (0-1).
+	MinConfidence float64 `toml:"min_confidence"`
+}
+
+// EntityRuleConfig is an [[entities.rule]]: a regular expression whose
+// first capture group, or whole match, names an entity of Kind.
+type EntityRuleConfig struct {
+	Kind       string  `toml:"kind"`
+	Pattern    string  `toml:"pattern"`
+	Confidence float64 `toml:"confidence"` // Default 1
+}
+
+// EnrichStages are the enrichment stages, in the order they run by
+// default.
+var EnrichStages = []string{"platform", "tags", "entities", "geocode"}
+
+// EnrichConfig sets up the enrichment stages 'calvault enrich' runs:
+// platform detection, [[enrich.tag]] rules, entity extraction (see
+// [entities]) and geocoding.
+type EnrichConfig struct {
+	// Stages lists the stages to run, in order. Empty runs every stage
+	// that is set up, in the order of EnrichStages.
+	Stages []string `toml:"stages"`
+
+	// AfterSync runs the stages after every sync, import and daemon sync,
+	// on the events they added or changed.
+	AfterSync bool `toml:"after_sync"`
+
+	Tags    []TagRuleConfig `toml:"tag"`
+	Geocode GeocodeConfig   `toml:"geocode"`
+}
+
+// TagRuleConfig is an [[enrich.tag]]: events whose title, description or
+// location match Pattern get Tag.
+type TagRuleConfig struct {
+	Tag     string `toml:"tag"`
+	Pattern string `toml:"pattern"`
+}
+
+// GeocodeConfig points the geocode stage at a Nominatim-compatible search
+// API, e.g. "https://nominatim.openstreetmap.org/search". Event locations
+// are sent to it; the stage is off without an endpoint.
+type GeocodeConfig struct {
+	Endpoint string `toml:"endpoint"`
+}
+
+// LLMConfig points 'calvault ask' at a chat completions endpoint speaking
+// the OpenAI API, which most hosted and local model servers offer.
+type LLMConfig struct {
+	// Endpoint is the API base URL, e.g. "https://api.openai.com/v1" or
+	// "http://localhost:11434/v1" for Ollama.
+	Endpoint string `toml:"endpoint"`
+	Model    string `toml:"model"`
+
+	// APIKeyEnv names the environment variable holding the API key, so the
+	// key stays out of config.toml. Local servers usually need none.
+	APIKeyEnv string `toml:"api_key_env"`
+}
+
+// Enabled reports whether an endpoint is configured.
+func (c *LLMConfig) Enabled() bool {
+	return c.Endpoint != ""
 }
 
-// DefaultHome returns the default calvault home directory.
-// Respects CALVAULT_HOME environment variable.
+// APIKey returns the API key from the environment, or "" if none is set.
+func (c *LLMConfig) APIKey() string {
+	if c.APIKeyEnv == "" {
+		return ""
+	}
+	return os.Getenv(c.APIKeyEnv)
+}
+
+// NamedQueryConfig defines a pre-registered query with typed parameters.
+type NamedQueryConfig struct {
+	Description string            `toml:"description"`
+	SQL         string            `toml:"sql"`
+	Params      map[string]string `toml:"params"` // parameter name -> type
+}
+
+// DefaultHome returns the default calvault home directory: CALVAULT_HOME if
+// set, otherwise ~/.calvault. On Windows it is %APPDATA%\calvault unless a
+// ~/.calvault directory already exists.
 func DefaultHome() string {
-	if h := os.Getenv("CALVAULT_HOME"); h != "" {
+	home, _ := os.UserHomeDir()
+	return defaultHome(runtime.GOOS, os.Getenv, home, dirExists)
+}
+
+// defaultHome implements DefaultHome for the given platform and environment.
+func defaultHome(goos string, getenv func(string) string, userHome string, exists func(string) bool) string {
+	if h := getenv("CALVAULT_HOME"); h != "" {
 		return h
 	}
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return ".calvault"
+	legacy := ".calvault"
+	if userHome != "" {
+		legacy = filepath.Join(userHome, ".calvault")
+	}
+	if goos == "windows" && !exists(legacy) {
+		if appData := getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "calvault")
+		}
 	}
-	return filepath.Join(home, ".calvault")
+	return legacy
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
 }
 
 // Load reads the configuration from the specified file.
 // If path is empty, uses the default location (~/.calvault/config.toml).
 func Load(path string) (*Config, error) {
-	homeDir := DefaultHome()
-
-	if path == "" {
-		path = filepath.Join(homeDir, "config.toml")
-	}
-
-	cfg := &Config{
-		HomeDir: homeDir,
-		Sync: SyncConfig{
-			RateLimitQPS: 10,
-		},
-	}
+	cfg := Default(path)
+	path = cfg.File
 
 	// Config file is optional - use defaults if not present
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -66,33 +736,516 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("decode config: %w", err)
 	}
 
+	if err := cfg.Sync.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Output.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Query.MaxRows < 0 {
+		return nil, fmt.Errorf("query.max_rows: must not be negative")
+	}
+	if cfg.Query.InstanceHorizonDays < 0 {
+		return nil, fmt.Errorf("query.instance_horizon_days: must not be negative")
+	}
+	if cfg.Query.CacheMinutes < 0 {
+		return nil, fmt.Errorf("query.cache_minutes: must not be negative")
+	}
+	if cfg.Storage.TrashDays < 0 {
+		return nil, fmt.Errorf("storage.trash_days: must not be negative")
+	}
+	if cfg.Storage.Retention != "" {
+		if _, err := ParseAge(cfg.Storage.Retention); err != nil {
+			return nil, fmt.Errorf("storage.retention: %w", err)
+		}
+		if cfg.Storage.LegalHold {
+			return nil, fmt.Errorf("storage.retention: events can't be deleted under legal_hold")
+		}
+	}
+	if err := cfg.Team.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Interviews.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.WorkHours.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.LLM.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Daemon.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Entities.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Enrich.validate(); err != nil {
+		return nil, err
+	}
+	if err := validateIdentities(cfg.Identities); err != nil {
+		return nil, err
+	}
+
 	// Expand ~ in paths
 	cfg.OAuth.ClientSecrets = expandPath(cfg.OAuth.ClientSecrets)
+	cfg.Storage.Database = expandPath(cfg.Storage.Database)
+	cfg.Daemon.Digest.Template = expandPath(cfg.Daemon.Digest.Template)
+	cfg.Sync.Changelog = expandPath(cfg.Sync.Changelog)
 
 	return cfg, nil
 }
 
+// Default returns the configuration used when the file at path (the
+// default location if empty) doesn't exist.
+func Default(path string) *Config {
+	homeDir := DefaultHome()
+	if path == "" {
+		path = filepath.Join(homeDir, "config.toml")
+	}
+	return &Config{
+		HomeDir: homeDir,
+		File:    path,
+		Microsoft: MicrosoftConfig{
+			Tenant:      "common",
+			PastYears:   10,
+			FutureYears: 2,
+		},
+		Sync: SyncConfig{
+			RateLimitQPS:       10,
+			Concurrency:        4,
+			AccountConcurrency: 2,
+			Profile:            "full",
+		},
+		Query: QueryConfig{
+			MaxRows:             10000,
+			InstanceHorizonDays: 365,
+			CacheMinutes:        10,
+		},
+		Output: OutputConfig{
+			Color:  "auto",
+			Locale: "auto",
+		},
+		Team: TeamConfig{
+			MinGroupSize: 3,
+		},
+		Interviews: InterviewsConfig{
+			Titles: []string{"*interview*", "*phone screen*"},
+		},
+		WorkHours: WorkHoursConfig{
+			Start: "09:00",
+			End:   "17:00",
+			Days:  []string{"mon", "tue", "wed", "thu", "fri"},
+		},
+		Storage: StorageConfig{
+			TrashDays: 30,
+		},
+		Daemon: DaemonConfig{
+			Schedule: "@every 30m",
+			Push: PushConfig{
+				Listen: "127.0.0.1:8765",
+			},
+			Digest: DigestConfig{
+				Schedule: "0 7 * * *",
+				SMTP:     SMTPConfig{Port: 587},
+			},
+		},
+	}
+}
+
+// UnknownKeys returns the keys in the config file at path that no setting
+// reads, such as misspelled ones, which Load ignores.
+func UnknownKeys(path string) ([]string, error) {
+	var cfg Config
+	md, err := toml.DecodeFile(path, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("decode config: %w", err)
+	}
+	var keys []string
+	for _, key := range md.Undecoded() {
+		keys = append(keys, key.String())
+	}
+	return keys, nil
+}
+
+// validate checks the rate limit, the calendar filter and [[sync.calendar]]
+// match patterns.
+func (c *SyncConfig) validate() error {
+	if c.RateLimitQPS <= 0 {
+		return fmt.Errorf("sync.rate_limit_qps: must be positive")
+	}
+	if c.RateLimitBurst < 0 {
+		return fmt.Errorf("sync.rate_limit_burst: must not be negative")
+	}
+	if c.AccountConcurrency <= 0 {
+		return fmt.Errorf("sync.account_concurrency: must be positive")
+	}
+	if c.DailyRequestBudget < 0 {
+		return fmt.Errorf("sync.daily_request_budget: must not be negative")
+	}
+	if !slices.Contains(syncProfiles, c.Profile) {
+		return fmt.Errorf("sync.profile: unknown profile %q (expected minimal, standard or full)", c.Profile)
+	}
+	for account, p := range c.Profiles {
+		if !slices.Contains(syncProfiles, p) {
+			return fmt.Errorf("sync.profiles: unknown profile %q for %s (expected minimal, standard or full)", p, account)
+		}
+	}
+
+	filters := []struct {
+		key      string
+		patterns []string
+	}{
+		{"include_calendars", c.IncludeCalendars},
+		{"exclude_calendars", c.ExcludeCalendars},
+	}
+	for _, f := range filters {
+		for _, p := range f.patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("sync.%s: invalid pattern %q: %w", f.key, p, err)
+			}
+		}
+	}
+
+	for _, o := range c.Calendars {
+		if o.Match == "" {
+			return fmt.Errorf("sync.calendar: match is required")
+		}
+		if _, err := path.Match(o.Match, ""); err != nil {
+			return fmt.Errorf("sync.calendar: invalid match %q: %w", o.Match, err)
+		}
+	}
+	return nil
+}
+
+// validate checks the private_calendars patterns.
+func (c *TeamConfig) validate() error {
+	for _, p := range c.PrivateCalendars {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("team.private_calendars: invalid pattern %q: %w", p, err)
+		}
+	}
+	seen := map[string]string{}
+	for group, members := range c.Groups {
+		if group == "" {
+			return fmt.Errorf("team.groups: empty group name")
+		}
+		for _, m := range members {
+			if other, ok := seen[m]; ok && other != group {
+				return fmt.Errorf("team.groups: %s is in both %s and %s", m, other, group)
+			}
+			seen[m] = group
+		}
+	}
+	for _, d := range c.MeetingFreeDays {
+		if _, ok := parseWeekday(d); !ok {
+			return fmt.Errorf("team.meeting_free_days: invalid day %q (expected a day name such as wed)", d)
+		}
+	}
+	if c.MinGroupSize < 1 {
+		return fmt.Errorf("team.min_group_size: must be at least 1")
+	}
+	return nil
+}
+
+// validate checks the title and attendee patterns.
+func (c *InterviewsConfig) validate() error {
+	filters := []struct {
+		key      string
+		patterns []string
+	}{
+		{"titles", c.Titles},
+		{"attendees", c.Attendees},
+	}
+	for _, f := range filters {
+		for _, p := range f.patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("interviews.%s: invalid pattern %q: %w", f.key, p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks the times, day names and time zone.
+func (c *WorkHoursConfig) validate() error {
+	start, err := parseClock(c.Start)
+	if err != nil {
+		return fmt.Errorf("work_hours.start: invalid time %q (expected HH:MM)", c.Start)
+	}
+	end, err := parseClock(c.End)
+	if err != nil {
+		return fmt.Errorf("work_hours.end: invalid time %q (expected HH:MM)", c.End)
+	}
+	if end <= start {
+		return fmt.Errorf("work_hours: end %s is not after start %s", c.End, c.Start)
+	}
+	for _, d := range c.Days {
+		if _, ok := parseWeekday(d); !ok {
+			return fmt.Errorf("work_hours.days: invalid day %q (expected a day name such as mon)", d)
+		}
+	}
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("work_hours.timezone: %w", err)
+		}
+	}
+	return nil
+}
+
+// validate checks that a configured endpoint is an HTTP URL with a model.
+// validate checks the extractor name, rule patterns and confidences.
+func (c *EntitiesConfig) validate() error {
+	switch c.Extractor {
+	case "", "rules", "llm":
+	default:
+		return fmt.Errorf("entities.extractor: unknown extractor %q (expected rules or llm)", c.Extractor)
+	}
+	if c.MinConfidence < 0 || c.MinConfidence > 1 {
+		return fmt.Errorf("entities.min_confidence: must be between 0 and 1")
+	}
+	for _, r := range c.Rules {
+		if r.Kind == "" {
+			return fmt.Errorf("entities.rule: kind is required")
+		}
+		if _, err := regexp.Compile(r.Pattern); err != nil || r.Pattern == "" {
+			return fmt.Errorf("entities.rule: invalid pattern %q for %s", r.Pattern, r.Kind)
+		}
+		if r.Confidence < 0 || r.Confidence > 1 {
+			return fmt.Errorf("entities.rule: confidence for %s must be between 0 and 1", r.Kind)
+		}
+	}
+	return nil
+}
+
+// validate checks the stage names, tag rules and geocoder endpoint.
+func (c *EnrichConfig) validate() error {
+	seen := map[string]bool{}
+	for _, stage := range c.Stages {
+		if !slices.Contains(EnrichStages, stage) {
+			return fmt.Errorf("enrich.stages: unknown stage %q (expected %s)", stage, strings.Join(EnrichStages, ", "))
+		}
+		if seen[stage] {
+			return fmt.Errorf("enrich.stages: %s is listed twice", stage)
+		}
+		seen[stage] = true
+	}
+	for _, t := range c.Tags {
+		if t.Tag == "" {
+			return fmt.Errorf("enrich.tag: tag is required")
+		}
+		if _, err := regexp.Compile(t.Pattern); err != nil || t.Pattern == "" {
+			return fmt.Errorf("enrich.tag: invalid pattern %q for %s", t.Pattern, t.Tag)
+		}
+	}
+	if c.Geocode.Endpoint != "" {
+		u, err := url.Parse(c.Geocode.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("enrich.geocode.endpoint: invalid URL %q (expected http:// or https://)", c.Geocode.Endpoint)
+		}
+	}
+	return nil
+}
+
+func (c *DaemonConfig) validate() error {
+	names := map[string]bool{}
+	for _, a := range c.Alerts {
+		if a.Name == "" {
+			return fmt.Errorf("daemon.alert: name is required")
+		}
+		if names[a.Name] {
+			return fmt.Errorf("daemon.alert: duplicate name %q", a.Name)
+		}
+		names[a.Name] = true
+		if len(a.Keywords) == 0 {
+			return fmt.Errorf("daemon.alert: keywords are required for %s", a.Name)
+		}
+		for _, k := range a.Keywords {
+			if strings.TrimSpace(k) == "" {
+				return fmt.Errorf("daemon.alert: empty keyword for %s", a.Name)
+			}
+		}
+		for _, p := range a.Calendars {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("daemon.alert: invalid calendar pattern %q for %s", p, a.Name)
+			}
+		}
+		for _, on := range a.On {
+			switch on {
+			case "added", "changed", "moved", "cancelled":
+			default:
+				return fmt.Errorf("daemon.alert: unknown change %q for %s (expected added, changed, moved or cancelled)", on, a.Name)
+			}
+		}
+		if a.Webhook == "" && a.Command == "" && !a.Desktop {
+			return fmt.Errorf("daemon.alert: %s needs a webhook, a command or desktop = true", a.Name)
+		}
+		if a.Webhook != "" {
+			u, err := url.Parse(a.Webhook)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("daemon.alert: invalid webhook URL %q for %s (expected http:// or https://)", a.Webhook, a.Name)
+			}
+		}
+	}
+	return c.Digest.validate()
+}
+
+func (c *DigestConfig) validate() error {
+	if c.Webhook != "" {
+		u, err := url.Parse(c.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("daemon.digest: invalid webhook URL %q (expected http:// or https://)", c.Webhook)
+		}
+	}
+	if c.SMTP.Host == "" {
+		return nil
+	}
+	if c.SMTP.Port < 1 || c.SMTP.Port > 65535 {
+		return fmt.Errorf("daemon.digest.smtp: invalid port %d", c.SMTP.Port)
+	}
+	if c.SMTP.From == "" || len(c.SMTP.To) == 0 {
+		return fmt.Errorf("daemon.digest.smtp: from and to are required")
+	}
+	return nil
+}
+
+func (c *LLMConfig) validate() error {
+	if c.Endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(c.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("llm.endpoint: invalid URL %q (expected http:// or https://)", c.Endpoint)
+	}
+	if c.Model == "" {
+		return fmt.Errorf("llm.model: required when llm.endpoint is set")
+	}
+	return nil
+}
+
+// validate checks the enumerated [output] settings.
+func (c *OutputConfig) validate() error {
+	switch c.Color {
+	case "auto", "always", "never":
+	default:
+		return fmt.Errorf("output.color: invalid value %q (expected auto, always, or never)", c.Color)
+	}
+	switch c.TimeFormat {
+	case "", "12h", "24h":
+	default:
+		return fmt.Errorf("output.time_format: invalid value %q (expected 12h or 24h)", c.TimeFormat)
+	}
+	switch c.DateOrder {
+	case "", "ymd", "dmy", "mdy":
+	default:
+		return fmt.Errorf("output.date_order: invalid value %q (expected ymd, dmy, or mdy)", c.DateOrder)
+	}
+	switch c.LogFormat {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("output.log_format: invalid value %q (expected text or json)", c.LogFormat)
+	}
+	switch strings.ToLower(c.WeekStart) {
+	case "", "sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday":
+	default:
+		return fmt.Errorf("output.week_start: invalid value %q (expected a day name such as monday)", c.WeekStart)
+	}
+	return nil
+}
+
 // DatabasePath returns the path to the SQLite database.
 func (c *Config) DatabasePath() string {
+	if c.Storage.Database != "" {
+		return c.Storage.Database
+	}
 	return filepath.Join(c.HomeDir, "calvault.db")
 }
 
+// ChangelogPath returns the path of the [sync] changelog, or "" if syncs
+// don't write one.
+func (c *Config) ChangelogPath() string {
+	if c.Sync.Changelog == "" || filepath.IsAbs(c.Sync.Changelog) {
+		return c.Sync.Changelog
+	}
+	return filepath.Join(c.HomeDir, c.Sync.Changelog)
+}
+
 // TokensDir returns the path to the OAuth tokens directory.
 func (c *Config) TokensDir() string {
 	return filepath.Join(c.HomeDir, "tokens")
 }
 
-// expandPath expands ~ to the user's home directory.
+// QueryCacheDir returns the path to the directory of cached query results.
+func (c *Config) QueryCacheDir() string {
+	return filepath.Join(c.HomeDir, "cache", "query")
+}
+
+// SyncLockPath returns the path to the lock file held while a sync runs.
+func (c *Config) SyncLockPath() string {
+	return filepath.Join(c.HomeDir, "sync.lock")
+}
+
+// DaemonLockPath returns the path to the lock file held by a running daemon.
+func (c *Config) DaemonLockPath() string {
+	return filepath.Join(c.HomeDir, "daemon.lock")
+}
+
+// PausePath returns the path to the file that pauses daemon syncs.
+func (c *Config) PausePath() string {
+	return filepath.Join(c.HomeDir, "sync.pause")
+}
+
+// MicrosoftTokensDir returns the path to the Microsoft OAuth tokens directory.
+func (c *Config) MicrosoftTokensDir() string {
+	return filepath.Join(c.TokensDir(), "microsoft")
+}
+
+// expandPath expands a leading ~ to the user's home directory and, on
+// Windows, %VAR% environment references such as %APPDATA%.
 func expandPath(path string) string {
-	if path == "" {
+	home, _ := os.UserHomeDir()
+	return expandPathFor(runtime.GOOS, path, os.Getenv, home)
+}
+
+// expandPathFor implements expandPath for the given platform and environment.
+func expandPathFor(goos, path string, getenv func(string) string, userHome string) string {
+	if goos == "windows" {
+		path = expandWindowsEnv(path, getenv)
+	}
+	rest, ok := strings.CutPrefix(path, "~")
+	if !ok || userHome == "" {
 		return path
 	}
-	if path[0] == '~' {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return path
-		}
-		return filepath.Join(home, path[1:])
+	// Only "~" and "~/..." (or "~\..." on Windows); "~user" is left alone
+	if rest == "" || rest[0] == '/' || (goos == "windows" && rest[0] == '\\') {
+		return filepath.Join(userHome, rest)
 	}
 	return path
 }
+
+// expandWindowsEnv replaces %VAR% with the variable's value. Unset variables
+// and unpaired percent signs are kept as written, as cmd.exe does.
+func expandWindowsEnv(path string, getenv func(string) string) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(path, '%')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(path[start+1:], '%')
+		if end < 0 {
+			break
+		}
+		end += start + 1
+		name := path[start+1 : end]
+		if v := getenv(name); name != "" && v != "" {
+			b.WriteString(path[:start] + v)
+			path = path[end+1:]
+			continue
+		}
+		// Keep the first % and rescan from the second, which may open a variable
+		b.WriteString(path[:end])
+		path = path[end:]
+	}
+	return b.String() + path
+}
@@ -0,0 +1,889 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad_CalendarOverrides(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.toml")
+	err := os.WriteFile(path, []byte(`
+[[sync.calendar]]
+match = "*@group.calendar.google.com"
+past_days = 90
+skip_attendees = true
+
+[[sync.calendar]]
+match = "Team*"
+single_events = true
+rate_limit_qps = 2
+`), 0o600)
+	if err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	tests := []struct {
+		id, name string
+		want     string // matching pattern, "" for none
+	}{
+		{"eng@group.calendar.google.com", "Engineering", "*@group.calendar.google.com"},
+		{"abc@example.com", "Team Offsites", "Team*"},
+		{"primary", "Me", ""},
+	}
+	for _, tt := range tests {
+		got := cfg.Sync.CalendarOverride(tt.id, tt.name)
+		switch {
+		case tt.want == "" && got != nil:
+			t.Errorf("CalendarOverride(%q, %q) = %q, want none", tt.id, tt.name, got.Match)
+		case tt.want != "" && (got == nil || got.Match != tt.want):
+			t.Errorf("CalendarOverride(%q, %q) = %v, want %q", tt.id, tt.name, got, tt.want)
+		}
+	}
+
+	if o := cfg.Sync.CalendarOverride("x@group.calendar.google.com", ""); o.PastDays != 90 || !o.SkipAttendees {
+		t.Errorf("override = %+v, want past_days 90 and skip_attendees", o)
+	}
+}
+
+func TestLoad_InvalidCalendarMatch(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[[sync.calendar]]\nmatch = \"[team\"\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "invalid match") {
+		t.Errorf("load error = %v, want invalid match", err)
+	}
+}
+
+func TestLoad_CalendarFilters(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.toml")
+	err := os.WriteFile(path, []byte(`
+[sync]
+include_calendars = ["primary", "*@group.calendar.google.com", "Team*"]
+exclude_calendars = ["*#holiday@group.v.calendar.google.com", "Team Social"]
+`), 0o600)
+	if err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	tests := []struct {
+		id, name string
+		want     bool
+	}{
+		{"primary", "me@example.com", true},
+		{"eng@group.calendar.google.com", "Engineering", true},
+		{"abc@example.com", "Team Offsites", true},
+		{"abc@example.com", "Team Social", false},
+		{"en.usa#holiday@group.v.calendar.google.com", "Holidays in United States", false},
+		{"other@example.com", "Someone Else", false},
+	}
+	for _, tt := range tests {
+		if got := cfg.Sync.IncludeCalendar(tt.id, tt.name); got != tt.want {
+			t.Errorf("IncludeCalendar(%q, %q) = %v, want %v", tt.id, tt.name, got, tt.want)
+		}
+	}
+
+	// Without include_calendars, everything not excluded is synced.
+	cfg.Sync.IncludeCalendars = nil
+	if !cfg.Sync.IncludeCalendar("other@example.com", "Someone Else") {
+		t.Error("IncludeCalendar with no include list = false, want true")
+	}
+}
+
+func TestLoad_InvalidCalendarFilter(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[sync]\nexclude_calendars = [\"[team\"]\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "exclude_calendars") {
+		t.Errorf("load error = %v, want exclude_calendars error", err)
+	}
+}
+
+func TestLoad_Team(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.toml")
+	err := os.WriteFile(path, []byte(`
+[team]
+member = "alice@example.com"
+private_calendars = ["Personal", "*#contacts@group.v.calendar.google.com"]
+`), 0o600)
+	if err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.Team.Enabled() {
+		t.Error("team mode not enabled")
+	}
+	if !cfg.Team.IsPrivateCalendar("abc@group.calendar.google.com", "Personal") {
+		t.Error("Personal calendar not private")
+	}
+	if cfg.Team.IsPrivateCalendar("primary", "alice@example.com") {
+		t.Error("primary calendar private, want shared")
+	}
+
+	if err := os.WriteFile(path, []byte("[team]\nprivate_calendars = [\"[x\"]\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "team.private_calendars") {
+		t.Errorf("load error = %v, want team.private_calendars error", err)
+	}
+}
+
+func TestLoad_TeamCapacity(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.toml")
+	err := os.WriteFile(path, []byte(`
+[team]
+member = "alice"
+meeting_free_days = ["wed"]
+
+[team.groups]
+platform = ["alice", "bob"]
+design = ["carol"]
+`), 0o600)
+	if err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Team.MinGroupSize != 3 {
+		t.Errorf("min_group_size = %d, want default 3", cfg.Team.MinGroupSize)
+	}
+	if got := cfg.Team.GroupOf("bob"); got != "platform" {
+		t.Errorf("group of bob = %q, want platform", got)
+	}
+	if got := cfg.Team.GroupOf("dave"); got != "" {
+		t.Errorf("group of dave = %q, want none", got)
+	}
+	if days := cfg.Team.MeetingFreeWeekdays(); !days[time.Wednesday] || days[time.Monday] {
+		t.Errorf("meeting-free days = %v, want only Wednesday", days)
+	}
+
+	invalid := []struct {
+		config, want string
+	}{
+		{"[team]\nmeeting_free_days = [\"someday\"]\n", "team.meeting_free_days"},
+		{"[team]\nmin_group_size = 0\n", "team.min_group_size"},
+		{"[team.groups]\na = [\"alice\"]\nb = [\"alice\"]\n", "alice is in both"},
+	}
+	for _, tt := range invalid {
+		if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("load %q error = %v, want %s", tt.config, err, tt.want)
+		}
+	}
+}
+
+func TestInterviewsConfig(t *testing.T) {
+	c := &InterviewsConfig{
+		Titles:    []string{"*interview*"},
+		Attendees: []string{"*@ats.example"},
+	}
+	attendees := []struct {
+		email, account string
+		want           bool
+	}{
+		{"dana@company.com", "me@company.com", true},
+		{"Dana@COMPANY.com", "me@company.com", true},
+		{"candidate@gmail.com", "me@company.com", false},
+		{"scheduler@ats.example", "me@company.com", false},
+	}
+	for _, tt := range attendees {
+		if got := c.IsInterviewer(tt.email, tt.account); got != tt.want {
+			t.Errorf("IsInterviewer(%q, %q) = %v, want %v", tt.email, tt.account, got, tt.want)
+		}
+	}
+	if !c.IsInterview("Onsite INTERVIEW: Backend", nil) {
+		t.Error("title match not an interview")
+	}
+	if !c.IsInterview("Chat", []string{"me@company.com", "Scheduler@ATS.example"}) {
+		t.Error("attendee match not an interview")
+	}
+	if c.IsInterview("1:1", []string{"me@company.com"}) {
+		t.Error("1:1 is an interview")
+	}
+
+	c.InterviewerDomains = []string{"partner.com"}
+	if c.IsInterviewer("dana@company.com", "me@company.com") {
+		t.Error("interviewer_domains not applied")
+	}
+}
+
+func TestLoad_WorkHours(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.toml")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load defaults: %v", err)
+	}
+	if start, end := cfg.WorkHours.Span(); start != 9*time.Hour || end != 17*time.Hour {
+		t.Errorf("default span = %v-%v, want 9h-17h", start, end)
+	}
+	if days := cfg.WorkHours.Weekdays(); days[time.Saturday] || !days[time.Monday] {
+		t.Errorf("default weekdays = %v, want Monday to Friday", days)
+	}
+
+	tests := []struct {
+		config string
+		want   string // Error substring; "" for valid
+	}{
+		{"[work_hours]\nstart = \"08:30\"\nend = \"16:30\"\ndays = [\"sunday\", \"Mon\"]\ntimezone = \"UTC\"\n", ""},
+		{"[work_hours]\nstart = \"9am\"\n", "work_hours.start"},
+		{"[work_hours]\nstart = \"18:00\"\n", "not after start"},
+		{"[work_hours]\ndays = [\"funday\"]\n", "work_hours.days"},
+		{"[work_hours]\ntimezone = \"Mars/Olympus\"\n", "work_hours.timezone"},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		cfg, err := Load(path)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("%q: %v", tt.config, err)
+				continue
+			}
+			if start, _ := cfg.WorkHours.Span(); start != 8*time.Hour+30*time.Minute {
+				t.Errorf("start = %v, want 8h30m", start)
+			}
+			if days := cfg.WorkHours.Weekdays(); !days[time.Sunday] || !days[time.Monday] || days[time.Tuesday] {
+				t.Errorf("weekdays = %v, want Sunday and Monday", days)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: error = %v, want %s", tt.config, err, tt.want)
+		}
+	}
+}
+
+func TestLoad_InvalidOutput(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{"[output]\ncolor = \"sometimes\"\n", "output.color"},
+		{"[output]\ntime_format = \"military\"\n", "output.time_format"},
+		{"[output]\ndate_order = \"dym\"\n", "output.date_order"},
+		{"[output]\nlog_format = \"logfmt\"\n", "output.log_format"},
+		{"[output]\nweek_start = \"funday\"\n", "output.week_start"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("load %q error = %v, want %s error", tt.config, err, tt.want)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[output]\nlocale = \"de_DE\"\nweek_start = \"Sunday\"\ntime_format = \"12h\"\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := Load(path); err != nil {
+		t.Errorf("load valid output config: %v", err)
+	}
+}
+
+func TestLoad_QueryMaxRows(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	tests := []struct {
+		config  string
+		want    int
+		wantErr bool
+	}{
+		{"", 10000, false},
+		{"[query]\nmax_rows = 0\n", 0, false},
+		{"[query]\nmax_rows = 500\n", 500, false},
+		{"[query]\nmax_rows = -1\n", 0, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		cfg, err := Load(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("load %q error = %v, wantErr %v", tt.config, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.Query.MaxRows != tt.want {
+			t.Errorf("load %q max_rows = %d, want %d", tt.config, cfg.Query.MaxRows, tt.want)
+		}
+	}
+}
+
+func TestLoad_QueryInstanceHorizonDays(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	tests := []struct {
+		config  string
+		want    int
+		wantErr bool
+	}{
+		{"", 365, false},
+		{"[query]\ninstance_horizon_days = 0\n", 0, false},
+		{"[query]\ninstance_horizon_days = 730\n", 730, false},
+		{"[query]\ninstance_horizon_days = -1\n", 0, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		cfg, err := Load(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("load %q error = %v, wantErr %v", tt.config, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.Query.InstanceHorizonDays != tt.want {
+			t.Errorf("load %q instance_horizon_days = %d, want %d", tt.config, cfg.Query.InstanceHorizonDays, tt.want)
+		}
+	}
+}
+
+func TestLoad_QueryCacheMinutes(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	tests := []struct {
+		config  string
+		want    int
+		wantErr bool
+	}{
+		{"", 10, false},
+		{"[query]\ncache_minutes = 0\n", 0, false},
+		{"[query]\ncache_minutes = 60\n", 60, false},
+		{"[query]\ncache_minutes = -1\n", 0, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		cfg, err := Load(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("load %q error = %v, wantErr %v", tt.config, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.Query.CacheMinutes != tt.want {
+			t.Errorf("load %q cache_minutes = %d, want %d", tt.config, cfg.Query.CacheMinutes, tt.want)
+		}
+	}
+}
+
+func TestLoad_Entities(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{"empty", "", false},
+		{"rules", "[entities]\nmin_confidence = 0.5\n[[entities.rule]]\nkind = \"project\"\npattern = '\\b[A-Z]{2,5}-\\d+\\b'\nconfidence = 0.8\n", false},
+		{"llm", "[entities]\nextractor = \"llm\"\n", false},
+		{"unknown extractor", "[entities]\nextractor = \"spacy\"\n", true},
+		{"bad pattern", "[[entities.rule]]\nkind = \"x\"\npattern = \"(\"\n", true},
+		{"missing kind", "[[entities.rule]]\npattern = \"x\"\n", true},
+		{"bad confidence", "[[entities.rule]]\nkind = \"x\"\npattern = \"x\"\nconfidence = 2\n", true},
+		{"bad min confidence", "[entities]\nmin_confidence = -0.1\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+				t.Fatalf("write config: %v", err)
+			}
+			cfg, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("load error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.name == "rules" && (len(cfg.Entities.Rules) != 1 || cfg.Entities.Rules[0].Pattern != `\b[A-Z]{2,5}-\d+\b`) {
+				t.Errorf("rules = %+v", cfg.Entities.Rules)
+			}
+		})
+	}
+}
+
+func TestLoad_Enrich(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{"empty", "", false},
+		{"stages", "[enrich]\nstages = [\"tags\", \"platform\"]\nafter_sync = true\n[[enrich.tag]]\ntag = \"health\"\npattern = '(?i)dentist'\n", false},
+		{"geocode", "[enrich.geocode]\nendpoint = \"https://nominatim.openstreetmap.org/search\"\n", false},
+		{"unknown stage", "[enrich]\nstages = [\"weather\"]\n", true},
+		{"duplicate stage", "[enrich]\nstages = [\"tags\", \"tags\"]\n", true},
+		{"missing tag", "[[enrich.tag]]\npattern = \"x\"\n", true},
+		{"bad pattern", "[[enrich.tag]]\ntag = \"x\"\npattern = \"(\"\n", true},
+		{"bad endpoint", "[enrich.geocode]\nendpoint = \"nominatim\"\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+				t.Fatalf("write config: %v", err)
+			}
+			cfg, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("load error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.name == "stages" && (len(cfg.Enrich.Stages) != 2 || !cfg.Enrich.AfterSync || len(cfg.Enrich.Tags) != 1) {
+				t.Errorf("enrich = %+v", cfg.Enrich)
+			}
+		})
+	}
+}
+
+func TestLoad_DaemonAlerts(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	rule := "[[daemon.alert]]\nname = \"dentist\"\nkeywords = [\"dentist\"]\n"
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{"webhook", rule + "webhook = \"https://hooks.example.com/x\"\non = [\"added\", \"changed\"]\ncalendars = [\"Family*\"]\n", false},
+		{"command", rule + "command = \"notify-send hi\"\n", false},
+		{"desktop", rule + "desktop = true\non = [\"added\", \"moved\"]\n", false},
+		{"no target", rule, true},
+		{"no keywords", "[[daemon.alert]]\nname = \"x\"\ncommand = \"true\"\n", true},
+		{"no name", "[[daemon.alert]]\nkeywords = [\"x\"]\ncommand = \"true\"\n", true},
+		{"duplicate name", rule + "command = \"true\"\n" + rule + "command = \"true\"\n", true},
+		{"unknown change", rule + "command = \"true\"\non = [\"deleted\"]\n", true},
+		{"bad webhook", rule + "webhook = \"hooks.example.com\"\n", true},
+		{"bad calendar pattern", rule + "command = \"true\"\ncalendars = [\"[x\"]\n", true},
+		{"digest", "[daemon.digest.smtp]\nhost = \"smtp.example.com\"\nfrom = \"me@example.com\"\nto = [\"me@example.com\"]\n", false},
+		{"digest without recipients", "[daemon.digest.smtp]\nhost = \"smtp.example.com\"\nfrom = \"me@example.com\"\n", true},
+		{"digest bad webhook", "[daemon.digest]\nwebhook = \"hooks.example.com\"\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+				t.Fatalf("write config: %v", err)
+			}
+			cfg, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("load error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.name == "webhook" {
+				a := cfg.Daemon.Alerts[0]
+				if !a.IncludeCalendar("x@group.calendar.google.com", "Family Plans") || a.IncludeCalendar("primary", "Work") {
+					t.Errorf("IncludeCalendar doesn't follow calendars = %v", a.Calendars)
+				}
+			}
+			if tt.name == "digest" {
+				d := cfg.Daemon.Digest
+				if !d.Enabled() || d.SMTP.Port != 587 || d.Schedule != "0 7 * * *" {
+					t.Errorf("digest = %+v, want enabled with default port and schedule", d)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_SyncDailyRequestBudget(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	tests := []struct {
+		config  string
+		want    int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"[sync]\ndaily_request_budget = 50000\n", 50000, false},
+		{"[sync]\ndaily_request_budget = -1\n", 0, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		cfg, err := Load(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("load %q error = %v, wantErr %v", tt.config, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.Sync.DailyRequestBudget != tt.want {
+			t.Errorf("load %q daily_request_budget = %d, want %d", tt.config, cfg.Sync.DailyRequestBudget, tt.want)
+		}
+	}
+}
+
+func TestLoad_SyncRateLimit(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	tests := []struct {
+		config    string
+		wantQPS   float64
+		wantBurst int
+		wantErr   bool
+	}{
+		{"", 10, 0, false},
+		{"[sync]\nrate_limit_qps = 5\nrate_limit_burst = 20\n", 5, 20, false},
+		{"[sync]\nrate_limit_qps = 0.5\n", 0.5, 0, false},
+		{"[sync]\nrate_limit_qps = 0\n", 0, 0, true},
+		{"[sync]\nrate_limit_burst = -1\n", 0, 0, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		cfg, err := Load(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("load %q error = %v, wantErr %v", tt.config, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (cfg.Sync.RateLimitQPS != tt.wantQPS || cfg.Sync.RateLimitBurst != tt.wantBurst) {
+			t.Errorf("load %q rate limit = %g QPS, burst %d; want %g, %d",
+				tt.config, cfg.Sync.RateLimitQPS, cfg.Sync.RateLimitBurst, tt.wantQPS, tt.wantBurst)
+		}
+	}
+}
+
+func TestLoad_SyncAccountConcurrency(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	tests := []struct {
+		config  string
+		want    int
+		wantErr bool
+	}{
+		{"", 2, false},
+		{"[sync]\naccount_concurrency = 1\n", 1, false},
+		{"[sync]\naccount_concurrency = 0\n", 0, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		cfg, err := Load(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("load %q error = %v, wantErr %v", tt.config, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.Sync.AccountConcurrency != tt.want {
+			t.Errorf("load %q account_concurrency = %d, want %d", tt.config, cfg.Sync.AccountConcurrency, tt.want)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"10y", time.Date(2014, 3, 15, 12, 0, 0, 0, time.UTC), false},
+		{"18m", time.Date(2022, 9, 15, 12, 0, 0, 0, time.UTC), false},
+		{"2w", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), false},
+		{"90d", time.Date(2023, 12, 16, 12, 0, 0, 0, time.UTC), false},
+		{"10", time.Time{}, true},
+		{"0y", time.Time{}, true},
+		{"-1y", time.Time{}, true},
+		{"10h", time.Time{}, true},
+		{"y", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		age, err := ParseAge(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAge(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !age.Before(now).Equal(tt.want) {
+			t.Errorf("ParseAge(%q) before %v = %v, want %v", tt.in, now, age.Before(now), tt.want)
+		}
+	}
+}
+
+func TestLoad_StorageRetention(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	tests := []struct {
+		config  string
+		want    Age
+		wantErr bool
+	}{
+		{"", Age{}, false},
+		{"[storage]\nretention = \"10y\"\n", Age{Years: 10}, false},
+		{"[storage]\nretention = \"ten years\"\n", Age{}, true},
+		{"[storage]\nretention = \"10y\"\nlegal_hold = true\n", Age{}, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		cfg, err := Load(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("load %q error = %v, wantErr %v", tt.config, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		age, ok := cfg.Storage.RetentionAge()
+		if ok != (tt.want != Age{}) || age != tt.want {
+			t.Errorf("load %q retention = %+v, %v; want %+v", tt.config, age, ok, tt.want)
+		}
+	}
+}
+
+func TestLoad_Identities(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	tests := []struct {
+		config  string
+		wantErr bool
+	}{
+		{"[[identity]]\nname = \"Me\"\nemails = [\"me@gmail.com\", \"me@corp.com\"]\n", false},
+		{"[[identity]]\nname = \"Me\"\n", true},
+		{"[[identity]]\nemails = [\"me\"]\n", true},
+		{"[[identity]]\nemails = [\"me@gmail.com\"]\n[[identity]]\nemails = [\"ME@gmail.com\"]\n", true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		cfg, err := Load(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("load %q error = %v, wantErr %v", tt.config, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (len(cfg.Identities) != 1 || cfg.Identities[0].Name != "Me" || len(cfg.Identities[0].Emails) != 2) {
+			t.Errorf("load %q identities = %+v", tt.config, cfg.Identities)
+		}
+	}
+}
+
+func TestLoad_SyncProfiles(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.toml")
+	config := "[sync]\nprofile = \"standard\"\n\n[sync.profiles]\n\"me@gmail.com\" = \"minimal\"\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := cfg.Sync.ProfileFor("me@gmail.com"); got != "minimal" {
+		t.Errorf("ProfileFor(me@gmail.com) = %q, want minimal", got)
+	}
+	if got := cfg.Sync.ProfileFor("me@work.com"); got != "standard" {
+		t.Errorf("ProfileFor(me@work.com) = %q, want standard", got)
+	}
+
+	for _, bad := range []string{
+		"[sync]\nprofile = \"everything\"\n",
+		"[sync.profiles]\n\"me@gmail.com\" = \"tiny\"\n",
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("load %q: expected an error", bad)
+		}
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	config := "[sync]\nrate_limit_qps = 5\nconcurency = 2\n\n[outptu]\ncolor = \"never\"\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	keys, err := UnknownKeys(path)
+	if err != nil {
+		t.Fatalf("unknown keys: %v", err)
+	}
+	want := []string{"sync.concurency", "outptu", "outptu.color"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("unknown keys = %v, want %v", keys, want)
+	}
+}
+
+func TestLoad_LLM(t *testing.T) {
+	t.Setenv("CALVAULT_HOME", t.TempDir())
+	t.Setenv("CALVAULT_TEST_LLM_KEY", "sk-test")
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	config := "[llm]\nendpoint = \"http://localhost:11434/v1\"\nmodel = \"llama3.1\"\napi_key_env = \"CALVAULT_TEST_LLM_KEY\"\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.LLM.Enabled() || cfg.LLM.Model != "llama3.1" || cfg.LLM.APIKey() != "sk-test" {
+		t.Errorf("llm = %+v (key %q), want endpoint, model and key", cfg.LLM, cfg.LLM.APIKey())
+	}
+
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{"[llm]\nendpoint = \"localhost:11434\"\nmodel = \"m\"\n", "llm.endpoint"},
+		{"[llm]\nendpoint = \"ftp://example.com\"\nmodel = \"m\"\n", "llm.endpoint"},
+		{"[llm]\nendpoint = \"https://api.openai.com/v1\"\n", "llm.model"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("load %q error = %v, want %s error", tt.config, err, tt.want)
+		}
+	}
+}
+
+func TestDefaultHome(t *testing.T) {
+	home := filepath.Join("home", "me")
+	appData := filepath.Join("Users", "me", "AppData", "Roaming")
+	tests := []struct {
+		name   string
+		goos   string
+		env    map[string]string
+		legacy bool // ~/.calvault exists
+		want   string
+	}{
+		{"linux", "linux", nil, false, filepath.Join(home, ".calvault")},
+		{"darwin", "darwin", nil, false, filepath.Join(home, ".calvault")},
+		{"override", "linux", map[string]string{"CALVAULT_HOME": "/data/cv"}, false, "/data/cv"},
+		{"windows", "windows", map[string]string{"APPDATA": appData}, false, filepath.Join(appData, "calvault")},
+		{"windows existing home", "windows", map[string]string{"APPDATA": appData}, true, filepath.Join(home, ".calvault")},
+		{"windows no APPDATA", "windows", nil, false, filepath.Join(home, ".calvault")},
+		{"windows override", "windows", map[string]string{"APPDATA": appData, "CALVAULT_HOME": `D:\cv`}, false, `D:\cv`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(k string) string { return tt.env[k] }
+			exists := func(string) bool { return tt.legacy }
+			if got := defaultHome(tt.goos, getenv, home, exists); got != tt.want {
+				t.Errorf("defaultHome = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	home := filepath.Join("home", "me")
+	env := map[string]string{"APPDATA": `C:\Users\me\AppData\Roaming`, "USERPROFILE": `C:\Users\me`}
+	getenv := func(k string) string { return env[k] }
+	tests := []struct {
+		goos, path, want string
+	}{
+		{"linux", "", ""},
+		{"linux", "~", home},
+		{"linux", "~/secrets/client.json", filepath.Join(home, "secrets", "client.json")},
+		{"linux", "~bob/client.json", "~bob/client.json"},
+		{"linux", "%APPDATA%/client.json", "%APPDATA%/client.json"},
+		{"windows", `%APPDATA%\calvault\client.json`, `C:\Users\me\AppData\Roaming\calvault\client.json`},
+		{"windows", `%USERPROFILE%\%UNSET%\client.json`, `C:\Users\me\%UNSET%\client.json`},
+		{"windows", `50%off %APPDATA%`, `50%off C:\Users\me\AppData\Roaming`},
+		{"windows", `100%`, `100%`},
+		{"windows", `~\client.json`, filepath.Join(home, `\client.json`)},
+	}
+	for _, tt := range tests {
+		if got := expandPathFor(tt.goos, tt.path, getenv, home); got != tt.want {
+			t.Errorf("expandPathFor(%s, %q) = %q, want %q", tt.goos, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSetTOMLString(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"empty file", "", "[storage]\ndatabase = \"/mnt/v/calvault.db\"\n"},
+		{
+			"new section",
+			"[oauth]\nclient_secrets = \"/x.json\"",
+			"[oauth]\nclient_secrets = \"/x.json\"\n\n[storage]\ndatabase = \"/mnt/v/calvault.db\"\n",
+		},
+		{
+			"existing section",
+			"[storage] # where data lives\n\n[sync]\nconcurrency = 2\n",
+			"[storage] # where data lives\ndatabase = \"/mnt/v/calvault.db\"\n\n[sync]\nconcurrency = 2\n",
+		},
+		{
+			"existing key keeps comments",
+			"# my vault\n[storage]\n  database = \"/old.db\"\n[sync]\ndatabase = \"not this\"\n",
+			"# my vault\n[storage]\ndatabase = \"/mnt/v/calvault.db\"\n[sync]\ndatabase = \"not this\"\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := setTOMLString(tt.content, "storage", "database", "/mnt/v/calvault.db"); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReplaceDir(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	err := os.WriteFile(path, []byte(`[oauth]
+client_secrets = "/home/me/.calvault/client_secret.json"
+
+[storage]
+database = "/home/me/.calvault2/calvault.db"  # Another directory; untouched
+`), 0o600)
+	if err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if err := ReplaceDir(path, "/home/me/.calvault", "/mnt/vault"); err != nil {
+		t.Fatalf("replace dir: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.OAuth.ClientSecrets != "/mnt/vault/client_secret.json" {
+		t.Errorf("client_secrets = %q, want /mnt/vault/client_secret.json", cfg.OAuth.ClientSecrets)
+	}
+	if cfg.Storage.Database != "/home/me/.calvault2/calvault.db" {
+		t.Errorf("database = %q, want unchanged", cfg.Storage.Database)
+	}
+	if cfg.DatabasePath() != cfg.Storage.Database {
+		t.Errorf("DatabasePath = %q, want storage.database", cfg.DatabasePath())
+	}
+
+	if err := ReplaceDir(filepath.Join(t.TempDir(), "missing.toml"), "/a", "/b"); err != nil {
+		t.Errorf("replace dir in missing file: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// SetString sets key in [section] of the TOML file at path to a string,
+// creating the file, section, or key as needed. Comments and other settings
+// are kept, and the file is replaced atomically.
+func SetString(path, section, key, value string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read config: %w", err)
+	}
+	return writeConfig(path, setTOMLString(string(data), section, key, value))
+}
+
+// ReplaceDir rewrites paths under oldDir to newDir in the TOML file at
+// path, e.g. client_secrets after the home directory moves. A missing file
+// is left alone.
+func ReplaceDir(path, oldDir, newDir string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+
+	content := string(data)
+	// Paths appear as written, or escaped in basic strings ("C:\\Users\\...")
+	forms := map[string]string{oldDir: newDir, escapeTOML(oldDir): escapeTOML(newDir)}
+	for from, to := range forms {
+		// Only whole directory names: oldDir followed by a separator or closing quote
+		re := regexp.MustCompile(regexp.QuoteMeta(from) + `([/\\"'])`)
+		content = re.ReplaceAllStringFunc(content, func(m string) string {
+			return to + m[len(from):]
+		})
+	}
+	if content == string(data) {
+		return nil
+	}
+	return writeConfig(path, content)
+}
+
+// setTOMLString edits TOML text line by line so formatting survives.
+func setTOMLString(content, section, key, value string) string {
+	line := key + ` = "` + escapeTOML(value) + `"`
+	keyPattern := regexp.MustCompile(`^\s*` + regexp.QuoteMeta(key) + `\s*=`)
+
+	lines := strings.Split(content, "\n")
+	current, header := "", -1
+	for i, l := range lines {
+		trimmed := strings.TrimSpace(l)
+		if strings.HasPrefix(trimmed, "[") {
+			name, _, _ := strings.Cut(trimmed, "#")
+			current = strings.TrimSpace(name) // "[[x]]" never equals "[section]"
+			if current == "["+section+"]" {
+				header = i
+			}
+			continue
+		}
+		if current == "["+section+"]" && keyPattern.MatchString(l) {
+			lines[i] = line
+			return strings.Join(lines, "\n")
+		}
+	}
+
+	if header >= 0 {
+		lines = append(lines[:header+1], append([]string{line}, lines[header+1:]...)...)
+		return strings.Join(lines, "\n")
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if content != "" {
+		content += "\n"
+	}
+	return content + "[" + section + "]\n" + line + "\n"
+}
+
+func escapeTOML(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(s)
+}
+
+// writeConfig checks that content still parses and atomically replaces the
+// file at path with it.
+func writeConfig(path, content string) error {
+	var check map[string]interface{}
+	if _, err := toml.Decode(content, &check); err != nil {
+		return fmt.Errorf("edited config is invalid (edit %s by hand): %w", path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create config directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*.toml")
+	if err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.WriteString(content); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replace config: %w", err)
+	}
+	return nil
+}
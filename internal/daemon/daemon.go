@@ -0,0 +1,119 @@
+package daemon
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Job is a named task run on a schedule.
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Run      func(ctx context.Context) error
+}
+
+// TriggerDelay is how long a triggered job waits before running, so a burst
+// of triggers results in a single run.
+const TriggerDelay = 5 * time.Second
+
+// Runner runs jobs on their schedules until its context is cancelled.
+// Jobs run one at a time; a job that comes due while another is running
+// starts once the earlier one finishes.
+type Runner struct {
+	jobs    []Job
+	logger  *slog.Logger
+	trigger chan string
+}
+
+// NewRunner creates a runner for jobs.
+func NewRunner(jobs []Job) *Runner {
+	return &Runner{
+		jobs:    jobs,
+		logger:  slog.Default(),
+		trigger: make(chan string, 64),
+	}
+}
+
+// WithLogger sets the logger.
+func (r *Runner) WithLogger(logger *slog.Logger) *Runner {
+	r.logger = logger
+	return r
+}
+
+// Trigger runs the named job TriggerDelay from now, or at its scheduled time
+// if that is sooner. It never blocks and is safe to call from any goroutine.
+func (r *Runner) Trigger(name string) {
+	select {
+	case r.trigger <- name:
+	default:
+		// Queue full; the pending triggers will run the job anyway.
+	}
+}
+
+// Run blocks until ctx is cancelled. Job errors are logged, not returned.
+func (r *Runner) Run(ctx context.Context) error {
+	if len(r.jobs) == 0 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	next := make([]time.Time, len(r.jobs))
+	now := time.Now()
+	for i, job := range r.jobs {
+		next[i] = job.Schedule.Next(now)
+		r.logger.Info("scheduled job", "job", job.Name, "next", next[i].Format(time.RFC3339))
+	}
+
+	for {
+		due := -1
+		for i := range next {
+			if !next[i].IsZero() && (due < 0 || next[i].Before(next[due])) {
+				due = i
+			}
+		}
+		if due < 0 {
+			r.logger.Warn("no future runs scheduled")
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case name := <-r.trigger:
+				r.schedule(next, name, time.Now().Add(TriggerDelay))
+				continue
+			}
+		}
+
+		timer := time.NewTimer(time.Until(next[due]))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case name := <-r.trigger:
+			timer.Stop()
+			r.schedule(next, name, time.Now().Add(TriggerDelay))
+			continue
+		case <-timer.C:
+		}
+
+		job := r.jobs[due]
+		start := time.Now()
+		r.logger.Info("running job", "job", job.Name)
+		if err := job.Run(ctx); err != nil {
+			r.logger.Error("job failed", "job", job.Name, "error", err)
+		} else {
+			r.logger.Info("job finished", "job", job.Name, "elapsed", time.Since(start).Round(time.Millisecond))
+		}
+		next[due] = job.Schedule.Next(time.Now())
+		r.logger.Info("scheduled job", "job", job.Name, "next", next[due].Format(time.RFC3339))
+	}
+}
+
+// schedule moves the named job's next run to at, if that is sooner.
+func (r *Runner) schedule(next []time.Time, name string, at time.Time) {
+	for i, job := range r.jobs {
+		if job.Name == name && (next[i].IsZero() || at.Before(next[i])) {
+			next[i] = at
+			r.logger.Debug("job triggered", "job", name, "next", at.Format(time.RFC3339))
+		}
+	}
+}
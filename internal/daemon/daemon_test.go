@@ -0,0 +1,216 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule_Next(t *testing.T) {
+	// Wednesday
+	from := time.Date(2024, 3, 6, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"*/15 * * * *", "2024-03-06 10:15"},
+		{"0 * * * *", "2024-03-06 11:00"},
+		{"@hourly", "2024-03-06 11:00"},
+		{"@daily", "2024-03-07 00:00"},
+		{"30 9 * * 1-5", "2024-03-07 09:30"},
+		{"0 8 * * 0", "2024-03-10 08:00"},
+		{"0 8 * * 7", "2024-03-10 08:00"},
+		{"0 0 1 * *", "2024-04-01 00:00"},
+		{"0 0 29 2 *", "2028-02-29 00:00"},
+		{"0 12 15 * 5", "2024-03-08 12:00"}, // Either day field matches
+		{"5,10 10 * * *", "2024-03-06 10:10"},
+		{"@every 45m", "2024-03-06 10:52"},
+	}
+	for _, tt := range tests {
+		sched, err := ParseSchedule(tt.expr)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := sched.Next(from).Format("2006-01-02 15:04"); got != tt.want {
+			t.Errorf("%q: next = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *",
+		"5-1 * * * *", "a * * * *", "@every 10s", "@every soon",
+	} {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.lock")
+
+	lock, err := AcquireLock(path)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := AcquireLock(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("second acquire error = %v, want ErrLocked", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+
+	// A lock left by a process that no longer exists is taken over.
+	if err := os.WriteFile(path, []byte(strconv.Itoa(1<<30)+"\n"), 0o600); err != nil {
+		t.Fatalf("write stale lock: %v", err)
+	}
+	lock, err = AcquireLock(path)
+	if err != nil {
+		t.Fatalf("acquire stale lock: %v", err)
+	}
+	_ = lock.Release()
+}
+
+func TestPause(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.pause")
+	now := time.Date(2024, 3, 6, 10, 0, 0, 0, time.UTC)
+
+	if _, paused, err := PausedUntil(path, now); err != nil || paused {
+		t.Errorf("no pause file: paused = %v, %v", paused, err)
+	}
+
+	until := now.Add(2 * time.Hour)
+	if err := Pause(path, until); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if got, paused, err := PausedUntil(path, now); err != nil || !paused || !got.Equal(until) {
+		t.Errorf("paused = %v until %v (%v), want until %v", paused, got, err, until)
+	}
+	if _, paused, _ := PausedUntil(path, until); paused {
+		t.Error("pause should end at its end time")
+	}
+
+	if err := Pause(path, time.Time{}); err != nil {
+		t.Fatalf("pause indefinitely: %v", err)
+	}
+	if got, paused, err := PausedUntil(path, now.AddDate(1, 0, 0)); err != nil || !paused || !got.IsZero() {
+		t.Errorf("indefinite pause: paused = %v until %v (%v)", paused, got, err)
+	}
+
+	if resumed, err := Resume(path); err != nil || !resumed {
+		t.Errorf("resume = %v, %v; want true", resumed, err)
+	}
+	if resumed, err := Resume(path); err != nil || resumed {
+		t.Errorf("second resume = %v, %v; want false", resumed, err)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2024, 3, 6, h, m, 0, 0, time.Local) }
+	tests := []struct {
+		window string
+		t      time.Time
+		want   bool
+	}{
+		{"09:00-17:00", at(9, 0), true},
+		{"09:00-17:00", at(16, 59), true},
+		{"09:00-17:00", at(17, 0), false},
+		{"22:00-07:00", at(23, 30), true},
+		{"22:00-07:00", at(6, 59), true},
+		{"22:00-07:00", at(12, 0), false},
+		{"00:00-24:00", at(23, 59), true},
+	}
+	for _, tt := range tests {
+		w, err := ParseWindow(tt.window)
+		if err != nil {
+			t.Errorf("ParseWindow(%q): %v", tt.window, err)
+			continue
+		}
+		if got := w.Contains(tt.t); got != tt.want {
+			t.Errorf("%s contains %s = %v, want %v", tt.window, tt.t.Format("15:04"), got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "22:00", "22:00-", "9-17", "25:00-07:00", "10:00-10:00", "10:60-11:00"} {
+		if _, err := ParseWindow(spec); err == nil {
+			t.Errorf("ParseWindow(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestNotificationHandler(t *testing.T) {
+	lookup := func(channelID string) (string, string, bool) {
+		if channelID == "ch-1" {
+			return "me@example.com", "secret", true
+		}
+		return "", "", false
+	}
+	var triggered []string
+	h := NotificationHandler(lookup, func(job string) { triggered = append(triggered, job) }, slog.Default())
+
+	tests := []struct {
+		channel, token, state string
+		wantCode              int
+	}{
+		{"ch-1", "secret", "sync", http.StatusNoContent},
+		{"ch-1", "secret", "exists", http.StatusNoContent},
+		{"ch-1", "wrong", "exists", http.StatusForbidden},
+		{"ch-2", "secret", "exists", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("X-Goog-Channel-ID", tt.channel)
+		req.Header.Set("X-Goog-Channel-Token", tt.token)
+		req.Header.Set("X-Goog-Resource-State", tt.state)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.wantCode {
+			t.Errorf("%s/%s/%s: status = %d, want %d", tt.channel, tt.token, tt.state, rec.Code, tt.wantCode)
+		}
+	}
+
+	if len(triggered) != 1 || triggered[0] != "me@example.com" {
+		t.Errorf("triggered = %v, want one run for me@example.com", triggered)
+	}
+}
+
+func TestRunner_Trigger(t *testing.T) {
+	ran := make(chan string, 1)
+	never, _ := ParseSchedule("0 0 1 1 *")
+	runner := NewRunner([]Job{{
+		Name:     "job",
+		Schedule: never,
+		Run: func(ctx context.Context) error {
+			ran <- "job"
+			return nil
+		},
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = runner.Run(ctx) }()
+
+	runner.Trigger("job")
+	runner.Trigger("job")
+	select {
+	case <-ran:
+	case <-time.After(TriggerDelay + 5*time.Second):
+		t.Fatal("triggered job did not run")
+	}
+	select {
+	case <-ran:
+		t.Error("repeated triggers ran the job twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,75 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrLocked is returned when another process holds a lock.
+var ErrLocked = errors.New("locked by another process")
+
+// Lock is an exclusive lock file containing the holder's PID.
+type Lock struct {
+	path string
+}
+
+// AcquireLock creates the lock file at path. A lock left behind by a process
+// that is no longer running is taken over.
+func AcquireLock(path string) (*Lock, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			_, werr := fmt.Fprintf(f, "%d\n", os.Getpid())
+			cerr := f.Close()
+			if werr != nil || cerr != nil {
+				_ = os.Remove(path)
+				return nil, fmt.Errorf("write lock %s: %w", path, errors.Join(werr, cerr))
+			}
+			return &Lock{path: path}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("create lock %s: %w", path, err)
+		}
+
+		pid, alive := lockHolder(path)
+		if alive {
+			return nil, fmt.Errorf("%s (pid %d): %w", path, pid, ErrLocked)
+		}
+		// Stale lock; remove it and retry once.
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("remove stale lock %s: %w", path, err)
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", path, ErrLocked)
+}
+
+// Release removes the lock file.
+func (l *Lock) Release() error {
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("release lock %s: %w", l.path, err)
+	}
+	return nil
+}
+
+// lockHolder reads the PID from a lock file and reports whether that process
+// is still running. Unreadable lock files are treated as held, so a lock
+// being written concurrently is not stolen.
+func lockHolder(path string) (int, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, !errors.Is(err, os.ErrNotExist)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, len(data) == 0
+	}
+	return pid, processAlive(pid)
+}
+
+// ProcessAlive reports whether a process with the given PID is running.
+func ProcessAlive(pid int) bool {
+	return processAlive(pid)
+}
@@ -0,0 +1,104 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pause writes a pause file at path holding scheduled syncs until until, or
+// until Resume if until is zero.
+func Pause(path string, until time.Time) error {
+	content := ""
+	if !until.IsZero() {
+		content = until.Format(time.RFC3339) + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		return fmt.Errorf("write pause file: %w", err)
+	}
+	return nil
+}
+
+// Resume removes the pause file at path, reporting whether syncs were paused.
+func Resume(path string) (bool, error) {
+	_, paused, err := PausedUntil(path, time.Now())
+	if err != nil {
+		return false, err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("remove pause file: %w", err)
+	}
+	return paused, nil
+}
+
+// PausedUntil reports whether the pause file at path holds syncs at now,
+// and until when; the time is zero for a pause without an end. An expired
+// pause file is ignored.
+func PausedUntil(path string, now time.Time) (time.Time, bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("read pause file: %w", err)
+	}
+	content := strings.TrimSpace(string(data))
+	if content == "" {
+		return time.Time{}, true, nil
+	}
+	until, err := time.Parse(time.RFC3339, content)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("pause file %s: invalid time %q", path, content)
+	}
+	return until, now.Before(until), nil
+}
+
+// Window is a daily span of local time, as offsets from midnight. A window
+// ending before it starts wraps past midnight.
+type Window struct {
+	Start, End time.Duration
+}
+
+// ParseWindow parses a window written as HH:MM-HH:MM, e.g. "22:00-07:00".
+func ParseWindow(s string) (Window, error) {
+	start, end, ok := strings.Cut(s, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid window %q (expected HH:MM-HH:MM)", s)
+	}
+	var w Window
+	var err error
+	if w.Start, err = parseClock(strings.TrimSpace(start)); err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	if w.End, err = parseClock(strings.TrimSpace(end)); err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	if w.Start == w.End {
+		return Window{}, fmt.Errorf("invalid window %q: empty", s)
+	}
+	return w, nil
+}
+
+// Contains reports whether t's local time of day falls in the window.
+func (w Window) Contains(t time.Time) bool {
+	h, m, sec := t.Clock()
+	d := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second
+	if w.Start < w.End {
+		return d >= w.Start && d < w.End
+	}
+	return d >= w.Start || d < w.End
+}
+
+// parseClock parses HH:MM as an offset from midnight; 24:00 is allowed.
+func parseClock(s string) (time.Duration, error) {
+	hh, mm, ok := strings.Cut(s, ":")
+	h, herr := strconv.Atoi(hh)
+	m, merr := strconv.Atoi(mm)
+	if !ok || herr != nil || merr != nil || len(mm) != 2 || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
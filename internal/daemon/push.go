@@ -0,0 +1,41 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+)
+
+// ChannelLookup resolves a push notification channel to the job it should
+// trigger, returning the channel's token for verification. ok is false for
+// unknown channels.
+type ChannelLookup func(channelID string) (job, token string, ok bool)
+
+// NotificationHandler receives Google Calendar push notifications and
+// triggers the job owning the channel. Google sends a "sync" message when a
+// channel is created and "exists" or "not_exists" when a watched resource
+// changes; only the latter trigger a run. Notifications with an unknown
+// channel or a wrong token are rejected.
+func NotificationHandler(lookup ChannelLookup, trigger func(job string), logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		channelID := r.Header.Get("X-Goog-Channel-ID")
+		job, token, ok := lookup(channelID)
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(r.Header.Get("X-Goog-Channel-Token"))) != 1 {
+			logger.Warn("rejected push notification", "channel", channelID)
+			http.Error(w, "unknown channel", http.StatusForbidden)
+			return
+		}
+
+		state := r.Header.Get("X-Goog-Resource-State")
+		logger.Debug("push notification", "channel", channelID, "state", state, "job", job)
+		if state != "sync" {
+			trigger(job)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,514 @@
+// Package demo fills a database with a realistic, made-up calendar archive:
+// work and personal calendars, recurring series with moved and cancelled
+// occurrences, meetings with colleagues and outside guests, video calls,
+// interviews and holidays. It lets calvault's queries and analytics be tried,
+// and benchmarked, without connecting an account.
+package demo
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/ics"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// Account is the account the demo archive is stored under.
+const Account = "demo@example.com"
+
+// Options control what Generate creates.
+type Options struct {
+	Events int       // Events to store, counting series and their exceptions
+	Years  int       // Years of history before Now (0 scales with Events)
+	Seed   int64     // The same seed and Now generate the same archive
+	Now    time.Time // Events run from Years before it to 90 days after
+}
+
+// Summary counts what Generate stored.
+type Summary struct {
+	Calendars  int
+	Events     int
+	Series     int
+	Exceptions int // Moved or cancelled occurrences of series
+	Attendees  int
+	People     int
+	From, To   time.Time
+}
+
+// batchSize is the number of events written per transaction.
+const batchSize = 1000
+
+// person is someone the demo account meets.
+type person struct {
+	email, name string
+}
+
+var (
+	firstNames = []string{"Ava", "Ben", "Chloe", "Dev", "Elena", "Farid", "Grace", "Hiro", "Isla", "Jonas",
+		"Kira", "Leo", "Maya", "Nikhil", "Olivia", "Pablo", "Quinn", "Rosa", "Sam", "Tariq",
+		"Uma", "Victor", "Wen", "Ximena", "Yusuf", "Zoe"}
+	lastNames = []string{"Adams", "Brooks", "Chen", "Diaz", "Evans", "Fischer", "Gupta", "Hughes", "Ito", "Jensen",
+		"Khan", "Lopez", "Moreau", "Nakamura", "Okafor", "Patel", "Rossi", "Silva", "Tanaka", "Walsh"}
+	partners = []string{"globex.example", "initech.example", "umbrella.example", "hooli.example"}
+	projects = []string{"Atlas", "Beacon", "Comet", "Delta", "Ember", "Falcon", "Granite", "Harbor"}
+	rooms    = []string{"Room 4B", "Room 2A", "Boardroom", "Lab 1", "Cafe corner", "Room 5C"}
+	places   = []string{"Blue Bottle, 150 Greenwich St", "Central Park", "Dr. Patel Dental, 12 Elm St",
+		"City Gym, 40 Main St", "JFK Terminal 4", "Home", "The Corner Bistro, 331 W 4th St"}
+	roles = []string{"Backend", "Frontend", "Data", "SRE", "Product Design", "Engineering Manager"}
+
+	workTitles = []string{"Sync with %s", "%s review", "%s planning", "Design review: %s", "%s retro",
+		"Coffee chat with %s", "%s roadmap", "Budget review", "Incident postmortem", "Customer call: %s",
+		"Pairing with %s", "%s demo", "Hiring committee", "Quarterly business review"}
+	personalTitles = []string{"Dentist", "Gym", "Dinner with %s", "Haircut", "Call mom", "Flight to Lisbon",
+		"Yoga", "Parent-teacher conference", "Car service", "Drinks with %s", "Doctor appointment", "Piano lesson"}
+	allDayTitles = []string{"Vacation", "Out of office", "%s's birthday", "Conference: KubeCon", "Offsite",
+		"Public holiday", "Moving day"}
+)
+
+// generator holds the state of one Generate run.
+type generator struct {
+	r         *rand.Rand
+	loc       *time.Location
+	now       time.Time
+	from, to  time.Time
+	sourceID  int64
+	self      person
+	team      []person // People on the demo account's team
+	people    []person // Everyone else it meets
+	calendars map[string]int64
+	writes    []*store.EventWrite
+	summary   *Summary
+}
+
+// Generate stores a demo archive of opts.Events events under Account,
+// replacing any demo archive stored before.
+func Generate(s *store.Store, opts Options) (*Summary, error) {
+	if opts.Events <= 0 {
+		return nil, fmt.Errorf("number of events must be positive")
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	if opts.Years <= 0 {
+		// About 4,000 events a year, a busy manager's calendar
+		opts.Years = min(max(opts.Events/4000, 1), 20)
+	}
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		loc = time.UTC
+	}
+
+	now := opts.Now.In(loc)
+	g := &generator{
+		r:         rand.New(rand.NewSource(opts.Seed)),
+		loc:       loc,
+		now:       now,
+		from:      time.Date(now.Year()-opts.Years, now.Month(), now.Day(), 0, 0, 0, 0, loc),
+		to:        now.AddDate(0, 0, 90),
+		self:      person{Account, "Demo User"},
+		calendars: map[string]int64{},
+		summary:   &Summary{},
+	}
+	g.summary.From, g.summary.To = g.from, g.to
+	g.makePeople()
+
+	if err := g.replaceSource(s); err != nil {
+		return nil, err
+	}
+	src, err := s.GetOrCreateSource(Account)
+	if err != nil {
+		return nil, fmt.Errorf("create demo account: %w", err)
+	}
+	g.sourceID = src.ID
+	if err := g.makeCalendars(s, src.ID); err != nil {
+		return nil, err
+	}
+
+	g.makeSeries(opts.Events)
+	for len(g.writes) < opts.Events {
+		g.makeEvent()
+	}
+	g.writes = g.writes[:opts.Events]
+
+	for start := 0; start < len(g.writes); start += batchSize {
+		batch := g.writes[start:min(start+batchSize, len(g.writes))]
+		err := s.InTx(func(tx *store.Tx) error {
+			_, err := tx.UpsertEvents(src.ID, batch)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("store demo events: %w", err)
+		}
+	}
+	for _, w := range g.writes {
+		g.summary.Attendees += len(w.Attendees)
+	}
+	g.summary.Events = len(g.writes)
+	return g.summary, nil
+}
+
+// replaceSource removes a demo archive stored before.
+func (g *generator) replaceSource(s *store.Store) error {
+	src, err := s.GetSourceByIdentifier(Account)
+	if err != nil {
+		return fmt.Errorf("get demo account: %w", err)
+	}
+	if src == nil {
+		return nil
+	}
+	return s.InTx(func(tx *store.Tx) error {
+		_, err := tx.DeleteSource(src.ID)
+		return err
+	})
+}
+
+// makePeople makes the demo account's team and the wider set of colleagues
+// and outside contacts it meets.
+func (g *generator) makePeople() {
+	seen := map[string]bool{}
+	for len(g.team)+len(g.people) < 80 {
+		first := firstNames[g.r.Intn(len(firstNames))]
+		last := lastNames[g.r.Intn(len(lastNames))]
+		domain := "acme.example"
+		if len(g.team) >= 8 && g.r.Intn(4) == 0 {
+			domain = partners[g.r.Intn(len(partners))]
+		}
+		email := strings.ToLower(first+"."+last) + "@" + domain
+		if seen[email] {
+			continue
+		}
+		seen[email] = true
+		p := person{email, first + " " + last}
+		if len(g.team) < 8 {
+			g.team = append(g.team, p)
+		} else {
+			g.people = append(g.people, p)
+		}
+	}
+	g.summary.People = len(g.team) + len(g.people)
+}
+
+// makeCalendars stores the demo account's calendars.
+func (g *generator) makeCalendars(s *store.Store, sourceID int64) error {
+	for _, cal := range []*store.Calendar{
+		{GoogleCalendarID: Account, Summary: Account, IsPrimary: true, AccessRole: "owner",
+			SubscriptionKind: store.CalendarKindPrimary, ColorID: "14", BackgroundColor: "#9fe1e7"},
+		{GoogleCalendarID: "personal@group.calendar.google.com", Summary: "Personal", AccessRole: "owner",
+			SubscriptionKind: store.CalendarKindOwned, ColorID: "2", BackgroundColor: "#d06b64"},
+		{GoogleCalendarID: "team@group.calendar.google.com", Summary: "Team", AccessRole: "reader",
+			SubscriptionKind: store.CalendarKindShared, ColorID: "9", BackgroundColor: "#7bd148"},
+		{GoogleCalendarID: "en.usa#holiday@group.v.calendar.google.com", Summary: "Holidays in United States",
+			AccessRole: "reader", SubscriptionKind: store.CalendarKindSubscription, ColorID: "8", BackgroundColor: "#16a765"},
+	} {
+		cal.Timezone = g.loc.String()
+		id, err := s.UpsertCalendar(sourceID, cal)
+		if err != nil {
+			return fmt.Errorf("create demo calendar: %w", err)
+		}
+		g.calendars[cal.Summary] = id
+		g.summary.Calendars++
+	}
+	return nil
+}
+
+// seriesTemplate describes a recurring series.
+type seriesTemplate struct {
+	title, rule, calendar string
+	hour, minute, minutes int
+	guests                []person
+	video                 bool
+}
+
+// makeSeries adds the recurring series, sized to the number of events, and
+// their exceptions.
+func (g *generator) makeSeries(events int) {
+	templates := []seriesTemplate{
+		{"Team standup", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", "primary", 9, 30, 15, g.team, true},
+		{"Sprint planning", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", "primary", 10, 0, 60, g.team, true},
+		{"All hands", "FREQ=MONTHLY;BYDAY=1TH", "primary", 16, 0, 60, g.pick(g.people, 20), true},
+		{"Gym", "FREQ=WEEKLY;BYDAY=TU,TH", "Personal", 7, 0, 60, nil, false},
+		{"Book club", "FREQ=MONTHLY;BYMONTHDAY=15", "Personal", 19, 0, 120, g.pick(g.people, 3), false},
+		{"Team lunch", "FREQ=WEEKLY;BYDAY=FR", "Team", 12, 0, 60, g.team, false},
+	}
+	for _, p := range g.team[:5] {
+		day := []string{"MO", "TU", "WE", "TH", "FR"}[g.r.Intn(5)]
+		templates = append(templates, seriesTemplate{"1:1 " + g.self.first() + " / " + p.first(), "FREQ=WEEKLY;BYDAY=" + day,
+			"primary", 11 + g.r.Intn(5), 0, 30, []person{p}, true})
+	}
+	// Project syncs come and go; a larger archive has more of them
+	for i := 0; i < events/1000; i++ {
+		day := []string{"MO", "TU", "WE", "TH", "FR"}[g.r.Intn(5)]
+		templates = append(templates, seriesTemplate{projects[g.r.Intn(len(projects))] + " weekly sync", "FREQ=WEEKLY;BYDAY=" + day,
+			"primary", 13 + g.r.Intn(4), 30 * g.r.Intn(2), 45, g.pick(g.people, 2+g.r.Intn(5)), true})
+	}
+
+	exceptionBudget := events / 20
+	for _, t := range templates {
+		if len(g.writes) >= events/2 {
+			break
+		}
+		g.addSeries(t, &exceptionBudget)
+	}
+}
+
+// addSeries adds a series, running for a random part of the archive, and
+// some of its occurrences moved or cancelled.
+func (g *generator) addSeries(t seriesTemplate, exceptionBudget *int) {
+	days := int(g.to.Sub(g.from).Hours() / 24)
+	first := g.from.AddDate(0, 0, g.r.Intn(max(days/3, 1)))
+	start := time.Date(first.Year(), first.Month(), first.Day(), t.hour, t.minute, 0, 0, g.loc)
+	rule := "RRULE:" + t.rule
+	// Most series end: people change teams, book clubs fold
+	if g.r.Intn(3) > 0 {
+		until := start.AddDate(0, 0, days/4+g.r.Intn(max(days/2, 1)))
+		if until.Before(g.to) {
+			rule += ";UNTIL=" + until.UTC().Format("20060102T150405Z")
+		}
+	}
+
+	series := g.event(t.calendar, t.title, start, time.Duration(t.minutes)*time.Minute)
+	series.Event.RecurrenceRule = rule
+	g.addGuests(series, t.guests)
+	if t.video {
+		g.addMeet(series)
+	}
+	g.writes = append(g.writes, series)
+	g.summary.Series++
+
+	rec, err := ics.ParseRecurrence(rule)
+	if err != nil {
+		return
+	}
+	// Leave room to move the last occurrence a day
+	for _, at := range rec.Between(start, start, g.to.AddDate(0, 0, -1)) {
+		if *exceptionBudget <= 0 {
+			return
+		}
+		if g.r.Intn(25) > 0 {
+			continue
+		}
+		inst := *series.Event
+		inst.GoogleEventID = series.Event.GoogleEventID + "_" + at.UTC().Format("20060102T150405Z")
+		inst.RecurringEventID = series.Event.GoogleEventID
+		inst.RecurrenceRule = ""
+		if g.r.Intn(3) == 0 {
+			inst.Status = "cancelled"
+			inst.StartTime = sql.NullTime{Time: at.UTC(), Valid: true}
+		} else {
+			// Moved an hour or a day
+			moved := at.Add(time.Hour)
+			if g.r.Intn(2) == 0 {
+				moved = at.AddDate(0, 0, 1)
+			}
+			inst.StartTime = sql.NullTime{Time: moved.UTC(), Valid: true}
+		}
+		inst.EndTime = sql.NullTime{Time: inst.StartTime.Time.Add(time.Duration(t.minutes) * time.Minute), Valid: true}
+		g.writes = append(g.writes, &store.EventWrite{Event: &inst, Attendees: series.Attendees, Conference: series.Conference})
+		g.summary.Exceptions++
+		*exceptionBudget--
+	}
+}
+
+// makeEvent adds a one-off event: mostly work meetings, with interviews,
+// personal appointments and all-day events mixed in.
+func (g *generator) makeEvent() {
+	day := g.from.Add(time.Duration(g.r.Int63n(int64(g.to.Sub(g.from)))))
+	weekend := day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+	switch n := g.r.Intn(100); {
+	case n < 5:
+		g.addAllDay(day)
+	case n < 20 || weekend:
+		g.addPersonal(day, weekend)
+	case n < 28:
+		g.addInterview(day)
+	default:
+		g.addMeeting(day)
+	}
+}
+
+// addMeeting adds a work meeting during office hours.
+func (g *generator) addMeeting(day time.Time) {
+	start := g.at(day, 8+g.r.Intn(10), 30*g.r.Intn(2))
+	minutes := []int{15, 30, 30, 30, 45, 60, 60, 90}[g.r.Intn(8)]
+	guests := g.pick(g.people, 1+g.r.Intn(6))
+	title := g.title(workTitles, guests[0])
+	w := g.event("primary", title, start, time.Duration(minutes)*time.Minute)
+	g.addGuests(w, guests)
+	switch n := g.r.Intn(10); {
+	case n < 5:
+		g.addMeet(w)
+	case n < 7:
+		w.Event.Location = fmt.Sprintf("https://zoom.us/j/%d", 80000000000+g.r.Int63n(9999999999))
+	default:
+		w.Event.Location = rooms[g.r.Intn(len(rooms))]
+	}
+	if g.r.Intn(4) == 0 {
+		w.Event.Description = fmt.Sprintf("Agenda:\n- Status of %s\n- Open questions\n- Next steps", projects[g.r.Intn(len(projects))])
+	}
+	g.writes = append(g.writes, w)
+}
+
+// addInterview adds a candidate interview with one or two colleagues.
+func (g *generator) addInterview(day time.Time) {
+	start := g.at(day, 10+g.r.Intn(7), 0)
+	candidate := firstNames[g.r.Intn(len(firstNames))] + " " + lastNames[g.r.Intn(len(lastNames))]
+	title := fmt.Sprintf("Interview: %s (%s)", candidate, roles[g.r.Intn(len(roles))])
+	w := g.event("primary", title, start, 45*time.Minute)
+	g.addGuests(w, g.pick(g.team, 1+g.r.Intn(2)))
+	g.addMeet(w)
+	g.writes = append(g.writes, w)
+}
+
+// addPersonal adds a personal appointment, in the evening on weekdays.
+func (g *generator) addPersonal(day time.Time, weekend bool) {
+	hour := 17 + g.r.Intn(4)
+	if weekend {
+		hour = 9 + g.r.Intn(12)
+	}
+	start := g.at(day, hour, 0)
+	friend := g.people[g.r.Intn(len(g.people))]
+	w := g.event("Personal", g.title(personalTitles, friend), start, time.Duration(30+30*g.r.Intn(4))*time.Minute)
+	w.Event.Location = places[g.r.Intn(len(places))]
+	if strings.Contains(w.Event.Summary, friend.first()) {
+		g.addGuests(w, []person{friend})
+	}
+	g.writes = append(g.writes, w)
+}
+
+// addAllDay adds an all-day event of one to five days.
+func (g *generator) addAllDay(day time.Time) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	days := 1
+	calendar := "primary"
+	title := g.title(allDayTitles, g.team[g.r.Intn(len(g.team))])
+	switch {
+	case title == "Vacation" || title == "Offsite":
+		days = 2 + g.r.Intn(4)
+	case title == "Public holiday":
+		calendar = "Holidays in United States"
+	case strings.HasSuffix(title, "birthday"):
+		calendar = "Team"
+	}
+	w := g.event(calendar, title, start, time.Duration(days)*24*time.Hour)
+	w.Event.AllDay = true
+	w.Event.OriginalTimezone = ""
+	g.writes = append(g.writes, w)
+}
+
+// event makes an event on the named calendar ("primary" for the account's
+// own), organized by the demo account.
+func (g *generator) event(calendar, title string, start time.Time, d time.Duration) *store.EventWrite {
+	if calendar == "primary" {
+		calendar = Account
+	}
+	id := fmt.Sprintf("demo%07d", len(g.writes)+1)
+	// Events are created up to a month ahead, and not after now
+	created := start.AddDate(0, 0, -1-g.r.Intn(30))
+	if created.After(g.now) {
+		created = g.now.Add(-time.Duration(g.r.Int63n(int64(30 * 24 * time.Hour))))
+	}
+	return &store.EventWrite{Event: &store.Event{
+		SourceID:         g.sourceID,
+		CalendarID:       g.calendars[calendar],
+		GoogleEventID:    id,
+		ICalUID:          id + "@google.com",
+		Summary:          title,
+		StartTime:        sql.NullTime{Time: start.UTC(), Valid: true},
+		EndTime:          sql.NullTime{Time: start.Add(d).UTC(), Valid: true},
+		OriginalTimezone: g.loc.String(),
+		Status:           "confirmed",
+		OrganizerEmail:   g.self.email,
+		OrganizerName:    g.self.name,
+		CreatorEmail:     g.self.email,
+		CreatedAt:        sql.NullTime{Time: created.UTC(), Valid: true},
+		UpdatedAt:        sql.NullTime{Time: created.UTC(), Valid: true},
+	}}
+}
+
+// addGuests invites people to an event, with the demo account attending.
+// Some events are organized by a guest instead.
+func (g *generator) addGuests(w *store.EventWrite, guests []person) {
+	if len(guests) == 0 {
+		return
+	}
+	organizer := g.self
+	if g.r.Intn(3) == 0 {
+		organizer = guests[0]
+		w.Event.OrganizerEmail, w.Event.OrganizerName, w.Event.CreatorEmail = organizer.email, organizer.name, organizer.email
+	}
+	selfStatus := "accepted"
+	if organizer != g.self {
+		selfStatus = []string{"accepted", "accepted", "accepted", "tentative", "declined", "needsAction"}[g.r.Intn(6)]
+	}
+	w.Attendees = []*store.Attendee{{Email: g.self.email, DisplayName: g.self.name, ResponseStatus: selfStatus,
+		IsOrganizer: organizer == g.self, IsSelf: true}}
+	for _, p := range guests {
+		status := "accepted"
+		if p != organizer {
+			status = []string{"accepted", "accepted", "accepted", "tentative", "declined", "needsAction"}[g.r.Intn(6)]
+		}
+		w.Attendees = append(w.Attendees, &store.Attendee{Email: p.email, DisplayName: p.name, ResponseStatus: status,
+			IsOrganizer: p == organizer})
+	}
+}
+
+// addMeet gives an event a Google Meet conference.
+func (g *generator) addMeet(w *store.EventWrite) {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	code := make([]byte, 0, 12)
+	for i, n := range []int{3, 4, 3} {
+		if i > 0 {
+			code = append(code, '-')
+		}
+		for j := 0; j < n; j++ {
+			code = append(code, letters[g.r.Intn(len(letters))])
+		}
+	}
+	link := "https://meet.google.com/" + string(code)
+	w.Conference = &store.Conference{
+		SolutionType: "hangoutsMeet",
+		SolutionName: "Google Meet",
+		ConferenceID: string(code),
+		MeetingCode:  string(code),
+		VideoURI:     link,
+		HangoutLink:  link,
+		EntryPoints:  []*store.ConferenceEntryPoint{{Type: "video", URI: link, Label: strings.TrimPrefix(link, "https://"), MeetingCode: string(code)}},
+	}
+}
+
+// at returns the given local time on day.
+func (g *generator) at(day time.Time, hour, minute int) time.Time {
+	day = day.In(g.loc)
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, g.loc)
+}
+
+// title picks a title, naming p or a project in those with a %s.
+func (g *generator) title(titles []string, p person) string {
+	t := titles[g.r.Intn(len(titles))]
+	if !strings.Contains(t, "%s") {
+		return t
+	}
+	if g.r.Intn(2) == 0 || strings.Contains(t, "with") || strings.Contains(t, "birthday") {
+		return fmt.Sprintf(t, p.first())
+	}
+	return fmt.Sprintf(t, projects[g.r.Intn(len(projects))])
+}
+
+// pick returns n different people from people.
+func (g *generator) pick(people []person, n int) []person {
+	n = min(n, len(people))
+	picked := make([]person, 0, n)
+	for _, i := range g.r.Perm(len(people))[:n] {
+		picked = append(picked, people[i])
+	}
+	return picked
+}
+
+// first returns a person's first name.
+func (p person) first() string {
+	first, _, _ := strings.Cut(p.name, " ")
+	return first
+}
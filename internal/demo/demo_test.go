@@ -0,0 +1,65 @@
+package demo
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+func TestGenerate(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	opts := Options{Events: 2000, Seed: 1, Now: time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)}
+	summary, err := Generate(s, opts)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if summary.Events != 2000 || summary.Calendars != 4 || summary.Series == 0 || summary.Exceptions == 0 {
+		t.Errorf("summary = %+v, want 2000 events in 4 calendars with series and exceptions", summary)
+	}
+
+	count := func(query string) int {
+		t.Helper()
+		var n int
+		if err := s.DB().QueryRow(query).Scan(&n); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return n
+	}
+	if n := count(`SELECT COUNT(*) FROM events`); n != 2000 {
+		t.Errorf("stored %d events, want 2000", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM events WHERE recurrence_rule != '' AND COALESCE(recurring_event_id, '') = ''`); n == 0 {
+		t.Error("no recurring series stored")
+	}
+	if n := count(`SELECT COUNT(*) FROM attendees WHERE is_self`); n == 0 {
+		t.Error("no events the demo account attends")
+	}
+	if n := count(`SELECT COUNT(*) FROM conference_data`); n == 0 {
+		t.Error("no video calls stored")
+	}
+	if n := count(`SELECT COUNT(*) FROM events WHERE start_time < '2025-06-01' OR start_time > '2026-09-01'`); n != 0 {
+		t.Errorf("%d events outside the year before Now and 90 days after", n)
+	}
+
+	// Generating again replaces the archive
+	opts.Events = 500
+	if _, err := Generate(s, opts); err != nil {
+		t.Fatalf("generate again: %v", err)
+	}
+	if n := count(`SELECT COUNT(*) FROM events`); n != 500 {
+		t.Errorf("regenerated archive has %d events, want 500", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM sources`); n != 1 {
+		t.Errorf("%d accounts, want the demo account only", n)
+	}
+}
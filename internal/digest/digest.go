@@ -0,0 +1,260 @@
+// Package digest builds the daemon's daily digest - yesterday's meetings,
+// today's agenda and the hours each took - renders it with a text template
+// and sends it by email or webhook.
+package digest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/salman1993/calvault/internal/agenda"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// Digest is the data a digest template is rendered with.
+type Digest struct {
+	Yesterday Day `json:"yesterday"`
+	Today     Day `json:"today"`
+}
+
+// Day is one day of a digest.
+type Day struct {
+	Date   time.Time `json:"date"` // Local midnight
+	Events []*Event  `json:"events"`
+	Hours  float64   `json:"hours"` // Timed events' length, declined ones left out
+}
+
+// Event is one occurrence in a digest.
+type Event struct {
+	Title     string    `json:"title"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end,omitempty"`
+	AllDay    bool      `json:"all_day"`
+	Location  string    `json:"location,omitempty"`
+	Tentative bool      `json:"tentative,omitempty"`
+	Declined  bool      `json:"declined,omitempty"` // By the account owner
+	Attendees int       `json:"attendees"`          // Rooms left out
+	Calendar  string    `json:"calendar"`
+	Account   string    `json:"account"`
+}
+
+// Build returns the digest for the local day today falls on. A meeting on
+// several accounts' calendars is listed once.
+func Build(s *store.Store, today time.Time) (*Digest, error) {
+	calendars, err := calendarNames(s)
+	if err != nil {
+		return nil, err
+	}
+	first := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)
+	d := &Digest{}
+	for _, day := range []struct {
+		day  *Day
+		date time.Time
+	}{{&d.Yesterday, first.AddDate(0, 0, -1)}, {&d.Today, first}} {
+		items, err := agenda.Days(s, day.date, day.date.AddDate(0, 0, 1))
+		if err != nil {
+			return nil, err
+		}
+		day.day.Date = day.date
+		day.day.Events = []*Event{}
+		seen := map[string]bool{}
+		for _, it := range items {
+			if it.Event.ICalUID != "" {
+				key := it.Event.ICalUID + "|" + it.Start.UTC().Format(time.RFC3339)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
+			e, err := event(s, it, calendars)
+			if err != nil {
+				return nil, err
+			}
+			day.day.Events = append(day.day.Events, e)
+			if !e.AllDay && !e.End.IsZero() && !e.Declined {
+				day.day.Hours += e.End.Sub(e.Start).Hours()
+			}
+		}
+	}
+	return d, nil
+}
+
+// calendarInfo names a calendar and its account.
+type calendarInfo struct {
+	name, account string
+}
+
+func calendarNames(s *store.Store) (map[int64]calendarInfo, error) {
+	sources, err := s.ListSources()
+	if err != nil {
+		return nil, err
+	}
+	calendars := map[int64]calendarInfo{}
+	for _, src := range sources {
+		cals, err := s.GetCalendars(src.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, cal := range cals {
+			calendars[cal.ID] = calendarInfo{name: cal.Summary, account: src.Identifier}
+		}
+	}
+	return calendars, nil
+}
+
+func event(s *store.Store, it *agenda.Item, calendars map[int64]calendarInfo) (*Event, error) {
+	cal := calendars[it.Event.CalendarID]
+	e := &Event{
+		Title:     it.Event.Summary,
+		Start:     it.Start,
+		End:       it.End,
+		AllDay:    it.Event.AllDay,
+		Location:  it.Event.Location,
+		Tentative: it.Event.Status == "tentative",
+		Calendar:  cal.name,
+		Account:   cal.account,
+	}
+	attendees, err := s.ListAttendees(it.Event.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list attendees: %w", err)
+	}
+	for _, a := range attendees {
+		if a.IsSelf && a.ResponseStatus == "declined" {
+			e.Declined = true
+		}
+		if !strings.HasSuffix(a.Email, "@resource.calendar.google.com") {
+			e.Attendees++
+		}
+	}
+	return e, nil
+}
+
+// defaultTemplate is the plain-text digest used without [daemon.digest]
+// template.
+const defaultTemplate = `{{define "day"}}{{if not .Events}}  No events.
+{{else}}{{range .Events}}  {{if .AllDay}}All day    {{else}}{{clock .Start}}-{{if .End.IsZero}}     {{else}}{{clock .End}}{{end}}{{end}}  {{or .Title "(no title)"}}{{if .Location}} @ {{.Location}}{{end}}{{if .Declined}} (declined){{else if .Tentative}} (tentative){{end}}
+{{end}}{{end}}{{end}}Today, {{date .Today.Date}}: {{len .Today.Events}} event(s), {{hours .Today.Hours}}
+{{template "day" .Today}}
+Yesterday, {{date .Yesterday.Date}}: {{len .Yesterday.Events}} event(s), {{hours .Yesterday.Hours}}
+{{template "day" .Yesterday}}`
+
+// funcs are the functions available to digest templates.
+var funcs = template.FuncMap{
+	"clock": func(t time.Time) string { return t.Local().Format("15:04") },
+	"date":  func(t time.Time) string { return t.Format("Monday 2006-01-02") },
+	"hours": func(h float64) string { return strconv.FormatFloat(h, 'f', 1, 64) + "h" },
+}
+
+// Template parses a digest template file, or returns the built-in template
+// if path is empty. Templates get a Digest and the functions clock (15:04),
+// date (Monday 2006-01-02) and hours (1.5h).
+func Template(path string) (*template.Template, error) {
+	text := defaultTemplate
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read digest template: %w", err)
+		}
+		text = string(b)
+	}
+	t, err := template.New("digest").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse digest template: %w", err)
+	}
+	return t, nil
+}
+
+// Render renders the digest with a template.
+func (d *Digest) Render(t *template.Template) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, d); err != nil {
+		return "", fmt.Errorf("render digest: %w", err)
+	}
+	return b.String(), nil
+}
+
+// Subject returns the digest's email subject.
+func (d *Digest) Subject() string {
+	return fmt.Sprintf("calvault digest for %s", d.Today.Date.Format("Monday 2006-01-02"))
+}
+
+// Payload is what a webhook receives: the rendered digest in text (the
+// field Slack and similar incoming webhooks post) and its data.
+type Payload struct {
+	Subject string  `json:"subject"`
+	Text    string  `json:"text"`
+	Digest  *Digest `json:"digest"`
+}
+
+// PostWebhook POSTs the digest to url as a JSON Payload.
+func PostWebhook(ctx context.Context, client *http.Client, url string, d *Digest, text string) error {
+	body, err := json.Marshal(&Payload{Subject: d.Subject(), Text: text, Digest: d})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// Mail is an SMTP server and the digest's sender and recipients.
+type Mail struct {
+	Host     string
+	Port     int
+	Username string // Empty to send without authenticating
+	Password string
+	From     string
+	To       []string
+}
+
+// Send mails the digest as plain text. net/smtp upgrades to TLS when the
+// server offers STARTTLS and only authenticates over TLS or to localhost.
+func (m *Mail) Send(d *Digest, text string) error {
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	if err := smtp.SendMail(addr, auth, m.From, m.To, m.message(d, text, time.Now())); err != nil {
+		return fmt.Errorf("send digest to %s: %w", addr, err)
+	}
+	return nil
+}
+
+// message formats the digest as an RFC 5322 message with CRLF line ends.
+func (m *Mail) message(d *Digest, text string, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", d.Subject())
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	b.WriteString(strings.ReplaceAll(text, "\n", "\r\n"))
+	return b.Bytes()
+}
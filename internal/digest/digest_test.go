@@ -0,0 +1,129 @@
+package digest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+func TestBuild(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	at := func(day, hour int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2024, 3, day, hour, 0, 0, 0, time.Local), Valid: true}
+	}
+	work, _ := s.GetOrCreateSource("work@example.com")
+	home, _ := s.GetOrCreateSource("home@example.com")
+	workCal, _ := s.UpsertCalendar(work.ID, &store.Calendar{GoogleCalendarID: "primary", Summary: "Work"})
+	homeCal, _ := s.UpsertCalendar(home.ID, &store.Calendar{GoogleCalendarID: "primary", Summary: "Home"})
+	events := []*store.Event{
+		{SourceID: work.ID, CalendarID: workCal, GoogleEventID: "review", ICalUID: "review@x", Summary: "Review", StartTime: at(4, 14), EndTime: at(4, 15)},
+		{SourceID: work.ID, CalendarID: workCal, GoogleEventID: "standup", ICalUID: "standup@x", Summary: "Standup", StartTime: at(5, 9), EndTime: at(5, 10)},
+		// The same meeting on the home calendar
+		{SourceID: home.ID, CalendarID: homeCal, GoogleEventID: "standup", ICalUID: "standup@x", Summary: "Standup", StartTime: at(5, 9), EndTime: at(5, 10)},
+		{SourceID: work.ID, CalendarID: workCal, GoogleEventID: "allhands", ICalUID: "allhands@x", Summary: "All hands", StartTime: at(5, 11), EndTime: at(5, 13)},
+		{SourceID: home.ID, CalendarID: homeCal, GoogleEventID: "dentist", Summary: "Dentist", Location: "Main St", StartTime: at(5, 16), EndTime: at(5, 17), Status: "tentative"},
+		{SourceID: work.ID, CalendarID: workCal, GoogleEventID: "later", Summary: "Later", StartTime: at(6, 9), EndTime: at(6, 10)},
+	}
+	ids := map[string]int64{}
+	for _, e := range events {
+		id, err := s.UpsertEvent(e)
+		if err != nil {
+			t.Fatalf("upsert %s: %v", e.GoogleEventID, err)
+		}
+		ids[e.GoogleEventID] = id
+	}
+	if err := s.ReplaceAttendees(ids["allhands"], []*store.Attendee{
+		{Email: "me@example.com", IsSelf: true, ResponseStatus: "declined"},
+		{Email: "ann@example.com", ResponseStatus: "accepted"},
+		{Email: "room@resource.calendar.google.com", ResponseStatus: "accepted"},
+	}); err != nil {
+		t.Fatalf("attendees: %v", err)
+	}
+
+	d, err := Build(s, time.Date(2024, 3, 5, 8, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	var titles []string
+	for _, e := range d.Today.Events {
+		titles = append(titles, e.Title)
+	}
+	if got := strings.Join(titles, ","); got != "Standup,All hands,Dentist" {
+		t.Errorf("today = %s, want Standup,All hands,Dentist", got)
+	}
+	if d.Today.Hours != 2 {
+		t.Errorf("today's hours = %v, want 2 (the declined all hands left out)", d.Today.Hours)
+	}
+	if e := d.Today.Events[1]; !e.Declined || e.Attendees != 2 || e.Calendar != "Work" || e.Account != "work@example.com" {
+		t.Errorf("all hands = %+v", e)
+	}
+	if len(d.Yesterday.Events) != 1 || d.Yesterday.Hours != 1 {
+		t.Errorf("yesterday = %+v, want the review", d.Yesterday)
+	}
+
+	tmpl, err := Template("")
+	if err != nil {
+		t.Fatalf("template: %v", err)
+	}
+	text, err := d.Render(tmpl)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	for _, line := range []string{
+		"Today, Tuesday 2024-03-05: 3 event(s), 2.0h\n",
+		"  11:00-13:00  All hands (declined)\n",
+		"  16:00-17:00  Dentist @ Main St (tentative)\n",
+		"Yesterday, Monday 2024-03-04: 1 event(s), 1.0h\n",
+	} {
+		if !strings.Contains(text, line) {
+			t.Errorf("digest missing %q:\n%s", line, text)
+		}
+	}
+
+	var got Payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+	}))
+	defer server.Close()
+	if err := PostWebhook(context.Background(), server.Client(), server.URL, d, text); err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	if got.Text != text || got.Subject != "calvault digest for Tuesday 2024-03-05" || len(got.Digest.Today.Events) != 3 {
+		t.Errorf("payload = %+v", got)
+	}
+}
+
+func TestMail_Message(t *testing.T) {
+	m := &Mail{From: "calvault@example.com", To: []string{"me@example.com", "you@example.com"}}
+	d := &Digest{Today: Day{Date: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)}}
+	now := time.Date(2024, 3, 5, 7, 0, 0, 0, time.UTC)
+	want := "From: calvault@example.com\r\n" +
+		"To: me@example.com, you@example.com\r\n" +
+		"Subject: calvault digest for Tuesday 2024-03-05\r\n" +
+		"Date: Tue, 05 Mar 2024 07:00:00 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"Line one\r\nLine two\r\n"
+	if got := string(m.message(d, "Line one\nLine two\n", now)); got != want {
+		t.Errorf("message =\n%q\nwant\n%q", got, want)
+	}
+}
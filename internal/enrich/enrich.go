@@ -0,0 +1,158 @@
+// Package enrich derives data from synced events in ordered stages: the
+// meeting platform an event is held on, tags from rules, the entities -
+// doctors, companies, project codes - it is about, and the coordinates of
+// its location. Each stage is an Enricher, stores its results in its own
+// tables and only revisits events that are new or re-synced since its last
+// run, so new kinds of derived data plug in without touching sync.
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/salman1993/calvault/internal/ask"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// Extractor finds the entities an event is about.
+type Extractor interface {
+	// Name identifies the extractor in the entities table.
+	Name() string
+	Extract(ctx context.Context, e *store.Event) ([]*store.Entity, error)
+}
+
+// Rule is a regular expression whose first capture group, or whole match,
+// names an entity of Kind.
+type Rule struct {
+	Kind       string
+	Pattern    *regexp.Regexp
+	Confidence float64
+}
+
+// RuleExtractor matches rules against event titles and descriptions.
+type RuleExtractor struct {
+	Rules []Rule
+}
+
+// Name implements Extractor.
+func (r *RuleExtractor) Name() string { return "rules" }
+
+// Extract implements Extractor.
+func (r *RuleExtractor) Extract(ctx context.Context, e *store.Event) ([]*store.Entity, error) {
+	var entities []*store.Entity
+	for _, text := range []string{e.Summary, e.Description} {
+		for _, rule := range r.Rules {
+			for _, m := range rule.Pattern.FindAllStringSubmatch(text, -1) {
+				name := m[0]
+				if len(m) > 1 && m[1] != "" {
+					name = m[1]
+				}
+				entities = append(entities, &store.Entity{Kind: rule.Kind, Name: strings.TrimSpace(name), Confidence: rule.Confidence})
+			}
+		}
+	}
+	return entities, nil
+}
+
+// LLMExtractor asks a language model for the entities in an event's title
+// and description.
+type LLMExtractor struct {
+	Client *ask.Client
+}
+
+// Name implements Extractor.
+func (l *LLMExtractor) Name() string { return "llm" }
+
+// maxDescription caps the description sent to the model.
+const maxDescription = 2000
+
+const extractPrompt = `You extract named entities from calendar events: the people (such as
+doctors), companies, places, products and project codes an event is about.
+Reply with only a JSON array of objects with "kind" (a short lowercase noun
+such as doctor, person, company, project), "name" (as written in the event)
+and "confidence" (0 to 1). Reply [] if there are none. Leave out the
+event's generic words (meeting, call, sync).`
+
+// Extract implements Extractor.
+func (l *LLMExtractor) Extract(ctx context.Context, e *store.Event) ([]*store.Entity, error) {
+	description := e.Description
+	if len(description) > maxDescription {
+		description = description[:maxDescription]
+	}
+	reply, err := l.Client.Complete(ctx, []ask.Message{
+		{Role: "system", Content: extractPrompt},
+		{Role: "user", Content: fmt.Sprintf("Title: %s\nDescription: %s", e.Summary, description)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseEntities(reply)
+}
+
+// parseEntities reads the model's JSON array, tolerating a code fence
+// around it.
+func parseEntities(reply string) ([]*store.Entity, error) {
+	start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON array in reply: %q", firstLine(reply))
+	}
+	var items []struct {
+		Kind       string  `json:"kind"`
+		Name       string  `json:"name"`
+		Confidence float64 `json:"confidence"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &items); err != nil {
+		return nil, fmt.Errorf("decode entities: %w", err)
+	}
+	var entities []*store.Entity
+	for _, it := range items {
+		kind, name := strings.ToLower(strings.TrimSpace(it.Kind)), strings.TrimSpace(it.Name)
+		if kind == "" || name == "" {
+			continue
+		}
+		entities = append(entities, &store.Entity{Kind: kind, Name: name, Confidence: min(max(it.Confidence, 0), 1)})
+	}
+	return entities, nil
+}
+
+func firstLine(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	return s
+}
+
+// EntityStage is the enrichment stage storing the entities an extractor
+// finds at or above MinConfidence.
+type EntityStage struct {
+	Extractor     Extractor
+	MinConfidence float64
+}
+
+// Name implements Enricher. Each extractor keeps its own state, as their
+// entities are stored side by side.
+func (st *EntityStage) Name() string { return "entities:" + st.Extractor.Name() }
+
+// Enrich implements Enricher.
+func (st *EntityStage) Enrich(ctx context.Context, s *store.Store, e *store.Event) (int, error) {
+	found, err := st.Extractor.Extract(ctx, e)
+	if err != nil {
+		return 0, err
+	}
+	var kept []*store.Entity
+	for _, entity := range found {
+		if entity.Confidence >= st.MinConfidence {
+			kept = append(kept, entity)
+		}
+	}
+	if err := s.SaveEntities(e.ID, st.Extractor.Name(), kept); err != nil {
+		return 0, err
+	}
+	return len(kept), nil
+}
+
+// Clear implements Enricher.
+func (st *EntityStage) Clear(s *store.Store) error {
+	return s.ClearEntities(st.Extractor.Name())
+}
@@ -0,0 +1,163 @@
+package enrich
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/salman1993/calvault/internal/ask"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+func TestRuleExtractor(t *testing.T) {
+	ex := &RuleExtractor{Rules: []Rule{
+		{Kind: "doctor", Pattern: regexp.MustCompile(`Dr\.? ([A-Z][a-z]+)`), Confidence: 0.9},
+		{Kind: "project", Pattern: regexp.MustCompile(`\b[A-Z]{2,5}-\d+\b`), Confidence: 1},
+	}}
+	got, err := ex.Extract(context.Background(), &store.Event{
+		Summary:     "Checkup with Dr. Patel",
+		Description: "Re: OPS-12 and OPS-14",
+	})
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	var names []string
+	for _, e := range got {
+		names = append(names, fmt.Sprintf("%s:%s:%.1f", e.Kind, e.Name, e.Confidence))
+	}
+	want := "doctor:Patel:0.9 project:OPS-12:1.0 project:OPS-14:1.0"
+	if strings.Join(names, " ") != want {
+		t.Errorf("entities = %v, want %s", names, want)
+	}
+}
+
+func TestParseEntities(t *testing.T) {
+	tests := []struct {
+		reply   string
+		want    string
+		wantErr bool
+	}{
+		{`[]`, "", false},
+		{"```json\n[{\"kind\": \"Company\", \"name\": \" Acme \", \"confidence\": 0.8}]\n```", "company:Acme:0.8", false},
+		{`[{"kind": "doctor", "name": "Dr. Lee", "confidence": 3}, {"kind": "", "name": "x"}]`, "doctor:Dr. Lee:1.0", false},
+		{"No entities here.", "", true},
+		{`[{"kind": 1}]`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseEntities(tt.reply)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseEntities(%q) error = %v, wantErr %v", tt.reply, err, tt.wantErr)
+			continue
+		}
+		var names []string
+		for _, e := range got {
+			names = append(names, fmt.Sprintf("%s:%s:%.1f", e.Kind, e.Name, e.Confidence))
+		}
+		if strings.Join(names, " ") != tt.want {
+			t.Errorf("parseEntities(%q) = %v, want %s", tt.reply, names, tt.want)
+		}
+	}
+}
+
+func TestLLMExtractor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%q}}]}`,
+			`[{"kind":"company","name":"Acme","confidence":0.7}]`)
+	}))
+	defer srv.Close()
+
+	ex := &LLMExtractor{Client: &ask.Client{Endpoint: srv.URL, Model: "test"}}
+	got, err := ex.Extract(context.Background(), &store.Event{Summary: "Acme renewal"})
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "Acme" || got[0].Kind != "company" {
+		t.Errorf("entities = %+v, want company Acme", got)
+	}
+}
+
+// failingExtractor fails on events whose title contains "fail".
+type failingExtractor struct{ RuleExtractor }
+
+func (f *failingExtractor) Extract(ctx context.Context, e *store.Event) ([]*store.Entity, error) {
+	if strings.Contains(e.Summary, "fail") {
+		return nil, errors.New("model unavailable")
+	}
+	return f.RuleExtractor.Extract(ctx, e)
+}
+
+func TestRun(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: "primary"})
+	upsert := func(id, summary string) {
+		_, err := s.UpsertEvent(&store.Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: id, Summary: summary,
+			StartTime: sql.NullTime{Time: time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC), Valid: true}})
+		if err != nil {
+			t.Fatalf("upsert %s: %v", id, err)
+		}
+	}
+	upsert("a", "Checkup with Dr. Patel")
+	upsert("b", "Follow-up, Dr Patel (maybe Dr Lee)")
+	upsert("c", "Lunch")
+	upsert("d", "fail with Dr. Who")
+
+	ex := &failingExtractor{RuleExtractor{Rules: []Rule{
+		{Kind: "doctor", Pattern: regexp.MustCompile(`Dr\.? ([A-Z][a-z]+)`), Confidence: 0.9},
+		{Kind: "maybe", Pattern: regexp.MustCompile(`maybe (Dr \w+)`), Confidence: 0.2},
+	}}}
+	stage := &EntityStage{Extractor: ex, MinConfidence: 0.5}
+	summary, err := Run(context.Background(), s, stage, 0, nil)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if summary.Events != 3 || summary.Found != 3 || summary.Failed != 1 || summary.LastErr == nil {
+		t.Errorf("summary = %+v, want 3 events, 3 entities, 1 failed", summary)
+	}
+
+	top, err := s.TopEntities(0, 10)
+	if err != nil {
+		t.Fatalf("top entities: %v", err)
+	}
+	var got []string
+	for _, c := range top {
+		got = append(got, fmt.Sprintf("%s:%s:%d", c.Kind, c.Name, c.Events))
+	}
+	if want := "doctor:Patel:2 doctor:Lee:1"; strings.Join(got, " ") != want {
+		t.Errorf("top entities = %v, want %s", got, want)
+	}
+
+	// Only the failed event is retried, until an event is synced again
+	summary, err = Run(context.Background(), s, stage, 0, nil)
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if summary.Events != 0 || summary.Failed != 1 {
+		t.Errorf("second run = %+v, want only the failed event retried", summary)
+	}
+	time.Sleep(10 * time.Millisecond)
+	upsert("c", "Lunch with Dr. Lee")
+	summary, err = Run(context.Background(), s, stage, 0, nil)
+	if err != nil {
+		t.Fatalf("third run: %v", err)
+	}
+	if summary.Events != 1 || summary.Found != 1 {
+		t.Errorf("third run = %+v, want the re-synced event processed", summary)
+	}
+}
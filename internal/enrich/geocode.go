@@ -0,0 +1,162 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// Geocoder looks up the coordinates of a place.
+type Geocoder interface {
+	// Geocode returns the place found for query, with Found false if there
+	// is no match.
+	Geocode(ctx context.Context, query string) (*store.Geocode, error)
+}
+
+// NominatimGeocoder looks places up with the search API of Nominatim
+// (OpenStreetMap) or a server compatible with it.
+type NominatimGeocoder struct {
+	Endpoint  string        // e.g. https://nominatim.openstreetmap.org/search
+	UserAgent string        // Identifies the application, as Nominatim requires
+	Interval  time.Duration // Minimum time between requests
+
+	Client *http.Client // nil for a client with a 30 second timeout
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// Geocode implements Geocoder.
+func (n *NominatimGeocoder) Geocode(ctx context.Context, query string) (*store.Geocode, error) {
+	if err := n.wait(ctx); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(n.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid geocoder endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("q", query)
+	q.Set("format", "jsonv2")
+	q.Set("limit", "1")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", n.UserAgent)
+	req.Header.Set("Accept", "application/json")
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return nil, fmt.Errorf("geocoder: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var places []struct {
+		Lat         string `json:"lat"`
+		Lon         string `json:"lon"`
+		DisplayName string `json:"display_name"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&places); err != nil {
+		return nil, fmt.Errorf("decode geocoder response: %w", err)
+	}
+	g := &store.Geocode{Query: query}
+	if len(places) == 0 {
+		return g, nil
+	}
+	lat, err1 := strconv.ParseFloat(places[0].Lat, 64)
+	lon, err2 := strconv.ParseFloat(places[0].Lon, 64)
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("geocoder returned invalid coordinates %q, %q", places[0].Lat, places[0].Lon)
+	}
+	g.Found, g.Latitude, g.Longitude, g.DisplayName = true, lat, lon, places[0].DisplayName
+	return g, nil
+}
+
+// wait spaces requests Interval apart.
+func (n *NominatimGeocoder) wait(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if d := time.Until(n.last.Add(n.Interval)); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	n.last = time.Now()
+	return nil
+}
+
+// GeocodeStage is the enrichment stage looking up the coordinates of event
+// locations into event_locations. Each distinct location is looked up once
+// and cached in geocodes; online meetings aren't looked up.
+type GeocodeStage struct {
+	Geocoder Geocoder
+}
+
+// Name implements Enricher.
+func (g *GeocodeStage) Name() string { return "geocode" }
+
+// Enrich implements Enricher.
+func (g *GeocodeStage) Enrich(ctx context.Context, s *store.Store, e *store.Event) (int, error) {
+	query := geocodeQuery(e.Location)
+	if query == "" {
+		return 0, s.SetEventLocation(e.ID, nil)
+	}
+	place, err := s.GetGeocode(query)
+	if err != nil {
+		return 0, err
+	}
+	if place == nil {
+		if place, err = g.Geocoder.Geocode(ctx, query); err != nil {
+			return 0, err
+		}
+		place.Query = query
+		if err := s.SaveGeocode(place); err != nil {
+			return 0, err
+		}
+	}
+	if err := s.SetEventLocation(e.ID, place); err != nil {
+		return 0, err
+	}
+	if !place.Found {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// Clear implements Enricher.
+func (g *GeocodeStage) Clear(s *store.Store) error {
+	return s.ClearEventLocations()
+}
+
+// geocodeQuery normalizes a location for lookup, returning "" for
+// locations that aren't places: empty, links and meeting platforms.
+func geocodeQuery(location string) string {
+	q := strings.Join(strings.Fields(strings.ToLower(location)), " ")
+	if q == "" || strings.Contains(q, "://") || matchName(q) != "" {
+		return ""
+	}
+	return q
+}
@@ -0,0 +1,133 @@
+package enrich
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// Platforms the platform stage detects, besides the video platforms of
+// platformLinks.
+const (
+	OtherVideo = "other_video" // A conference of an unknown provider
+	InPerson   = "in_person"   // A physical location and no meeting link
+)
+
+// platformLinks match the join links of video meeting platforms.
+var platformLinks = []struct {
+	platform string
+	pattern  *regexp.Regexp
+}{
+	{"meet", regexp.MustCompile(`https?://meet\.google\.com/[\w-]+`)},
+	{"zoom", regexp.MustCompile(`https?://(?:[\w-]+\.)*zoom\.(?:us|com)/[^\s<>"']+`)},
+	{"teams", regexp.MustCompile(`https?://teams\.(?:microsoft|live)\.com/[^\s<>"']+`)},
+	{"webex", regexp.MustCompile(`https?://(?:[\w-]+\.)*webex\.com/[^\s<>"']+`)},
+	{"gotomeeting", regexp.MustCompile(`https?://(?:[\w-]+\.)*(?:gotomeeting\.com|gotomeet\.me|meet\.goto\.com)/[^\s<>"']+`)},
+	{"chime", regexp.MustCompile(`https?://chime\.aws/[^\s<>"']+`)},
+	{"jitsi", regexp.MustCompile(`https?://meet\.jit\.si/[^\s<>"']+`)},
+	{"whereby", regexp.MustCompile(`https?://(?:[\w-]+\.)*whereby\.com/[^\s<>"']+`)},
+	{"skype", regexp.MustCompile(`https?://join\.skype\.com/[^\s<>"']+`)},
+	{"slack", regexp.MustCompile(`https?://app\.slack\.com/huddle/[^\s<>"']+`)},
+}
+
+// platformNames match platforms named without a link, as in conference
+// solution names and the locations calendar clients fill in ("Microsoft
+// Teams Meeting").
+var platformNames = []struct {
+	platform string
+	pattern  *regexp.Regexp
+}{
+	{"meet", regexp.MustCompile(`(?i)\bgoogle meet\b|\bhangouts?\b`)},
+	{"zoom", regexp.MustCompile(`(?i)\bzoom\b`)},
+	{"teams", regexp.MustCompile(`(?i)\bmicrosoft teams\b|\bteams meeting\b`)},
+	{"webex", regexp.MustCompile(`(?i)\bwebex\b`)},
+	{"gotomeeting", regexp.MustCompile(`(?i)\bgoto ?meeting\b`)},
+	{"skype", regexp.MustCompile(`(?i)\bskype\b`)},
+}
+
+// PlatformStage is the enrichment stage detecting the meeting platform an
+// event is held on into event_platforms.
+type PlatformStage struct{}
+
+// Name implements Enricher.
+func (PlatformStage) Name() string { return "platform" }
+
+// Enrich implements Enricher.
+func (PlatformStage) Enrich(ctx context.Context, s *store.Store, e *store.Event) (int, error) {
+	conf, err := s.GetConference(e.ID)
+	if err != nil {
+		return 0, err
+	}
+	platform, url := DetectPlatform(e, conf)
+	if err := s.SetEventPlatform(e.ID, platform, url); err != nil {
+		return 0, err
+	}
+	if platform == "" {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// Clear implements Enricher.
+func (PlatformStage) Clear(s *store.Store) error {
+	return s.ClearEventPlatforms()
+}
+
+// DetectPlatform returns the platform an event is held on, and the link it
+// was detected from, or "" if it can't tell. The conference attached to the
+// event (conf may be nil) comes first, then links and names in its
+// location, then links in its description; an event with a location and
+// no meeting is in person.
+func DetectPlatform(e *store.Event, conf *store.Conference) (platform, url string) {
+	if conf != nil && !conf.IsEmpty() {
+		links := []string{conf.HangoutLink, conf.VideoURI}
+		for _, ep := range conf.EntryPoints {
+			links = append(links, ep.URI)
+		}
+		for _, link := range links {
+			if platform, url := matchLink(link); platform != "" {
+				return platform, url
+			}
+		}
+		if conf.SolutionType == "hangoutsMeet" || conf.HangoutLink != "" {
+			return "meet", conf.HangoutLink
+		}
+		if platform := matchName(conf.SolutionName); platform != "" {
+			return platform, conf.VideoURI
+		}
+		return OtherVideo, conf.VideoURI
+	}
+	if platform, url := matchLink(e.Location); platform != "" {
+		return platform, url
+	}
+	if platform := matchName(e.Location); platform != "" {
+		return platform, ""
+	}
+	if platform, url := matchLink(e.Description); platform != "" {
+		return platform, url
+	}
+	if location := strings.TrimSpace(e.Location); location != "" && !strings.Contains(location, "://") {
+		return InPerson, ""
+	}
+	return "", ""
+}
+
+func matchLink(text string) (platform, url string) {
+	for _, p := range platformLinks {
+		if m := p.pattern.FindString(text); m != "" {
+			return p.platform, m
+		}
+	}
+	return "", ""
+}
+
+func matchName(text string) string {
+	for _, p := range platformNames {
+		if p.pattern.MatchString(text) {
+			return p.platform
+		}
+	}
+	return ""
+}
@@ -0,0 +1,36 @@
+package enrich
+
+import (
+	"testing"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+func TestDetectPlatform(t *testing.T) {
+	tests := []struct {
+		name         string
+		event        *store.Event
+		conf         *store.Conference
+		wantPlatform string
+		wantURL      string
+	}{
+		{"meet conference", &store.Event{}, &store.Conference{SolutionType: "hangoutsMeet", HangoutLink: "https://meet.google.com/abc-defg-hij"}, "meet", "https://meet.google.com/abc-defg-hij"},
+		{"zoom add-on", &store.Event{}, &store.Conference{SolutionType: "addOn", SolutionName: "Zoom Meeting", ConferenceID: "123",
+			EntryPoints: []*store.ConferenceEntryPoint{{Type: "video", URI: "https://us02web.zoom.us/j/123"}}}, "zoom", "https://us02web.zoom.us/j/123"},
+		{"named add-on", &store.Event{}, &store.Conference{SolutionType: "addOn", SolutionName: "Webex", ConferenceID: "9"}, "webex", ""},
+		{"unknown add-on", &store.Event{}, &store.Conference{SolutionType: "addOn", SolutionName: "Acme Video", ConferenceID: "9"}, OtherVideo, ""},
+		{"teams link in location", &store.Event{Location: "https://teams.microsoft.com/l/meetup-join/19%3a"}, nil, "teams", "https://teams.microsoft.com/l/meetup-join/19%3a"},
+		{"teams by name", &store.Event{Location: "Microsoft Teams Meeting"}, nil, "teams", ""},
+		{"link in description", &store.Event{Description: "Join: <https://meet.jit.si/standup> thanks"}, nil, "jitsi", "https://meet.jit.si/standup"},
+		{"room", &store.Event{Location: "Room 4B", Description: "Agenda attached"}, nil, InPerson, ""},
+		{"room with zoom fallback", &store.Event{Location: "Room 4B", Description: "or https://zoom.us/j/55"}, nil, "zoom", "https://zoom.us/j/55"},
+		{"nothing", &store.Event{Summary: "Focus"}, nil, "", ""},
+		{"other link", &store.Event{Location: "https://example.com/room"}, nil, "", ""},
+	}
+	for _, tt := range tests {
+		platform, url := DetectPlatform(tt.event, tt.conf)
+		if platform != tt.wantPlatform || url != tt.wantURL {
+			t.Errorf("%s: DetectPlatform = %q, %q, want %q, %q", tt.name, platform, url, tt.wantPlatform, tt.wantURL)
+		}
+	}
+}
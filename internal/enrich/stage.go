@@ -0,0 +1,96 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// Enricher is a stage of the enrichment pipeline.
+type Enricher interface {
+	// Name identifies the stage in enrich_state.
+	Name() string
+
+	// Enrich derives the stage's data for an event and stores it in place
+	// of what the stage stored for the event before. It returns how many
+	// items - entities, tags - it stored.
+	Enrich(ctx context.Context, s *store.Store, e *store.Event) (int, error)
+
+	// Clear removes everything the stage stored.
+	Clear(s *store.Store) error
+}
+
+// Summary counts the work of a Run.
+type Summary struct {
+	Stage   string
+	Events  int   // Events processed
+	Found   int   // Items stored
+	Failed  int   // Events the stage failed on; retried next run
+	LastErr error // The last of those failures
+}
+
+// Progress is called with a stage's summary after each event it tries.
+type Progress func(*Summary)
+
+// Run enriches up to limit events (all if 0) the stage hasn't processed
+// since they were synced, calling progress (if not nil) after each. A
+// failure on one event is counted and left for the next run unless it is
+// the context's.
+func Run(ctx context.Context, s *store.Store, stage Enricher, limit int, progress Progress) (*Summary, error) {
+	events, err := s.EventsToEnrich(stage.Name(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{Stage: stage.Name()}
+	for _, e := range events {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+		n, err := stage.Enrich(ctx, s, e)
+		if err != nil {
+			if ctx.Err() != nil {
+				return summary, ctx.Err()
+			}
+			summary.Failed++
+			summary.LastErr = fmt.Errorf("event %s: %w", e.GoogleEventID, err)
+		} else {
+			if err := s.MarkEnriched(e.ID, stage.Name()); err != nil {
+				return summary, err
+			}
+			summary.Events++
+			summary.Found += n
+		}
+		if progress != nil {
+			progress(summary)
+		}
+	}
+	return summary, nil
+}
+
+// RunAll runs the stages in order, each over the events it hasn't
+// processed, and returns their summaries. It stops at the first stage that
+// returns an error.
+func RunAll(ctx context.Context, s *store.Store, stages []Enricher, limit int, progress Progress) ([]*Summary, error) {
+	var summaries []*Summary
+	for _, stage := range stages {
+		summary, err := Run(ctx, s, stage, limit, progress)
+		if summary != nil {
+			summaries = append(summaries, summary)
+		}
+		if err != nil {
+			return summaries, fmt.Errorf("%s: %w", stage.Name(), err)
+		}
+	}
+	return summaries, nil
+}
+
+// Rebuild discards what the stage stored and its state, so its next run
+// processes every event again.
+func Rebuild(s *store.Store, stage Enricher) error {
+	if err := stage.Clear(s); err != nil {
+		return err
+	}
+	return s.ClearEnrichState(stage.Name())
+}
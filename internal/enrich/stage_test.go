@@ -0,0 +1,125 @@
+package enrich
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+func TestRunAll(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	var lookups atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		if r.Header.Get("User-Agent") != "calvault-test" {
+			t.Errorf("User-Agent = %q", r.Header.Get("User-Agent"))
+		}
+		switch r.URL.Query().Get("q") {
+		case "10 main st, springfield":
+			fmt.Fprint(w, `[{"lat": "39.78", "lon": "-89.65", "display_name": "10 Main St"}]`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+	defer server.Close()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: "primary"})
+	upsert := func(id, summary, location string) {
+		t.Helper()
+		_, err := s.UpsertEvent(&store.Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: id, Summary: summary, Location: location,
+			StartTime: sql.NullTime{Time: time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC), Valid: true}})
+		if err != nil {
+			t.Fatalf("upsert %s: %v", id, err)
+		}
+	}
+	upsert("a", "Dentist", "10 Main St,  Springfield")
+	upsert("b", "Dentist follow-up", "10 main st, springfield")
+	upsert("c", "Standup", "https://zoom.us/j/123")
+	upsert("d", "Offsite", "Nowhere Lake")
+
+	stages := []Enricher{
+		PlatformStage{},
+		&TagStage{Rules: []TagRule{
+			{Tag: "health", Pattern: regexp.MustCompile(`(?i)dentist`)},
+			{Tag: "health", Pattern: regexp.MustCompile(`(?i)springfield`)},
+			{Tag: "team", Pattern: regexp.MustCompile(`Standup|Offsite`)},
+		}},
+		&GeocodeStage{Geocoder: &NominatimGeocoder{Endpoint: server.URL + "/search", UserAgent: "calvault-test"}},
+	}
+	summaries, err := RunAll(context.Background(), s, stages, 0, nil)
+	if err != nil {
+		t.Fatalf("run all: %v", err)
+	}
+	var got []string
+	for _, sum := range summaries {
+		got = append(got, fmt.Sprintf("%s:%d/%d/%d", sum.Stage, sum.Events, sum.Found, sum.Failed))
+	}
+	if want := "platform:4/4/0 tags:4/4/0 geocode:4/2/0"; strings.Join(got, " ") != want {
+		t.Errorf("summaries = %v, want %s", got, want)
+	}
+	// The two spellings of Main St are one lookup; the Zoom link none
+	if n := lookups.Load(); n != 2 {
+		t.Errorf("geocoder called %d times, want 2", n)
+	}
+
+	rows, err := s.DB().Query(`
+		SELECT e.google_event_id, COALESCE(p.platform, ''),
+		       COALESCE((SELECT group_concat(tag, '+') FROM event_tags t WHERE t.event_id = e.id), ''),
+		       COALESCE(l.latitude, 0)
+		FROM events e
+		LEFT JOIN event_platforms p ON p.event_id = e.id
+		LEFT JOIN event_locations l ON l.event_id = e.id
+		ORDER BY e.google_event_id
+	`)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	got = nil
+	for rows.Next() {
+		var id, platform, tags string
+		var lat float64
+		if err := rows.Scan(&id, &platform, &tags, &lat); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		got = append(got, fmt.Sprintf("%s:%s:%s:%g", id, platform, tags, lat))
+	}
+	_ = rows.Close()
+	if want := "a:in_person:health:39.78 b:in_person:health:39.78 c:zoom:team:0 d:in_person:team:0"; strings.Join(got, " ") != want {
+		t.Errorf("rows = %v, want %s", got, want)
+	}
+
+	// Nothing is processed again until an event is synced again, and a
+	// rebuild starts the stage over
+	summaries, _ = RunAll(context.Background(), s, stages, 0, nil)
+	for _, sum := range summaries {
+		if sum.Events != 0 {
+			t.Errorf("second run of %s processed %d events, want 0", sum.Stage, sum.Events)
+		}
+	}
+	if err := Rebuild(s, stages[1]); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	sum, err := Run(context.Background(), s, stages[1], 0, nil)
+	if err != nil || sum.Events != 4 {
+		t.Errorf("run after rebuild = %+v, %v, want 4 events", sum, err)
+	}
+}
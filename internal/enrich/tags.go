@@ -0,0 +1,51 @@
+package enrich
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// TagRule tags the events whose title, description or location matches
+// Pattern.
+type TagRule struct {
+	Tag     string
+	Pattern *regexp.Regexp
+}
+
+// TagStage is the enrichment stage tagging events by rules into
+// event_tags.
+type TagStage struct {
+	Rules []TagRule
+}
+
+// Name implements Enricher.
+func (t *TagStage) Name() string { return "tags" }
+
+// Enrich implements Enricher.
+func (t *TagStage) Enrich(ctx context.Context, s *store.Store, e *store.Event) (int, error) {
+	var tags []string
+	seen := map[string]bool{}
+	for _, r := range t.Rules {
+		if seen[r.Tag] {
+			continue
+		}
+		for _, text := range []string{e.Summary, e.Description, e.Location} {
+			if r.Pattern.MatchString(text) {
+				tags = append(tags, r.Tag)
+				seen[r.Tag] = true
+				break
+			}
+		}
+	}
+	if err := s.SetEventTags(e.ID, tags); err != nil {
+		return 0, err
+	}
+	return len(tags), nil
+}
+
+// Clear implements Enricher.
+func (t *TagStage) Clear(s *store.Store) error {
+	return s.ClearEventTags()
+}
@@ -0,0 +1,274 @@
+// Package graph provides a Microsoft Graph calendar client with rate limiting.
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/salman1993/calvault/internal/calendar"
+	"github.com/salman1993/calvault/internal/metrics"
+	"golang.org/x/oauth2"
+)
+
+// DefaultBaseURL is the Microsoft Graph v1.0 endpoint.
+const DefaultBaseURL = "https://graph.microsoft.com/v1.0"
+
+// maxRetries bounds retries of throttled (429) and unavailable (503) requests.
+const maxRetries = 3
+
+// ErrDeltaExpired indicates a stored delta link can no longer be used and a
+// full sync is required.
+var ErrDeltaExpired = errors.New("delta token expired")
+
+// APIError is an error response from Microsoft Graph.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("graph api: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Client wraps the Microsoft Graph calendar API with rate limiting.
+type Client struct {
+	http        *http.Client
+	baseURL     string
+	rateLimiter *calendar.RateLimiter
+	logger      *slog.Logger
+}
+
+// ClientOption configures the client.
+type ClientOption func(*Client)
+
+// WithLogger sets the logger.
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithRateLimiter sets a custom rate limiter.
+func WithRateLimiter(rl *calendar.RateLimiter) ClientOption {
+	return func(c *Client) {
+		c.rateLimiter = rl
+	}
+}
+
+// WithBaseURL overrides the Graph endpoint (used by tests).
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		c.baseURL = baseURL
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for requests. It must add
+// authorization itself; the token source passed to NewClient is ignored.
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// NewClient creates a new Graph API client.
+func NewClient(ctx context.Context, tokenSource oauth2.TokenSource, opts ...ClientOption) *Client {
+	c := &Client{
+		http:        oauth2.NewClient(ctx, tokenSource),
+		baseURL:     DefaultBaseURL,
+		rateLimiter: calendar.NewRateLimiter(10, 0), // Default 10 QPS
+		logger:      slog.Default(),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	// Count requests without changing a client passed in by WithHTTPClient
+	hc := *c.http
+	hc.Transport = metrics.Transport("microsoft", hc.Transport)
+	c.http = &hc
+
+	return c
+}
+
+// CalendarEntry represents a calendar from /me/calendars.
+type CalendarEntry struct {
+	ID        string
+	Name      string
+	Owner     string
+	IsDefault bool
+	CanEdit   bool
+}
+
+// ListCalendars returns all calendars for the authenticated user.
+func (c *Client) ListCalendars(ctx context.Context) ([]*CalendarEntry, error) {
+	var calendars []*CalendarEntry
+	next := c.baseURL + "/me/calendars?$top=100"
+
+	for next != "" {
+		var page struct {
+			Value []struct {
+				ID                string `json:"id"`
+				Name              string `json:"name"`
+				IsDefaultCalendar bool   `json:"isDefaultCalendar"`
+				CanEdit           bool   `json:"canEdit"`
+				Owner             struct {
+					Address string `json:"address"`
+				} `json:"owner"`
+			} `json:"value"`
+			NextLink string `json:"@odata.nextLink"`
+		}
+		if err := c.get(ctx, next, &page); err != nil {
+			return nil, fmt.Errorf("list calendars: %w", err)
+		}
+
+		for _, entry := range page.Value {
+			calendars = append(calendars, &CalendarEntry{
+				ID:        entry.ID,
+				Name:      entry.Name,
+				Owner:     entry.Owner.Address,
+				IsDefault: entry.IsDefaultCalendar,
+				CanEdit:   entry.CanEdit,
+			})
+		}
+		next = page.NextLink
+	}
+
+	return calendars, nil
+}
+
+// EventsPage represents a page of a calendar view delta query.
+type EventsPage struct {
+	Events    []*Event
+	NextLink  string // Fetch this for the next page
+	DeltaLink string // Set on the last page; store for the next incremental sync
+}
+
+// CalendarViewDelta starts a delta query over a calendar's view between
+// start and end. Recurring series are expanded into their occurrences.
+func (c *Client) CalendarViewDelta(ctx context.Context, calendarID string, start, end time.Time) (*EventsPage, error) {
+	query := url.Values{}
+	query.Set("startDateTime", start.UTC().Format(time.RFC3339))
+	query.Set("endDateTime", end.UTC().Format(time.RFC3339))
+	link := fmt.Sprintf("%s/me/calendars/%s/calendarView/delta?%s",
+		c.baseURL, url.PathEscape(calendarID), query.Encode())
+	return c.EventsPage(ctx, link)
+}
+
+// EventsPage fetches a delta page from a next or delta link.
+func (c *Client) EventsPage(ctx context.Context, link string) (*EventsPage, error) {
+	var page struct {
+		Value     []*Event `json:"value"`
+		NextLink  string   `json:"@odata.nextLink"`
+		DeltaLink string   `json:"@odata.deltaLink"`
+	}
+	if err := c.get(ctx, link, &page); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && isExpiredDelta(apiErr) {
+			return nil, ErrDeltaExpired
+		}
+		return nil, fmt.Errorf("list events: %w", err)
+	}
+
+	return &EventsPage{
+		Events:    page.Value,
+		NextLink:  page.NextLink,
+		DeltaLink: page.DeltaLink,
+	}, nil
+}
+
+// isExpiredDelta reports whether an error means the delta state is gone.
+func isExpiredDelta(err *APIError) bool {
+	switch {
+	case err.StatusCode == http.StatusGone:
+		return true
+	case err.Code == "SyncStateNotFound", err.Code == "SyncStateInvalid", err.Code == "resyncRequired":
+		return true
+	default:
+		return false
+	}
+}
+
+// get issues a rate-limited GET and decodes the JSON response into v,
+// retrying throttled requests after the server-provided delay.
+func (c *Client) get(ctx context.Context, link string, v interface{}) error {
+	for attempt := 0; ; attempt++ {
+		if err := c.rateLimiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+		if err != nil {
+			return fmt.Errorf("create request: %w", err)
+		}
+		// Return times in UTC and plain-text bodies
+		req.Header.Add("Prefer", `outlook.timezone="UTC"`)
+		req.Header.Add("Prefer", `outlook.body-content-type="text"`)
+		req.Header.Add("Prefer", "odata.maxpagesize=200")
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			err := json.NewDecoder(resp.Body).Decode(v)
+			_ = resp.Body.Close()
+			if err != nil {
+				return fmt.Errorf("decode response: %w", err)
+			}
+			return nil
+		}
+
+		apiErr := readAPIError(resp)
+		_ = resp.Body.Close()
+
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+		if !retryable || attempt >= maxRetries {
+			return apiErr
+		}
+
+		wait := retryAfter(resp.Header.Get("Retry-After"), attempt)
+		c.logger.Debug("graph request throttled", "status", resp.StatusCode, "retry_after", wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// readAPIError parses a Graph error response body.
+func readAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: resp.Status}
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if json.Unmarshal(data, &body) == nil && body.Error.Code != "" {
+		apiErr.Code = body.Error.Code
+		apiErr.Message = body.Error.Message
+	}
+	return apiErr
+}
+
+// retryAfter returns the delay from a Retry-After header (in seconds),
+// falling back to exponential backoff.
+func retryAfter(header string, attempt int) time.Duration {
+	if secs, err := strconv.Atoi(header); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return time.Duration(1<<attempt) * time.Second
+}
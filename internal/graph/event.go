@@ -0,0 +1,215 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Event is a Microsoft Graph event resource, limited to the fields calvault stores.
+type Event struct {
+	ID                    string               `json:"id"`
+	Subject               string               `json:"subject"`
+	BodyPreview           string               `json:"bodyPreview"`
+	Body                  *ItemBody            `json:"body"`
+	Location              *Location            `json:"location"`
+	Start                 *DateTimeTimeZone    `json:"start"`
+	End                   *DateTimeTimeZone    `json:"end"`
+	IsAllDay              bool                 `json:"isAllDay"`
+	IsCancelled           bool                 `json:"isCancelled"`
+	OriginalStartTimeZone string               `json:"originalStartTimeZone"`
+	OriginalEndTimeZone   string               `json:"originalEndTimeZone"`
+	SeriesMasterID        string               `json:"seriesMasterId"`
+	Type                  string               `json:"type"` // singleInstance, occurrence, exception, seriesMaster
+	Recurrence            *PatternedRecurrence `json:"recurrence"`
+	Sensitivity           string               `json:"sensitivity"` // normal, personal, private, confidential
+	Organizer             *Recipient           `json:"organizer"`
+	Attendees             []*Attendee          `json:"attendees"`
+	CreatedDateTime       string               `json:"createdDateTime"`
+	LastModifiedDateTime  string               `json:"lastModifiedDateTime"`
+
+	// Removed is set on delta results for events that were deleted or fell
+	// out of the calendar view window.
+	Removed *struct {
+		Reason string `json:"reason"`
+	} `json:"@removed"`
+}
+
+// ItemBody is an event body.
+type ItemBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+// Location is an event location.
+type Location struct {
+	DisplayName string `json:"displayName"`
+}
+
+// DateTimeTimeZone is a local date-time with its time zone name.
+type DateTimeTimeZone struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+// EmailAddress is a named email address.
+type EmailAddress struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// Recipient wraps an email address.
+type Recipient struct {
+	EmailAddress EmailAddress `json:"emailAddress"`
+}
+
+// Attendee is an event attendee with their response.
+type Attendee struct {
+	EmailAddress EmailAddress `json:"emailAddress"`
+	Type         string       `json:"type"` // required, optional, resource
+	Status       struct {
+		Response string `json:"response"`
+	} `json:"status"`
+}
+
+// PatternedRecurrence describes a recurring series.
+type PatternedRecurrence struct {
+	Pattern struct {
+		Type           string   `json:"type"`
+		Interval       int      `json:"interval"`
+		Month          int      `json:"month"`
+		DayOfMonth     int      `json:"dayOfMonth"`
+		DaysOfWeek     []string `json:"daysOfWeek"`
+		FirstDayOfWeek string   `json:"firstDayOfWeek"`
+		Index          string   `json:"index"`
+	} `json:"pattern"`
+	Range struct {
+		Type                string `json:"type"` // endDate, noEnd, numbered
+		EndDate             string `json:"endDate"`
+		NumberOfOccurrences int    `json:"numberOfOccurrences"`
+	} `json:"range"`
+}
+
+// Text returns the event body as plain text, falling back to the preview.
+func (e *Event) Text() string {
+	if e.Body != nil && strings.EqualFold(e.Body.ContentType, "text") {
+		return e.Body.Content
+	}
+	return e.BodyPreview
+}
+
+// ParseDateTime parses a Graph date-time. Requests ask for UTC, so values
+// without a recognized zone are treated as UTC.
+func ParseDateTime(dt *DateTimeTimeZone) (time.Time, error) {
+	if dt == nil || dt.DateTime == "" {
+		return time.Time{}, fmt.Errorf("missing date-time")
+	}
+	loc := time.UTC
+	if dt.TimeZone != "" && !strings.EqualFold(dt.TimeZone, "UTC") {
+		if l, err := time.LoadLocation(dt.TimeZone); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04:05.9999999", dt.DateTime, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse date-time %q: %w", dt.DateTime, err)
+	}
+	return t.UTC(), nil
+}
+
+// ResponseStatus maps a Graph response to the Google Calendar vocabulary
+// used in the attendees table.
+func ResponseStatus(response string) string {
+	switch response {
+	case "accepted", "organizer":
+		return "accepted"
+	case "tentativelyAccepted":
+		return "tentative"
+	case "declined":
+		return "declined"
+	default:
+		return "needsAction"
+	}
+}
+
+// Visibility maps Graph sensitivity to Google Calendar visibility.
+func Visibility(sensitivity string) string {
+	switch sensitivity {
+	case "private", "personal":
+		return "private"
+	case "confidential":
+		return "confidential"
+	default:
+		return "default"
+	}
+}
+
+// weekdays maps Graph day names to RRULE BYDAY codes.
+var weekdays = map[string]string{
+	"sunday": "SU", "monday": "MO", "tuesday": "TU", "wednesday": "WE",
+	"thursday": "TH", "friday": "FR", "saturday": "SA",
+}
+
+// weekIndexes maps Graph week indexes to RRULE BYDAY ordinals.
+var weekIndexes = map[string]string{
+	"first": "1", "second": "2", "third": "3", "fourth": "4", "last": "-1",
+}
+
+// RecurrenceRule converts a patterned recurrence to an RFC 5545 RRULE line.
+// It returns "" for patterns it doesn't recognize.
+func RecurrenceRule(r *PatternedRecurrence) string {
+	if r == nil {
+		return ""
+	}
+	p := r.Pattern
+
+	var parts []string
+	byDay := func(prefix string) {
+		var days []string
+		for _, d := range p.DaysOfWeek {
+			if code, ok := weekdays[strings.ToLower(d)]; ok {
+				days = append(days, prefix+code)
+			}
+		}
+		if len(days) > 0 {
+			parts = append(parts, "BYDAY="+strings.Join(days, ","))
+		}
+	}
+
+	switch p.Type {
+	case "daily":
+		parts = append(parts, "FREQ=DAILY")
+	case "weekly":
+		parts = append(parts, "FREQ=WEEKLY")
+		byDay("")
+	case "absoluteMonthly":
+		parts = append(parts, "FREQ=MONTHLY", fmt.Sprintf("BYMONTHDAY=%d", p.DayOfMonth))
+	case "relativeMonthly":
+		parts = append(parts, "FREQ=MONTHLY")
+		byDay(weekIndexes[p.Index])
+	case "absoluteYearly":
+		parts = append(parts, "FREQ=YEARLY", fmt.Sprintf("BYMONTH=%d", p.Month), fmt.Sprintf("BYMONTHDAY=%d", p.DayOfMonth))
+	case "relativeYearly":
+		parts = append(parts, "FREQ=YEARLY", fmt.Sprintf("BYMONTH=%d", p.Month))
+		byDay(weekIndexes[p.Index])
+	default:
+		return ""
+	}
+
+	if p.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", p.Interval))
+	}
+
+	switch r.Range.Type {
+	case "endDate":
+		if end, err := time.Parse("2006-01-02", r.Range.EndDate); err == nil {
+			parts = append(parts, "UNTIL="+end.Format("20060102"))
+		}
+	case "numbered":
+		if r.Range.NumberOfOccurrences > 0 {
+			parts = append(parts, fmt.Sprintf("COUNT=%d", r.Range.NumberOfOccurrences))
+		}
+	}
+
+	return "RRULE:" + strings.Join(parts, ";")
+}
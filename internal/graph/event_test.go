@@ -0,0 +1,31 @@
+package graph
+
+import "testing"
+
+func TestRecurrenceRule(t *testing.T) {
+	tests := []struct {
+		pattern string
+		days    []string
+		index   string
+		rng     string
+		want    string
+	}{
+		{"weekly", []string{"monday", "wednesday"}, "", "noEnd", "RRULE:FREQ=WEEKLY;BYDAY=MO,WE;INTERVAL=2"},
+		{"relativeMonthly", []string{"friday"}, "last", "numbered", "RRULE:FREQ=MONTHLY;BYDAY=-1FR;INTERVAL=2;COUNT=5"},
+		{"daily", nil, "", "endDate", "RRULE:FREQ=DAILY;INTERVAL=2;UNTIL=20241231"},
+		{"unknown", nil, "", "noEnd", ""},
+	}
+	for _, tt := range tests {
+		r := &PatternedRecurrence{}
+		r.Pattern.Type = tt.pattern
+		r.Pattern.Interval = 2
+		r.Pattern.DaysOfWeek = tt.days
+		r.Pattern.Index = tt.index
+		r.Range.Type = tt.rng
+		r.Range.EndDate = "2024-12-31"
+		r.Range.NumberOfOccurrences = 5
+		if got := RecurrenceRule(r); got != tt.want {
+			t.Errorf("RecurrenceRule(%s) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package ics
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// ExportOptions configures an export.
+type ExportOptions struct {
+	Filter   store.EventFilter
+	Name     string // X-WR-CALNAME
+	Timezone string // X-WR-TIMEZONE
+}
+
+// Export writes stored events matching opts.Filter as an iCalendar stream
+// and returns the number of events written.
+func Export(s *store.Store, w io.Writer, opts ExportOptions) (int, error) {
+	events, err := s.ListEvents(opts.Filter)
+	if err != nil {
+		return 0, err
+	}
+
+	cal := &Calendar{Name: opts.Name, Timezone: opts.Timezone}
+	for _, e := range events {
+		attendees, err := s.ListAttendees(e.ID)
+		if err != nil {
+			return 0, err
+		}
+		reminders, err := s.ListReminders(e.ID)
+		if err != nil {
+			return 0, err
+		}
+		cal.Events = append(cal.Events, convertEvent(e, attendees, reminders))
+	}
+
+	if err := Write(w, cal, time.Now()); err != nil {
+		return 0, err
+	}
+	return len(cal.Events), nil
+}
+
+// convertEvent maps a stored event, its attendees and reminders to a VEVENT.
+func convertEvent(e *store.Event, attendees []*store.Attendee, reminders []*store.Reminder) *Event {
+	ev := &Event{
+		UID:         e.GoogleEventID,
+		Summary:     e.Summary,
+		Description: e.Description,
+		Location:    e.Location,
+		AllDay:      e.AllDay,
+		TZID:        e.OriginalTimezone,
+		Status:      e.Status,
+		Class:       e.Visibility,
+	}
+	if e.StartTime.Valid {
+		ev.Start = e.StartTime.Time
+	}
+	if e.EndTime.Valid {
+		ev.End = e.EndTime.Time
+	}
+	if e.CreatedAt.Valid {
+		ev.Created = e.CreatedAt.Time
+	}
+	if e.UpdatedAt.Valid {
+		ev.LastModified = e.UpdatedAt.Time
+	}
+	if e.RecurrenceRule != "" {
+		ev.Recurrence = strings.Split(e.RecurrenceRule, "\n")
+	}
+
+	// Modified instances share the series UID. Both Google and imported
+	// instance IDs are "<series id>_<original start>".
+	if e.RecurringEventID != "" {
+		ev.UID = e.RecurringEventID
+		if suffix, ok := strings.CutPrefix(e.GoogleEventID, e.RecurringEventID+"_"); ok && suffix != "" {
+			ev.RecurrenceID = suffix
+		} else if e.StartTime.Valid {
+			ev.RecurrenceID = formatUTC(e.StartTime.Time)
+		}
+	}
+
+	if e.OrganizerEmail != "" {
+		ev.Organizer = &Person{Email: e.OrganizerEmail, Name: e.OrganizerName}
+	}
+	for _, a := range attendees {
+		ev.Attendees = append(ev.Attendees, &Attendee{
+			Person:   Person{Email: a.Email, Name: a.DisplayName},
+			PartStat: partStat(a.ResponseStatus),
+		})
+	}
+	ev.Alarms = convertReminders(reminders, attendees)
+
+	return ev
+}
+
+// convertReminders maps reminders to VALARMs. Email reminders go to the
+// user's own address; without a known one they become display alarms.
+func convertReminders(reminders []*store.Reminder, attendees []*store.Attendee) []*Alarm {
+	self := ""
+	for _, a := range attendees {
+		if a.IsSelf {
+			self = a.Email
+		}
+	}
+	var alarms []*Alarm
+	for _, r := range reminders {
+		alarm := &Alarm{Action: "DISPLAY", Minutes: r.Minutes}
+		if r.Method == "email" && self != "" {
+			alarm.Action = "EMAIL"
+			alarm.Email = self
+		}
+		alarms = append(alarms, alarm)
+	}
+	return alarms
+}
+
+// partStat converts a Google response status to an iCalendar PARTSTAT.
+func partStat(responseStatus string) string {
+	switch responseStatus {
+	case "accepted":
+		return "ACCEPTED"
+	case "declined":
+		return "DECLINED"
+	case "tentative":
+		return "TENTATIVE"
+	case "needsAction", "":
+		return "NEEDS-ACTION"
+	default:
+		return strings.ToUpper(responseStatus)
+	}
+}
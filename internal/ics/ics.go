@@ -0,0 +1,507 @@
+// Package ics provides a minimal iCalendar (RFC 5545) parser for importing
+// calendars exported from other applications.
+package ics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Calendar is a parsed VCALENDAR.
+type Calendar struct {
+	Name     string // X-WR-CALNAME
+	Timezone string // X-WR-TIMEZONE
+	Events   []*Event
+	Warnings []string // Such as TZIDs nothing in the file resolves
+}
+
+// Event is a parsed VEVENT.
+type Event struct {
+	UID          string
+	Summary      string
+	Description  string
+	Location     string
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	TZID         string
+	RecurrenceID string   // Raw RECURRENCE-ID value for modified instances
+	Recurrence   []string // RRULE, RDATE, EXRULE, and EXDATE lines
+	Status       string   // Lowercased, e.g. confirmed, tentative, cancelled
+	Class        string   // Lowercased, e.g. public, private
+	Organizer    *Person
+	Attendees    []*Attendee
+	Created      time.Time
+	LastModified time.Time
+	Alarms       []*Alarm // Written on export; not parsed
+
+	// DTSTART, DTEND and DURATION, read into Start and End once the whole
+	// calendar, with its VTIMEZONEs, has been read
+	dtStart, dtEnd *property
+	duration       *time.Duration
+}
+
+// Alarm is a VALARM reminding of an event some minutes before it starts.
+type Alarm struct {
+	Action  string // DISPLAY or EMAIL
+	Minutes int
+	Email   string // Recipient of an EMAIL alarm
+}
+
+// Person is a calendar user address with an optional display name.
+type Person struct {
+	Email string
+	Name  string
+}
+
+// Attendee is a parsed ATTENDEE property.
+type Attendee struct {
+	Person
+	PartStat string // Raw PARTSTAT, e.g. ACCEPTED
+	Role     string // Raw ROLE, e.g. REQ-PARTICIPANT
+}
+
+// property is a single content line: NAME;PARAM=VALUE:value.
+type property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Parse reads an iCalendar stream and returns its events. VTIMEZONEs
+// define the TZIDs that aren't IANA or Windows zone names; other
+// components (VALARM, VTODO, ...) are skipped.
+func Parse(r io.Reader) (*Calendar, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cal := &Calendar{}
+	var current *Event
+	var stack []string
+	zones := map[string]*vtimezone{}
+	var zone *vtimezone
+	var obs *observance
+
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		prop, err := parseProperty(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		switch prop.Name {
+		case "BEGIN":
+			component := strings.ToUpper(prop.Value)
+			stack = append(stack, component)
+			parent := ""
+			if len(stack) >= 2 {
+				parent = stack[len(stack)-2]
+			}
+			switch {
+			case component == "VEVENT" && parent == "VCALENDAR":
+				current = &Event{}
+			case component == "VTIMEZONE" && parent == "VCALENDAR":
+				zone = &vtimezone{}
+			case (component == "STANDARD" || component == "DAYLIGHT") && parent == "VTIMEZONE":
+				obs = &observance{}
+			}
+			continue
+		case "END":
+			component := strings.ToUpper(prop.Value)
+			if len(stack) == 0 || stack[len(stack)-1] != component {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", i+1, prop.Value)
+			}
+			stack = stack[:len(stack)-1]
+			if component == "VEVENT" && current != nil && len(stack) == 1 {
+				if current.UID == "" {
+					return nil, fmt.Errorf("line %d: VEVENT without UID", i+1)
+				}
+				cal.Events = append(cal.Events, current)
+				current = nil
+			}
+			if component == "VTIMEZONE" && zone != nil && len(stack) == 1 {
+				zones[zone.tzid] = zone
+				zone = nil
+			}
+			if (component == "STANDARD" || component == "DAYLIGHT") && obs != nil && zone != nil {
+				if len(obs.recurrence) > 0 {
+					if obs.onsets, err = ParseRecurrence(strings.Join(obs.recurrence, "\n")); err != nil {
+						return nil, fmt.Errorf("line %d: %s: %w", i+1, component, err)
+					}
+				}
+				zone.observances = append(zone.observances, obs)
+				obs = nil
+			}
+			continue
+		}
+
+		if len(stack) == 0 {
+			return nil, fmt.Errorf("line %d: property %s outside of VCALENDAR", i+1, prop.Name)
+		}
+
+		switch stack[len(stack)-1] {
+		case "VCALENDAR":
+			switch prop.Name {
+			case "X-WR-CALNAME":
+				cal.Name = unescapeText(prop.Value)
+			case "X-WR-TIMEZONE":
+				cal.Timezone = prop.Value
+			}
+		case "VEVENT":
+			if current != nil {
+				if err := current.apply(prop); err != nil {
+					return nil, fmt.Errorf("line %d: %w", i+1, err)
+				}
+			}
+		case "VTIMEZONE":
+			if zone != nil && prop.Name == "TZID" {
+				zone.tzid = prop.Value
+			}
+		case "STANDARD", "DAYLIGHT":
+			if obs != nil {
+				if err := obs.apply(prop); err != nil {
+					return nil, fmt.Errorf("line %d: %w", i+1, err)
+				}
+			}
+		}
+	}
+
+	if len(stack) != 0 {
+		return nil, fmt.Errorf("unterminated %s component", stack[len(stack)-1])
+	}
+
+	// Times in unresolvable zones are read in the calendar's zone, or UTC
+	fallback := time.UTC
+	if loc, _ := loadLocation(cal.Timezone); loc != nil {
+		fallback = loc
+	}
+	unknown := map[string]bool{}
+	for _, e := range cal.Events {
+		if err := e.resolveTimes(zones, fallback, unknown); err != nil {
+			return nil, fmt.Errorf("event %s: %w", e.UID, err)
+		}
+	}
+	for _, tzid := range sortedKeys(unknown) {
+		cal.Warnings = append(cal.Warnings, fmt.Sprintf("unknown time zone %q: its times were read as %s", tzid, fallback))
+	}
+
+	return cal, nil
+}
+
+// resolveTimes sets Start, End and AllDay from DTSTART, DTEND and DURATION,
+// in zones or as IANA or Windows zone names. Times in other zones are read
+// in fallback, and their TZIDs added to unknown.
+func (e *Event) resolveTimes(zones map[string]*vtimezone, fallback *time.Location, unknown map[string]bool) error {
+	read := func(prop *property) (time.Time, bool, error) {
+		t, allDay, err := parseDateTime(*prop, zones)
+		if errors.Is(err, errUnknownZone) {
+			unknown[prop.Params["TZID"]] = true
+			y, m, d := t.Date()
+			hour, min, sec := t.Clock()
+			return time.Date(y, m, d, hour, min, sec, 0, fallback), allDay, nil
+		}
+		if err != nil {
+			return t, allDay, fmt.Errorf("parse %s: %w", prop.Name, err)
+		}
+		return t, allDay, nil
+	}
+
+	var err error
+	if e.dtStart != nil {
+		if e.Start, e.AllDay, err = read(e.dtStart); err != nil {
+			return err
+		}
+		e.TZID = e.dtStart.Params["TZID"]
+		if _, name := loadLocation(e.TZID); name != "" {
+			e.TZID = name
+		}
+	}
+	switch {
+	case e.dtEnd != nil:
+		if e.End, _, err = read(e.dtEnd); err != nil {
+			return err
+		}
+	case e.duration != nil && !e.Start.IsZero():
+		e.End = e.Start.Add(*e.duration)
+	}
+	return nil
+}
+
+// apply sets the event field corresponding to prop.
+func (e *Event) apply(prop property) error {
+	var err error
+	switch prop.Name {
+	case "UID":
+		e.UID = prop.Value
+	case "SUMMARY":
+		e.Summary = unescapeText(prop.Value)
+	case "DESCRIPTION":
+		e.Description = unescapeText(prop.Value)
+	case "LOCATION":
+		e.Location = unescapeText(prop.Value)
+	case "STATUS":
+		e.Status = strings.ToLower(prop.Value)
+	case "CLASS":
+		e.Class = strings.ToLower(prop.Value)
+	case "DTSTART":
+		e.dtStart = &prop
+	case "DTEND":
+		e.dtEnd = &prop
+	case "DURATION":
+		var d time.Duration
+		if d, err = parseDuration(prop.Value); err == nil {
+			e.duration = &d
+		}
+	case "RECURRENCE-ID":
+		e.RecurrenceID = prop.Value
+	case "RRULE", "RDATE", "EXRULE", "EXDATE":
+		e.Recurrence = append(e.Recurrence, prop.String())
+	case "ORGANIZER":
+		e.Organizer = &Person{
+			Email: mailtoAddress(prop.Value),
+			Name:  prop.Params["CN"],
+		}
+	case "ATTENDEE":
+		e.Attendees = append(e.Attendees, &Attendee{
+			Person: Person{
+				Email: mailtoAddress(prop.Value),
+				Name:  prop.Params["CN"],
+			},
+			PartStat: prop.Params["PARTSTAT"],
+			Role:     prop.Params["ROLE"],
+		})
+	case "CREATED":
+		e.Created, err = parseTimestamp(prop)
+	case "LAST-MODIFIED":
+		e.LastModified, err = parseTimestamp(prop)
+	}
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", prop.Name, err)
+	}
+	return nil
+}
+
+// String reassembles the property as a content line (without folding).
+func (p property) String() string {
+	keys := make([]string, 0, len(p.Params))
+	for k := range p.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(p.Name)
+	for _, k := range keys {
+		b.WriteString(";")
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(p.Params[k])
+	}
+	b.WriteString(":")
+	b.WriteString(p.Value)
+	return b.String()
+}
+
+// unfold reads content lines, joining folded continuation lines.
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read ics: %w", err)
+	}
+	return lines, nil
+}
+
+// parseProperty splits a content line into name, parameters, and value.
+func parseProperty(line string) (property, error) {
+	prop := property{Params: map[string]string{}}
+
+	// Find the name/params section, respecting quoted parameter values.
+	inQuotes := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			inQuotes = !inQuotes
+		} else if c == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return prop, fmt.Errorf("malformed content line %q", line)
+	}
+	prop.Value = line[colon+1:]
+
+	parts := splitParams(line[:colon])
+	prop.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		k, v, ok := strings.Cut(param, "=")
+		if !ok {
+			continue
+		}
+		prop.Params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+
+	return prop, nil
+}
+
+// splitParams splits on semicolons that are not inside quotes.
+func splitParams(s string) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i, c := range s {
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == ';' && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// parseDateTime parses a DATE or DATE-TIME value, honoring TZID: an IANA
+// or Windows zone name, or one of zones, the calendar's VTIMEZONEs. It
+// reports whether the value was a DATE (all-day). A TZID none of those
+// resolve reads as UTC, with an error wrapping errUnknownZone.
+func parseDateTime(prop property, zones map[string]*vtimezone) (time.Time, bool, error) {
+	value := prop.Value
+	if prop.Params["VALUE"] == "DATE" || (len(value) == 8 && !strings.Contains(value, "T")) {
+		t, err := time.Parse("20060102", value)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+
+	tzid := prop.Params["TZID"]
+	if loc, _ := loadLocation(tzid); loc != nil {
+		t, err := time.ParseInLocation("20060102T150405", value, loc)
+		return t, false, err
+	}
+	t, err := time.Parse("20060102T150405", value)
+	if err != nil || tzid == "" {
+		return t, false, err
+	}
+	if zone := zones[tzid]; zone != nil {
+		return zone.in(t), false, nil
+	}
+	return t, false, fmt.Errorf("%w %q", errUnknownZone, tzid)
+}
+
+// parseTimestamp parses a DATE-TIME that RFC 5545 requires in UTC, such as
+// CREATED, reading one with a TZID nothing resolves as UTC.
+func parseTimestamp(prop property) (time.Time, error) {
+	t, _, err := parseDateTime(prop, nil)
+	if errors.Is(err, errUnknownZone) {
+		return t, nil
+	}
+	return t, err
+}
+
+// parseDuration parses an RFC 5545 duration such as P1D, PT1H30M, or -PT15M.
+func parseDuration(value string) (time.Duration, error) {
+	s := value
+	sign := time.Duration(1)
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+		s = s[1:]
+	} else {
+		s = strings.TrimPrefix(s, "+")
+	}
+	if !strings.HasPrefix(s, "P") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	s = s[1:]
+
+	var d time.Duration
+	inTime := false
+	num := ""
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			num += string(c)
+		case c == 'T':
+			inTime = true
+		default:
+			n, err := strconv.Atoi(num)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			num = ""
+			switch {
+			case c == 'W':
+				d += time.Duration(n) * 7 * 24 * time.Hour
+			case c == 'D':
+				d += time.Duration(n) * 24 * time.Hour
+			case c == 'H' && inTime:
+				d += time.Duration(n) * time.Hour
+			case c == 'M' && inTime:
+				d += time.Duration(n) * time.Minute
+			case c == 'S' && inTime:
+				d += time.Duration(n) * time.Second
+			default:
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+		}
+	}
+	if num != "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	return sign * d, nil
+}
+
+// mailtoAddress strips a mailto: prefix from a calendar user address.
+func mailtoAddress(value string) string {
+	if len(value) >= 7 && strings.EqualFold(value[:7], "mailto:") {
+		return value[7:]
+	}
+	return value
+}
+
+// unescapeText reverses RFC 5545 TEXT escaping.
+func unescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,564 @@
+package ics
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+const sampleICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"X-WR-CALNAME:Home\r\n" +
+	"X-WR-TIMEZONE:America/New_York\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:America/New_York\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:evt-1@example.com\r\n" +
+	"SUMMARY:Dentist\\, checkup\r\n" +
+	"DESCRIPTION:Bring insurance card\\nand ID\r\n" +
+	"LOCATION:123 Main St\r\n" +
+	"DTSTART;TZID=America/New_York:20240305T090000\r\n" +
+	"DURATION:PT45M\r\n" +
+	"ORGANIZER;CN=Dr. Smith:mailto:smith@example.com\r\n" +
+	"ATTENDEE;CN=\"Doe, Jane\";PARTSTAT=ACCEPTED:mailto:jane@example.com\r\n" +
+	"ATTENDEE;PARTSTAT=NEEDS-ACTION:MAILTO:jane@example.com\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"SUMMARY:Alarm\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:evt-2@example.com\r\n" +
+	"SUMMARY:Weekly sync with a very long summary that has been folded acros\r\n" +
+	" s two lines\r\n" +
+	"DTSTART:20240301T150000Z\r\n" +
+	"DTEND:20240301T153000Z\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=FR\r\n" +
+	"STATUS:CONFIRMED\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:evt-3@example.com\r\n" +
+	"SUMMARY:Vacation\r\n" +
+	"DTSTART;VALUE=DATE:20240710\r\n" +
+	"DTEND;VALUE=DATE:20240715\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParse(t *testing.T) {
+	cal, err := Parse(strings.NewReader(sampleICS))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if cal.Name != "Home" || cal.Timezone != "America/New_York" {
+		t.Errorf("calendar = %q/%q, want Home/America/New_York", cal.Name, cal.Timezone)
+	}
+	if len(cal.Events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(cal.Events))
+	}
+
+	e := cal.Events[0]
+	if e.Summary != "Dentist, checkup" {
+		t.Errorf("summary = %q, want unescaped text", e.Summary)
+	}
+	if e.Description != "Bring insurance card\nand ID" {
+		t.Errorf("description = %q, want newline unescaped", e.Description)
+	}
+	ny, _ := time.LoadLocation("America/New_York")
+	wantStart := time.Date(2024, 3, 5, 9, 0, 0, 0, ny)
+	if !e.Start.Equal(wantStart) {
+		t.Errorf("start = %v, want %v", e.Start, wantStart)
+	}
+	if !e.End.Equal(wantStart.Add(45 * time.Minute)) {
+		t.Errorf("end = %v, want start + 45m", e.End)
+	}
+	if e.Organizer == nil || e.Organizer.Email != "smith@example.com" || e.Organizer.Name != "Dr. Smith" {
+		t.Errorf("organizer = %+v", e.Organizer)
+	}
+	if len(e.Attendees) != 2 || e.Attendees[0].Name != "Doe, Jane" || e.Attendees[1].Email != "jane@example.com" {
+		t.Errorf("attendees = %+v", e.Attendees)
+	}
+
+	e = cal.Events[1]
+	if e.Summary != "Weekly sync with a very long summary that has been folded across two lines" {
+		t.Errorf("folded summary = %q", e.Summary)
+	}
+	if len(e.Recurrence) != 1 || e.Recurrence[0] != "RRULE:FREQ=WEEKLY;BYDAY=FR" {
+		t.Errorf("recurrence = %v", e.Recurrence)
+	}
+	if e.Status != "confirmed" {
+		t.Errorf("status = %q, want confirmed", e.Status)
+	}
+
+	e = cal.Events[2]
+	if !e.AllDay {
+		t.Error("expected all-day event")
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"missing uid", "BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:x\nEND:VEVENT\nEND:VCALENDAR\n"},
+		{"unterminated", "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:1\n"},
+		{"mismatched end", "BEGIN:VCALENDAR\nEND:VEVENT\n"},
+		{"bad date", "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:1\nDTSTART:notadate\nEND:VEVENT\nEND:VCALENDAR\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(tt.input)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestParse_TimeZones(t *testing.T) {
+	// An event after the VTIMEZONE defining its zone, written as Outlook
+	// does for zones with no IANA or Windows name
+	const customZone = "BEGIN:VEVENT\n" +
+		"UID:custom-summer\n" +
+		"DTSTART;TZID=Custom Eastern:20240704T090000\n" +
+		"DTEND;TZID=Custom Eastern:20240704T100000\n" +
+		"END:VEVENT\n" +
+		"BEGIN:VTIMEZONE\n" +
+		"TZID:Custom Eastern\n" +
+		"BEGIN:STANDARD\n" +
+		"DTSTART:16010101T020000\n" +
+		"TZOFFSETFROM:-0400\n" +
+		"TZOFFSETTO:-0500\n" +
+		"RRULE:FREQ=YEARLY;BYDAY=1SU;BYMONTH=11\n" +
+		"END:STANDARD\n" +
+		"BEGIN:DAYLIGHT\n" +
+		"DTSTART:16010101T020000\n" +
+		"TZOFFSETFROM:-0500\n" +
+		"TZOFFSETTO:-0400\n" +
+		"RRULE:FREQ=YEARLY;BYDAY=2SU;BYMONTH=3\n" +
+		"END:DAYLIGHT\n" +
+		"END:VTIMEZONE\n" +
+		"BEGIN:VEVENT\n" +
+		"UID:custom-winter\n" +
+		"DTSTART;TZID=Custom Eastern:20240115T090000\n" +
+		"END:VEVENT\n"
+
+	tests := []struct {
+		name      string
+		events    string
+		uid       string
+		wantStart string // RFC 3339, in UTC
+		wantEnd   string
+		wantTZID  string
+	}{
+		{
+			"windows name",
+			"BEGIN:VEVENT\nUID:1\nDTSTART;TZID=W. Europe Standard Time:20240704T090000\nDTEND;TZID=Pacific Standard Time:20240704T010000\nEND:VEVENT\n",
+			"1", "2024-07-04T07:00:00Z", "2024-07-04T08:00:00Z", "Europe/Berlin",
+		},
+		{
+			"mozilla path",
+			"BEGIN:VEVENT\nUID:1\nDTSTART;TZID=/mozilla.org/20070129_1/Europe/Berlin:20240115T090000\nDURATION:PT30M\nEND:VEVENT\n",
+			"1", "2024-01-15T08:00:00Z", "2024-01-15T08:30:00Z", "Europe/Berlin",
+		},
+		{
+			"vtimezone in daylight time",
+			customZone, "custom-summer", "2024-07-04T13:00:00Z", "2024-07-04T14:00:00Z", "Custom Eastern",
+		},
+		{
+			"vtimezone in standard time",
+			customZone, "custom-winter", "2024-01-15T14:00:00Z", "", "Custom Eastern",
+		},
+		{
+			"duration before dtstart",
+			"BEGIN:VEVENT\nUID:1\nDURATION:PT1H\nDTSTART:20240301T150000Z\nEND:VEVENT\n",
+			"1", "2024-03-01T15:00:00Z", "2024-03-01T16:00:00Z", "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cal, err := Parse(strings.NewReader("BEGIN:VCALENDAR\n" + tt.events + "END:VCALENDAR\n"))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if len(cal.Warnings) != 0 {
+				t.Errorf("warnings = %v", cal.Warnings)
+			}
+			var e *Event
+			for _, ev := range cal.Events {
+				if ev.UID == tt.uid {
+					e = ev
+				}
+			}
+			if e == nil {
+				t.Fatalf("no event %s", tt.uid)
+			}
+			format := func(t time.Time) string {
+				if t.IsZero() {
+					return ""
+				}
+				return t.UTC().Format(time.RFC3339)
+			}
+			if got := format(e.Start); got != tt.wantStart {
+				t.Errorf("start = %s, want %s", got, tt.wantStart)
+			}
+			if got := format(e.End); got != tt.wantEnd {
+				t.Errorf("end = %s, want %s", got, tt.wantEnd)
+			}
+			if e.TZID != tt.wantTZID {
+				t.Errorf("TZID = %q, want %q", e.TZID, tt.wantTZID)
+			}
+		})
+	}
+
+	// A zone nothing resolves is read in the calendar's zone, with a warning
+	cal, err := Parse(strings.NewReader("BEGIN:VCALENDAR\nX-WR-TIMEZONE:America/New_York\n" +
+		"BEGIN:VEVENT\nUID:1\nDTSTART;TZID=Nowhere:20240115T090000\nEND:VEVENT\nEND:VCALENDAR\n"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := cal.Events[0].Start.UTC().Format(time.RFC3339); got != "2024-01-15T14:00:00Z" {
+		t.Errorf("start = %s, want 2024-01-15T14:00:00Z", got)
+	}
+	if len(cal.Warnings) != 1 || !strings.Contains(cal.Warnings[0], `"Nowhere"`) {
+		t.Errorf("warnings = %v, want one for Nowhere", cal.Warnings)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"PT45M", 45 * time.Minute},
+		{"PT1H30M", 90 * time.Minute},
+		{"P1D", 24 * time.Hour},
+		{"P1W", 7 * 24 * time.Hour},
+		{"-PT15M", -15 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDuration(tt.input)
+		if err != nil {
+			t.Errorf("parseDuration(%q): %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDuration(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	s, err := store.Open(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	cal, err := Parse(strings.NewReader(sampleICS))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	summary, err := Import(s, "local", "home", cal)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if summary.EventsAdded != 3 {
+		t.Errorf("events added = %d, want 3", summary.EventsAdded)
+	}
+
+	src, _ := s.GetSourceByIdentifier("local")
+	if src == nil || src.SourceType != store.SourceTypeICS {
+		t.Fatalf("source = %+v, want ics source", src)
+	}
+
+	// Duplicate attendee addresses collapse to one row
+	var attendees int
+	_ = s.DB().QueryRow(`SELECT COUNT(*) FROM attendees`).Scan(&attendees)
+	if attendees != 1 {
+		t.Errorf("attendee count = %d, want 1", attendees)
+	}
+
+	// Re-importing updates in place
+	summary, err = Import(s, "local", "home", cal)
+	if err != nil {
+		t.Fatalf("re-import: %v", err)
+	}
+	if summary.EventsAdded != 0 || summary.EventsUpdated != 3 {
+		t.Errorf("re-import = +%d ~%d, want +0 ~3", summary.EventsAdded, summary.EventsUpdated)
+	}
+	count, _ := s.GetEventCount(src.ID)
+	if count != 3 {
+		t.Errorf("event count = %d, want 3", count)
+	}
+
+	// Attendees removed from the file are removed on re-import
+	cal.Events[0].Attendees = nil
+	if _, err := Import(s, "local", "home", cal); err != nil {
+		t.Fatalf("re-import: %v", err)
+	}
+	_ = s.DB().QueryRow(`SELECT COUNT(*) FROM attendees`).Scan(&attendees)
+	if attendees != 0 {
+		t.Errorf("attendee count after removing them = %d, want 0", attendees)
+	}
+
+	// Google sources with the same identifier are rejected
+	if _, err := s.GetOrCreateSource("local"); err == nil {
+		t.Error("expected error reusing ics identifier as google source")
+	}
+}
+
+func TestImport_MergesSyncedEvents(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	// The weekly sync is also synced from a Google account
+	google, _ := s.GetOrCreateSource("me@example.com")
+	googleCal, _ := s.UpsertCalendar(google.ID, &store.Calendar{GoogleCalendarID: "primary"})
+	syncedID, err := s.UpsertEvent(&store.Event{
+		SourceID: google.ID, CalendarID: googleCal, GoogleEventID: "abc123", ICalUID: "evt-2@example.com",
+		Summary: "Weekly sync", RecurrenceRule: "RRULE:FREQ=WEEKLY;BYDAY=FR",
+	})
+	if err != nil {
+		t.Fatalf("upsert synced event: %v", err)
+	}
+
+	cal, err := Parse(strings.NewReader(sampleICS))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	summary, err := Import(s, "local", "home", cal)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if summary.EventsAdded != 2 || summary.EventsMerged != 1 {
+		t.Errorf("import = +%d, %d merged; want +2, 1 merged", summary.EventsAdded, summary.EventsMerged)
+	}
+
+	var n int
+	_ = s.DB().QueryRow(`SELECT COUNT(*) FROM events WHERE ical_uid = 'evt-2@example.com'`).Scan(&n)
+	if n != 1 {
+		t.Errorf("copies of the weekly sync = %d, want 1", n)
+	}
+	var eventID int64
+	var externalID string
+	err = s.DB().QueryRow(`
+		SELECT p.event_id, p.external_id FROM event_provenance p JOIN sources s ON s.id = p.source_id
+		WHERE s.identifier = 'local'
+	`).Scan(&eventID, &externalID)
+	if err != nil {
+		t.Fatalf("query provenance: %v", err)
+	}
+	if eventID != syncedID || externalID != "evt-2@example.com" {
+		t.Errorf("provenance = event %d, %q; want event %d, evt-2@example.com", eventID, externalID, syncedID)
+	}
+}
+
+func TestExport_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	s, err := store.Open(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	cal, err := Parse(strings.NewReader(sampleICS))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, err := Import(s, "local", "home", cal); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	var buf strings.Builder
+	n, err := Export(s, &buf, ExportOptions{Name: "Home"})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("exported %d events, want 3", n)
+	}
+
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line exceeds %d octets: %q", maxLineOctets, line)
+		}
+	}
+
+	reparsed, err := Parse(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("re-parse exported ics: %v", err)
+	}
+	if len(reparsed.Events) != 3 {
+		t.Fatalf("re-parsed %d events, want 3", len(reparsed.Events))
+	}
+
+	byUID := make(map[string]*Event)
+	for _, e := range reparsed.Events {
+		byUID[e.UID] = e
+	}
+	orig := cal.Events[0]
+	got := byUID[orig.UID]
+	if got == nil {
+		t.Fatalf("event %s missing from export", orig.UID)
+	}
+	if got.Summary != orig.Summary || got.Description != orig.Description {
+		t.Errorf("text fields = %q/%q, want %q/%q", got.Summary, got.Description, orig.Summary, orig.Description)
+	}
+	if !got.Start.Equal(orig.Start) || !got.End.Equal(orig.End) {
+		t.Errorf("times = %v..%v, want %v..%v", got.Start, got.End, orig.Start, orig.End)
+	}
+	if len(got.Attendees) != 1 || got.Attendees[0].Name != "Doe, Jane" || got.Attendees[0].PartStat != "ACCEPTED" {
+		t.Errorf("attendees = %+v", got.Attendees)
+	}
+	if rrule := byUID["evt-2@example.com"].Recurrence; len(rrule) != 1 || rrule[0] != "RRULE:FREQ=WEEKLY;BYDAY=FR" {
+		t.Errorf("recurrence = %v", rrule)
+	}
+	if !byUID["evt-3@example.com"].AllDay {
+		t.Error("expected all-day event to round-trip")
+	}
+}
+
+func TestExport_Alarms(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &store.Calendar{
+		GoogleCalendarID: "primary",
+		DefaultReminders: []*store.Reminder{{Method: "popup", Minutes: 10}},
+	})
+	start := sql.NullTime{Time: time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC), Valid: true}
+	err = s.InTx(func(tx *store.Tx) error {
+		_, err := tx.UpsertEvents(src.ID, []*store.EventWrite{
+			{
+				Event:     &store.Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "own", Summary: "Dentist", StartTime: start},
+				Attendees: []*store.Attendee{{Email: "me@example.com", IsSelf: true}},
+				Reminders: &store.EventReminders{Overrides: []*store.Reminder{{Method: "email", Minutes: 1440}, {Method: "popup", Minutes: 30}}},
+			},
+			{
+				Event:     &store.Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "default", Summary: "Standup", StartTime: start},
+				Reminders: &store.EventReminders{UseDefault: true},
+			},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("store events: %v", err)
+	}
+
+	var buf strings.Builder
+	if _, err := Export(s, &buf, ExportOptions{}); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	for _, want := range []string{
+		"ACTION:EMAIL\r\nTRIGGER:-P1D\r\nDESCRIPTION:Dentist\r\nSUMMARY:Dentist\r\nATTENDEE:mailto:me@example.com\r\n",
+		"ACTION:DISPLAY\r\nTRIGGER:-PT30M\r\nDESCRIPTION:Dentist\r\n",
+		"ACTION:DISPLAY\r\nTRIGGER:-PT10M\r\nDESCRIPTION:Standup\r\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("export lacks alarm %q:\n%s", want, buf.String())
+		}
+	}
+	if n := strings.Count(buf.String(), "BEGIN:VALARM"); n != 3 {
+		t.Errorf("exported %d alarms, want 3", n)
+	}
+	if _, err := Parse(strings.NewReader(buf.String())); err != nil {
+		t.Errorf("re-parse exported ics: %v", err)
+	}
+}
+
+func TestRecurrence_Between(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no tzdata")
+	}
+	// Monday 4 March 2024, 09:00 New York; DST starts 10 March
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, ny)
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		want  []string // First occurrences, as 2006-01-02 15:04 in start's zone
+		count int      // Total in the window; 0 means len(want)
+	}{
+		{name: "daily count", rule: "RRULE:FREQ=DAILY;COUNT=3", want: []string{"2024-03-04 09:00", "2024-03-05 09:00", "2024-03-06 09:00"}},
+		{name: "weekly keeps wall clock across DST", rule: "RRULE:FREQ=WEEKLY;UNTIL=20240312T000000Z", want: []string{"2024-03-04 09:00", "2024-03-11 09:00"}},
+		{name: "weekly until date", rule: "RRULE:FREQ=WEEKLY;UNTIL=20240311", want: []string{"2024-03-04 09:00", "2024-03-11 09:00"}},
+		{name: "biweekly days", rule: "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=4", want: []string{"2024-03-04 09:00", "2024-03-07 09:00", "2024-03-18 09:00", "2024-03-21 09:00"}},
+		{name: "exdate", rule: "RRULE:FREQ=DAILY;COUNT=3\nEXDATE;TZID=America/New_York:20240305T090000", want: []string{"2024-03-04 09:00", "2024-03-06 09:00"}},
+		{name: "rdate", rule: "RRULE:FREQ=DAILY;COUNT=1\nRDATE:20240320T130000Z", want: []string{"2024-03-04 09:00", "2024-03-20 09:00"}},
+		{name: "second tuesday", rule: "RRULE:FREQ=MONTHLY;BYDAY=2TU", want: []string{"2024-03-12 09:00", "2024-04-09 09:00"}},
+		{name: "last friday", rule: "RRULE:FREQ=MONTHLY;BYDAY=-1FR", want: []string{"2024-03-29 09:00", "2024-04-26 09:00"}},
+		{name: "last weekday", rule: "RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", want: []string{"2024-03-29 09:00", "2024-04-30 09:00"}},
+		{name: "month day skips short months", rule: "RRULE:FREQ=MONTHLY", start: time.Date(2024, 1, 31, 9, 0, 0, 0, ny), want: []string{"2024-03-31 09:00"}},
+		{name: "negative month day", rule: "RRULE:FREQ=MONTHLY;BYMONTHDAY=-1", want: []string{"2024-03-31 09:00", "2024-04-30 09:00"}},
+		{name: "yearly", rule: "RRULE:FREQ=YEARLY", start: time.Date(2020, 4, 15, 9, 0, 0, 0, ny), want: []string{"2024-04-15 09:00"}},
+		{name: "yearly by month", rule: "RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=1SU", start: time.Date(2020, 3, 1, 9, 0, 0, 0, ny), want: []string{"2024-03-03 09:00"}},
+		{name: "weekdays in window", rule: "RRULE:FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", want: []string{"2024-03-04 09:00", "2024-03-05 09:00"}, count: 42},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := ParseRecurrence(tt.rule)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			s := start
+			if !tt.start.IsZero() {
+				s = tt.start
+			}
+			got := rec.Between(s, from, to)
+			want := tt.count
+			if want == 0 {
+				want = len(tt.want)
+			}
+			if len(got) != want {
+				t.Errorf("got %d occurrences (%v), want %d", len(got), got, want)
+			}
+			for i, w := range tt.want {
+				if i < len(got) && got[i].In(ny).Format("2006-01-02 15:04") != w {
+					t.Errorf("occurrence %d = %s, want %s", i, got[i].In(ny).Format("2006-01-02 15:04"), w)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRule_Errors(t *testing.T) {
+	for _, rule := range []string{"", "FREQ=HOURLY", "FREQ=DAILY;BYHOUR=9", "FREQ=WEEKLY;BYDAY=XX", "FREQ=DAILY;INTERVAL=0", "FREQ"} {
+		if _, err := ParseRule(rule); err == nil {
+			t.Errorf("ParseRule(%q) succeeded, want error", rule)
+		}
+	}
+}
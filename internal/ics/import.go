@@ -0,0 +1,228 @@
+package ics
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// ImportSummary contains import statistics.
+type ImportSummary struct {
+	EventsAdded   int
+	EventsUpdated int
+	EventsDeleted int
+	EventsMerged  int // Events an API source already stores, kept as provenance
+	EventsFlagged int // Stored with implausible dates (store.EventQuality)
+}
+
+// Import stores a parsed calendar under an ICS source named identifier.
+// calendarID identifies the calendar within the source; re-importing the same
+// calendar updates events in place, keyed by UID (and RECURRENCE-ID).
+func Import(s *store.Store, identifier, calendarID string, cal *Calendar) (*ImportSummary, error) {
+	source, err := s.GetOrCreateSourceOfType(store.SourceTypeICS, identifier)
+	if err != nil {
+		return nil, fmt.Errorf("get source: %w", err)
+	}
+
+	name := cal.Name
+	if name == "" {
+		name = calendarID
+	}
+	calID, err := s.UpsertCalendar(source.ID, &store.Calendar{
+		GoogleCalendarID: calendarID,
+		Summary:          name,
+		Timezone:         cal.Timezone,
+		SubscriptionKind: store.CalendarKindImport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("upsert calendar: %w", err)
+	}
+
+	runID, err := s.StartSyncRun(source.ID, calID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := importEvents(s, source, calID, cal.Events)
+	if err != nil {
+		_ = s.FailSyncRun(runID, err.Error())
+		return nil, err
+	}
+
+	if err := s.CompleteSyncRun(runID, store.SyncStats{
+		EventsAdded:   summary.EventsAdded,
+		EventsUpdated: summary.EventsUpdated,
+		EventsDeleted: summary.EventsDeleted,
+	}); err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// importEvents upserts events and their attendees in one transaction.
+func importEvents(s *store.Store, source *store.Source, calID int64, events []*Event) (*ImportSummary, error) {
+	summary := &ImportSummary{}
+
+	// Events an API source also syncs keep the synced row; the import is
+	// recorded as its provenance, and the series' exceptions come from the
+	// API source too.
+	canonical := make(map[string]int64)
+	for _, e := range events {
+		if e.RecurrenceID != "" || e.UID == "" {
+			continue
+		}
+		id, err := s.APIEventByICalUID(e.UID)
+		if err != nil {
+			return nil, err
+		}
+		if id != 0 {
+			canonical[e.UID] = id
+		}
+	}
+
+	// The events are stored together, as sync stores a page
+	err := s.InTx(func(tx *store.Tx) error {
+		for _, e := range events {
+			eventID := e.UID
+			if e.RecurrenceID != "" {
+				eventID = e.UID + "_" + e.RecurrenceID
+			}
+
+			exists, err := tx.EventExists(source.ID, eventID)
+			if err != nil {
+				return err
+			}
+
+			if apiID := canonical[e.UID]; apiID != 0 {
+				if exists {
+					if err := tx.DeleteEvent(source.ID, eventID); err != nil {
+						return err
+					}
+				}
+				if e.RecurrenceID == "" {
+					if err := tx.AddEventProvenance(apiID, source.ID, calID, eventID); err != nil {
+						return err
+					}
+				}
+				summary.EventsMerged++
+				continue
+			}
+
+			// Cancelled instances are removed, mirroring incremental Google sync
+			if e.Status == "cancelled" {
+				if exists {
+					if err := tx.DeleteEvent(source.ID, eventID); err != nil {
+						return err
+					}
+					summary.EventsDeleted++
+				}
+				continue
+			}
+
+			event := &store.Event{
+				SourceID:         source.ID,
+				CalendarID:       calID,
+				GoogleEventID:    eventID,
+				ICalUID:          e.UID,
+				Summary:          e.Summary,
+				Description:      e.Description,
+				Location:         e.Location,
+				StartTime:        nullTime(e.Start),
+				EndTime:          nullTime(e.End),
+				AllDay:           e.AllDay,
+				OriginalTimezone: e.TZID,
+				RecurrenceRule:   strings.Join(e.Recurrence, "\n"),
+				Status:           e.Status,
+				Visibility:       e.Class,
+				CreatedAt:        nullTime(e.Created),
+				UpdatedAt:        nullTime(e.LastModified),
+			}
+			if event.Status == "" {
+				event.Status = "confirmed"
+			}
+			if e.RecurrenceID != "" {
+				event.RecurringEventID = e.UID
+			}
+			if e.Organizer != nil {
+				event.OrganizerEmail = e.Organizer.Email
+				event.OrganizerName = e.Organizer.Name
+			}
+
+			id, err := tx.UpsertEvent(event)
+			if err != nil {
+				return err
+			}
+
+			// Replaced even when empty, so attendees removed from the file go
+			if err := tx.ReplaceAttendees(id, convertAttendees(e, source.Identifier)); err != nil {
+				return fmt.Errorf("store attendees for %s: %w", eventID, err)
+			}
+
+			if store.EventQuality(event, time.Now()) != "" {
+				summary.EventsFlagged++
+			}
+			if exists {
+				summary.EventsUpdated++
+			} else {
+				summary.EventsAdded++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// convertAttendees maps ATTENDEE properties to store attendees, dropping
+// duplicate addresses and translating PARTSTAT to Google response statuses.
+func convertAttendees(e *Event, selfEmail string) []*store.Attendee {
+	var attendees []*store.Attendee
+	seen := make(map[string]bool)
+	for _, a := range e.Attendees {
+		key := strings.ToLower(a.Email)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		attendees = append(attendees, &store.Attendee{
+			Email:          a.Email,
+			DisplayName:    a.Name,
+			ResponseStatus: responseStatus(a.PartStat),
+			IsOrganizer:    e.Organizer != nil && strings.EqualFold(e.Organizer.Email, a.Email),
+			IsSelf:         strings.EqualFold(selfEmail, a.Email),
+		})
+	}
+	return attendees
+}
+
+// responseStatus converts an iCalendar PARTSTAT to a Google response status.
+func responseStatus(partStat string) string {
+	switch strings.ToUpper(partStat) {
+	case "ACCEPTED":
+		return "accepted"
+	case "DECLINED":
+		return "declined"
+	case "TENTATIVE":
+		return "tentative"
+	case "", "NEEDS-ACTION":
+		return "needsAction"
+	default:
+		return strings.ToLower(partStat)
+	}
+}
+
+// nullTime converts a possibly-zero time to sql.NullTime.
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t, Valid: true}
+}
@@ -0,0 +1,399 @@
+package ics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence is a set of recurrence lines (RRULE, RDATE, EXDATE) as stored
+// in events.recurrence_rule by Google sync, Outlook sync and ICS import.
+type Recurrence struct {
+	Rules   []*Rule
+	RDates  []time.Time
+	ExDates []time.Time
+	exDays  map[string]bool // EXDATE;VALUE=DATE values, as YYYYMMDD
+}
+
+// Rule is a parsed RRULE. Only the parts calendars use for events are
+// supported: FREQ from DAILY to YEARLY with INTERVAL, COUNT, UNTIL, BYDAY,
+// BYMONTHDAY, BYMONTH, BYSETPOS and WKST.
+type Rule struct {
+	Freq       string // DAILY, WEEKLY, MONTHLY or YEARLY
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	BySetPos   []int
+	WeekStart  time.Weekday
+
+	untilDate bool // UNTIL was a DATE: the whole day in the series' location
+}
+
+// WeekdayNum is a BYDAY entry such as MO, 2TU or -1FR. N is 0 for every
+// such weekday in the period.
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// ParseRecurrence parses newline-separated recurrence lines. EXRULE, which
+// RFC 5545 deprecates, is ignored.
+func ParseRecurrence(lines string) (*Recurrence, error) {
+	r := &Recurrence{exDays: map[string]bool{}}
+	for _, line := range strings.Split(lines, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		prop, err := parseProperty(line)
+		if err != nil {
+			return nil, err
+		}
+		switch prop.Name {
+		case "RRULE":
+			rule, err := ParseRule(prop.Value)
+			if err != nil {
+				return nil, err
+			}
+			r.Rules = append(r.Rules, rule)
+		case "RDATE", "EXDATE":
+			for _, value := range strings.Split(prop.Value, ",") {
+				if strings.Contains(value, "/") {
+					continue // PERIOD values
+				}
+				// Stored lines come without their VTIMEZONEs: a TZID that
+				// isn't an IANA or Windows name reads as UTC
+				t, allDay, err := parseDateTime(property{Name: prop.Name, Params: prop.Params, Value: value}, nil)
+				if err != nil && !errors.Is(err, errUnknownZone) {
+					return nil, fmt.Errorf("parse %s: %w", prop.Name, err)
+				}
+				switch {
+				case prop.Name == "RDATE":
+					r.RDates = append(r.RDates, t)
+				case allDay:
+					r.exDays[t.Format("20060102")] = true
+				default:
+					r.ExDates = append(r.ExDates, t)
+				}
+			}
+		}
+	}
+	if len(r.Rules) == 0 && len(r.RDates) == 0 {
+		return nil, fmt.Errorf("no RRULE or RDATE in recurrence")
+	}
+	return r, nil
+}
+
+// ParseRule parses an RRULE value such as FREQ=WEEKLY;BYDAY=MO,WE.
+func ParseRule(value string) (*Rule, error) {
+	rule := &Rule{Interval: 1, WeekStart: time.Monday}
+	for _, part := range strings.Split(strings.TrimPrefix(value, "RRULE:"), ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed RRULE part %q", part)
+		}
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(val)
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(val)
+			if err == nil && rule.Interval < 1 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(val)
+		case "UNTIL":
+			rule.Until, rule.untilDate, err = parseDateTime(property{Params: map[string]string{}, Value: val}, nil)
+		case "BYDAY":
+			for _, d := range strings.Split(val, ",") {
+				wd, ok := weekdayCodes[strings.ToUpper(d[max(len(d)-2, 0):])]
+				if !ok {
+					return nil, fmt.Errorf("invalid BYDAY %q", d)
+				}
+				n := 0
+				if prefix := d[:len(d)-2]; prefix != "" {
+					if n, err = strconv.Atoi(prefix); err != nil {
+						break
+					}
+				}
+				rule.ByDay = append(rule.ByDay, WeekdayNum{N: n, Day: wd})
+			}
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseInts(val)
+		case "BYMONTH":
+			var months []int
+			months, err = parseInts(val)
+			for _, m := range months {
+				rule.ByMonth = append(rule.ByMonth, time.Month(m))
+			}
+		case "BYSETPOS":
+			rule.BySetPos, err = parseInts(val)
+		case "WKST":
+			var ok bool
+			if rule.WeekStart, ok = weekdayCodes[strings.ToUpper(val)]; !ok {
+				err = fmt.Errorf("invalid weekday")
+			}
+		default:
+			return nil, fmt.Errorf("unsupported RRULE part %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("RRULE %s=%s: %w", key, val, err)
+		}
+	}
+	switch rule.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	case "":
+		return nil, fmt.Errorf("RRULE without FREQ")
+	default:
+		return nil, fmt.Errorf("unsupported RRULE frequency %s", rule.Freq)
+	}
+	return rule, nil
+}
+
+func parseInts(s string) ([]int, error) {
+	var ints []int
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, err
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
+
+// Between returns the start times of occurrences of a series first starting
+// at start that fall in [from, to), in order. Occurrences keep start's
+// wall-clock time in start's location, across DST changes.
+func (r *Recurrence) Between(start, from, to time.Time) []time.Time {
+	seen := map[time.Time]bool{}
+	var times []time.Time
+	add := func(t time.Time) {
+		if t.Before(from) || !t.Before(to) || seen[t.UTC()] || r.excluded(t) {
+			return
+		}
+		seen[t.UTC()] = true
+		times = append(times, t)
+	}
+	for _, rule := range r.Rules {
+		rule.each(start, to, func(t time.Time) { add(t) })
+	}
+	for _, t := range r.RDates {
+		add(t.In(start.Location()))
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times
+}
+
+func (r *Recurrence) excluded(t time.Time) bool {
+	if r.exDays[t.Format("20060102")] {
+		return true
+	}
+	for _, ex := range r.ExDates {
+		if ex.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// each calls fn for the rule's occurrences from start until to, COUNT or
+// UNTIL ends the series.
+func (r *Rule) each(start, to time.Time, fn func(time.Time)) {
+	loc := start.Location()
+	hour, min, sec := start.Clock()
+	until := r.Until
+	if r.untilDate {
+		y, m, d := until.Date()
+		until = time.Date(y, m, d, 23, 59, 59, 0, loc)
+	}
+
+	count := 0
+	for period := 0; ; period++ {
+		first, days := r.periodDays(start, period)
+		if first.After(to) {
+			return
+		}
+		for _, day := range days {
+			y, m, d := day.Date()
+			t := time.Date(y, m, d, hour, min, sec, 0, loc)
+			if t.Before(start) {
+				continue
+			}
+			if (!until.IsZero() && t.After(until)) || (r.Count > 0 && count >= r.Count) || !t.Before(to) {
+				return
+			}
+			count++
+			fn(t)
+		}
+	}
+}
+
+// periodDays returns the first day of the rule's nth period and the days in
+// it that match the rule, in order. Days are civil dates at UTC midnight.
+func (r *Rule) periodDays(start time.Time, n int) (time.Time, []time.Time) {
+	sy, sm, sd := start.Date()
+	startDay := time.Date(sy, sm, sd, 0, 0, 0, 0, time.UTC)
+
+	var first time.Time
+	var days []time.Time
+	switch r.Freq {
+	case "DAILY":
+		first = startDay.AddDate(0, 0, n*r.Interval)
+		if r.matchesMonth(first) && r.matchesMonthDay(first) && r.matchesWeekday(first) {
+			days = []time.Time{first}
+		}
+	case "WEEKLY":
+		offset := (int(startDay.Weekday()) - int(r.WeekStart) + 7) % 7
+		first = startDay.AddDate(0, 0, -offset+7*n*r.Interval)
+		byDay := r.ByDay
+		if len(byDay) == 0 {
+			byDay = []WeekdayNum{{Day: startDay.Weekday()}}
+		}
+		for i := 0; i < 7; i++ {
+			day := first.AddDate(0, 0, i)
+			for _, wd := range byDay {
+				if wd.Day == day.Weekday() && r.matchesMonth(day) {
+					days = append(days, day)
+				}
+			}
+		}
+	case "MONTHLY":
+		first = time.Date(sy, sm+time.Month(n*r.Interval), 1, 0, 0, 0, 0, time.UTC)
+		if r.matchesMonth(first) {
+			days = r.monthDays(first, sd)
+		}
+	case "YEARLY":
+		first = time.Date(sy+n*r.Interval, 1, 1, 0, 0, 0, 0, time.UTC)
+		switch {
+		case len(r.ByMonth) > 0:
+			for m := time.January; m <= time.December; m++ {
+				if month := time.Date(first.Year(), m, 1, 0, 0, 0, 0, time.UTC); r.matchesMonth(month) {
+					days = append(days, r.monthDays(month, sd)...)
+				}
+			}
+		case len(r.ByMonthDay) > 0:
+			for m := time.January; m <= time.December; m++ {
+				days = append(days, r.monthDays(time.Date(first.Year(), m, 1, 0, 0, 0, 0, time.UTC), sd)...)
+			}
+		case len(r.ByDay) > 0:
+			days = r.matchingDays(first, first.AddDate(1, 0, 0))
+		default:
+			if day := time.Date(first.Year(), sm, sd, 0, 0, 0, 0, time.UTC); day.Day() == sd {
+				days = []time.Time{day}
+			}
+		}
+	}
+	return first, r.setPos(days)
+}
+
+// monthDays returns the matching days of the month starting at first; with
+// neither BYMONTHDAY nor BYDAY that is day defaultDay, if the month has it.
+func (r *Rule) monthDays(first time.Time, defaultDay int) []time.Time {
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if day := first.AddDate(0, 0, defaultDay-1); day.Month() == first.Month() {
+			return []time.Time{day}
+		}
+		return nil
+	}
+	return r.matchingDays(first, first.AddDate(0, 1, 0))
+}
+
+// matchingDays returns the days in [first, end) matching BYMONTHDAY and
+// BYDAY, with BYDAY ordinals counted within that span.
+func (r *Rule) matchingDays(first, end time.Time) []time.Time {
+	total := int(end.Sub(first).Hours()/24 + 0.5)
+	var days []time.Time
+	for i := 0; i < total; i++ {
+		day := first.AddDate(0, 0, i)
+		if !r.matchesMonthDay(day) {
+			continue
+		}
+		if len(r.ByDay) == 0 {
+			days = append(days, day)
+			continue
+		}
+		for _, wd := range r.ByDay {
+			if wd.Day != day.Weekday() {
+				continue
+			}
+			if wd.N == 0 || (wd.N > 0 && i/7+1 == wd.N) || (wd.N < 0 && (total-1-i)/7+1 == -wd.N) {
+				days = append(days, day)
+				break
+			}
+		}
+	}
+	return days
+}
+
+func (r *Rule) matchesMonth(day time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if m == day.Month() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, d := range r.ByMonthDay {
+		if d == day.Day() || (d < 0 && daysInMonth+d+1 == day.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWeekday applies BYDAY to DAILY rules, where it only limits days.
+func (r *Rule) matchesWeekday(day time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Day == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// setPos applies BYSETPOS to a period's days.
+func (r *Rule) setPos(days []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 || len(days) == 0 {
+		return days
+	}
+	var picked []time.Time
+	for _, pos := range r.BySetPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(days) + pos
+		}
+		if i >= 0 && i < len(days) {
+			picked = append(picked, days[i])
+		}
+	}
+	sort.Slice(picked, func(i, j int) bool { return picked[i].Before(picked[j]) })
+	return picked
+}
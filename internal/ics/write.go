@@ -0,0 +1,209 @@
+package ics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// prodID identifies calvault as the producer of exported files.
+const prodID = "-//calvault//calvault//EN"
+
+// maxLineOctets is the RFC 5545 content line length limit (excluding CRLF).
+const maxLineOctets = 75
+
+// Write serializes a calendar as an iCalendar stream. Timed events are
+// written in UTC; all-day events use DATE values. stamp is used for DTSTAMP.
+func Write(w io.Writer, cal *Calendar, stamp time.Time) error {
+	bw := bufio.NewWriter(w)
+	lw := &lineWriter{w: bw}
+
+	lw.line("BEGIN:VCALENDAR")
+	lw.line("VERSION:2.0")
+	lw.line("PRODID:" + prodID)
+	lw.line("CALSCALE:GREGORIAN")
+	if cal.Name != "" {
+		lw.line("X-WR-CALNAME:" + escapeText(cal.Name))
+	}
+	if cal.Timezone != "" {
+		lw.line("X-WR-TIMEZONE:" + cal.Timezone)
+	}
+
+	for _, e := range cal.Events {
+		writeEvent(lw, e, stamp)
+	}
+
+	lw.line("END:VCALENDAR")
+	if lw.err != nil {
+		return fmt.Errorf("write ics: %w", lw.err)
+	}
+	return bw.Flush()
+}
+
+// writeEvent writes a single VEVENT component.
+func writeEvent(lw *lineWriter, e *Event, stamp time.Time) {
+	lw.line("BEGIN:VEVENT")
+	lw.line("UID:" + e.UID)
+	lw.line("DTSTAMP:" + formatUTC(stamp))
+
+	if !e.Start.IsZero() {
+		lw.line("DTSTART" + formatDateTimeValue(e.Start, e.AllDay))
+	}
+	if !e.End.IsZero() {
+		lw.line("DTEND" + formatDateTimeValue(e.End, e.AllDay))
+	}
+	if e.RecurrenceID != "" {
+		if len(e.RecurrenceID) == 8 {
+			lw.line("RECURRENCE-ID;VALUE=DATE:" + e.RecurrenceID)
+		} else {
+			lw.line("RECURRENCE-ID:" + e.RecurrenceID)
+		}
+	}
+	for _, r := range e.Recurrence {
+		if r != "" {
+			lw.line(r)
+		}
+	}
+
+	if e.Summary != "" {
+		lw.line("SUMMARY:" + escapeText(e.Summary))
+	}
+	if e.Description != "" {
+		lw.line("DESCRIPTION:" + escapeText(e.Description))
+	}
+	if e.Location != "" {
+		lw.line("LOCATION:" + escapeText(e.Location))
+	}
+	if e.Status != "" {
+		lw.line("STATUS:" + strings.ToUpper(e.Status))
+	}
+	if e.Class != "" && e.Class != "default" {
+		lw.line("CLASS:" + strings.ToUpper(e.Class))
+	}
+
+	if e.Organizer != nil && e.Organizer.Email != "" {
+		lw.line("ORGANIZER" + cnParam(e.Organizer.Name) + ":mailto:" + e.Organizer.Email)
+	}
+	for _, a := range e.Attendees {
+		params := cnParam(a.Name)
+		if a.Role != "" {
+			params += ";ROLE=" + a.Role
+		}
+		if a.PartStat != "" {
+			params += ";PARTSTAT=" + a.PartStat
+		}
+		lw.line("ATTENDEE" + params + ":mailto:" + a.Email)
+	}
+
+	if !e.Created.IsZero() {
+		lw.line("CREATED:" + formatUTC(e.Created))
+	}
+	if !e.LastModified.IsZero() {
+		lw.line("LAST-MODIFIED:" + formatUTC(e.LastModified))
+	}
+	for _, a := range e.Alarms {
+		writeAlarm(lw, e, a)
+	}
+	lw.line("END:VEVENT")
+}
+
+// writeAlarm writes a VALARM component of e. RFC 5545 requires a
+// DESCRIPTION for both actions, and a SUMMARY and ATTENDEE for EMAIL.
+func writeAlarm(lw *lineWriter, e *Event, a *Alarm) {
+	text := e.Summary
+	if text == "" {
+		text = "Reminder"
+	}
+	lw.line("BEGIN:VALARM")
+	lw.line("ACTION:" + a.Action)
+	lw.line("TRIGGER:" + formatTrigger(a.Minutes))
+	lw.line("DESCRIPTION:" + escapeText(text))
+	if a.Action == "EMAIL" {
+		lw.line("SUMMARY:" + escapeText(text))
+		lw.line("ATTENDEE:mailto:" + a.Email)
+	}
+	lw.line("END:VALARM")
+}
+
+// formatTrigger formats a TRIGGER duration minutes before the start, in
+// whole days or weeks where possible.
+func formatTrigger(minutes int) string {
+	switch {
+	case minutes <= 0:
+		return "PT0M"
+	case minutes%(7*24*60) == 0:
+		return fmt.Sprintf("-P%dW", minutes/(7*24*60))
+	case minutes%(24*60) == 0:
+		return fmt.Sprintf("-P%dD", minutes/(24*60))
+	default:
+		return fmt.Sprintf("-PT%dM", minutes)
+	}
+}
+
+// lineWriter writes folded CRLF-terminated content lines, keeping the first error.
+type lineWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (lw *lineWriter) line(s string) {
+	if lw.err != nil {
+		return
+	}
+	_, lw.err = io.WriteString(lw.w, fold(s)+"\r\n")
+}
+
+// fold splits a content line into 75-octet segments joined by CRLF + space,
+// without breaking UTF-8 sequences.
+func fold(s string) string {
+	if len(s) <= maxLineOctets {
+		return s
+	}
+	var b strings.Builder
+	limit := maxLineOctets
+	n := 0
+	for _, r := range s {
+		size := len(string(r))
+		if n+size > limit {
+			b.WriteString("\r\n ")
+			n = 0
+			limit = maxLineOctets - 1 // Continuation lines start with a space
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	return b.String()
+}
+
+// formatUTC formats t as a UTC DATE-TIME value.
+func formatUTC(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// formatDateTimeValue formats the parameters and value of a DTSTART/DTEND.
+func formatDateTimeValue(t time.Time, allDay bool) string {
+	if allDay {
+		return ";VALUE=DATE:" + t.Format("20060102")
+	}
+	return ":" + formatUTC(t)
+}
+
+// cnParam returns a CN parameter, quoted when it contains special characters.
+func cnParam(name string) string {
+	if name == "" {
+		return ""
+	}
+	name = strings.ReplaceAll(name, `"`, "'")
+	if strings.ContainsAny(name, ",;:") {
+		return `;CN="` + name + `"`
+	}
+	return ";CN=" + name
+}
+
+// escapeText applies RFC 5545 TEXT escaping.
+func escapeText(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
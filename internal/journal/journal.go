@@ -0,0 +1,173 @@
+// Package journal renders one day of the archive through a text/template,
+// for feeding personal journaling workflows.
+package journal
+
+import (
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/salman1993/calvault/internal/agenda"
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// Day is the data a journal template is executed with.
+type Day struct {
+	Date   time.Time // Local midnight
+	Events []*Event
+	People []*Person // Attendees other than you, most meetings first
+	Hours  float64   // Time in timed events you didn't decline
+}
+
+// Event is one occurrence on the day. Times are local.
+type Event struct {
+	Summary     string
+	Description string
+	Location    string
+	Status      string
+	Calendar    string
+	Account     string
+	Start       time.Time
+	End         time.Time // Zero if the event has no end time
+	AllDay      bool
+	Recurring   bool
+	Declined    bool // You declined it
+	Attendees   []*Person
+}
+
+// Person is an attendee.
+type Person struct {
+	Name     string
+	Email    string
+	Meetings int // Events of the day they attend
+}
+
+// String returns "Name <email>", or just the email if the name is unknown.
+func (p *Person) String() string {
+	if p.Name == "" {
+		return p.Email
+	}
+	return p.Name + " <" + p.Email + ">"
+}
+
+// DefaultTemplate is used when no template file is given.
+const DefaultTemplate = `# {{date .Date}} ({{.Date.Weekday}})
+{{if .Events}}
+## Events
+{{range .Events}}
+- {{if .AllDay}}All day{{else}}{{time .Start}}{{if not .End.IsZero}}-{{time .End}}{{end}}{{end}} {{or .Summary "(no title)"}}
+  {{- if .Location}} @ {{.Location}}{{end}}{{if .Declined}} (declined){{end}}
+{{- end}}
+
+{{printf "%.1f" .Hours}} hours in meetings.
+{{else}}
+No events.
+{{end}}
+{{- if .People}}
+## People
+{{range .People}}
+- {{.}}{{if gt .Meetings 1}} ({{.Meetings}} meetings){{end}}
+{{- end}}
+{{end}}
+## Notes
+
+`
+
+// Parse parses a journal template. Besides the text/template builtins it
+// provides date and time, which format a time.Time in locale l.
+func Parse(name, text string, l render.Locale) (*template.Template, error) {
+	return template.New(name).Funcs(template.FuncMap{
+		"date": l.Date,
+		"time": l.Time,
+	}).Parse(text)
+}
+
+// Build collects the events of the local day starting at date, with
+// recurring series expanded.
+func Build(s *store.Store, date time.Time) (*Day, error) {
+	items, err := agenda.Days(s, date, date.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	sources, err := s.ListSources()
+	if err != nil {
+		return nil, err
+	}
+	accounts := make(map[int64]string, len(sources))
+	calendars := map[int64]string{}
+	for _, src := range sources {
+		accounts[src.ID] = src.Identifier
+		cals, err := s.GetCalendars(src.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cals {
+			calendars[c.ID] = c.Summary
+		}
+	}
+
+	day := &Day{Date: date}
+	people := map[string]*Person{}
+	for _, it := range items {
+		e := it.Event
+		ev := &Event{
+			Summary:     e.Summary,
+			Description: e.Description,
+			Location:    e.Location,
+			Status:      e.Status,
+			Calendar:    calendars[e.CalendarID],
+			Account:     accounts[e.SourceID],
+			Start:       it.Start.Local(),
+			AllDay:      e.AllDay,
+			Recurring:   e.RecurrenceRule != "" || e.RecurringEventID != "",
+		}
+		if !it.End.IsZero() {
+			ev.End = it.End.Local()
+		}
+
+		attendees, err := s.ListAttendees(e.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range attendees {
+			if a.IsSelf {
+				ev.Declined = a.ResponseStatus == "declined"
+				continue
+			}
+			if a.ResponseStatus == "declined" {
+				continue
+			}
+			key := strings.ToLower(a.Email)
+			p := people[key]
+			if p == nil {
+				p = &Person{Email: a.Email}
+				people[key] = p
+			}
+			if p.Name == "" {
+				p.Name = a.DisplayName
+			}
+			p.Meetings++
+			ev.Attendees = append(ev.Attendees, p)
+		}
+
+		if !ev.AllDay && !ev.End.IsZero() && !ev.Declined {
+			day.Hours += ev.End.Sub(ev.Start).Hours()
+		}
+		day.Events = append(day.Events, ev)
+	}
+
+	for _, p := range people {
+		day.People = append(day.People, p)
+	}
+	sort.Slice(day.People, func(i, j int) bool {
+		a, b := day.People[i], day.People[j]
+		if a.Meetings != b.Meetings {
+			return a.Meetings > b.Meetings
+		}
+		return strings.ToLower(a.String()) < strings.ToLower(b.String())
+	})
+	return day, nil
+}
@@ -0,0 +1,103 @@
+package journal
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+func TestBuild(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	date := time.Date(2024, 6, 12, 0, 0, 0, 0, time.Local)
+	at := func(day, hour, min int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2024, 6, day, hour, min, 0, 0, time.Local), Valid: true}
+	}
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: "primary", Summary: "Work"})
+	events := []struct {
+		event     *store.Event
+		attendees []*store.Attendee
+	}{
+		{&store.Event{GoogleEventID: "standup", Summary: "Standup", StartTime: at(10, 9, 0), EndTime: at(10, 9, 30),
+			RecurrenceRule: "RRULE:FREQ=DAILY;COUNT=5"}, []*store.Attendee{
+			{Email: "me@example.com", IsSelf: true, ResponseStatus: "accepted"},
+			{Email: "dana@example.com", DisplayName: "Dana", ResponseStatus: "accepted"},
+		}},
+		{&store.Event{GoogleEventID: "review", Summary: "Design review", Location: "Room 4", StartTime: at(12, 14, 0), EndTime: at(12, 15, 0)}, []*store.Attendee{
+			{Email: "me@example.com", IsSelf: true, ResponseStatus: "accepted"},
+			{Email: "dana@example.com", DisplayName: "Dana", ResponseStatus: "accepted"},
+			{Email: "lee@example.com", ResponseStatus: "declined"},
+		}},
+		{&store.Event{GoogleEventID: "skipped", Summary: "All hands", StartTime: at(12, 16, 0), EndTime: at(12, 17, 0)}, []*store.Attendee{
+			{Email: "me@example.com", IsSelf: true, ResponseStatus: "declined"},
+			{Email: "ceo@example.com", ResponseStatus: "accepted"},
+		}},
+		{&store.Event{GoogleEventID: "tomorrow", Summary: "Tomorrow", StartTime: at(13, 10, 0), EndTime: at(13, 11, 0)}, nil},
+	}
+	for _, e := range events {
+		e.event.SourceID, e.event.CalendarID = src.ID, calID
+		id, err := s.UpsertEvent(e.event)
+		if err != nil {
+			t.Fatalf("upsert %s: %v", e.event.GoogleEventID, err)
+		}
+		if err := s.ReplaceAttendees(id, e.attendees); err != nil {
+			t.Fatalf("attendees %s: %v", e.event.GoogleEventID, err)
+		}
+	}
+
+	day, err := Build(s, date)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if len(day.Events) != 3 {
+		t.Fatalf("got %d events, want 3", len(day.Events))
+	}
+	if !day.Events[0].Recurring || day.Events[0].Calendar != "Work" || day.Events[0].Account != "me@example.com" {
+		t.Errorf("standup = %+v, want recurring on Work", day.Events[0])
+	}
+	if day.Hours != 1.5 {
+		t.Errorf("hours = %v, want 1.5 (declined events excluded)", day.Hours)
+	}
+
+	tmpl, err := Parse("day", DefaultTemplate, render.ISO)
+	if err != nil {
+		t.Fatalf("parse default template: %v", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, day); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	for _, want := range []string{
+		"# 2024-06-12 (Wednesday)",
+		"- 09:00-09:30 Standup",
+		"- 14:00-15:00 Design review @ Room 4",
+		"- 16:00-17:00 All hands (declined)",
+		"1.5 hours in meetings.",
+		"- Dana <dana@example.com> (2 meetings)",
+		"- ceo@example.com\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, b.String())
+		}
+	}
+	if strings.Contains(b.String(), "lee@example.com") {
+		t.Error("declined attendee listed among people")
+	}
+
+	if _, err := Parse("bad", "{{.Events", render.ISO); err == nil {
+		t.Error("expected parse error")
+	}
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Default holds calvault's metrics below; the daemon serves it when
+// [daemon] metrics_listen is set.
+var Default = NewRegistry()
+
+// durationBuckets suit API requests (tenths of a second) through full
+// syncs of large calendars (many minutes), in seconds.
+var durationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600}
+
+// waitBuckets suit rate limiter waits, from a few milliseconds to the
+// seconds a slow per-calendar limit imposes.
+var waitBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// calvault's metrics. Accounts are labelled by email, providers as google
+// or microsoft.
+var (
+	APIRequests = Default.Counter("calvault_api_requests_total",
+		"Calendar API requests, by provider and HTTP status code (0 for requests that got no response).",
+		"provider", "code")
+	APIRequestDuration = Default.Histogram("calvault_api_request_duration_seconds",
+		"Time calendar API requests took.", durationBuckets, "provider")
+	RateLimitWaits = Default.Counter("calvault_rate_limit_waits_total",
+		"Requests that waited for the client-side rate limiter.")
+	RateLimitWaitSeconds = Default.Counter("calvault_rate_limit_wait_seconds_total",
+		"Time spent waiting for the client-side rate limiter.")
+	RateLimitWaitDuration = Default.Histogram("calvault_rate_limit_wait_duration_seconds",
+		"How long requests that waited for the client-side rate limiter waited.", waitBuckets)
+
+	Syncs = Default.Counter("calvault_syncs_total",
+		"Account syncs, by account, kind (full or incremental) and result (ok, error, or deferred once the daily request budget was spent).",
+		"account", "kind", "result")
+	SyncDuration = Default.Histogram("calvault_sync_duration_seconds",
+		"Time account syncs took.", durationBuckets, "account", "kind")
+	LastSuccessfulSync = Default.Gauge("calvault_last_successful_sync_timestamp_seconds",
+		"Unix time an account's last successful sync finished.", "account")
+	CalendarSyncErrors = Default.Counter("calvault_calendar_sync_errors_total",
+		"Calendars that failed to sync while the rest of their account synced.", "account")
+	EventsUpserted = Default.Counter("calvault_events_upserted_total",
+		"Events written by syncs, by account and result (added or updated).", "account", "result")
+	EventsDeleted = Default.Counter("calvault_events_deleted_total",
+		"Events deleted by syncs.", "account")
+)
+
+// Transport wraps next (http.DefaultTransport if nil) to count a
+// provider's API requests in APIRequests and APIRequestDuration.
+func Transport(provider string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		APIRequestDuration.Observe(time.Since(start).Seconds(), provider)
+		code := 0
+		if err == nil {
+			code = resp.StatusCode
+		}
+		APIRequests.Inc(provider, strconv.Itoa(code))
+		return resp, err
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
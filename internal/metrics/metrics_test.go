@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+	requests := r.Counter("test_requests_total", "Requests.", "provider", "code")
+	last := r.Gauge("test_last_sync_seconds", "Last sync.\nWith a newline.", "account")
+	duration := r.Histogram("test_duration_seconds", "Durations.", []float64{0.5, 1})
+	r.Counter("test_unused_total", "Never incremented.")
+
+	requests.Inc("microsoft", "429")
+	requests.Inc("google", "200")
+	requests.Add(2, "google", "200")
+	last.Set(100, `me"@example.com`)
+	last.Set(1700000000, `me"@example.com`)
+	for _, v := range []float64{0.2, 0.5, 0.7, 3} {
+		duration.Observe(v)
+	}
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	want := `# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{provider="google",code="200"} 3
+test_requests_total{provider="microsoft",code="429"} 1
+# HELP test_last_sync_seconds Last sync.\nWith a newline.
+# TYPE test_last_sync_seconds gauge
+test_last_sync_seconds{account="me\"@example.com"} 1.7e+09
+# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.5"} 2
+test_duration_seconds_bucket{le="1"} 3
+test_duration_seconds_bucket{le="+Inf"} 4
+test_duration_seconds_sum 4.4
+test_duration_seconds_count 4
+`
+	if b.String() != want {
+		t.Errorf("exposition =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestRegistry_Misuse(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(r *Registry)
+	}{
+		{"duplicate name", func(r *Registry) {
+			r.Counter("x_total", "")
+			r.Gauge("x_total", "")
+		}},
+		{"wrong label count", func(r *Registry) { r.Counter("x_total", "", "account").Inc() }},
+		{"negative counter", func(r *Registry) { r.Counter("x_total", "").Add(-1) }},
+		{"unsorted buckets", func(r *Registry) { r.Histogram("x_seconds", "", []float64{2, 1}) }},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: no panic", tt.name)
+				}
+			}()
+			tt.fn(NewRegistry())
+		}()
+	}
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: Transport("test", nil)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	rec := httptest.NewRecorder()
+	Default.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		`calvault_api_requests_total{provider="test",code="429"} 1`,
+		`calvault_api_request_duration_seconds_count{provider="test"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics missing %s:\n%s", line, body)
+		}
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("content type = %q", ct)
+	}
+}
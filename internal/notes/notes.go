@@ -0,0 +1,180 @@
+// Package notes links meeting note files to the events they're about,
+// matching notes named by date and title (2024-06-12-standup.md) to the
+// events on that day.
+package notes
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// Extensions are the file types treated as notes.
+var Extensions = []string{".md", ".markdown", ".txt", ".org"}
+
+// stopWords don't count towards a match.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "of": true, "with": true,
+	"meeting": true, "notes": true, "note": true,
+}
+
+// ParseName reads the date and title words from a note's file name, such
+// as 2024-06-12-standup.md. ok is false if the name doesn't start with a
+// date. The date is midnight in loc.
+func ParseName(name string, loc *time.Location) (day time.Time, words []string, ok bool) {
+	name = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	if len(name) < len("2006-01-02") {
+		return time.Time{}, nil, false
+	}
+	day, err := time.ParseInLocation("2006-01-02", name[:10], loc)
+	if err != nil {
+		return time.Time{}, nil, false
+	}
+	return day, titleWords(name[10:]), true
+}
+
+// titleWords splits a title or file name into lowercase words, leaving out
+// stop words.
+func titleWords(s string) []string {
+	var words []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !stopWords[w] {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// Best picks the candidate whose title shares the most words with the
+// note's. It returns nil if none shares a word, and nil and true if several
+// events tie.
+func Best(candidates []*store.NoteCandidate, words []string) (best *store.NoteCandidate, tied bool) {
+	bestScore := 0
+	for _, c := range candidates {
+		title := map[string]bool{}
+		for _, w := range titleWords(c.Summary) {
+			title[w] = true
+		}
+		score := 0
+		for _, w := range words {
+			if title[w] {
+				score++
+			}
+		}
+		switch {
+		case score == 0 || score < bestScore:
+		case score > bestScore:
+			best, bestScore, tied = c, score, false
+		case c.EventID != best.EventID:
+			tied = true
+		}
+	}
+	if tied {
+		return nil, true
+	}
+	return best, false
+}
+
+// Link is a note matched to an event.
+type Link struct {
+	Path    string
+	EventID int64
+	Summary string
+	Start   time.Time
+}
+
+// Summary counts the work of a Match.
+type Summary struct {
+	Notes     int     // Dated note files found
+	Linked    []*Link // Newly linked (or, on a dry run, to be linked)
+	Unmatched int     // Notes no event on their day matched
+	Ambiguous int     // Notes several events matched equally well
+	Skipped   int     // Notes already linked
+}
+
+// Match walks dir for dated notes that aren't linked yet and links each
+// to the event on its day, in loc, whose title matches best. With dryRun,
+// nothing is stored.
+func Match(s *store.Store, dir string, loc *time.Location, dryRun bool) (*Summary, error) {
+	summary := &Summary{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isNote(path) {
+			return nil
+		}
+		day, words, ok := ParseName(path, loc)
+		if !ok {
+			return nil
+		}
+		summary.Notes++
+
+		path, err = filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		linked, err := s.NoteLinked(path)
+		if err != nil {
+			return err
+		}
+		if linked {
+			summary.Skipped++
+			return nil
+		}
+
+		candidates, err := s.NoteCandidates(day, day.AddDate(0, 0, 1))
+		if err != nil {
+			return err
+		}
+		best, tied := Best(candidates, words)
+		switch {
+		case tied:
+			summary.Ambiguous++
+			return nil
+		case best == nil:
+			summary.Unmatched++
+			return nil
+		}
+
+		if !dryRun {
+			note := &store.EventNote{EventID: best.EventID, Path: path, Matched: true}
+			if best.Occurrence {
+				note.Occurrence = sql.NullTime{Time: best.Start, Valid: true}
+			}
+			if _, err := s.LinkNote(note); err != nil {
+				return err
+			}
+		}
+		summary.Linked = append(summary.Linked, &Link{Path: path, EventID: best.EventID, Summary: best.Summary, Start: best.Start})
+		return nil
+	})
+	if err != nil {
+		return summary, fmt.Errorf("match notes in %s: %w", dir, err)
+	}
+	return summary, nil
+}
+
+func isNote(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package notes
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		name  string
+		day   string
+		words string
+		ok    bool
+	}{
+		{"2024-06-12-standup.md", "2024-06-12", "standup", true},
+		{"/notes/2024-06-12 Design Review notes.md", "2024-06-12", "design review", true},
+		{"2024-06-12_1-1-with-Dana.txt", "2024-06-12", "1 1 dana", true},
+		{"2024-06-12.md", "2024-06-12", "", true},
+		{"2024-13-01-bad.md", "", "", false},
+		{"standup.md", "", "", false},
+		{"todo", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day, words, ok := ParseName(tt.name, time.UTC)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if got := day.Format("2006-01-02"); got != tt.day {
+				t.Errorf("day = %s, want %s", got, tt.day)
+			}
+			if got := strings.Join(words, " "); got != tt.words {
+				t.Errorf("words = %q, want %q", got, tt.words)
+			}
+		})
+	}
+}
+
+func TestBest(t *testing.T) {
+	candidates := []*store.NoteCandidate{
+		{EventID: 1, Summary: "Daily Standup"},
+		{EventID: 2, Summary: "Design review: checkout"},
+		{EventID: 3, Summary: "Review budget"},
+	}
+	tests := []struct {
+		words string
+		want  int64
+		tied  bool
+	}{
+		{"standup", 1, false},
+		{"design review", 2, false},
+		{"review", 0, true},
+		{"budget review", 3, false},
+		{"offsite", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.words, func(t *testing.T) {
+			best, tied := Best(candidates, strings.Fields(tt.words))
+			var got int64
+			if best != nil {
+				got = best.EventID
+			}
+			if got != tt.want || tied != tt.tied {
+				t.Errorf("Best = %d, %v; want %d, %v", got, tied, tt.want, tt.tied)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	at := func(day, hour int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2024, 6, day, hour, 0, 0, 0, time.UTC), Valid: true}
+	}
+	src, _ := s.GetOrCreateSource("me@example.com")
+	cal, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: "primary"})
+	standup, _ := s.UpsertEvent(&store.Event{SourceID: src.ID, CalendarID: cal, GoogleEventID: "standup", Summary: "Daily Standup",
+		StartTime: at(10, 9), RecurrenceRule: "RRULE:FREQ=DAILY"})
+	review, _ := s.UpsertEvent(&store.Event{SourceID: src.ID, CalendarID: cal, GoogleEventID: "review", Summary: "Design review", StartTime: at(12, 14)})
+	_, _ = s.UpsertEvent(&store.Event{SourceID: src.ID, CalendarID: cal, GoogleEventID: "budget", Summary: "Budget review", StartTime: at(12, 16)})
+	err = s.ReplaceEventInstances([]*store.EventInstance{
+		{SourceID: src.ID, RecurringEventID: "standup", SeriesID: sql.NullInt64{Int64: standup, Valid: true}, EventID: standup, Start: at(12, 9), Expanded: true},
+	})
+	if err != nil {
+		t.Fatalf("instances: %v", err)
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{
+		"2024-06-12-standup.md",
+		"2024-06-12-design-review.md",
+		"2024-06-12-review.md",   // Ambiguous
+		"2024-06-13-standup.md",  // No instance stored that day
+		"2024-06-12-budget.docx", // Not a note
+		"ideas.md",               // Not dated
+		".trash/2024-06-12-design-review.md",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("notes"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dry, err := Match(s, dir, time.UTC, true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if len(dry.Linked) != 2 {
+		t.Errorf("dry run linked %d notes, want 2", len(dry.Linked))
+	}
+	if linked, _ := s.NoteLinked(filepath.Join(dir, "2024-06-12-standup.md")); linked {
+		t.Error("dry run stored a link")
+	}
+
+	summary, err := Match(s, dir, time.UTC, false)
+	if err != nil {
+		t.Fatalf("match: %v", err)
+	}
+	if summary.Notes != 4 || len(summary.Linked) != 2 || summary.Ambiguous != 1 || summary.Unmatched != 1 {
+		t.Errorf("summary = %d notes, %d linked, %d ambiguous, %d unmatched; want 4, 2, 1, 1",
+			summary.Notes, len(summary.Linked), summary.Ambiguous, summary.Unmatched)
+	}
+	notes, _ := s.ListNotes(standup)
+	if len(notes) != 1 || !notes[0].Matched || !notes[0].Occurrence.Valid || !notes[0].Occurrence.Time.Equal(at(12, 9).Time) {
+		t.Errorf("standup notes = %+v, want the 12 June occurrence", notes)
+	}
+	if notes, _ := s.ListNotes(review); len(notes) != 1 || notes[0].Occurrence.Valid {
+		t.Errorf("review notes = %+v, want one without an occurrence", notes)
+	}
+
+	again, err := Match(s, dir, time.UTC, false)
+	if err != nil {
+		t.Fatalf("rematch: %v", err)
+	}
+	if len(again.Linked) != 0 || again.Skipped != 2 {
+		t.Errorf("rematch linked %d and skipped %d, want 0 and 2", len(again.Linked), again.Skipped)
+	}
+}
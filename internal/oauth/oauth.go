@@ -1,4 +1,5 @@
-// Package oauth provides OAuth2 authentication flows for Google Calendar.
+// Package oauth provides OAuth2 authentication flows for Google Calendar
+// and Microsoft 365.
 package oauth
 
 import (
@@ -7,9 +8,12 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -19,6 +23,7 @@ import (
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/microsoft"
 )
 
 // Scopes for calvault operations (read-only calendar access).
@@ -26,11 +31,21 @@ var Scopes = []string{
 	"https://www.googleapis.com/auth/calendar.readonly",
 }
 
+// MicrosoftScopes for Microsoft Graph (read-only calendar access).
+// offline_access is required to receive a refresh token.
+var MicrosoftScopes = []string{
+	"offline_access",
+	"User.Read",
+	"Calendars.Read",
+}
+
 // Manager handles OAuth2 token acquisition and storage.
 type Manager struct {
 	config    *oauth2.Config
 	tokensDir string
+	tokens    tokenStore
 	logger    *slog.Logger
+	microsoft bool // Use the standard device flow and PKCE
 }
 
 // NewManager creates an OAuth manager from client secrets.
@@ -52,10 +67,32 @@ func NewManager(clientSecretsPath, tokensDir string, logger *slog.Logger) (*Mana
 	return &Manager{
 		config:    config,
 		tokensDir: tokensDir,
+		tokens:    newTokenStore(),
 		logger:    logger,
 	}, nil
 }
 
+// NewMicrosoftManager creates an OAuth manager for Microsoft identity platform
+// accounts. Desktop app registrations are public clients, so no secret is
+// needed; an empty tenant means "common".
+func NewMicrosoftManager(clientID, tenant, tokensDir string, logger *slog.Logger) *Manager {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Manager{
+		config: &oauth2.Config{
+			ClientID: clientID,
+			Endpoint: microsoft.AzureADEndpoint(tenant),
+			Scopes:   MicrosoftScopes,
+		},
+		tokensDir: tokensDir,
+		tokens:    newTokenStore(),
+		logger:    logger,
+		microsoft: true,
+	}
+}
+
 // TokenSource returns a token source for the given email.
 // If a valid token exists, it will be reused and auto-refreshed.
 func (m *Manager) TokenSource(ctx context.Context, email string) (oauth2.TokenSource, error) {
@@ -75,7 +112,7 @@ func (m *Manager) TokenSource(ctx context.Context, email string) (oauth2.TokenSo
 
 	if newToken.AccessToken != token.AccessToken {
 		if err := m.saveToken(email, newToken); err != nil {
-			m.logger.Warn("failed to save refreshed token", "email", email, "error", err)
+			m.logger.Warn("failed to save refreshed token", "account", email, "error", err)
 		}
 	}
 
@@ -94,7 +131,9 @@ func (m *Manager) Authorize(ctx context.Context, email string, headless bool) er
 	var token *oauth2.Token
 	var err error
 
-	if headless {
+	if headless && m.microsoft {
+		token, err = m.standardDeviceFlow(ctx)
+	} else if headless {
 		token, err = m.deviceFlow(ctx)
 	} else {
 		token, err = m.browserFlow(ctx)
@@ -150,7 +189,15 @@ func (m *Manager) browserFlow(ctx context.Context) (*oauth2.Token, error) {
 
 	// Generate auth URL
 	m.config.RedirectURL = "http://localhost:8089/callback"
-	authURL := m.config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce)
+	authOpts := []oauth2.AuthCodeOption{oauth2.AccessTypeOffline, oauth2.ApprovalForce}
+	var exchangeOpts []oauth2.AuthCodeOption
+	if m.microsoft {
+		// Public clients have no secret, so the code exchange is bound with PKCE
+		verifier := oauth2.GenerateVerifier()
+		authOpts = append(authOpts, oauth2.S256ChallengeOption(verifier))
+		exchangeOpts = []oauth2.AuthCodeOption{oauth2.VerifierOption(verifier)}
+	}
+	authURL := m.config.AuthCodeURL(state, authOpts...)
 
 	// Open browser
 	fmt.Printf("Opening browser for authorization...\n")
@@ -163,7 +210,7 @@ func (m *Manager) browserFlow(ctx context.Context) (*oauth2.Token, error) {
 	// Wait for callback
 	select {
 	case code := <-codeChan:
-		return m.config.Exchange(ctx, code)
+		return m.config.Exchange(ctx, code, exchangeOpts...)
 	case err := <-errChan:
 		return nil, err
 	case <-ctx.Done():
@@ -237,6 +284,28 @@ func (m *Manager) deviceFlow(ctx context.Context) (*oauth2.Token, error) {
 	return nil, fmt.Errorf("authorization timed out")
 }
 
+// standardDeviceFlow uses the RFC 8628 device authorization grant as
+// implemented by the oauth2 package (used for Microsoft accounts).
+func (m *Manager) standardDeviceFlow(ctx context.Context) (*oauth2.Token, error) {
+	resp, err := m.config.DeviceAuth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("request device code: %w", err)
+	}
+
+	fmt.Printf("\n")
+	fmt.Printf("To authorize calvault, visit:\n")
+	fmt.Printf("  %s\n\n", resp.VerificationURI)
+	fmt.Printf("And enter code: %s\n\n", resp.UserCode)
+	fmt.Printf("Waiting for authorization...\n")
+
+	token, err := m.config.DeviceAccessToken(ctx, resp)
+	if err != nil {
+		return nil, fmt.Errorf("wait for authorization: %w", err)
+	}
+	fmt.Printf("Authorization successful!\n")
+	return token, nil
+}
+
 // pollForToken polls the token endpoint during device flow.
 func (m *Manager) pollForToken(_ context.Context, deviceCode string) (*oauth2.Token, error) {
 	resp, err := http.PostForm("https://oauth2.googleapis.com/token", map[string][]string{
@@ -281,8 +350,11 @@ type tokenFile struct {
 
 // loadToken loads a saved token for the given email.
 func (m *Manager) loadToken(email string) (*oauth2.Token, error) {
-	path := m.tokenPath(email)
-	data, err := os.ReadFile(path)
+	return loadToken(m.tokens, m.tokenPath(email))
+}
+
+func loadToken(tokens tokenStore, path string) (*oauth2.Token, error) {
+	data, err := tokens.load(path)
 	if err != nil {
 		return nil, err
 	}
@@ -295,12 +367,144 @@ func (m *Manager) loadToken(email string) (*oauth2.Token, error) {
 	return &tf.Token, nil
 }
 
-// saveToken saves a token for the given email, including the scopes.
-func (m *Manager) saveToken(email string, token *oauth2.Token) error {
-	if err := os.MkdirAll(m.tokensDir, 0700); err != nil {
+// Token states reported by CheckToken.
+const (
+	TokenValid      = "valid"      // Refreshable, or an unexpired access token
+	TokenExpired    = "expired"    // Expired with no refresh token; run auth refresh
+	TokenMissing    = "missing"    // No saved token
+	TokenUnreadable = "unreadable" // Saved token can't be read or parsed
+)
+
+// CheckToken reports the state of the saved token for an email in
+// tokensDir without contacting the provider, so a revoked refresh token
+// still shows as valid.
+func CheckToken(tokensDir, email string) string {
+	return InspectToken(tokensDir, email).State
+}
+
+// TokenInfo describes a saved token.
+type TokenInfo struct {
+	State      string    // As reported by CheckToken
+	Expiry     time.Time // Of the access token; zero if unknown
+	HasRefresh bool      // A refresh token is saved
+	Scopes     []string  // Requested when the token was saved
+}
+
+// InspectToken reads the saved token for an email in tokensDir without
+// contacting the provider.
+func InspectToken(tokensDir, email string) *TokenInfo {
+	data, err := newTokenStore().load(tokenPath(tokensDir, email))
+	if errors.Is(err, os.ErrNotExist) {
+		return &TokenInfo{State: TokenMissing}
+	}
+	var tf tokenFile
+	if err == nil {
+		err = json.Unmarshal(data, &tf)
+	}
+	if err != nil {
+		return &TokenInfo{State: TokenUnreadable}
+	}
+	info := &TokenInfo{
+		State:      TokenValid,
+		Expiry:     tf.Expiry,
+		HasRefresh: tf.RefreshToken != "",
+		Scopes:     tf.Scopes,
+	}
+	if !info.HasRefresh && !tf.Token.Valid() {
+		info.State = TokenExpired
+	}
+	return info
+}
+
+// Refresh exchanges the saved refresh token for a new access token, as a
+// sync would once the current one expires, and saves the result. It
+// returns the scopes the provider reports as granted, if it reports them.
+// A *oauth2.RetrieveError means the provider refused, for example because
+// the token was revoked (invalid_grant).
+func (m *Manager) Refresh(ctx context.Context, email string) ([]string, error) {
+	token, err := m.loadToken(email)
+	if err != nil {
+		return nil, fmt.Errorf("no valid token for %s: %w", email, err)
+	}
+	if token.RefreshToken == "" {
+		return nil, fmt.Errorf("no refresh token for %s", email)
+	}
+
+	// A token with only the refresh token makes the source refresh
+	fresh, err := m.config.TokenSource(ctx, &oauth2.Token{RefreshToken: token.RefreshToken}).Token()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.saveToken(email, fresh); err != nil {
+		m.logger.Warn("failed to save refreshed token", "account", email, "error", err)
+	}
+	scope, _ := fresh.Extra("scope").(string)
+	return strings.Fields(scope), nil
+}
+
+// googleRevokeURL is Google's token revocation endpoint.
+const googleRevokeURL = "https://oauth2.googleapis.com/revoke"
+
+// ErrRevokeUnsupported is returned by Revoke for providers without a token
+// revocation endpoint, such as Microsoft.
+var ErrRevokeUnsupported = errors.New("provider doesn't support revoking tokens")
+
+// ErrTokenInvalid is returned by Revoke when the provider no longer knows
+// the token: it was already revoked or has expired.
+var ErrTokenInvalid = errors.New("token already revoked or expired")
+
+// Revoke revokes the saved token for an email at the provider, ending
+// calvault's access to the account: revoking the refresh token revokes the
+// whole grant. The saved token is left for the caller to delete.
+func (m *Manager) Revoke(ctx context.Context, email string) error {
+	if m.microsoft {
+		return ErrRevokeUnsupported
+	}
+	token, err := m.loadToken(email)
+	if err != nil {
+		return fmt.Errorf("no valid token for %s: %w", email, err)
+	}
+	revoke := token.RefreshToken
+	if revoke == "" {
+		revoke = token.AccessToken
+	}
+
+	form := url.Values{"token": {revoke}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, googleRevokeURL, strings.NewReader(form.Encode()))
+	if err != nil {
 		return err
 	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("revoke token: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var body struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	if body.Error == "invalid_token" {
+		return ErrTokenInvalid
+	}
+	if body.Description != "" {
+		return fmt.Errorf("revoke token: %s: %s", resp.Status, body.Description)
+	}
+	return fmt.Errorf("revoke token: %s", resp.Status)
+}
+
+// RequiredScopes returns the scopes syncing needs.
+func (m *Manager) RequiredScopes() []string {
+	return m.config.Scopes
+}
 
+// saveToken saves a token for the given email, including the scopes.
+func (m *Manager) saveToken(email string, token *oauth2.Token) error {
 	tf := tokenFile{
 		Token:  *token,
 		Scopes: m.config.Scopes,
@@ -311,25 +515,29 @@ func (m *Manager) saveToken(email string, token *oauth2.Token) error {
 		return err
 	}
 
-	path := m.tokenPath(email)
-	return os.WriteFile(path, data, 0600)
+	return m.tokens.save(m.tokenPath(email), data)
 }
 
 // tokenPath returns the path to the token file for an email.
 func (m *Manager) tokenPath(email string) string {
+	return tokenPath(m.tokensDir, email)
+}
+
+// tokenPath returns the path to the token file for an email in tokensDir.
+func tokenPath(tokensDir, email string) string {
 	// Sanitize email to prevent path traversal
 	safe := strings.ReplaceAll(email, "/", "_")
 	safe = strings.ReplaceAll(safe, "\\", "_")
 	safe = strings.ReplaceAll(safe, "..", "_")
 
 	// Ensure the final path is within tokensDir
-	path := filepath.Join(m.tokensDir, safe+".json")
+	path := filepath.Join(tokensDir, safe+".json")
 	cleanPath := filepath.Clean(path)
 
 	// Verify the path is still within tokensDir
-	if !strings.HasPrefix(cleanPath, filepath.Clean(m.tokensDir)) {
+	if !strings.HasPrefix(cleanPath, filepath.Clean(tokensDir)) {
 		// If path escapes tokensDir, use a hash-based fallback
-		return filepath.Join(m.tokensDir, fmt.Sprintf("%x.json", sha256.Sum256([]byte(email))))
+		return filepath.Join(tokensDir, fmt.Sprintf("%x.json", sha256.Sum256([]byte(email))))
 	}
 
 	return cleanPath
@@ -340,30 +548,79 @@ func scopesToString(scopes []string) string {
 	return strings.Join(scopes, " ")
 }
 
-// openBrowser opens the default browser to the given URL.
+// openBrowser opens the default browser to the given URL, trying each
+// candidate command that is installed.
 func openBrowser(url string) error {
-	var cmd *exec.Cmd
+	for _, args := range browserCommands(runtime.GOOS, url) {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		if err := exec.Command(args[0], args[1:]...).Start(); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("no browser launcher found on %s (set BROWSER)", runtime.GOOS)
+}
 
-	switch runtime.GOOS {
+// browserCommands lists commands that open url, in order of preference:
+// $BROWSER, then the platform's URL handler. rundll32 is used on Windows
+// rather than "cmd /c start", which splits URLs at '&'.
+func browserCommands(goos, url string) [][]string {
+	var cmds [][]string
+	for _, b := range filepath.SplitList(os.Getenv("BROWSER")) {
+		if b != "" {
+			cmds = append(cmds, []string{b, url})
+		}
+	}
+
+	switch goos {
 	case "darwin":
-		cmd = exec.Command("open", url)
-	case "linux":
-		cmd = exec.Command("xdg-open", url)
+		cmds = append(cmds, []string{"open", url})
 	case "windows":
-		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+		cmds = append(cmds, []string{"rundll32", "url.dll,FileProtocolHandler", url})
 	default:
-		return fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+		if isWSL() {
+			// Hand off to the Windows side
+			cmds = append(cmds,
+				[]string{"wslview", url},
+				[]string{"rundll32.exe", "url.dll,FileProtocolHandler", url})
+		}
+		cmds = append(cmds, []string{"xdg-open", url})
 	}
+	return cmds
+}
 
-	return cmd.Start()
+// isWSL reports whether we're running under Windows Subsystem for Linux.
+func isWSL() bool {
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	return err == nil && strings.Contains(strings.ToLower(string(release)), "microsoft")
 }
 
-// DeleteToken removes the token file for the given email.
+// DeleteToken removes the saved token for the given email.
 func (m *Manager) DeleteToken(email string) error {
-	path := m.tokenPath(email)
-	err := os.Remove(path)
-	if os.IsNotExist(err) {
+	return m.tokens.remove(m.tokenPath(email))
+}
+
+// DeleteToken removes the saved token for an email from tokensDir without
+// needing client credentials, e.g. when removing an account.
+func DeleteToken(tokensDir, email string) error {
+	return newTokenStore().remove(tokenPath(tokensDir, email))
+}
+
+// CopyToken copies the saved token for an email from one tokens directory to
+// another, e.g. when the home directory moves. It is a no-op if no token is
+// saved.
+func CopyToken(fromDir, toDir, email string) error {
+	tokens := newTokenStore()
+	data, err := tokens.load(tokenPath(fromDir, email))
+	if errors.Is(err, os.ErrNotExist) {
 		return nil
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	return tokens.save(tokenPath(toDir, email), data)
 }
@@ -0,0 +1,37 @@
+package oauth
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// tokenStore persists serialized tokens. Keys are token file paths, so a
+// store backed by something other than files still keeps accounts from
+// different homes and providers apart.
+type tokenStore interface {
+	load(path string) ([]byte, error) // os.ErrNotExist if missing
+	save(path string, data []byte) error
+	remove(path string) error // Missing tokens are not an error
+}
+
+// fileTokenStore keeps each token in a file readable only by the user.
+type fileTokenStore struct{}
+
+func (fileTokenStore) load(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (fileTokenStore) save(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+func (fileTokenStore) remove(path string) error {
+	err := os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package oauth
+
+// newTokenStore returns the platform's token store: files under the tokens
+// directory.
+func newTokenStore() tokenStore {
+	return fileTokenStore{}
+}
@@ -0,0 +1,135 @@
+//go:build windows
+
+package oauth
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// newTokenStore returns the platform's token store: Windows Credential
+// Manager, falling back to files.
+func newTokenStore() tokenStore {
+	return credentialTokenStore{}
+}
+
+var (
+	advapi32        = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	credMaxBlobSize         = 5 * 512 // CRED_MAX_CREDENTIAL_BLOB_SIZE
+	errorNotFound           = syscall.Errno(1168)
+)
+
+// credential mirrors the Win32 CREDENTIALW structure.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// credentialTokenStore keeps tokens as generic credentials named
+// "calvault:<token file path>". Tokens too large for a credential (some
+// Microsoft refresh tokens) and tokens saved by earlier versions stay in
+// files; a file token moves into Credential Manager the next time it is saved.
+type credentialTokenStore struct {
+	files fileTokenStore
+}
+
+func credentialTarget(path string) string {
+	return "calvault:" + path
+}
+
+func (s credentialTokenStore) load(path string) ([]byte, error) {
+	data, err := credRead(credentialTarget(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return s.files.load(path)
+	}
+	return data, err
+}
+
+func (s credentialTokenStore) save(path string, data []byte) error {
+	if len(data) > credMaxBlobSize {
+		if err := credDelete(credentialTarget(path)); err != nil {
+			return err
+		}
+		return s.files.save(path, data)
+	}
+	if err := credWrite(credentialTarget(path), data); err != nil {
+		return err
+	}
+	return s.files.remove(path)
+}
+
+func (s credentialTokenStore) remove(path string) error {
+	return errors.Join(credDelete(credentialTarget(path)), s.files.remove(path))
+}
+
+func credRead(target string) ([]byte, error) {
+	name, err := syscall.UTF16PtrFromString(target)
+	if err != nil {
+		return nil, err
+	}
+	var cred *credential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(name)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if errors.Is(err, errorNotFound) {
+			return nil, os.ErrNotExist
+		}
+		return nil, err
+	}
+	defer func() { _, _, _ = procCredFree.Call(uintptr(unsafe.Pointer(cred))) }()
+
+	return append([]byte(nil), unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)...), nil
+}
+
+func credWrite(target string, data []byte) error {
+	name, err := syscall.UTF16PtrFromString(target)
+	if err != nil {
+		return err
+	}
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         name,
+		CredentialBlobSize: uint32(len(data)),
+		Persist:            credPersistLocalMachine,
+	}
+	if len(data) > 0 {
+		cred.CredentialBlob = &data[0]
+	}
+	r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+func credDelete(target string) error {
+	name, err := syscall.UTF16PtrFromString(target)
+	if err != nil {
+		return err
+	}
+	r, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(name)), credTypeGeneric, 0)
+	if r == 0 && !errors.Is(err, errorNotFound) {
+		return err
+	}
+	return nil
+}
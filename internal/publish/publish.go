@@ -0,0 +1,246 @@
+// Package publish generates a static HTML page of meeting-load statistics
+// that is safe to share: aggregates only, with no event titles, attendees
+// or times of individual meetings.
+package publish
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+//go:embed index.html.tmpl
+var indexTemplate string
+
+// Stats is the data the page is built from.
+type Stats struct {
+	Title     string
+	Generated time.Time
+	Since     time.Time // First day covered
+	Until     time.Time // Last day covered
+	People    int       // Distinct people met with
+	Total     *store.LoadPeriod
+	Years     []*store.LoadPeriod
+	Months    []*store.LoadPeriod
+	Rhythm    *store.MeetingRhythm
+}
+
+// Build gathers the statistics of meetings starting in [since, until), in
+// since's location, using hours for the share of working time in meetings.
+func Build(s *store.Store, title string, since, until time.Time, hours store.WorkingHours) (*Stats, error) {
+	month := func(day time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+	}
+	months, err := s.GetLoadReport(since, until, hours, month)
+	if err != nil {
+		return nil, err
+	}
+	rhythm, err := s.GetMeetingRhythm(since, until)
+	if err != nil {
+		return nil, err
+	}
+	people, err := s.GetPeopleReport(since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{
+		Title:     title,
+		Generated: time.Now(),
+		Since:     since,
+		Until:     until.AddDate(0, 0, -1),
+		People:    len(people.People),
+		Total:     &store.LoadPeriod{Start: since},
+		Months:    months,
+		Rhythm:    rhythm,
+	}
+	for _, m := range months {
+		if n := len(stats.Years); n == 0 || stats.Years[n-1].Start.Year() != m.Start.Year() {
+			stats.Years = append(stats.Years, &store.LoadPeriod{Start: time.Date(m.Start.Year(), 1, 1, 0, 0, 0, 0, m.Start.Location())})
+		}
+		for _, p := range []*store.LoadPeriod{stats.Years[len(stats.Years)-1], stats.Total} {
+			p.Meetings += m.Meetings
+			p.Hours += m.Hours
+			p.WorkingHours += m.WorkingHours
+			p.BusyHours += m.BusyHours
+		}
+	}
+	return stats, nil
+}
+
+// Write renders the page into dir as index.html, creating dir if needed.
+// Dates and numbers follow locale l, and weekdays start on its first day of
+// the week.
+func Write(dir string, stats *Stats, l render.Locale) error {
+	tmpl, err := template.New("index").Funcs(template.FuncMap{
+		"date":   l.Date,
+		"number": func(n int) string { return l.Number(int64(n)) },
+		"hours": func(h float64) string {
+			if h < 10 {
+				return fmt.Sprintf("%.1f", h)
+			}
+			return l.Number(int64(h + 0.5))
+		},
+		"average": func(p *store.LoadPeriod) string {
+			if p.Meetings == 0 {
+				return "-"
+			}
+			return fmt.Sprintf("%.0f min", p.AverageMinutes())
+		},
+		"share": func(p *store.LoadPeriod) string {
+			if p.WorkingHours == 0 {
+				return "-"
+			}
+			return fmt.Sprintf("%.0f%%", 100*p.BusyShare())
+		},
+		"year": func(p *store.LoadPeriod) int { return p.Start.Year() },
+	}).Parse(indexTemplate)
+	if err != nil {
+		return fmt.Errorf("parse page template: %w", err)
+	}
+
+	data := struct {
+		*Stats
+		MonthlyHours, MonthlyShare, YearlyHours, Weekdays, HoursOfDay template.HTML
+	}{
+		Stats:        stats,
+		MonthlyHours: monthlyChart(stats.Months, "h", func(p *store.LoadPeriod) float64 { return p.Hours }),
+		MonthlyShare: monthlyChart(stats.Months, "%", func(p *store.LoadPeriod) float64 { return 100 * p.BusyShare() }),
+		YearlyHours:  yearlyChart(stats.Years),
+		Weekdays:     weekdayChart(stats.Rhythm, l.WeekStart),
+		HoursOfDay:   hourChart(stats.Rhythm),
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return fmt.Errorf("render page: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("write page: %w", err)
+	}
+	return nil
+}
+
+// bar is one bar of a chart.
+type bar struct {
+	Value float64
+	Label string // Axis label, or empty
+	Tip   string // Hover text
+}
+
+// Chart geometry, in SVG user units.
+const (
+	chartWidth  = 720
+	chartHeight = 200
+	chartTop    = 16
+	chartBottom = 24 // Room for axis labels
+	chartLeft   = 40 // Room for the scale
+)
+
+// barChart draws bars as an inline SVG, scaled to the largest value, with
+// unit after the scale's numbers.
+func barChart(bars []bar, unit string) template.HTML {
+	if len(bars) == 0 {
+		return ""
+	}
+	top := 0.0
+	for _, b := range bars {
+		top = max(top, b.Value)
+	}
+	if top == 0 {
+		top = 1
+	}
+	plot := float64(chartHeight - chartTop - chartBottom)
+	step := float64(chartWidth-chartLeft) / float64(len(bars))
+	gap := min(step*0.2, 4)
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg viewBox="0 0 %d %d" role="img" class="chart">`, chartWidth, chartHeight)
+	for _, f := range []float64{0, 0.5, 1} {
+		y := float64(chartTop) + plot*(1-f)
+		fmt.Fprintf(&svg, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" class="grid"/>`, chartLeft, chartWidth, y, y)
+		fmt.Fprintf(&svg, `<text x="%d" y="%.1f" class="scale">%s%s</text>`, chartLeft-6, y+4, formatScale(top*f), unit)
+	}
+	for i, b := range bars {
+		x := float64(chartLeft) + float64(i)*step
+		h := plot * b.Value / top
+		fmt.Fprintf(&svg, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f"><title>%s</title></rect>`,
+			x+gap/2, float64(chartTop)+plot-h, step-gap, h, template.HTMLEscapeString(b.Tip))
+		if b.Label != "" {
+			fmt.Fprintf(&svg, `<text x="%.1f" y="%d" class="label">%s</text>`, x+step/2, chartHeight-6, template.HTMLEscapeString(b.Label))
+		}
+	}
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
+
+// formatScale formats a scale value without needless decimals.
+func formatScale(v float64) string {
+	if v >= 10 || v == float64(int(v)) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.1f", v)
+}
+
+// monthlyChart charts value per month. Up to two years, every month is
+// labelled; beyond, only the years.
+func monthlyChart(months []*store.LoadPeriod, unit string, value func(*store.LoadPeriod) float64) template.HTML {
+	bars := make([]bar, len(months))
+	for i, m := range months {
+		v := value(m)
+		bars[i] = bar{Value: v, Tip: fmt.Sprintf("%s: %.0f%s", m.Start.Format("Jan 2006"), v, unit)}
+		newYear := i == 0 || m.Start.Month() == time.January
+		switch {
+		case len(months) <= 24 && newYear:
+			bars[i].Label = m.Start.Format("Jan 06")
+		case len(months) <= 24:
+			bars[i].Label = m.Start.Format("Jan")
+		case newYear:
+			bars[i].Label = m.Start.Format("2006")
+		}
+	}
+	return barChart(bars, unit)
+}
+
+// yearlyChart charts meeting hours per year.
+func yearlyChart(years []*store.LoadPeriod) template.HTML {
+	bars := make([]bar, len(years))
+	for i, y := range years {
+		bars[i] = bar{Value: y.Hours, Label: y.Start.Format("2006"), Tip: fmt.Sprintf("%s: %.0fh in %d meetings", y.Start.Format("2006"), y.Hours, y.Meetings)}
+	}
+	return barChart(bars, "h")
+}
+
+// weekdayChart charts meetings per weekday, starting on weekStart.
+func weekdayChart(r *store.MeetingRhythm, weekStart time.Weekday) template.HTML {
+	bars := make([]bar, 7)
+	for i := range bars {
+		day := (weekStart + time.Weekday(i)) % 7
+		n := r.Weekdays[day]
+		bars[i] = bar{Value: float64(n), Label: day.String()[:3], Tip: fmt.Sprintf("%s: %d meetings", day, n)}
+	}
+	return barChart(bars, "")
+}
+
+// hourChart charts meetings per starting hour, labelling every third hour.
+func hourChart(r *store.MeetingRhythm) template.HTML {
+	bars := make([]bar, 24)
+	for h := range bars {
+		n := r.Hours[h]
+		bars[h] = bar{Value: float64(n), Tip: fmt.Sprintf("%02d:00: %d meetings", h, n)}
+		if h%3 == 0 {
+			bars[h].Label = fmt.Sprintf("%02d", h)
+		}
+	}
+	return barChart(bars, "")
+}
@@ -0,0 +1,102 @@
+package publish
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/salman1993/calvault/internal/render"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+func TestBuildAndWrite(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	at := func(year int, month time.Month, day, hour int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(year, month, day, hour, 0, 0, 0, time.UTC), Valid: true}
+	}
+	src, _ := s.GetOrCreateSource("me@example.com")
+	cal, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: "primary"})
+	meetings := []struct {
+		id         string
+		start, end sql.NullTime
+	}{
+		{"a", at(2023, 11, 6, 10), at(2023, 11, 6, 11)}, // Monday
+		{"b", at(2024, 1, 9, 14), at(2024, 1, 9, 16)},   // Tuesday
+		{"c", at(2024, 1, 10, 14), at(2024, 1, 10, 15)}, // Wednesday
+	}
+	for _, m := range meetings {
+		id, err := s.UpsertEvent(&store.Event{SourceID: src.ID, CalendarID: cal, GoogleEventID: m.id,
+			Summary: "Secret project " + m.id, StartTime: m.start, EndTime: m.end})
+		if err != nil {
+			t.Fatalf("upsert %s: %v", m.id, err)
+		}
+		if err := s.ReplaceAttendees(id, []*store.Attendee{{Email: "dana@example.com", DisplayName: "Dana"}}); err != nil {
+			t.Fatalf("attendees: %v", err)
+		}
+	}
+
+	first, last, err := s.MeetingRange()
+	if err != nil {
+		t.Fatalf("meeting range: %v", err)
+	}
+	if !first.Equal(at(2023, 11, 6, 10).Time) || !last.Equal(at(2024, 1, 10, 14).Time) {
+		t.Errorf("meeting range = %v to %v", first, last)
+	}
+
+	stats, err := Build(s, "My meetings", time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), store.DefaultWorkingHours)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if stats.Total.Meetings != 3 || stats.Total.Hours != 4 || stats.People != 1 {
+		t.Errorf("totals = %d meetings, %.1fh, %d people; want 3, 4h, 1", stats.Total.Meetings, stats.Total.Hours, stats.People)
+	}
+	if len(stats.Months) != 3 || len(stats.Years) != 2 || stats.Years[1].Meetings != 2 {
+		t.Errorf("got %d months and %d years, want 3 and 2 (2 meetings in 2024)", len(stats.Months), len(stats.Years))
+	}
+	if stats.Rhythm.Weekdays[time.Tuesday] != 1 || stats.Rhythm.Hours[14] != 2 {
+		t.Errorf("rhythm = %+v", stats.Rhythm)
+	}
+
+	dir := filepath.Join(t.TempDir(), "site")
+	if err := Write(dir, stats, render.LocaleFor("en-US")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	page, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil {
+		t.Fatalf("read page: %v", err)
+	}
+	html := string(page)
+	for _, want := range []string{"<title>My meetings</title>", "<b>3</b>meetings", "<b>4.0</b>hours", "<td>2024</td><td>2</td><td>3.0</td><td>90 min</td>", "Jan 2024: 3h", "Tuesday: 1 meetings"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("page missing %q", want)
+		}
+	}
+	for _, secret := range []string{"Secret", "dana", "Dana", "me@example.com", "primary"} {
+		if strings.Contains(html, secret) {
+			t.Errorf("page leaks %q", secret)
+		}
+	}
+}
+
+func TestBarChart(t *testing.T) {
+	if got := barChart(nil, "h"); got != "" {
+		t.Errorf("empty chart = %q", got)
+	}
+	chart := string(barChart([]bar{{Value: 0, Label: "a", Tip: "<zero>"}, {Value: 3, Label: "b"}}, "h"))
+	for _, want := range []string{`height="0.0"`, `height="160.0"`, "&lt;zero&gt;", `class="scale">3h</text>`, `class="scale">1.5h</text>`} {
+		if !strings.Contains(chart, want) {
+			t.Errorf("chart missing %q:\n%s", want, chart)
+		}
+	}
+}
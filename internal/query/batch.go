@@ -0,0 +1,76 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// BatchParallelism is how many queries of a batch run at once.
+const BatchParallelism = 4
+
+// BatchQuery is one query of a batch: ad-hoc SQL or a named query, with
+// parameters bound as by ExecuteParams or ExecuteNamed.
+type BatchQuery struct {
+	ID     string            `json:"id,omitempty"` // Label echoed in the result
+	SQL    string            `json:"sql,omitempty"`
+	Named  string            `json:"named,omitempty"`
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// BatchResult is the outcome of one query of a batch: its result, or the
+// error that stopped it.
+type BatchResult struct {
+	ID string `json:"id,omitempty"`
+	*QueryResult
+	Error string `json:"error,omitempty"`
+}
+
+// ExecuteBatch runs several queries, up to BatchParallelism at a time, and
+// returns their results in the order given. A failing query doesn't stop
+// the others; its error is reported in its result. Each query is audited
+// and cached as if run on its own. Scoped sessions hold their views on a
+// single connection, so their queries run one after another.
+func (e *Executor) ExecuteBatch(ctx context.Context, queries []BatchQuery) []*BatchResult {
+	workers := min(BatchParallelism, len(queries))
+	if e.conn != nil {
+		workers = 1
+	}
+
+	results := make([]*BatchResult, len(queries))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				result, err := e.executeBatchQuery(ctx, queries[i])
+				results[i] = &BatchResult{ID: queries[i].ID, QueryResult: result}
+				if err != nil {
+					results[i].Error = err.Error()
+				}
+			}
+		}()
+	}
+	for i := range queries {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// executeBatchQuery runs one query of a batch.
+func (e *Executor) executeBatchQuery(ctx context.Context, q BatchQuery) (*QueryResult, error) {
+	switch {
+	case q.SQL != "" && q.Named != "":
+		return nil, fmt.Errorf("set either sql or named, not both")
+	case q.Named != "":
+		return e.ExecuteNamed(ctx, q.Named, q.Params)
+	case q.SQL != "":
+		return e.ExecuteParams(ctx, q.SQL, q.Params)
+	default:
+		return nil, fmt.Errorf("no sql or named query")
+	}
+}
@@ -0,0 +1,259 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithCache keeps successful results as files in dir for ttl, and serves
+// repeats of a query from them while the database's data is unchanged.
+// The executor also keeps them in memory, so one that lives on - an ask
+// session, a batch, or a server embedding the package for dashboards that
+// refresh every few seconds - answers repeats without reading files or
+// running aggregations again. A ttl of 0 disables caching.
+func WithCache(dir string, ttl time.Duration) ExecutorOption {
+	return func(e *Executor) {
+		e.cacheDir = dir
+		e.cacheTTL = ttl
+	}
+}
+
+// cacheKey identifies a result: the query, its parameters, and everything
+// about the executor and database that changes what it returns.
+type cacheKey struct {
+	Database      string            `json:"database"`
+	Version       int64             `json:"version"` // data_version when run
+	QueryName     string            `json:"query_name,omitempty"`
+	SQL           string            `json:"sql"` // Whitespace normalized
+	Params        map[string]string `json:"params,omitempty"`
+	AllowlistOnly bool              `json:"allowlist_only,omitempty"`
+	Scope         string            `json:"scope,omitempty"`
+	Owner         string            `json:"owner,omitempty"`
+	Team          bool              `json:"team,omitempty"`
+	Viewer        string            `json:"viewer,omitempty"`
+	AsOf          time.Time         `json:"as_of,omitempty"`
+	MaxRows       int               `json:"max_rows"`
+	Limit         int               `json:"limit,omitempty"`
+	Offset        int               `json:"offset,omitempty"`
+}
+
+// cacheEntry is the file a result is cached in.
+type cacheEntry struct {
+	Stored time.Time    `json:"stored"`
+	Result *QueryResult `json:"result"`
+}
+
+// maxMemoryEntries caps the results an executor keeps in memory; past it,
+// they are all dropped.
+const maxMemoryEntries = 256
+
+// memoryCache holds the results of one data_version in memory. A write,
+// such as a completed sync, moves data_version on and drops them.
+type memoryCache struct {
+	mu      sync.Mutex
+	version int64
+	entries map[string]cacheEntry // By cache file name
+}
+
+// get returns a copy, marked Cached, of an unexpired result stored at
+// version under name.
+func (m *memoryCache) get(version int64, name string, ttl time.Duration) (*QueryResult, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[name]
+	if !ok || m.version != version || time.Since(entry.Stored) > ttl {
+		return nil, false
+	}
+	result := copyResult(entry.Result)
+	result.Cached = true
+	return result, true
+}
+
+// put stores a copy of a result of version under name, dropping results of
+// other versions.
+func (m *memoryCache) put(version int64, name string, entry cacheEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.entries == nil || m.version != version || len(m.entries) >= maxMemoryEntries {
+		m.version = version
+		m.entries = map[string]cacheEntry{}
+	}
+	entry.Result = copyResult(entry.Result)
+	m.entries[name] = entry
+}
+
+// copyResult copies a result down to its values, so callers changing the
+// rows they were handed can't change what the cache serves next.
+func copyResult(r *QueryResult) *QueryResult {
+	c := *r
+	c.Columns = append([]string(nil), r.Columns...)
+	c.Rows = make([][]interface{}, len(r.Rows))
+	for i, row := range r.Rows {
+		c.Rows[i] = make([]interface{}, len(row))
+		for j, v := range row {
+			if b, ok := v.([]byte); ok {
+				v = append([]byte(nil), b...)
+			}
+			c.Rows[i][j] = v
+		}
+	}
+	return &c
+}
+
+// cached returns the cached result of a query, or runs it with fn and caches
+// the result. Without a cache, or when the data version can't be read (a
+// database from before data_version), every query runs.
+func (e *Executor) cached(ctx context.Context, name, query string, params map[string]string, fn func() (*QueryResult, error)) (*QueryResult, error) {
+	if e.cacheDir == "" || e.cacheTTL <= 0 {
+		return fn()
+	}
+	var version int64
+	// Scoped sessions hide data_version behind an empty view
+	if err := e.queryRow(ctx, `SELECT version FROM main.data_version WHERE id = 1`).Scan(&version); err != nil {
+		return fn()
+	}
+
+	key := cacheKey{
+		Database:      e.dbPath,
+		Version:       version,
+		QueryName:     name,
+		SQL:           normalizeSQL(query),
+		Params:        params,
+		AllowlistOnly: e.allowlistOnly,
+		Scope:         e.scope,
+		Owner:         e.owner,
+		Team:          e.team,
+		Viewer:        e.viewer,
+		AsOf:          e.asOf,
+		MaxRows:       e.maxRows,
+		Limit:         e.limit,
+		Offset:        e.offset,
+	}
+	// encoding/json sorts map keys, so equal params give equal keys
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fn()
+	}
+	sum := sha256.Sum256(data)
+	file := hex.EncodeToString(sum[:]) + ".json"
+	path := filepath.Join(e.cacheDir, file)
+
+	if result, ok := e.memory.get(version, file, e.cacheTTL); ok {
+		return result, nil
+	}
+	if entry, ok := e.readCache(path); ok {
+		e.memory.put(version, file, entry)
+		result := *entry.Result
+		result.Cached = true
+		return &result, nil
+	}
+	result, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	entry := cacheEntry{Stored: time.Now(), Result: result}
+	e.memory.put(version, file, entry)
+	// A failed write only costs the next run a query
+	_ = e.writeCache(path, entry)
+	return result, nil
+}
+
+// readCache loads an unexpired cached result.
+func (e *Executor) readCache(path string) (cacheEntry, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cacheEntry{}, false
+	}
+	// Keep numbers as written: integers must not come back as floats
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var entry cacheEntry
+	if err := dec.Decode(&entry); err != nil || entry.Result == nil || time.Since(entry.Stored) > e.cacheTTL {
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+// writeCache stores a result, replacing the file atomically so concurrent
+// readers never see half of it, and removes expired entries.
+func (e *Executor) writeCache(path string, entry cacheEntry) error {
+	if err := os.MkdirAll(e.cacheDir, 0o700); err != nil {
+		return fmt.Errorf("create cache directory: %w", err)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encode result: %w", err)
+	}
+	tmp, err := os.CreateTemp(e.cacheDir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create cache file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write cache file: %w", err)
+	}
+	e.pruneCache()
+	return nil
+}
+
+// pruneCache removes cached results older than the TTL.
+func (e *Executor) pruneCache() {
+	entries, err := os.ReadDir(e.cacheDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if time.Since(info.ModTime()) > e.cacheTTL {
+			_ = os.Remove(filepath.Join(e.cacheDir, entry.Name()))
+		}
+	}
+}
+
+// normalizeSQL collapses runs of whitespace outside quoted strings and
+// identifiers, so queries differing only in layout share a cache entry.
+func normalizeSQL(query string) string {
+	var b strings.Builder
+	var quote rune
+	space := false
+	for _, r := range strings.TrimSpace(query) {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -4,16 +4,91 @@ package query
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// ErrNotAllowlisted is returned for ad-hoc SQL when the executor only
+// permits registered named queries.
+var ErrNotAllowlisted = errors.New("arbitrary SQL is disabled (allowlist mode): run a named query instead")
+
+// AuditEntry describes a query run through the executor.
+type AuditEntry struct {
+	Caller    string
+	QueryName string // Set for named queries
+	SQL       string
+	Params    map[string]string // Raw named query parameters
+	Duration  time.Duration
+	RowCount  int
+	Err       error
+}
+
+// AuditLogger records executed queries.
+type AuditLogger interface {
+	LogQuery(entry AuditEntry) error
+}
+
 // Executor executes read-only SQL queries.
 type Executor struct {
-	db *sql.DB
+	db            *sql.DB
+	dbPath        string
+	named         *Registry
+	allowlistOnly bool
+	audit         AuditLogger
+	auditMu       sync.Mutex // Serializes audit logging from batches
+	caller        string
+	scope         string        // Account identifier for row-level scoping
+	owner         string        // Team member whose accounts are in scope
+	team          bool          // Hide other members' private data
+	viewer        string        // Team member whose private data stays visible
+	asOf          time.Time     // Events as they were then; zero = now
+	conn          *sql.Conn     // Pinned connection holding the scoped views
+	maxRows       int           // Row cap; 0 = none
+	limit, offset int           // Page of rows returned
+	cacheDir      string        // Where results are cached; empty = no caching
+	cacheTTL      time.Duration // How long cached results are served
+	memory        memoryCache   // Cached results of the current data_version
+}
+
+// DefaultMaxRows caps the rows a query returns unless WithMaxRows changes it.
+const DefaultMaxRows = 10000
+
+// ExecutorOption configures the executor.
+type ExecutorOption func(*Executor)
+
+// WithNamedQueries sets the registry used by ExecuteNamed.
+func WithNamedQueries(r *Registry) ExecutorOption {
+	return func(e *Executor) {
+		e.named = r
+	}
+}
+
+// WithAllowlistOnly rejects ad-hoc SQL so that only named queries can run.
+func WithAllowlistOnly() ExecutorOption {
+	return func(e *Executor) {
+		e.allowlistOnly = true
+	}
+}
+
+// WithMaxRows caps the rows a query returns; 0 removes the cap.
+func WithMaxRows(n int) ExecutorOption {
+	return func(e *Executor) {
+		e.maxRows = n
+	}
+}
+
+// WithPage skips the first offset rows of each result and returns at most
+// limit rows after them (0 = up to the row cap).
+func WithPage(limit, offset int) ExecutorOption {
+	return func(e *Executor) {
+		e.limit = limit
+		e.offset = offset
+	}
 }
 
 // QueryResult holds the result of a query.
@@ -21,10 +96,23 @@ type QueryResult struct {
 	Columns  []string        `json:"columns"`
 	Rows     [][]interface{} `json:"rows"`
 	RowCount int             `json:"row_count"`
+	// Truncated is set when more rows followed those returned, cut off by
+	// the row cap or the page limit.
+	Truncated bool `json:"truncated"`
+	// Cached is set when the result was served from the cache (WithCache).
+	Cached bool `json:"cached,omitempty"`
+}
+
+// WithAuditLog records every executed query, attributed to caller.
+func WithAuditLog(logger AuditLogger, caller string) ExecutorOption {
+	return func(e *Executor) {
+		e.audit = logger
+		e.caller = caller
+	}
 }
 
 // NewExecutor creates a new query executor with read-only access.
-func NewExecutor(dbPath string) (*Executor, error) {
+func NewExecutor(dbPath string, opts ...ExecutorOption) (*Executor, error) {
 	// Open in read-only mode
 	db, err := sql.Open("sqlite3", dbPath+"?mode=ro")
 	if err != nil {
@@ -37,43 +125,162 @@ func NewExecutor(dbPath string) (*Executor, error) {
 		return nil, fmt.Errorf("ping database: %w", err)
 	}
 
-	return &Executor{db: db}, nil
+	e := &Executor{db: db, dbPath: dbPath, maxRows: DefaultMaxRows}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	if e.shadowed() {
+		if err := e.applyScope(context.Background()); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+
+	return e, nil
 }
 
 // Close closes the database connection.
 func (e *Executor) Close() error {
+	if e.conn != nil {
+		_ = e.conn.Close()
+	}
 	return e.db.Close()
 }
 
 // Execute runs a read-only SQL query with a timeout.
 func (e *Executor) Execute(ctx context.Context, query string) (*QueryResult, error) {
+	return e.ExecuteParams(ctx, query, nil)
+}
+
+// ExecuteParams runs an ad-hoc query with its :name placeholders bound to
+// params as SQL parameters. Keys may declare a type as name:type (see
+// ParamType); values are bound as strings otherwise. Every placeholder
+// must be supplied and every parameter used.
+func (e *Executor) ExecuteParams(ctx context.Context, query string, params map[string]string) (*QueryResult, error) {
+	start := time.Now()
+	result, err := e.cached(ctx, "", query, params, func() (*QueryResult, error) {
+		return e.execute(ctx, query, params)
+	})
+	e.logQuery(AuditEntry{SQL: query, Params: params}, start, result, err)
+	return result, err
+}
+
+// execute validates, binds and runs an ad-hoc query.
+func (e *Executor) execute(ctx context.Context, query string, params map[string]string) (*QueryResult, error) {
+	if e.allowlistOnly {
+		return nil, ErrNotAllowlisted
+	}
+	if err := validateQuery(query); err != nil {
+		return nil, err
+	}
+	if e.shadowed() && referencesMainSchema(sqlTokens(query)) {
+		return nil, fmt.Errorf("query references the main schema, which is not allowed for scoped or as-of queries")
+	}
+	args, err := bindPlaceholders(query, params)
+	if err != nil {
+		return nil, err
+	}
+	return e.run(ctx, query, args...)
+}
+
+// ExecuteNamed runs a registered named query, binding params by name.
+func (e *Executor) ExecuteNamed(ctx context.Context, name string, params map[string]string) (*QueryResult, error) {
+	start := time.Now()
+	entry := AuditEntry{QueryName: name, Params: params}
+	if e.named != nil {
+		if q, ok := e.named.Get(name); ok {
+			entry.SQL = q.SQL
+		}
+	}
+
+	result, err := e.cached(ctx, name, entry.SQL, params, func() (*QueryResult, error) {
+		return e.executeNamed(ctx, name, params)
+	})
+	e.logQuery(entry, start, result, err)
+	return result, err
+}
+
+// executeNamed binds parameters and runs a registered named query.
+func (e *Executor) executeNamed(ctx context.Context, name string, params map[string]string) (*QueryResult, error) {
+	if e.named == nil {
+		return nil, fmt.Errorf("no named queries registered")
+	}
+	q, ok := e.named.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown named query %q", name)
+	}
+
+	bound, err := q.bind(params)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, 0, len(bound))
+	for k, v := range bound {
+		args = append(args, sql.Named(k, v))
+	}
+
+	return e.run(ctx, q.SQL, args...)
+}
+
+// logQuery records an executed query to the audit log, if configured.
+// Audit failures never fail the query itself.
+func (e *Executor) logQuery(entry AuditEntry, start time.Time, result *QueryResult, err error) {
+	if e.audit == nil {
+		return
+	}
+	entry.Caller = e.caller
+	entry.Duration = time.Since(start)
+	entry.Err = err
+	if result != nil {
+		entry.RowCount = result.RowCount
+	}
+	e.auditMu.Lock()
+	defer e.auditMu.Unlock()
+	_ = e.audit.LogQuery(entry)
+}
+
+// validateQuery checks that a query is a single read-only SELECT, optionally
+// preceded by a WITH clause of common table expressions.
+func validateQuery(query string) error {
 	// Strip SQL comments and whitespace for validation
 	normalized := stripSQLComments(query)
 	normalizedUpper := strings.ToUpper(normalized)
-	if !strings.HasPrefix(normalizedUpper, "SELECT") {
-		return nil, fmt.Errorf("only SELECT queries allowed")
+	switch {
+	case strings.HasPrefix(normalizedUpper, "SELECT"):
+	case hasKeywordPrefix(normalizedUpper, "WITH"):
+		if hasWriteStatement(sqlTokens(query)) {
+			return fmt.Errorf("only SELECT queries allowed after WITH")
+		}
+	default:
+		return fmt.Errorf("only SELECT or WITH ... SELECT queries allowed")
 	}
 
 	// Reject dangerous patterns even in SELECT
 	lower := strings.ToLower(query)
 	dangerousPatterns := []string{
-		"into ",     // SELECT INTO
-		"attach ",   // ATTACH DATABASE
-		"detach ",   // DETACH DATABASE
-		"pragma ",   // PRAGMA commands
+		"into ",          // SELECT INTO
+		"attach ",        // ATTACH DATABASE
+		"detach ",        // DETACH DATABASE
+		"pragma ",        // PRAGMA commands
 		"load_extension", // Load extension
 	}
 	for _, pattern := range dangerousPatterns {
 		if strings.Contains(lower, pattern) {
-			return nil, fmt.Errorf("query contains forbidden pattern: %s", pattern)
+			return fmt.Errorf("query contains forbidden pattern: %s", pattern)
 		}
 	}
 
+	return nil
+}
+
+// run executes a validated query and converts the rows to a QueryResult.
+func (e *Executor) run(ctx context.Context, query string, args ...interface{}) (*QueryResult, error) {
 	// Add timeout
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	rows, err := e.db.QueryContext(ctx, query)
+	rows, err := e.query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -85,9 +292,22 @@ func (e *Executor) Execute(ctx context.Context, query string) (*QueryResult, err
 		return nil, fmt.Errorf("get columns: %w", err)
 	}
 
-	// Scan all rows
+	// Scan the page of rows, stopping at the cap
+	max := e.maxRows
+	if e.limit > 0 && (max == 0 || e.limit < max) {
+		max = e.limit
+	}
 	var results [][]interface{}
-	for rows.Next() {
+	truncated := false
+	for skipped := 0; rows.Next(); {
+		if skipped < e.offset {
+			skipped++
+			continue
+		}
+		if max > 0 && len(results) == max {
+			truncated = true
+			break
+		}
 		values := make([]interface{}, len(columns))
 		ptrs := make([]interface{}, len(columns))
 		for i := range values {
@@ -117,12 +337,39 @@ func (e *Executor) Execute(ctx context.Context, query string) (*QueryResult, err
 	}
 
 	return &QueryResult{
-		Columns:  columns,
-		Rows:     results,
-		RowCount: len(results),
+		Columns:   columns,
+		Rows:      results,
+		RowCount:  len(results),
+		Truncated: truncated,
 	}, nil
 }
 
+// query runs a statement on the pinned connection if there is one.
+func (e *Executor) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if e.conn != nil {
+		return e.conn.QueryContext(ctx, query, args...)
+	}
+	return e.db.QueryContext(ctx, query, args...)
+}
+
+// queryRow runs a single-row statement on the pinned connection if there
+// is one.
+func (e *Executor) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if e.conn != nil {
+		return e.conn.QueryRowContext(ctx, query, args...)
+	}
+	return e.db.QueryRowContext(ctx, query, args...)
+}
+
+// hasKeywordPrefix reports whether s starts with keyword as a whole word.
+func hasKeywordPrefix(s, keyword string) bool {
+	if !strings.HasPrefix(s, keyword) {
+		return false
+	}
+	rest := s[len(keyword):]
+	return rest == "" || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '('
+}
+
 // stripSQLComments removes SQL comments and leading whitespace for validation.
 func stripSQLComments(query string) string {
 	lines := strings.Split(query, "\n")
@@ -2,9 +2,14 @@ package query
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/salman1993/calvault/internal/store"
 )
@@ -173,3 +178,807 @@ func TestStripSQLComments(t *testing.T) {
 		}
 	}
 }
+
+func TestNormalizeSQL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"  SELECT\n\t1  ", "SELECT 1"},
+		{"SELECT  'a  b'  FROM \"x  y\"", "SELECT 'a  b' FROM \"x  y\""},
+		{"SELECT 'it''s'   ,  2", "SELECT 'it''s' , 2"},
+	}
+	for _, tt := range tests {
+		if got := normalizeSQL(tt.in); got != tt.want {
+			t.Errorf("normalizeSQL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExecutor_NamedQueries(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	registry := NewRegistry()
+	err := registry.Register(&NamedQuery{
+		Name:   "add",
+		SQL:    "SELECT :a + :b AS total, :label AS label",
+		Params: []Param{{Name: "a", Type: ParamInt}, {Name: "b", Type: ParamInt}, {Name: "label", Type: ParamString}},
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	exec, err := NewExecutor(dbPath, WithNamedQueries(registry), WithAllowlistOnly())
+	if err != nil {
+		t.Fatalf("new executor: %v", err)
+	}
+	defer func() { _ = exec.Close() }()
+
+	result, err := exec.ExecuteNamed(context.Background(), "add", map[string]string{"a": "2", "b": "3", "label": "x"})
+	if err != nil {
+		t.Fatalf("execute named: %v", err)
+	}
+	if result.RowCount != 1 || result.Rows[0][0] != int64(5) || result.Rows[0][1] != "x" {
+		t.Errorf("result rows = %v, want [[5 x]]", result.Rows)
+	}
+
+	// Ad-hoc SQL is rejected in allowlist mode
+	if _, err := exec.Execute(context.Background(), "SELECT 1"); !errors.Is(err, ErrNotAllowlisted) {
+		t.Errorf("expected ErrNotAllowlisted, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		params map[string]string
+	}{
+		{"unknown query", "missing", nil},
+		{"missing param", "add", map[string]string{"a": "1", "label": "x"}},
+		{"extra param", "add", map[string]string{"a": "1", "b": "2", "label": "x", "c": "3"}},
+		{"wrong type", "add", map[string]string{"a": "one", "b": "2", "label": "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := exec.ExecuteNamed(context.Background(), tt.query, tt.params); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestExecutor_AdHocParams(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	exec, err := NewExecutor(dbPath)
+	if err != nil {
+		t.Fatalf("new executor: %v", err)
+	}
+	defer func() { _ = exec.Close() }()
+
+	result, err := exec.ExecuteParams(context.Background(),
+		"SELECT :email AS email, ':email' AS literal, :n + 1 AS next -- :ignored",
+		map[string]string{"email": "boss@corp.com", "n:int": "41"})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if result.Rows[0][0] != "boss@corp.com" || result.Rows[0][1] != ":email" || result.Rows[0][2] != int64(42) {
+		t.Errorf("result rows = %v, want [[boss@corp.com :email 42]]", result.Rows)
+	}
+
+	// Values are bound, never spliced into the SQL
+	result, err = exec.ExecuteParams(context.Background(), "SELECT :s", map[string]string{"s": "x' OR '1'='1"})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if result.Rows[0][0] != "x' OR '1'='1" {
+		t.Errorf("bound value = %v", result.Rows[0][0])
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		params map[string]string
+	}{
+		{"missing param", "SELECT :a, :b", map[string]string{"a": "1"}},
+		{"unreferenced param", "SELECT :a", map[string]string{"a": "1", "b": "2"}},
+		{"param only in literal", "SELECT ':a'", map[string]string{"a": "1"}},
+		{"wrong type", "SELECT :a", map[string]string{"a:int": "one"}},
+		{"unknown type", "SELECT :a", map[string]string{"a:uuid": "1"}},
+		{"duplicate param", "SELECT :a", map[string]string{"a": "1", "a:int": "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := exec.ExecuteParams(context.Background(), tt.query, tt.params); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestExecutor_RowLimits(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// 25 rows: 1..25
+	const numbers = "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 25) SELECT i FROM n"
+	tests := []struct {
+		name          string
+		opts          []ExecutorOption
+		first, count  int
+		wantTruncated bool
+	}{
+		{"default cap", nil, 1, 25, false},
+		{"cap", []ExecutorOption{WithMaxRows(10)}, 1, 10, true},
+		{"cap equals rows", []ExecutorOption{WithMaxRows(25)}, 1, 25, false},
+		{"no cap", []ExecutorOption{WithMaxRows(0)}, 1, 25, false},
+		{"page", []ExecutorOption{WithPage(5, 10)}, 11, 5, true},
+		{"last page", []ExecutorOption{WithPage(5, 20)}, 21, 5, false},
+		{"offset only", []ExecutorOption{WithPage(0, 22)}, 23, 3, false},
+		{"offset past end", []ExecutorOption{WithPage(5, 30)}, 0, 0, false},
+		{"limit above cap", []ExecutorOption{WithMaxRows(3), WithPage(10, 0)}, 1, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec, err := NewExecutor(dbPath, tt.opts...)
+			if err != nil {
+				t.Fatalf("new executor: %v", err)
+			}
+			defer func() { _ = exec.Close() }()
+
+			result, err := exec.Execute(context.Background(), numbers)
+			if err != nil {
+				t.Fatalf("execute: %v", err)
+			}
+			if result.RowCount != tt.count || result.Truncated != tt.wantTruncated {
+				t.Fatalf("got %d rows (truncated %v), want %d (truncated %v)", result.RowCount, result.Truncated, tt.count, tt.wantTruncated)
+			}
+			if tt.count > 0 && result.Rows[0][0] != int64(tt.first) {
+				t.Errorf("first row = %v, want %d", result.Rows[0][0], tt.first)
+			}
+		})
+	}
+}
+
+func TestExecutor_Cache(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+	cacheDir := filepath.Join(filepath.Dir(dbPath), "cache")
+
+	run := func(query string, opts ...ExecutorOption) *QueryResult {
+		t.Helper()
+		exec, err := NewExecutor(dbPath, append([]ExecutorOption{WithCache(cacheDir, time.Hour)}, opts...)...)
+		if err != nil {
+			t.Fatalf("new executor: %v", err)
+		}
+		defer func() { _ = exec.Close() }()
+		result, err := exec.Execute(context.Background(), query)
+		if err != nil {
+			t.Fatalf("execute %q: %v", query, err)
+		}
+		return result
+	}
+
+	const count = "SELECT COUNT(*) AS n FROM events WHERE summary != 'a  b'"
+	if result := run(count); result.Cached {
+		t.Error("first run served from cache")
+	}
+	result := run("SELECT COUNT(*) AS n\n\tFROM events WHERE summary != 'a  b'  ")
+	if !result.Cached {
+		t.Error("repeat differing only in layout not served from cache")
+	}
+	if got := fmt.Sprint(result.Rows[0][0]); got != "0" || result.Columns[0] != "n" {
+		t.Errorf("cached result = %v %v, want n = 0", result.Columns, result.Rows)
+	}
+	if run("SELECT COUNT(*) AS n FROM events WHERE summary != 'a b'").Cached {
+		t.Error("query with a different string literal served from cache")
+	}
+	if run(count, WithMaxRows(5)).Cached {
+		t.Error("query with a different row cap served from cache")
+	}
+
+	// Writing to the archive invalidates cached results
+	s, err := store.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: "primary"})
+	_, _ = s.UpsertEvent(&store.Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "e1", Summary: "Planning"})
+	_ = s.RecordQueryAudit(&store.QueryAudit{ExecutedAt: time.Now(), Caller: "cli", SQL: "SELECT 1"})
+	_ = s.Close()
+
+	result = run(count)
+	if result.Cached || result.Rows[0][0] != int64(1) {
+		t.Errorf("after a write: cached %v, rows %v, want a fresh count of 1", result.Cached, result.Rows)
+	}
+	if !run(count).Cached {
+		t.Error("audit log write invalidated the cache")
+	}
+
+	exec, err := NewExecutor(dbPath, WithCache(cacheDir, time.Nanosecond))
+	if err != nil {
+		t.Fatalf("new executor: %v", err)
+	}
+	defer func() { _ = exec.Close() }()
+	if result, _ := exec.Execute(context.Background(), count); result.Cached {
+		t.Error("expired result served from cache")
+	}
+
+	// Explain neither serves nor stores cached results
+	exec, err = NewExecutor(dbPath, WithCache(cacheDir, time.Hour))
+	if err != nil {
+		t.Fatalf("new executor: %v", err)
+	}
+	defer func() { _ = exec.Close() }()
+	const summaries = "SELECT summary FROM events"
+	if _, err := exec.Explain(context.Background(), summaries); err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if run(summaries).Cached {
+		t.Error("Explain stored its result in the cache")
+	}
+}
+
+func TestExecutor_MemoryCache(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+	cacheDir := filepath.Join(filepath.Dir(dbPath), "cache")
+
+	exec, err := NewExecutor(dbPath, WithCache(cacheDir, time.Hour))
+	if err != nil {
+		t.Fatalf("new executor: %v", err)
+	}
+	defer func() { _ = exec.Close() }()
+	ctx := context.Background()
+
+	const count = "SELECT COUNT(*) AS n FROM events"
+	if result, err := exec.Execute(ctx, count); err != nil || result.Cached {
+		t.Fatalf("first run: cached %v, err %v", result != nil && result.Cached, err)
+	}
+	// Repeats come from memory, without the files
+	if err := os.RemoveAll(cacheDir); err != nil {
+		t.Fatalf("remove cache: %v", err)
+	}
+	result, err := exec.Execute(ctx, count)
+	if err != nil || !result.Cached || result.Rows[0][0] != int64(0) {
+		t.Fatalf("repeat = %+v, %v; want a cached count of 0", result, err)
+	}
+	// Changing a result handed out leaves the cached one as it was
+	result.Rows[0][0] = int64(99)
+	result, err = exec.Execute(ctx, count)
+	if err != nil || !result.Cached || result.Rows[0][0] != int64(0) {
+		t.Fatalf("repeat after changing a result = %+v, %v; want a cached count of 0", result, err)
+	}
+
+	// A sync writing to the archive drops them
+	s, err := store.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: "primary"})
+	_, _ = s.UpsertEvent(&store.Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "e1", Summary: "Planning"})
+	_ = s.Close()
+
+	result, err = exec.Execute(ctx, count)
+	if err != nil || result.Cached || result.Rows[0][0] != int64(1) {
+		t.Errorf("after a write = %+v, %v; want a fresh count of 1", result, err)
+	}
+}
+
+func TestExecutor_Batch(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	s, err := store.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	for _, email := range []string{"work@example.com", "home@example.com"} {
+		src, _ := s.GetOrCreateSource(email)
+		calID, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: "primary"})
+		_, _ = s.UpsertEvent(&store.Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "e1", Summary: email})
+	}
+	_ = s.Close()
+
+	registry := NewRegistry()
+	if err := registry.Register(&NamedQuery{Name: "double", SQL: "SELECT :n * 2", Params: []Param{{Name: "n", Type: ParamInt}}}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	logger := &recordingAuditLogger{}
+
+	batch := []BatchQuery{
+		{ID: "events", SQL: "SELECT COUNT(*) FROM events"},
+		{ID: "bad", SQL: "DELETE FROM events"},
+		{ID: "named", Named: "double", Params: map[string]string{"n": "21"}},
+		{ID: "param", SQL: "SELECT :x", Params: map[string]string{"x": "hi"}},
+		{ID: "both", SQL: "SELECT 1", Named: "double"},
+		{ID: "empty"},
+	}
+	for i := 0; i < 10; i++ {
+		batch = append(batch, BatchQuery{ID: fmt.Sprint("n", i), SQL: fmt.Sprintf("SELECT %d", i)})
+	}
+
+	exec, err := NewExecutor(dbPath, WithNamedQueries(registry), WithAuditLog(logger, "test"))
+	if err != nil {
+		t.Fatalf("new executor: %v", err)
+	}
+	defer func() { _ = exec.Close() }()
+
+	results := exec.ExecuteBatch(context.Background(), batch)
+	if len(results) != len(batch) {
+		t.Fatalf("got %d results, want %d", len(results), len(batch))
+	}
+	for i, r := range results {
+		if r.ID != batch[i].ID {
+			t.Fatalf("result %d is %q, want %q: results out of order", i, r.ID, batch[i].ID)
+		}
+	}
+	want := map[string]interface{}{"events": int64(2), "named": int64(42), "param": "hi", "n7": int64(7)}
+	for _, r := range results {
+		if w, ok := want[r.ID]; ok && (r.Error != "" || r.QueryResult == nil || r.Rows[0][0] != w) {
+			t.Errorf("%s = %+v (error %q), want %v", r.ID, r.QueryResult, r.Error, w)
+		}
+	}
+	for _, i := range []int{1, 4, 5} {
+		if results[i].Error == "" || results[i].QueryResult != nil {
+			t.Errorf("%s: expected an error and no result, got %+v", results[i].ID, results[i])
+		}
+	}
+	if len(logger.entries) != len(batch)-2 {
+		t.Errorf("audited %d queries, want %d", len(logger.entries), len(batch)-2)
+	}
+
+	// Scoped sessions run the batch on their pinned connection
+	scoped, err := NewExecutor(dbPath, WithSourceScope("work@example.com"))
+	if err != nil {
+		t.Fatalf("new scoped executor: %v", err)
+	}
+	defer func() { _ = scoped.Close() }()
+	for _, r := range scoped.ExecuteBatch(context.Background(), batch[:1]) {
+		if r.Error != "" || r.Rows[0][0] != int64(1) {
+			t.Errorf("scoped %s = %+v (error %q), want 1", r.ID, r.QueryResult, r.Error)
+		}
+	}
+}
+
+func TestRegistry_RejectsInvalidQueries(t *testing.T) {
+	tests := []struct {
+		name string
+		q    *NamedQuery
+	}{
+		{"not select", &NamedQuery{Name: "del", SQL: "DELETE FROM events"}},
+		{"bad type", &NamedQuery{Name: "t", SQL: "SELECT :x", Params: []Param{{Name: "x", Type: "blob"}}}},
+		{"unreferenced param", &NamedQuery{Name: "u", SQL: "SELECT 1", Params: []Param{{Name: "x", Type: ParamInt}}}},
+		{"no name", &NamedQuery{SQL: "SELECT 1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := NewRegistry().Register(tt.q); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// recordingAuditLogger collects audit entries in memory.
+type recordingAuditLogger struct {
+	entries []AuditEntry
+}
+
+func (l *recordingAuditLogger) LogQuery(entry AuditEntry) error {
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+func TestExecutor_AuditLog(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	audit := &recordingAuditLogger{}
+	exec, err := NewExecutor(dbPath, WithAuditLog(audit, "agent"))
+	if err != nil {
+		t.Fatalf("new executor: %v", err)
+	}
+	defer func() { _ = exec.Close() }()
+
+	_, _ = exec.Execute(context.Background(), "SELECT 1 UNION ALL SELECT 2")
+	_, _ = exec.Execute(context.Background(), "DELETE FROM events")
+
+	if len(audit.entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %d", len(audit.entries))
+	}
+	if e := audit.entries[0]; e.Caller != "agent" || e.RowCount != 2 || e.Err != nil {
+		t.Errorf("first entry = %+v, want agent/2 rows/no error", e)
+	}
+	if e := audit.entries[1]; e.Err == nil || e.SQL != "DELETE FROM events" {
+		t.Errorf("second entry = %+v, want rejected DELETE", e)
+	}
+}
+
+func TestExecutor_SourceScope(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	s, err := store.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	for _, email := range []string{"work@example.com", "home@example.com"} {
+		src, _ := s.GetOrCreateSource(email)
+		calID, _ := s.UpsertCalendar(src.ID, &store.Calendar{
+			GoogleCalendarID: "primary",
+			Summary:          email,
+			DefaultReminders: []*store.Reminder{{Method: "popup", Minutes: 10}},
+		})
+		for i := 0; i < 2; i++ {
+			eventID, _ := s.UpsertEvent(&store.Event{
+				SourceID:      src.ID,
+				CalendarID:    calID,
+				GoogleEventID: fmt.Sprintf("%s-%d", email, i),
+				Summary:       email,
+			})
+			_ = s.ReplaceAttendees(eventID, []*store.Attendee{{Email: "x@example.com"}})
+		}
+	}
+	_ = s.Close()
+
+	exec, err := NewExecutor(dbPath, WithSourceScope("work@example.com"))
+	if err != nil {
+		t.Fatalf("new executor: %v", err)
+	}
+	defer func() { _ = exec.Close() }()
+
+	tests := []struct {
+		query string
+		want  int64
+	}{
+		{"SELECT COUNT(*) FROM events", 2},
+		{"SELECT COUNT(*) FROM sources", 1},
+		{"SELECT COUNT(*) FROM calendars", 1},
+		{"SELECT COUNT(*) FROM attendees", 2},
+		{"SELECT COUNT(*) FROM events WHERE summary = 'home@example.com'", 0},
+		{"SELECT COUNT(*) FROM query_audit", 0},
+		{"SELECT COUNT(*) FROM event_colors", 2},
+		{"SELECT COUNT(*) FROM color_names", 11},
+		{"SELECT COUNT(*) FROM reminders", 1},
+	}
+	for _, tt := range tests {
+		result, err := exec.Execute(context.Background(), tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if got := result.Rows[0][0]; got != tt.want {
+			t.Errorf("%s = %v, want %d", tt.query, got, tt.want)
+		}
+	}
+
+	for _, q := range []string{
+		"SELECT COUNT(*) FROM main.events",
+		`SELECT COUNT(*) FROM "main".events`,
+		"SELECT COUNT(*) FROM MAIN . events",
+		"SELECT COUNT(*) FROM main/**/.events",
+		"SELECT COUNT(*) FROM main/*x*/.events",
+		"SELECT COUNT(*) FROM [main].events",
+		"SELECT COUNT(*) FROM main -- comment\n.events",
+	} {
+		if _, err := exec.Execute(context.Background(), q); err == nil {
+			t.Errorf("expected main schema reference to be rejected: %s", q)
+		}
+	}
+
+	// Only schema qualifiers count, not "main" in literals or comments
+	for _, q := range []string{
+		"SELECT COUNT(*) FROM events WHERE summary <> 'main.x'",
+		"SELECT COUNT(*) FROM events -- main.events",
+		"SELECT COUNT(*) FROM events /* main.events */",
+	} {
+		if _, err := exec.Execute(context.Background(), q); err != nil {
+			t.Errorf("%s: %v", q, err)
+		}
+	}
+
+	if _, err := NewExecutor(dbPath, WithSourceScope("nobody@example.com")); err == nil {
+		t.Error("expected error for unknown account")
+	}
+}
+
+func TestExecutor_AsOf(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	s, err := store.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	if err := s.SetEventHistory(true); err != nil {
+		t.Fatalf("enable event history: %v", err)
+	}
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: "primary"})
+	start := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	at := func(t time.Time) sql.NullTime { return sql.NullTime{Time: t, Valid: true} }
+	upsert := func(id, summary string, start, created time.Time) {
+		t.Helper()
+		_, err := s.UpsertEvent(&store.Event{
+			SourceID: src.ID, CalendarID: calID, GoogleEventID: id, Summary: summary,
+			StartTime: at(start), EndTime: at(start.Add(time.Hour)), CreatedAt: at(created),
+		})
+		if err != nil {
+			t.Fatalf("upsert %s: %v", id, err)
+		}
+	}
+	past := time.Now().Add(-24 * time.Hour)
+	upsert("reorg", "Team sync", start, past)
+	upsert("kept", "1:1", start, past)
+
+	asOf := time.Now()
+	time.Sleep(20 * time.Millisecond)
+	upsert("reorg", "Org sync", start.Add(48*time.Hour), past)
+	upsert("new", "Offsite", start, time.Now())
+	_ = s.Close()
+
+	tests := []struct {
+		asOf  time.Time
+		query string
+		want  string
+	}{
+		{asOf, "SELECT summary, weekday FROM events ORDER BY google_event_id", "1:1 2, Team sync 2"},
+		{time.Time{}, "SELECT summary, weekday FROM events ORDER BY google_event_id", "1:1 2, Offsite 2, Org sync 4"},
+		{past.Add(-time.Hour), "SELECT summary, weekday FROM events ORDER BY google_event_id", ""},
+	}
+	for _, tt := range tests {
+		var opts []ExecutorOption
+		if !tt.asOf.IsZero() {
+			opts = append(opts, WithAsOf(tt.asOf))
+		}
+		exec, err := NewExecutor(dbPath, opts...)
+		if err != nil {
+			t.Fatalf("new executor: %v", err)
+		}
+		result, err := exec.Execute(context.Background(), tt.query)
+		_ = exec.Close()
+		if err != nil {
+			t.Errorf("as of %v: %v", tt.asOf, err)
+			continue
+		}
+		var got []string
+		for _, row := range result.Rows {
+			got = append(got, fmt.Sprintf("%v %v", row[0], row[1]))
+		}
+		if strings.Join(got, ", ") != tt.want {
+			t.Errorf("as of %v: rows = %q, want %q", tt.asOf, strings.Join(got, ", "), tt.want)
+		}
+	}
+
+	exec, err := NewExecutor(dbPath, WithAsOf(asOf))
+	if err != nil {
+		t.Fatalf("new executor: %v", err)
+	}
+	defer func() { _ = exec.Close() }()
+	if _, err := exec.Execute(context.Background(), "SELECT COUNT(*) FROM main.events"); err == nil {
+		t.Error("expected main schema reference to be rejected as of a date")
+	}
+}
+
+func TestExecutor_TeamScope(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	s, err := store.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	var instances []*store.EventInstance
+	for _, owner := range []string{"alice", "bob"} {
+		src, _ := s.GetOrCreateSource(owner + "@example.com")
+		_ = s.ClaimSource(src.ID, owner)
+		for _, name := range []string{"Work", "Personal"} {
+			calID, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: owner + "-" + name, Summary: name})
+			for _, visibility := range []string{"default", "private"} {
+				id := fmt.Sprintf("%s-%s-%s", owner, name, visibility)
+				eventID, _ := s.UpsertEvent(&store.Event{
+					SourceID:      src.ID,
+					CalendarID:    calID,
+					GoogleEventID: id,
+					Visibility:    visibility,
+				})
+				instances = append(instances, &store.EventInstance{
+					SourceID:         src.ID,
+					RecurringEventID: id,
+					SeriesID:         sql.NullInt64{Int64: eventID, Valid: true},
+					EventID:          eventID,
+					Start:            sql.NullTime{Time: time.Now(), Valid: true},
+					Expanded:         true,
+				})
+			}
+		}
+		_, _ = s.MarkPrivateCalendars(src.ID, func(id, name string) bool { return name == "Personal" })
+	}
+	if err := s.ReplaceEventInstances(instances); err != nil {
+		t.Fatalf("replace event instances: %v", err)
+	}
+	_ = s.Close()
+
+	tests := []struct {
+		name  string
+		opts  []ExecutorOption
+		query string
+		want  int64
+	}{
+		{"alice sees own private data", []ExecutorOption{WithTeamPrivacy("alice")}, "SELECT COUNT(*) FROM events", 4 + 1},
+		{"alice sees bob's shared calendar", []ExecutorOption{WithTeamPrivacy("alice")}, "SELECT COUNT(*) FROM calendars", 2 + 1},
+		{"no viewer sees no private data", []ExecutorOption{WithTeamPrivacy("")}, "SELECT COUNT(*) FROM events", 2},
+		{"instances follow their events", []ExecutorOption{WithTeamPrivacy("alice")}, "SELECT COUNT(*) FROM event_instances", 4 + 1},
+		{"owner scope", []ExecutorOption{WithTeamPrivacy("alice"), WithOwnerScope("bob")}, "SELECT COUNT(*) FROM events", 1},
+		{"owner scope sources", []ExecutorOption{WithOwnerScope("bob")}, "SELECT COUNT(*) FROM sources", 1},
+		{"owner scope without privacy", []ExecutorOption{WithOwnerScope("bob")}, "SELECT COUNT(*) FROM events", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec, err := NewExecutor(dbPath, tt.opts...)
+			if err != nil {
+				t.Fatalf("new executor: %v", err)
+			}
+			defer func() { _ = exec.Close() }()
+			result, err := exec.Execute(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.query, err)
+			}
+			if got := result.Rows[0][0]; got != tt.want {
+				t.Errorf("%s = %v, want %d", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewExecutor(dbPath, WithOwnerScope("carol")); err == nil {
+		t.Error("expected error for owner without accounts")
+	}
+}
+
+func TestExecutor_Explain(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	exec, err := NewExecutor(dbPath)
+	if err != nil {
+		t.Fatalf("new executor: %v", err)
+	}
+	defer func() { _ = exec.Close() }()
+
+	ctx := context.Background()
+
+	profile, err := exec.Explain(ctx, "SELECT summary FROM events e WHERE e.location = 'Gym' AND e.visibility = 'private'")
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if len(profile.Plan) == 0 {
+		t.Fatal("expected a query plan")
+	}
+	if len(profile.Suggestions) != 1 {
+		t.Fatalf("got %d suggestions, want 1: %+v", len(profile.Suggestions), profile.Suggestions)
+	}
+	sug := profile.Suggestions[0]
+	if sug.Table != "events" || fmt.Sprint(sug.Columns) != "[location visibility]" {
+		t.Errorf("suggestion = %+v, want events(location, visibility)", sug)
+	}
+
+	// Already-indexed predicates produce no suggestions
+	profile, err = exec.Explain(ctx, "SELECT summary FROM events WHERE calendar_id = 1")
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if len(profile.Suggestions) != 0 {
+		t.Errorf("unexpected suggestions: %+v", profile.Suggestions)
+	}
+
+	// Sorting on an unindexed column suggests an index for ORDER BY
+	profile, err = exec.Explain(ctx, "SELECT summary FROM events ORDER BY location LIMIT 5")
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if len(profile.Suggestions) != 1 || profile.Suggestions[0].Columns[0] != "location" {
+		t.Errorf("suggestions = %+v, want events(location)", profile.Suggestions)
+	}
+
+	if _, err := exec.Explain(ctx, "DELETE FROM events"); err == nil {
+		t.Error("expected error for non-SELECT query")
+	}
+}
+
+func TestExecutor_CTEAndWindowFunctions(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	s, err := store.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: "primary"})
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	for i, hours := range []int{1, 2, 3} {
+		eventStart := start.AddDate(0, 0, 7*i)
+		if _, err := s.UpsertEvent(&store.Event{
+			SourceID:      src.ID,
+			CalendarID:    calID,
+			GoogleEventID: fmt.Sprintf("ev-%d", i),
+			StartTime:     sql.NullTime{Time: eventStart, Valid: true},
+			EndTime:       sql.NullTime{Time: eventStart.Add(time.Duration(hours) * time.Hour), Valid: true},
+		}); err != nil {
+			t.Fatalf("upsert event: %v", err)
+		}
+	}
+	_ = s.Close()
+
+	exec, err := NewExecutor(dbPath)
+	if err != nil {
+		t.Fatalf("new executor: %v", err)
+	}
+	defer func() { _ = exec.Close() }()
+	ctx := context.Background()
+
+	// Running total of meeting hours
+	result, err := exec.Execute(ctx, `
+		-- weekly hours
+		WITH weekly AS (
+			SELECT strftime('%Y-%W', start_time) AS week,
+			       SUM((julianday(end_time) - julianday(start_time)) * 24) AS hours
+			FROM events GROUP BY week
+		)
+		SELECT week, ROUND(SUM(hours) OVER (ORDER BY week)) AS running FROM weekly ORDER BY week`)
+	if err != nil {
+		t.Fatalf("execute CTE: %v", err)
+	}
+	var running []float64
+	for _, row := range result.Rows {
+		running = append(running, row[1].(float64))
+	}
+	if fmt.Sprint(running) != "[1 3 6]" {
+		t.Errorf("running totals = %v, want [1 3 6]", running)
+	}
+
+	for _, q := range []string{
+		"with recursive n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 3) SELECT i FROM n",
+		"WITH\tx(n) AS (SELECT 1) SELECT n FROM x",
+		"WITH x AS (SELECT replace(summary,'a','b') s FROM events) SELECT COUNT(*) FROM x",
+		"WITH x AS (SELECT * FROM events WHERE summary LIKE '%update%') SELECT COUNT(*) FROM x",
+		`WITH x AS (SELECT 1 AS "delete") SELECT "delete" FROM x -- then update`,
+	} {
+		if _, err := exec.Execute(ctx, q); err != nil {
+			t.Errorf("unexpected error for %q: %v", q, err)
+		}
+	}
+
+	for _, q := range []string{
+		"WITH x AS (SELECT 1) DELETE FROM events",
+		"WITH x AS (SELECT 1) INSERT INTO events (summary) SELECT 'x' FROM x",
+		"WITH x AS (SELECT 1) UPDATE events SET summary = 'x'",
+		"WITH x AS (SELECT 1) REPLACE INTO events (summary) VALUES ('x')",
+		"WITH x AS (SELECT 1) /* select */ DELETE FROM events",
+		"WITHOUT 1",
+	} {
+		if _, err := exec.Execute(ctx, q); err == nil {
+			t.Errorf("expected error for %q", q)
+		}
+	}
+
+	// The example templates must stay valid against the current schema
+	files, err := filepath.Glob("../../examples/*.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("find examples: %v", err)
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatalf("read %s: %v", f, err)
+		}
+		if _, err := exec.Execute(ctx, string(data)); err != nil {
+			t.Errorf("%s: %v", filepath.Base(f), err)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// PlanStep is one row of EXPLAIN QUERY PLAN output.
+type PlanStep struct {
+	ID     int    `json:"id"`
+	Parent int    `json:"parent"`
+	Detail string `json:"detail"`
+}
+
+// IndexSuggestion is an index that may speed up a query.
+type IndexSuggestion struct {
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"`
+	Reason  string   `json:"reason"`
+}
+
+// Profile is the result of profiling a query.
+type Profile struct {
+	Plan        []PlanStep        `json:"plan"`
+	Duration    time.Duration     `json:"duration"`
+	RowCount    int               `json:"row_count"`
+	Suggestions []IndexSuggestion `json:"suggestions"`
+}
+
+var (
+	// tableRefPattern matches "FROM table [AS] alias" and "JOIN table [AS] alias".
+	tableRefPattern = regexp.MustCompile(`(?i)\b(?:FROM|JOIN)\s+(\w+)(?:\s+(?:AS\s+)?(\w+))?`)
+	// predicatePattern matches an indexable comparison with a column on the
+	// left, capturing a qualified column on the right (a join condition).
+	predicatePattern = regexp.MustCompile(`(?i)(?:\b(\w+)\.)?\b(\w+)\s*(==|=|<=|>=|<|>|\bIN\b|\bIS\b|\bBETWEEN\b)\s*(\w+\.\w+)?`)
+	// reversePredicatePattern matches an equality with a column on the right.
+	reversePredicatePattern = regexp.MustCompile(`(?i)=\s*(?:\b(\w+)\.)?\b(\w+)\b`)
+	// orderByPattern captures an ORDER BY clause up to LIMIT or the end.
+	orderByPattern = regexp.MustCompile(`(?is)\bORDER\s+BY\s+(.+?)(?:\bLIMIT\b|$)`)
+	// scanPattern matches a full table scan step.
+	scanPattern = regexp.MustCompile(`^SCAN (\w+)$`)
+)
+
+// sqlKeywords are never treated as table aliases.
+var sqlKeywords = map[string]bool{
+	"WHERE": true, "JOIN": true, "LEFT": true, "RIGHT": true, "INNER": true, "OUTER": true,
+	"CROSS": true, "ON": true, "USING": true, "GROUP": true, "ORDER": true, "LIMIT": true,
+	"UNION": true, "NATURAL": true, "HAVING": true, "WINDOW": true, "EXCEPT": true, "INTERSECT": true,
+}
+
+// Explain profiles an ad-hoc query: it captures the query plan, runs the
+// query once to time it, bypassing the result cache, and suggests indexes
+// for full table scans and temporary sort trees found in the plan.
+func (e *Executor) Explain(ctx context.Context, query string) (*Profile, error) {
+	if e.allowlistOnly {
+		return nil, ErrNotAllowlisted
+	}
+	if err := validateQuery(query); err != nil {
+		return nil, err
+	}
+
+	plan, err := e.queryPlan(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run past the result cache, so a repeat profile times the query again
+	start := time.Now()
+	result, err := e.execute(ctx, query, nil)
+	duration := time.Since(start)
+	e.logQuery(AuditEntry{SQL: query}, start, result, err)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions, err := e.suggestIndexes(ctx, query, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Profile{
+		Plan:        plan,
+		Duration:    duration,
+		RowCount:    result.RowCount,
+		Suggestions: suggestions,
+	}, nil
+}
+
+// queryPlan runs EXPLAIN QUERY PLAN for a query.
+func (e *Executor) queryPlan(ctx context.Context, query string) ([]PlanStep, error) {
+	rows, err := e.query(ctx, "EXPLAIN QUERY PLAN "+query)
+	if err != nil {
+		return nil, fmt.Errorf("explain query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var plan []PlanStep
+	for rows.Next() {
+		var step PlanStep
+		var notused int
+		if err := rows.Scan(&step.ID, &step.Parent, &notused, &step.Detail); err != nil {
+			return nil, fmt.Errorf("scan plan: %w", err)
+		}
+		plan = append(plan, step)
+	}
+	return plan, rows.Err()
+}
+
+// suggestIndexes derives index suggestions from a query plan. Columns are
+// taken from the query's comparisons (equality first, then one range
+// column) and, for temp-tree sorts, its ORDER BY clause.
+func (e *Executor) suggestIndexes(ctx context.Context, query string, plan []PlanStep) ([]IndexSuggestion, error) {
+	aliases := map[string]string{} // alias or name -> table
+	for _, m := range tableRefPattern.FindAllStringSubmatch(query, -1) {
+		table := m[1]
+		aliases[strings.ToLower(table)] = table
+		if m[2] != "" && !sqlKeywords[strings.ToUpper(m[2])] {
+			aliases[strings.ToLower(m[2])] = table
+		}
+	}
+
+	var suggestions []IndexSuggestion
+	seen := map[string]bool{}
+	add := func(table string, columns []string, reason string) error {
+		if len(columns) == 0 {
+			return nil
+		}
+		key := strings.ToLower(table + "(" + strings.Join(columns, ",") + ")")
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+
+		covered, err := e.hasIndexPrefix(ctx, table, columns)
+		if err != nil {
+			return err
+		}
+		if !covered {
+			suggestions = append(suggestions, IndexSuggestion{Table: table, Columns: columns, Reason: reason})
+		}
+		return nil
+	}
+
+	for _, step := range plan {
+		switch {
+		case scanPattern.MatchString(step.Detail):
+			ref := scanPattern.FindStringSubmatch(step.Detail)[1]
+			table, ok := aliases[strings.ToLower(ref)]
+			if !ok {
+				continue // Subquery or CTE
+			}
+			columns, err := e.tableColumns(ctx, table)
+			if err != nil {
+				return nil, err
+			}
+			indexCols := predicateColumns(query, ref, table, columns)
+			if err := add(table, indexCols, "full scan of "+table); err != nil {
+				return nil, err
+			}
+
+		case strings.HasPrefix(step.Detail, "USE TEMP B-TREE FOR ORDER BY"):
+			table, cols := e.orderByColumns(ctx, query, aliases)
+			if err := add(table, cols, "temporary sort for ORDER BY"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return suggestions, nil
+}
+
+// predicateColumns returns the columns of table compared in the query:
+// equality filters first, then join columns, then at most one range column.
+func predicateColumns(query, ref, table string, columns map[string]string) []string {
+	var equality, joins, ranges []string
+	seen := map[string]bool{}
+	collect := func(qualifier, column, op string, join bool) {
+		if qualifier != "" && !strings.EqualFold(qualifier, ref) && !strings.EqualFold(qualifier, table) {
+			return
+		}
+		name, ok := columns[strings.ToLower(column)]
+		if !ok || seen[name] {
+			return
+		}
+		seen[name] = true
+		switch strings.ToUpper(op) {
+		case "=", "==", "IN", "IS":
+			if join {
+				joins = append(joins, name)
+			} else {
+				equality = append(equality, name)
+			}
+		default:
+			ranges = append(ranges, name)
+		}
+	}
+
+	for _, m := range predicatePattern.FindAllStringSubmatch(query, -1) {
+		collect(m[1], m[2], m[3], m[4] != "")
+	}
+	for _, m := range reversePredicatePattern.FindAllStringSubmatch(query, -1) {
+		if m[1] != "" {
+			collect(m[1], m[2], "=", true)
+		}
+	}
+
+	equality = append(equality, joins...)
+	if len(ranges) > 0 {
+		equality = append(equality, ranges[0])
+	}
+	return equality
+}
+
+// orderByColumns returns the ORDER BY columns if they all belong to a
+// single table, or "" if the clause can't be served by one index.
+func (e *Executor) orderByColumns(ctx context.Context, query string, aliases map[string]string) (string, []string) {
+	m := orderByPattern.FindStringSubmatch(query)
+	if m == nil {
+		return "", nil
+	}
+
+	var table string
+	var cols []string
+	for _, term := range strings.Split(m[1], ",") {
+		fields := strings.Fields(strings.TrimSpace(term))
+		if len(fields) == 0 {
+			return "", nil
+		}
+		qualifier, column, found := strings.Cut(fields[0], ".")
+		if !found {
+			column, qualifier = qualifier, ""
+		}
+
+		var candidates []string
+		if qualifier != "" {
+			if t, ok := aliases[strings.ToLower(qualifier)]; ok {
+				candidates = []string{t}
+			}
+		} else {
+			for _, t := range aliases {
+				candidates = append(candidates, t)
+			}
+		}
+
+		var match string
+		for _, t := range candidates {
+			columns, err := e.tableColumns(ctx, t)
+			if err != nil {
+				continue
+			}
+			if name, ok := columns[strings.ToLower(column)]; ok {
+				if match != "" && match != t {
+					return "", nil // Ambiguous
+				}
+				match = t
+				column = name
+			}
+		}
+		if match == "" || (table != "" && table != match) {
+			return "", nil
+		}
+		table = match
+		cols = append(cols, column)
+	}
+	return table, cols
+}
+
+// tableColumns returns a table's columns keyed by lowercase name.
+func (e *Executor) tableColumns(ctx context.Context, table string) (map[string]string, error) {
+	rows, err := e.query(ctx, `SELECT name FROM pragma_table_info(?, 'main')`, table)
+	if err != nil {
+		return nil, fmt.Errorf("list columns of %s: %w", table, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	columns := map[string]string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan column: %w", err)
+		}
+		columns[strings.ToLower(name)] = name
+	}
+	return columns, rows.Err()
+}
+
+// hasIndexPrefix reports whether an existing index on table starts with columns.
+func (e *Executor) hasIndexPrefix(ctx context.Context, table string, columns []string) (bool, error) {
+	rows, err := e.query(ctx, `
+		SELECT il.name, ii.seqno, ii.name
+		FROM pragma_index_list(?, 'main') il
+		JOIN pragma_index_info(il.name, 'main') ii
+		ORDER BY il.name, ii.seqno
+	`, table)
+	if err != nil {
+		return false, fmt.Errorf("list indexes of %s: %w", table, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	indexes := map[string][]string{}
+	for rows.Next() {
+		var index string
+		var seqno int
+		var column *string
+		if err := rows.Scan(&index, &seqno, &column); err != nil {
+			return false, fmt.Errorf("scan index: %w", err)
+		}
+		if column != nil {
+			indexes[index] = append(indexes[index], *column)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	for _, indexCols := range indexes {
+		if len(indexCols) < len(columns) {
+			continue
+		}
+		match := true
+		for i, c := range columns {
+			if !strings.EqualFold(indexCols[i], c) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,127 @@
+package query
+
+import "strings"
+
+// sqlToken is a word or punctuation character of a query. String literals
+// and comments are skipped.
+type sqlToken struct {
+	text   string // A word without its quotes, or a punctuation character
+	word   bool   // A keyword or identifier, quoted or not, or a number
+	quoted bool   // A quoted identifier, never a keyword
+}
+
+// sqlTokens splits a query into tokens, as far as validation needs: words
+// (keywords, identifiers - bare, "quoted", `quoted` or [bracketed] - and
+// numbers) and single punctuation characters. An unterminated literal,
+// identifier or comment ends the query.
+func sqlTokens(query string) []sqlToken {
+	var tokens []sqlToken
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+		case c == '\'':
+			end := quotedEnd(query, i, '\'')
+			if end < 0 {
+				return tokens
+			}
+			i = end
+		case c == '"' || c == '`':
+			end := quotedEnd(query, i, c)
+			if end < 0 {
+				return tokens
+			}
+			q := string(c)
+			tokens = append(tokens, sqlToken{text: strings.ReplaceAll(query[i+1:end], q+q, q), word: true, quoted: true})
+			i = end
+		case c == '[':
+			end := strings.IndexByte(query[i:], ']')
+			if end < 0 {
+				return tokens
+			}
+			tokens = append(tokens, sqlToken{text: query[i+1 : i+end], word: true, quoted: true})
+			i += end
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return tokens
+			}
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 3
+		case isWordByte(c):
+			j := i + 1
+			for j < len(query) && isWordByte(query[j]) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{text: query[i:j], word: true})
+			i = j - 1
+		default:
+			tokens = append(tokens, sqlToken{text: string(c)})
+		}
+	}
+	return tokens
+}
+
+// quotedEnd returns the index of the quote closing the literal or
+// identifier opened at query[start], where a doubled quote stands for
+// itself, or -1 if it isn't closed.
+func quotedEnd(query string, start int, quote byte) int {
+	for i := start + 1; i < len(query); i++ {
+		if query[i] != quote {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			i++
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+// isWordByte reports whether c can be part of a bare SQLite identifier,
+// keyword or number. Bytes of multi-byte UTF-8 characters are.
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || isAlnum(c) || c >= 0x80
+}
+
+// referencesMainSchema reports whether a query qualifies a name with the
+// main schema, as in main.events or "main" . events, which would bypass
+// the TEMP views that scope queries.
+func referencesMainSchema(tokens []sqlToken) bool {
+	for i := 0; i+1 < len(tokens); i++ {
+		if tokens[i].word && strings.EqualFold(tokens[i].text, "main") && !tokens[i+1].word && tokens[i+1].text == "." {
+			return true
+		}
+	}
+	return false
+}
+
+// writeKeywords start statements that modify data.
+var writeKeywords = map[string]bool{"INSERT": true, "UPDATE": true, "DELETE": true, "REPLACE": true}
+
+// hasWriteStatement reports whether a WITH query's statement, outside the
+// parenthesized common table expressions, modifies data. Keywords in
+// literals, comments and quoted identifiers don't count, nor does the
+// replace() function.
+func hasWriteStatement(tokens []sqlToken) bool {
+	depth := 0
+	for i, t := range tokens {
+		switch {
+		case t.text == "(" && !t.word:
+			depth++
+		case t.text == ")" && !t.word:
+			depth--
+		case depth == 0 && t.word && !t.quoted && writeKeywords[strings.ToUpper(t.text)]:
+			if i+1 < len(tokens) && tokens[i+1].text == "(" && !tokens[i+1].word {
+				continue // A function call such as replace()
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,233 @@
+package query
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParamType is the declared type of a named query parameter.
+type ParamType string
+
+// Supported parameter types.
+const (
+	ParamString ParamType = "string"
+	ParamInt    ParamType = "int"
+	ParamFloat  ParamType = "float"
+	ParamBool   ParamType = "bool"
+	ParamDate   ParamType = "date" // YYYY-MM-DD
+)
+
+// Param declares a typed parameter of a named query.
+type Param struct {
+	Name string    `json:"name"`
+	Type ParamType `json:"type"`
+}
+
+// NamedQuery is a pre-registered SQL statement with typed parameters.
+// Parameters are referenced in the SQL as :name.
+type NamedQuery struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	SQL         string  `json:"sql"`
+	Params      []Param `json:"params"`
+}
+
+// Registry holds the named queries that may be executed.
+type Registry struct {
+	queries map[string]*NamedQuery
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{queries: make(map[string]*NamedQuery)}
+}
+
+// Register validates and adds a named query.
+func (r *Registry) Register(q *NamedQuery) error {
+	if q.Name == "" {
+		return fmt.Errorf("named query has no name")
+	}
+	if _, ok := r.queries[q.Name]; ok {
+		return fmt.Errorf("named query %q already registered", q.Name)
+	}
+	if err := validateQuery(q.SQL); err != nil {
+		return fmt.Errorf("named query %q: %w", q.Name, err)
+	}
+	for _, p := range q.Params {
+		switch p.Type {
+		case ParamString, ParamInt, ParamFloat, ParamBool, ParamDate:
+		default:
+			return fmt.Errorf("named query %q: parameter %q has unsupported type %q", q.Name, p.Name, p.Type)
+		}
+		if !strings.Contains(q.SQL, ":"+p.Name) {
+			return fmt.Errorf("named query %q: parameter %q is not referenced as :%s", q.Name, p.Name, p.Name)
+		}
+	}
+	r.queries[q.Name] = q
+	return nil
+}
+
+// Get returns the named query, if registered.
+func (r *Registry) Get(name string) (*NamedQuery, bool) {
+	q, ok := r.queries[name]
+	return q, ok
+}
+
+// List returns all registered queries sorted by name.
+func (r *Registry) List() []*NamedQuery {
+	list := make([]*NamedQuery, 0, len(r.queries))
+	for _, q := range r.queries {
+		list = append(list, q)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// bind converts raw string values to typed values keyed by parameter name.
+// Every declared parameter must be supplied and no others are accepted.
+func (q *NamedQuery) bind(values map[string]string) (map[string]interface{}, error) {
+	declared := make(map[string]ParamType, len(q.Params))
+	for _, p := range q.Params {
+		declared[p.Name] = p.Type
+	}
+	for name := range values {
+		if _, ok := declared[name]; !ok {
+			return nil, fmt.Errorf("unknown parameter %q for query %q", name, q.Name)
+		}
+	}
+
+	bound := make(map[string]interface{}, len(q.Params))
+	for _, p := range q.Params {
+		raw, ok := values[p.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing parameter %q for query %q", p.Name, q.Name)
+		}
+		v, err := convertParam(p.Type, raw)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %q: %w", p.Name, err)
+		}
+		bound[p.Name] = v
+	}
+	return bound, nil
+}
+
+// convertParam parses a raw string as the given parameter type.
+func convertParam(typ ParamType, raw string) (interface{}, error) {
+	switch typ {
+	case ParamString:
+		return raw, nil
+	case ParamInt:
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected int, got %q", raw)
+		}
+		return v, nil
+	case ParamFloat:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected float, got %q", raw)
+		}
+		return v, nil
+	case ParamBool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("expected bool, got %q", raw)
+		}
+		return v, nil
+	case ParamDate:
+		// Bind as text so comparisons match SQLite date() output
+		if _, err := time.Parse("2006-01-02", raw); err != nil {
+			return nil, fmt.Errorf("expected date (YYYY-MM-DD), got %q", raw)
+		}
+		return raw, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %q", typ)
+	}
+}
+
+// bindPlaceholders binds values to the :name placeholders of an ad-hoc
+// query. Keys are name or name:type.
+func bindPlaceholders(query string, values map[string]string) ([]interface{}, error) {
+	refs := placeholders(query)
+	bound := make(map[string]interface{}, len(values))
+	for key, raw := range values {
+		name, typ := key, ParamString
+		if i := strings.IndexByte(key, ':'); i >= 0 {
+			name, typ = key[:i], ParamType(key[i+1:])
+		}
+		if !refs[name] {
+			return nil, fmt.Errorf("parameter %q is not referenced as :%s", name, name)
+		}
+		if _, ok := bound[name]; ok {
+			return nil, fmt.Errorf("parameter %q given more than once", name)
+		}
+		v, err := convertParam(typ, raw)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %q: %w", name, err)
+		}
+		bound[name] = v
+	}
+
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		v, ok := bound[name]
+		if !ok {
+			return nil, fmt.Errorf("missing parameter %q (use --param %s=value)", name, name)
+		}
+		args = append(args, sql.Named(name, v))
+	}
+	return args, nil
+}
+
+// placeholders returns the names of the :name placeholders in a query,
+// ignoring string literals, quoted identifiers and comments.
+func placeholders(query string) map[string]bool {
+	names := map[string]bool{}
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				return names
+			}
+			i += end + 1
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return names
+			}
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return names
+			}
+			i += end + 3
+		case c == ':':
+			j := i + 1
+			for j < len(query) && (query[j] == '_' || isAlnum(query[j])) {
+				j++
+			}
+			if j > i+1 && !isDigit(query[i+1]) {
+				names[query[i+1:j]] = true
+			}
+			i = j - 1
+		}
+	}
+	return names
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,284 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// WithSourceScope restricts every query to data belonging to one account,
+// identified by its email (sources.identifier).
+func WithSourceScope(identifier string) ExecutorOption {
+	return func(e *Executor) {
+		e.scope = identifier
+	}
+}
+
+// WithOwnerScope restricts every query to the accounts a team member owns
+// (sources.owner).
+func WithOwnerScope(owner string) ExecutorOption {
+	return func(e *Executor) {
+		e.owner = owner
+	}
+}
+
+// WithTeamPrivacy hides private calendars, private events, and aggregates
+// that include them, except for accounts owned by viewer. It is used for
+// team vaults, where several people's accounts share a database.
+func WithTeamPrivacy(viewer string) ExecutorOption {
+	return func(e *Executor) {
+		e.team = true
+		e.viewer = viewer
+	}
+}
+
+// WithAsOf runs queries against events as they were at t: events is
+// shadowed by a TEMP view built by store.AsOfEventsSQL, which other scoping
+// applies on top of. Other tables are as they are now.
+func WithAsOf(t time.Time) ExecutorOption {
+	return func(e *Executor) {
+		e.asOf = t
+	}
+}
+
+// scoped reports whether queries run against scoping views.
+func (e *Executor) scoped() bool {
+	return e.scope != "" || e.owner != "" || e.team
+}
+
+// Restricted reports whether the executor limits what can be read: to
+// allowlisted queries, an account or owner scope, or past team privacy.
+// Callers that read the store directly would get around it.
+func (e *Executor) Restricted() bool {
+	return e.allowlistOnly || e.scoped()
+}
+
+// shadowed reports whether any main schema table is shadowed by a TEMP
+// view, by scoping or WithAsOf.
+func (e *Executor) shadowed() bool {
+	return e.scoped() || !e.asOf.IsZero()
+}
+
+// privateAggregates are tables aggregating events, which include private
+// ones; in team scope only their owners see them.
+var privateAggregates = map[string]bool{
+	"daily_meeting_minutes": true,
+	"person_meeting_counts": true,
+	"analytics_dirty":       true,
+}
+
+// sharedViews hold fixed lookup data, the same for every account.
+var sharedViews = map[string]bool{
+	"color_names": true,
+}
+
+// applyScope pins a single connection and shadows each table in the main
+// schema with a TEMP view filtered to the scoped rows, and events with its
+// state at the WithAsOf time. Unqualified table names resolve to the temp
+// schema first, so queries only see scoped rows.
+func (e *Executor) applyScope(ctx context.Context) error {
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection: %w", err)
+	}
+
+	v, err := e.visibility(ctx, conn)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	objects, err := mainSchemaObjects(ctx, conn)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	for _, obj := range objects {
+		if obj.name == "calendars" {
+			v.privateColumn = obj.columns["private"]
+		}
+	}
+
+	for _, obj := range objects {
+		from, where := "main."+quoteIdent(obj.name), "1"
+		if obj.name == "events" && !e.asOf.IsZero() {
+			from = "(" + store.AsOfEventsSQL(obj.names, e.asOf) + ")"
+		} else if !e.scoped() {
+			continue
+		}
+		if e.scoped() {
+			where = v.condition(obj)
+		}
+		view := fmt.Sprintf(`CREATE TEMP VIEW %s AS SELECT * FROM %s WHERE %s`, quoteIdent(obj.name), from, where)
+		if _, err := conn.ExecContext(ctx, view); err != nil {
+			_ = conn.Close()
+			return fmt.Errorf("create scoped view for %s: %w", obj.name, err)
+		}
+	}
+
+	e.conn = conn
+	return nil
+}
+
+// visibility holds the scope as conditions on main.sources.
+type visibility struct {
+	sources       string // Accounts whose rows are visible
+	private       string // Of those, accounts whose private rows are visible
+	team          bool
+	privateColumn bool // calendars.private exists
+}
+
+// visibility resolves the executor's scope options.
+func (e *Executor) visibility(ctx context.Context, conn *sql.Conn) (*visibility, error) {
+	v := &visibility{sources: "1", private: "1", team: e.team}
+
+	if e.scope != "" {
+		var sourceID int64
+		err := conn.QueryRowContext(ctx,
+			`SELECT id FROM main.sources WHERE identifier = ?`, e.scope,
+		).Scan(&sourceID)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("account %q not found", e.scope)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("look up account: %w", err)
+		}
+		v.sources = fmt.Sprintf("id = %d", sourceID)
+	}
+
+	if e.owner != "" {
+		var n int
+		err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM main.sources WHERE owner = ?`, e.owner).Scan(&n)
+		if err != nil {
+			return nil, fmt.Errorf("look up owner: %w", err)
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("no accounts belong to %q", e.owner)
+		}
+		v.sources += " AND owner = " + quoteLiteral(e.owner)
+	}
+
+	if e.team {
+		v.private = "0"
+		if e.viewer != "" {
+			v.private = "owner = " + quoteLiteral(e.viewer)
+		}
+	}
+	return v, nil
+}
+
+// condition returns the WHERE clause restricting obj to visible rows.
+// Tables that can't be tied to a source are hidden entirely.
+func (v *visibility) condition(obj *schemaObject) string {
+	sources := "SELECT id FROM main.sources WHERE " + v.sources
+	private := fmt.Sprintf("SELECT id FROM main.sources WHERE (%s) AND (%s)", v.sources, v.private)
+
+	calendars := fmt.Sprintf("source_id IN (%s)", sources)
+	events := calendars
+	if v.team {
+		if v.privateColumn {
+			calendars += fmt.Sprintf(" AND (NOT COALESCE(private, FALSE) OR source_id IN (%s))", private)
+		}
+		events = fmt.Sprintf("calendar_id IN (SELECT id FROM main.calendars WHERE %s)"+
+			" AND (COALESCE(visibility, '') != 'private' OR source_id IN (%s))", calendars, private)
+	}
+
+	switch {
+	case obj.isView && sharedViews[obj.name]:
+		return "1"
+	case obj.isView && obj.columns["event_id"]:
+		// Views of event rows follow the events
+		return fmt.Sprintf("event_id IN (SELECT id FROM main.events WHERE %s)", events)
+	case obj.isView:
+		return "0"
+	case obj.name == "sources":
+		return v.sources
+	case obj.name == "calendars":
+		return calendars
+	case obj.name == "events":
+		return events
+	case obj.name == "reminders":
+		// Calendar defaults have no event and follow their calendar
+		return fmt.Sprintf("calendar_id IN (SELECT id FROM main.calendars WHERE %s)"+
+			" AND (event_id IS NULL OR event_id IN (SELECT id FROM main.events WHERE %s))", calendars, events)
+	case obj.name == "event_instances" || obj.name == "event_provenance":
+		// Occurrences and merged copies follow the event they belong to
+		return fmt.Sprintf("event_id IN (SELECT id FROM main.events WHERE %s)", events)
+	case privateAggregates[obj.name] && obj.columns["source_id"]:
+		return fmt.Sprintf("source_id IN (%s)", private)
+	case obj.columns["source_id"]:
+		return fmt.Sprintf("source_id IN (%s)", sources)
+	case obj.columns["event_id"]:
+		return fmt.Sprintf("event_id IN (SELECT id FROM main.events WHERE %s)", events)
+	case obj.columns["calendar_id"]:
+		return fmt.Sprintf("calendar_id IN (SELECT id FROM main.calendars WHERE %s)", calendars)
+	default:
+		return "0"
+	}
+}
+
+// schemaObject is a table or view in the main schema.
+type schemaObject struct {
+	name    string
+	isView  bool
+	columns map[string]bool
+	names   []string // Columns in table order
+}
+
+// mainSchemaObjects lists user tables and views in the main schema.
+func mainSchemaObjects(ctx context.Context, conn *sql.Conn) ([]*schemaObject, error) {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT name, type FROM main.sqlite_master
+		WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+	var objects []*schemaObject
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("scan table: %w", err)
+		}
+		objects = append(objects, &schemaObject{name: name, isView: typ == "view", columns: map[string]bool{}})
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+
+	for _, obj := range objects {
+		cols, err := conn.QueryContext(ctx, `SELECT name FROM pragma_table_info(?, 'main')`, obj.name)
+		if err != nil {
+			return nil, fmt.Errorf("list columns of %s: %w", obj.name, err)
+		}
+		for cols.Next() {
+			var col string
+			if err := cols.Scan(&col); err != nil {
+				_ = cols.Close()
+				return nil, fmt.Errorf("scan column: %w", err)
+			}
+			obj.columns[col] = true
+			obj.names = append(obj.names, col)
+		}
+		_ = cols.Close()
+	}
+
+	return objects, nil
+}
+
+// quoteIdent quotes an SQL identifier.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes an SQL string literal.
+func quoteLiteral(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `''`) + `'`
+}
@@ -0,0 +1,175 @@
+package render
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date orders accepted in Locale.DateOrder.
+const (
+	DateYMD = "ymd"
+	DateDMY = "dmy"
+	DateMDY = "mdy"
+)
+
+// Locale controls how dates, times and numbers are written.
+type Locale struct {
+	DateOrder string // DateYMD, DateDMY or DateMDY
+	DateSep   string // Between date fields, e.g. "-", "/" or "."
+	Clock24   bool   // 15:04 rather than 3:04 PM
+	WeekStart time.Weekday
+	Thousands string // Digit group separator; empty for none
+}
+
+// ISO is the locale-neutral default: 2006-01-02, 24-hour clock, weeks
+// starting on Monday and ungrouped numbers.
+var ISO = Locale{DateOrder: DateYMD, DateSep: "-", Clock24: true, WeekStart: time.Monday}
+
+// territories holds conventions by ISO 3166 country code.
+var territories = map[string]Locale{
+	"US": {DateMDY, "/", false, time.Sunday, ","},
+	"CA": {DateYMD, "-", false, time.Sunday, ","},
+	"MX": {DateDMY, "/", false, time.Sunday, ","},
+	"BR": {DateDMY, "/", true, time.Sunday, "."},
+	"GB": {DateDMY, "/", true, time.Monday, ","},
+	"IE": {DateDMY, "/", true, time.Monday, ","},
+	"AU": {DateDMY, "/", false, time.Monday, ","},
+	"NZ": {DateDMY, "/", false, time.Monday, ","},
+	"IN": {DateDMY, "/", false, time.Sunday, ","},
+	"DE": {DateDMY, ".", true, time.Monday, "."},
+	"AT": {DateDMY, ".", true, time.Monday, "."},
+	"CH": {DateDMY, ".", true, time.Monday, "'"},
+	"FR": {DateDMY, "/", true, time.Monday, " "},
+	"BE": {DateDMY, "/", true, time.Monday, "."},
+	"ES": {DateDMY, "/", true, time.Monday, "."},
+	"IT": {DateDMY, "/", true, time.Monday, "."},
+	"PT": {DateDMY, "/", true, time.Monday, " "},
+	"NL": {DateDMY, "-", true, time.Monday, "."},
+	"SE": {DateYMD, "-", true, time.Monday, " "},
+	"NO": {DateDMY, ".", true, time.Monday, " "},
+	"DK": {DateDMY, ".", true, time.Monday, "."},
+	"FI": {DateDMY, ".", true, time.Monday, " "},
+	"PL": {DateDMY, ".", true, time.Monday, " "},
+	"CZ": {DateDMY, ".", true, time.Monday, " "},
+	"RU": {DateDMY, ".", true, time.Monday, " "},
+	"UA": {DateDMY, ".", true, time.Monday, " "},
+	"JP": {DateYMD, "/", true, time.Sunday, ","},
+	"CN": {DateYMD, "/", true, time.Monday, ","},
+	"TW": {DateYMD, "/", false, time.Sunday, ","},
+	"KR": {DateYMD, ".", false, time.Sunday, ","},
+}
+
+// languageTerritories picks a territory for locale names without one.
+var languageTerritories = map[string]string{
+	"en": "US", "de": "DE", "fr": "FR", "es": "ES", "it": "IT", "pt": "PT",
+	"nl": "NL", "sv": "SE", "nb": "NO", "nn": "NO", "no": "NO", "da": "DK",
+	"fi": "FI", "pl": "PL", "cs": "CZ", "ru": "RU", "uk": "UA", "ja": "JP",
+	"zh": "CN", "ko": "KR",
+}
+
+// LocaleFor returns the conventions for a POSIX or BCP 47 locale name such
+// as "en_GB.UTF-8" or "de-CH". Unknown names, "C" and "POSIX" get ISO.
+func LocaleFor(name string) Locale {
+	// Drop the codeset and modifier: en_GB.UTF-8@euro -> en_GB
+	if i := strings.IndexAny(name, ".@"); i >= 0 {
+		name = name[:i]
+	}
+	lang, territory, _ := strings.Cut(strings.ReplaceAll(name, "-", "_"), "_")
+	territory = strings.ToUpper(territory)
+	if territory == "" {
+		territory = languageTerritories[strings.ToLower(lang)]
+	}
+	if l, ok := territories[territory]; ok {
+		return l
+	}
+	return ISO
+}
+
+// DetectLocale returns the conventions for the time locale of the
+// environment (LC_ALL, then LC_TIME, then LANG).
+func DetectLocale() Locale {
+	for _, key := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		if v := os.Getenv(key); v != "" {
+			return LocaleFor(v)
+		}
+	}
+	return ISO
+}
+
+// ParseWeekday parses an English day name such as "monday" or "Sun".
+func ParseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(s)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", s)
+}
+
+// Date formats the date of t.
+func (l Locale) Date(t time.Time) string {
+	var layout []string
+	switch l.DateOrder {
+	case DateDMY:
+		layout = []string{"02", "01", "2006"}
+	case DateMDY:
+		layout = []string{"01", "02", "2006"}
+	default:
+		layout = []string{"2006", "01", "02"}
+	}
+	return t.Format(strings.Join(layout, l.DateSep))
+}
+
+// Time formats the time of day of t.
+func (l Locale) Time(t time.Time) string {
+	if l.Clock24 {
+		return t.Format("15:04")
+	}
+	return t.Format("3:04 PM")
+}
+
+// DateTime formats t as a date and time of day.
+func (l Locale) DateTime(t time.Time) string {
+	return l.Date(t) + " " + l.Time(t)
+}
+
+// Timestamp formats t as a date and time of day with seconds.
+func (l Locale) Timestamp(t time.Time) string {
+	if l.Clock24 {
+		return l.Date(t) + " " + t.Format("15:04:05")
+	}
+	return l.Date(t) + " " + t.Format("3:04:05 PM")
+}
+
+// Number formats n with digits grouped in thousands.
+func (l Locale) Number(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	if l.Thousands == "" {
+		return s
+	}
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteString(l.Thousands)
+		}
+		b.WriteRune(r)
+	}
+	return sign + b.String()
+}
+
+// StartOfWeek returns midnight on the first day of the week containing t,
+// in t's location.
+func (l Locale) StartOfWeek(t time.Time) time.Time {
+	offset := (int(t.Weekday()) - int(l.WeekStart) + 7) % 7
+	y, m, d := t.Date()
+	return time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,107 @@
+package render
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocaleFor(t *testing.T) {
+	at := time.Date(2024, 3, 7, 14, 5, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		date      string
+		time      string
+		weekStart time.Weekday
+		number    string
+	}{
+		{"en_US.UTF-8", "03/07/2024", "2:05 PM", time.Sunday, "1,234,567"},
+		{"en_GB.UTF-8", "07/03/2024", "14:05", time.Monday, "1,234,567"},
+		{"de_DE.UTF-8@euro", "07.03.2024", "14:05", time.Monday, "1.234.567"},
+		{"de-CH", "07.03.2024", "14:05", time.Monday, "1'234'567"},
+		{"fr", "07/03/2024", "14:05", time.Monday, "1 234 567"},
+		{"ja_JP", "2024/03/07", "14:05", time.Sunday, "1,234,567"},
+		{"C", "2024-03-07", "14:05", time.Monday, "1234567"},
+		{"", "2024-03-07", "14:05", time.Monday, "1234567"},
+		{"xx_ZZ", "2024-03-07", "14:05", time.Monday, "1234567"},
+	}
+	for _, tt := range tests {
+		l := LocaleFor(tt.name)
+		if got := l.Date(at); got != tt.date {
+			t.Errorf("%q: Date = %q, want %q", tt.name, got, tt.date)
+		}
+		if got := l.Time(at); got != tt.time {
+			t.Errorf("%q: Time = %q, want %q", tt.name, got, tt.time)
+		}
+		if l.WeekStart != tt.weekStart {
+			t.Errorf("%q: WeekStart = %v, want %v", tt.name, l.WeekStart, tt.weekStart)
+		}
+		if got := l.Number(1234567); got != tt.number {
+			t.Errorf("%q: Number = %q, want %q", tt.name, got, tt.number)
+		}
+	}
+}
+
+func TestDetectLocale(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_TIME", "de_DE.UTF-8")
+	t.Setenv("LANG", "en_US.UTF-8")
+	if got := DetectLocale(); got != territories["DE"] {
+		t.Errorf("DetectLocale with LC_TIME=de_DE = %+v, want German conventions", got)
+	}
+	t.Setenv("LC_ALL", "C")
+	if got := DetectLocale(); got != ISO {
+		t.Errorf("DetectLocale with LC_ALL=C = %+v, want ISO", got)
+	}
+}
+
+func TestNumber(t *testing.T) {
+	l := Locale{Thousands: ","}
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{-1234, "-1,234"},
+		{100000, "100,000"},
+	}
+	for _, tt := range tests {
+		if got := l.Number(tt.in); got != tt.want {
+			t.Errorf("Number(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStartOfWeek(t *testing.T) {
+	thu := time.Date(2024, 3, 7, 14, 5, 0, 0, time.UTC)
+	sun := time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		at        time.Time
+		weekStart time.Weekday
+		want      string
+	}{
+		{thu, time.Monday, "2024-03-04"},
+		{thu, time.Sunday, "2024-03-03"},
+		{thu, time.Saturday, "2024-03-02"},
+		{sun, time.Monday, "2024-03-04"},
+		{sun, time.Sunday, "2024-03-10"},
+	}
+	for _, tt := range tests {
+		got := Locale{WeekStart: tt.weekStart}.StartOfWeek(tt.at)
+		if got.Format("2006-01-02 15:04") != tt.want+" 00:00" {
+			t.Errorf("StartOfWeek(%s, %v) = %s, want %s", tt.at.Weekday(), tt.weekStart, got, tt.want)
+		}
+	}
+}
+
+func TestParseWeekday(t *testing.T) {
+	for _, s := range []string{"monday", "Mon", "MONDAY"} {
+		if d, err := ParseWeekday(s); err != nil || d != time.Monday {
+			t.Errorf("ParseWeekday(%q) = %v, %v; want Monday", s, d, err)
+		}
+	}
+	if _, err := ParseWeekday("funday"); err == nil {
+		t.Error("ParseWeekday(funday) succeeded, want error")
+	}
+}
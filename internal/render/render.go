@@ -0,0 +1,251 @@
+// Package render formats terminal output: headings, key/value lists and
+// aligned tables, with optional ANSI colors from a Theme.
+package render
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Theme maps output roles to ANSI SGR sequences. An empty sequence leaves
+// text unstyled.
+type Theme struct {
+	Heading string // Report titles
+	Label   string // Table headers and key/value labels
+	Muted   string // Secondary details (locations, SQL, timestamps)
+	Good    string // Successes and additions
+	Bad     string // Errors and deletions
+	Warn    string // Warnings and skipped items
+	Accent  string // Names and highlights
+}
+
+// DefaultTheme is used when color is enabled.
+var DefaultTheme = Theme{
+	Heading: "\x1b[1m",
+	Label:   "\x1b[1m",
+	Muted:   "\x1b[2m",
+	Good:    "\x1b[32m",
+	Bad:     "\x1b[31m",
+	Warn:    "\x1b[33m",
+	Accent:  "\x1b[36m",
+}
+
+// NoColor leaves all text unstyled.
+var NoColor = Theme{}
+
+// Color modes accepted by ColorEnabled.
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// ColorEnabled decides whether to color output written to f. In auto mode,
+// color is used only when f is a terminal, NO_COLOR is unset or empty
+// (https://no-color.org), and TERM isn't "dumb".
+func ColorEnabled(mode string, f *os.File) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// Renderer writes styled output.
+type Renderer struct {
+	w      io.Writer
+	theme  Theme
+	locale Locale
+}
+
+// New creates a renderer writing to w with theme, formatting dates and
+// numbers as ISO until SetLocale is called.
+func New(w io.Writer, theme Theme) *Renderer {
+	return &Renderer{w: w, theme: theme, locale: ISO}
+}
+
+// SetLocale sets the conventions used for dates, times and numbers.
+func (r *Renderer) SetLocale(l Locale) { r.locale = l }
+
+// Locale returns the renderer's locale, e.g. for week boundaries.
+func (r *Renderer) Locale() Locale { return r.locale }
+
+// Date formats the date of t in the renderer's locale.
+func (r *Renderer) Date(t time.Time) string { return r.locale.Date(t) }
+
+// Time formats the time of day of t in the renderer's locale.
+func (r *Renderer) Time(t time.Time) string { return r.locale.Time(t) }
+
+// DateTime formats t as a date and time of day in the renderer's locale.
+func (r *Renderer) DateTime(t time.Time) string { return r.locale.DateTime(t) }
+
+// Timestamp formats t with seconds in the renderer's locale.
+func (r *Renderer) Timestamp(t time.Time) string { return r.locale.Timestamp(t) }
+
+// Number formats n with the renderer's digit grouping.
+func (r *Renderer) Number(n int64) string { return r.locale.Number(n) }
+
+func style(seq, s string) string {
+	if seq == "" || s == "" {
+		return s
+	}
+	return seq + s + "\x1b[0m"
+}
+
+// Heading styles s as a report title.
+func (r *Renderer) Heading(s string) string { return style(r.theme.Heading, s) }
+
+// Label styles s as a header or label.
+func (r *Renderer) Label(s string) string { return style(r.theme.Label, s) }
+
+// Muted styles s as secondary detail.
+func (r *Renderer) Muted(s string) string { return style(r.theme.Muted, s) }
+
+// Good styles s as a success.
+func (r *Renderer) Good(s string) string { return style(r.theme.Good, s) }
+
+// Bad styles s as an error.
+func (r *Renderer) Bad(s string) string { return style(r.theme.Bad, s) }
+
+// Warn styles s as a warning.
+func (r *Renderer) Warn(s string) string { return style(r.theme.Warn, s) }
+
+// Accent styles s as a highlight.
+func (r *Renderer) Accent(s string) string { return style(r.theme.Accent, s) }
+
+// Printf writes formatted output.
+func (r *Renderer) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(r.w, format, args...)
+}
+
+// Println writes its arguments followed by a newline.
+func (r *Renderer) Println(args ...interface{}) {
+	fmt.Fprintln(r.w, args...)
+}
+
+// Title writes a report title underlined with '='.
+func (r *Renderer) Title(title string) {
+	fmt.Fprintln(r.w, r.Heading(title))
+	fmt.Fprintln(r.w, r.Muted(strings.Repeat("=", Width(title))))
+}
+
+// KeyValues writes indented "key: value" lines with values aligned. Pairs
+// alternate keys and values.
+func (r *Renderer) KeyValues(pairs ...string) {
+	width := 0
+	for i := 0; i < len(pairs); i += 2 {
+		width = max(width, Width(pairs[i])+1)
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		key := pairs[i] + ":"
+		fmt.Fprintf(r.w, "  %s%s %s\n", r.Label(key), strings.Repeat(" ", width-Width(key)), pairs[i+1])
+	}
+}
+
+// Table writes t with columns aligned and the header styled as labels.
+func (r *Renderer) Table(t *Table) {
+	widths := make([]int, len(t.headers))
+	for i, h := range t.headers {
+		widths[i] = Width(h)
+	}
+	for _, row := range t.rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], Width(cell))
+		}
+	}
+
+	header := make([]string, len(t.headers))
+	for i, h := range t.headers {
+		header[i] = r.Label(h)
+	}
+	r.tableRow(t, widths, header)
+	for _, row := range t.rows {
+		r.tableRow(t, widths, row)
+	}
+}
+
+func (r *Renderer) tableRow(t *Table, widths []int, cells []string) {
+	var b strings.Builder
+	b.WriteString("  ")
+	for i, cell := range cells {
+		pad := strings.Repeat(" ", widths[i]-Width(cell))
+		if i > 0 {
+			b.WriteString("  ")
+		}
+		switch {
+		case t.right[i]:
+			b.WriteString(pad + cell)
+		case i == len(cells)-1:
+			b.WriteString(cell) // No trailing spaces
+		default:
+			b.WriteString(cell + pad)
+		}
+	}
+	fmt.Fprintln(r.w, b.String())
+}
+
+// Table is a set of rows rendered with aligned columns.
+type Table struct {
+	headers []string
+	right   []bool
+	rows    [][]string
+}
+
+// NewTable creates a table with the given column headers. Columns are
+// left-aligned unless set with AlignRight.
+func NewTable(headers ...string) *Table {
+	return &Table{headers: headers, right: make([]bool, len(headers))}
+}
+
+// AlignRight right-aligns the given columns, typically numbers.
+func (t *Table) AlignRight(cols ...int) *Table {
+	for _, c := range cols {
+		t.right[c] = true
+	}
+	return t
+}
+
+// Row appends a row. Missing cells are blank; extra cells are dropped.
+func (t *Table) Row(cells ...string) {
+	row := make([]string, len(t.headers))
+	copy(row, cells)
+	t.rows = append(t.rows, row)
+}
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// Width returns the number of columns s occupies, ignoring ANSI styling.
+func Width(s string) int {
+	return utf8.RuneCountInString(ansiEscape.ReplaceAllString(s, ""))
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws values as a row of bars scaled to the largest, with a
+// space for zero.
+func Sparkline(values []int) string {
+	top := 0
+	for _, v := range values {
+		top = max(top, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		if v <= 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		b.WriteRune(sparks[(v*len(sparks)-1)/top])
+	}
+	return b.String()
+}
@@ -0,0 +1,127 @@
+package render
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTable(t *testing.T) {
+	tests := []struct {
+		name  string
+		theme Theme
+		want  string
+	}{
+		{
+			name:  "plain",
+			theme: NoColor,
+			want: "" +
+				"  Calendar   Events\n" +
+				"  Work           12\n" +
+				"  Birthdays       3\n" +
+				"  Café         1024\n",
+		},
+		{
+			name:  "colored cells keep alignment",
+			theme: DefaultTheme,
+			want: "" +
+				"  \x1b[1mCalendar\x1b[0m   \x1b[1mEvents\x1b[0m\n" +
+				"  Work           12\n" +
+				"  Birthdays       3\n" +
+				"  Café         1024\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			r := New(&buf, tt.theme)
+			tbl := NewTable("Calendar", "Events").AlignRight(1)
+			tbl.Row("Work", "12")
+			tbl.Row("Birthdays", "3")
+			tbl.Row("Café", "1024")
+			r.Table(tbl)
+			if buf.String() != tt.want {
+				t.Errorf("table =\n%q\nwant\n%q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyValues(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, NoColor).KeyValues("Accounts", "2", "Total events", "1500")
+	want := "  Accounts:     2\n  Total events: 1500\n"
+	if buf.String() != want {
+		t.Errorf("key values =\n%q\nwant\n%q", buf.String(), want)
+	}
+}
+
+func TestWidth(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"\x1b[1mabc\x1b[0m", 3},
+		{"\x1b[1;32m✓ ok\x1b[0m", 4},
+	}
+	for _, tt := range tests {
+		if got := Width(tt.in); got != tt.want {
+			t.Errorf("Width(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		in   []int
+		want string
+	}{
+		{nil, ""},
+		{[]int{0, 0}, "  "},
+		{[]int{1, 8, 4, 0}, "▁█▄ "},
+		{[]int{3, 3}, "██"},
+	}
+	for _, tt := range tests {
+		if got := Sparkline(tt.in); got != tt.want {
+			t.Errorf("Sparkline(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm")
+	tests := []struct {
+		mode    string
+		noColor string
+		want    bool
+	}{
+		{ColorAlways, "1", true},
+		{ColorNever, "", false},
+		{ColorAuto, "", false}, // Not a terminal
+		{ColorAuto, "1", false},
+	}
+	for _, tt := range tests {
+		t.Setenv("NO_COLOR", tt.noColor)
+		if got := ColorEnabled(tt.mode, f); got != tt.want {
+			t.Errorf("ColorEnabled(%q) with NO_COLOR=%q = %v, want %v", tt.mode, tt.noColor, got, tt.want)
+		}
+	}
+}
+
+func TestStyleNoColor(t *testing.T) {
+	r := New(&bytes.Buffer{}, NoColor)
+	if got := r.Good("added"); strings.Contains(got, "\x1b") {
+		t.Errorf("Good with NoColor = %q, want unstyled", got)
+	}
+}
@@ -0,0 +1,360 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// Account is an account as listed by accounts.list.
+type Account struct {
+	ID      int64  `json:"id"`
+	Email   string `json:"email"`
+	Type    string `json:"type"`
+	Owner   string `json:"owner,omitempty"`
+	Created string `json:"created"`
+}
+
+func (srv *Server) accountsList(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	if err := decodeParams(params, &struct{}{}); err != nil {
+		return nil, err
+	}
+	sources, err := srv.store.ListSources()
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]Account, 0, len(sources))
+	for _, src := range sources {
+		accounts = append(accounts, Account{
+			ID:      src.ID,
+			Email:   src.Identifier,
+			Type:    src.SourceType,
+			Owner:   src.Owner,
+			Created: src.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return accounts, nil
+}
+
+// Calendar is a calendar as listed by calendars.list.
+type Calendar struct {
+	ID         int64  `json:"id"`
+	CalendarID string `json:"calendar_id"`
+	Name       string `json:"name"`
+	Account    string `json:"account"`
+	Timezone   string `json:"timezone,omitempty"`
+	Primary    bool   `json:"primary"`
+}
+
+func (srv *Server) calendarsList(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Account string `json:"account"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	sources, err := srv.store.ListSources()
+	if err != nil {
+		return nil, err
+	}
+	calendars := []Calendar{}
+	found := p.Account == ""
+	for _, src := range sources {
+		if p.Account != "" && src.Identifier != p.Account {
+			continue
+		}
+		found = true
+		cals, err := srv.store.GetCalendars(src.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cals {
+			calendars = append(calendars, Calendar{
+				ID:         c.ID,
+				CalendarID: c.GoogleCalendarID,
+				Name:       c.Summary,
+				Account:    src.Identifier,
+				Timezone:   c.Timezone,
+				Primary:    c.IsPrimary,
+			})
+		}
+	}
+	if !found {
+		return nil, invalidParams("account %q not found", p.Account)
+	}
+	return calendars, nil
+}
+
+// Event is an event as listed by events.list and events.search, in the
+// shape of 'calvault events --json'.
+type Event struct {
+	ID        string   `json:"id"`
+	Account   string   `json:"account"`
+	Calendar  string   `json:"calendar"`
+	Summary   string   `json:"summary"`
+	Start     string   `json:"start,omitempty"`
+	End       string   `json:"end,omitempty"`
+	AllDay    bool     `json:"all_day"`
+	Location  string   `json:"location,omitempty"`
+	Status    string   `json:"status,omitempty"`
+	Organizer string   `json:"organizer,omitempty"`
+	Attendees []string `json:"attendees"`
+	Snippet   string   `json:"snippet,omitempty"` // events.search only
+}
+
+// eventsListParams are the filters of events.list; see EventFilter. To is
+// inclusive and Limit defaults to 100 (0 = no limit).
+type eventsListParams struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Account  string `json:"account"`
+	Calendar string `json:"calendar"`
+	Attendee string `json:"attendee"`
+	Location string `json:"location"`
+	Status   string `json:"status"`
+	Limit    *int   `json:"limit"`
+}
+
+func (srv *Server) eventsList(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p eventsListParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	filter := store.EventFilter{
+		Attendee: p.Attendee,
+		Location: p.Location,
+		Status:   p.Status,
+		Limit:    100,
+	}
+	var err error
+	if filter.Since, err = parseDate("from", p.From); err != nil {
+		return nil, err
+	}
+	if filter.Until, err = parseDate("to", p.To); err != nil {
+		return nil, err
+	}
+	if !filter.Until.IsZero() {
+		filter.Until = filter.Until.AddDate(0, 0, 1)
+	}
+	switch p.Status {
+	case "", "confirmed", "tentative", "cancelled":
+	default:
+		return nil, invalidParams("invalid status %q (expected confirmed, tentative or cancelled)", p.Status)
+	}
+	if p.Limit != nil {
+		if *p.Limit < 0 {
+			return nil, invalidParams("limit must not be negative")
+		}
+		filter.Limit = *p.Limit
+	}
+	if filter.SourceID, err = srv.sourceID(p.Account); err != nil {
+		return nil, err
+	}
+	if p.Calendar != "" {
+		cals, err := srv.store.FindCalendars(p.Calendar)
+		if err != nil {
+			return nil, err
+		}
+		switch len(cals) {
+		case 0:
+			return nil, invalidParams("calendar %q not found", p.Calendar)
+		case 1:
+			filter.CalendarID = cals[0].ID
+		default:
+			return nil, invalidParams("calendar %q is ambiguous (%d matches) - use the numeric calendar ID", p.Calendar, len(cals))
+		}
+	}
+
+	events, err := srv.store.ListEvents(filter)
+	if err != nil {
+		return nil, err
+	}
+	return srv.eventEntries(events, nil)
+}
+
+func (srv *Server) eventsSearch(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Query   string `json:"query"`
+		Account string `json:"account"`
+		Limit   *int   `json:"limit"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Query == "" {
+		return nil, invalidParams("missing query")
+	}
+	opts := store.SearchOptions{Limit: 50}
+	if p.Limit != nil {
+		if *p.Limit <= 0 {
+			return nil, invalidParams("limit must be positive")
+		}
+		opts.Limit = *p.Limit
+	}
+	var err error
+	if opts.SourceID, err = srv.sourceID(p.Account); err != nil {
+		return nil, err
+	}
+
+	results, err := srv.store.SearchEvents(p.Query, opts)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*store.Event, len(results))
+	snippets := make([]string, len(results))
+	for i, r := range results {
+		events[i], snippets[i] = r.Event, r.Snippet
+	}
+	return srv.eventEntries(events, snippets)
+}
+
+// eventEntries converts events to their JSON shape, with snippets if given.
+func (srv *Server) eventEntries(events []*store.Event, snippets []string) ([]Event, error) {
+	sources, err := srv.store.ListSources()
+	if err != nil {
+		return nil, err
+	}
+	accounts := make(map[int64]string, len(sources))
+	calendars := map[int64]string{}
+	for _, src := range sources {
+		accounts[src.ID] = src.Identifier
+		cals, err := srv.store.GetCalendars(src.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cals {
+			calendars[c.ID] = c.Summary
+			if c.Summary == "" {
+				calendars[c.ID] = c.GoogleCalendarID
+			}
+		}
+	}
+
+	entries := make([]Event, 0, len(events))
+	for i, e := range events {
+		attendees, err := srv.store.ListAttendees(e.ID)
+		if err != nil {
+			return nil, err
+		}
+		entry := Event{
+			ID:        e.GoogleEventID,
+			Account:   accounts[e.SourceID],
+			Calendar:  calendars[e.CalendarID],
+			Summary:   e.Summary,
+			AllDay:    e.AllDay,
+			Location:  e.Location,
+			Status:    e.Status,
+			Organizer: e.OrganizerEmail,
+			Attendees: make([]string, 0, len(attendees)),
+		}
+		if e.StartTime.Valid {
+			entry.Start = e.StartTime.Time.Format(time.RFC3339)
+		}
+		if e.EndTime.Valid {
+			entry.End = e.EndTime.Time.Format(time.RFC3339)
+		}
+		for _, a := range attendees {
+			entry.Attendees = append(entry.Attendees, a.Email)
+		}
+		if snippets != nil && snippets[i] != e.Summary {
+			entry.Snippet = snippets[i]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Stats is the result of stats.
+type Stats struct {
+	Accounts        int    `json:"accounts"`
+	Calendars       int    `json:"calendars"`
+	Events          int    `json:"events"`
+	Earliest        string `json:"earliest,omitempty"`
+	Latest          string `json:"latest,omitempty"`
+	UniqueLocations int    `json:"unique_locations"`
+	Recurring       int    `json:"recurring"`
+}
+
+func (srv *Server) stats(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	if err := decodeParams(params, &struct{}{}); err != nil {
+		return nil, err
+	}
+	st, err := srv.store.GetStats()
+	if err != nil {
+		return nil, err
+	}
+	result := &Stats{
+		Accounts:        st.AccountCount,
+		Calendars:       st.CalendarCount,
+		Events:          st.EventCount,
+		UniqueLocations: st.UniqueLocations,
+		Recurring:       st.RecurringCount,
+	}
+	if !st.EarliestEvent.IsZero() {
+		result.Earliest = st.EarliestEvent.Format(time.RFC3339)
+	}
+	if !st.LatestEvent.IsZero() {
+		result.Latest = st.LatestEvent.Format(time.RFC3339)
+	}
+	return result, nil
+}
+
+// query runs read-only SQL, binding params to its :name placeholders.
+func (srv *Server) query(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		SQL    string            `json:"sql"`
+		Params map[string]string `json:"params"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.SQL == "" {
+		return nil, invalidParams("missing sql")
+	}
+	return srv.executor.ExecuteParams(ctx, p.SQL, p.Params)
+}
+
+// queryNamed runs a named query from config.
+func (srv *Server) queryNamed(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name   string            `json:"name"`
+		Params map[string]string `json:"params"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Name == "" {
+		return nil, invalidParams("missing name")
+	}
+	return srv.executor.ExecuteNamed(ctx, p.Name, p.Params)
+}
+
+// sourceID resolves an account email to its source ID; "" is 0 (any).
+func (srv *Server) sourceID(account string) (int64, error) {
+	if account == "" {
+		return 0, nil
+	}
+	src, err := srv.store.GetSourceByIdentifier(account)
+	if err != nil {
+		return 0, err
+	}
+	if src == nil {
+		return 0, invalidParams("account %q not found", account)
+	}
+	return src.ID, nil
+}
+
+// parseDate parses a YYYY-MM-DD date param in local time; "" is the zero time.
+func parseDate(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, invalidParams("invalid %s %q (expected YYYY-MM-DD)", name, value)
+	}
+	return t, nil
+}
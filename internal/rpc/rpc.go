@@ -0,0 +1,159 @@
+// Package rpc answers single JSON-RPC 2.0 requests against the vault, so
+// automation tools that can run a command but can't compose SQL can still
+// list and search events.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/salman1993/calvault/internal/query"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// Error codes. The negative codes below -32000 are defined by JSON-RPC 2.0;
+// CodeFailed reports a method that was called correctly but failed.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeFailed         = -32000
+)
+
+// Request is a JSON-RPC request. The jsonrpc version and id are optional;
+// the id is echoed in the response.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC response carrying either a result or an error.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// invalidParams reports a request whose params can't be used.
+func invalidParams(format string, args ...interface{}) error {
+	return &Error{Code: CodeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// method answers a request's params with a JSON-encodable result.
+type method func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+// Server dispatches requests to methods reading the store. SQL methods run
+// through the executor, which applies the query command's scoping and
+// allowlist; the other methods read the store directly, so they are
+// refused when the executor is restricted.
+type Server struct {
+	store    *store.Store
+	executor *query.Executor
+	methods  map[string]method
+}
+
+// NewServer creates a server reading s, running SQL methods on executor.
+func NewServer(s *store.Store, executor *query.Executor) *Server {
+	srv := &Server{store: s, executor: executor}
+	srv.methods = map[string]method{
+		"accounts.list":  srv.accountsList,
+		"calendars.list": srv.calendarsList,
+		"events.list":    srv.eventsList,
+		"events.search":  srv.eventsSearch,
+		"stats":          srv.stats,
+		"query":          srv.query,
+		"query.named":    srv.queryNamed,
+	}
+	return srv
+}
+
+// sqlMethods run through the executor rather than reading the store.
+var sqlMethods = map[string]bool{"query": true, "query.named": true}
+
+// Methods returns the names of the supported methods, sorted.
+func (srv *Server) Methods() []string {
+	names := make([]string, 0, len(srv.methods))
+	for name := range srv.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Handle decodes one request and answers it. Failures are reported in the
+// response's error.
+func (srv *Server) Handle(ctx context.Context, data []byte) *Response {
+	resp := &Response{JSONRPC: "2.0"}
+
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) || len(bytes.TrimSpace(data)) == 0 {
+			resp.Error = &Error{Code: CodeParseError, Message: fmt.Sprintf("parse error: %v", err)}
+		} else {
+			resp.Error = &Error{Code: CodeInvalidRequest, Message: "request must be a JSON object with a method"}
+		}
+		return resp
+	}
+	resp.ID = req.ID
+
+	if req.Method == "" {
+		resp.Error = &Error{Code: CodeInvalidRequest, Message: "missing method"}
+		return resp
+	}
+	m := srv.methods[req.Method]
+	if m == nil {
+		resp.Error = &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("unknown method %q", req.Method)}
+		return resp
+	}
+
+	if !sqlMethods[req.Method] && srv.executor != nil && srv.executor.Restricted() {
+		resp.Error = &Error{Code: CodeFailed, Message: fmt.Sprintf(
+			"%s is unavailable while [query] allowlist_only, account or team privacy is set; use query or query.named", req.Method)}
+		return resp
+	}
+
+	result, err := m(ctx, req.Params)
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeFailed, Message: err.Error()}
+		}
+		resp.Error = rpcErr
+		return resp
+	}
+	resp.Result = result
+	return resp
+}
+
+// decodeParams decodes params into v, rejecting unknown fields so typos
+// don't silently widen a filter. Missing or null params leave v as is.
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 || string(bytes.TrimSpace(params)) == "null" {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return invalidParams("invalid params: %v", err)
+	}
+	return nil
+}
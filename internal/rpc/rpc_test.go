@@ -0,0 +1,183 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/salman1993/calvault/internal/query"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+func TestServer_Handle(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	s, err := store.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	at := func(day, hour int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2024, 3, day, hour, 0, 0, 0, time.Local), Valid: true}
+	}
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: "primary", Summary: "Work", IsPrimary: true})
+	for _, e := range []*store.Event{
+		{GoogleEventID: "a", Summary: "Dentist", Location: "Clinic", StartTime: at(4, 9), EndTime: at(4, 10)},
+		{GoogleEventID: "b", Summary: "Standup", StartTime: at(5, 9), EndTime: at(5, 10)},
+		{GoogleEventID: "c", Summary: "Retro", StartTime: at(9, 9), EndTime: at(9, 10)},
+	} {
+		e.SourceID, e.CalendarID = src.ID, calID
+		id, err := s.UpsertEvent(e)
+		if err != nil {
+			t.Fatalf("upsert %s: %v", e.GoogleEventID, err)
+		}
+		if e.GoogleEventID == "b" {
+			if err := s.ReplaceAttendees(id, []*store.Attendee{{Email: "dana@example.com"}}); err != nil {
+				t.Fatalf("attendees: %v", err)
+			}
+		}
+	}
+
+	executor, err := query.NewExecutor(dbPath)
+	if err != nil {
+		t.Fatalf("new executor: %v", err)
+	}
+	defer func() { _ = executor.Close() }()
+	srv := NewServer(s, executor)
+
+	// handle answers a request and round-trips the response through JSON.
+	handle := func(request string) (json.RawMessage, *Error, string) {
+		t.Helper()
+		data, err := json.Marshal(srv.Handle(context.Background(), []byte(request)))
+		if err != nil {
+			t.Fatalf("marshal response: %v", err)
+		}
+		var resp struct {
+			ID     json.RawMessage `json:"id"`
+			Result json.RawMessage `json:"result"`
+			Error  *Error          `json:"error"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			t.Fatalf("unmarshal response: %v", err)
+		}
+		return resp.Result, resp.Error, string(resp.ID)
+	}
+
+	result, rpcErr, id := handle(`{"jsonrpc":"2.0","id":"x1","method":"events.list","params":{"from":"2024-03-04","to":"2024-03-05"}}`)
+	if rpcErr != nil {
+		t.Fatalf("events.list: %v", rpcErr)
+	}
+	if id != `"x1"` {
+		t.Errorf("id = %s, want \"x1\"", id)
+	}
+	var events []Event
+	if err := json.Unmarshal(result, &events); err != nil {
+		t.Fatalf("decode events: %v", err)
+	}
+	if len(events) != 2 || events[0].Summary != "Dentist" || events[1].Attendees[0] != "dana@example.com" {
+		t.Errorf("events = %+v, want Dentist and Standup (to is inclusive)", events)
+	}
+	if events[0].Calendar != "Work" || events[0].Account != "me@example.com" {
+		t.Errorf("event = %+v, want calendar and account names", events[0])
+	}
+
+	result, rpcErr, _ = handle(`{"method":"query","params":{"sql":"SELECT COUNT(*) FROM events WHERE location = :loc","params":{"loc":"Clinic"}}}`)
+	if rpcErr != nil {
+		t.Fatalf("query: %v", rpcErr)
+	}
+	var qr query.QueryResult
+	if err := json.Unmarshal(result, &qr); err != nil {
+		t.Fatalf("decode query result: %v", err)
+	}
+	if len(qr.Rows) != 1 || qr.Rows[0][0] != float64(1) {
+		t.Errorf("query rows = %v, want [[1]]", qr.Rows)
+	}
+
+	result, rpcErr, _ = handle(`{"method":"calendars.list"}`)
+	if rpcErr != nil {
+		t.Fatalf("calendars.list: %v", rpcErr)
+	}
+	var cals []Calendar
+	if err := json.Unmarshal(result, &cals); err != nil {
+		t.Fatalf("decode calendars: %v", err)
+	}
+	if len(cals) != 1 || cals[0].Name != "Work" || !cals[0].Primary {
+		t.Errorf("calendars = %+v, want primary Work", cals)
+	}
+
+	tests := []struct {
+		name    string
+		request string
+		code    int
+	}{
+		{"malformed", `{"method":`, CodeParseError},
+		{"batch", `[{"method":"stats"}]`, CodeInvalidRequest},
+		{"no method", `{"params":{}}`, CodeInvalidRequest},
+		{"unknown method", `{"method":"events.delete"}`, CodeMethodNotFound},
+		{"unknown param", `{"method":"events.list","params":{"form":"2024-03-01"}}`, CodeInvalidParams},
+		{"bad date", `{"method":"events.list","params":{"from":"March 1"}}`, CodeInvalidParams},
+		{"bad status", `{"method":"events.list","params":{"status":"maybe"}}`, CodeInvalidParams},
+		{"unknown account", `{"method":"calendars.list","params":{"account":"x@example.com"}}`, CodeInvalidParams},
+		{"missing sql", `{"method":"query","params":{}}`, CodeInvalidParams},
+		{"write rejected", `{"method":"query","params":{"sql":"DELETE FROM events"}}`, CodeFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, rpcErr, _ := handle(tt.request)
+			if rpcErr == nil {
+				t.Fatal("expected error")
+			}
+			if rpcErr.Code != tt.code {
+				t.Errorf("code = %d (%s), want %d", rpcErr.Code, rpcErr.Message, tt.code)
+			}
+		})
+	}
+}
+
+func TestServer_RestrictedExecutor(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	s, err := store.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	for _, email := range []string{"work@example.com", "home@example.com"} {
+		src, _ := s.GetOrCreateSource(email)
+		calID, _ := s.UpsertCalendar(src.ID, &store.Calendar{GoogleCalendarID: "primary", Summary: email})
+		if _, err := s.UpsertEvent(&store.Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: email, Summary: email}); err != nil {
+			t.Fatalf("upsert event: %v", err)
+		}
+	}
+
+	executor, err := query.NewExecutor(dbPath, query.WithSourceScope("work@example.com"))
+	if err != nil {
+		t.Fatalf("new executor: %v", err)
+	}
+	defer func() { _ = executor.Close() }()
+	srv := NewServer(s, executor)
+
+	for _, method := range []string{"accounts.list", "calendars.list", "events.list", "events.search", "stats"} {
+		resp := srv.Handle(context.Background(), []byte(`{"method":"`+method+`"}`))
+		if resp.Error == nil || resp.Error.Code != CodeFailed {
+			t.Errorf("%s under an account scope = %+v, want refused", method, resp)
+		}
+	}
+
+	resp := srv.Handle(context.Background(), []byte(`{"method":"query","params":{"sql":"SELECT summary FROM events"}}`))
+	if resp.Error != nil {
+		t.Fatalf("query: %v", resp.Error)
+	}
+	if qr := resp.Result.(*query.QueryResult); len(qr.Rows) != 1 || qr.Rows[0][0] != "work@example.com" {
+		t.Errorf("query rows = %v, want only work@example.com's event", qr.Rows)
+	}
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AccountSummary describes a source with its archive and sync state.
+type AccountSummary struct {
+	Source
+	Calendars  int64
+	Events     int64
+	LastSync   time.Time // Completion of the latest successful sync; zero if none
+	LastStatus string    // Status of the most recent sync run; "" if never synced
+	LastError  string    // Error of the most recent sync run, if it failed
+}
+
+// ListAccountSummaries returns a summary of every source, by identifier.
+func (s *Store) ListAccountSummaries() ([]*AccountSummary, error) {
+	rows, err := s.db.Query(`
+		SELECT s.id, s.source_type, s.identifier, COALESCE(s.owner, ''), s.created_at,
+		       (SELECT COUNT(*) FROM calendars c WHERE c.source_id = s.id),
+		       (SELECT COUNT(*) FROM events e WHERE e.source_id = s.id),
+		       (SELECT MAX(completed_at) FROM sync_runs r
+		        WHERE r.source_id = s.id AND r.status = 'completed'),
+		       latest.status, COALESCE(latest.error_message, '')
+		FROM sources s
+		LEFT JOIN sync_runs latest ON latest.id = (
+			SELECT id FROM sync_runs r WHERE r.source_id = s.id
+			ORDER BY started_at DESC, id DESC LIMIT 1)
+		ORDER BY s.identifier
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query accounts: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var accounts []*AccountSummary
+	for rows.Next() {
+		a := &AccountSummary{}
+		var lastSync, lastStatus sql.NullString // MAX() loses the column's DATETIME type
+		if err := rows.Scan(&a.ID, &a.SourceType, &a.Identifier, &a.Owner, &a.CreatedAt,
+			&a.Calendars, &a.Events, &lastSync, &lastStatus, &a.LastError); err != nil {
+			return nil, fmt.Errorf("scan account: %w", err)
+		}
+		if lastSync.Valid {
+			if a.LastSync, err = parseTimestamp(lastSync.String); err != nil {
+				return nil, err
+			}
+		}
+		a.LastStatus = lastStatus.String
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AlertSeen is a matching event as an alert rule last saw it.
+type AlertSeen struct {
+	Summary   string
+	Location  string
+	StartTime sql.NullTime
+	EndTime   sql.NullTime
+	Status    string
+}
+
+// AlertMatches returns the events an alert with these keywords watches:
+// those whose title, location or description contains a keyword, ignoring
+// case, and that end at or after now or recur. Flagged events are left out.
+func (s *Store) AlertMatches(keywords []string, now time.Time) ([]*Event, error) {
+	if len(keywords) == 0 {
+		return nil, nil
+	}
+	var match []string
+	var args []interface{}
+	for _, k := range keywords {
+		match = append(match, `summary LIKE ? ESCAPE '\' OR location LIKE ? ESCAPE '\' OR description LIKE ? ESCAPE '\'`)
+		pattern := "%" + escapeLike(k) + "%"
+		args = append(args, pattern, pattern, pattern)
+	}
+	args = append(args, now)
+	rows, err := s.db.Query(`
+		SELECT `+eventColumns+`
+		FROM events
+		WHERE (`+strings.Join(match, " OR ")+`)
+		  AND quality IS NULL
+		  AND (COALESCE(end_time, start_time) >= ? OR COALESCE(recurrence_rule, '') != '')
+		ORDER BY start_time, id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query alert matches: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var events []*Event
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// StartAlertRule records that an alert rule is being checked, reporting
+// whether this is its first check.
+func (s *Store) StartAlertRule(name string) (bool, error) {
+	res, err := s.db.Exec(`INSERT OR IGNORE INTO alert_rules (name, created_at) VALUES (?, ?)`, name, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("start alert rule: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("start alert rule: %w", err)
+	}
+	return n > 0, nil
+}
+
+// GetAlertSeen returns the events an alert rule has seen, by event ID.
+func (s *Store) GetAlertSeen(rule string) (map[int64]*AlertSeen, error) {
+	rows, err := s.db.Query(`
+		SELECT event_id, COALESCE(summary, ''), COALESCE(location, ''), start_time, end_time, COALESCE(status, '')
+		FROM alert_events WHERE rule = ?
+	`, rule)
+	if err != nil {
+		return nil, fmt.Errorf("query alert events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	seen := make(map[int64]*AlertSeen)
+	for rows.Next() {
+		var id int64
+		a := &AlertSeen{}
+		if err := rows.Scan(&id, &a.Summary, &a.Location, &a.StartTime, &a.EndTime, &a.Status); err != nil {
+			return nil, fmt.Errorf("scan alert event: %w", err)
+		}
+		seen[id] = a
+	}
+	return seen, rows.Err()
+}
+
+// RecordAlertSeen stores an event as an alert rule saw it, and whether it
+// alerted this time.
+func (s *Store) RecordAlertSeen(rule string, e *Event, alerted bool) error {
+	var alertedAt interface{}
+	if alerted {
+		alertedAt = time.Now()
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO alert_events (rule, event_id, summary, location, start_time, end_time, status, alerted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(rule, event_id) DO UPDATE SET
+			summary = excluded.summary,
+			location = excluded.location,
+			start_time = excluded.start_time,
+			end_time = excluded.end_time,
+			status = excluded.status,
+			alerted_at = COALESCE(excluded.alerted_at, alert_events.alerted_at)
+	`, rule, e.ID, e.Summary, e.Location, e.StartTime, e.EndTime, e.Status, alertedAt)
+	if err != nil {
+		return fmt.Errorf("record alert event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// DurationBucket counts timed events whose duration falls within a range.
+type DurationBucket struct {
+	Label      string
+	MaxMinutes float64 // Upper bound (inclusive); 0 means unbounded
+	Recurring  int
+	AdHoc      int
+}
+
+// DurationSummary holds aggregate duration statistics for a set of events.
+type DurationSummary struct {
+	Count         int
+	TotalMinutes  float64
+	MedianMinutes float64
+	P90Minutes    float64
+}
+
+// DurationTrend holds duration statistics for a single month.
+type DurationTrend struct {
+	Month     string // YYYY-MM
+	Recurring DurationSummary
+	AdHoc     DurationSummary
+}
+
+// DurationReport describes the distribution of meeting durations.
+type DurationReport struct {
+	Buckets   []DurationBucket
+	Recurring DurationSummary
+	AdHoc     DurationSummary
+	Trend     []DurationTrend
+}
+
+// durationBuckets defines the upper bounds used for the duration distribution.
+var durationBuckets = []DurationBucket{
+	{Label: "0-15m", MaxMinutes: 15},
+	{Label: "15-30m", MaxMinutes: 30},
+	{Label: "30-60m", MaxMinutes: 60},
+	{Label: "60-90m", MaxMinutes: 90},
+	{Label: "90m+", MaxMinutes: 0},
+}
+
+// GetDurationReport returns the duration distribution of timed events.
+// All-day, cancelled and quality-flagged events are ignored. If since is
+// non-zero, only events starting at or after since are included.
+func (s *Store) GetDurationReport(since time.Time) (*DurationReport, error) {
+	query := `
+		SELECT start_time, end_time,
+		       (COALESCE(recurring_event_id, '') != '' OR COALESCE(recurrence_rule, '') != '')
+		FROM events
+		WHERE start_time IS NOT NULL AND end_time IS NOT NULL
+		  AND all_day = FALSE
+		  AND quality IS NULL
+		  AND COALESCE(status, '') != 'cancelled'`
+	var args []interface{}
+	if !since.IsZero() {
+		cond, condArgs := startBound("", ">=", since)
+		query += ` AND ` + cond
+		args = append(args, condArgs...)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query durations: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	report := &DurationReport{
+		Buckets: make([]DurationBucket, len(durationBuckets)),
+	}
+	copy(report.Buckets, durationBuckets)
+
+	var recurring, adHoc []float64
+	monthly := make(map[string]*[2][]float64) // [0] recurring, [1] ad-hoc
+
+	for rows.Next() {
+		var start, end time.Time
+		var isRecurring bool
+		if err := rows.Scan(&start, &end, &isRecurring); err != nil {
+			return nil, fmt.Errorf("scan duration: %w", err)
+		}
+
+		minutes := end.Sub(start).Minutes()
+		if minutes < 0 {
+			continue
+		}
+
+		for i := range report.Buckets {
+			b := &report.Buckets[i]
+			if b.MaxMinutes == 0 || minutes <= b.MaxMinutes {
+				if isRecurring {
+					b.Recurring++
+				} else {
+					b.AdHoc++
+				}
+				break
+			}
+		}
+
+		month := start.UTC().Format("2006-01")
+		m, ok := monthly[month]
+		if !ok {
+			m = &[2][]float64{}
+			monthly[month] = m
+		}
+		if isRecurring {
+			recurring = append(recurring, minutes)
+			m[0] = append(m[0], minutes)
+		} else {
+			adHoc = append(adHoc, minutes)
+			m[1] = append(m[1], minutes)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	report.Recurring = summarizeDurations(recurring)
+	report.AdHoc = summarizeDurations(adHoc)
+
+	months := make([]string, 0, len(monthly))
+	for month := range monthly {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+	for _, month := range months {
+		m := monthly[month]
+		report.Trend = append(report.Trend, DurationTrend{
+			Month:     month,
+			Recurring: summarizeDurations(m[0]),
+			AdHoc:     summarizeDurations(m[1]),
+		})
+	}
+
+	return report, nil
+}
+
+// summarizeDurations computes count, total, median, and p90 for durations in minutes.
+func summarizeDurations(minutes []float64) DurationSummary {
+	summary := DurationSummary{Count: len(minutes)}
+	if len(minutes) == 0 {
+		return summary
+	}
+
+	sorted := make([]float64, len(minutes))
+	copy(sorted, minutes)
+	sort.Float64s(sorted)
+
+	for _, m := range sorted {
+		summary.TotalMinutes += m
+	}
+	summary.MedianMinutes = percentile(sorted, 50)
+	summary.P90Minutes = percentile(sorted, 90)
+	return summary
+}
+
+// percentile returns the p-th percentile of sorted values using the nearest-rank method.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
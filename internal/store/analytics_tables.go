@@ -0,0 +1,170 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// meetingCondition selects events that count as meetings in the
+// materialized analytics tables: timed, plausibly dated, not cancelled, not
+// declined, and not tombstoned under legal hold.
+const meetingCondition = `
+	e.start_time IS NOT NULL AND e.end_time IS NOT NULL
+	AND e.all_day = FALSE
+	AND e.quality IS NULL
+	AND COALESCE(e.status, '') != 'cancelled'
+	AND NOT EXISTS (SELECT 1 FROM event_tombstones t WHERE t.event_id = e.id)
+	AND NOT EXISTS (
+		SELECT 1 FROM attendees s
+		WHERE s.event_id = e.id AND s.is_self AND s.response_status = 'declined'
+	)`
+
+// meetingMinutes is an event's duration in minutes.
+const meetingMinutes = `e.duration_minutes`
+
+// AnalyticsRefresh summarizes a refresh of the materialized analytics tables.
+type AnalyticsRefresh struct {
+	FullRebuild   bool
+	DaysUpdated   int
+	PeopleUpdated int
+}
+
+// RefreshAnalyticsTables brings daily_meeting_minutes and person_meeting_counts
+// up to date. Only days and people recorded as stale by the schema triggers
+// are recomputed; the first refresh rebuilds everything.
+func (s *Store) RefreshAnalyticsTables() (*AnalyticsRefresh, error) {
+	var builtAt sql.NullTime
+	err := s.db.QueryRow(`SELECT built_at FROM analytics_state WHERE id = 1`).Scan(&builtAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("get analytics state: %w", err)
+	}
+	if !builtAt.Valid {
+		return s.RebuildAnalyticsTables()
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result := &AnalyticsRefresh{}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM analytics_dirty WHERE kind = 'day'`).Scan(&result.DaysUpdated); err != nil {
+		return nil, fmt.Errorf("count dirty days: %w", err)
+	}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM analytics_dirty WHERE kind = 'person'`).Scan(&result.PeopleUpdated); err != nil {
+		return nil, fmt.Errorf("count dirty people: %w", err)
+	}
+
+	statements := []struct {
+		name  string
+		query string
+	}{
+		{"clear stale days", `
+			DELETE FROM daily_meeting_minutes
+			WHERE EXISTS (
+				SELECT 1 FROM analytics_dirty d
+				WHERE d.kind = 'day' AND d.source_id = daily_meeting_minutes.source_id
+				  AND d.key = daily_meeting_minutes.day
+			)`},
+		{"recompute days", `
+			INSERT INTO daily_meeting_minutes (source_id, day, meeting_count, meeting_minutes)
+			SELECT e.source_id, e.start_date, COUNT(*), SUM(` + meetingMinutes + `)
+			FROM events e
+			JOIN analytics_dirty d
+			  ON d.kind = 'day' AND d.source_id = e.source_id AND d.key = e.start_date
+			WHERE ` + meetingCondition + `
+			GROUP BY e.source_id, e.start_date`},
+		{"clear stale people", `
+			DELETE FROM person_meeting_counts
+			WHERE EXISTS (
+				SELECT 1 FROM analytics_dirty d
+				WHERE d.kind = 'person' AND d.source_id = person_meeting_counts.source_id
+				  AND d.key = person_meeting_counts.email
+			)`},
+		{"recompute people", `
+			INSERT INTO person_meeting_counts (source_id, email, meeting_count, meeting_minutes, first_seen, last_seen)
+			SELECT e.source_id, a.canonical_email, COUNT(DISTINCT e.id), SUM(` + meetingMinutes + `),
+			       MIN(e.start_time), MAX(e.start_time)
+			FROM attendees a
+			JOIN events e ON e.id = a.event_id
+			JOIN analytics_dirty d
+			  ON d.kind = 'person' AND d.source_id = e.source_id AND d.key = a.canonical_email
+			WHERE a.is_self = FALSE AND ` + meetingCondition + `
+			GROUP BY e.source_id, a.canonical_email`},
+		{"clear dirty keys", `DELETE FROM analytics_dirty`},
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt.query); err != nil {
+			return nil, fmt.Errorf("%s: %w", stmt.name, err)
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE analytics_state SET refreshed_at = ? WHERE id = 1`, time.Now()); err != nil {
+		return nil, fmt.Errorf("update analytics state: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return result, nil
+}
+
+// RebuildAnalyticsTables recomputes the materialized analytics tables from scratch.
+func (s *Store) RebuildAnalyticsTables() (*AnalyticsRefresh, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	statements := []struct {
+		name  string
+		query string
+	}{
+		{"clear days", `DELETE FROM daily_meeting_minutes`},
+		{"build days", `
+			INSERT INTO daily_meeting_minutes (source_id, day, meeting_count, meeting_minutes)
+			SELECT e.source_id, e.start_date, COUNT(*), SUM(` + meetingMinutes + `)
+			FROM events e
+			WHERE ` + meetingCondition + `
+			GROUP BY e.source_id, e.start_date`},
+		{"clear people", `DELETE FROM person_meeting_counts`},
+		{"build people", `
+			INSERT INTO person_meeting_counts (source_id, email, meeting_count, meeting_minutes, first_seen, last_seen)
+			SELECT e.source_id, a.canonical_email, COUNT(DISTINCT e.id), SUM(` + meetingMinutes + `),
+			       MIN(e.start_time), MAX(e.start_time)
+			FROM attendees a
+			JOIN events e ON e.id = a.event_id
+			WHERE a.is_self = FALSE AND ` + meetingCondition + `
+			GROUP BY e.source_id, a.canonical_email`},
+		{"clear dirty keys", `DELETE FROM analytics_dirty`},
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt.query); err != nil {
+			return nil, fmt.Errorf("%s: %w", stmt.name, err)
+		}
+	}
+
+	now := time.Now()
+	if _, err := tx.Exec(`
+		INSERT INTO analytics_state (id, built_at, refreshed_at) VALUES (1, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET built_at = excluded.built_at, refreshed_at = excluded.refreshed_at
+	`, now, now); err != nil {
+		return nil, fmt.Errorf("update analytics state: %w", err)
+	}
+
+	result := &AnalyticsRefresh{FullRebuild: true}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM daily_meeting_minutes`).Scan(&result.DaysUpdated); err != nil {
+		return nil, fmt.Errorf("count days: %w", err)
+	}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM person_meeting_counts`).Scan(&result.PeopleUpdated); err != nil {
+		return nil, fmt.Errorf("count people: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return result, nil
+}
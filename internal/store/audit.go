@@ -0,0 +1,87 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// QueryAudit records a single query run through the query executor.
+type QueryAudit struct {
+	ID           int64
+	ExecutedAt   time.Time
+	Caller       string
+	QueryName    string
+	SQL          string
+	Params       string // JSON object, empty if none
+	Duration     time.Duration
+	RowCount     int
+	ErrorMessage string
+}
+
+// AuditFilter restricts which audit entries are listed.
+type AuditFilter struct {
+	Caller string
+	Limit  int
+}
+
+// RecordQueryAudit appends an entry to the query audit log.
+func (s *Store) RecordQueryAudit(a *QueryAudit) error {
+	executedAt := a.ExecutedAt
+	if executedAt.IsZero() {
+		executedAt = time.Now()
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO query_audit (executed_at, caller, query_name, sql, params, duration_ms, row_count, error_message)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, executedAt, a.Caller, nullString(a.QueryName), a.SQL, nullString(a.Params),
+		a.Duration.Milliseconds(), a.RowCount, nullString(a.ErrorMessage))
+	if err != nil {
+		return fmt.Errorf("record query audit: %w", err)
+	}
+	return nil
+}
+
+// ListQueryAudit returns audit entries, most recent first.
+func (s *Store) ListQueryAudit(filter AuditFilter) ([]*QueryAudit, error) {
+	query := `
+		SELECT id, executed_at, COALESCE(caller, ''), COALESCE(query_name, ''), sql,
+		       COALESCE(params, ''), COALESCE(duration_ms, 0), COALESCE(row_count, 0),
+		       COALESCE(error_message, '')
+		FROM query_audit`
+	var args []interface{}
+	if filter.Caller != "" {
+		query += ` WHERE caller = ?`
+		args = append(args, filter.Caller)
+	}
+	query += ` ORDER BY executed_at DESC, id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query audit log: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []*QueryAudit
+	for rows.Next() {
+		var a QueryAudit
+		var durationMS int64
+		if err := rows.Scan(&a.ID, &a.ExecutedAt, &a.Caller, &a.QueryName, &a.SQL,
+			&a.Params, &durationMS, &a.RowCount, &a.ErrorMessage); err != nil {
+			return nil, fmt.Errorf("scan audit entry: %w", err)
+		}
+		a.Duration = time.Duration(durationMS) * time.Millisecond
+		entries = append(entries, &a)
+	}
+
+	return entries, rows.Err()
+}
+
+// nullString converts an empty string to NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
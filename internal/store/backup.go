@@ -0,0 +1,108 @@
+package store
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Backup writes a consistent copy of the database to path, which must not
+// exist, gzip-compressed if compress is set, and returns the size of the
+// file written. Writers such as a running sync or the daemon may keep
+// going: the copy holds the data as of the moment it started. The copy is
+// checked before it is moved to path, so a failed backup leaves nothing
+// there.
+func (s *Store) Backup(path string, compress bool) (int64, error) {
+	if _, err := os.Stat(path); err == nil {
+		return 0, fmt.Errorf("%s already exists", path)
+	}
+
+	// Work next to path so the final rename doesn't cross file systems
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".calvault-backup-*")
+	if err != nil {
+		return 0, fmt.Errorf("create backup: %w", err)
+	}
+	copyPath := tmp.Name()
+	_ = tmp.Close()
+	// VACUUM INTO refuses to write over an existing file
+	if err := os.Remove(copyPath); err != nil {
+		return 0, fmt.Errorf("create backup: %w", err)
+	}
+	defer func() { _ = os.Remove(copyPath) }()
+
+	if err := s.CopyTo(copyPath); err != nil {
+		return 0, err
+	}
+	if err := os.Chmod(copyPath, 0o600); err != nil {
+		return 0, fmt.Errorf("create backup: %w", err)
+	}
+	if err := checkDatabase(copyPath, "quick_check"); err != nil {
+		return 0, fmt.Errorf("check backup: %w", err)
+	}
+
+	final := copyPath
+	if compress {
+		final = copyPath + ".gz"
+		defer func() { _ = os.Remove(final) }()
+		if err := gzipFile(copyPath, final, strings.TrimSuffix(filepath.Base(path), ".gz")); err != nil {
+			return 0, err
+		}
+	}
+	if err := os.Rename(final, path); err != nil {
+		return 0, fmt.Errorf("create backup: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, fmt.Errorf("create backup: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// checkDatabase runs SQLite's quick_check or integrity_check on the
+// database at path, returning the first problem found.
+func checkDatabase(path, check string) error {
+	db, err := sql.Open("sqlite3", path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	var result string
+	if err := db.QueryRow(`PRAGMA ` + check).Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("%s failed: %s", check, result)
+	}
+	return nil
+}
+
+// gzipFile compresses the file at src into a new file at dst, recording
+// name as the original file name.
+func gzipFile(src, dst, name string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("compress backup: %w", err)
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("compress backup: %w", err)
+	}
+	zw := gzip.NewWriter(out)
+	zw.Name = name
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("compress backup: %w", err)
+	}
+	return nil
+}
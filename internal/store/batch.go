@@ -0,0 +1,131 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EventWrite is one event of a batch passed to UpsertEvents.
+type EventWrite struct {
+	Event     *Event
+	Attendees []*Attendee // nil leaves stored attendees untouched
+	// Conference replaces the stored conference; an empty one removes it
+	// and nil leaves it untouched.
+	Conference *Conference
+	Reminders  *EventReminders // nil leaves stored reminders untouched
+}
+
+// existsChunk bounds the number of IDs bound into one existence query,
+// staying well below SQLite's variable limit.
+const existsChunk = 500
+
+// UpsertEvents inserts or updates a batch of events with their attendees,
+// conferences and reminders, reusing prepared statements across the batch. It reports
+// for each event whether it was newly inserted. Event IDs are set on the events. All events
+// must belong to sourceID.
+func (t *Tx) UpsertEvents(sourceID int64, writes []*EventWrite) ([]bool, error) {
+	ids := make([]string, len(writes))
+	for i, w := range writes {
+		ids[i] = w.Event.GoogleEventID
+	}
+	existing, err := existingEvents(t.tx, sourceID, ids)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := accountEmail(t.tx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	upsert, err := t.tx.Prepare(upsertEventSQL)
+	if err != nil {
+		return nil, fmt.Errorf("prepare upsert event: %w", err)
+	}
+	defer func() { _ = upsert.Close() }()
+	delAttendees, err := t.tx.Prepare(`DELETE FROM attendees WHERE event_id = ?`)
+	if err != nil {
+		return nil, fmt.Errorf("prepare delete attendees: %w", err)
+	}
+	defer func() { _ = delAttendees.Close() }()
+	insAttendee, err := t.tx.Prepare(insertAttendeeSQL)
+	if err != nil {
+		return nil, fmt.Errorf("prepare insert attendee: %w", err)
+	}
+	defer func() { _ = insAttendee.Close() }()
+
+	isNew := make([]bool, len(writes))
+	for i, w := range writes {
+		isNew[i] = !existing[w.Event.GoogleEventID]
+		// A batch may repeat an event; later copies are updates.
+		existing[w.Event.GoogleEventID] = true
+
+		if err := upsert.QueryRow(upsertEventArgs(w.Event)...).Scan(&w.Event.ID); err != nil {
+			return nil, fmt.Errorf("upsert event %s: %w", w.Event.GoogleEventID, err)
+		}
+
+		if w.Conference != nil {
+			if err := replaceConference(t.tx, w.Event.ID, w.Conference); err != nil {
+				return nil, err
+			}
+		}
+		if w.Reminders != nil {
+			if err := replaceEventReminders(t.tx, w.Event.ID, w.Event.CalendarID, w.Reminders); err != nil {
+				return nil, err
+			}
+		}
+
+		if w.Attendees == nil {
+			continue
+		}
+		if _, err := delAttendees.Exec(w.Event.ID); err != nil {
+			return nil, fmt.Errorf("delete attendees: %w", err)
+		}
+		for _, a := range w.Attendees {
+			if _, err := insAttendee.Exec(w.Event.ID, a.Email, CanonicalEmail(a.Email), a.DisplayName, a.ResponseStatus, a.IsOrganizer, a.IsSelf, owner); err != nil {
+				return nil, fmt.Errorf("insert attendee: %w", err)
+			}
+		}
+	}
+	return isNew, nil
+}
+
+// ExistingEvents returns which of the given google_event_ids are stored.
+func (s *Store) ExistingEvents(sourceID int64, googleEventIDs []string) (map[string]bool, error) {
+	return existingEvents(s.db, sourceID, googleEventIDs)
+}
+
+func existingEvents(db execer, sourceID int64, googleEventIDs []string) (map[string]bool, error) {
+	existing := make(map[string]bool, len(googleEventIDs))
+	for start := 0; start < len(googleEventIDs); start += existsChunk {
+		chunk := googleEventIDs[start:min(start+existsChunk, len(googleEventIDs))]
+
+		args := make([]interface{}, 0, len(chunk)+1)
+		args = append(args, sourceID)
+		for _, id := range chunk {
+			args = append(args, id)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(chunk)), ",")
+
+		rows, err := db.Query(`
+			SELECT google_event_id FROM events
+			WHERE source_id = ? AND google_event_id IN (`+placeholders+`)
+		`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("query existing events: %w", err)
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				_ = rows.Close()
+				return nil, fmt.Errorf("scan existing event: %w", err)
+			}
+			existing[id] = true
+		}
+		err = rows.Err()
+		_ = rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("query existing events: %w", err)
+		}
+	}
+	return existing, nil
+}
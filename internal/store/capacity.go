@@ -0,0 +1,206 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// CapacityOptions sets how GetTeamCapacity groups and anonymizes members.
+type CapacityOptions struct {
+	// GroupOf returns a member's group; "" for members in none.
+	GroupOf func(member string) string
+	// MeetingFreeDays are the days, indexed by time.Weekday, checked for
+	// meetings.
+	MeetingFreeDays [7]bool
+	// MinGroupSize is the fewest members a figure may cover; groups with
+	// fewer are hidden.
+	MinGroupSize int
+}
+
+// GroupCapacity is the meeting load of a group of team members.
+type GroupCapacity struct {
+	Group    string // "" for members in no group
+	Members  int
+	Meetings int     // Distinct meetings any member was in
+	Hours    float64 // Meeting hours summed over members
+	Days     int     // Member-days on meeting-free days
+	FreeDays int     // Of those, the days without meetings
+	// Hidden is set when the group has fewer members than the minimum;
+	// its figures other than Members are left at zero.
+	Hidden bool
+}
+
+// Adherence returns the share of member-days on meeting-free days that
+// were kept free, or 0 if there were none.
+func (g *GroupCapacity) Adherence() float64 {
+	if g.Days == 0 {
+		return 0
+	}
+	return float64(g.FreeDays) / float64(g.Days)
+}
+
+// TeamCapacity is an aggregate report of a team vault's meeting load.
+type TeamCapacity struct {
+	Total  GroupCapacity
+	Groups []*GroupCapacity // By name, members in no group last
+	// Shared holds the hours of meetings between members of two groups,
+	// keyed by the group names in order; a group paired with itself has
+	// meetings between two or more of its own members. Pairs with a
+	// hidden group are left out.
+	Shared map[[2]string]float64
+}
+
+// GetTeamCapacity reports the meeting load of team members - accounts'
+// owners; unassigned accounts are left out - for meetings starting in
+// [since, until), with days in since's location. Meetings count as in
+// GetTeamLoad. Copies of a meeting, by iCalendar UID and start, count
+// once per member, and make it shared between the members who have them.
+func (s *Store) GetTeamCapacity(since, until time.Time, opts CapacityOptions) (*TeamCapacity, error) {
+	memberRows, err := s.db.Query(`SELECT DISTINCT owner FROM sources WHERE COALESCE(owner, '') != '' ORDER BY owner`)
+	if err != nil {
+		return nil, fmt.Errorf("query team members: %w", err)
+	}
+	var members []string
+	for memberRows.Next() {
+		var m string
+		if err := memberRows.Scan(&m); err != nil {
+			_ = memberRows.Close()
+			return nil, fmt.Errorf("scan team member: %w", err)
+		}
+		members = append(members, m)
+	}
+	_ = memberRows.Close()
+	if err := memberRows.Err(); err != nil {
+		return nil, fmt.Errorf("query team members: %w", err)
+	}
+
+	inRange, inRangeArgs := startsIn("e", since, until)
+	rows, err := s.db.Query(`
+		SELECT src.owner, COALESCE(NULLIF(e.ical_uid, ''), 'id:' || e.id), e.start_time, e.end_time
+		FROM events e
+		JOIN calendars c ON c.id = e.calendar_id AND NOT COALESCE(c.private, FALSE)
+		JOIN sources src ON src.id = c.source_id
+		WHERE COALESCE(src.owner, '') != ''
+		  AND `+inRange+`
+		  AND COALESCE(e.visibility, '') != 'private'
+		  AND `+meetingCondition+`
+	`, inRangeArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("query team meetings: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	type meeting struct {
+		hours   float64
+		members map[string]bool
+	}
+	meetings := map[string]*meeting{}
+	busy := map[string]map[string]bool{} // Member to days with meetings
+	loc := since.Location()
+	for rows.Next() {
+		var member, uid string
+		var start, end time.Time
+		if err := rows.Scan(&member, &uid, &start, &end); err != nil {
+			return nil, fmt.Errorf("scan team meeting: %w", err)
+		}
+		if !end.After(start) {
+			continue
+		}
+		key := uid + "|" + start.UTC().Format(time.RFC3339)
+		m := meetings[key]
+		if m == nil {
+			m = &meeting{hours: end.Sub(start).Hours(), members: map[string]bool{}}
+			meetings[key] = m
+		}
+		m.members[member] = true
+		if busy[member] == nil {
+			busy[member] = map[string]bool{}
+		}
+		busy[member][start.In(loc).Format("2006-01-02")] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query team meetings: %w", err)
+	}
+
+	groupOf := func(member string) string {
+		if opts.GroupOf == nil {
+			return ""
+		}
+		return opts.GroupOf(member)
+	}
+	report := &TeamCapacity{Shared: map[[2]string]float64{}}
+	groups := map[string]*GroupCapacity{}
+	for _, m := range members {
+		g := groups[groupOf(m)]
+		if g == nil {
+			g = &GroupCapacity{Group: groupOf(m)}
+			groups[g.Group] = g
+			report.Groups = append(report.Groups, g)
+		}
+		g.Members++
+		report.Total.Members++
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i].Group, report.Groups[j].Group
+		if (a == "") != (b == "") {
+			return b == ""
+		}
+		return a < b
+	})
+
+	y, mo, d := since.In(loc).Date()
+	for day := time.Date(y, mo, d, 0, 0, 0, 0, loc); day.Before(until); day = day.AddDate(0, 0, 1) {
+		if !opts.MeetingFreeDays[day.Weekday()] {
+			continue
+		}
+		date := day.Format("2006-01-02")
+		for _, m := range members {
+			g := groups[groupOf(m)]
+			g.Days++
+			report.Total.Days++
+			if !busy[m][date] {
+				g.FreeDays++
+				report.Total.FreeDays++
+			}
+		}
+	}
+
+	for _, m := range meetings {
+		report.Total.Meetings++
+		report.Total.Hours += m.hours * float64(len(m.members))
+		inGroup := map[string]int{}
+		for member := range m.members {
+			inGroup[groupOf(member)]++
+		}
+		for name, n := range inGroup {
+			g := groups[name]
+			g.Meetings++
+			g.Hours += m.hours * float64(n)
+			if n > 1 {
+				report.Shared[[2]string{name, name}] += m.hours
+			}
+			for other := range inGroup {
+				if name < other {
+					report.Shared[[2]string{name, other}] += m.hours
+				}
+			}
+		}
+	}
+
+	hide := func(g *GroupCapacity) {
+		if g.Members < opts.MinGroupSize {
+			*g = GroupCapacity{Group: g.Group, Members: g.Members, Hidden: true}
+		}
+	}
+	hide(&report.Total)
+	for _, g := range report.Groups {
+		hide(g)
+	}
+	for pair := range report.Shared {
+		if groups[pair[0]].Hidden || groups[pair[1]].Hidden {
+			delete(report.Shared, pair)
+		}
+	}
+	return report, nil
+}
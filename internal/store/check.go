@@ -0,0 +1,52 @@
+package store
+
+import "fmt"
+
+// CheckIntegrity runs SQLite's quick_check, or the slower integrity_check
+// if full is set, and returns the problems found; none if the database is
+// intact.
+func (s *Store) CheckIntegrity(full bool) ([]string, error) {
+	check := "quick_check"
+	if full {
+		check = "integrity_check"
+	}
+	rows, err := s.db.Query(`PRAGMA ` + check)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", check, err)
+	}
+	defer func() { _ = rows.Close() }()
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return nil, fmt.Errorf("%s: %w", check, err)
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// ForeignKeyViolations counts rows whose parent no longer exists, by
+// table, as SQLite's foreign_key_check reports them. Databases written
+// before a foreign key was declared, or with foreign keys disabled, can
+// have them; CleanupOrphans removes the common ones.
+func (s *Store) ForeignKeyViolations() (map[string]int64, error) {
+	rows, err := s.db.Query(`PRAGMA foreign_key_check`)
+	if err != nil {
+		return nil, fmt.Errorf("foreign_key_check: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	counts := map[string]int64{}
+	for rows.Next() {
+		// table, rowid (NULL for WITHOUT ROWID tables), parent, fkid
+		var table, parent string
+		var rowid, fkid any
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return nil, fmt.Errorf("foreign_key_check: %w", err)
+		}
+		counts[table]++
+	}
+	return counts, rows.Err()
+}
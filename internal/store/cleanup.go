@@ -0,0 +1,77 @@
+package store
+
+import "fmt"
+
+// OrphanCleanup counts the rows CleanupOrphans removed or detached. Rows
+// removed by a cascade from another orphan are not counted.
+type OrphanCleanup struct {
+	Calendars     int64 // Calendars whose source is gone
+	Events        int64 // Events whose source or calendar is gone
+	Attendees     int64 // Attendees whose event is gone
+	WatchChannels int64 // Watch channels whose calendar is gone
+	SyncRuns      int64 // Sync runs detached from a deleted calendar
+}
+
+// Total returns the number of rows affected.
+func (c *OrphanCleanup) Total() int64 {
+	return c.Calendars + c.Events + c.Attendees + c.WatchChannels + c.SyncRuns
+}
+
+// CleanupOrphans removes child rows whose parent no longer exists. Foreign
+// key cascades prevent new orphans; this clears ones left by databases
+// written before the cascades were declared or with foreign keys disabled.
+// It fails with ErrLegalHold under legal hold.
+func (s *Store) CleanupOrphans() (*OrphanCleanup, error) {
+	var c *OrphanCleanup
+	err := s.InTx(func(tx *Tx) error {
+		if err := checkLegalHold(tx.tx); err != nil {
+			return err
+		}
+		var err error
+		c, err = cleanupOrphans(tx.tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// cleanupOrphans removes orphans parents-first, so rows stranded by an
+// earlier step are caught by a later one.
+func cleanupOrphans(db execer) (*OrphanCleanup, error) {
+	c := &OrphanCleanup{}
+	steps := []struct {
+		name  string
+		count *int64
+		sql   string
+	}{
+		{"calendars", &c.Calendars, `
+			DELETE FROM calendars
+			WHERE NOT EXISTS (SELECT 1 FROM sources s WHERE s.id = calendars.source_id)`},
+		{"events", &c.Events, `
+			DELETE FROM events
+			WHERE NOT EXISTS (SELECT 1 FROM sources s WHERE s.id = events.source_id)
+			   OR NOT EXISTS (SELECT 1 FROM calendars c WHERE c.id = events.calendar_id)`},
+		{"attendees", &c.Attendees, `
+			DELETE FROM attendees
+			WHERE NOT EXISTS (SELECT 1 FROM events e WHERE e.id = attendees.event_id)`},
+		{"watch channels", &c.WatchChannels, `
+			DELETE FROM watch_channels
+			WHERE NOT EXISTS (SELECT 1 FROM calendars c WHERE c.id = watch_channels.calendar_id)`},
+		{"sync runs", &c.SyncRuns, `
+			UPDATE sync_runs SET calendar_id = NULL
+			WHERE calendar_id IS NOT NULL
+			  AND NOT EXISTS (SELECT 1 FROM calendars c WHERE c.id = sync_runs.calendar_id)`},
+	}
+	for _, step := range steps {
+		result, err := db.Exec(step.sql)
+		if err != nil {
+			return nil, fmt.Errorf("clean up orphaned %s: %w", step.name, err)
+		}
+		if *step.count, err = result.RowsAffected(); err != nil {
+			return nil, fmt.Errorf("clean up orphaned %s: %w", step.name, err)
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// EventColor is one of the colors Google Calendar offers for events.
+type EventColor struct {
+	ID   string
+	Name string // As shown in Google Calendar
+	Hex  string // Background color
+}
+
+// EventColors is Google Calendar's event palette (colors.get), with the
+// names its UI gives the colors.
+var EventColors = []EventColor{
+	{"1", "Lavender", "#a4bdfc"},
+	{"2", "Sage", "#7ae7bf"},
+	{"3", "Grape", "#dbadff"},
+	{"4", "Flamingo", "#ff887c"},
+	{"5", "Banana", "#fbd75b"},
+	{"6", "Tangerine", "#ffb878"},
+	{"7", "Peacock", "#46d6db"},
+	{"8", "Graphite", "#e1e1e1"},
+	{"9", "Blueberry", "#5484ed"},
+	{"10", "Basil", "#51b749"},
+	{"11", "Tomato", "#dc2127"},
+}
+
+// views returns the views migrate keeps up to date, in creation order.
+// They select columns added by columnMigrations, so they can't be in
+// schema.sql.
+func views() []struct{ name, sql string } {
+	rows := make([]string, len(EventColors))
+	for i, c := range EventColors {
+		rows[i] = fmt.Sprintf("('%s', '%s', '%s')", c.ID, c.Name, c.Hex)
+	}
+	return []struct{ name, sql string }{
+		{"color_names", `CREATE VIEW color_names AS
+SELECT column1 AS color_id, column2 AS name, column3 AS hex
+FROM (VALUES ` + strings.Join(rows, ", ") + `)`},
+		// The color each event is shown in: its own, or else its calendar's
+		{"event_colors", `CREATE VIEW event_colors AS
+SELECT e.id AS event_id, e.calendar_id, e.color_id,
+       e.color_id IS NULL AS calendar_color,
+       n.name AS color_name,
+       COALESCE(n.hex, c.background_color) AS color_hex
+FROM events e
+JOIN calendars c ON c.id = e.calendar_id
+LEFT JOIN color_names n ON n.color_id = e.color_id`},
+		// The reminders each event fires: its own, or its calendar's defaults
+		{"event_reminders", `CREATE VIEW event_reminders AS
+SELECT r.event_id, r.method, r.minutes, FALSE AS is_default
+FROM reminders r
+WHERE r.event_id IS NOT NULL
+UNION ALL
+SELECT e.id AS event_id, r.method, r.minutes, TRUE AS is_default
+FROM events e
+JOIN reminders r ON r.calendar_id = e.calendar_id AND r.event_id IS NULL
+WHERE e.reminders_default`},
+	}
+}
+
+// initViews creates the views, replacing any whose definition changed.
+func (s *Store) initViews() error {
+	for _, v := range views() {
+		var current string
+		err := s.db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'view' AND name = ?`, v.name).Scan(&current)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("check view %s: %w", v.name, err)
+		}
+		if current == v.sql {
+			continue
+		}
+		if _, err := s.db.Exec(`DROP VIEW IF EXISTS ` + v.name); err != nil {
+			return fmt.Errorf("drop view %s: %w", v.name, err)
+		}
+		if _, err := s.db.Exec(v.sql); err != nil {
+			return fmt.Errorf("create view %s: %w", v.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// Conference is the video conference attached to an event: Google Meet,
+// or a conferencing add-on such as Zoom.
+type Conference struct {
+	EventID      int64
+	SolutionType string // hangoutsMeet, eventHangout, eventNamedHangout or addOn
+	SolutionName string // e.g. Google Meet, Zoom Meeting
+	ConferenceID string
+	MeetingCode  string // Of the video entry point
+	VideoURI     string
+	HangoutLink  string
+	EntryPoints  []*ConferenceEntryPoint
+}
+
+// ConferenceEntryPoint is one way to join a conference. Passcodes and PINs
+// are not stored.
+type ConferenceEntryPoint struct {
+	Type        string `json:"type"` // video, phone, sip or more
+	URI         string `json:"uri"`
+	Label       string `json:"label,omitempty"`
+	MeetingCode string `json:"meeting_code,omitempty"`
+}
+
+// IsEmpty reports whether c holds no conference at all.
+func (c *Conference) IsEmpty() bool {
+	return c.SolutionType == "" && c.ConferenceID == "" && c.HangoutLink == "" && len(c.EntryPoints) == 0
+}
+
+// GetConference returns an event's conference, or nil if it has none.
+func (s *Store) GetConference(eventID int64) (*Conference, error) {
+	c := &Conference{EventID: eventID}
+	var entryPoints string
+	err := s.db.QueryRow(`
+		SELECT COALESCE(solution_type, ''), COALESCE(solution_name, ''), COALESCE(conference_id, ''),
+			COALESCE(meeting_code, ''), COALESCE(video_uri, ''), COALESCE(hangout_link, ''), COALESCE(entry_points, '')
+		FROM conference_data WHERE event_id = ?
+	`, eventID).Scan(&c.SolutionType, &c.SolutionName, &c.ConferenceID, &c.MeetingCode, &c.VideoURI, &c.HangoutLink, &entryPoints)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get conference: %w", err)
+	}
+	if entryPoints != "" {
+		if err := json.Unmarshal([]byte(entryPoints), &c.EntryPoints); err != nil {
+			return nil, fmt.Errorf("decode conference entry points: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// replaceConference stores an event's conference, or removes it when c is
+// empty.
+func replaceConference(db execer, eventID int64, c *Conference) error {
+	if c.IsEmpty() {
+		if _, err := db.Exec(`DELETE FROM conference_data WHERE event_id = ?`, eventID); err != nil {
+			return fmt.Errorf("delete conference: %w", err)
+		}
+		return nil
+	}
+
+	var entryPoints sql.NullString
+	if len(c.EntryPoints) > 0 {
+		data, err := json.Marshal(c.EntryPoints)
+		if err != nil {
+			return fmt.Errorf("encode conference entry points: %w", err)
+		}
+		entryPoints = sql.NullString{String: string(data), Valid: true}
+	}
+	_, err := db.Exec(`
+		INSERT INTO conference_data (event_id, solution_type, solution_name, conference_id, meeting_code, video_uri, hangout_link, entry_points)
+		VALUES (?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), ?)
+		ON CONFLICT(event_id) DO UPDATE SET
+			solution_type = excluded.solution_type,
+			solution_name = excluded.solution_name,
+			conference_id = excluded.conference_id,
+			meeting_code = excluded.meeting_code,
+			video_uri = excluded.video_uri,
+			hangout_link = excluded.hangout_link,
+			entry_points = excluded.entry_points
+	`, eventID, c.SolutionType, c.SolutionName, c.ConferenceID, c.MeetingCode, c.VideoURI, c.HangoutLink, entryPoints)
+	if err != nil {
+		return fmt.Errorf("store conference: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ConflictEvent is a meeting taking part in a conflict.
+type ConflictEvent struct {
+	EventID    int64 // The series, for an occurrence of a recurring meeting
+	CalendarID int64
+	Summary    string
+	Start      time.Time
+	End        time.Time
+}
+
+// Conflict is a stretch of time booked by two or more meetings at once.
+type Conflict struct {
+	Start  time.Time
+	End    time.Time
+	Events []*ConflictEvent // By start
+}
+
+// ConflictPeriod sums the conflicts of one week or month.
+type ConflictPeriod struct {
+	Start     time.Time // First day of the period
+	Conflicts int
+	Hours     float64 // Time double-booked
+	Events    int     // Distinct meetings involved
+}
+
+// ConflictReport lists double-booked time.
+type ConflictReport struct {
+	Conflicts []*Conflict       // Oldest first
+	Periods   []*ConflictPeriod // Every period in the range, oldest first
+}
+
+// GetConflictReport finds the times in [since, until) when two or more
+// meetings (as counted by the analytics tables, across all calendars)
+// overlap, and sums them into periods, where period maps a day to the first
+// day of its week or month. Recurring meetings count through their
+// occurrences in event_instances. A meeting held in several accounts counts
+// once, so it doesn't conflict with itself. Days are in since's location.
+func (s *Store) GetConflictReport(since, until time.Time, period func(day time.Time) time.Time) (*ConflictReport, error) {
+	inRange, args := startsIn("e", since, until)
+	instancesInRange, instanceArgs := startsIn("i", since, until)
+	rows, err := s.db.Query(`
+		SELECT e.id, COALESCE(e.ical_uid, ''), e.calendar_id, COALESCE(e.summary, ''), e.start_time, e.end_time
+		FROM events e
+		WHERE `+inRange+`
+		  AND COALESCE(e.recurrence_rule, '') = ''
+		  AND `+meetingCondition+`
+		UNION ALL
+		SELECT e.id, COALESCE(e.ical_uid, ''), e.calendar_id, COALESCE(e.summary, ''), i.start_time, i.end_time
+		FROM event_instances i
+		JOIN events e ON e.id = i.event_id
+		WHERE i.expanded AND i.all_day = FALSE AND i.end_time IS NOT NULL
+		  AND `+instancesInRange+`
+		  AND `+meetingCondition+`
+		ORDER BY 5, 1
+	`, append(args, instanceArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("query meetings: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	loc := since.Location()
+	var meetings []*ConflictEvent
+	seen := map[string]bool{}
+	for rows.Next() {
+		var icalUID string
+		m := &ConflictEvent{}
+		if err := rows.Scan(&m.EventID, &icalUID, &m.CalendarID, &m.Summary, &m.Start, &m.End); err != nil {
+			return nil, fmt.Errorf("scan meeting: %w", err)
+		}
+		key := meetingKey(m.EventID, icalUID, m.Start)
+		if seen[key] || !m.End.After(m.Start) {
+			continue
+		}
+		seen[key] = true
+		m.Start, m.End = m.Start.In(loc), m.End.In(loc)
+		meetings = append(meetings, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query meetings: %w", err)
+	}
+
+	report := &ConflictReport{Conflicts: findConflicts(meetings)}
+
+	periods := map[time.Time]*ConflictPeriod{}
+	y, m, d := since.Date()
+	for day := time.Date(y, m, d, 0, 0, 0, 0, loc); day.Before(until); day = day.AddDate(0, 0, 1) {
+		start := period(day)
+		if periods[start] == nil {
+			periods[start] = &ConflictPeriod{Start: start}
+			report.Periods = append(report.Periods, periods[start])
+		}
+	}
+	involved := map[*ConflictPeriod]map[*ConflictEvent]bool{}
+	for _, c := range report.Conflicts {
+		y, m, d := c.Start.Date()
+		p := periods[period(time.Date(y, m, d, 0, 0, 0, 0, loc))]
+		if p == nil {
+			continue
+		}
+		p.Conflicts++
+		p.Hours += c.End.Sub(c.Start).Hours()
+		if involved[p] == nil {
+			involved[p] = map[*ConflictEvent]bool{}
+		}
+		for _, e := range c.Events {
+			if !involved[p][e] {
+				involved[p][e] = true
+				p.Events++
+			}
+		}
+	}
+	sort.Slice(report.Periods, func(i, j int) bool { return report.Periods[i].Start.Before(report.Periods[j].Start) })
+	return report, nil
+}
+
+// findConflicts sweeps meetings sorted by start for the maximal stretches
+// of time two or more of them share.
+func findConflicts(meetings []*ConflictEvent) []*Conflict {
+	type edge struct {
+		at    time.Time
+		delta int
+		m     *ConflictEvent
+	}
+	var edges []edge
+	for _, m := range meetings {
+		edges = append(edges, edge{m.Start, 1, m}, edge{m.End, -1, m})
+	}
+	// Ends before starts at the same instant: back-to-back meetings don't conflict
+	sort.SliceStable(edges, func(i, j int) bool {
+		if !edges[i].at.Equal(edges[j].at) {
+			return edges[i].at.Before(edges[j].at)
+		}
+		return edges[i].delta < edges[j].delta
+	})
+
+	var conflicts []*Conflict
+	var current *Conflict
+	active := map[*ConflictEvent]bool{}
+	involved := map[*ConflictEvent]bool{}
+	for _, e := range edges {
+		if e.delta > 0 {
+			active[e.m] = true
+		} else {
+			delete(active, e.m)
+		}
+		switch {
+		case len(active) >= 2 && current == nil:
+			current = &Conflict{Start: e.at}
+			involved = map[*ConflictEvent]bool{}
+			fallthrough
+		case len(active) >= 2:
+			for m := range active {
+				if !involved[m] {
+					involved[m] = true
+					current.Events = append(current.Events, m)
+				}
+			}
+		case current != nil:
+			current.End = e.at
+			sort.Slice(current.Events, func(i, j int) bool {
+				a, b := current.Events[i], current.Events[j]
+				if !a.Start.Equal(b.Start) {
+					return a.Start.Before(b.Start)
+				}
+				return a.EventID < b.EventID
+			})
+			conflicts = append(conflicts, current)
+			current = nil
+		}
+	}
+	return conflicts
+}
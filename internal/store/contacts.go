@@ -0,0 +1,70 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Contact is a unique attendee derived from the archive.
+type Contact struct {
+	Email        string
+	DisplayName  string
+	FirstSeen    time.Time
+	LastSeen     time.Time
+	MeetingCount int
+}
+
+// ListContacts returns unique attendees across all events, excluding the
+// account owner. Attendees are matched by canonical email, so case, +tags
+// and [[identity]] aliases fold into one contact, named by their identity
+// or else by the most recently seen non-empty display name.
+func (s *Store) ListContacts() ([]*Contact, error) {
+	// One pass over the attendees: the window picks each contact's latest
+	// name while the outer query aggregates
+	rows, err := s.db.Query(`
+		SELECT c.email, COALESCE(p.name, MAX(c.latest_name)),
+		       MIN(c.start_time), MAX(c.start_time), COUNT(DISTINCT c.event_id)
+		FROM (
+			SELECT COALESCE(a.canonical_email, lower(a.email)) AS email, e.id AS event_id, e.start_time,
+			       FIRST_VALUE(NULLIF(a.display_name, '')) OVER (
+			           PARTITION BY COALESCE(a.canonical_email, lower(a.email))
+			           ORDER BY NULLIF(a.display_name, '') IS NULL, e.start_time DESC
+			       ) AS latest_name
+			FROM attendees a
+			JOIN events e ON e.id = a.event_id
+			WHERE a.is_self = FALSE
+			  AND NOT EXISTS (SELECT 1 FROM event_tombstones t WHERE t.event_id = e.id)
+		) c
+		LEFT JOIN people p ON p.email = c.email
+		GROUP BY c.email
+		ORDER BY COUNT(DISTINCT c.event_id) DESC, c.email
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query contacts: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var contacts []*Contact
+	for rows.Next() {
+		var c Contact
+		var name, firstSeen, lastSeen sql.NullString
+		if err := rows.Scan(&c.Email, &name, &firstSeen, &lastSeen, &c.MeetingCount); err != nil {
+			return nil, fmt.Errorf("scan contact: %w", err)
+		}
+		c.DisplayName = name.String
+		if firstSeen.Valid {
+			if c.FirstSeen, err = parseTimestamp(firstSeen.String); err != nil {
+				return nil, err
+			}
+		}
+		if lastSeen.Valid {
+			if c.LastSeen, err = parseTimestamp(lastSeen.String); err != nil {
+				return nil, err
+			}
+		}
+		contacts = append(contacts, &c)
+	}
+
+	return contacts, rows.Err()
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// APIEventByICalUID returns the ID of the series or single event with the
+// given iCalendar UID stored by an API (non-ICS) source, or 0 if none is.
+func (s *Store) APIEventByICalUID(icalUID string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`
+		SELECT e.id FROM events e JOIN sources s ON s.id = e.source_id
+		WHERE e.ical_uid = ? AND COALESCE(e.recurring_event_id, '') = '' AND s.source_type != ?
+		ORDER BY e.id LIMIT 1
+	`, icalUID, SourceTypeICS).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("find event by iCalUID: %w", err)
+	}
+	return id, nil
+}
+
+// AddEventProvenance records that the source's calendar also holds the
+// event, under externalID.
+func (s *Store) AddEventProvenance(eventID, sourceID, calendarID int64, externalID string) error {
+	return addEventProvenance(s.db, eventID, sourceID, calendarID, externalID)
+}
+
+// AddEventProvenance records that the source's calendar also holds the
+// event, under externalID.
+func (t *Tx) AddEventProvenance(eventID, sourceID, calendarID int64, externalID string) error {
+	return addEventProvenance(t.tx, eventID, sourceID, calendarID, externalID)
+}
+
+func addEventProvenance(db execer, eventID, sourceID, calendarID int64, externalID string) error {
+	_, err := db.Exec(`
+		INSERT INTO event_provenance (event_id, source_id, calendar_id, external_id, seen_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(event_id, source_id, external_id) DO UPDATE SET
+			calendar_id = excluded.calendar_id,
+			seen_at = excluded.seen_at
+	`, eventID, sourceID, calendarID, externalID, time.Now())
+	if err != nil {
+		return fmt.Errorf("add event provenance: %w", err)
+	}
+	return nil
+}
+
+// MergeImportedCopies folds ICS-imported copies of API events into them:
+// for each series or single event with an iCalendar UID, imported events
+// with the same UID (including their instances) are deleted and recorded as
+// provenance of the API event. It returns the number of events deleted.
+func (t *Tx) MergeImportedCopies(events []*Event) (int, error) {
+	find, err := t.tx.Prepare(`
+		SELECT e.id, e.source_id, e.calendar_id, e.google_event_id, COALESCE(e.recurring_event_id, '')
+		FROM events e JOIN sources s ON s.id = e.source_id
+		WHERE e.ical_uid = ? AND s.source_type = ?
+		  AND NOT EXISTS (SELECT 1 FROM event_tombstones t WHERE t.event_id = e.id)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("prepare find imported copies: %w", err)
+	}
+	defer func() { _ = find.Close() }()
+
+	merged := 0
+	for _, e := range events {
+		if e.ICalUID == "" || e.RecurringEventID != "" || e.ID == 0 {
+			continue
+		}
+		type copy struct {
+			id, sourceID, calendarID int64
+			externalID, recurringID  string
+		}
+		var copies []copy
+		rows, err := find.Query(e.ICalUID, SourceTypeICS)
+		if err != nil {
+			return 0, fmt.Errorf("find imported copies: %w", err)
+		}
+		for rows.Next() {
+			var c copy
+			if err := rows.Scan(&c.id, &c.sourceID, &c.calendarID, &c.externalID, &c.recurringID); err != nil {
+				_ = rows.Close()
+				return 0, fmt.Errorf("scan imported copy: %w", err)
+			}
+			copies = append(copies, c)
+		}
+		err = rows.Err()
+		_ = rows.Close()
+		if err != nil {
+			return 0, fmt.Errorf("find imported copies: %w", err)
+		}
+
+		for _, c := range copies {
+			if c.recurringID == "" {
+				if err := addEventProvenance(t.tx, e.ID, c.sourceID, c.calendarID, c.externalID); err != nil {
+					return 0, err
+				}
+			}
+			if _, err := t.tx.Exec(`DELETE FROM events WHERE id = ?`, c.id); err != nil {
+				return 0, fmt.Errorf("delete imported copy: %w", err)
+			}
+			merged++
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,24 @@
+package store
+
+import "strings"
+
+// CanonicalEmail returns the form of an attendee email that people
+// analytics group by, so one person's addresses count as one: lowercased,
+// without a +tag (jane+calendar@example.com is jane@example.com), and
+// with googlemail.com, Gmail's former domain in some countries, as
+// gmail.com. The stored email keeps the address as the provider gave it.
+func CanonicalEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+	if plus := strings.Index(local, "+"); plus > 0 {
+		local = local[:plus]
+	}
+	if domain == "googlemail.com" {
+		domain = "gmail.com"
+	}
+	return local + "@" + domain
+}
@@ -0,0 +1,203 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// EventsToEnrich returns up to limit events (all if limit is 0) the stage
+// hasn't processed since they were last synced, oldest first.
+func (s *Store) EventsToEnrich(stage string, limit int) ([]*Event, error) {
+	query := `
+		SELECT ` + eventColumns + ` FROM events
+		WHERE NOT EXISTS (
+			SELECT 1 FROM enrich_state x
+			WHERE x.event_id = events.id AND x.stage = ? AND x.enriched_at >= events.synced_at
+		)
+		ORDER BY start_time, id`
+	args := []interface{}{stage}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query events to enrich: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var events []*Event
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// CountEventsToEnrich counts the events the stage hasn't processed since
+// they were last synced.
+func (s *Store) CountEventsToEnrich(stage string) (int64, error) {
+	var n int64
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM events
+		WHERE NOT EXISTS (
+			SELECT 1 FROM enrich_state x
+			WHERE x.event_id = events.id AND x.stage = ? AND x.enriched_at >= events.synced_at
+		)`, stage).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count events to enrich: %w", err)
+	}
+	return n, nil
+}
+
+// MarkEnriched records that the stage processed an event.
+func (s *Store) MarkEnriched(eventID int64, stage string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO enrich_state (event_id, stage, enriched_at) VALUES (?, ?, ?)
+		ON CONFLICT(event_id, stage) DO UPDATE SET enriched_at = excluded.enriched_at
+	`, eventID, stage, time.Now())
+	if err != nil {
+		return fmt.Errorf("mark event enriched: %w", err)
+	}
+	return nil
+}
+
+// ClearEnrichState forgets which events the stage processed, so its next
+// run processes every event again.
+func (s *Store) ClearEnrichState(stage string) error {
+	if _, err := s.db.Exec(`DELETE FROM enrich_state WHERE stage = ?`, stage); err != nil {
+		return fmt.Errorf("clear enrich state: %w", err)
+	}
+	return nil
+}
+
+// SetEventPlatform stores the meeting platform of an event, or removes it
+// when platform is empty.
+func (s *Store) SetEventPlatform(eventID int64, platform, url string) error {
+	var err error
+	if platform == "" {
+		_, err = s.db.Exec(`DELETE FROM event_platforms WHERE event_id = ?`, eventID)
+	} else {
+		_, err = s.db.Exec(`
+			INSERT INTO event_platforms (event_id, platform, url) VALUES (?, ?, ?)
+			ON CONFLICT(event_id) DO UPDATE SET platform = excluded.platform, url = excluded.url
+		`, eventID, platform, nullString(url))
+	}
+	if err != nil {
+		return fmt.Errorf("set event platform: %w", err)
+	}
+	return nil
+}
+
+// ClearEventPlatforms removes every detected platform.
+func (s *Store) ClearEventPlatforms() error {
+	if _, err := s.db.Exec(`DELETE FROM event_platforms`); err != nil {
+		return fmt.Errorf("clear event platforms: %w", err)
+	}
+	return nil
+}
+
+// SetEventTags replaces the tags of an event.
+func (s *Store) SetEventTags(eventID int64, tags []string) error {
+	return s.InTx(func(tx *Tx) error {
+		if _, err := tx.tx.Exec(`DELETE FROM event_tags WHERE event_id = ?`, eventID); err != nil {
+			return fmt.Errorf("clear event tags: %w", err)
+		}
+		for _, tag := range tags {
+			if _, err := tx.tx.Exec(`INSERT OR IGNORE INTO event_tags (event_id, tag) VALUES (?, ?)`, eventID, tag); err != nil {
+				return fmt.Errorf("insert event tag: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// ClearEventTags removes every tag.
+func (s *Store) ClearEventTags() error {
+	if _, err := s.db.Exec(`DELETE FROM event_tags`); err != nil {
+		return fmt.Errorf("clear event tags: %w", err)
+	}
+	return nil
+}
+
+// Geocode is a location looked up by the geocode stage. Found is false for
+// locations the geocoder had no match for.
+type Geocode struct {
+	Query       string
+	Found       bool
+	Latitude    float64
+	Longitude   float64
+	DisplayName string
+}
+
+// GetGeocode returns the cached lookup of a normalized location, or nil if
+// it hasn't been looked up.
+func (s *Store) GetGeocode(query string) (*Geocode, error) {
+	g := &Geocode{Query: query}
+	var lat, lon sql.NullFloat64
+	var name sql.NullString
+	err := s.db.QueryRow(`SELECT latitude, longitude, display_name FROM geocodes WHERE query = ?`, query).Scan(&lat, &lon, &name)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get geocode: %w", err)
+	}
+	g.Found = lat.Valid && lon.Valid
+	g.Latitude, g.Longitude, g.DisplayName = lat.Float64, lon.Float64, name.String
+	return g, nil
+}
+
+// SaveGeocode caches the lookup of a normalized location.
+func (s *Store) SaveGeocode(g *Geocode) error {
+	var lat, lon interface{}
+	if g.Found {
+		lat, lon = g.Latitude, g.Longitude
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO geocodes (query, latitude, longitude, display_name, geocoded_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(query) DO UPDATE SET
+			latitude = excluded.latitude, longitude = excluded.longitude,
+			display_name = excluded.display_name, geocoded_at = excluded.geocoded_at
+	`, g.Query, lat, lon, nullString(g.DisplayName), time.Now())
+	if err != nil {
+		return fmt.Errorf("save geocode: %w", err)
+	}
+	return nil
+}
+
+// SetEventLocation stores the coordinates of an event's location, or
+// removes them when g is nil or wasn't found.
+func (s *Store) SetEventLocation(eventID int64, g *Geocode) error {
+	var err error
+	if g == nil || !g.Found {
+		_, err = s.db.Exec(`DELETE FROM event_locations WHERE event_id = ?`, eventID)
+	} else {
+		_, err = s.db.Exec(`
+			INSERT INTO event_locations (event_id, latitude, longitude, display_name) VALUES (?, ?, ?, ?)
+			ON CONFLICT(event_id) DO UPDATE SET
+				latitude = excluded.latitude, longitude = excluded.longitude, display_name = excluded.display_name
+		`, eventID, g.Latitude, g.Longitude, nullString(g.DisplayName))
+	}
+	if err != nil {
+		return fmt.Errorf("set event location: %w", err)
+	}
+	return nil
+}
+
+// ClearEventLocations removes every event's coordinates and the cached
+// lookups, so locations are looked up again.
+func (s *Store) ClearEventLocations() error {
+	return s.InTx(func(tx *Tx) error {
+		for _, table := range []string{"event_locations", "geocodes"} {
+			if _, err := tx.tx.Exec(`DELETE FROM ` + table); err != nil {
+				return fmt.Errorf("clear %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,73 @@
+package store
+
+import "fmt"
+
+// Entity is something an event is about, extracted from its text.
+type Entity struct {
+	Kind       string
+	Name       string
+	Confidence float64
+}
+
+// SaveEntities replaces the entities the extractor found in an event.
+func (s *Store) SaveEntities(eventID int64, extractor string, entities []*Entity) error {
+	return s.InTx(func(tx *Tx) error {
+		if _, err := tx.tx.Exec(`DELETE FROM entities WHERE event_id = ? AND extractor = ?`, eventID, extractor); err != nil {
+			return fmt.Errorf("clear entities: %w", err)
+		}
+		for _, e := range entities {
+			_, err := tx.tx.Exec(`
+				INSERT INTO entities (event_id, kind, name, confidence, extractor) VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT(event_id, extractor, kind, name) DO UPDATE SET
+					confidence = MAX(confidence, excluded.confidence)
+			`, eventID, e.Kind, e.Name, e.Confidence, extractor)
+			if err != nil {
+				return fmt.Errorf("insert entity: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// ClearEntities removes everything the extractor found.
+func (s *Store) ClearEntities(extractor string) error {
+	if _, err := s.db.Exec(`DELETE FROM entities WHERE extractor = ?`, extractor); err != nil {
+		return fmt.Errorf("clear entities: %w", err)
+	}
+	return nil
+}
+
+// EntityCount is how many events mention an entity.
+type EntityCount struct {
+	Kind          string
+	Name          string
+	Events        int
+	MaxConfidence float64
+}
+
+// TopEntities returns the limit entities found in the most events, at or
+// above minConfidence.
+func (s *Store) TopEntities(minConfidence float64, limit int) ([]*EntityCount, error) {
+	rows, err := s.db.Query(`
+		SELECT kind, name, COUNT(DISTINCT event_id), MAX(confidence)
+		FROM entities
+		WHERE confidence >= ?
+		GROUP BY kind, name
+		ORDER BY COUNT(DISTINCT event_id) DESC, kind, name
+		LIMIT ?
+	`, minConfidence, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query top entities: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var counts []*EntityCount
+	for rows.Next() {
+		var c EntityCount
+		if err := rows.Scan(&c.Kind, &c.Name, &c.Events, &c.MaxConfidence); err != nil {
+			return nil, fmt.Errorf("scan entity count: %w", err)
+		}
+		counts = append(counts, &c)
+	}
+	return counts, rows.Err()
+}
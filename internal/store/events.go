@@ -0,0 +1,281 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventFilter restricts which events are listed. Zero values are ignored.
+type EventFilter struct {
+	SourceID   int64
+	CalendarID int64
+	Since      time.Time // Starts at or after Since; all-day events from midnight in Since's zone
+	Until      time.Time // Starts before Until; all-day events from midnight in Until's zone
+
+	RecurringEventID string // Instances and exceptions of this series
+	SeriesOnly       bool   // Recurring series: events with a rule that aren't instances
+	InstancesOnly    bool   // Instances and exceptions of any series
+
+	Attendee string // Substring of an attendee's email or name (case-insensitive)
+	Location string // Substring of the location (case-insensitive)
+	Status   string // confirmed (also matches events without a status), tentative or cancelled
+	Limit    int    // Maximum number of events listed; 0 = no limit
+}
+
+// eventColumns lists the columns scanned by scanEvent, in order.
+const eventColumns = `
+	id, source_id, calendar_id, google_event_id, COALESCE(ical_uid, ''),
+	COALESCE(summary, ''), COALESCE(description, ''), COALESCE(location, ''),
+	start_time, end_time, COALESCE(all_day, FALSE), COALESCE(original_timezone, ''),
+	COALESCE(recurring_event_id, ''), COALESCE(recurrence_rule, ''),
+	COALESCE(status, ''), COALESCE(visibility, ''), COALESCE(color_id, ''), COALESCE(quality, ''),
+	COALESCE(organizer_email, ''), COALESCE(organizer_name, ''), COALESCE(creator_email, ''),
+	created_at, updated_at, synced_at`
+
+// calendarColumns lists the columns scanned by scanCalendar, in order.
+const calendarColumns = `
+	id, source_id, google_calendar_id, COALESCE(summary, ''), COALESCE(description, ''),
+	COALESCE(timezone, ''), is_primary, sync_token, page_token, last_synced_at,
+	COALESCE(access_role, ''), COALESCE(subscription_kind, ''), COALESCE(private, FALSE),
+	COALESCE(color_id, ''), COALESCE(background_color, '')`
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanEvent scans a row selected with eventColumns.
+func scanEvent(row scanner) (*Event, error) {
+	var e Event
+	if err := row.Scan(
+		&e.ID, &e.SourceID, &e.CalendarID, &e.GoogleEventID, &e.ICalUID,
+		&e.Summary, &e.Description, &e.Location,
+		&e.StartTime, &e.EndTime, &e.AllDay, &e.OriginalTimezone,
+		&e.RecurringEventID, &e.RecurrenceRule,
+		&e.Status, &e.Visibility, &e.ColorID, &e.Quality,
+		&e.OrganizerEmail, &e.OrganizerName, &e.CreatorEmail,
+		&e.CreatedAt, &e.UpdatedAt, &e.SyncedAt,
+	); err != nil {
+		return nil, fmt.Errorf("scan event: %w", err)
+	}
+	return &e, nil
+}
+
+// scanCalendar scans a row selected with calendarColumns.
+func scanCalendar(row scanner) (*Calendar, error) {
+	var cal Calendar
+	if err := row.Scan(
+		&cal.ID, &cal.SourceID, &cal.GoogleCalendarID, &cal.Summary,
+		&cal.Description, &cal.Timezone, &cal.IsPrimary, &cal.SyncToken, &cal.PageToken, &cal.LastSyncedAt,
+		&cal.AccessRole, &cal.SubscriptionKind, &cal.Private,
+		&cal.ColorID, &cal.BackgroundColor,
+	); err != nil {
+		return nil, fmt.Errorf("scan calendar: %w", err)
+	}
+	return &cal, nil
+}
+
+// allDayBound returns the date, as YYYY-MM-DD, of the first midnight at or
+// after t in t's zone: an all-day event starts at or after t exactly when
+// its date is on or after it.
+func allDayBound(t time.Time) string {
+	y, m, d := t.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	if midnight.Before(t) {
+		midnight = midnight.AddDate(0, 0, 1)
+	}
+	return midnight.Format("2006-01-02")
+}
+
+// startBound returns a condition, and its arguments, comparing the start of
+// the events or event_instances row aliased alias ("" if unaliased) with t
+// using op (">=" or "<"). All-day events are stored at UTC midnight of
+// their date, so they are compared by date in t's zone (allDayBound) and
+// timed events by instant.
+func startBound(alias, op string, t time.Time) (string, []interface{}) {
+	col := func(name string) string {
+		if alias == "" {
+			return name
+		}
+		return alias + "." + name
+	}
+	cond := fmt.Sprintf("CASE WHEN %s THEN substr(%s, 1, 10) %s ? ELSE %s %s ? END",
+		col("all_day"), col("start_time"), op, col("start_time"), op)
+	return cond, []interface{}{allDayBound(t), t}
+}
+
+// startsIn returns a condition, and its arguments, selecting rows that
+// start in [since, until); see startBound.
+func startsIn(alias string, since, until time.Time) (string, []interface{}) {
+	from, fromArgs := startBound(alias, ">=", since)
+	to, toArgs := startBound(alias, "<", until)
+	return from + " AND " + to, append(fromArgs, toArgs...)
+}
+
+// where returns the filter as a WHERE clause and its arguments. Events
+// tombstoned under legal hold are always left out.
+func (f EventFilter) where() (string, []interface{}) {
+	where := []string{notTombstoned}
+	var args []interface{}
+	if f.SourceID > 0 {
+		where = append(where, "source_id = ?")
+		args = append(args, f.SourceID)
+	}
+	if f.CalendarID > 0 {
+		where = append(where, "calendar_id = ?")
+		args = append(args, f.CalendarID)
+	}
+	if !f.Since.IsZero() {
+		cond, condArgs := startBound("", ">=", f.Since)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+	if !f.Until.IsZero() {
+		cond, condArgs := startBound("", "<", f.Until)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+	if f.RecurringEventID != "" {
+		where = append(where, "recurring_event_id = ?")
+		args = append(args, f.RecurringEventID)
+	}
+	if f.SeriesOnly {
+		where = append(where, "COALESCE(recurrence_rule, '') != '' AND COALESCE(recurring_event_id, '') = ''")
+	}
+	if f.InstancesOnly {
+		where = append(where, "COALESCE(recurring_event_id, '') != ''")
+	}
+	if f.Attendee != "" {
+		where = append(where, `id IN (SELECT event_id FROM attendees
+			WHERE email LIKE ? ESCAPE '\' OR display_name LIKE ? ESCAPE '\')`)
+		pattern := "%" + escapeLike(f.Attendee) + "%"
+		args = append(args, pattern, pattern)
+	}
+	if f.Location != "" {
+		where = append(where, `location LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(f.Location)+"%")
+	}
+	if f.Status != "" {
+		where = append(where, "COALESCE(NULLIF(status, ''), 'confirmed') = ?")
+		args = append(args, f.Status)
+	}
+	return ` WHERE ` + strings.Join(where, " AND "), args
+}
+
+// CountEvents returns the number of events matching the filter.
+func (s *Store) CountEvents(filter EventFilter) (int64, error) {
+	where, args := filter.where()
+	var n int64
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM events`+where, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count events: %w", err)
+	}
+	return n, nil
+}
+
+// ListEvents returns events matching the filter, ordered by start time.
+func (s *Store) ListEvents(filter EventFilter) ([]*Event, error) {
+	where, args := filter.where()
+	query := `SELECT ` + eventColumns + ` FROM events` + where + ` ORDER BY start_time, id`
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(` LIMIT %d`, filter.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var events []*Event
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// ListAttendees returns the attendees of an event.
+func (s *Store) ListAttendees(eventID int64) ([]*Attendee, error) {
+	rows, err := s.db.Query(`
+		SELECT id, event_id, email, COALESCE(display_name, ''), COALESCE(response_status, ''),
+		       COALESCE(is_organizer, FALSE), COALESCE(is_self, FALSE)
+		FROM attendees WHERE event_id = ?
+		ORDER BY id
+	`, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("query attendees: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var attendees []*Attendee
+	for rows.Next() {
+		var a Attendee
+		if err := rows.Scan(&a.ID, &a.EventID, &a.Email, &a.DisplayName,
+			&a.ResponseStatus, &a.IsOrganizer, &a.IsSelf); err != nil {
+			return nil, fmt.Errorf("scan attendee: %w", err)
+		}
+		attendees = append(attendees, &a)
+	}
+
+	return attendees, rows.Err()
+}
+
+// FindEvents returns events matching ref, which may be a numeric event ID
+// or a Google event ID (the id in 'calvault events --json').
+func (s *Store) FindEvents(ref string) ([]*Event, error) {
+	id, _ := strconv.ParseInt(ref, 10, 64)
+	rows, err := s.db.Query(`
+		SELECT `+eventColumns+`
+		FROM events
+		WHERE id = ? OR google_event_id = ?
+		ORDER BY id
+	`, id, ref)
+	if err != nil {
+		return nil, fmt.Errorf("query events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var events []*Event
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// FindCalendars returns calendars matching ref, which may be a numeric
+// calendar ID, a Google calendar ID, or a calendar name.
+func (s *Store) FindCalendars(ref string) ([]*Calendar, error) {
+	id, _ := strconv.ParseInt(ref, 10, 64)
+	rows, err := s.db.Query(`
+		SELECT `+calendarColumns+`
+		FROM calendars
+		WHERE id = ? OR google_calendar_id = ? OR summary = ?
+		ORDER BY id
+	`, id, ref, ref)
+	if err != nil {
+		return nil, fmt.Errorf("query calendars: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var calendars []*Calendar
+	for rows.Next() {
+		cal, err := scanCalendar(rows)
+		if err != nil {
+			return nil, err
+		}
+		calendars = append(calendars, cal)
+	}
+
+	return calendars, rows.Err()
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// historyColumns are the event columns event_history keeps. A change to
+// any but updated_at records the previous version.
+var historyColumns = []string{
+	"summary", "description", "location", "start_time", "end_time", "all_day",
+	"original_timezone", "recurrence_rule", "status", "visibility", "organizer_email",
+	"updated_at",
+}
+
+// EventVersion is an event as it was until a change replaced it.
+type EventVersion struct {
+	ChangedAt time.Time // When the change was stored
+	// Event holds the kept columns of the earlier version; the rest are zero
+	Event *Event
+}
+
+// historyTriggerSQL is the trigger that copies an event's previous version
+// into event_history when an update changes its details.
+func historyTriggerSQL() string {
+	var changed, old []string
+	for _, c := range historyColumns {
+		if c != "updated_at" {
+			changed = append(changed, fmt.Sprintf("old.%s IS NOT new.%s", c, c))
+		}
+		old = append(old, "old."+c)
+	}
+	return `CREATE TRIGGER trg_events_history AFTER UPDATE ON events
+WHEN ` + strings.Join(changed, " OR ") + `
+BEGIN
+	INSERT INTO event_history (event_id, changed_at, ` + strings.Join(historyColumns, ", ") + `)
+	VALUES (old.id, strftime('%Y-%m-%d %H:%M:%f', 'now'), ` + strings.Join(old, ", ") + `);
+END`
+}
+
+// SetEventHistory turns keeping earlier versions of events in event_history
+// on or off. The setting is stored in the database: it holds for every
+// writer until changed, and versions already kept stay either way.
+func (s *Store) SetEventHistory(enabled bool) error {
+	var current string
+	err := s.db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'trigger' AND name = 'trg_events_history'`).Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("check event history trigger: %w", err)
+	}
+	want := ""
+	if enabled {
+		want = historyTriggerSQL()
+	}
+	if current == want {
+		return nil
+	}
+	if _, err := s.db.Exec(`DROP TRIGGER IF EXISTS trg_events_history`); err != nil {
+		return fmt.Errorf("drop event history trigger: %w", err)
+	}
+	if enabled {
+		if _, err := s.db.Exec(want); err != nil {
+			return fmt.Errorf("create event history trigger: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetEventHistory returns an event's earlier versions, oldest first.
+func (s *Store) GetEventHistory(eventID int64) ([]*EventVersion, error) {
+	rows, err := s.db.Query(`
+		SELECT changed_at, COALESCE(summary, ''), COALESCE(description, ''), COALESCE(location, ''),
+		       start_time, end_time, COALESCE(all_day, FALSE), COALESCE(original_timezone, ''),
+		       COALESCE(recurrence_rule, ''), COALESCE(status, ''), COALESCE(visibility, ''),
+		       COALESCE(organizer_email, ''), updated_at
+		FROM event_history
+		WHERE event_id = ?
+		ORDER BY changed_at, id
+	`, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("get event history: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var versions []*EventVersion
+	for rows.Next() {
+		e := &Event{ID: eventID}
+		v := &EventVersion{Event: e}
+		if err := rows.Scan(&v.ChangedAt, &e.Summary, &e.Description, &e.Location,
+			&e.StartTime, &e.EndTime, &e.AllDay, &e.OriginalTimezone,
+			&e.RecurrenceRule, &e.Status, &e.Visibility,
+			&e.OrganizerEmail, &e.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan event version: %w", err)
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// AsOfEventsSQL returns a query of the events table as it was at t, for
+// time-travel queries. Events the provider created after t are left out,
+// as are events legal hold kept after a deletion before t, and each event
+// has the details of its event_history version current at t. columns are
+// the events table's stored columns, in order; the derived columns follow,
+// computed from the times as they were. Changes made while event_history
+// was off, and events deleted outright, can't be recovered.
+func AsOfEventsSQL(columns []string, t time.Time) string {
+	kept := make(map[string]bool, len(historyColumns))
+	for _, c := range historyColumns {
+		kept[c] = true
+	}
+	// changed_at and deleted_at are written by strftime in UTC, so they
+	// compare as text and the history index applies
+	at := "'" + t.UTC().Format("2006-01-02 15:04:05.000") + "'"
+
+	selects := make([]string, 0, len(columns))
+	for _, c := range columns {
+		if kept[c] {
+			selects = append(selects, fmt.Sprintf("CASE WHEN h.id IS NULL THEN e.%s ELSE h.%s END AS %s", c, c, c))
+		} else if _, derived := derivedColumns[c]; !derived {
+			selects = append(selects, "e."+c)
+		}
+	}
+	inner := `SELECT ` + strings.Join(selects, ", ") + `
+	FROM main.events e
+	LEFT JOIN main.event_history h ON h.id = (
+		SELECT id FROM main.event_history
+		WHERE event_id = e.id AND changed_at > ` + at + `
+		ORDER BY changed_at, id LIMIT 1)
+	WHERE (e.created_at IS NULL OR julianday(e.created_at) <= julianday(` + at + `))
+	  AND NOT EXISTS (SELECT 1 FROM main.event_tombstones t WHERE t.event_id = e.id AND t.deleted_at <= ` + at + `)`
+
+	names := make([]string, 0, len(derivedColumns))
+	for name := range derivedColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	outer := []string{"*"}
+	for _, name := range names {
+		outer = append(outer, derivedExpr(derivedColumns[name])+" AS "+name)
+	}
+	return `SELECT ` + strings.Join(outer, ", ") + ` FROM (` + inner + `)`
+}
+
+// derivedExpr returns the expression of a derivedColumns declaration.
+func derivedExpr(decl string) string {
+	expr := decl[strings.Index(decl, " AS ")+len(" AS "):]
+	return strings.TrimSuffix(expr, " VIRTUAL")
+}
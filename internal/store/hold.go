@@ -0,0 +1,124 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLegalHold is returned by operations that would delete archived rows
+// while the database is under legal hold.
+var ErrLegalHold = errors.New("the archive is under legal hold ([storage] legal_hold)")
+
+// legalHoldTriggerSQL is the trigger that turns deleting an event into a
+// tombstone: the delete is skipped and the first attempt recorded in
+// event_tombstones. It catches every path that deletes events - syncs,
+// imports, merges of imported copies and cascades. Listings and reports
+// leave tombstoned events out, so like trg_events_analytics_delete it marks
+// the event's day and people for the analytics tables to refresh - RAISE
+// (IGNORE) would skip that trigger if it ran first.
+const legalHoldTriggerSQL = `CREATE TRIGGER trg_events_legal_hold BEFORE DELETE ON events
+BEGIN
+	INSERT OR IGNORE INTO event_tombstones (event_id, deleted_at)
+	VALUES (old.id, strftime('%Y-%m-%d %H:%M:%f', 'now'));
+	INSERT INTO analytics_dirty (source_id, kind, key)
+	SELECT old.source_id, 'day', substr(old.start_time, 1, 10)
+	WHERE old.start_time IS NOT NULL
+	  AND NOT EXISTS (SELECT 1 FROM analytics_dirty
+	                  WHERE source_id = old.source_id AND kind = 'day' AND key = substr(old.start_time, 1, 10));
+	INSERT INTO analytics_dirty (source_id, kind, key)
+	SELECT DISTINCT old.source_id, 'person', a.canonical_email FROM attendees a
+	WHERE a.event_id = old.id
+	  AND NOT EXISTS (SELECT 1 FROM analytics_dirty
+	                  WHERE source_id = old.source_id AND kind = 'person' AND key = a.canonical_email);
+	SELECT RAISE(IGNORE);
+END`
+
+// notTombstoned leaves out events kept under legal hold after something
+// deleted them, in queries on the events table that don't alias it.
+const notTombstoned = `NOT EXISTS (SELECT 1 FROM event_tombstones t WHERE t.event_id = events.id)`
+
+// SetLegalHold puts the database under legal hold or lifts it. Under hold,
+// events are never deleted - deletions are recorded as tombstones instead
+// - and removing accounts, purging the trash and cleaning up orphans fail
+// with ErrLegalHold. Like SetEventHistory, the setting is stored in the
+// database and holds for every writer until changed.
+func (s *Store) SetLegalHold(enabled bool) error {
+	held, err := legalHold(s.db)
+	if err != nil {
+		return err
+	}
+	if held && enabled {
+		return s.upgradeLegalHoldTrigger()
+	}
+	if held == enabled {
+		return nil
+	}
+	if enabled {
+		if _, err := s.db.Exec(legalHoldTriggerSQL); err != nil {
+			return fmt.Errorf("create legal hold trigger: %w", err)
+		}
+		return nil
+	}
+	if _, err := s.db.Exec(`DROP TRIGGER IF EXISTS trg_events_legal_hold`); err != nil {
+		return fmt.Errorf("drop legal hold trigger: %w", err)
+	}
+	return nil
+}
+
+// upgradeLegalHoldTrigger replaces a legal hold trigger created by an older
+// calvault, which didn't mark the analytics tables dirty, with the current one.
+func (s *Store) upgradeLegalHoldTrigger() error {
+	var current string
+	err := s.db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'trigger' AND name = 'trg_events_legal_hold'`).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("check legal hold trigger: %w", err)
+	}
+	if current == legalHoldTriggerSQL {
+		return nil
+	}
+	return s.InTx(func(tx *Tx) error {
+		if _, err := tx.tx.Exec(`DROP TRIGGER trg_events_legal_hold`); err != nil {
+			return fmt.Errorf("drop legal hold trigger: %w", err)
+		}
+		if _, err := tx.tx.Exec(legalHoldTriggerSQL); err != nil {
+			return fmt.Errorf("create legal hold trigger: %w", err)
+		}
+		return nil
+	})
+}
+
+// LegalHold reports whether the database is under legal hold.
+func (s *Store) LegalHold() (bool, error) {
+	return legalHold(s.db)
+}
+
+func legalHold(db execer) (bool, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = 'trg_events_legal_hold'`).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("check legal hold trigger: %w", err)
+	}
+	return n > 0, nil
+}
+
+// checkLegalHold returns ErrLegalHold if the database is under legal hold.
+func checkLegalHold(db execer) error {
+	held, err := legalHold(db)
+	if err != nil {
+		return err
+	}
+	if held {
+		return ErrLegalHold
+	}
+	return nil
+}
+
+// CountTombstones returns how many events have been kept under legal hold
+// after something deleted them.
+func (s *Store) CountTombstones() (int64, error) {
+	var n int64
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM event_tombstones`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count tombstones: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"fmt"
+	"maps"
+)
+
+// Identity is one person's email addresses, the first standing for all of
+// them in people analytics.
+type Identity struct {
+	Name   string
+	Emails []string
+}
+
+// person is a row of the people table.
+type person struct {
+	email, name string
+}
+
+// SetIdentities makes the people table hold identities. The setting is
+// stored in the database, like SetEventHistory's: attendees written from
+// then on get their person's email as canonical_email. When it changes,
+// stored attendees are updated to match, attendees sharing a person with
+// their event's account are marked is_self, and the analytics tables are
+// rebuilt. is_self marks from an identity no longer configured stay until
+// the event is synced again.
+func (s *Store) SetIdentities(identities []Identity) error {
+	want := map[string]person{}
+	for _, id := range identities {
+		if len(id.Emails) == 0 {
+			continue
+		}
+		p := person{email: CanonicalEmail(id.Emails[0]), name: id.Name}
+		for _, email := range id.Emails {
+			want[CanonicalEmail(email)] = p
+		}
+	}
+
+	current, err := s.people()
+	if err != nil {
+		return err
+	}
+	if maps.Equal(current, want) {
+		return nil
+	}
+
+	// Attendees whose canonical_email either mapping touches
+	affected := map[string]bool{}
+	for alias, p := range current {
+		affected[alias] = true
+		affected[p.email] = true
+	}
+	for alias := range want {
+		affected[alias] = true
+	}
+
+	err = s.InTx(func(tx *Tx) error {
+		if _, err := tx.tx.Exec(`DELETE FROM people`); err != nil {
+			return fmt.Errorf("clear people: %w", err)
+		}
+		insert, err := tx.tx.Prepare(`INSERT INTO people (email, person_email, name) VALUES (?, ?, NULLIF(?, ''))`)
+		if err != nil {
+			return fmt.Errorf("insert person: %w", err)
+		}
+		defer func() { _ = insert.Close() }()
+		for alias, p := range want {
+			if _, err := insert.Exec(alias, p.email, p.name); err != nil {
+				return fmt.Errorf("insert person: %w", err)
+			}
+		}
+		if err := recanonicalizeAttendees(tx, affected, want); err != nil {
+			return err
+		}
+		return markSelfAliases(tx)
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := s.RebuildAnalyticsTables(); err != nil {
+		return fmt.Errorf("rebuild analytics tables: %w", err)
+	}
+	return nil
+}
+
+// markSelfAliases marks is_self the attendees who are the same person as
+// their event's account. Accounts are matched by CanonicalEmail, as people
+// keys aliases, so a googlemail.com account finds its gmail.com person.
+func markSelfAliases(tx *Tx) error {
+	rows, err := tx.tx.Query(`SELECT id, identifier FROM sources`)
+	if err != nil {
+		return fmt.Errorf("read sources: %w", err)
+	}
+	accounts := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var identifier string
+		if err := rows.Scan(&id, &identifier); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("read sources: %w", err)
+		}
+		accounts[id] = CanonicalEmail(identifier)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("read sources: %w", err)
+	}
+
+	for id, email := range accounts {
+		if _, err := tx.tx.Exec(`
+			UPDATE attendees SET is_self = TRUE
+			WHERE NOT COALESCE(is_self, FALSE)
+			  AND canonical_email = (SELECT person_email FROM people WHERE email = ?)
+			  AND event_id IN (SELECT id FROM events WHERE source_id = ?)
+		`, email, id); err != nil {
+			return fmt.Errorf("mark self aliases: %w", err)
+		}
+	}
+	return nil
+}
+
+// people returns the people table, by alias.
+func (s *Store) people() (map[string]person, error) {
+	rows, err := s.db.Query(`SELECT email, person_email, COALESCE(name, '') FROM people`)
+	if err != nil {
+		return nil, fmt.Errorf("read people: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	people := map[string]person{}
+	for rows.Next() {
+		var alias string
+		var p person
+		if err := rows.Scan(&alias, &p.email, &p.name); err != nil {
+			return nil, fmt.Errorf("read people: %w", err)
+		}
+		people[alias] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read people: %w", err)
+	}
+	return people, nil
+}
+
+// recanonicalizeAttendees recomputes canonical_email under people for the
+// attendees whose canonical_email is in affected.
+func recanonicalizeAttendees(tx *Tx, affected map[string]bool, people map[string]person) error {
+	rows, err := tx.tx.Query(`SELECT id, email, canonical_email FROM attendees WHERE canonical_email IS NOT NULL`)
+	if err != nil {
+		return fmt.Errorf("read attendee emails: %w", err)
+	}
+	emails := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var email, canonical string
+		if err := rows.Scan(&id, &email, &canonical); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("read attendee emails: %w", err)
+		}
+		if !affected[canonical] {
+			continue
+		}
+		want := CanonicalEmail(email)
+		if p, ok := people[want]; ok {
+			want = p.email
+		}
+		if want != canonical {
+			emails[id] = want
+		}
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("read attendee emails: %w", err)
+	}
+
+	update, err := tx.tx.Prepare(`UPDATE attendees SET canonical_email = ? WHERE id = ?`)
+	if err != nil {
+		return fmt.Errorf("set canonical emails: %w", err)
+	}
+	defer func() { _ = update.Close() }()
+	for id, email := range emails {
+		if _, err := update.Exec(email, id); err != nil {
+			return fmt.Errorf("set canonical emails: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UserIndexPrefix prefixes the names of user-created indexes.
+const UserIndexPrefix = "user_idx_"
+
+// UserIndex is an index created by a user rather than the built-in schema.
+type UserIndex struct {
+	Name      string
+	Table     string
+	Columns   []string
+	CreatedAt time.Time
+}
+
+// CreateUserIndex creates an index on table(columns) in the user index
+// namespace and returns its name. The table and columns must exist.
+func (s *Store) CreateUserIndex(table string, columns []string) (string, error) {
+	if len(columns) == 0 {
+		return "", fmt.Errorf("no columns given")
+	}
+
+	// Generated columns can be indexed too
+	rows, err := s.db.Query(`SELECT name FROM pragma_table_xinfo(?) WHERE hidden != 1`, table)
+	if err != nil {
+		return "", fmt.Errorf("list columns: %w", err)
+	}
+	known := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			_ = rows.Close()
+			return "", fmt.Errorf("scan column: %w", err)
+		}
+		known[name] = true
+	}
+	_ = rows.Close()
+	if len(known) == 0 {
+		return "", fmt.Errorf("table %q not found", table)
+	}
+	for _, c := range columns {
+		if !known[c] {
+			return "", fmt.Errorf("column %q not found in %s", c, table)
+		}
+	}
+
+	name := UserIndexPrefix + table + "_" + strings.Join(columns, "_")
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = `"` + c + `"`
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Identifiers were validated against the schema above
+	if _, err := tx.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%s" ON "%s" (%s)`,
+		name, table, strings.Join(quoted, ", "))); err != nil {
+		return "", fmt.Errorf("create index: %w", err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO user_indexes (name, table_name, columns, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO NOTHING
+	`, name, table, strings.Join(columns, ","), time.Now()); err != nil {
+		return "", fmt.Errorf("record index: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("commit: %w", err)
+	}
+	return name, nil
+}
+
+// ListUserIndexes returns user-created indexes ordered by name.
+func (s *Store) ListUserIndexes() ([]*UserIndex, error) {
+	rows, err := s.db.Query(`SELECT name, table_name, columns, created_at FROM user_indexes ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("query user indexes: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var indexes []*UserIndex
+	for rows.Next() {
+		var idx UserIndex
+		var columns string
+		if err := rows.Scan(&idx.Name, &idx.Table, &columns, &idx.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan user index: %w", err)
+		}
+		idx.Columns = strings.Split(columns, ",")
+		indexes = append(indexes, &idx)
+	}
+
+	return indexes, rows.Err()
+}
+
+// DropUserIndex drops a user-created index. Built-in indexes can't be dropped.
+func (s *Store) DropUserIndex(name string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.Exec(`DELETE FROM user_indexes WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("delete user index: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("user index %q not found", name)
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`DROP INDEX IF EXISTS "%s"`, strings.ReplaceAll(name, `"`, `""`))); err != nil {
+		return fmt.Errorf("drop index: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EventInstance is one occurrence of a recurring series.
+type EventInstance struct {
+	SourceID         int64
+	RecurringEventID string        // The series' google_event_id
+	SeriesID         sql.NullInt64 // Invalid if the series isn't stored
+	EventID          int64         // The series for expanded occurrences, else the stored instance
+	Start            sql.NullTime
+	End              sql.NullTime
+	AllDay           bool
+	Expanded         bool
+}
+
+// ReplaceEventInstances replaces the contents of event_instances.
+func (s *Store) ReplaceEventInstances(instances []*EventInstance) error {
+	return s.InTx(func(tx *Tx) error {
+		if _, err := tx.tx.Exec(`DELETE FROM event_instances`); err != nil {
+			return fmt.Errorf("clear event instances: %w", err)
+		}
+		stmt, err := tx.tx.Prepare(`
+			INSERT INTO event_instances (source_id, recurring_event_id, series_id, event_id, start_time, end_time, all_day, expanded)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`)
+		if err != nil {
+			return fmt.Errorf("prepare event instance insert: %w", err)
+		}
+		defer func() { _ = stmt.Close() }()
+
+		for _, inst := range instances {
+			if _, err := stmt.Exec(inst.SourceID, inst.RecurringEventID, inst.SeriesID, inst.EventID,
+				inst.Start, inst.End, inst.AllDay, inst.Expanded); err != nil {
+				return fmt.Errorf("insert event instance: %w", err)
+			}
+		}
+		return nil
+	})
+}
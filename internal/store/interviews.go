@@ -0,0 +1,174 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// InterviewRules decides which meetings are interviews and which of their
+// attendees interviewed.
+type InterviewRules struct {
+	IsInterview   func(title string, attendees []string) bool
+	IsInterviewer func(email, account string) bool // account: email of the source the event came from
+}
+
+// InterviewWeek is the interview load of one week.
+type InterviewWeek struct {
+	Start      time.Time
+	Interviews int
+	Hours      float64
+}
+
+// InterviewerLoad is one interviewer's share of the interviews.
+type InterviewerLoad struct {
+	Email      string
+	Name       string
+	Interviews int
+	Hours      float64
+	Last       time.Time
+}
+
+// InterviewReport tallies interviews per week and per interviewer.
+type InterviewReport struct {
+	Interviews   int
+	Hours        float64
+	Weeks        []*InterviewWeek   // Every week in the period, oldest first
+	Interviewers []*InterviewerLoad // By interviews descending
+}
+
+// GetInterviewReport finds the interviews starting in [since, until) among
+// meetings (as counted by the analytics tables) using rules. Weeks begin on
+// weekStart in since's location. An interview synced from several accounts
+// is counted once.
+func (s *Store) GetInterviewReport(since, until time.Time, weekStart time.Weekday, rules InterviewRules) (*InterviewReport, error) {
+	inRange, inRangeArgs := startsIn("e", since, until)
+	rows, err := s.db.Query(`
+		SELECT e.id, e.google_event_id, COALESCE(e.summary, ''), e.start_time, e.end_time, src.identifier
+		FROM events e
+		JOIN sources src ON src.id = e.source_id
+		WHERE `+inRange+`
+		  AND `+meetingCondition+`
+		ORDER BY e.start_time, e.id
+	`, inRangeArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("query meetings: %w", err)
+	}
+	type meeting struct {
+		key        string
+		title      string
+		start, end time.Time
+		account    string
+	}
+	var meetings []*meeting
+	byID := map[int64]*meeting{}
+	for rows.Next() {
+		var id int64
+		var eventID string
+		m := &meeting{}
+		if err := rows.Scan(&id, &eventID, &m.title, &m.start, &m.end, &m.account); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("scan meeting: %w", err)
+		}
+		m.key = eventID + "|" + m.start.UTC().Format(time.RFC3339)
+		meetings = append(meetings, m)
+		byID[id] = m
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query meetings: %w", err)
+	}
+
+	// Attendees who didn't decline, per meeting
+	attendees := map[*meeting][]*Attendee{}
+	rows, err = s.db.Query(`
+		SELECT a.event_id, a.email, COALESCE(a.display_name, '')
+		FROM attendees a
+		JOIN events e ON e.id = a.event_id
+		WHERE `+inRange+`
+		  AND COALESCE(a.response_status, '') != 'declined'
+		ORDER BY a.id
+	`, inRangeArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("query attendees: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var eventID int64
+		a := &Attendee{}
+		if err := rows.Scan(&eventID, &a.Email, &a.DisplayName); err != nil {
+			return nil, fmt.Errorf("scan attendee: %w", err)
+		}
+		if m := byID[eventID]; m != nil {
+			attendees[m] = append(attendees[m], a)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query attendees: %w", err)
+	}
+
+	loc := since.Location()
+	weekOf := func(t time.Time) time.Time {
+		t = t.In(loc)
+		y, m, d := t.Date()
+		return time.Date(y, m, d-(int(t.Weekday())-int(weekStart)+7)%7, 0, 0, 0, 0, loc)
+	}
+	report := &InterviewReport{}
+	weeks := map[time.Time]*InterviewWeek{}
+	for w := weekOf(since); w.Before(until); w = w.AddDate(0, 0, 7) {
+		week := &InterviewWeek{Start: w}
+		weeks[w] = week
+		report.Weeks = append(report.Weeks, week)
+	}
+
+	interviewers := map[string]*InterviewerLoad{}
+	seen := map[string]bool{}
+	for _, m := range meetings {
+		emails := make([]string, 0, len(attendees[m]))
+		for _, a := range attendees[m] {
+			emails = append(emails, a.Email)
+		}
+		if seen[m.key] || !rules.IsInterview(m.title, emails) {
+			continue
+		}
+		seen[m.key] = true
+
+		hours := m.end.Sub(m.start).Hours()
+		report.Interviews++
+		report.Hours += hours
+		if week := weeks[weekOf(m.start)]; week != nil {
+			week.Interviews++
+			week.Hours += hours
+		}
+		for _, a := range attendees[m] {
+			if !rules.IsInterviewer(a.Email, m.account) {
+				continue
+			}
+			key := strings.ToLower(a.Email)
+			l := interviewers[key]
+			if l == nil {
+				l = &InterviewerLoad{Email: a.Email}
+				interviewers[key] = l
+			}
+			if l.Name == "" {
+				l.Name = a.DisplayName
+			}
+			l.Interviews++
+			l.Hours += hours
+			l.Last = m.start
+		}
+	}
+
+	for _, l := range interviewers {
+		report.Interviewers = append(report.Interviewers, l)
+	}
+	sort.Slice(report.Interviewers, func(i, j int) bool {
+		a, b := report.Interviewers[i], report.Interviewers[j]
+		if a.Interviews != b.Interviews {
+			return a.Interviews > b.Interviews
+		}
+		return a.Email < b.Email
+	})
+	return report, nil
+}
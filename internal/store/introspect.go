@@ -0,0 +1,216 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// SchemaObject is a table, view, index or trigger as recorded in
+// sqlite_master.
+type SchemaObject struct {
+	Type  string
+	Name  string
+	Table string
+	SQL   string
+}
+
+// TableInfo describes a table's columns, foreign keys and indexes, or a
+// view's columns.
+type TableInfo struct {
+	Name        string
+	View        bool
+	Columns     []ColumnInfo
+	ForeignKeys []ForeignKeyInfo
+	Indexes     []IndexInfo
+}
+
+// ColumnInfo describes a table column.
+type ColumnInfo struct {
+	Name       string
+	Type       string
+	NotNull    bool
+	PrimaryKey bool
+}
+
+// ForeignKeyInfo describes a single-column foreign key.
+type ForeignKeyInfo struct {
+	From     string // Column in the referencing table
+	Table    string // Referenced table
+	To       string // Referenced column
+	OnDelete string
+}
+
+// IndexInfo describes an index, including those SQLite creates for UNIQUE
+// constraints. Primary key indexes are left out.
+type IndexInfo struct {
+	Name    string
+	Columns []string // "<expr>" for an expression
+	Unique  bool
+}
+
+// SchemaObjects returns the live schema in an order it can be replayed in:
+// tables, then views, indexes and triggers, each by name. SQLite's internal
+// tables, automatic indexes and FTS shadow tables are left out.
+func (s *Store) SchemaObjects() ([]*SchemaObject, error) {
+	rows, err := s.db.Query(`
+		SELECT type, name, tbl_name, sql FROM sqlite_master
+		WHERE sql IS NOT NULL
+		  AND name NOT LIKE 'sqlite_%'
+		  AND name NOT IN (SELECT name FROM pragma_table_list WHERE type = 'shadow')
+		ORDER BY CASE type WHEN 'table' THEN 0 WHEN 'view' THEN 1 WHEN 'index' THEN 2 ELSE 3 END, name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query schema: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var objects []*SchemaObject
+	for rows.Next() {
+		o := &SchemaObject{}
+		if err := rows.Scan(&o.Type, &o.Name, &o.Table, &o.SQL); err != nil {
+			return nil, fmt.Errorf("scan schema: %w", err)
+		}
+		objects = append(objects, o)
+	}
+	return objects, rows.Err()
+}
+
+// Tables describes every ordinary table, then every view, each by name.
+// Virtual and shadow tables are left out.
+func (s *Store) Tables() ([]*TableInfo, error) {
+	names, views, err := s.tableNames()
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make([]*TableInfo, 0, len(names))
+	for _, name := range names {
+		t := &TableInfo{Name: name, View: views[name]}
+		if t.Columns, err = s.columns(name); err != nil {
+			return nil, err
+		}
+		if t.ForeignKeys, err = s.foreignKeys(name); err != nil {
+			return nil, err
+		}
+		if t.Indexes, err = s.indexes(name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+func (s *Store) tableNames() ([]string, map[string]bool, error) {
+	rows, err := s.db.Query(`
+		SELECT name, type = 'view' FROM pragma_table_list
+		WHERE schema = 'main' AND type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'
+		ORDER BY type = 'view', name
+	`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list tables: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var names []string
+	views := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		var view bool
+		if err := rows.Scan(&name, &view); err != nil {
+			return nil, nil, fmt.Errorf("scan table: %w", err)
+		}
+		names = append(names, name)
+		views[name] = view
+	}
+	return names, views, rows.Err()
+}
+
+func (s *Store) columns(table string) ([]ColumnInfo, error) {
+	// table_xinfo includes generated columns (hidden 2 or 3) but also the
+	// hidden columns of virtual tables (1), which aren't queried by name
+	rows, err := s.db.Query(`SELECT name, type, "notnull", pk FROM pragma_table_xinfo(?) WHERE hidden != 1 ORDER BY cid`, table)
+	if err != nil {
+		return nil, fmt.Errorf("columns of %s: %w", table, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var cols []ColumnInfo
+	for rows.Next() {
+		var c ColumnInfo
+		var pk int
+		if err := rows.Scan(&c.Name, &c.Type, &c.NotNull, &pk); err != nil {
+			return nil, fmt.Errorf("scan column of %s: %w", table, err)
+		}
+		c.PrimaryKey = pk > 0
+		cols = append(cols, c)
+	}
+	return cols, rows.Err()
+}
+
+func (s *Store) foreignKeys(table string) ([]ForeignKeyInfo, error) {
+	rows, err := s.db.Query(`SELECT "from", "table", "to", on_delete FROM pragma_foreign_key_list(?) ORDER BY id, seq`, table)
+	if err != nil {
+		return nil, fmt.Errorf("foreign keys of %s: %w", table, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var fks []ForeignKeyInfo
+	for rows.Next() {
+		var fk ForeignKeyInfo
+		var to sql.NullString // NULL when the parent's primary key is implied
+		if err := rows.Scan(&fk.From, &fk.Table, &to, &fk.OnDelete); err != nil {
+			return nil, fmt.Errorf("scan foreign key of %s: %w", table, err)
+		}
+		fk.To = to.String
+		fks = append(fks, fk)
+	}
+	return fks, rows.Err()
+}
+
+func (s *Store) indexes(table string) ([]IndexInfo, error) {
+	rows, err := s.db.Query(`SELECT name, "unique" FROM pragma_index_list(?) WHERE origin != 'pk' ORDER BY name`, table)
+	if err != nil {
+		return nil, fmt.Errorf("indexes of %s: %w", table, err)
+	}
+	var idxs []IndexInfo
+	for rows.Next() {
+		var idx IndexInfo
+		if err := rows.Scan(&idx.Name, &idx.Unique); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("scan index of %s: %w", table, err)
+		}
+		idxs = append(idxs, idx)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("indexes of %s: %w", table, err)
+	}
+
+	for i := range idxs {
+		if idxs[i].Columns, err = s.indexColumns(idxs[i].Name); err != nil {
+			return nil, err
+		}
+	}
+	return idxs, nil
+}
+
+func (s *Store) indexColumns(index string) ([]string, error) {
+	rows, err := s.db.Query(`SELECT name FROM pragma_index_info(?) ORDER BY seqno`, index)
+	if err != nil {
+		return nil, fmt.Errorf("columns of index %s: %w", index, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var cols []string
+	for rows.Next() {
+		var name sql.NullString // NULL for an expression
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan column of index %s: %w", index, err)
+		}
+		if !name.Valid {
+			name.String = "<expr>"
+		}
+		cols = append(cols, name.String)
+	}
+	return cols, rows.Err()
+}
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Job statuses.
+const (
+	JobRunning     = "running"
+	JobCompleted   = "completed"
+	JobFailed      = "failed"
+	JobInterrupted = "interrupted" // Stopped, or its process died; can be resumed
+	JobResumed     = "resumed"     // Continued by a later job
+)
+
+// ErrJobNotFound is returned for job IDs that don't exist.
+var ErrJobNotFound = errors.New("job not found")
+
+// Job is a long-running operation recorded in the jobs table.
+type Job struct {
+	ID          int64
+	Kind        string // sync, enrich or expand
+	Description string
+	Params      map[string]string // What the job is resumed with
+	Status      string
+	Done        int64
+	Total       sql.NullInt64
+	PID         int
+	ResumedFrom sql.NullInt64
+	Error       string
+	StartedAt   time.Time
+	UpdatedAt   time.Time
+	FinishedAt  sql.NullTime
+}
+
+// StartJob records a job started by this process. A job resuming another
+// (resumedFrom > 0) carries on its count of items done, and the other is
+// marked resumed.
+func (s *Store) StartJob(kind, description string, params map[string]string, resumedFrom int64) (*Job, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("encode job params: %w", err)
+	}
+	now := time.Now()
+	job := &Job{Kind: kind, Description: description, Params: params, Status: JobRunning, PID: os.Getpid(), StartedAt: now, UpdatedAt: now}
+	err = s.InTx(func(tx *Tx) error {
+		if resumedFrom > 0 {
+			if err := tx.tx.QueryRow(`SELECT done FROM jobs WHERE id = ?`, resumedFrom).Scan(&job.Done); err != nil {
+				return fmt.Errorf("get resumed job: %w", err)
+			}
+			job.ResumedFrom = sql.NullInt64{Int64: resumedFrom, Valid: true}
+			if _, err := tx.tx.Exec(`UPDATE jobs SET status = ?, updated_at = ? WHERE id = ?`, JobResumed, now, resumedFrom); err != nil {
+				return fmt.Errorf("mark job resumed: %w", err)
+			}
+		}
+		res, err := tx.tx.Exec(`
+			INSERT INTO jobs (kind, description, params, status, done, pid, resumed_from, started_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, kind, description, string(data), JobRunning, job.Done, job.PID, job.ResumedFrom, now, now)
+		if err != nil {
+			return fmt.Errorf("create job: %w", err)
+		}
+		job.ID, err = res.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// UpdateJobProgress records a running job's items done and, if total is
+// non-negative, the items expected.
+func (s *Store) UpdateJobProgress(id, done, total int64) error {
+	var err error
+	if total >= 0 {
+		_, err = s.db.Exec(`UPDATE jobs SET done = ?, total = ?, updated_at = ? WHERE id = ?`, done, total, time.Now(), id)
+	} else {
+		_, err = s.db.Exec(`UPDATE jobs SET done = ?, updated_at = ? WHERE id = ?`, done, time.Now(), id)
+	}
+	if err != nil {
+		return fmt.Errorf("update job progress: %w", err)
+	}
+	return nil
+}
+
+// FinishJob records how a job ended, with its final count and the error it
+// failed or was interrupted with, if any.
+func (s *Store) FinishJob(id int64, status string, done int64, jobErr error) error {
+	var message interface{}
+	if jobErr != nil {
+		message = jobErr.Error()
+	}
+	now := time.Now()
+	_, err := s.db.Exec(`
+		UPDATE jobs SET status = ?, done = ?, error_message = ?, updated_at = ?, finished_at = ? WHERE id = ?
+	`, status, done, message, now, now, id)
+	if err != nil {
+		return fmt.Errorf("finish job: %w", err)
+	}
+	return nil
+}
+
+// MarkDeadJobs marks the running jobs whose process is gone - killed, or
+// the machine restarted - as interrupted, and returns how many there were.
+// alive reports whether a process ID is running.
+func (s *Store) MarkDeadJobs(alive func(pid int) bool) (int, error) {
+	jobs, err := s.queryJobs(`WHERE status = ?`, JobRunning)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, job := range jobs {
+		if job.PID > 0 && alive(job.PID) {
+			continue
+		}
+		_, err := s.db.Exec(`
+			UPDATE jobs SET status = ?, error_message = 'process exited while the job was running', finished_at = updated_at
+			WHERE id = ? AND status = ?
+		`, JobInterrupted, job.ID, JobRunning)
+		if err != nil {
+			return n, fmt.Errorf("mark job interrupted: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// ListJobs returns the limit most recent jobs (all if limit is 0), newest
+// first.
+func (s *Store) ListJobs(limit int) ([]*Job, error) {
+	where := `ORDER BY id DESC`
+	var args []interface{}
+	if limit > 0 {
+		where += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	return s.queryJobs(where, args...)
+}
+
+// GetJob returns a job by ID.
+func (s *Store) GetJob(id int64) (*Job, error) {
+	jobs, err := s.queryJobs(`WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("job %d: %w", id, ErrJobNotFound)
+	}
+	return jobs[0], nil
+}
+
+func (s *Store) queryJobs(where string, args ...interface{}) ([]*Job, error) {
+	rows, err := s.db.Query(`
+		SELECT id, kind, description, params, status, done, total, COALESCE(pid, 0), resumed_from,
+		       COALESCE(error_message, ''), started_at, updated_at, finished_at
+		FROM jobs `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query jobs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var jobs []*Job
+	for rows.Next() {
+		job := &Job{}
+		var params string
+		err := rows.Scan(&job.ID, &job.Kind, &job.Description, &params, &job.Status, &job.Done, &job.Total, &job.PID,
+			&job.ResumedFrom, &job.Error, &job.StartedAt, &job.UpdatedAt, &job.FinishedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan job: %w", err)
+		}
+		if err := json.Unmarshal([]byte(params), &job.Params); err != nil {
+			return nil, fmt.Errorf("decode params of job %d: %w", job.ID, err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
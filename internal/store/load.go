@@ -0,0 +1,202 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// LoadPeriod is the meeting load of one week or month.
+type LoadPeriod struct {
+	Start        time.Time // First day of the period
+	Meetings     int
+	Hours        float64 // Total meeting time
+	WorkingHours float64 // Working time in the period, within the report's range
+	BusyHours    float64 // Working time spent in meetings, overlaps counted once
+}
+
+// AverageMinutes returns the average meeting duration.
+func (p *LoadPeriod) AverageMinutes() float64 {
+	if p.Meetings == 0 {
+		return 0
+	}
+	return p.Hours * 60 / float64(p.Meetings)
+}
+
+// BusyShare returns the share (0-1) of working time spent in meetings.
+func (p *LoadPeriod) BusyShare() float64 {
+	if p.WorkingHours == 0 {
+		return 0
+	}
+	return p.BusyHours / p.WorkingHours
+}
+
+// GetLoadReport sums meetings (as counted by the analytics tables) starting
+// in [since, until) into periods, where period maps a day to the first day
+// of its week or month. Every period in the range is returned, oldest
+// first. Days are in since's location; a meeting held in several accounts
+// counts once.
+func (s *Store) GetLoadReport(since, until time.Time, hours WorkingHours, period func(day time.Time) time.Time) ([]*LoadPeriod, error) {
+	inRange, inRangeArgs := startsIn("e", since, until)
+	rows, err := s.db.Query(`
+		SELECT e.id, COALESCE(e.ical_uid, ''), e.start_time, e.end_time
+		FROM events e
+		WHERE `+inRange+`
+		  AND `+meetingCondition+`
+		ORDER BY e.start_time
+	`, inRangeArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("query meetings: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	loc := since.Location()
+	periods := map[time.Time]*LoadPeriod{}
+	var ordered []*LoadPeriod
+	periodOf := func(t time.Time) *LoadPeriod {
+		y, m, d := t.In(loc).Date()
+		start := period(time.Date(y, m, d, 0, 0, 0, 0, loc))
+		p := periods[start]
+		if p == nil {
+			p = &LoadPeriod{Start: start}
+			periods[start] = p
+			ordered = append(ordered, p)
+		}
+		return p
+	}
+
+	// Every day of the range adds its working time
+	y, m, d := since.Date()
+	for day := time.Date(y, m, d, 0, 0, 0, 0, loc); day.Before(until); day = day.AddDate(0, 0, 1) {
+		from, to := day, day.AddDate(0, 0, 1)
+		if from.Before(since) {
+			from = since
+		}
+		if to.After(until) {
+			to = until
+		}
+		periodOf(day).WorkingHours += hours.Within(from, to).Hours()
+	}
+
+	type interval struct{ start, end time.Time }
+	var meetings []interval
+	seen := map[string]bool{}
+	for rows.Next() {
+		var id int64
+		var icalUID string
+		var start, end time.Time
+		if err := rows.Scan(&id, &icalUID, &start, &end); err != nil {
+			return nil, fmt.Errorf("scan meeting: %w", err)
+		}
+		key := meetingKey(id, icalUID, start)
+		if seen[key] || !end.After(start) {
+			continue
+		}
+		seen[key] = true
+		start, end = start.In(loc), end.In(loc)
+		p := periodOf(start)
+		p.Meetings++
+		p.Hours += end.Sub(start).Hours()
+		meetings = append(meetings, interval{start, end})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query meetings: %w", err)
+	}
+
+	// Busy time is the union of meetings, split by day into periods
+	var busy []interval
+	for _, mt := range meetings {
+		if n := len(busy); n > 0 && !mt.start.After(busy[n-1].end) {
+			if mt.end.After(busy[n-1].end) {
+				busy[n-1].end = mt.end
+			}
+			continue
+		}
+		busy = append(busy, mt)
+	}
+	for _, b := range busy {
+		y, m, d := b.start.Date()
+		for day := time.Date(y, m, d, 0, 0, 0, 0, loc); day.Before(b.end); day = day.AddDate(0, 0, 1) {
+			from, to := day, day.AddDate(0, 0, 1)
+			if from.Before(b.start) {
+				from = b.start
+			}
+			if to.After(b.end) {
+				to = b.end
+			}
+			periodOf(day).BusyHours += hours.Within(from, to).Hours()
+		}
+	}
+
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Start.Before(ordered[j].Start) })
+	return ordered, nil
+}
+
+// MeetingRange returns the start of the first and last meeting (as counted
+// by the analytics tables), or zero times if there are none.
+func (s *Store) MeetingRange() (first, last time.Time, err error) {
+	var firstStr, lastStr sql.NullString
+	err = s.db.QueryRow(`
+		SELECT MIN(e.start_time), MAX(e.start_time) FROM events e WHERE `+meetingCondition,
+	).Scan(&firstStr, &lastStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("query meeting range: %w", err)
+	}
+	if !firstStr.Valid {
+		return time.Time{}, time.Time{}, nil
+	}
+	if first, err = parseTimestamp(firstStr.String); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if last, err = parseTimestamp(lastStr.String); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return first, last, nil
+}
+
+// MeetingRhythm counts meetings by the weekday and hour they start.
+type MeetingRhythm struct {
+	Weekdays [7]int  // Indexed by time.Weekday
+	Hours    [24]int // Indexed by hour of day
+}
+
+// GetMeetingRhythm counts meetings (as counted by the analytics tables)
+// starting in [since, until) by weekday and hour in since's location. A
+// meeting held in several accounts counts once.
+func (s *Store) GetMeetingRhythm(since, until time.Time) (*MeetingRhythm, error) {
+	inRange, inRangeArgs := startsIn("e", since, until)
+	rows, err := s.db.Query(`
+		SELECT e.id, COALESCE(e.ical_uid, ''), e.start_time
+		FROM events e
+		WHERE `+inRange+`
+		  AND `+meetingCondition+`
+	`, inRangeArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("query meetings: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	rhythm := &MeetingRhythm{}
+	seen := map[string]bool{}
+	for rows.Next() {
+		var id int64
+		var icalUID string
+		var start time.Time
+		if err := rows.Scan(&id, &icalUID, &start); err != nil {
+			return nil, fmt.Errorf("scan meeting: %w", err)
+		}
+		key := meetingKey(id, icalUID, start)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		start = start.In(since.Location())
+		rhythm.Weekdays[start.Weekday()]++
+		rhythm.Hours[start.Hour()]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query meetings: %w", err)
+	}
+	return rhythm, nil
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// MaintainOptions select the steps Maintain runs.
+type MaintainOptions struct {
+	Vacuum bool // Rewrite the database without its free pages
+}
+
+// Maintenance describes what Maintain did.
+type Maintenance struct {
+	SizeBefore int64 // Database and write-ahead log, in bytes
+	SizeAfter  int64
+	FreePages  int64 // Unused pages in the database before it ran
+	PageSize   int64
+	Vacuumed   bool
+	Steps      []MaintenanceStep
+}
+
+// MaintenanceStep is one step Maintain ran.
+type MaintenanceStep struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Reclaimed returns the bytes of disk space Maintain freed.
+func (m *Maintenance) Reclaimed() int64 {
+	return m.SizeBefore - m.SizeAfter
+}
+
+// Maintain tidies the database at path, which s has open: it optimizes
+// the search index, updates the query planner's statistics with ANALYZE,
+// rewrites the database with VACUUM if opts.Vacuum is set (the file only
+// shrinks this way; deleted rows leave free pages behind), and moves the
+// write-ahead log into the database and truncates it.
+//
+// VACUUM holds the write lock while it copies the whole database and
+// needs about as much free disk space as the database takes; the caller
+// keeps syncs from running meanwhile.
+func (s *Store) Maintain(path string, opts MaintainOptions) (*Maintenance, error) {
+	m := &Maintenance{Vacuumed: opts.Vacuum}
+	var err error
+	if m.SizeBefore, err = databaseSize(path); err != nil {
+		return nil, err
+	}
+	if err := s.db.QueryRow(`PRAGMA freelist_count`).Scan(&m.FreePages); err != nil {
+		return nil, fmt.Errorf("count free pages: %w", err)
+	}
+	if err := s.db.QueryRow(`PRAGMA page_size`).Scan(&m.PageSize); err != nil {
+		return nil, fmt.Errorf("get page size: %w", err)
+	}
+
+	step := func(name, stmt string) error {
+		start := time.Now()
+		if _, err := s.db.Exec(stmt); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		m.Steps = append(m.Steps, MaintenanceStep{Name: name, Duration: time.Since(start)})
+		return nil
+	}
+
+	hasIndex, err := s.SearchIndexAvailable()
+	if err != nil {
+		return nil, err
+	}
+	if hasIndex {
+		// Merges the index's segments, which pile up as events change
+		if err := step("optimize search index", `INSERT INTO events_fts (events_fts) VALUES ('optimize')`); err != nil {
+			return nil, err
+		}
+	}
+	if err := step("analyze", `ANALYZE`); err != nil {
+		return nil, err
+	}
+	if opts.Vacuum {
+		if err := step("vacuum", `VACUUM`); err != nil {
+			return nil, err
+		}
+	}
+	// Last, as in WAL mode VACUUM writes the rewritten database to the log
+	if err := step("checkpoint", `PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return nil, err
+	}
+
+	if m.SizeAfter, err = databaseSize(path); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// databaseSize returns the size of the database at path and its
+// write-ahead log.
+func databaseSize(path string) (int64, error) {
+	var total int64
+	for _, p := range []string{path, path + "-wal"} {
+		info, err := os.Stat(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("database size: %w", err)
+		}
+		total += info.Size()
+	}
+	return total, nil
+}
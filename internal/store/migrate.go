@@ -0,0 +1,357 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// columnMigrations adds columns introduced after a table was first created.
+// CREATE TABLE IF NOT EXISTS in schema.sql only covers new databases.
+var columnMigrations = []struct {
+	table, column, decl string
+}{
+	{"calendars", "access_role", "TEXT"},
+	{"calendars", "subscription_kind", "TEXT"},
+	{"calendars", "page_token", "TEXT"},
+	{"calendars", "private", "BOOLEAN DEFAULT FALSE"},
+	{"sources", "owner", "TEXT"},
+	{"sources", "calendar_list_token", "TEXT"},
+	{"events", "ical_uid", "TEXT"},
+	{"events", "color_id", "TEXT"},
+	{"calendars", "color_id", "TEXT"},
+	{"calendars", "background_color", "TEXT"},
+	{"events", "quality", "TEXT"},
+	{"events", "reminders_default", "BOOLEAN"},
+	{"attendees", "canonical_email", "TEXT"},
+	{"sync_runs", "kind", "TEXT"},
+	{"events", "duration_minutes", derivedColumns["duration_minutes"]},
+	{"events", "start_date", derivedColumns["start_date"]},
+	{"events", "start_hour", derivedColumns["start_hour"]},
+	{"events", "weekday", derivedColumns["weekday"]},
+}
+
+// derivedColumns are the generated columns of events, as declared in
+// schema.sql. SQLite computes them from the stored times when read, and
+// indexes on them store the values, so analytics can group and filter by
+// day, hour or weekday without recomputing them per query. ALTER TABLE can
+// only add VIRTUAL generated columns.
+var derivedColumns = map[string]string{
+	"duration_minutes": `REAL GENERATED ALWAYS AS ((strftime('%s', end_time) - strftime('%s', start_time)) / 60.0) VIRTUAL`,
+	"start_date":       `TEXT GENERATED ALWAYS AS (substr(start_time, 1, 10)) VIRTUAL`,
+	"start_hour":       `INTEGER GENERATED ALWAYS AS (CASE WHEN all_day THEN NULL ELSE CAST(substr(start_time, 12, 2) AS INTEGER) END) VIRTUAL`,
+	"weekday":          `INTEGER GENERATED ALWAYS AS (CAST(strftime('%w', substr(start_time, 1, 10)) AS INTEGER)) VIRTUAL`,
+}
+
+// indexMigrations index columns added by columnMigrations, and add unique
+// indexes existing rows may violate until dedupAttendees ran. They can't be
+// in schema.sql, which runs before either on older databases.
+var indexMigrations = []string{
+	`CREATE INDEX IF NOT EXISTS idx_events_ical_uid ON events(ical_uid)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_attendees_event_email ON attendees(event_id, lower(email))`,
+	`CREATE INDEX IF NOT EXISTS idx_events_start_date ON events(start_date)`,
+	`CREATE INDEX IF NOT EXISTS idx_events_weekday_hour ON events(weekday, start_hour)`,
+	`CREATE INDEX IF NOT EXISTS idx_attendees_canonical_email ON attendees(canonical_email)`,
+}
+
+// foreignKeyMigrations gives existing foreign keys the ON DELETE action
+// schema.sql now declares for them. SQLite can't alter a constraint, so the
+// table is rebuilt from its schema.sql definition.
+var foreignKeyMigrations = []struct {
+	table, column, onDelete string
+}{
+	{"events", "calendar_id", "CASCADE"},
+	{"sync_runs", "calendar_id", "SET NULL"},
+	{"watch_channels", "calendar_id", "CASCADE"},
+}
+
+// migrate applies columnMigrations, foreignKeyMigrations and
+// indexMigrations to an existing database, and creates the views.
+func (s *Store) migrate() error {
+	added := make(map[string]bool)
+	for _, m := range columnMigrations {
+		var n int
+		// table_info leaves out generated columns; table_xinfo lists them
+		err := s.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_xinfo(?) WHERE name = ?`, m.table, m.column).Scan(&n)
+		if err != nil {
+			return fmt.Errorf("check %s.%s: %w", m.table, m.column, err)
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := s.db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, m.table, m.column, m.decl)); err != nil {
+			return fmt.Errorf("add %s.%s: %w", m.table, m.column, err)
+		}
+		added[m.table+"."+m.column] = true
+	}
+	// Events stored before quality flags get theirs now; later writes set them
+	if added["events.quality"] {
+		if err := s.recheckEventQuality(); err != nil {
+			return err
+		}
+	}
+	// People analytics group attendees by canonical_email from now on
+	if added["attendees.canonical_email"] {
+		if err := s.migrateCanonicalEmails(); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range foreignKeyMigrations {
+		var onDelete string
+		err := s.db.QueryRow(`SELECT on_delete FROM pragma_foreign_key_list(?) WHERE "from" = ?`, m.table, m.column).Scan(&onDelete)
+		if err != nil {
+			return fmt.Errorf("check %s.%s foreign key: %w", m.table, m.column, err)
+		}
+		if onDelete == m.onDelete {
+			continue
+		}
+		if err := s.rebuildTable(m.table); err != nil {
+			return err
+		}
+	}
+
+	if err := s.migrateEntityExtractions(); err != nil {
+		return err
+	}
+
+	// Rebuilding a table drops its indexes and triggers, so these come last
+	if err := s.dedupAttendees(); err != nil {
+		return err
+	}
+	for _, stmt := range indexMigrations {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return fmt.Errorf("create index: %w", err)
+		}
+	}
+	if err := s.initViews(); err != nil {
+		return err
+	}
+	return s.initVersionTriggers()
+}
+
+// personTriggers are the analytics triggers that key people by
+// canonical_email; before it they keyed them by lower(email).
+var personTriggers = []string{
+	"trg_events_analytics_update", "trg_events_analytics_delete",
+	"trg_attendees_analytics_insert", "trg_attendees_analytics_delete",
+}
+
+// migrateCanonicalEmails fills in canonical_email for attendees stored
+// before it, recreates the analytics triggers to key people by it, and
+// rebuilds person_meeting_counts, which was keyed by lowercased email.
+func (s *Store) migrateCanonicalEmails() error {
+	emails := map[int64]string{}
+	rows, err := s.db.Query(`SELECT id, email FROM attendees`)
+	if err != nil {
+		return fmt.Errorf("read attendee emails: %w", err)
+	}
+	for rows.Next() {
+		var id int64
+		var email string
+		if err := rows.Scan(&id, &email); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("read attendee emails: %w", err)
+		}
+		emails[id] = CanonicalEmail(email)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("read attendee emails: %w", err)
+	}
+
+	err = s.InTx(func(tx *Tx) error {
+		update, err := tx.tx.Prepare(`UPDATE attendees SET canonical_email = ? WHERE id = ?`)
+		if err != nil {
+			return fmt.Errorf("set canonical emails: %w", err)
+		}
+		defer func() { _ = update.Close() }()
+		for id, email := range emails {
+			if _, err := update.Exec(email, id); err != nil {
+				return fmt.Errorf("set canonical emails: %w", err)
+			}
+		}
+		for _, name := range personTriggers {
+			if _, err := tx.tx.Exec(`DROP TRIGGER IF EXISTS ` + name); err != nil {
+				return fmt.Errorf("drop trigger %s: %w", name, err)
+			}
+		}
+		if _, err := tx.tx.Exec(schema); err != nil {
+			return fmt.Errorf("recreate analytics triggers: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := s.RebuildAnalyticsTables(); err != nil {
+		return fmt.Errorf("rebuild analytics tables: %w", err)
+	}
+	return nil
+}
+
+// migrateEntityExtractions moves the processed events recorded in
+// entity_extractions, from before enrichment stages shared enrich_state,
+// into enrich_state as the entities:<extractor> stages, and drops it.
+func (s *Store) migrateEntityExtractions() error {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'entity_extractions'`).Scan(&n)
+	if err != nil {
+		return fmt.Errorf("check entity_extractions: %w", err)
+	}
+	if n == 0 {
+		return nil
+	}
+	return s.InTx(func(tx *Tx) error {
+		_, err := tx.tx.Exec(`
+			INSERT OR IGNORE INTO enrich_state (event_id, stage, enriched_at)
+			SELECT event_id, 'entities:' || extractor, extracted_at FROM entity_extractions
+		`)
+		if err != nil {
+			return fmt.Errorf("move entity_extractions: %w", err)
+		}
+		if _, err := tx.tx.Exec(`DROP TABLE entity_extractions`); err != nil {
+			return fmt.Errorf("drop entity_extractions: %w", err)
+		}
+		return nil
+	})
+}
+
+// dedupAttendees merges attendees listed more than once on an event, with
+// emails differing only in case, into the row stored first, once: before
+// idx_attendees_event_email exists to prevent them. The merged row keeps any
+// organizer or self flag, the latest response other than needsAction, and
+// the latest display name.
+func (s *Store) dedupAttendees() error {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_attendees_event_email'`).Scan(&n)
+	if err != nil {
+		return fmt.Errorf("check attendee index: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	return s.InTx(func(tx *Tx) error {
+		if _, err := tx.tx.Exec(`
+			UPDATE attendees AS a SET
+				is_organizer = (SELECT MAX(COALESCE(d.is_organizer, FALSE)) FROM attendees d
+				                WHERE d.event_id = a.event_id AND lower(d.email) = lower(a.email)),
+				is_self = (SELECT MAX(COALESCE(d.is_self, FALSE)) FROM attendees d
+				           WHERE d.event_id = a.event_id AND lower(d.email) = lower(a.email)),
+				response_status = (SELECT d.response_status FROM attendees d
+				                   WHERE d.event_id = a.event_id AND lower(d.email) = lower(a.email)
+				                   ORDER BY COALESCE(d.response_status, 'needsAction') = 'needsAction', d.id DESC LIMIT 1),
+				display_name = (SELECT d.display_name FROM attendees d
+				                WHERE d.event_id = a.event_id AND lower(d.email) = lower(a.email)
+				                ORDER BY COALESCE(d.display_name, '') = '', d.id DESC LIMIT 1)
+			WHERE a.id IN (SELECT MIN(id) FROM attendees GROUP BY event_id, lower(email) HAVING COUNT(*) > 1)
+		`); err != nil {
+			return fmt.Errorf("merge duplicate attendees: %w", err)
+		}
+		if _, err := tx.tx.Exec(`
+			DELETE FROM attendees
+			WHERE id NOT IN (SELECT MIN(id) FROM attendees GROUP BY event_id, lower(email))
+		`); err != nil {
+			return fmt.Errorf("delete duplicate attendees: %w", err)
+		}
+		return nil
+	})
+}
+
+// rebuildTable recreates a table from its schema.sql definition, keeping its
+// rows, following SQLite's procedure for schema changes ALTER TABLE can't
+// make. Orphans that would violate the new constraints are cleaned up first.
+// Indexes and triggers dropped with the old table are recreated by
+// re-applying the schema; the search index notices its triggers are gone and
+// rebuilds itself.
+func (s *Store) rebuildTable(table string) error {
+	def, err := tableDefinition(table)
+	if err != nil {
+		return err
+	}
+	tmp := table + "_rebuild"
+	create := strings.Replace(def, "CREATE TABLE IF NOT EXISTS "+table+" (", "CREATE TABLE "+tmp+" (", 1)
+
+	// Foreign keys must be off while the old table is dropped, and triggers
+	// on other tables that mention it must not be checked while it's
+	// missing. Both pragmas are per connection, so pin one.
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("rebuild %s: %w", table, err)
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF; PRAGMA legacy_alter_table = ON`); err != nil {
+		return fmt.Errorf("rebuild %s: %w", table, err)
+	}
+	defer func() { _, _ = conn.ExecContext(ctx, `PRAGMA foreign_keys = ON; PRAGMA legacy_alter_table = OFF`) }()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("rebuild %s: %w", table, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Generated columns aren't listed, nor copied: the new table computes them
+	var columns []string
+	rows, err := tx.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return fmt.Errorf("rebuild %s: list columns: %w", table, err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("rebuild %s: list columns: %w", table, err)
+		}
+		columns = append(columns, name)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rebuild %s: list columns: %w", table, err)
+	}
+	cols := strings.Join(columns, ", ")
+
+	if _, err := cleanupOrphans(tx); err != nil {
+		return fmt.Errorf("rebuild %s: %w", table, err)
+	}
+	steps := []string{
+		create,
+		fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s FROM %s`, tmp, cols, cols, table),
+		fmt.Sprintf(`DROP TABLE %s`, table),
+		fmt.Sprintf(`ALTER TABLE %s RENAME TO %s`, tmp, table),
+		schema,
+	}
+	for _, step := range steps {
+		if _, err := tx.Exec(step); err != nil {
+			return fmt.Errorf("rebuild %s: %w", table, err)
+		}
+	}
+
+	var violations int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_foreign_key_check(?)`, table).Scan(&violations); err != nil {
+		return fmt.Errorf("rebuild %s: check foreign keys: %w", table, err)
+	}
+	if violations > 0 {
+		return fmt.Errorf("rebuild %s: %d foreign key violations", table, violations)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("rebuild %s: commit: %w", table, err)
+	}
+	return nil
+}
+
+// tableDefinition returns the CREATE TABLE statement for table in schema.sql.
+func tableDefinition(table string) (string, error) {
+	start := strings.Index(schema, "CREATE TABLE IF NOT EXISTS "+table+" (")
+	if start < 0 {
+		return "", fmt.Errorf("table %s not in schema", table)
+	}
+	end := strings.Index(schema[start:], "\n);")
+	if end < 0 {
+		return "", fmt.Errorf("table %s: unterminated definition", table)
+	}
+	return schema[start : start+end+len("\n);")], nil
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// EventNote links a meeting note file to an event.
+type EventNote struct {
+	ID         int64
+	EventID    int64
+	Path       string       // Absolute
+	Occurrence sql.NullTime // Start of the occurrence, for a note about one occurrence of a series
+	Matched    bool         // Matched by file name rather than linked by hand
+	LinkedAt   time.Time
+}
+
+// LinkNote links a note to an event, reporting false if it already was.
+// An existing link is left as it is.
+func (s *Store) LinkNote(n *EventNote) (bool, error) {
+	res, err := s.db.Exec(`
+		INSERT INTO event_notes (event_id, path, occurrence, matched, linked_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(event_id, path) DO NOTHING
+	`, n.EventID, n.Path, n.Occurrence, n.Matched, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("link note: %w", err)
+	}
+	created, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("link note: %w", err)
+	}
+	return created > 0, nil
+}
+
+// UnlinkNote removes a note's link to an event, reporting false if there
+// was none.
+func (s *Store) UnlinkNote(eventID int64, path string) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM event_notes WHERE event_id = ? AND path = ?`, eventID, path)
+	if err != nil {
+		return false, fmt.Errorf("unlink note: %w", err)
+	}
+	removed, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("unlink note: %w", err)
+	}
+	return removed > 0, nil
+}
+
+// ListNotes returns the notes linked to an event, by occurrence and path.
+func (s *Store) ListNotes(eventID int64) ([]*EventNote, error) {
+	rows, err := s.db.Query(`
+		SELECT id, event_id, path, occurrence, matched, linked_at
+		FROM event_notes WHERE event_id = ?
+		ORDER BY occurrence, path
+	`, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("query notes: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var notes []*EventNote
+	for rows.Next() {
+		var n EventNote
+		if err := rows.Scan(&n.ID, &n.EventID, &n.Path, &n.Occurrence, &n.Matched, &n.LinkedAt); err != nil {
+			return nil, fmt.Errorf("scan note: %w", err)
+		}
+		notes = append(notes, &n)
+	}
+	return notes, rows.Err()
+}
+
+// NoteLinked reports whether a note is linked to any event.
+func (s *Store) NoteLinked(path string) (bool, error) {
+	var linked bool
+	if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM event_notes WHERE path = ?)`, path).Scan(&linked); err != nil {
+		return false, fmt.Errorf("check note link: %w", err)
+	}
+	return linked, nil
+}
+
+// NoteCandidate is an event, or one occurrence of a recurring series, a
+// note could be about.
+type NoteCandidate struct {
+	EventID    int64
+	Summary    string
+	Start      time.Time
+	Occurrence bool // Start is an occurrence of the series EventID
+}
+
+// NoteCandidates returns the events that weren't cancelled starting in
+// [since, until), with recurring series expanded from event_instances, by
+// start time.
+func (s *Store) NoteCandidates(since, until time.Time) ([]*NoteCandidate, error) {
+	inRange, args := startsIn("", since, until)
+	instancesInRange, instanceArgs := startsIn("i", since, until)
+	rows, err := s.db.Query(`
+		SELECT id, COALESCE(summary, ''), start_time, FALSE
+		FROM events
+		WHERE `+inRange+`
+		  AND COALESCE(recurrence_rule, '') = ''
+		  AND COALESCE(status, '') != 'cancelled'
+		UNION ALL
+		SELECT e.id, COALESCE(e.summary, ''), i.start_time, TRUE
+		FROM event_instances i
+		JOIN events e ON e.id = i.event_id
+		WHERE i.expanded AND `+instancesInRange+`
+		  AND COALESCE(e.status, '') != 'cancelled'
+		ORDER BY 3, 1
+	`, append(args, instanceArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("query note candidates: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var candidates []*NoteCandidate
+	for rows.Next() {
+		var c NoteCandidate
+		if err := rows.Scan(&c.EventID, &c.Summary, &c.Start, &c.Occurrence); err != nil {
+			return nil, fmt.Errorf("scan note candidate: %w", err)
+		}
+		candidates = append(candidates, &c)
+	}
+	return candidates, rows.Err()
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// OffHoursTally counts meetings outside working hours for one month,
+// organizer or time zone.
+type OffHoursTally struct {
+	Key      string  // YYYY-MM, organizer email or time zone; "" if unknown
+	Meetings int     // All meetings
+	OffHours int     // On working days, at least partly outside working hours
+	Weekend  int     // On days off
+	Hours    float64 // Time spent in meetings outside working hours
+}
+
+// Outside returns the number of meetings on days off or outside hours.
+func (t *OffHoursTally) Outside() int { return t.OffHours + t.Weekend }
+
+// OffHoursReport breaks down meetings outside working hours.
+type OffHoursReport struct {
+	Total      OffHoursTally
+	Months     []*OffHoursTally // Every month with meetings, oldest first
+	Organizers []*OffHoursTally // Organizers of off-hours meetings, most first
+	Timezones  []*OffHoursTally // Event time zones of off-hours meetings, most first
+}
+
+// GetOffHoursReport classifies meetings (as counted by the analytics tables)
+// starting in [since, until) against hours, in since's location. A meeting
+// on a day off counts as a weekend meeting; one on a working day that
+// starts early or ends late counts as off-hours. Events without a time zone
+// of their own are grouped under their calendar's.
+func (s *Store) GetOffHoursReport(since, until time.Time, hours WorkingHours) (*OffHoursReport, error) {
+	inRange, inRangeArgs := startsIn("e", since, until)
+	rows, err := s.db.Query(`
+		SELECT e.start_time, e.end_time, COALESCE(e.organizer_email, ''),
+		       COALESCE(NULLIF(e.original_timezone, ''), c.timezone, '')
+		FROM events e
+		JOIN calendars c ON c.id = e.calendar_id
+		WHERE `+inRange+`
+		  AND `+meetingCondition+`
+	`, inRangeArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("query meetings: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	report := &OffHoursReport{}
+	months := map[string]*OffHoursTally{}
+	organizers := map[string]*OffHoursTally{}
+	timezones := map[string]*OffHoursTally{}
+	tally := func(m map[string]*OffHoursTally, key string) *OffHoursTally {
+		t := m[key]
+		if t == nil {
+			t = &OffHoursTally{Key: key}
+			m[key] = t
+		}
+		return t
+	}
+
+	loc := since.Location()
+	for rows.Next() {
+		var start, end time.Time
+		var organizer, timezone string
+		if err := rows.Scan(&start, &end, &organizer, &timezone); err != nil {
+			return nil, fmt.Errorf("scan meeting: %w", err)
+		}
+		if !end.After(start) {
+			continue
+		}
+		start, end = start.In(loc), end.In(loc)
+
+		outside := end.Sub(start) - hours.Within(start, end)
+		weekend := !hours.Days[start.Weekday()]
+		groups := []*OffHoursTally{&report.Total, tally(months, start.Format("2006-01"))}
+		if weekend || outside > 0 {
+			groups = append(groups, tally(organizers, organizer), tally(timezones, timezone))
+		}
+		for _, t := range groups {
+			t.Meetings++
+			switch {
+			case weekend:
+				t.Weekend++
+			case outside > 0:
+				t.OffHours++
+			}
+			t.Hours += outside.Hours()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query meetings: %w", err)
+	}
+
+	report.Months = sortedTallies(months, func(a, b *OffHoursTally) bool { return a.Key < b.Key })
+	byOutside := func(a, b *OffHoursTally) bool {
+		if a.Outside() != b.Outside() {
+			return a.Outside() > b.Outside()
+		}
+		return a.Key < b.Key
+	}
+	report.Organizers = sortedTallies(organizers, byOutside)
+	report.Timezones = sortedTallies(timezones, byOutside)
+	return report, nil
+}
+
+func sortedTallies(m map[string]*OffHoursTally, less func(a, b *OffHoursTally) bool) []*OffHoursTally {
+	tallies := make([]*OffHoursTally, 0, len(m))
+	for _, t := range m {
+		tallies = append(tallies, t)
+	}
+	sort.Slice(tallies, func(i, j int) bool { return less(tallies[i], tallies[j]) })
+	return tallies
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// PersonTally sums the meetings shared with one attendee.
+type PersonTally struct {
+	Email     string // Canonical (see CanonicalEmail)
+	Name      string // The identity's name, or the most recent non-empty display name
+	Meetings  int
+	Hours     float64
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Months    []int // Meetings per month of the report, oldest first
+}
+
+// PeopleReport ranks the people met with most.
+type PeopleReport struct {
+	Months []string       // Every month (YYYY-MM) in the report's range
+	People []*PersonTally // Most meetings first
+}
+
+// GetPeopleReport tallies meetings (as counted by the analytics tables)
+// starting in [since, until) per attendee, by canonical email (so a
+// person's aliases in people count together), other than the account owner
+// and room or resource calendars. A meeting held in several accounts -
+// same iCalendar UID and start - counts once. Months are in since's location.
+func (s *Store) GetPeopleReport(since, until time.Time) (*PeopleReport, error) {
+	inRange, inRangeArgs := startsIn("e", since, until)
+	rows, err := s.db.Query(`
+		SELECT DISTINCT e.id, COALESCE(e.ical_uid, ''), e.start_time, e.end_time,
+		       a.canonical_email, COALESCE(p.name, a.display_name, '')
+		FROM events e
+		JOIN attendees a ON a.event_id = e.id
+		LEFT JOIN people p ON p.email = a.canonical_email
+		WHERE `+inRange+`
+		  AND a.is_self = FALSE
+		  AND a.email NOT LIKE '%@resource.calendar.google.com'
+		  AND `+meetingCondition+`
+		ORDER BY e.start_time
+	`, inRangeArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("query meetings: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	loc := since.Location()
+	report := &PeopleReport{}
+	month := map[string]int{}
+	for m := time.Date(since.Year(), since.Month(), 1, 0, 0, 0, 0, loc); m.Before(until); m = m.AddDate(0, 1, 0) {
+		month[m.Format("2006-01")] = len(report.Months)
+		report.Months = append(report.Months, m.Format("2006-01"))
+	}
+
+	people := map[string]*PersonTally{}
+	seen := map[string]bool{}
+	for rows.Next() {
+		var id int64
+		var icalUID, email, name string
+		var start, end time.Time
+		if err := rows.Scan(&id, &icalUID, &start, &end, &email, &name); err != nil {
+			return nil, fmt.Errorf("scan meeting: %w", err)
+		}
+
+		meeting := meetingKey(id, icalUID, start)
+		if seen[meeting+"|"+email] {
+			continue
+		}
+		seen[meeting+"|"+email] = true
+
+		p := people[email]
+		if p == nil {
+			p = &PersonTally{Email: email, FirstSeen: start, Months: make([]int, len(report.Months))}
+			people[email] = p
+		}
+		if name != "" {
+			p.Name = name
+		}
+		p.Meetings++
+		p.Hours += end.Sub(start).Hours()
+		p.LastSeen = start
+		if i, ok := month[start.In(loc).Format("2006-01")]; ok {
+			p.Months[i]++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query meetings: %w", err)
+	}
+
+	for _, p := range people {
+		report.People = append(report.People, p)
+	}
+	sort.Slice(report.People, func(i, j int) bool {
+		a, b := report.People[i], report.People[j]
+		if a.Meetings != b.Meetings {
+			return a.Meetings > b.Meetings
+		}
+		if a.Hours != b.Hours {
+			return a.Hours > b.Hours
+		}
+		return a.Email < b.Email
+	})
+	return report, nil
+}
+
+// meetingKey identifies a meeting across the accounts that hold it: by
+// iCalendar UID and start where known, else by event ID.
+func meetingKey(id int64, icalUID string, start time.Time) string {
+	if icalUID == "" {
+		return strconv.FormatInt(id, 10)
+	}
+	return icalUID + "|" + start.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// TakeAPIRequest counts one API request against day's usage if fewer than
+// limit (at least 1) have been made, reporting whether it was counted.
+func (s *Store) TakeAPIRequest(day string, limit int) (bool, error) {
+	result, err := s.db.Exec(`
+		INSERT INTO api_usage (day, requests) VALUES (?, 1)
+		ON CONFLICT(day) DO UPDATE SET requests = requests + 1 WHERE requests < ?
+	`, day, limit)
+	if err != nil {
+		return false, fmt.Errorf("take API request: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("take API request: %w", err)
+	}
+	return n == 1, nil
+}
+
+// AddAPIUsage counts n requests made on day regardless of any limit, such
+// as those of an estimate.
+func (s *Store) AddAPIUsage(day string, n int) error {
+	_, err := s.db.Exec(`
+		INSERT INTO api_usage (day, requests) VALUES (?, ?)
+		ON CONFLICT(day) DO UPDATE SET requests = requests + excluded.requests
+	`, day, n)
+	if err != nil {
+		return fmt.Errorf("add API usage: %w", err)
+	}
+	return nil
+}
+
+// APIUsage returns the number of API requests counted on day.
+func (s *Store) APIUsage(day string) (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COALESCE(SUM(requests), 0) FROM api_usage WHERE day = ?`, day).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("get API usage: %w", err)
+	}
+	return n, nil
+}
+
+// SyncPlanEntry is one calendar in an account's sync plan.
+type SyncPlanEntry struct {
+	GoogleCalendarID  string
+	Summary           string
+	EstimatedEvents   int
+	EstimatedRequests int
+	Requests          int  // Full sync requests made so far
+	Done              bool // The calendar has a sync token
+	InProgress        bool // A full sync was interrupted and will resume
+}
+
+// Remaining estimates the requests left to finish the calendar's full sync.
+func (e *SyncPlanEntry) Remaining() int {
+	if e.Done {
+		return 0
+	}
+	return max(e.EstimatedRequests-e.Requests, 1)
+}
+
+// ReplaceSyncPlan saves entries, in order, as the source's sync plan.
+func (s *Store) ReplaceSyncPlan(sourceID int64, entries []*SyncPlanEntry) error {
+	return s.InTx(func(tx *Tx) error {
+		if _, err := tx.tx.Exec(`DELETE FROM sync_plan WHERE source_id = ?`, sourceID); err != nil {
+			return fmt.Errorf("clear sync plan: %w", err)
+		}
+		now := time.Now()
+		for i, e := range entries {
+			_, err := tx.tx.Exec(`
+				INSERT INTO sync_plan (source_id, google_calendar_id, summary, position, estimated_events, estimated_requests, created_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?)
+			`, sourceID, e.GoogleCalendarID, e.Summary, i, e.EstimatedEvents, e.EstimatedRequests, now)
+			if err != nil {
+				return fmt.Errorf("save sync plan: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetSyncPlan returns the source's sync plan in order, with each calendar's
+// progress; it is empty if the source has no plan.
+func (s *Store) GetSyncPlan(sourceID int64) ([]*SyncPlanEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT p.google_calendar_id, COALESCE(p.summary, ''), p.estimated_events, p.estimated_requests, p.requests,
+		       COALESCE(c.sync_token, '') != '', COALESCE(c.page_token, '') != ''
+		FROM sync_plan p
+		LEFT JOIN calendars c ON c.source_id = p.source_id AND c.google_calendar_id = p.google_calendar_id
+		WHERE p.source_id = ?
+		ORDER BY p.position
+	`, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("query sync plan: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []*SyncPlanEntry
+	for rows.Next() {
+		var e SyncPlanEntry
+		if err := rows.Scan(&e.GoogleCalendarID, &e.Summary, &e.EstimatedEvents, &e.EstimatedRequests,
+			&e.Requests, &e.Done, &e.InProgress); err != nil {
+			return nil, fmt.Errorf("scan sync plan: %w", err)
+		}
+		entries = append(entries, &e)
+	}
+	return entries, rows.Err()
+}
+
+// AddSyncPlanRequest counts a full sync request against the calendar's
+// plan entry, if it has one.
+func (t *Tx) AddSyncPlanRequest(sourceID int64, googleCalendarID string) error {
+	_, err := t.tx.Exec(`
+		UPDATE sync_plan SET requests = requests + 1 WHERE source_id = ? AND google_calendar_id = ?
+	`, sourceID, googleCalendarID)
+	if err != nil {
+		return fmt.Errorf("update sync plan progress: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EventPurge picks the events PurgeEvents deletes: those that ended before
+// Before, on the given calendars.
+type EventPurge struct {
+	Before time.Time
+	// Calendars are calendar IDs or names, ignoring case; empty means
+	// every calendar.
+	Calendars []string
+	// Keep is how long the trash keeps the deleted rows, as one operation
+	// recorded under Command; 0 deletes them outright.
+	Keep    time.Duration
+	Command string // e.g. purge
+}
+
+// where returns the condition on events e that picks the purged events.
+// Series masters are only purged once every occurrence expanded from them
+// ended before the cutoff, since later occurrences depend on their rule.
+func (p EventPurge) where() (string, []any) {
+	before := p.Before.UTC()
+	conds := []string{`
+		COALESCE(e.end_time, e.start_time) < ?
+		AND (COALESCE(e.recurrence_rule, '') = ''
+		     OR (EXISTS (SELECT 1 FROM event_instances i WHERE i.series_id = e.id)
+		         AND NOT EXISTS (SELECT 1 FROM event_instances i
+		                         WHERE i.series_id = e.id AND COALESCE(i.end_time, i.start_time) >= ?)))`}
+	args := []any{before, before}
+	if len(p.Calendars) > 0 {
+		in := strings.TrimSuffix(strings.Repeat("?,", len(p.Calendars)), ",")
+		conds = append(conds, `e.calendar_id IN (
+			SELECT id FROM calendars WHERE google_calendar_id IN (`+in+`) OR lower(summary) IN (`+in+`))`)
+		for _, c := range p.Calendars {
+			args = append(args, c)
+		}
+		for _, c := range p.Calendars {
+			args = append(args, strings.ToLower(c))
+		}
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// checkCalendars returns an error naming the first of p.Calendars that
+// matches no calendar.
+func (p EventPurge) checkCalendars(db execer) error {
+	for _, c := range p.Calendars {
+		var n int
+		err := db.QueryRow(`SELECT COUNT(*) FROM calendars WHERE google_calendar_id = ? OR lower(summary) = ?`,
+			c, strings.ToLower(c)).Scan(&n)
+		if err != nil {
+			return fmt.Errorf("look up calendar: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("no calendar with ID or name %q", c)
+		}
+	}
+	return nil
+}
+
+// CountPurgeEvents returns how many events PurgeEvents would delete.
+func (s *Store) CountPurgeEvents(p EventPurge) (int64, error) {
+	if err := p.checkCalendars(s.db); err != nil {
+		return 0, err
+	}
+	where, args := p.where()
+	var n int64
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM events e WHERE `+where, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count events to purge: %w", err)
+	}
+	return n, nil
+}
+
+// description describes the purged events for the trash.
+func (p EventPurge) description() string {
+	d := "events that ended before " + p.Before.Format(time.DateOnly)
+	if len(p.Calendars) > 0 {
+		d += " on " + strings.Join(p.Calendars, ", ")
+	}
+	return d
+}
+
+// PurgeEvents deletes the events p picks, with their attendees and the
+// other rows that cascade from them, batchSize at a time, each batch in a
+// transaction of its own so syncs aren't held up for long. With p.Keep set,
+// each batch's rows are copied into the trash first, under one operation
+// for the whole run. onBatch, if set, is called with the total deleted
+// after each batch. It returns how many events were deleted and the trash
+// operation's ID (0 if nothing was kept), and fails with ErrLegalHold under
+// legal hold.
+func (s *Store) PurgeEvents(p EventPurge, batchSize int, onBatch func(deleted int64)) (deleted, trashID int64, err error) {
+	if err := checkLegalHold(s.db); err != nil {
+		return 0, 0, err
+	}
+	if err := p.checkCalendars(s.db); err != nil {
+		return 0, 0, err
+	}
+	where, args := p.where()
+	for {
+		var n int64
+		err := s.InTx(func(tx *Tx) error {
+			ids, err := purgeBatch(tx.tx, where, append(args, batchSize))
+			if err != nil || len(ids) == 0 {
+				return err
+			}
+			in := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+			if p.Keep > 0 {
+				if trashID == 0 {
+					if trashID, err = tx.createTrashOperation(p.Command, p.description(), p.Keep); err != nil {
+						return err
+					}
+				}
+				if err := tx.trashEvents(trashID, in, ids); err != nil {
+					return err
+				}
+			}
+			res, err := tx.tx.Exec(`DELETE FROM events WHERE id IN (`+in+`)`, ids...)
+			if err != nil {
+				return fmt.Errorf("purge events: %w", err)
+			}
+			if n, err = res.RowsAffected(); err != nil {
+				return fmt.Errorf("purge events: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return deleted, trashID, err
+		}
+		if n == 0 {
+			return deleted, trashID, nil
+		}
+		deleted += n
+		if onBatch != nil {
+			onBatch(deleted)
+		}
+	}
+}
+
+// purgeBatch returns the IDs of the next batch of events to purge.
+func purgeBatch(db execer, where string, args []any) ([]any, error) {
+	rows, err := db.Query(`SELECT e.id FROM events e WHERE `+where+` LIMIT ?`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("find events to purge: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var ids []any
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("find events to purge: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Quality flags mark events whose dates can't be right, typically from a
+// client with a skewed clock or a broken import. Flagged events are stored
+// as received but left out of analytics and the stats date range.
+const (
+	QualityDateTooEarly    = "date_too_early"   // Starts before 1900, or at the Unix epoch
+	QualityDateTooLate     = "date_too_late"    // Starts or ends more than 100 years from now
+	QualityEndBeforeStart  = "end_before_start" // Ends before it starts
+	QualityFutureTimestamp = "future_timestamp" // Created or updated more than a day from now
+)
+
+// earliestPlausible is the earliest start an event is expected to have.
+var earliestPlausible = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// EventQuality returns the quality flag of an event as of now, or "" if its
+// dates are plausible. The first problem found wins.
+func EventQuality(e *Event, now time.Time) string {
+	latest := now.AddDate(100, 0, 0)
+	if e.StartTime.Valid {
+		start := e.StartTime.Time
+		if start.Before(earliestPlausible) || start.Unix() == 0 {
+			return QualityDateTooEarly
+		}
+		if start.After(latest) {
+			return QualityDateTooLate
+		}
+	}
+	if e.EndTime.Valid {
+		if e.EndTime.Time.After(latest) {
+			return QualityDateTooLate
+		}
+		if e.StartTime.Valid && e.EndTime.Time.Before(e.StartTime.Time) {
+			return QualityEndBeforeStart
+		}
+	}
+	skew := now.Add(24 * time.Hour)
+	if (e.CreatedAt.Valid && e.CreatedAt.Time.After(skew)) || (e.UpdatedAt.Valid && e.UpdatedAt.Time.After(skew)) {
+		return QualityFutureTimestamp
+	}
+	return ""
+}
+
+// FlaggedEventCounts returns how many stored events carry each quality flag.
+func (s *Store) FlaggedEventCounts() (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT quality, COUNT(*) FROM events WHERE quality IS NOT NULL GROUP BY quality`)
+	if err != nil {
+		return nil, fmt.Errorf("count flagged events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var quality string
+		var n int
+		if err := rows.Scan(&quality, &n); err != nil {
+			return nil, fmt.Errorf("scan flagged events: %w", err)
+		}
+		counts[quality] = n
+	}
+	return counts, rows.Err()
+}
+
+// recheckEventQuality sets the quality flag of every stored event, for
+// databases whose events were stored before flags existed.
+func (s *Store) recheckEventQuality() error {
+	rows, err := s.db.Query(`SELECT ` + eventColumns + ` FROM events`)
+	if err != nil {
+		return fmt.Errorf("check event quality: %w", err)
+	}
+	flags := make(map[int64]string)
+	now := time.Now()
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			_ = rows.Close()
+			return err
+		}
+		if q := EventQuality(e, now); q != "" {
+			flags[e.ID] = q
+		}
+	}
+	err = rows.Err()
+	_ = rows.Close()
+	if err != nil {
+		return fmt.Errorf("check event quality: %w", err)
+	}
+	for id, q := range flags {
+		if _, err := s.db.Exec(`UPDATE events SET quality = ? WHERE id = ?`, q, id); err != nil {
+			return fmt.Errorf("flag event %d: %w", id, err)
+		}
+	}
+	return nil
+}
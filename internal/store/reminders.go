@@ -0,0 +1,75 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Reminder is an alarm some time before an event starts.
+type Reminder struct {
+	Method    string // popup or email
+	Minutes   int    // Before the start
+	IsDefault bool   // From the calendar's defaults rather than the event
+}
+
+// EventReminders are an event's reminder settings as Google reports them.
+type EventReminders struct {
+	UseDefault bool        // The calendar's default reminders apply
+	Overrides  []*Reminder // The event's own reminders
+}
+
+// ListReminders returns the reminders an event fires, its own or its
+// calendar's defaults, soonest to the start last.
+func (s *Store) ListReminders(eventID int64) ([]*Reminder, error) {
+	rows, err := s.db.Query(`
+		SELECT method, minutes, is_default FROM event_reminders
+		WHERE event_id = ?
+		ORDER BY minutes DESC, method
+	`, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("list reminders: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var reminders []*Reminder
+	for rows.Next() {
+		r := &Reminder{}
+		if err := rows.Scan(&r.Method, &r.Minutes, &r.IsDefault); err != nil {
+			return nil, fmt.Errorf("scan reminder: %w", err)
+		}
+		reminders = append(reminders, r)
+	}
+	return reminders, rows.Err()
+}
+
+// replaceEventReminders stores an event's reminder settings, replacing its
+// previous ones.
+func replaceEventReminders(db execer, eventID, calendarID int64, r *EventReminders) error {
+	if _, err := db.Exec(`UPDATE events SET reminders_default = ? WHERE id = ?`, r.UseDefault, eventID); err != nil {
+		return fmt.Errorf("store reminder settings: %w", err)
+	}
+	if _, err := db.Exec(`DELETE FROM reminders WHERE event_id = ?`, eventID); err != nil {
+		return fmt.Errorf("delete reminders: %w", err)
+	}
+	return insertReminders(db, calendarID, sql.NullInt64{Int64: eventID, Valid: true}, r.Overrides)
+}
+
+// replaceCalendarReminders replaces a calendar's default reminders.
+func replaceCalendarReminders(db execer, calendarID int64, reminders []*Reminder) error {
+	if _, err := db.Exec(`DELETE FROM reminders WHERE calendar_id = ? AND event_id IS NULL`, calendarID); err != nil {
+		return fmt.Errorf("delete default reminders: %w", err)
+	}
+	return insertReminders(db, calendarID, sql.NullInt64{}, reminders)
+}
+
+func insertReminders(db execer, calendarID int64, eventID sql.NullInt64, reminders []*Reminder) error {
+	for _, r := range reminders {
+		if _, err := db.Exec(
+			`INSERT INTO reminders (calendar_id, event_id, method, minutes) VALUES (?, ?, ?, ?)`,
+			calendarID, eventID, r.Method, r.Minutes,
+		); err != nil {
+			return fmt.Errorf("insert reminder: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package store
+
+import "fmt"
+
+// SourceRemoval counts the rows removed with a source.
+type SourceRemoval struct {
+	Calendars     int64
+	Events        int64
+	Attendees     int64
+	SyncRuns      int64
+	WatchChannels int64
+}
+
+// DeleteSource removes a source and everything recorded for it: calendars,
+// events, attendees, sync runs, watch channels, its sync plan, the
+// provenance it gave merged events and its analytics rows. It fails with
+// ErrLegalHold under legal hold.
+func (t *Tx) DeleteSource(sourceID int64) (*SourceRemoval, error) {
+	r := &SourceRemoval{}
+	if err := checkLegalHold(t.tx); err != nil {
+		return nil, err
+	}
+
+	// Attendees go with their events by cascade, so count them first
+	err := t.tx.QueryRow(`
+		SELECT COUNT(*) FROM attendees
+		WHERE event_id IN (SELECT id FROM events WHERE source_id = ?)
+	`, sourceID).Scan(&r.Attendees)
+	if err != nil {
+		return nil, fmt.Errorf("count attendees: %w", err)
+	}
+
+	steps := []struct {
+		table string
+		count *int64
+	}{
+		{"watch_channels", &r.WatchChannels},
+		{"sync_runs", &r.SyncRuns},
+		{"sync_plan", nil},
+		{"event_instances", nil},
+		{"event_provenance", nil},
+		{"events", &r.Events},
+		{"calendars", &r.Calendars},
+		// Deleting events marks their analytics keys dirty, so these go last
+		{"daily_meeting_minutes", nil},
+		{"person_meeting_counts", nil},
+		{"analytics_dirty", nil},
+	}
+	for _, step := range steps {
+		result, err := t.tx.Exec(`DELETE FROM `+step.table+` WHERE source_id = ?`, sourceID)
+		if err != nil {
+			return nil, fmt.Errorf("delete %s: %w", step.table, err)
+		}
+		if step.count == nil {
+			continue
+		}
+		if *step.count, err = result.RowsAffected(); err != nil {
+			return nil, fmt.Errorf("delete %s: %w", step.table, err)
+		}
+	}
+
+	if _, err := t.tx.Exec(`DELETE FROM sources WHERE id = ?`, sourceID); err != nil {
+		return nil, fmt.Errorf("delete source: %w", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrNewerSchema is returned when restoring a backup over a database with a
+// newer schema, which would lose what the newer calvault stored.
+var ErrNewerSchema = errors.New("database has a newer schema than the backup")
+
+// Restored describes a backup restored by Restore.
+type Restored struct {
+	SchemaVersion  int    // Of the backup; 0 if it predates SchemaVersion
+	ReplacedSchema int    // Of the database it replaced
+	Replaced       string // Where the replaced database was kept, if there was one
+}
+
+// Restore replaces the database at dbPath with a backup written by Backup,
+// plain or gzip-compressed. The backup is copied next to dbPath and checked
+// with SQLite's integrity_check first, and then renamed over the database,
+// so a failed restore leaves the database as it was. Unless force is set,
+// a database with a newer schema than the backup is not replaced. A database
+// under legal hold (SetLegalHold) is never replaced, with ErrLegalHold. The
+// database it replaces is kept as <dbPath>.pre-restore.
+//
+// Nothing may have the database open: the caller stops syncs and the daemon.
+func Restore(backup, dbPath string, force bool) (*Restored, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(dbPath), ".calvault-restore-*")
+	if err != nil {
+		return nil, fmt.Errorf("replace database: %w", err)
+	}
+	copyPath := tmp.Name()
+	defer func() { _ = os.Remove(copyPath) }()
+	err = copyBackup(backup, tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read backup: %w", err)
+	}
+
+	if err := checkDatabase(copyPath, "integrity_check"); err != nil {
+		return nil, fmt.Errorf("check backup: %w", err)
+	}
+	restored := &Restored{}
+	restored.SchemaVersion, err = backupSchemaVersion(copyPath)
+	if err != nil {
+		return nil, err
+	}
+	if restored.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("backup has schema version %d, newer than this calvault supports (%d) - upgrade calvault to restore it", restored.SchemaVersion, SchemaVersion)
+	}
+
+	if _, err := os.Stat(dbPath); err == nil {
+		restored.ReplacedSchema, err = checkpointDatabase(dbPath)
+		if err != nil {
+			return nil, err
+		}
+		if restored.ReplacedSchema > restored.SchemaVersion && !force {
+			return nil, fmt.Errorf("%w (%d, backup %d)", ErrNewerSchema, restored.ReplacedSchema, restored.SchemaVersion)
+		}
+		restored.Replaced = dbPath + ".pre-restore"
+		if err := os.Remove(restored.Replaced); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("replace database: %w", err)
+		}
+		if err := keepReplaced(dbPath, restored.Replaced); err != nil {
+			return nil, fmt.Errorf("keep replaced database: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("replace database: %w", err)
+	}
+
+	// A write-ahead log left next to the restored file would be applied
+	// to it; the checkpoint above emptied it into the replaced database
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("replace database: %w", err)
+		}
+	}
+	if err := os.Rename(copyPath, dbPath); err != nil {
+		return nil, fmt.Errorf("replace database: %w", err)
+	}
+	return restored, nil
+}
+
+// linkFile is os.Link, replaced in tests.
+var linkFile = os.Link
+
+// keepReplaced keeps the database at path as keep: a hard link keeps it
+// without copying it, and where one can't be made - a file system without
+// hard links, say - it is copied.
+func keepReplaced(path, keep string) error {
+	if err := linkFile(path, keep); err == nil {
+		return nil
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.OpenFile(keep, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(keep)
+	}
+	return err
+}
+
+// copyBackup writes the database in a backup file to dst, decompressing
+// it if it is gzipped.
+func copyBackup(backup string, dst io.Writer) error {
+	f, err := os.Open(backup)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	r := bufio.NewReader(f)
+	var src io.Reader = r
+	if magic, err := r.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = zr.Close() }()
+		src = zr
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// backupSchemaVersion returns the schema version of the database at path,
+// after checking it is a calvault database.
+func backupSchemaVersion(path string) (int, error) {
+	db, err := sql.Open("sqlite3", path+"?mode=ro")
+	if err != nil {
+		return 0, fmt.Errorf("open backup: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	var n int
+	err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('sources', 'events')`).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("check backup: %w", err)
+	}
+	if n != 2 {
+		return 0, fmt.Errorf("not a calvault database")
+	}
+	return schemaVersion(db)
+}
+
+// checkpointDatabase moves the write-ahead log of the database at path
+// into the database file, and returns its schema version. It returns
+// ErrLegalHold if the database is under legal hold.
+func checkpointDatabase(path string) (int, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return 0, fmt.Errorf("open database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+	if err := checkLegalHold(db); err != nil {
+		return 0, err
+	}
+	if _, err := db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return 0, fmt.Errorf("checkpoint database: %w", err)
+	}
+	return schemaVersion(db)
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// RoomUsage summarizes bookings of one room or resource calendar.
+type RoomUsage struct {
+	CalendarID  int64
+	Name        string
+	Bookings    int
+	BookedHours float64
+	// BookingRate is the share of working hours that were booked.
+	BookingRate float64
+	// NoShows counts bookings whose organizer declined: the room stayed
+	// reserved though the meeting likely didn't happen there.
+	NoShows int
+	// Hourly counts bookings in progress during each hour of the day.
+	Hourly [24]int
+}
+
+// PeakHours returns the hours of the day with the most bookings, up to n,
+// busiest first.
+func (u *RoomUsage) PeakHours(n int) []int {
+	var hours []int
+	for h, c := range u.Hourly {
+		if c > 0 {
+			hours = append(hours, h)
+		}
+	}
+	sort.SliceStable(hours, func(i, j int) bool { return u.Hourly[hours[i]] > u.Hourly[hours[j]] })
+	if len(hours) > n {
+		hours = hours[:n]
+	}
+	return hours
+}
+
+// GetRoomReport returns usage of the calendars isRoom picks out, from the
+// calendar's ID and name, for bookings starting in [since, until): timed,
+// non-cancelled events on the room's calendar. Working and peak hours are
+// in since's location. Rooms are ordered by booking rate, busiest first.
+func (s *Store) GetRoomReport(since, until time.Time, hours WorkingHours, isRoom func(id, name string) bool) ([]*RoomUsage, error) {
+	rooms := map[int64]*RoomUsage{}
+	calRows, err := s.db.Query(`SELECT id, google_calendar_id, COALESCE(summary, '') FROM calendars`)
+	if err != nil {
+		return nil, fmt.Errorf("query calendars: %w", err)
+	}
+	for calRows.Next() {
+		var id int64
+		var calID, name string
+		if err := calRows.Scan(&id, &calID, &name); err != nil {
+			_ = calRows.Close()
+			return nil, fmt.Errorf("scan calendar: %w", err)
+		}
+		if !isRoom(calID, name) {
+			continue
+		}
+		if name == "" {
+			name = calID
+		}
+		rooms[id] = &RoomUsage{CalendarID: id, Name: name}
+	}
+	_ = calRows.Close()
+	if err := calRows.Err(); err != nil {
+		return nil, fmt.Errorf("query calendars: %w", err)
+	}
+	if len(rooms) == 0 {
+		return nil, nil
+	}
+
+	inRange, inRangeArgs := startsIn("e", since, until)
+	rows, err := s.db.Query(`
+		SELECT e.calendar_id, e.start_time, e.end_time,
+		       EXISTS (SELECT 1 FROM attendees a
+		               WHERE a.event_id = e.id AND a.is_organizer AND a.response_status = 'declined')
+		FROM events e
+		WHERE `+inRange+`
+		  AND e.end_time IS NOT NULL
+		  AND e.all_day = FALSE
+		  AND COALESCE(e.status, '') != 'cancelled'
+	`, inRangeArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("query room bookings: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	loc := since.Location()
+	for rows.Next() {
+		var calendarID int64
+		var start, end time.Time
+		var declined bool
+		if err := rows.Scan(&calendarID, &start, &end, &declined); err != nil {
+			return nil, fmt.Errorf("scan room booking: %w", err)
+		}
+		room := rooms[calendarID]
+		if room == nil || !end.After(start) {
+			continue
+		}
+		start, end = start.In(loc), end.In(loc)
+
+		room.Bookings++
+		room.BookedHours += end.Sub(start).Hours()
+		room.BookingRate += hours.Within(start, end).Hours()
+		if declined {
+			room.NoShows++
+		}
+		for h := start.Truncate(time.Hour); h.Before(end); h = h.Add(time.Hour) {
+			room.Hourly[h.Hour()]++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query room bookings: %w", err)
+	}
+
+	available := hours.Within(since, until).Hours()
+	report := make([]*RoomUsage, 0, len(rooms))
+	for _, room := range rooms {
+		if available > 0 {
+			room.BookingRate /= available
+		} else {
+			room.BookingRate = 0
+		}
+		report = append(report, room)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].BookingRate != report[j].BookingRate {
+			return report[i].BookingRate > report[j].BookingRate
+		}
+		return report[i].Name < report[j].Name
+	})
+	return report, nil
+}
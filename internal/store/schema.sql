@@ -3,6 +3,8 @@ CREATE TABLE IF NOT EXISTS sources (
     id INTEGER PRIMARY KEY,
     source_type TEXT NOT NULL DEFAULT 'google',
     identifier TEXT NOT NULL UNIQUE,  -- email address
+    owner TEXT,  -- Team member the account belongs to (team vaults)
+    calendar_list_token TEXT,  -- Sync token for calendar list changes
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -16,7 +18,13 @@ CREATE TABLE IF NOT EXISTS calendars (
     timezone TEXT,
     is_primary BOOLEAN DEFAULT FALSE,
     sync_token TEXT,  -- For incremental sync
+    page_token TEXT,  -- Next page of an interrupted full sync (resume point)
     last_synced_at DATETIME,
+    access_role TEXT,        -- owner, writer, reader, freeBusyReader
+    subscription_kind TEXT,  -- primary, owned, shared, subscription, import
+    private BOOLEAN DEFAULT FALSE,  -- Hidden from other team members
+    color_id TEXT,  -- Google's calendar palette ID (colors.get)
+    background_color TEXT,  -- The calendar's color as shown, e.g. #9fc6e7; may be custom
     UNIQUE(source_id, google_calendar_id)
 );
 
@@ -26,8 +34,9 @@ CREATE INDEX IF NOT EXISTS idx_calendars_source ON calendars(source_id);
 CREATE TABLE IF NOT EXISTS events (
     id INTEGER PRIMARY KEY,
     source_id INTEGER NOT NULL REFERENCES sources(id),
-    calendar_id INTEGER NOT NULL REFERENCES calendars(id),
+    calendar_id INTEGER NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
     google_event_id TEXT NOT NULL,
+    ical_uid TEXT,  -- iCalendar UID, shared by every copy of the event (indexed in migrate.go)
     
     -- Core fields
     summary TEXT,
@@ -47,6 +56,9 @@ CREATE TABLE IF NOT EXISTS events (
     -- Status
     status TEXT DEFAULT 'confirmed',  -- confirmed, tentative, cancelled
     visibility TEXT,  -- default, public, private
+    color_id TEXT,  -- Event color 1-11 (see the color_names view); NULL = the calendar's color
+    reminders_default BOOLEAN,  -- The calendar's default reminders apply; NULL = unknown
+    quality TEXT,  -- NULL = plausible dates; else why not: date_too_early, date_too_late, end_before_start, future_timestamp
     
     -- People
     organizer_email TEXT,
@@ -58,6 +70,14 @@ CREATE TABLE IF NOT EXISTS events (
     updated_at DATETIME,
     synced_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     
+    -- Derived from the times, computed by SQLite (see derivedColumns in
+    -- migrate.go). Dates, hours and weekdays are in the event's own UTC
+    -- offset, as start_time is stored.
+    duration_minutes REAL GENERATED ALWAYS AS ((strftime('%s', end_time) - strftime('%s', start_time)) / 60.0) VIRTUAL,
+    start_date TEXT GENERATED ALWAYS AS (substr(start_time, 1, 10)) VIRTUAL,  -- YYYY-MM-DD
+    start_hour INTEGER GENERATED ALWAYS AS (CASE WHEN all_day THEN NULL ELSE CAST(substr(start_time, 12, 2) AS INTEGER) END) VIRTUAL,  -- 0-23; NULL for all-day events
+    weekday INTEGER GENERATED ALWAYS AS (CAST(strftime('%w', substr(start_time, 1, 10)) AS INTEGER)) VIRTUAL,  -- 0 = Sunday
+    
     UNIQUE(source_id, google_event_id)
 );
 
@@ -66,11 +86,42 @@ CREATE INDEX IF NOT EXISTS idx_events_calendar ON events(calendar_id);
 CREATE INDEX IF NOT EXISTS idx_events_recurring ON events(recurring_event_id);
 CREATE INDEX IF NOT EXISTS idx_events_summary ON events(summary);
 
+-- Occurrences of recurring series up to [query] instance_horizon_days ahead:
+-- expanded from the series' rule (EXDATEs and replaced occurrences left
+-- out), or a stored instance or exception. Rebuilt after syncs and imports.
+CREATE TABLE IF NOT EXISTS event_instances (
+    id INTEGER PRIMARY KEY,
+    source_id INTEGER NOT NULL REFERENCES sources(id),
+    recurring_event_id TEXT NOT NULL,  -- The series' google_event_id
+    series_id INTEGER REFERENCES events(id) ON DELETE CASCADE,  -- NULL if only instances are stored (single_events)
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,  -- The series for expanded occurrences, else the stored instance
+    start_time DATETIME NOT NULL,
+    end_time DATETIME,
+    all_day BOOLEAN DEFAULT FALSE,
+    expanded BOOLEAN NOT NULL  -- Generated from the rule rather than stored
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_instances_series ON event_instances(series_id);
+CREATE INDEX IF NOT EXISTS idx_event_instances_start ON event_instances(start_time);
+
+-- Other sources an event arrived from. An ICS import of an event that an
+-- API source (Google) also syncs, matched by iCalendar UID, is kept as the
+-- API source's row; the imported copy is recorded here instead.
+CREATE TABLE IF NOT EXISTS event_provenance (
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    source_id INTEGER NOT NULL REFERENCES sources(id),
+    calendar_id INTEGER NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    external_id TEXT NOT NULL,  -- The event's ID in that source (e.g. the ICS UID)
+    seen_at DATETIME NOT NULL,  -- Last import that included it
+    PRIMARY KEY (event_id, source_id, external_id)
+);
+
 -- Attendees
 CREATE TABLE IF NOT EXISTS attendees (
     id INTEGER PRIMARY KEY,
     event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
-    email TEXT NOT NULL,
+    email TEXT NOT NULL,  -- As the provider gave it
+    canonical_email TEXT,  -- Lowercased, without a +tag, googlemail.com as gmail.com, or its person's email in people; people analytics group by it (indexed in migrate.go)
     display_name TEXT,
     response_status TEXT,  -- needsAction, declined, tentative, accepted
     is_organizer BOOLEAN DEFAULT FALSE,
@@ -81,14 +132,170 @@ CREATE TABLE IF NOT EXISTS attendees (
 CREATE INDEX IF NOT EXISTS idx_attendees_email ON attendees(email);
 CREATE INDEX IF NOT EXISTS idx_attendees_event ON attendees(event_id);
 
+-- Email aliases of one person, from [[identity]] in config.toml
+-- (SetIdentities). Attendees with any of them get the person's email as
+-- canonical_email, so people analytics count them as one.
+CREATE TABLE IF NOT EXISTS people (
+    email TEXT PRIMARY KEY,  -- An alias, as CanonicalEmail
+    person_email TEXT NOT NULL,  -- The identity's first email, as CanonicalEmail
+    name TEXT
+);
+
+-- Video conferences attached to events (Google Meet, or add-ons such as
+-- Zoom), from Google's conferenceData and hangoutLink. Events without one
+-- have no row. Passcodes and PINs are not stored.
+CREATE TABLE IF NOT EXISTS conference_data (
+    event_id INTEGER PRIMARY KEY REFERENCES events(id) ON DELETE CASCADE,
+    solution_type TEXT,  -- hangoutsMeet, eventHangout, eventNamedHangout or addOn
+    solution_name TEXT,  -- e.g. Google Meet, Zoom Meeting
+    conference_id TEXT,  -- The provider's ID for the conference
+    meeting_code TEXT,  -- Of the video entry point
+    video_uri TEXT,  -- Link to join by video
+    hangout_link TEXT,  -- The event's Google Meet link
+    entry_points TEXT  -- JSON array of {type, uri, label, meeting_code}; type is video, phone, sip or more
+);
+
+-- Earlier versions of events, kept while [storage] event_history is on:
+-- each row is an event as it was before an update changed its details
+-- (written by the trg_events_history trigger, see history.go).
+CREATE TABLE IF NOT EXISTS event_history (
+    id INTEGER PRIMARY KEY,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    changed_at DATETIME NOT NULL,  -- When calvault stored the change
+    summary TEXT,
+    description TEXT,
+    location TEXT,
+    start_time DATETIME,
+    end_time DATETIME,
+    all_day BOOLEAN,
+    original_timezone TEXT,
+    recurrence_rule TEXT,
+    status TEXT,
+    visibility TEXT,
+    organizer_email TEXT,
+    updated_at DATETIME  -- The provider's modification time of this version
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_history_event ON event_history(event_id, changed_at);
+
+-- Events something deleted while [storage] legal_hold was on: the event is
+-- kept as it was and its first deletion recorded here instead (written by
+-- the trg_events_legal_hold trigger, see hold.go). Leave them out with
+-- NOT EXISTS (SELECT 1 FROM event_tombstones t WHERE t.event_id = e.id).
+CREATE TABLE IF NOT EXISTS event_tombstones (
+    event_id INTEGER PRIMARY KEY REFERENCES events(id) ON DELETE CASCADE,
+    deleted_at DATETIME NOT NULL  -- When the first deletion was attempted
+);
+
+-- Reminders (alarms before an event starts): an event's own reminders, and
+-- each calendar's default reminders (event_id NULL), which apply to events
+-- with events.reminders_default set. The event_reminders view resolves
+-- them per event.
+CREATE TABLE IF NOT EXISTS reminders (
+    id INTEGER PRIMARY KEY,
+    calendar_id INTEGER NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    event_id INTEGER REFERENCES events(id) ON DELETE CASCADE,  -- NULL = a calendar default
+    method TEXT NOT NULL,  -- popup or email
+    minutes INTEGER NOT NULL  -- Before the start
+);
+
+CREATE INDEX IF NOT EXISTS idx_reminders_event ON reminders(event_id);
+CREATE INDEX IF NOT EXISTS idx_reminders_calendar ON reminders(calendar_id);
+
+-- Entities (doctors, companies, project codes...) extracted from event
+-- titles and descriptions by 'calvault enrich'.
+CREATE TABLE IF NOT EXISTS entities (
+    id INTEGER PRIMARY KEY,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,  -- As named by the rule or model, e.g. doctor, company, project
+    name TEXT NOT NULL,
+    confidence REAL NOT NULL,  -- 0-1
+    extractor TEXT NOT NULL,  -- rules or llm
+    UNIQUE(event_id, extractor, kind, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_entities_name ON entities(kind, name);
+
+-- Events each enrichment stage has processed, so enrich only revisits new
+-- and re-synced events.
+CREATE TABLE IF NOT EXISTS enrich_state (
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    stage TEXT NOT NULL,  -- e.g. platform, tags, entities:rules, entities:llm
+    enriched_at DATETIME NOT NULL,
+    PRIMARY KEY (event_id, stage)
+);
+
+-- The meeting platform an event is held on, detected by the platform
+-- enrichment stage from its conference, location and description.
+CREATE TABLE IF NOT EXISTS event_platforms (
+    event_id INTEGER PRIMARY KEY REFERENCES events(id) ON DELETE CASCADE,
+    platform TEXT NOT NULL,  -- meet, zoom, teams, webex, ..., other_video or in_person
+    url TEXT  -- The join link the platform was detected from
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_platforms_platform ON event_platforms(platform);
+
+-- Tags given to events by the [[enrich.tag]] rules of the tags stage.
+CREATE TABLE IF NOT EXISTS event_tags (
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (event_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_tags_tag ON event_tags(tag);
+
+-- Locations looked up by the geocode stage, keyed by their normalized
+-- text, so each place is only looked up once. Places the geocoder didn't
+-- find have no coordinates.
+CREATE TABLE IF NOT EXISTS geocodes (
+    query TEXT PRIMARY KEY,  -- events.location, lowercased with whitespace collapsed
+    latitude REAL,
+    longitude REAL,
+    display_name TEXT,  -- The geocoder's name for the place
+    geocoded_at DATETIME NOT NULL
+);
+
+-- The coordinates of events whose location the geocode stage found.
+CREATE TABLE IF NOT EXISTS event_locations (
+    event_id INTEGER PRIMARY KEY REFERENCES events(id) ON DELETE CASCADE,
+    latitude REAL NOT NULL,
+    longitude REAL NOT NULL,
+    display_name TEXT
+);
+
+-- Meeting notes linked to events by 'calvault link', by hand or matched by
+-- the date and title in the note's file name.
+CREATE TABLE IF NOT EXISTS event_notes (
+    id INTEGER PRIMARY KEY,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    path TEXT NOT NULL,  -- Absolute path of the note file
+    occurrence DATETIME,  -- For a recurring series, the start of the occurrence the note is about
+    matched BOOLEAN NOT NULL DEFAULT FALSE,  -- Matched by file name rather than linked by hand
+    linked_at DATETIME NOT NULL,
+    UNIQUE(event_id, path)
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_notes_path ON event_notes(path);
+
+-- Counts writes to the archive, so cached query results can tell the data
+-- changed. Bumped by triggers on every table but query_audit (created by
+-- migrate, see version.go).
+CREATE TABLE IF NOT EXISTS data_version (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    version INTEGER NOT NULL
+);
+
+INSERT OR IGNORE INTO data_version (id, version) VALUES (1, 0);
+
 -- Sync tracking
 CREATE TABLE IF NOT EXISTS sync_runs (
     id INTEGER PRIMARY KEY,
     source_id INTEGER NOT NULL REFERENCES sources(id),
-    calendar_id INTEGER REFERENCES calendars(id),
+    calendar_id INTEGER REFERENCES calendars(id) ON DELETE SET NULL,
+    kind TEXT,  -- full or incremental; NULL for imports
     started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     completed_at DATETIME,
-    status TEXT DEFAULT 'running',  -- running, completed, failed
+    status TEXT DEFAULT 'running',  -- running, completed, interrupted, deferred, failed
     events_added INTEGER DEFAULT 0,
     events_updated INTEGER DEFAULT 0,
     events_deleted INTEGER DEFAULT 0,
@@ -96,3 +303,250 @@ CREATE TABLE IF NOT EXISTS sync_runs (
 );
 
 CREATE INDEX IF NOT EXISTS idx_sync_runs_source ON sync_runs(source_id);
+
+-- Long-running operations - full syncs, enrichment, expanding recurring
+-- series - and their progress, so 'calvault jobs' can show them and resume
+-- the interrupted ones.
+CREATE TABLE IF NOT EXISTS jobs (
+    id INTEGER PRIMARY KEY,
+    kind TEXT NOT NULL,  -- sync, enrich or expand
+    description TEXT NOT NULL,
+    params TEXT NOT NULL,  -- JSON the job is resumed with
+    status TEXT NOT NULL,  -- running, completed, failed, interrupted or resumed
+    done INTEGER NOT NULL DEFAULT 0,  -- Items processed: events synced or enriched, occurrences expanded
+    total INTEGER,  -- Items expected, if known
+    pid INTEGER,  -- Of the process running the job
+    resumed_from INTEGER REFERENCES jobs(id) ON DELETE SET NULL,
+    error_message TEXT,
+    started_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    finished_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
+
+-- Archive statistics recorded after syncs, for charting growth over time
+CREATE TABLE IF NOT EXISTS stats_history (
+    id INTEGER PRIMARY KEY,
+    recorded_at DATETIME NOT NULL,
+    trigger TEXT NOT NULL,  -- What recorded it: sync, daemon, import...
+    accounts INTEGER NOT NULL,
+    calendars INTEGER NOT NULL,
+    events INTEGER NOT NULL,
+    db_size_bytes INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_stats_history_recorded ON stats_history(recorded_at);
+
+-- Event counts per calendar in a stats_history snapshot; names are copied
+-- so removed calendars still chart
+CREATE TABLE IF NOT EXISTS stats_history_calendars (
+    snapshot_id INTEGER NOT NULL REFERENCES stats_history(id) ON DELETE CASCADE,
+    calendar_id INTEGER NOT NULL,
+    account TEXT NOT NULL,
+    calendar TEXT NOT NULL,
+    events INTEGER NOT NULL,
+    PRIMARY KEY (snapshot_id, calendar_id)
+);
+
+-- Query audit log (every query run through the executor)
+CREATE TABLE IF NOT EXISTS query_audit (
+    id INTEGER PRIMARY KEY,
+    executed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    caller TEXT,
+    query_name TEXT,  -- Named query, if any
+    sql TEXT NOT NULL,
+    params TEXT,  -- JSON object of bound parameters
+    duration_ms INTEGER,
+    row_count INTEGER,
+    error_message TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_query_audit_executed ON query_audit(executed_at);
+
+-- Materialized analytics (maintained by RefreshAnalyticsTables)
+-- Days are the event's local date as recorded (its own UTC offset).
+CREATE TABLE IF NOT EXISTS daily_meeting_minutes (
+    source_id INTEGER NOT NULL REFERENCES sources(id),
+    day TEXT NOT NULL,  -- YYYY-MM-DD
+    meeting_count INTEGER NOT NULL,
+    meeting_minutes REAL NOT NULL,
+    PRIMARY KEY (source_id, day)
+);
+
+CREATE TABLE IF NOT EXISTS person_meeting_counts (
+    source_id INTEGER NOT NULL REFERENCES sources(id),
+    email TEXT NOT NULL,  -- Attendee's canonical_email
+    meeting_count INTEGER NOT NULL,
+    meeting_minutes REAL NOT NULL,
+    first_seen DATETIME,
+    last_seen DATETIME,
+    PRIMARY KEY (source_id, email)
+);
+
+-- Keys whose aggregates are stale, recorded by the triggers below.
+-- The triggers use NOT EXISTS rather than INSERT OR IGNORE because an
+-- outer UPSERT's conflict policy overrides the one inside a trigger.
+CREATE TABLE IF NOT EXISTS analytics_dirty (
+    source_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,  -- day, person
+    key TEXT NOT NULL,
+    PRIMARY KEY (source_id, kind, key)
+);
+
+CREATE TABLE IF NOT EXISTS analytics_state (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    built_at DATETIME,
+    refreshed_at DATETIME
+);
+
+CREATE TRIGGER IF NOT EXISTS trg_events_analytics_insert AFTER INSERT ON events
+WHEN new.start_time IS NOT NULL
+BEGIN
+    INSERT INTO analytics_dirty (source_id, kind, key)
+    SELECT new.source_id, 'day', substr(new.start_time, 1, 10)
+    WHERE NOT EXISTS (SELECT 1 FROM analytics_dirty
+                      WHERE source_id = new.source_id AND kind = 'day' AND key = substr(new.start_time, 1, 10));
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_events_analytics_update AFTER UPDATE ON events
+BEGIN
+    INSERT INTO analytics_dirty (source_id, kind, key)
+    SELECT DISTINCT new.source_id, 'day', k.key
+    FROM (SELECT substr(old.start_time, 1, 10) AS key UNION SELECT substr(new.start_time, 1, 10)) k
+    WHERE k.key IS NOT NULL
+      AND NOT EXISTS (SELECT 1 FROM analytics_dirty
+                      WHERE source_id = new.source_id AND kind = 'day' AND key = k.key);
+    INSERT INTO analytics_dirty (source_id, kind, key)
+    SELECT DISTINCT new.source_id, 'person', a.canonical_email FROM attendees a
+    WHERE a.event_id = new.id
+      AND NOT EXISTS (SELECT 1 FROM analytics_dirty
+                      WHERE source_id = new.source_id AND kind = 'person' AND key = a.canonical_email);
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_events_analytics_delete BEFORE DELETE ON events
+BEGIN
+    INSERT INTO analytics_dirty (source_id, kind, key)
+    SELECT old.source_id, 'day', substr(old.start_time, 1, 10)
+    WHERE old.start_time IS NOT NULL
+      AND NOT EXISTS (SELECT 1 FROM analytics_dirty
+                      WHERE source_id = old.source_id AND kind = 'day' AND key = substr(old.start_time, 1, 10));
+    INSERT INTO analytics_dirty (source_id, kind, key)
+    SELECT DISTINCT old.source_id, 'person', a.canonical_email FROM attendees a
+    WHERE a.event_id = old.id
+      AND NOT EXISTS (SELECT 1 FROM analytics_dirty
+                      WHERE source_id = old.source_id AND kind = 'person' AND key = a.canonical_email);
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_attendees_analytics_insert AFTER INSERT ON attendees
+BEGIN
+    INSERT INTO analytics_dirty (source_id, kind, key)
+    SELECT e.source_id, 'person', new.canonical_email FROM events e
+    WHERE e.id = new.event_id
+      AND NOT EXISTS (SELECT 1 FROM analytics_dirty
+                      WHERE source_id = e.source_id AND kind = 'person' AND key = new.canonical_email);
+    INSERT INTO analytics_dirty (source_id, kind, key)
+    SELECT e.source_id, 'day', substr(e.start_time, 1, 10) FROM events e
+    WHERE e.id = new.event_id AND new.is_self AND e.start_time IS NOT NULL
+      AND NOT EXISTS (SELECT 1 FROM analytics_dirty
+                      WHERE source_id = e.source_id AND kind = 'day' AND key = substr(e.start_time, 1, 10));
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_attendees_analytics_delete AFTER DELETE ON attendees
+BEGIN
+    INSERT INTO analytics_dirty (source_id, kind, key)
+    SELECT e.source_id, 'person', old.canonical_email FROM events e
+    WHERE e.id = old.event_id
+      AND NOT EXISTS (SELECT 1 FROM analytics_dirty
+                      WHERE source_id = e.source_id AND kind = 'person' AND key = old.canonical_email);
+    INSERT INTO analytics_dirty (source_id, kind, key)
+    SELECT e.source_id, 'day', substr(e.start_time, 1, 10) FROM events e
+    WHERE e.id = old.event_id AND old.is_self AND e.start_time IS NOT NULL
+      AND NOT EXISTS (SELECT 1 FROM analytics_dirty
+                      WHERE source_id = e.source_id AND kind = 'day' AND key = substr(e.start_time, 1, 10));
+END;
+
+-- Indexes created by users (e.g. via explain-slow), kept apart from the
+-- built-in schema indexes so they can be listed and dropped safely.
+CREATE TABLE IF NOT EXISTS user_indexes (
+    name TEXT PRIMARY KEY,           -- Always prefixed with user_idx_
+    table_name TEXT NOT NULL,
+    columns TEXT NOT NULL,           -- Comma-separated column list
+    created_at DATETIME NOT NULL
+);
+
+-- Google Calendar push notification channels (events.watch), one per
+-- calendar. Renewed before expires_at by the daemon.
+CREATE TABLE IF NOT EXISTS watch_channels (
+    id INTEGER PRIMARY KEY,
+    source_id INTEGER NOT NULL REFERENCES sources(id),
+    calendar_id INTEGER NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    channel_id TEXT NOT NULL UNIQUE,  -- Our ID, echoed in X-Goog-Channel-ID
+    resource_id TEXT NOT NULL,        -- Google's ID for the watched resource, needed to stop the channel
+    token TEXT NOT NULL,              -- Shared secret, echoed in X-Goog-Channel-Token
+    address TEXT NOT NULL,            -- Webhook URL notifications are sent to
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_watch_channels_calendar ON watch_channels(calendar_id);
+
+-- API requests made by syncs per local day, for [sync] daily_request_budget
+CREATE TABLE IF NOT EXISTS api_usage (
+    day TEXT PRIMARY KEY,  -- YYYY-MM-DD
+    requests INTEGER NOT NULL DEFAULT 0
+);
+
+-- Order and estimated cost of an account's first full syncs, spread over
+-- several days by the request budget (sync-plan). Progress comes from the
+-- calendars' sync and page tokens.
+CREATE TABLE IF NOT EXISTS sync_plan (
+    source_id INTEGER NOT NULL REFERENCES sources(id),
+    google_calendar_id TEXT NOT NULL,
+    summary TEXT,
+    position INTEGER NOT NULL,
+    estimated_events INTEGER NOT NULL,
+    estimated_requests INTEGER NOT NULL,
+    requests INTEGER NOT NULL DEFAULT 0,  -- Full sync requests made so far
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (source_id, google_calendar_id)
+);
+
+-- Daemon alert rules ([[daemon.alert]]) and the matching events each has
+-- seen, as they were when last seen, so the next check after a sync can
+-- tell added, changed and cancelled events apart (see alerts.go).
+CREATE TABLE IF NOT EXISTS alert_rules (
+    name TEXT PRIMARY KEY,
+    created_at DATETIME NOT NULL  -- First checked; events matching then don't alert
+);
+
+CREATE TABLE IF NOT EXISTS alert_events (
+    rule TEXT NOT NULL REFERENCES alert_rules(name) ON DELETE CASCADE,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    summary TEXT,
+    location TEXT,
+    start_time DATETIME,
+    end_time DATETIME,
+    status TEXT,
+    alerted_at DATETIME,  -- Last alert sent for the event; NULL if none
+    PRIMARY KEY (rule, event_id)
+);
+CREATE INDEX IF NOT EXISTS idx_alert_events_event ON alert_events(event_id);
+
+-- Rows removed by destructive commands such as remove-account, kept until
+-- expires_at so 'calvault undo' can put them back (see trash.go). Each row
+-- is stored as a JSON object of its columns.
+CREATE TABLE IF NOT EXISTS trash_operations (
+    id INTEGER PRIMARY KEY,
+    command TEXT NOT NULL,      -- e.g. remove-account
+    description TEXT NOT NULL,  -- What was removed, e.g. the account's email
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS trash_rows (
+    id INTEGER PRIMARY KEY,
+    operation_id INTEGER NOT NULL REFERENCES trash_operations(id) ON DELETE CASCADE,
+    table_name TEXT NOT NULL,
+    row_data TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_trash_rows_operation ON trash_rows(operation_id, table_name);
@@ -0,0 +1,27 @@
+-- Full-text search over events (requires SQLite built with FTS5).
+-- External-content table: the text lives in events, the index in events_fts.
+CREATE VIRTUAL TABLE IF NOT EXISTS events_fts USING fts5(
+    summary, description, location,
+    content='events', content_rowid='id',
+    tokenize='porter unicode61'
+);
+
+CREATE TRIGGER IF NOT EXISTS trg_events_fts_insert AFTER INSERT ON events
+BEGIN
+    INSERT INTO events_fts (rowid, summary, description, location)
+    VALUES (new.id, new.summary, new.description, new.location);
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_events_fts_delete AFTER DELETE ON events
+BEGIN
+    INSERT INTO events_fts (events_fts, rowid, summary, description, location)
+    VALUES ('delete', old.id, old.summary, old.description, old.location);
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_events_fts_update AFTER UPDATE OF summary, description, location ON events
+BEGIN
+    INSERT INTO events_fts (events_fts, rowid, summary, description, location)
+    VALUES ('delete', old.id, old.summary, old.description, old.location);
+    INSERT INTO events_fts (rowid, summary, description, location)
+    VALUES (new.id, new.summary, new.description, new.location);
+END;
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SearchResult is an event matching a search, with a highlighted excerpt.
+type SearchResult struct {
+	Event   *Event
+	Snippet string
+}
+
+// SearchOptions restricts a search. Zero values are ignored.
+type SearchOptions struct {
+	SourceID int64
+	Limit    int
+}
+
+// ftsTriggers are the triggers that keep events_fts in sync with events.
+var ftsTriggers = []string{"trg_events_fts_insert", "trg_events_fts_delete", "trg_events_fts_update"}
+
+// fts5Compiled reports whether the SQLite library includes FTS5. With
+// mattn/go-sqlite3 this requires building with -tags sqlite_fts5.
+func (s *Store) fts5Compiled() (bool, error) {
+	var used bool
+	if err := s.db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&used); err != nil {
+		return false, fmt.Errorf("check fts5: %w", err)
+	}
+	return used, nil
+}
+
+// schemaObjectExists reports whether a table or trigger exists.
+func (s *Store) schemaObjectExists(typ, name string) (bool, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = ? AND name = ?`, typ, name).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("check %s %s: %w", typ, name, err)
+	}
+	return n > 0, nil
+}
+
+// initSearchIndex creates the events_fts index when FTS5 is available.
+// Builds without FTS5 drop the maintenance triggers (which would otherwise
+// break every event write); the index is rebuilt the next time a build with
+// FTS5 opens the database.
+func (s *Store) initSearchIndex() error {
+	compiled, err := s.fts5Compiled()
+	if err != nil {
+		return err
+	}
+	hasTriggers, err := s.schemaObjectExists("trigger", ftsTriggers[0])
+	if err != nil {
+		return err
+	}
+
+	if !compiled {
+		for _, name := range ftsTriggers {
+			if _, err := s.db.Exec(`DROP TRIGGER IF EXISTS ` + name); err != nil {
+				return fmt.Errorf("drop trigger %s: %w", name, err)
+			}
+		}
+		return nil
+	}
+
+	hasTable, err := s.schemaObjectExists("table", "events_fts")
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(ftsSchema); err != nil {
+		return err
+	}
+	if !hasTable || !hasTriggers {
+		return s.RebuildSearchIndex()
+	}
+	return nil
+}
+
+// SearchIndexAvailable reports whether full-text search is set up.
+func (s *Store) SearchIndexAvailable() (bool, error) {
+	compiled, err := s.fts5Compiled()
+	if err != nil || !compiled {
+		return false, err
+	}
+	return s.schemaObjectExists("trigger", ftsTriggers[0])
+}
+
+// RebuildSearchIndex repopulates events_fts from the events table.
+func (s *Store) RebuildSearchIndex() error {
+	if _, err := s.db.Exec(`INSERT INTO events_fts (events_fts) VALUES ('rebuild')`); err != nil {
+		return fmt.Errorf("rebuild search index: %w", err)
+	}
+	return nil
+}
+
+// SearchEvents finds events whose summary, description, or location match
+// query, best matches first. The query uses FTS5 syntax (terms, "phrases",
+// prefix*, OR, NOT). Without FTS5, it falls back to a case-insensitive
+// substring match on the whole query, most recent first.
+func (s *Store) SearchEvents(query string, opts SearchOptions) ([]*SearchResult, error) {
+	available, err := s.SearchIndexAvailable()
+	if err != nil {
+		return nil, err
+	}
+
+	var sqlQuery string
+	var args []interface{}
+	if available {
+		sqlQuery = `
+			SELECT ` + eventColumns + `, f.snip
+			FROM events
+			JOIN (
+				SELECT rowid, rank, snippet(events_fts, -1, '[', ']', '...', 12) AS snip
+				FROM events_fts WHERE events_fts MATCH ?
+			) f ON f.rowid = events.id
+			WHERE ` + notTombstoned
+		args = append(args, query)
+	} else {
+		pattern := "%" + escapeLike(query) + "%"
+		sqlQuery = `
+			SELECT ` + eventColumns + `, COALESCE(summary, '')
+			FROM events
+			WHERE (summary LIKE ? ESCAPE '\' OR description LIKE ? ESCAPE '\' OR location LIKE ? ESCAPE '\')
+			  AND ` + notTombstoned
+		args = append(args, pattern, pattern, pattern)
+	}
+
+	if opts.SourceID > 0 {
+		sqlQuery += ` AND events.source_id = ?`
+		args = append(args, opts.SourceID)
+	}
+	if available {
+		sqlQuery += ` ORDER BY f.rank, events.start_time DESC`
+	} else {
+		sqlQuery += ` ORDER BY start_time DESC`
+	}
+	if opts.Limit > 0 {
+		sqlQuery += ` LIMIT ?`
+		args = append(args, opts.Limit)
+	}
+
+	rows, err := s.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var results []*SearchResult
+	for rows.Next() {
+		var r SearchResult
+		e, err := scanEvent(withExtra(rows, &r.Snippet))
+		if err != nil {
+			return nil, err
+		}
+		r.Event = e
+		results = append(results, &r)
+	}
+
+	return results, rows.Err()
+}
+
+// extraScanner scans eventColumns followed by extra trailing columns.
+type extraScanner struct {
+	row   scanner
+	extra []interface{}
+}
+
+func withExtra(row scanner, extra ...interface{}) scanner {
+	return extraScanner{row: row, extra: extra}
+}
+
+func (x extraScanner) Scan(dest ...interface{}) error {
+	return x.row.Scan(append(dest, x.extra...)...)
+}
+
+// escapeLike escapes LIKE wildcards using backslash.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
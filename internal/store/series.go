@@ -0,0 +1,292 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SeriesHealth sums up how a recurring meeting series has been going.
+type SeriesHealth struct {
+	Key          string // The series' iCalendar UID, without a split suffix
+	Title        string // The latest occurrence's title
+	Organizer    string
+	First, Last  time.Time // Starts of the first and last held occurrences
+	Held         int       // Occurrences that weren't cancelled
+	Cancelled    int       // Occurrences cancelled individually
+	SelfDeclined int       // Held occurrences the account owner declined
+	Hours        float64   // Length of the held occurrences
+	Attendees    int       // Summed over held occurrences, rooms left out
+	Accepted     int       // Of those, attendees who accepted
+	Months       []SeriesMonth
+}
+
+// SeriesMonth is one month of a SeriesHealth, in the report's Months.
+type SeriesMonth struct {
+	Held, Cancelled, Attendees, Accepted int
+}
+
+// SeriesReport lists the recurring series met in a period.
+type SeriesReport struct {
+	Months []string // Every month (YYYY-MM) in the report's range
+	Series []*SeriesHealth
+}
+
+// CancelRate returns the share of scheduled occurrences that were
+// cancelled.
+func (h *SeriesHealth) CancelRate() float64 {
+	if h.Held+h.Cancelled == 0 {
+		return 0
+	}
+	return float64(h.Cancelled) / float64(h.Held+h.Cancelled)
+}
+
+// AverageAttendees returns the mean attendee count of held occurrences.
+func (h *SeriesHealth) AverageAttendees() float64 {
+	if h.Held == 0 {
+		return 0
+	}
+	return float64(h.Attendees) / float64(h.Held)
+}
+
+// AcceptRate returns the share of invitations to held occurrences that
+// were accepted.
+func (h *SeriesHealth) AcceptRate() float64 {
+	if h.Attendees == 0 {
+		return 0
+	}
+	return float64(h.Accepted) / float64(h.Attendees)
+}
+
+// DeclineRate returns the share of held occurrences the account owner
+// declined.
+func (h *SeriesHealth) DeclineRate() float64 {
+	if h.Held == 0 {
+		return 0
+	}
+	return float64(h.SelfDeclined) / float64(h.Held)
+}
+
+// AttendeeChange returns the average attendee count of the last months
+// with held occurrences minus that of the first ones, comparing the
+// first and last third of those months (at least one each). It is 0 for
+// series held in a single month.
+func (h *SeriesHealth) AttendeeChange() float64 {
+	var held []SeriesMonth
+	for _, m := range h.Months {
+		if m.Held > 0 {
+			held = append(held, m)
+		}
+	}
+	if len(held) < 2 {
+		return 0
+	}
+	n := max(1, len(held)/3)
+	average := func(months []SeriesMonth) float64 {
+		var attendees, occurrences int
+		for _, m := range months {
+			attendees += m.Attendees
+			occurrences += m.Held
+		}
+		return float64(attendees) / float64(occurrences)
+	}
+	return average(held[len(held)-n:]) - average(held[:n])
+}
+
+// splitSuffix is the "_R<date>" Google appends to a series' iCalendar UID
+// when it is split by editing "this and following events".
+var splitSuffix = regexp.MustCompile(`_R\d{8}(T\d{6}Z?)?(@|$)`)
+
+// seriesKey identifies a series across accounts and splits: its
+// iCalendar UID without a split suffix, or else its account and ID.
+func seriesKey(icalUID string, sourceID int64, recurringEventID string) string {
+	if icalUID == "" {
+		return fmt.Sprintf("%d:%s", sourceID, recurringEventID)
+	}
+	return splitSuffix.ReplaceAllString(icalUID, "$2")
+}
+
+// GetSeriesReport sums up the recurring series with occurrences starting in
+// [since, until): occurrences held (from event_instances) and cancelled
+// (stored cancelled instances), attendee counts and responses, and the
+// account owner's declines, overall and per month. A series held in several
+// accounts, or split into several by editing "this and following", counts
+// as one. An occurrence that wasn't changed individually has the series'
+// current attendees and responses. All-day series and quality-flagged
+// events are left out; series are ordered by hours held, most first.
+// Months are in since's location.
+func (s *Store) GetSeriesReport(since, until time.Time) (*SeriesReport, error) {
+	loc := since.Location()
+	report := &SeriesReport{}
+	month := map[string]int{}
+	for m := time.Date(since.Year(), since.Month(), 1, 0, 0, 0, 0, loc); m.Before(until); m = m.AddDate(0, 1, 0) {
+		month[m.Format("2006-01")] = len(report.Months)
+		report.Months = append(report.Months, m.Format("2006-01"))
+	}
+
+	series := map[string]*SeriesHealth{}
+	get := func(key string) *SeriesHealth {
+		h := series[key]
+		if h == nil {
+			h = &SeriesHealth{Key: key, Months: make([]SeriesMonth, len(report.Months))}
+			series[key] = h
+		}
+		return h
+	}
+
+	inRange, inRangeArgs := startsIn("i", since, until)
+	rows, err := s.db.Query(`
+		SELECT i.source_id, i.recurring_event_id, COALESCE(NULLIF(se.ical_uid, ''), e.ical_uid, ''),
+		       COALESCE(e.summary, ''), COALESCE(e.organizer_email, ''), i.start_time, i.end_time,
+		       (SELECT COUNT(*) FROM attendees a WHERE a.event_id = e.id
+		          AND a.email NOT LIKE '%@resource.calendar.google.com'),
+		       (SELECT COUNT(*) FROM attendees a WHERE a.event_id = e.id
+		          AND a.email NOT LIKE '%@resource.calendar.google.com' AND a.response_status = 'accepted'),
+		       EXISTS (SELECT 1 FROM attendees a WHERE a.event_id = e.id AND a.is_self AND a.response_status = 'declined')
+		FROM event_instances i
+		JOIN events e ON e.id = i.event_id
+		LEFT JOIN events se ON se.id = i.series_id
+		WHERE `+inRange+`
+		  AND i.all_day = FALSE AND i.end_time IS NOT NULL
+		  AND e.quality IS NULL
+		ORDER BY i.start_time
+	`, inRangeArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("query occurrences: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	seen := map[string]bool{}
+	for rows.Next() {
+		var sourceID int64
+		var recurringID, icalUID, title, organizer string
+		var start, end time.Time
+		var attendees, accepted int
+		var declined bool
+		if err := rows.Scan(&sourceID, &recurringID, &icalUID, &title, &organizer, &start, &end,
+			&attendees, &accepted, &declined); err != nil {
+			return nil, fmt.Errorf("scan occurrence: %w", err)
+		}
+		key := seriesKey(icalUID, sourceID, recurringID)
+		occurrence := key + "|" + start.UTC().Format(time.RFC3339)
+		if seen[occurrence] {
+			continue
+		}
+		seen[occurrence] = true
+
+		h := get(key)
+		if h.Held == 0 {
+			h.First = start
+		}
+		h.Last = start
+		if title != "" {
+			h.Title = title
+		}
+		if organizer != "" {
+			h.Organizer = organizer
+		}
+		h.Held++
+		h.Hours += end.Sub(start).Hours()
+		h.Attendees += attendees
+		h.Accepted += accepted
+		if declined {
+			h.SelfDeclined++
+		}
+		if i, ok := month[start.In(loc).Format("2006-01")]; ok {
+			h.Months[i].Held++
+			h.Months[i].Attendees += attendees
+			h.Months[i].Accepted += accepted
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query occurrences: %w", err)
+	}
+
+	if err := s.countCancelledOccurrences(since, until, month, series); err != nil {
+		return nil, err
+	}
+
+	for _, h := range series {
+		report.Series = append(report.Series, h)
+	}
+	sort.Slice(report.Series, func(i, j int) bool {
+		a, b := report.Series[i], report.Series[j]
+		if a.Hours != b.Hours {
+			return a.Hours > b.Hours
+		}
+		return a.Key < b.Key
+	})
+	return report, nil
+}
+
+// countCancelledOccurrences adds the cancelled instances in [since, until)
+// of the series held in that time to their SeriesHealth. Google often
+// leaves a cancelled instance's start out; its ID carries the occurrence's
+// original start.
+func (s *Store) countCancelledOccurrences(since, until time.Time, month map[string]int, series map[string]*SeriesHealth) error {
+	rows, err := s.db.Query(`
+		SELECT c.source_id, c.recurring_event_id, c.google_event_id,
+		       COALESCE(NULLIF(se.ical_uid, ''), c.ical_uid, ''), c.start_time
+		FROM events c
+		LEFT JOIN events se ON se.source_id = c.source_id AND se.google_event_id = c.recurring_event_id
+		WHERE c.status = 'cancelled' AND COALESCE(c.recurring_event_id, '') != ''
+		  AND c.all_day = FALSE
+	`)
+	if err != nil {
+		return fmt.Errorf("query cancelled occurrences: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	loc := since.Location()
+	seen := map[string]bool{}
+	for rows.Next() {
+		var sourceID int64
+		var recurringID, id, icalUID string
+		var start sql.NullTime
+		if err := rows.Scan(&sourceID, &recurringID, &id, &icalUID, &start); err != nil {
+			return fmt.Errorf("scan cancelled occurrence: %w", err)
+		}
+		t, ok := originalStart(id, recurringID)
+		if !ok {
+			if !start.Valid {
+				continue
+			}
+			t = start.Time
+		}
+		if t.Before(since) || !t.Before(until) {
+			continue
+		}
+		key := seriesKey(icalUID, sourceID, recurringID)
+		h := series[key]
+		occurrence := key + "|" + t.UTC().Format(time.RFC3339)
+		if h == nil || seen[occurrence] {
+			continue
+		}
+		seen[occurrence] = true
+		h.Cancelled++
+		if i, ok := month[t.In(loc).Format("2006-01")]; ok {
+			h.Months[i].Cancelled++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query cancelled occurrences: %w", err)
+	}
+	return nil
+}
+
+// originalStart parses the original start of an occurrence from the
+// "<series>_<recurrence-id>" IDs Google gives instances.
+func originalStart(id, recurringID string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(id, recurringID+"_")
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("20060102T150405Z", suffix)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// StatsSnapshot is the archive's size at one point in time, recorded in
+// stats_history.
+type StatsSnapshot struct {
+	ID          int64
+	RecordedAt  time.Time
+	Trigger     string // What recorded it: sync, daemon, import...
+	Accounts    int
+	Calendars   int
+	Events      int
+	DBSizeBytes int64
+	ByCalendar  []CalendarEventCount
+}
+
+// CalendarEventCount is a calendar's event count in a StatsSnapshot.
+type CalendarEventCount struct {
+	CalendarID int64
+	Account    string
+	Calendar   string
+	Events     int
+}
+
+// RecordStatsSnapshot records the archive's counts and the database's size
+// in stats_history. A snapshot whose counts match the latest one isn't
+// recorded, so frequent incremental syncs that change nothing don't fill
+// the history; it returns false then.
+func (s *Store) RecordStatsSnapshot(trigger string, dbSize int64) (bool, error) {
+	recorded := false
+	err := s.InTx(func(tx *Tx) error {
+		snap := &StatsSnapshot{RecordedAt: time.Now(), Trigger: trigger, DBSizeBytes: dbSize}
+		err := tx.tx.QueryRow(`
+			SELECT (SELECT COUNT(*) FROM sources), (SELECT COUNT(*) FROM calendars), (SELECT COUNT(*) FROM events)
+		`).Scan(&snap.Accounts, &snap.Calendars, &snap.Events)
+		if err != nil {
+			return fmt.Errorf("count archive: %w", err)
+		}
+		snap.ByCalendar, err = scanCalendarCounts(tx.tx.Query(`
+			SELECT c.id, s.identifier, COALESCE(NULLIF(c.summary, ''), c.google_calendar_id),
+				(SELECT COUNT(*) FROM events e WHERE e.calendar_id = c.id)
+			FROM calendars c JOIN sources s ON s.id = c.source_id
+			ORDER BY c.id
+		`))
+		if err != nil {
+			return err
+		}
+
+		var lastID int64
+		err = tx.tx.QueryRow(`SELECT id FROM stats_history ORDER BY id DESC LIMIT 1`).Scan(&lastID)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("get latest snapshot: %w", err)
+		}
+		if err == nil {
+			last, err := getStatsSnapshot(tx.tx, lastID)
+			if err != nil {
+				return err
+			}
+			if sameCounts(last, snap) {
+				return nil
+			}
+		}
+
+		res, err := tx.tx.Exec(`
+			INSERT INTO stats_history (recorded_at, trigger, accounts, calendars, events, db_size_bytes)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, snap.RecordedAt, trigger, snap.Accounts, snap.Calendars, snap.Events, dbSize)
+		if err != nil {
+			return fmt.Errorf("record stats snapshot: %w", err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		for _, c := range snap.ByCalendar {
+			_, err := tx.tx.Exec(`
+				INSERT INTO stats_history_calendars (snapshot_id, calendar_id, account, calendar, events)
+				VALUES (?, ?, ?, ?, ?)
+			`, id, c.CalendarID, c.Account, c.Calendar, c.Events)
+			if err != nil {
+				return fmt.Errorf("record calendar counts: %w", err)
+			}
+		}
+		recorded = true
+		return nil
+	})
+	return recorded, err
+}
+
+// sameCounts reports whether two snapshots count the same accounts,
+// calendars and events, calendar by calendar.
+func sameCounts(a, b *StatsSnapshot) bool {
+	if a.Accounts != b.Accounts || a.Calendars != b.Calendars || a.Events != b.Events || len(a.ByCalendar) != len(b.ByCalendar) {
+		return false
+	}
+	for i := range a.ByCalendar {
+		if a.ByCalendar[i].CalendarID != b.ByCalendar[i].CalendarID || a.ByCalendar[i].Events != b.ByCalendar[i].Events {
+			return false
+		}
+	}
+	return true
+}
+
+// ListStatsHistory returns the snapshots recorded since the given time
+// (all if zero), oldest first, with their per-calendar counts. With
+// limit > 0 only the latest limit snapshots are returned.
+func (s *Store) ListStatsHistory(since time.Time, limit int) ([]*StatsSnapshot, error) {
+	q := `SELECT id FROM stats_history WHERE recorded_at >= ? ORDER BY id DESC`
+	args := []any{since}
+	if limit > 0 {
+		q += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list stats history: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("list stats history: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list stats history: %w", err)
+	}
+
+	snaps := make([]*StatsSnapshot, len(ids))
+	for i, id := range ids {
+		snap, err := getStatsSnapshot(s.db, id)
+		if err != nil {
+			return nil, err
+		}
+		snaps[len(ids)-1-i] = snap
+	}
+	return snaps, nil
+}
+
+// getStatsSnapshot reads a snapshot with its per-calendar counts.
+func getStatsSnapshot(q execer, id int64) (*StatsSnapshot, error) {
+	snap := &StatsSnapshot{ID: id}
+	err := q.QueryRow(`
+		SELECT recorded_at, trigger, accounts, calendars, events, db_size_bytes
+		FROM stats_history WHERE id = ?
+	`, id).Scan(&snap.RecordedAt, &snap.Trigger, &snap.Accounts, &snap.Calendars, &snap.Events, &snap.DBSizeBytes)
+	if err != nil {
+		return nil, fmt.Errorf("get stats snapshot %d: %w", id, err)
+	}
+	snap.ByCalendar, err = scanCalendarCounts(q.Query(`
+		SELECT calendar_id, account, calendar, events FROM stats_history_calendars
+		WHERE snapshot_id = ? ORDER BY calendar_id
+	`, id))
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// scanCalendarCounts reads rows of calendar ID, account, calendar name and
+// event count.
+func scanCalendarCounts(rows *sql.Rows, err error) ([]CalendarEventCount, error) {
+	if err != nil {
+		return nil, fmt.Errorf("count events by calendar: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var counts []CalendarEventCount
+	for rows.Next() {
+		var c CalendarEventCount
+		if err := rows.Scan(&c.CalendarID, &c.Account, &c.Calendar, &c.Events); err != nil {
+			return nil, fmt.Errorf("count events by calendar: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
@@ -7,22 +7,34 @@ import (
 	"fmt"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 //go:embed schema.sql
 var schema string
 
+//go:embed schema_fts.sql
+var ftsSchema string
+
 // Store provides database operations for calendar data.
 type Store struct {
 	db *sql.DB
 }
 
+// execer is implemented by *sql.DB and *sql.Tx, so writes can run either
+// directly or as part of a Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 // Source represents a Google account.
 type Source struct {
 	ID         int64
 	SourceType string
 	Identifier string // email address
+	Owner      string // Team member the account belongs to; "" outside team vaults
 	CreatedAt  time.Time
 }
 
@@ -36,15 +48,35 @@ type Calendar struct {
 	Timezone         string
 	IsPrimary        bool
 	SyncToken        sql.NullString
+	PageToken        sql.NullString // Checkpoint of an interrupted full sync
 	LastSyncedAt     sql.NullTime
+	AccessRole       string // owner, writer, reader, freeBusyReader
+	SubscriptionKind string // One of the CalendarKind constants
+	Private          bool   // Hidden from other members of a team vault
+	ColorID          string // Google's calendar palette ID
+	BackgroundColor  string // Color as shown, e.g. #9fc6e7
+	// DefaultReminders replace the calendar's default reminders when
+	// upserted; nil leaves them untouched. Not loaded by GetCalendars.
+	DefaultReminders []*Reminder
 }
 
+// Calendar subscription kinds, distinguishing the user's own calendars from
+// ones they follow.
+const (
+	CalendarKindPrimary      = "primary"      // The account's main calendar
+	CalendarKindOwned        = "owned"        // Secondary calendars the user owns
+	CalendarKindShared       = "shared"       // Other people's calendars shared with the user
+	CalendarKindSubscription = "subscription" // Public feeds: holidays, sports, birthdays, subscribed URLs
+	CalendarKindImport       = "import"       // Imported from an .ics file
+)
+
 // Event represents a calendar event.
 type Event struct {
 	ID                int64
 	SourceID          int64
 	CalendarID        int64
 	GoogleEventID     string
+	ICalUID           string
 	Summary           string
 	Description       string
 	Location          string
@@ -56,6 +88,8 @@ type Event struct {
 	RecurrenceRule    string
 	Status            string
 	Visibility        string
+	ColorID           string // Event color 1-11 (EventColors); "" = the calendar's color
+	Quality           string // Quality flag set when stored (EventQuality); "" = plausible
 	OrganizerEmail    string
 	OrganizerName     string
 	CreatorEmail      string
@@ -91,11 +125,15 @@ type Stats struct {
 	LatestEvent     time.Time
 	UniqueLocations int
 	RecurringCount  int
+	Flagged         map[string]int // Events by quality flag (EventQuality)
 }
 
 // Open opens or creates the SQLite database at the given path.
 func Open(path string) (*Store, error) {
-	db, err := sql.Open("sqlite3", path+"?_foreign_keys=on&_journal_mode=WAL")
+	// Calendars may sync concurrently: writers wait for each other instead
+	// of failing with SQLITE_BUSY, and transactions take the write lock up
+	// front so two readers can't deadlock upgrading to writers.
+	db, err := sql.Open("sqlite3", path+"?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=10000&_txlock=immediate")
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
@@ -119,30 +157,91 @@ func (s *Store) DB() *sql.DB {
 	return s.db
 }
 
+// CopyTo writes a consistent, compacted copy of the database to path, which
+// must not exist. Writers may keep going while it runs.
+func (s *Store) CopyTo(path string) error {
+	if _, err := s.db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("copy database: %w", err)
+	}
+	return nil
+}
+
+// SchemaVersion is recorded in the database's user_version by InitSchema.
+// Bump it with every migration, so restore can tell a backup from a
+// database with a newer schema. Databases from before it was kept have 0.
+const SchemaVersion = 3
+
 // InitSchema creates the database tables if they don't exist.
 func (s *Store) InitSchema() error {
 	_, err := s.db.Exec(schema)
 	if err != nil {
 		return fmt.Errorf("init schema: %w", err)
 	}
+	if err := s.migrate(); err != nil {
+		return fmt.Errorf("migrate schema: %w", err)
+	}
+	if err := s.initSearchIndex(); err != nil {
+		return fmt.Errorf("init search index: %w", err)
+	}
+	// A newer calvault may have opened the database; keep its version
+	version, err := schemaVersion(s.db)
+	if err != nil {
+		return err
+	}
+	if version < SchemaVersion {
+		if _, err := s.db.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, SchemaVersion)); err != nil {
+			return fmt.Errorf("set schema version: %w", err)
+		}
+	}
 	return nil
 }
 
-// GetOrCreateSource returns an existing source or creates a new one.
+// schemaVersion returns the SchemaVersion recorded in a database.
+func schemaVersion(db *sql.DB) (int, error) {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("get schema version: %w", err)
+	}
+	return version, nil
+}
+
+// StoredSchemaVersion returns the SchemaVersion recorded in the database,
+// which may be older or newer than this build's.
+func (s *Store) StoredSchemaVersion() (int, error) {
+	return schemaVersion(s.db)
+}
+
+// Source types.
+const (
+	SourceTypeGoogle    = "google"
+	SourceTypeICS       = "ics"
+	SourceTypeMicrosoft = "microsoft"
+)
+
+// GetOrCreateSource returns an existing Google source or creates a new one.
 func (s *Store) GetOrCreateSource(email string) (*Source, error) {
+	return s.GetOrCreateSourceOfType(SourceTypeGoogle, email)
+}
+
+// GetOrCreateSourceOfType returns an existing source or creates a new one
+// with the given source type.
+func (s *Store) GetOrCreateSourceOfType(sourceType, identifier string) (*Source, error) {
 	// Try to get existing source
-	source, err := s.GetSourceByIdentifier(email)
+	source, err := s.GetSourceByIdentifier(identifier)
 	if err != nil {
 		return nil, err
 	}
 	if source != nil {
+		if source.SourceType != sourceType {
+			return nil, fmt.Errorf("source %q already exists with type %s", identifier, source.SourceType)
+		}
 		return source, nil
 	}
 
 	// Create new source
 	result, err := s.db.Exec(
-		`INSERT INTO sources (source_type, identifier) VALUES ('google', ?)`,
-		email,
+		`INSERT INTO sources (source_type, identifier) VALUES (?, ?)`,
+		sourceType, identifier,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("insert source: %w", err)
@@ -155,8 +254,8 @@ func (s *Store) GetOrCreateSource(email string) (*Source, error) {
 
 	return &Source{
 		ID:         id,
-		SourceType: "google",
-		Identifier: email,
+		SourceType: sourceType,
+		Identifier: identifier,
 		CreatedAt:  time.Now(),
 	}, nil
 }
@@ -164,12 +263,12 @@ func (s *Store) GetOrCreateSource(email string) (*Source, error) {
 // GetSourceByIdentifier returns a source by email address.
 func (s *Store) GetSourceByIdentifier(email string) (*Source, error) {
 	row := s.db.QueryRow(
-		`SELECT id, source_type, identifier, created_at FROM sources WHERE identifier = ?`,
+		`SELECT id, source_type, identifier, COALESCE(owner, ''), created_at FROM sources WHERE identifier = ?`,
 		email,
 	)
 
 	var source Source
-	err := row.Scan(&source.ID, &source.SourceType, &source.Identifier, &source.CreatedAt)
+	err := row.Scan(&source.ID, &source.SourceType, &source.Identifier, &source.Owner, &source.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -183,7 +282,7 @@ func (s *Store) GetSourceByIdentifier(email string) (*Source, error) {
 // ListSources returns all sources.
 func (s *Store) ListSources() ([]*Source, error) {
 	rows, err := s.db.Query(
-		`SELECT id, source_type, identifier, created_at FROM sources ORDER BY identifier`,
+		`SELECT id, source_type, identifier, COALESCE(owner, ''), created_at FROM sources ORDER BY identifier`,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("query sources: %w", err)
@@ -193,7 +292,7 @@ func (s *Store) ListSources() ([]*Source, error) {
 	var sources []*Source
 	for rows.Next() {
 		var source Source
-		if err := rows.Scan(&source.ID, &source.SourceType, &source.Identifier, &source.CreatedAt); err != nil {
+		if err := rows.Scan(&source.ID, &source.SourceType, &source.Identifier, &source.Owner, &source.CreatedAt); err != nil {
 			return nil, fmt.Errorf("scan source: %w", err)
 		}
 		sources = append(sources, &source)
@@ -205,14 +304,20 @@ func (s *Store) ListSources() ([]*Source, error) {
 // UpsertCalendar inserts or updates a calendar.
 func (s *Store) UpsertCalendar(sourceID int64, cal *Calendar) (int64, error) {
 	result, err := s.db.Exec(`
-		INSERT INTO calendars (source_id, google_calendar_id, summary, description, timezone, is_primary)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO calendars (source_id, google_calendar_id, summary, description, timezone, is_primary,
+		                       access_role, subscription_kind, color_id, background_color)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(source_id, google_calendar_id) DO UPDATE SET
 			summary = excluded.summary,
 			description = excluded.description,
 			timezone = excluded.timezone,
-			is_primary = excluded.is_primary
-	`, sourceID, cal.GoogleCalendarID, cal.Summary, cal.Description, cal.Timezone, cal.IsPrimary)
+			is_primary = excluded.is_primary,
+			access_role = excluded.access_role,
+			subscription_kind = excluded.subscription_kind,
+			color_id = excluded.color_id,
+			background_color = excluded.background_color
+	`, sourceID, cal.GoogleCalendarID, cal.Summary, cal.Description, cal.Timezone, cal.IsPrimary,
+		nullString(cal.AccessRole), nullString(cal.SubscriptionKind), nullString(cal.ColorID), nullString(cal.BackgroundColor))
 	if err != nil {
 		return 0, fmt.Errorf("upsert calendar: %w", err)
 	}
@@ -226,6 +331,11 @@ func (s *Store) UpsertCalendar(sourceID int64, cal *Calendar) (int64, error) {
 	if err != nil {
 		return 0, fmt.Errorf("get calendar id: %w", err)
 	}
+	if cal.DefaultReminders != nil {
+		if err := replaceCalendarReminders(s.db, id, cal.DefaultReminders); err != nil {
+			return 0, err
+		}
+	}
 
 	_ = result // Suppress unused variable warning
 	return id, nil
@@ -234,8 +344,7 @@ func (s *Store) UpsertCalendar(sourceID int64, cal *Calendar) (int64, error) {
 // GetCalendars returns all calendars for a source.
 func (s *Store) GetCalendars(sourceID int64) ([]*Calendar, error) {
 	rows, err := s.db.Query(`
-		SELECT id, source_id, google_calendar_id, summary, description, timezone, 
-		       is_primary, sync_token, last_synced_at
+		SELECT `+calendarColumns+`
 		FROM calendars WHERE source_id = ?
 		ORDER BY is_primary DESC, summary
 	`, sourceID)
@@ -246,14 +355,11 @@ func (s *Store) GetCalendars(sourceID int64) ([]*Calendar, error) {
 
 	var calendars []*Calendar
 	for rows.Next() {
-		var cal Calendar
-		if err := rows.Scan(
-			&cal.ID, &cal.SourceID, &cal.GoogleCalendarID, &cal.Summary,
-			&cal.Description, &cal.Timezone, &cal.IsPrimary, &cal.SyncToken, &cal.LastSyncedAt,
-		); err != nil {
-			return nil, fmt.Errorf("scan calendar: %w", err)
+		cal, err := scanCalendar(rows)
+		if err != nil {
+			return nil, err
 		}
-		calendars = append(calendars, &cal)
+		calendars = append(calendars, cal)
 	}
 
 	return calendars, rows.Err()
@@ -261,8 +367,14 @@ func (s *Store) GetCalendars(sourceID int64) ([]*Calendar, error) {
 
 // UpdateCalendarSyncToken updates the sync token for a calendar.
 func (s *Store) UpdateCalendarSyncToken(calID int64, token string) error {
-	_, err := s.db.Exec(
-		`UPDATE calendars SET sync_token = ?, last_synced_at = ? WHERE id = ?`,
+	return updateCalendarSyncToken(s.db, calID, token)
+}
+
+// updateCalendarSyncToken saves the sync token and ends any checkpointed
+// full sync, whose page token is no longer needed.
+func updateCalendarSyncToken(db execer, calID int64, token string) error {
+	_, err := db.Exec(
+		`UPDATE calendars SET sync_token = ?, page_token = NULL, last_synced_at = ? WHERE id = ?`,
 		token, time.Now(), calID,
 	)
 	if err != nil {
@@ -283,63 +395,122 @@ func (s *Store) ClearCalendarSyncToken(calID int64) error {
 	return nil
 }
 
+// GetCalendarListToken returns the source's calendar list sync token, or ""
+// if the calendar list hasn't been checked for changes yet.
+func (s *Store) GetCalendarListToken(sourceID int64) (string, error) {
+	var token string
+	err := s.db.QueryRow(
+		`SELECT COALESCE(calendar_list_token, '') FROM sources WHERE id = ?`,
+		sourceID,
+	).Scan(&token)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("get calendar list token: %w", err)
+	}
+	return token, nil
+}
+
+// SetCalendarListToken saves the source's calendar list sync token; an
+// empty token clears it.
+func (s *Store) SetCalendarListToken(sourceID int64, token string) error {
+	_, err := s.db.Exec(
+		`UPDATE sources SET calendar_list_token = NULLIF(?, '') WHERE id = ?`,
+		token, sourceID,
+	)
+	if err != nil {
+		return fmt.Errorf("set calendar list token: %w", err)
+	}
+	return nil
+}
+
 // UpsertEvent inserts or updates an event.
 func (s *Store) UpsertEvent(event *Event) (int64, error) {
-	result, err := s.db.Exec(`
-		INSERT INTO events (
-			source_id, calendar_id, google_event_id, summary, description, location,
-			start_time, end_time, all_day, original_timezone,
-			recurring_event_id, recurrence_rule, status, visibility,
-			organizer_email, organizer_name, creator_email,
-			created_at, updated_at, synced_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(source_id, google_event_id) DO UPDATE SET
-			calendar_id = excluded.calendar_id,
-			summary = excluded.summary,
-			description = excluded.description,
-			location = excluded.location,
-			start_time = excluded.start_time,
-			end_time = excluded.end_time,
-			all_day = excluded.all_day,
-			original_timezone = excluded.original_timezone,
-			recurring_event_id = excluded.recurring_event_id,
-			recurrence_rule = excluded.recurrence_rule,
-			status = excluded.status,
-			visibility = excluded.visibility,
-			organizer_email = excluded.organizer_email,
-			organizer_name = excluded.organizer_name,
-			creator_email = excluded.creator_email,
-			updated_at = excluded.updated_at,
-			synced_at = excluded.synced_at
-	`,
-		event.SourceID, event.CalendarID, event.GoogleEventID,
+	return upsertEvent(s.db, event)
+}
+
+func upsertEvent(db execer, event *Event) (int64, error) {
+	var id int64
+	if err := db.QueryRow(upsertEventSQL, upsertEventArgs(event)...).Scan(&id); err != nil {
+		return 0, fmt.Errorf("upsert event: %w", err)
+	}
+	return id, nil
+}
+
+// upsertEventSQL inserts or updates an event keyed by (source_id,
+// google_event_id) and returns its ID either way.
+const upsertEventSQL = `
+	INSERT INTO events (
+		source_id, calendar_id, google_event_id, ical_uid, summary, description, location,
+		start_time, end_time, all_day, original_timezone,
+		recurring_event_id, recurrence_rule, status, visibility, color_id, quality,
+		organizer_email, organizer_name, creator_email,
+		created_at, updated_at, synced_at
+	) VALUES (?, ?, ?, NULLIF(?, ''), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(source_id, google_event_id) DO UPDATE SET
+		calendar_id = excluded.calendar_id,
+		ical_uid = excluded.ical_uid,
+		summary = excluded.summary,
+		description = excluded.description,
+		location = excluded.location,
+		start_time = excluded.start_time,
+		end_time = excluded.end_time,
+		all_day = excluded.all_day,
+		original_timezone = excluded.original_timezone,
+		recurring_event_id = excluded.recurring_event_id,
+		recurrence_rule = excluded.recurrence_rule,
+		status = excluded.status,
+		visibility = excluded.visibility,
+		color_id = excluded.color_id,
+		quality = excluded.quality,
+		organizer_email = excluded.organizer_email,
+		organizer_name = excluded.organizer_name,
+		creator_email = excluded.creator_email,
+		updated_at = excluded.updated_at,
+		synced_at = excluded.synced_at
+	RETURNING id`
+
+// upsertEventArgs returns the arguments for upsertEventSQL, flagging
+// implausible dates with EventQuality.
+func upsertEventArgs(event *Event) []interface{} {
+	now := time.Now()
+	return []interface{}{
+		event.SourceID, event.CalendarID, event.GoogleEventID, event.ICalUID,
 		event.Summary, event.Description, event.Location,
 		event.StartTime, event.EndTime, event.AllDay, event.OriginalTimezone,
-		event.RecurringEventID, event.RecurrenceRule, event.Status, event.Visibility,
+		event.RecurringEventID, event.RecurrenceRule, event.Status, event.Visibility, event.ColorID,
+		EventQuality(event, now),
 		event.OrganizerEmail, event.OrganizerName, event.CreatorEmail,
-		event.CreatedAt, event.UpdatedAt, time.Now(),
-	)
-	if err != nil {
-		return 0, fmt.Errorf("upsert event: %w", err)
+		event.CreatedAt, event.UpdatedAt, now,
 	}
+}
+
+// EventExists reports whether an event with the given google_event_id is stored.
+func (s *Store) EventExists(sourceID int64, googleEventID string) (bool, error) {
+	return eventExists(s.db, sourceID, googleEventID)
+}
 
-	// Get the ID
+func eventExists(db execer, sourceID int64, googleEventID string) (bool, error) {
 	var id int64
-	err = s.db.QueryRow(
+	err := db.QueryRow(
 		`SELECT id FROM events WHERE source_id = ? AND google_event_id = ?`,
-		event.SourceID, event.GoogleEventID,
+		sourceID, googleEventID,
 	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
 	if err != nil {
-		return 0, fmt.Errorf("get event id: %w", err)
+		return false, fmt.Errorf("check event: %w", err)
 	}
-
-	_ = result
-	return id, nil
+	return true, nil
 }
 
-// DeleteEvent deletes an event by google_event_id.
+// DeleteEvent deletes an event by google_event_id. Under legal hold the
+// event is kept and a tombstone recorded instead (see SetLegalHold).
 func (s *Store) DeleteEvent(sourceID int64, googleEventID string) error {
-	_, err := s.db.Exec(
+	return deleteEvent(s.db, sourceID, googleEventID)
+}
+
+func deleteEvent(db execer, sourceID int64, googleEventID string) error {
+	_, err := db.Exec(
 		`DELETE FROM events WHERE source_id = ? AND google_event_id = ?`,
 		sourceID, googleEventID,
 	)
@@ -364,29 +535,66 @@ func (s *Store) GetEventCount(sourceID int64) (int64, error) {
 
 // ReplaceAttendees replaces all attendees for an event.
 func (s *Store) ReplaceAttendees(eventID int64, attendees []*Attendee) error {
-	tx, err := s.db.Begin()
+	return s.InTx(func(tx *Tx) error {
+		return tx.ReplaceAttendees(eventID, attendees)
+	})
+}
+
+// insertAttendeeSQL adds an attendee to an event, with its CanonicalEmail,
+// or its person's email if it's an alias in people; an alias of the same
+// person as the event's account, whose accountEmail is ?8, is is_self. Providers sometimes list one
+// twice, or with differently cased emails; the copies are merged into one
+// row, keeping either's organizer and self flags.
+const insertAttendeeSQL = `
+	INSERT INTO attendees (event_id, email, canonical_email, display_name, response_status, is_organizer, is_self)
+	VALUES (?1, ?2, COALESCE((SELECT person_email FROM people WHERE email = ?3), ?3), ?4, ?5, ?6,
+	        ?7 OR EXISTS (SELECT 1 FROM people owner
+	                      JOIN people p ON p.person_email = owner.person_email
+	                      WHERE owner.email = ?8 AND p.email = ?3))
+	ON CONFLICT(event_id, lower(email)) DO UPDATE SET
+		display_name = COALESCE(NULLIF(excluded.display_name, ''), display_name),
+		response_status = excluded.response_status,
+		is_organizer = is_organizer OR excluded.is_organizer,
+		is_self = is_self OR excluded.is_self`
+
+func replaceAttendees(db execer, eventID int64, attendees []*Attendee) error {
+	var sourceID int64
+	if err := db.QueryRow(`SELECT source_id FROM events WHERE id = ?`, eventID).Scan(&sourceID); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("read event source: %w", err)
+	}
+	owner, err := accountEmail(db, sourceID)
 	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
+		return err
 	}
-	defer func() { _ = tx.Rollback() }()
 
 	// Delete existing attendees
-	if _, err := tx.Exec(`DELETE FROM attendees WHERE event_id = ?`, eventID); err != nil {
+	if _, err := db.Exec(`DELETE FROM attendees WHERE event_id = ?`, eventID); err != nil {
 		return fmt.Errorf("delete attendees: %w", err)
 	}
 
 	// Insert new attendees
 	for _, a := range attendees {
-		_, err := tx.Exec(`
-			INSERT INTO attendees (event_id, email, display_name, response_status, is_organizer, is_self)
-			VALUES (?, ?, ?, ?, ?, ?)
-		`, eventID, a.Email, a.DisplayName, a.ResponseStatus, a.IsOrganizer, a.IsSelf)
+		_, err := db.Exec(insertAttendeeSQL, eventID, a.Email, CanonicalEmail(a.Email), a.DisplayName, a.ResponseStatus, a.IsOrganizer, a.IsSelf, owner)
 		if err != nil {
 			return fmt.Errorf("insert attendee: %w", err)
 		}
 	}
 
-	return tx.Commit()
+	return nil
+}
+
+// accountEmail returns the CanonicalEmail of a source's account, as the
+// people table keys its aliases, or "" for no such source.
+func accountEmail(db execer, sourceID int64) (string, error) {
+	var identifier string
+	err := db.QueryRow(`SELECT identifier FROM sources WHERE id = ?`, sourceID).Scan(&identifier)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("read source identifier: %w", err)
+	}
+	return CanonicalEmail(identifier), nil
 }
 
 // StartSyncRun creates a new sync run record.
@@ -449,18 +657,46 @@ func (s *Store) GetStats() (*Stats, error) {
 	// Calendar count
 	_ = s.db.QueryRow(`SELECT COUNT(*) FROM calendars`).Scan(&stats.CalendarCount)
 
-	// Event count
-	_ = s.db.QueryRow(`SELECT COUNT(*) FROM events`).Scan(&stats.EventCount)
+	// Event count, leaving out events tombstoned under legal hold here and below
+	_ = s.db.QueryRow(`SELECT COUNT(*) FROM events WHERE ` + notTombstoned).Scan(&stats.EventCount)
 
-	// Date range
-	_ = s.db.QueryRow(`SELECT MIN(start_time) FROM events WHERE start_time IS NOT NULL`).Scan(&stats.EarliestEvent)
-	_ = s.db.QueryRow(`SELECT MAX(start_time) FROM events WHERE start_time IS NOT NULL`).Scan(&stats.LatestEvent)
+	// Date range of plausibly dated events; MIN and MAX come back as strings
+	var earliest, latest sql.NullString
+	_ = s.db.QueryRow(`SELECT MIN(start_time), MAX(start_time) FROM events WHERE start_time IS NOT NULL AND quality IS NULL AND ` + notTombstoned).Scan(&earliest, &latest)
+	if earliest.Valid && latest.Valid {
+		var err error
+		if stats.EarliestEvent, err = parseTimestamp(earliest.String); err != nil {
+			return nil, fmt.Errorf("get stats: %w", err)
+		}
+		if stats.LatestEvent, err = parseTimestamp(latest.String); err != nil {
+			return nil, fmt.Errorf("get stats: %w", err)
+		}
+	}
 
 	// Unique locations
-	_ = s.db.QueryRow(`SELECT COUNT(DISTINCT location) FROM events WHERE location IS NOT NULL AND location != ''`).Scan(&stats.UniqueLocations)
+	_ = s.db.QueryRow(`SELECT COUNT(DISTINCT location) FROM events WHERE location IS NOT NULL AND location != '' AND ` + notTombstoned).Scan(&stats.UniqueLocations)
 
 	// Recurring events
-	_ = s.db.QueryRow(`SELECT COUNT(*) FROM events WHERE recurring_event_id IS NOT NULL AND recurring_event_id != ''`).Scan(&stats.RecurringCount)
+	_ = s.db.QueryRow(`SELECT COUNT(*) FROM events WHERE recurring_event_id IS NOT NULL AND recurring_event_id != '' AND ` + notTombstoned).Scan(&stats.RecurringCount)
+
+	// Events with implausible dates, by flag
+	flagged, err := s.FlaggedEventCounts()
+	if err != nil {
+		return nil, fmt.Errorf("get stats: %w", err)
+	}
+	stats.Flagged = flagged
 
 	return stats, nil
 }
+
+// parseTimestamp parses a timestamp string as stored by the sqlite3 driver.
+// Aggregates such as MIN/MAX lose the DATETIME column type, so the driver
+// returns them as plain strings.
+func parseTimestamp(value string) (time.Time, error) {
+	for _, format := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("parse timestamp %q", value)
+}
@@ -1,9 +1,17 @@
 package store
 
 import (
+	"compress/gzip"
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -79,6 +87,8 @@ func TestStore_CalendarUpsert(t *testing.T) {
 		Summary:          "My Calendar",
 		Timezone:         "America/New_York",
 		IsPrimary:        true,
+		AccessRole:       "owner",
+		SubscriptionKind: CalendarKindPrimary,
 	}
 
 	// Insert
@@ -105,6 +115,536 @@ func TestStore_CalendarUpsert(t *testing.T) {
 	if cals[0].Summary != "Updated Name" {
 		t.Errorf("summary = %q, want Updated Name", cals[0].Summary)
 	}
+	if cals[0].AccessRole != "owner" || cals[0].SubscriptionKind != CalendarKindPrimary {
+		t.Errorf("access_role, subscription_kind = %q, %q, want owner, primary", cals[0].AccessRole, cals[0].SubscriptionKind)
+	}
+}
+
+func TestStore_MigrateAddsColumns(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	// Simulate a database created before the calendar-list metadata columns.
+	for _, col := range []string{"access_role", "subscription_kind"} {
+		if _, err := s.db.Exec(`ALTER TABLE calendars DROP COLUMN ` + col); err != nil {
+			t.Fatalf("drop %s: %v", col, err)
+		}
+	}
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("re-init schema: %v", err)
+	}
+
+	src, _ := s.GetOrCreateSource("test@example.com")
+	if _, err := s.UpsertCalendar(src.ID, &Calendar{
+		GoogleCalendarID: "en.usa#holiday@group.v.calendar.google.com",
+		AccessRole:       "reader",
+		SubscriptionKind: CalendarKindSubscription,
+	}); err != nil {
+		t.Fatalf("upsert calendar after migration: %v", err)
+	}
+}
+
+func TestStore_DerivedColumns(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	// Simulate a database created before the derived columns
+	for _, stmt := range []string{
+		`DROP INDEX idx_events_start_date`,
+		`DROP INDEX idx_events_weekday_hour`,
+		`ALTER TABLE events DROP COLUMN duration_minutes`,
+		`ALTER TABLE events DROP COLUMN start_date`,
+		`ALTER TABLE events DROP COLUMN start_hour`,
+		`ALTER TABLE events DROP COLUMN weekday`,
+	} {
+		if _, err := s.db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("re-init schema: %v", err)
+	}
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	berlin := time.FixedZone("CET", 3600)
+	// Sunday 23:30 in Berlin is Sunday evening for the event, not Monday
+	start := time.Date(2024, 3, 3, 23, 30, 0, 0, berlin)
+	at := func(t time.Time) sql.NullTime { return sql.NullTime{Time: t, Valid: true} }
+	_, _ = s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "late", StartTime: at(start), EndTime: at(start.Add(90 * time.Minute))})
+	day := time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC)
+	_, _ = s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "offsite", AllDay: true, StartTime: at(day), EndTime: at(day.AddDate(0, 0, 2))})
+
+	rows, err := s.db.Query(`SELECT google_event_id, duration_minutes, start_date, start_hour, weekday FROM events ORDER BY google_event_id`)
+	if err != nil {
+		t.Fatalf("query derived columns: %v", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var got []string
+	for rows.Next() {
+		var id, date string
+		var minutes float64
+		var hour sql.NullInt64
+		var weekday int
+		if err := rows.Scan(&id, &minutes, &date, &hour, &weekday); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		got = append(got, fmt.Sprint(id, " ", minutes, " ", date, " ", hour.Int64, hour.Valid, " ", weekday))
+	}
+	want := []string{"late 90 2024-03-03 23 true 0", "offsite 2880 2024-03-06 0 false 3"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("derived columns = %q, want %q", got, want)
+	}
+}
+
+func TestStore_MigrateEntityExtractions(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	eventID, _ := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "e1"})
+
+	// Simulate a database from before enrich_state
+	_, err := s.db.Exec(`
+		CREATE TABLE entity_extractions (
+			event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+			extractor TEXT NOT NULL,
+			extracted_at DATETIME NOT NULL,
+			PRIMARY KEY (event_id, extractor)
+		)`)
+	if err != nil {
+		t.Fatalf("create entity_extractions: %v", err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO entity_extractions VALUES (?, 'llm', ?)`, eventID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("insert extraction: %v", err)
+	}
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("re-init schema: %v", err)
+	}
+
+	var n int
+	_ = s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'entity_extractions'`).Scan(&n)
+	if n != 0 {
+		t.Error("entity_extractions still exists")
+	}
+	// The event the llm extractor processed isn't processed again
+	for stage, want := range map[string]int{"entities:llm": 0, "entities:rules": 1} {
+		events, err := s.EventsToEnrich(stage, 0)
+		if err != nil {
+			t.Fatalf("events to enrich: %v", err)
+		}
+		if len(events) != want {
+			t.Errorf("%s: %d events to enrich, want %d", stage, len(events), want)
+		}
+	}
+}
+
+func TestStore_MigrateForeignKeyCascades(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	// Simulate a database created before calendar deletions cascaded, with
+	// orphans left behind while foreign keys were off.
+	s.db.SetMaxOpenConns(1)
+	events, _ := tableDefinition("events")
+	attendees, _ := tableDefinition("attendees")
+	legacy := []string{
+		`PRAGMA foreign_keys = OFF`,
+		strings.ReplaceAll(schema[:strings.Index(schema, "-- Events")], "IF NOT EXISTS ", ""),
+		strings.Replace(events, " ON DELETE CASCADE", "", 1),
+		attendees,
+		`INSERT INTO sources (id, identifier) VALUES (1, 'test@example.com')`,
+		`INSERT INTO calendars (id, source_id, google_calendar_id) VALUES (1, 1, 'primary')`,
+		`INSERT INTO events (id, source_id, calendar_id, google_event_id) VALUES (1, 1, 1, 'kept'), (2, 1, 99, 'orphan')`,
+		`INSERT INTO attendees (event_id, email, canonical_email) VALUES (1, 'a@example.com', 'a@example.com'), (2, 'b@example.com', 'b@example.com')`,
+		`PRAGMA foreign_keys = ON`,
+	}
+	for _, stmt := range legacy {
+		if _, err := s.db.Exec(stmt); err != nil {
+			t.Fatalf("create legacy schema: %v", err)
+		}
+	}
+	s.db.SetMaxOpenConns(0)
+
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	var onDelete string
+	_ = s.db.QueryRow(`SELECT on_delete FROM pragma_foreign_key_list('events') WHERE "from" = 'calendar_id'`).Scan(&onDelete)
+	if onDelete != "CASCADE" {
+		t.Errorf("events.calendar_id on delete = %q, want CASCADE", onDelete)
+	}
+	count := func(query string) int {
+		var n int
+		if err := s.db.QueryRow(query).Scan(&n); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return n
+	}
+	if n := count(`SELECT COUNT(*) FROM events`); n != 1 {
+		t.Errorf("events after migration = %d, want 1 (orphan removed)", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM attendees`); n != 1 {
+		t.Errorf("attendees after migration = %d, want 1", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND tbl_name = 'events'`); n == 0 {
+		t.Error("events triggers not recreated")
+	}
+
+	// Deleting a calendar now removes its events and their attendees.
+	if _, err := s.db.Exec(`DELETE FROM calendars WHERE id = 1`); err != nil {
+		t.Fatalf("delete calendar: %v", err)
+	}
+	if n := count(`SELECT COUNT(*) FROM events`) + count(`SELECT COUNT(*) FROM attendees`); n != 0 {
+		t.Errorf("%d rows left after deleting calendar, want 0", n)
+	}
+}
+
+func TestStore_CleanupOrphans(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("test@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	eventID, _ := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "evt1"})
+	_ = s.ReplaceAttendees(eventID, []*Attendee{{Email: "a@example.com"}})
+	runID, _ := s.StartSyncRun(src.ID, calID)
+
+	// Orphan the event and sync run with foreign keys off.
+	conn, err := s.db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("conn: %v", err)
+	}
+	for _, stmt := range []string{
+		`PRAGMA foreign_keys = OFF`,
+		fmt.Sprintf(`DELETE FROM calendars WHERE id = %d`, calID),
+		`PRAGMA foreign_keys = ON`,
+	} {
+		if _, err := conn.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	_ = conn.Close()
+
+	violations, err := s.ForeignKeyViolations()
+	if err != nil {
+		t.Fatalf("foreign key violations: %v", err)
+	}
+	if violations["events"] != 1 || violations["sync_runs"] != 1 {
+		t.Errorf("violations = %v, want an event and a sync run", violations)
+	}
+
+	c, err := s.CleanupOrphans()
+	if err != nil {
+		t.Fatalf("cleanup orphans: %v", err)
+	}
+	// The event's attendee goes with it by cascade.
+	want := OrphanCleanup{Events: 1, SyncRuns: 1}
+	if *c != want {
+		t.Errorf("cleanup = %+v, want %+v", *c, want)
+	}
+	if violations, err := s.ForeignKeyViolations(); err != nil || len(violations) != 0 {
+		t.Errorf("violations after cleanup = %v (%v)", violations, err)
+	}
+	if problems, err := s.CheckIntegrity(true); err != nil || len(problems) != 0 {
+		t.Errorf("integrity problems = %v (%v)", problems, err)
+	}
+	var attendees int
+	_ = s.db.QueryRow(`SELECT COUNT(*) FROM attendees`).Scan(&attendees)
+	if attendees != 0 {
+		t.Errorf("attendees after cleanup = %d, want 0", attendees)
+	}
+
+	var calendarID sql.NullInt64
+	_ = s.db.QueryRow(`SELECT calendar_id FROM sync_runs WHERE id = ?`, runID).Scan(&calendarID)
+	if calendarID.Valid {
+		t.Errorf("sync run calendar_id = %d, want NULL", calendarID.Int64)
+	}
+
+	if c, _ := s.CleanupOrphans(); c.Total() != 0 {
+		t.Errorf("second cleanup = %+v, want nothing", *c)
+	}
+}
+
+func TestStore_DeleteSource(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	start := sql.NullTime{Time: time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC), Valid: true}
+	end := sql.NullTime{Time: start.Time.Add(time.Hour), Valid: true}
+	var sources []*Source
+	for _, email := range []string{"gone@example.com", "kept@example.com"} {
+		src, _ := s.GetOrCreateSource(email)
+		calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+		eventID, _ := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "evt1", StartTime: start, EndTime: end})
+		_ = s.ReplaceAttendees(eventID, []*Attendee{{Email: "a@example.com"}, {Email: "b@example.com"}})
+		_, _ = s.StartSyncRun(src.ID, calID)
+		_, err := s.SaveWatchChannel(&WatchChannel{SourceID: src.ID, CalendarID: calID, ChannelID: "ch-" + email, ResourceID: "res", ExpiresAt: time.Now().Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("save watch channel: %v", err)
+		}
+		sources = append(sources, src)
+	}
+	if _, err := s.RefreshAnalyticsTables(); err != nil {
+		t.Fatalf("refresh analytics: %v", err)
+	}
+
+	var r *SourceRemoval
+	err := s.InTx(func(tx *Tx) error {
+		var err error
+		r, err = tx.DeleteSource(sources[0].ID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("delete source: %v", err)
+	}
+	want := SourceRemoval{Calendars: 1, Events: 1, Attendees: 2, SyncRuns: 1, WatchChannels: 1}
+	if *r != want {
+		t.Errorf("removal = %+v, want %+v", *r, want)
+	}
+
+	if src, _ := s.GetSourceByIdentifier("gone@example.com"); src != nil {
+		t.Error("deleted source still exists")
+	}
+	for _, table := range []string{"calendars", "events", "sync_runs", "watch_channels", "daily_meeting_minutes", "person_meeting_counts", "analytics_dirty"} {
+		var n int
+		_ = s.db.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE source_id = ?`, sources[0].ID).Scan(&n)
+		if n != 0 {
+			t.Errorf("%s rows for deleted source = %d, want 0", table, n)
+		}
+	}
+
+	// The other account is untouched.
+	var attendees, people int
+	_ = s.db.QueryRow(`SELECT COUNT(*) FROM attendees`).Scan(&attendees)
+	_ = s.db.QueryRow(`SELECT COUNT(*) FROM person_meeting_counts WHERE source_id = ?`, sources[1].ID).Scan(&people)
+	if attendees != 2 || people != 2 {
+		t.Errorf("kept account has %d attendees and %d people, want 2 and 2", attendees, people)
+	}
+}
+
+func TestStore_TrashSource(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	start := sql.NullTime{Time: time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC), Valid: true}
+	end := sql.NullTime{Time: start.Time.Add(time.Hour), Valid: true}
+	src, _ := s.GetOrCreateSource("gone@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary", Summary: "Gone", DefaultReminders: []*Reminder{{Method: "popup", Minutes: 10}}})
+	eventID, _ := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "evt1", Summary: "Review", StartTime: start, EndTime: end})
+	_ = s.ReplaceAttendees(eventID, []*Attendee{{Email: "a@example.com"}, {Email: "b@example.com", IsSelf: true}})
+	_, _ = s.StartSyncRun(src.ID, calID)
+
+	counts := func() string {
+		var out []string
+		for _, table := range []string{"sources", "calendars", "events", "attendees", "reminders", "sync_runs"} {
+			var n int
+			_ = s.db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n)
+			out = append(out, fmt.Sprintf("%s=%d", table, n))
+		}
+		return strings.Join(out, " ")
+	}
+	before := counts()
+
+	remove := func(keep time.Duration) int64 {
+		t.Helper()
+		var opID int64
+		err := s.InTx(func(tx *Tx) error {
+			var err error
+			if opID, err = tx.TrashSource(src.ID, "remove-account", src.Identifier, keep); err != nil {
+				return err
+			}
+			_, err = tx.DeleteSource(src.ID)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("trash source: %v", err)
+		}
+		return opID
+	}
+	opID := remove(24 * time.Hour)
+	if got, want := counts(), "sources=0 calendars=0 events=0 attendees=0 reminders=0 sync_runs=0"; got != want {
+		t.Errorf("after removal: %s, want %s", got, want)
+	}
+	ops, err := s.ListTrash()
+	if err != nil || len(ops) != 1 || ops[0].ID != opID || ops[0].Description != "gone@example.com" || ops[0].Rows != 7 {
+		t.Fatalf("ListTrash() = %+v, %v; want operation %d with 7 rows", ops, err, opID)
+	}
+
+	// An account added again in the meantime blocks the restore
+	again, _ := s.GetOrCreateSource("gone@example.com")
+	if _, err := s.RestoreTrash(opID); err == nil {
+		t.Error("restore over a re-added account succeeded")
+	}
+	_ = s.InTx(func(tx *Tx) error { _, err := tx.DeleteSource(again.ID); return err })
+
+	op, err := s.RestoreTrash(opID)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if op.Rows != 7 {
+		t.Errorf("restored %d rows, want 7", op.Rows)
+	}
+	if got := counts(); got != before {
+		t.Errorf("after restore: %s, want %s", got, before)
+	}
+	events, _ := s.ListEvents(EventFilter{SourceID: src.ID})
+	if len(events) != 1 || events[0].ID != eventID || events[0].Summary != "Review" || !events[0].StartTime.Time.Equal(start.Time) {
+		t.Errorf("restored events = %+v, want event %d", events, eventID)
+	}
+	if _, err := s.RestoreTrash(opID); !errors.Is(err, ErrTrashNotFound) {
+		t.Errorf("second restore error = %v, want ErrTrashNotFound", err)
+	}
+
+	// Expired operations can't be restored and are purged
+	opID = remove(-time.Minute)
+	if _, err := s.RestoreTrash(opID); !errors.Is(err, ErrTrashNotFound) {
+		t.Errorf("restore of expired operation error = %v, want ErrTrashNotFound", err)
+	}
+	if n, err := s.PurgeExpiredTrash(); err != nil || n != 1 {
+		t.Errorf("PurgeExpiredTrash() = %d, %v; want 1", n, err)
+	}
+	var rows int
+	_ = s.db.QueryRow(`SELECT COUNT(*) FROM trash_rows`).Scan(&rows)
+	if rows != 0 {
+		t.Errorf("trash rows after purge = %d, want 0", rows)
+	}
+}
+
+func TestStore_TrashSourceAfterStatsSnapshot(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary", Summary: "Work"})
+	_, _ = s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "evt1", Summary: "Review"})
+	// A sync or import records a snapshot counting the calendar
+	if _, err := s.RecordStatsSnapshot("sync", 0); err != nil {
+		t.Fatalf("record snapshot: %v", err)
+	}
+
+	var opID int64
+	err := s.InTx(func(tx *Tx) error {
+		var err error
+		if opID, err = tx.TrashSource(src.ID, "remove-account", src.Identifier, time.Hour); err != nil {
+			return err
+		}
+		_, err = tx.DeleteSource(src.ID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("remove account: %v", err)
+	}
+	if _, err := s.RestoreTrash(opID); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+
+	if got, err := s.GetSourceByIdentifier("me@example.com"); err != nil || got == nil || got.ID != src.ID {
+		t.Errorf("restored source = %+v, %v; want source %d", got, err, src.ID)
+	}
+	if n, _ := s.GetEventCount(src.ID); n != 1 {
+		t.Errorf("restored events = %d, want 1", n)
+	}
+	var calendars int
+	_ = s.db.QueryRow(`SELECT COUNT(*) FROM stats_history_calendars WHERE calendar_id = ?`, calID).Scan(&calendars)
+	if calendars != 1 {
+		t.Errorf("snapshot calendar rows = %d, want 1", calendars)
+	}
+}
+
+func TestStore_ListAccountSummaries(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	synced, _ := s.GetOrCreateSource("synced@example.com")
+	calID, _ := s.UpsertCalendar(synced.ID, &Calendar{GoogleCalendarID: "primary"})
+	for _, id := range []string{"evt1", "evt2"} {
+		_, _ = s.UpsertEvent(&Event{SourceID: synced.ID, CalendarID: calID, GoogleEventID: id})
+	}
+	runID, _ := s.StartSyncRun(synced.ID, calID)
+	if err := s.CompleteSyncRun(runID, SyncStats{EventsAdded: 2}); err != nil {
+		t.Fatalf("complete sync run: %v", err)
+	}
+	runID, _ = s.StartSyncRun(synced.ID, calID)
+	_ = s.FailSyncRun(runID, "quota exceeded")
+	_, _ = s.GetOrCreateSource("new@example.com")
+
+	accounts, err := s.ListAccountSummaries()
+	if err != nil {
+		t.Fatalf("list accounts: %v", err)
+	}
+	if len(accounts) != 2 {
+		t.Fatalf("got %d accounts, want 2", len(accounts))
+	}
+
+	fresh, a := accounts[0], accounts[1]
+	if fresh.Identifier != "new@example.com" || fresh.Events != 0 || !fresh.LastSync.IsZero() || fresh.LastStatus != "" {
+		t.Errorf("unsynced account = %+v, want no events or sync runs", fresh)
+	}
+	if a.Identifier != "synced@example.com" || a.Calendars != 1 || a.Events != 2 {
+		t.Errorf("synced account = %+v, want 1 calendar and 2 events", a)
+	}
+	if a.LastSync.IsZero() || time.Since(a.LastSync) > time.Minute {
+		t.Errorf("last sync = %v, want just now", a.LastSync)
+	}
+	if a.LastStatus != "failed" || a.LastError != "quota exceeded" {
+		t.Errorf("last run = %q (%q), want failed (quota exceeded)", a.LastStatus, a.LastError)
+	}
+}
+
+func TestStore_SyncRuns(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	me, _ := s.GetOrCreateSource("me@example.com")
+	work, _ := s.GetOrCreateSource("work@example.com")
+	start := time.Now().Add(-time.Hour)
+	runs := []struct {
+		sourceID int64
+		run      *SyncRun
+	}{
+		{me.ID, &SyncRun{Kind: "full", Status: SyncCompleted, Added: 5}},
+		{work.ID, &SyncRun{Kind: "incremental", Status: SyncFailed, Error: "quota exceeded"}},
+		{me.ID, &SyncRun{Kind: "incremental", Status: SyncDeferred, Updated: 2}},
+	}
+	for i, r := range runs {
+		r.run.StartedAt = start.Add(time.Duration(i) * time.Minute)
+		r.run.CompletedAt = sql.NullTime{Time: r.run.StartedAt.Add(30 * time.Second), Valid: true}
+		if err := s.RecordSyncRun(r.sourceID, r.run); err != nil {
+			t.Fatalf("record sync run: %v", err)
+		}
+	}
+
+	all, err := s.ListSyncRuns(0, 0)
+	if err != nil {
+		t.Fatalf("list sync runs: %v", err)
+	}
+	var got []string
+	for _, r := range all {
+		got = append(got, fmt.Sprintf("%s %s %s +%d ~%d %q %s", r.Account, r.Kind, r.Status, r.Added, r.Updated, r.Error, r.Duration()))
+	}
+	want := []string{
+		`me@example.com incremental deferred +0 ~2 "" 30s`,
+		`work@example.com incremental failed +0 ~0 "quota exceeded" 30s`,
+		`me@example.com full completed +5 ~0 "" 30s`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("runs =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	mine, err := s.ListSyncRuns(me.ID, 1)
+	if err != nil {
+		t.Fatalf("list sync runs: %v", err)
+	}
+	if len(mine) != 1 || mine[0].Status != SyncDeferred {
+		t.Errorf("latest run of me@example.com = %+v, want the deferred one", mine)
+	}
 }
 
 func TestStore_EventUpsertAndDelete(t *testing.T) {
@@ -170,6 +710,85 @@ func TestStore_EventUpsertAndDelete(t *testing.T) {
 	}
 }
 
+func TestStore_MergeImportedCopies(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ics, _ := s.GetOrCreateSourceOfType(SourceTypeICS, "local")
+	icsCal, _ := s.UpsertCalendar(ics.ID, &Calendar{GoogleCalendarID: "home"})
+	imported := []*Event{
+		{SourceID: ics.ID, CalendarID: icsCal, GoogleEventID: "standup@x", ICalUID: "standup@x", RecurrenceRule: "RRULE:FREQ=DAILY"},
+		{SourceID: ics.ID, CalendarID: icsCal, GoogleEventID: "standup@x_20240306T090000Z", ICalUID: "standup@x", RecurringEventID: "standup@x"},
+		{SourceID: ics.ID, CalendarID: icsCal, GoogleEventID: "dentist@x", ICalUID: "dentist@x"},
+	}
+	for _, e := range imported {
+		if _, err := s.UpsertEvent(e); err != nil {
+			t.Fatalf("upsert %s: %v", e.GoogleEventID, err)
+		}
+	}
+
+	// Imported events are never canonical
+	if id, err := s.APIEventByICalUID("standup@x"); err != nil || id != 0 {
+		t.Errorf("APIEventByICalUID before sync = %d, %v; want 0", id, err)
+	}
+
+	google, _ := s.GetOrCreateSource("me@example.com")
+	googleCal, _ := s.UpsertCalendar(google.ID, &Calendar{GoogleCalendarID: "primary"})
+	synced := []*Event{
+		{SourceID: google.ID, CalendarID: googleCal, GoogleEventID: "g1", ICalUID: "standup@x", RecurrenceRule: "RRULE:FREQ=DAILY"},
+		{SourceID: google.ID, CalendarID: googleCal, GoogleEventID: "g1_20240306T090000Z", ICalUID: "standup@x", RecurringEventID: "g1"},
+		{SourceID: google.ID, CalendarID: googleCal, GoogleEventID: "g2", ICalUID: "lunch@x"},
+	}
+	var merged int
+	err := s.InTx(func(tx *Tx) error {
+		for _, e := range synced {
+			id, err := tx.UpsertEvent(e)
+			if err != nil {
+				return err
+			}
+			e.ID = id
+		}
+		var err error
+		merged, err = tx.MergeImportedCopies(synced)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if merged != 2 {
+		t.Errorf("merged = %d, want 2 (the series and its instance)", merged)
+	}
+	if n, _ := s.GetEventCount(ics.ID); n != 1 {
+		t.Errorf("imported events left = %d, want 1", n)
+	}
+
+	var eventID int64
+	var externalID string
+	if err := s.DB().QueryRow(`SELECT event_id, external_id FROM event_provenance WHERE source_id = ?`, ics.ID).Scan(&eventID, &externalID); err != nil {
+		t.Fatalf("query provenance: %v", err)
+	}
+	if eventID != synced[0].ID || externalID != "standup@x" {
+		t.Errorf("provenance = event %d, %q; want event %d, standup@x", eventID, externalID, synced[0].ID)
+	}
+	if id, err := s.APIEventByICalUID("standup@x"); err != nil || id != synced[0].ID {
+		t.Errorf("APIEventByICalUID = %d, %v; want %d", id, err, synced[0].ID)
+	}
+
+	// Provenance goes with the imported source
+	err = s.InTx(func(tx *Tx) error {
+		_, err := tx.DeleteSource(ics.ID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("delete source: %v", err)
+	}
+	var n int
+	_ = s.DB().QueryRow(`SELECT COUNT(*) FROM event_provenance`).Scan(&n)
+	if n != 0 {
+		t.Errorf("provenance rows after deleting source = %d, want 0", n)
+	}
+}
+
 func TestStore_Attendees(t *testing.T) {
 	s, cleanup := setupTestStore(t)
 	defer cleanup()
@@ -248,6 +867,23 @@ func TestStore_SyncToken(t *testing.T) {
 	if cals[0].SyncToken.Valid {
 		t.Error("expected sync token to be cleared")
 	}
+
+	// Calendar list token
+	if token, err := s.GetCalendarListToken(src.ID); err != nil || token != "" {
+		t.Errorf("calendar list token = %q, %v; want none", token, err)
+	}
+	if err := s.SetCalendarListToken(src.ID, "list-1"); err != nil {
+		t.Fatalf("set calendar list token: %v", err)
+	}
+	if token, _ := s.GetCalendarListToken(src.ID); token != "list-1" {
+		t.Errorf("calendar list token = %q, want list-1", token)
+	}
+	if err := s.SetCalendarListToken(src.ID, ""); err != nil {
+		t.Fatalf("clear calendar list token: %v", err)
+	}
+	if token, _ := s.GetCalendarListToken(src.ID); token != "" {
+		t.Errorf("calendar list token = %q after clearing, want none", token)
+	}
 }
 
 func TestStore_Stats(t *testing.T) {
@@ -264,6 +900,7 @@ func TestStore_Stats(t *testing.T) {
 	}
 
 	// Add some data
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
 	src, _ := s.GetOrCreateSource("test@example.com")
 	calID, _ := s.UpsertCalendar(src.ID, &Calendar{
 		GoogleCalendarID: "primary",
@@ -275,7 +912,7 @@ func TestStore_Stats(t *testing.T) {
 		GoogleEventID: "evt1",
 		Summary:       "Event 1",
 		Location:      "Office",
-		StartTime:     sql.NullTime{Time: time.Now(), Valid: true},
+		StartTime:     sql.NullTime{Time: start, Valid: true},
 	})
 	_, _ = s.UpsertEvent(&Event{
 		SourceID:      src.ID,
@@ -283,7 +920,7 @@ func TestStore_Stats(t *testing.T) {
 		GoogleEventID: "evt2",
 		Summary:       "Event 2",
 		Location:      "Home",
-		StartTime:     sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+		StartTime:     sql.NullTime{Time: start.Add(time.Hour), Valid: true},
 	})
 
 	stats, _ = s.GetStats()
@@ -299,4 +936,2853 @@ func TestStore_Stats(t *testing.T) {
 	if stats.UniqueLocations != 2 {
 		t.Errorf("unique locations = %d, want 2", stats.UniqueLocations)
 	}
+	if !stats.EarliestEvent.Equal(start) || !stats.LatestEvent.Equal(start.Add(time.Hour)) {
+		t.Errorf("date range = %v to %v, want %v to %v", stats.EarliestEvent, stats.LatestEvent, start, start.Add(time.Hour))
+	}
+}
+
+func TestStore_CountEvents(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("test@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary", Summary: "Test"})
+	base := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC) // Monday
+	for i, day := range []int{-1, 0, 2, 6, 7} {
+		e := &Event{
+			SourceID:      src.ID,
+			CalendarID:    calID,
+			GoogleEventID: fmt.Sprintf("evt%d", i),
+			StartTime:     sql.NullTime{Time: base.AddDate(0, 0, day), Valid: true},
+		}
+		switch i {
+		case 1:
+			e.RecurrenceRule = "RRULE:FREQ=DAILY"
+		case 2:
+			e.RecurringEventID = "evt1"
+		case 3:
+			e.Location, e.Status = "Room 4B", "tentative"
+		case 4:
+			e.Status = "cancelled"
+		}
+		id, err := s.UpsertEvent(e)
+		if err != nil {
+			t.Fatalf("upsert event: %v", err)
+		}
+		if i == 4 {
+			_ = s.ReplaceAttendees(id, []*Attendee{{Email: "dana@example.com", DisplayName: "Dana Scully"}})
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		want   int64
+	}{
+		{"all", EventFilter{}, 5},
+		{"week", EventFilter{Since: base.Truncate(24 * time.Hour), Until: base.AddDate(0, 0, 7).Truncate(24 * time.Hour)}, 3},
+		{"since", EventFilter{Since: base}, 4},
+		{"other calendar", EventFilter{CalendarID: calID + 1}, 0},
+		{"series", EventFilter{SeriesOnly: true}, 1},
+		{"instances", EventFilter{RecurringEventID: "evt1"}, 1},
+		{"attendee email", EventFilter{Attendee: "DANA@"}, 1},
+		{"attendee name", EventFilter{Attendee: "scully"}, 1},
+		{"location", EventFilter{Location: "room 4"}, 1},
+		{"location wildcard is literal", EventFilter{Location: "%"}, 0},
+		{"confirmed includes no status", EventFilter{Status: "confirmed"}, 3},
+		{"tentative", EventFilter{Status: "tentative"}, 1},
+	}
+	for _, tt := range tests {
+		got, err := s.CountEvents(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: count events: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: count = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStore_ListEventsAllDay(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("test@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary", Summary: "Test"})
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("no tz database: %v", err)
+	}
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	day := func(m time.Month, d int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2024, m, d, 0, 0, 0, 0, time.UTC), Valid: true}
+	}
+	for _, e := range []*Event{
+		{GoogleEventID: "holiday", AllDay: true, StartTime: day(3, 4), EndTime: day(3, 5)},
+		// Spans the switch to daylight saving time in Los Angeles on March 10
+		{GoogleEventID: "trip", AllDay: true, StartTime: day(3, 10), EndTime: day(3, 13)},
+		{GoogleEventID: "dinner", StartTime: sql.NullTime{Time: time.Date(2024, 3, 4, 18, 0, 0, 0, la), Valid: true}},
+	} {
+		e.SourceID, e.CalendarID = src.ID, calID
+		if _, err := s.UpsertEvent(e); err != nil {
+			t.Fatalf("upsert event: %v", err)
+		}
+	}
+
+	at := func(loc *time.Location, d, hour int) time.Time {
+		return time.Date(2024, 3, d, hour, 0, 0, 0, loc)
+	}
+	tests := []struct {
+		name         string
+		since, until time.Time
+		want         []string
+	}{
+		{"day with negative offset", at(la, 4, 0), at(la, 5, 0), []string{"holiday", "dinner"}},
+		{"day before", at(la, 3, 0), at(la, 4, 0), nil},
+		{"since the afternoon", at(la, 4, 12), at(la, 5, 0), []string{"dinner"}},
+		{"until the morning", at(la, 4, 0), at(la, 4, 9), []string{"holiday"}},
+		{"day DST starts", at(la, 10, 0), at(la, 11, 0), []string{"trip"}},
+		{"day after DST started", at(la, 11, 0), at(la, 12, 0), nil},
+		{"day with positive offset", at(tokyo, 10, 0), at(tokyo, 11, 0), []string{"trip"}},
+	}
+	for _, tt := range tests {
+		events, err := s.ListEvents(EventFilter{Since: tt.since, Until: tt.until})
+		if err != nil {
+			t.Fatalf("%s: list events: %v", tt.name, err)
+		}
+		var got []string
+		for _, e := range events {
+			got = append(got, e.GoogleEventID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: events = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStore_ReportsAllDayBounds(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("no tz database: %v", err)
+	}
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	day := func(d int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC), Valid: true}
+	}
+	late := time.Date(2024, 3, 4, 23, 30, 0, 0, la) // March 5 in UTC
+	offsite, _ := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "offsite", Summary: "Offsite",
+		AllDay: true, StartTime: day(1), EndTime: day(2), RecurrenceRule: "RRULE:FREQ=DAILY"})
+	for _, e := range []*Event{
+		{GoogleEventID: "holiday", Summary: "Holiday", AllDay: true, StartTime: day(4), EndTime: day(5)},
+		{GoogleEventID: "late", Summary: "Late call", StartTime: sql.NullTime{Time: late, Valid: true},
+			EndTime: sql.NullTime{Time: late.Add(30 * time.Minute), Valid: true}},
+	} {
+		e.SourceID, e.CalendarID = src.ID, calID
+		if _, err := s.UpsertEvent(e); err != nil {
+			t.Fatalf("upsert event: %v", err)
+		}
+	}
+	err = s.ReplaceEventInstances([]*EventInstance{
+		{SourceID: src.ID, RecurringEventID: "offsite", SeriesID: sql.NullInt64{Int64: offsite, Valid: true}, EventID: offsite,
+			Start: day(4), End: day(5), AllDay: true, Expanded: true},
+	})
+	if err != nil {
+		t.Fatalf("instances: %v", err)
+	}
+
+	// All-day events are stored at UTC midnight, which is the evening
+	// before in Los Angeles; they still belong to their own date there
+	tests := []struct {
+		name         string
+		since, until time.Time
+		notes        []string
+		meetings     int
+	}{
+		{"their day", time.Date(2024, 3, 4, 0, 0, 0, 0, la), time.Date(2024, 3, 5, 0, 0, 0, 0, la),
+			[]string{"Offsite", "Holiday", "Late call"}, 1},
+		{"the day before", time.Date(2024, 3, 3, 0, 0, 0, 0, la), time.Date(2024, 3, 4, 0, 0, 0, 0, la), nil, 0},
+		{"the day after", time.Date(2024, 3, 5, 0, 0, 0, 0, la), time.Date(2024, 3, 6, 0, 0, 0, 0, la), nil, 0},
+	}
+	for _, tt := range tests {
+		candidates, err := s.NoteCandidates(tt.since, tt.until)
+		if err != nil {
+			t.Fatalf("%s: note candidates: %v", tt.name, err)
+		}
+		var notes []string
+		for _, c := range candidates {
+			notes = append(notes, c.Summary)
+		}
+		if !reflect.DeepEqual(notes, tt.notes) {
+			t.Errorf("%s: note candidates = %v, want %v", tt.name, notes, tt.notes)
+		}
+
+		rhythm, err := s.GetMeetingRhythm(tt.since, tt.until)
+		if err != nil {
+			t.Fatalf("%s: meeting rhythm: %v", tt.name, err)
+		}
+		meetings := 0
+		for _, n := range rhythm.Weekdays {
+			meetings += n
+		}
+		if meetings != tt.meetings {
+			t.Errorf("%s: meetings = %d, want %d", tt.name, meetings, tt.meetings)
+		}
+	}
+}
+
+func TestStore_DurationReport(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("test@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{
+		GoogleCalendarID: "primary",
+		Summary:          "Test",
+	})
+
+	base := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	events := []struct {
+		id        string
+		minutes   int
+		recurring string
+		allDay    bool
+	}{
+		{"e1", 15, "", false},
+		{"e2", 30, "", false},
+		{"e3", 45, "series1", false},
+		{"e4", 120, "", false},
+		{"e5", 1440, "", true}, // all-day, excluded
+	}
+	for i, e := range events {
+		start := base.Add(time.Duration(i) * 24 * time.Hour)
+		_, err := s.UpsertEvent(&Event{
+			SourceID:         src.ID,
+			CalendarID:       calID,
+			GoogleEventID:    e.id,
+			StartTime:        sql.NullTime{Time: start, Valid: true},
+			EndTime:          sql.NullTime{Time: start.Add(time.Duration(e.minutes) * time.Minute), Valid: true},
+			AllDay:           e.allDay,
+			RecurringEventID: e.recurring,
+			Status:           "confirmed",
+		})
+		if err != nil {
+			t.Fatalf("upsert event %s: %v", e.id, err)
+		}
+	}
+
+	report, err := s.GetDurationReport(time.Time{})
+	if err != nil {
+		t.Fatalf("get duration report: %v", err)
+	}
+
+	wantAdHoc := []int{1, 1, 0, 0, 1}
+	wantRecurring := []int{0, 0, 1, 0, 0}
+	for i, b := range report.Buckets {
+		if b.AdHoc != wantAdHoc[i] || b.Recurring != wantRecurring[i] {
+			t.Errorf("bucket %s = %d ad-hoc/%d recurring, want %d/%d",
+				b.Label, b.AdHoc, b.Recurring, wantAdHoc[i], wantRecurring[i])
+		}
+	}
+
+	if report.AdHoc.Count != 3 {
+		t.Errorf("ad-hoc count = %d, want 3", report.AdHoc.Count)
+	}
+	if report.AdHoc.MedianMinutes != 30 {
+		t.Errorf("ad-hoc median = %v, want 30", report.AdHoc.MedianMinutes)
+	}
+	if report.AdHoc.P90Minutes != 120 {
+		t.Errorf("ad-hoc p90 = %v, want 120", report.AdHoc.P90Minutes)
+	}
+	if report.Recurring.Count != 1 {
+		t.Errorf("recurring count = %d, want 1", report.Recurring.Count)
+	}
+	if len(report.Trend) != 1 || report.Trend[0].Month != "2024-03" {
+		t.Errorf("trend = %+v, want single 2024-03 entry", report.Trend)
+	}
+}
+
+func TestStore_ListContacts(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{
+		GoogleCalendarID: "primary",
+		Summary:          "Test",
+	})
+
+	first := time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC)
+	last := time.Date(2024, 6, 20, 9, 0, 0, 0, time.UTC)
+	attendees := [][]*Attendee{
+		{{Email: "Alice@Example.com", DisplayName: "Alice 0"}, {Email: "bob@example.com", DisplayName: "Bob"}},
+		{{Email: "alice+lists@example.com"}, {Email: "robert@corp.example.com", DisplayName: "Robert"}},
+		{{Email: "Alice@Example.com", DisplayName: "Alice 2"}},
+	}
+	for i, start := range []time.Time{first, first.AddDate(0, 2, 0), last} {
+		eventID, err := s.UpsertEvent(&Event{
+			SourceID:      src.ID,
+			CalendarID:    calID,
+			GoogleEventID: fmt.Sprintf("evt%d", i),
+			StartTime:     sql.NullTime{Time: start, Valid: true},
+		})
+		if err != nil {
+			t.Fatalf("upsert event: %v", err)
+		}
+		list := append([]*Attendee{{Email: "me@example.com", IsSelf: true}}, attendees[i]...)
+		if err := s.ReplaceAttendees(eventID, list); err != nil {
+			t.Fatalf("replace attendees: %v", err)
+		}
+	}
+	err := s.SetIdentities([]Identity{{Name: "Bob Jones", Emails: []string{"bob@example.com", "robert@corp.example.com"}}})
+	if err != nil {
+		t.Fatalf("set identities: %v", err)
+	}
+
+	contacts, err := s.ListContacts()
+	if err != nil {
+		t.Fatalf("list contacts: %v", err)
+	}
+	var got []string
+	for _, c := range contacts {
+		got = append(got, fmt.Sprintf("%s %q %d %s..%s", c.Email, c.DisplayName, c.MeetingCount,
+			c.FirstSeen.Format(time.DateOnly), c.LastSeen.Format(time.DateOnly)))
+	}
+	// Case, +tags and identity aliases fold into one contact each, named
+	// by their identity or most recent name
+	want := []string{
+		`alice@example.com "Alice 2" 3 2024-01-10..2024-06-20`,
+		`bob@example.com "Bob Jones" 2 2024-01-10..2024-03-10`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("contacts = %q, want %q", got, want)
+	}
+}
+
+func TestStore_QueryAudit(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	base := time.Now().Add(-time.Hour)
+	entries := []*QueryAudit{
+		{ExecutedAt: base, Caller: "cli", SQL: "SELECT 1", Duration: 5 * time.Millisecond, RowCount: 1},
+		{ExecutedAt: base.Add(time.Minute), Caller: "agent", QueryName: "visits", SQL: "SELECT :x", Params: `{"x":"1"}`},
+		{ExecutedAt: base.Add(2 * time.Minute), Caller: "agent", SQL: "DELETE FROM events", ErrorMessage: "only SELECT queries allowed"},
+	}
+	for _, e := range entries {
+		if err := s.RecordQueryAudit(e); err != nil {
+			t.Fatalf("record audit: %v", err)
+		}
+	}
+
+	all, err := s.ListQueryAudit(AuditFilter{})
+	if err != nil {
+		t.Fatalf("list audit: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(all))
+	}
+	if all[0].ErrorMessage == "" || all[2].Duration != 5*time.Millisecond {
+		t.Errorf("entries not ordered most recent first: %+v", all)
+	}
+
+	agent, _ := s.ListQueryAudit(AuditFilter{Caller: "agent", Limit: 1})
+	if len(agent) != 1 || agent[0].Caller != "agent" {
+		t.Errorf("filtered entries = %+v, want 1 agent entry", agent)
+	}
+}
+
+func TestStore_DataVersion(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	version := func() int64 {
+		t.Helper()
+		v, err := s.DataVersion()
+		if err != nil {
+			t.Fatalf("data version: %v", err)
+		}
+		return v
+	}
+
+	before := version()
+	src, _ := s.GetOrCreateSource("me@example.com")
+	cal, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	_, _ = s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: cal, GoogleEventID: "e1", Summary: "Planning"})
+	afterWrite := version()
+	if afterWrite <= before {
+		t.Errorf("version after writes = %d, want more than %d", afterWrite, before)
+	}
+
+	if err := s.RecordQueryAudit(&QueryAudit{ExecutedAt: time.Now(), Caller: "cli", SQL: "SELECT 1"}); err != nil {
+		t.Fatalf("record audit: %v", err)
+	}
+	if v := version(); v != afterWrite {
+		t.Errorf("version after audit = %d, want unchanged %d", v, afterWrite)
+	}
+
+	// Reopening keeps the count and the triggers
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema again: %v", err)
+	}
+	if v := version(); v != afterWrite {
+		t.Errorf("version after InitSchema = %d, want %d", v, afterWrite)
+	}
+	if err := s.DeleteEvent(src.ID, "e1"); err != nil {
+		t.Fatalf("delete event: %v", err)
+	}
+	if v := version(); v <= afterWrite {
+		t.Errorf("version after delete = %d, want more than %d", v, afterWrite)
+	}
+}
+
+func TestStore_AnalyticsTables(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary", Summary: "Test"})
+
+	day1 := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	addEvent := func(id string, start time.Time, minutes int, attendees ...string) int64 {
+		t.Helper()
+		eventID, err := s.UpsertEvent(&Event{
+			SourceID:      src.ID,
+			CalendarID:    calID,
+			GoogleEventID: id,
+			StartTime:     sql.NullTime{Time: start, Valid: true},
+			EndTime:       sql.NullTime{Time: start.Add(time.Duration(minutes) * time.Minute), Valid: true},
+			Status:        "confirmed",
+		})
+		if err != nil {
+			t.Fatalf("upsert event: %v", err)
+		}
+		var list []*Attendee
+		for _, email := range attendees {
+			list = append(list, &Attendee{Email: email})
+		}
+		if len(list) > 0 {
+			if err := s.ReplaceAttendees(eventID, list); err != nil {
+				t.Fatalf("replace attendees: %v", err)
+			}
+		}
+		return eventID
+	}
+
+	addEvent("e1", day1, 30, "Alice@example.com")
+	addEvent("e2", day1.Add(2*time.Hour), 60, "alice@example.com", "bob@example.com")
+
+	result, err := s.RefreshAnalyticsTables()
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if !result.FullRebuild {
+		t.Error("expected first refresh to be a full rebuild")
+	}
+
+	dayMinutes := func(day string) (count int, minutes float64) {
+		_ = s.DB().QueryRow(`SELECT meeting_count, meeting_minutes FROM daily_meeting_minutes WHERE day = ?`, day).
+			Scan(&count, &minutes)
+		return count, minutes
+	}
+	personCount := func(email string) (count int) {
+		_ = s.DB().QueryRow(`SELECT meeting_count FROM person_meeting_counts WHERE email = ?`, email).Scan(&count)
+		return count
+	}
+
+	if c, m := dayMinutes("2024-03-04"); c != 2 || int(m+0.5) != 90 {
+		t.Errorf("day1 = %d meetings/%v minutes, want 2/90", c, m)
+	}
+	if personCount("alice@example.com") != 2 || personCount("bob@example.com") != 1 {
+		t.Errorf("person counts = alice %d, bob %d, want 2, 1",
+			personCount("alice@example.com"), personCount("bob@example.com"))
+	}
+
+	// Move e2 to the next day, add e3, and delete e1; refresh incrementally
+	addEvent("e2", day2, 60, "alice@example.com", "bob@example.com")
+	addEvent("e3", day2.Add(time.Hour), 15, "carol@example.com")
+	if err := s.DeleteEvent(src.ID, "e1"); err != nil {
+		t.Fatalf("delete event: %v", err)
+	}
+
+	result, err = s.RefreshAnalyticsTables()
+	if err != nil {
+		t.Fatalf("incremental refresh: %v", err)
+	}
+	if result.FullRebuild || result.DaysUpdated != 2 {
+		t.Errorf("refresh = %+v, want incremental over 2 days", result)
+	}
+
+	if c, _ := dayMinutes("2024-03-04"); c != 0 {
+		t.Errorf("day1 meetings = %d, want 0 after move and delete", c)
+	}
+	if c, m := dayMinutes("2024-03-05"); c != 2 || int(m+0.5) != 75 {
+		t.Errorf("day2 = %d meetings/%v minutes, want 2/75", c, m)
+	}
+	if personCount("alice@example.com") != 1 || personCount("carol@example.com") != 1 {
+		t.Errorf("person counts = alice %d, carol %d, want 1, 1",
+			personCount("alice@example.com"), personCount("carol@example.com"))
+	}
+
+	var dirty int
+	_ = s.DB().QueryRow(`SELECT COUNT(*) FROM analytics_dirty`).Scan(&dirty)
+	if dirty != 0 {
+		t.Errorf("dirty keys after refresh = %d, want 0", dirty)
+	}
+
+	// An event tombstoned under legal hold stops counting as a meeting
+	if err := s.SetLegalHold(true); err != nil {
+		t.Fatalf("SetLegalHold: %v", err)
+	}
+	if err := s.DeleteEvent(src.ID, "e3"); err != nil {
+		t.Fatalf("delete event: %v", err)
+	}
+	if _, err := s.RefreshAnalyticsTables(); err != nil {
+		t.Fatalf("refresh under legal hold: %v", err)
+	}
+	if c, m := dayMinutes("2024-03-05"); c != 1 || int(m+0.5) != 60 {
+		t.Errorf("day2 = %d meetings/%v minutes after tombstoning e3, want 1/60", c, m)
+	}
+	if personCount("carol@example.com") != 0 {
+		t.Errorf("carol meetings = %d after tombstoning e3, want 0", personCount("carol@example.com"))
+	}
+}
+
+func TestStore_UserIndexes(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	name, err := s.CreateUserIndex("events", []string{"location", "start_time"})
+	if err != nil {
+		t.Fatalf("create user index: %v", err)
+	}
+	if name != "user_idx_events_location_start_time" {
+		t.Errorf("name = %q", name)
+	}
+	// Creating the same index again is a no-op
+	if _, err := s.CreateUserIndex("events", []string{"location", "start_time"}); err != nil {
+		t.Fatalf("create user index again: %v", err)
+	}
+
+	if _, err := s.CreateUserIndex("events", []string{"nope"}); err == nil {
+		t.Error("expected error for unknown column")
+	}
+	if _, err := s.CreateUserIndex("nope", []string{"id"}); err == nil {
+		t.Error("expected error for unknown table")
+	}
+
+	indexes, err := s.ListUserIndexes()
+	if err != nil {
+		t.Fatalf("list user indexes: %v", err)
+	}
+	if len(indexes) != 1 || indexes[0].Table != "events" || len(indexes[0].Columns) != 2 {
+		t.Fatalf("indexes = %+v", indexes)
+	}
+
+	if err := s.DropUserIndex("idx_events_start"); err == nil {
+		t.Error("expected error dropping a built-in index")
+	}
+	if err := s.DropUserIndex(name); err != nil {
+		t.Fatalf("drop user index: %v", err)
+	}
+	var count int
+	if err := s.DB().QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?`, name).Scan(&count); err != nil {
+		t.Fatalf("count indexes: %v", err)
+	}
+	if count != 0 {
+		t.Error("index still exists after drop")
+	}
+}
+
+func TestStore_SearchEvents(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	events := []*Event{
+		{GoogleEventID: "1", Summary: "Dentist appointment", Location: "Smile Clinic"},
+		{GoogleEventID: "2", Summary: "Team sync", Description: "Discuss dentist benefits"},
+		{GoogleEventID: "3", Summary: "Running club", Location: "Park"},
+	}
+	for i, e := range events {
+		e.SourceID = src.ID
+		e.CalendarID = calID
+		e.StartTime = sql.NullTime{Time: time.Date(2024, 1, 1+i, 9, 0, 0, 0, time.UTC), Valid: true}
+		if _, err := s.UpsertEvent(e); err != nil {
+			t.Fatalf("upsert event: %v", err)
+		}
+	}
+
+	results, err := s.SearchEvents("dentist", SearchOptions{})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+
+	// Updates are reflected in the index
+	events[0].Summary = "Orthodontist appointment"
+	if _, err := s.UpsertEvent(events[0]); err != nil {
+		t.Fatalf("update event: %v", err)
+	}
+	results, err = s.SearchEvents("dentist", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 1 || results[0].Event.GoogleEventID != "2" {
+		t.Errorf("after update got %d results, want only event 2", len(results))
+	}
+
+	// Deletes are reflected in the index
+	if err := s.DeleteEvent(src.ID, "2"); err != nil {
+		t.Fatalf("delete event: %v", err)
+	}
+	results, err = s.SearchEvents("dentist", SearchOptions{})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("after delete got %d results, want 0", len(results))
+	}
+
+	available, err := s.SearchIndexAvailable()
+	if err != nil {
+		t.Fatalf("search index available: %v", err)
+	}
+	if !available {
+		t.Skip("FTS5 not compiled in (build with -tags sqlite_fts5)")
+	}
+
+	// Porter stemming matches "run" to "Running"; snippets highlight matches
+	results, err = s.SearchEvents("run", SearchOptions{})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 1 || results[0].Snippet != "[Running] club" {
+		t.Errorf("stemmed search = %+v", results)
+	}
+
+	// Rebuilding keeps the index consistent
+	if err := s.RebuildSearchIndex(); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	results, err = s.SearchEvents("park OR orthodontist", SearchOptions{})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("got %d results after rebuild, want 2", len(results))
+	}
+}
+
+func TestStore_SyncPlan(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	// The request budget stops counting at the limit
+	for i, want := range []bool{true, true, false} {
+		ok, err := s.TakeAPIRequest("2024-03-05", 2)
+		if err != nil || ok != want {
+			t.Errorf("take %d = %v, %v; want %v", i, ok, err, want)
+		}
+	}
+	if err := s.AddAPIUsage("2024-03-05", 5); err != nil {
+		t.Fatalf("add usage: %v", err)
+	}
+	if n, _ := s.APIUsage("2024-03-05"); n != 7 {
+		t.Errorf("usage = %d, want 7", n)
+	}
+	if ok, _ := s.TakeAPIRequest("2024-03-06", 2); !ok {
+		t.Error("a new day should start with the full budget")
+	}
+
+	src, _ := s.GetOrCreateSource("test@example.com")
+	err := s.ReplaceSyncPlan(src.ID, []*SyncPlanEntry{
+		{GoogleCalendarID: "primary", Summary: "Me", EstimatedEvents: 100, EstimatedRequests: 1},
+		{GoogleCalendarID: "big", Summary: "Big", EstimatedEvents: 10000, EstimatedRequests: 4},
+		{GoogleCalendarID: "later", Summary: "Later", EstimatedEvents: 20000, EstimatedRequests: 8},
+	})
+	if err != nil {
+		t.Fatalf("replace plan: %v", err)
+	}
+
+	// Progress comes from the calendars' tokens and counted requests
+	primaryID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	_ = s.UpdateCalendarSyncToken(primaryID, "sync-1")
+	bigID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "big"})
+	err = s.InTx(func(tx *Tx) error {
+		if err := tx.AddSyncPlanRequest(src.ID, "big"); err != nil {
+			return err
+		}
+		return tx.SetCalendarPageToken(bigID, "page-2")
+	})
+	if err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+
+	plan, err := s.GetSyncPlan(src.ID)
+	if err != nil {
+		t.Fatalf("get plan: %v", err)
+	}
+	if len(plan) != 3 || plan[0].GoogleCalendarID != "primary" || plan[2].GoogleCalendarID != "later" {
+		t.Fatalf("plan = %+v, want primary, big, later", plan)
+	}
+	if !plan[0].Done || plan[0].Remaining() != 0 {
+		t.Errorf("primary = %+v, want done", plan[0])
+	}
+	if !plan[1].InProgress || plan[1].Requests != 1 || plan[1].Remaining() != 3 {
+		t.Errorf("big = %+v, want in progress with 3 requests left", plan[1])
+	}
+	if plan[2].Done || plan[2].InProgress || plan[2].Remaining() != 8 {
+		t.Errorf("later = %+v, want pending", plan[2])
+	}
+
+	// Replanning replaces the old plan
+	if err := s.ReplaceSyncPlan(src.ID, []*SyncPlanEntry{{GoogleCalendarID: "later", EstimatedRequests: 8}}); err != nil {
+		t.Fatalf("replan: %v", err)
+	}
+	if plan, _ := s.GetSyncPlan(src.ID); len(plan) != 1 {
+		t.Errorf("replanned entries = %d, want 1", len(plan))
+	}
+}
+
+func TestStore_WatchChannels(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("test@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary", Summary: "Test Cal"})
+
+	expires := time.Now().Add(7 * 24 * time.Hour).Truncate(time.Second)
+	if _, err := s.SaveWatchChannel(&WatchChannel{
+		SourceID:   src.ID,
+		CalendarID: calID,
+		ChannelID:  "ch-1",
+		ResourceID: "res-1",
+		Token:      "secret",
+		Address:    "https://example.com/notify",
+		ExpiresAt:  expires,
+	}); err != nil {
+		t.Fatalf("save watch channel: %v", err)
+	}
+
+	w, err := s.GetWatchChannel("ch-1")
+	if err != nil || w == nil {
+		t.Fatalf("get watch channel: %v, %v", w, err)
+	}
+	if w.CalendarID != calID || w.Token != "secret" || !w.ExpiresAt.Equal(expires) {
+		t.Errorf("watch channel = %+v", w)
+	}
+
+	if missing, err := s.GetWatchChannel("nope"); err != nil || missing != nil {
+		t.Errorf("get missing channel = %v, %v; want nil, nil", missing, err)
+	}
+
+	if err := s.DeleteWatchChannel("ch-1"); err != nil {
+		t.Fatalf("delete watch channel: %v", err)
+	}
+	if channels, _ := s.ListWatchChannels(src.ID); len(channels) != 0 {
+		t.Errorf("got %d channels after delete, want 0", len(channels))
+	}
+}
+
+func TestStore_InTxPageCheckpoint(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("test@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary", Summary: "Test Cal"})
+	event := func(id string) *Event {
+		return &Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: id, Summary: id}
+	}
+
+	// A failed page leaves neither events, attendees nor checkpoint behind.
+	err := s.InTx(func(tx *Tx) error {
+		eventID, err := tx.UpsertEvent(event("evt1"))
+		if err != nil {
+			return err
+		}
+		if err := tx.ReplaceAttendees(eventID, []*Attendee{{Email: "a@example.com"}}); err != nil {
+			return err
+		}
+		if err := tx.SetCalendarPageToken(calID, "page-2"); err != nil {
+			return err
+		}
+		return fmt.Errorf("interrupted")
+	})
+	if err == nil {
+		t.Fatal("expected error from InTx")
+	}
+	if exists, _ := s.EventExists(src.ID, "evt1"); exists {
+		t.Error("event from rolled-back page was stored")
+	}
+	cals, _ := s.GetCalendars(src.ID)
+	if cals[0].PageToken.Valid {
+		t.Errorf("page token = %q after rollback, want none", cals[0].PageToken.String)
+	}
+
+	// A committed page stores its checkpoint; the final page swaps it for
+	// the sync token.
+	if err := s.InTx(func(tx *Tx) error {
+		if _, err := tx.UpsertEvent(event("evt1")); err != nil {
+			return err
+		}
+		return tx.SetCalendarPageToken(calID, "page-2")
+	}); err != nil {
+		t.Fatalf("commit page: %v", err)
+	}
+	cals, _ = s.GetCalendars(src.ID)
+	if cals[0].PageToken.String != "page-2" {
+		t.Errorf("page token = %q, want page-2", cals[0].PageToken.String)
+	}
+
+	if err := s.InTx(func(tx *Tx) error {
+		return tx.UpdateCalendarSyncToken(calID, "sync-1")
+	}); err != nil {
+		t.Fatalf("commit last page: %v", err)
+	}
+	cals, _ = s.GetCalendars(src.ID)
+	if cals[0].PageToken.Valid || cals[0].SyncToken.String != "sync-1" {
+		t.Errorf("page token = %v, sync token = %q; want none, sync-1", cals[0].PageToken, cals[0].SyncToken.String)
+	}
+}
+
+func TestStore_UpsertEvents(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("test@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary", Summary: "Test Cal"})
+	write := func(id string, attendees ...string) *EventWrite {
+		w := &EventWrite{Event: &Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: id, Summary: id}}
+		for _, email := range attendees {
+			w.Attendees = append(w.Attendees, &Attendee{Email: email})
+		}
+		return w
+	}
+
+	existingID, _ := s.UpsertEvent(write("evt1").Event)
+	_ = s.ReplaceAttendees(existingID, []*Attendee{{Email: "old@example.com"}})
+
+	writes := []*EventWrite{
+		write("evt1", "a@example.com", "b@example.com"),
+		write("evt2"),
+		write("evt2", "c@example.com"),
+	}
+	var isNew []bool
+	if err := s.InTx(func(tx *Tx) error {
+		var err error
+		isNew, err = tx.UpsertEvents(src.ID, writes)
+		return err
+	}); err != nil {
+		t.Fatalf("upsert events: %v", err)
+	}
+
+	if want := []bool{false, true, false}; fmt.Sprint(isNew) != fmt.Sprint(want) {
+		t.Errorf("isNew = %v, want %v", isNew, want)
+	}
+	if writes[0].Event.ID != existingID {
+		t.Errorf("evt1 ID = %d, want %d", writes[0].Event.ID, existingID)
+	}
+	if writes[1].Event.ID == 0 || writes[1].Event.ID != writes[2].Event.ID {
+		t.Errorf("evt2 IDs = %d, %d; want the same non-zero ID", writes[1].Event.ID, writes[2].Event.ID)
+	}
+
+	for _, tc := range []struct {
+		eventID int64
+		want    int
+	}{
+		{existingID, 2},
+		{writes[1].Event.ID, 1},
+	} {
+		var count int
+		_ = s.DB().QueryRow("SELECT COUNT(*) FROM attendees WHERE event_id = ?", tc.eventID).Scan(&count)
+		if count != tc.want {
+			t.Errorf("event %d attendee count = %d, want %d", tc.eventID, count, tc.want)
+		}
+	}
+
+	// Existence lookups are chunked; a batch larger than one chunk still
+	// sees every stored event.
+	var large []*EventWrite
+	for i := 0; i < existsChunk+10; i++ {
+		large = append(large, write(fmt.Sprintf("bulk%d", i)))
+	}
+	for round, wantNew := range []bool{true, false} {
+		if err := s.InTx(func(tx *Tx) error {
+			var err error
+			isNew, err = tx.UpsertEvents(src.ID, large)
+			return err
+		}); err != nil {
+			t.Fatalf("round %d: upsert events: %v", round, err)
+		}
+		for i, n := range isNew {
+			if n != wantNew {
+				t.Fatalf("round %d: isNew[%d] = %v, want %v", round, i, n, wantNew)
+			}
+		}
+	}
+}
+
+func TestStore_Conference(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	upsert := func(c *Conference) int64 {
+		t.Helper()
+		w := &EventWrite{Event: &Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "call", Summary: "Call"}, Conference: c}
+		if err := s.InTx(func(tx *Tx) error {
+			_, err := tx.UpsertEvents(src.ID, []*EventWrite{w})
+			return err
+		}); err != nil {
+			t.Fatalf("upsert events: %v", err)
+		}
+		return w.Event.ID
+	}
+
+	meet := &Conference{
+		SolutionType: "hangoutsMeet",
+		SolutionName: "Google Meet",
+		ConferenceID: "abc-defg-hij",
+		MeetingCode:  "abc-defg-hij",
+		VideoURI:     "https://meet.google.com/abc-defg-hij",
+		HangoutLink:  "https://meet.google.com/abc-defg-hij",
+		EntryPoints: []*ConferenceEntryPoint{
+			{Type: "video", URI: "https://meet.google.com/abc-defg-hij", MeetingCode: "abc-defg-hij"},
+			{Type: "phone", URI: "tel:+1-555-0100", Label: "+1 555-0100"},
+		},
+	}
+	id := upsert(meet)
+	got, err := s.GetConference(id)
+	if err != nil {
+		t.Fatalf("get conference: %v", err)
+	}
+	if got == nil || got.SolutionType != "hangoutsMeet" || got.VideoURI != meet.VideoURI || len(got.EntryPoints) != 2 || got.EntryPoints[1].Label != "+1 555-0100" {
+		t.Errorf("conference = %+v, want the Meet call", got)
+	}
+
+	var phones int
+	_ = s.DB().QueryRow(`SELECT COUNT(*) FROM conference_data c, json_each(c.entry_points) p
+		WHERE json_extract(p.value, '$.type') = 'phone'`).Scan(&phones)
+	if phones != 1 {
+		t.Errorf("phone entry points via json_each = %d, want 1", phones)
+	}
+
+	// nil leaves the conference alone; an empty one removes it
+	upsert(nil)
+	if got, _ := s.GetConference(id); got == nil {
+		t.Error("conference removed by a write without one")
+	}
+	upsert(&Conference{})
+	if got, _ := s.GetConference(id); got != nil {
+		t.Errorf("conference = %+v after removal, want none", got)
+	}
+}
+
+func TestStore_EventHistory(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	event := func(summary string, start time.Time) *Event {
+		return &Event{
+			SourceID: src.ID, CalendarID: calID, GoogleEventID: "sync", Summary: summary,
+			StartTime: sql.NullTime{Time: start, Valid: true},
+			EndTime:   sql.NullTime{Time: start.Add(time.Hour), Valid: true},
+		}
+	}
+	id, _ := s.UpsertEvent(event("Sync", start))
+
+	// Off by default: updates overwrite
+	_, _ = s.UpsertEvent(event("Sync", start.Add(time.Hour)))
+	if versions, err := s.GetEventHistory(id); err != nil || len(versions) != 0 {
+		t.Fatalf("history without event_history = %d versions (%v), want none", len(versions), err)
+	}
+
+	if err := s.SetEventHistory(true); err != nil {
+		t.Fatalf("SetEventHistory: %v", err)
+	}
+	if err := s.SetEventHistory(true); err != nil {
+		t.Fatalf("SetEventHistory again: %v", err)
+	}
+	_, _ = s.UpsertEvent(event("Sync", start.Add(time.Hour))) // Unchanged: no version
+	_, _ = s.UpsertEvent(event("Sync", start.AddDate(0, 0, 1)))
+	_, _ = s.UpsertEvent(event("Weekly sync", start.AddDate(0, 0, 1)))
+
+	versions, err := s.GetEventHistory(id)
+	if err != nil {
+		t.Fatalf("GetEventHistory: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("history = %d versions, want 2", len(versions))
+	}
+	if got := versions[0].Event.StartTime.Time; !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("first version starts %v, want %v", got, start.Add(time.Hour))
+	}
+	if versions[1].Event.Summary != "Sync" || versions[1].ChangedAt.IsZero() {
+		t.Errorf("second version = %+v at %v, want the old title", versions[1].Event, versions[1].ChangedAt)
+	}
+
+	if err := s.SetEventHistory(false); err != nil {
+		t.Fatalf("SetEventHistory(false): %v", err)
+	}
+	_, _ = s.UpsertEvent(event("Standup", start))
+	if versions, _ := s.GetEventHistory(id); len(versions) != 2 {
+		t.Errorf("history after turning it off = %d versions, want the 2 kept", len(versions))
+	}
+}
+
+func TestStore_PurgeEvents(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	primary, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary", Summary: "me@example.com"})
+	holidays, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "h@group.v.calendar.google.com", Summary: "Holidays"})
+
+	ids := map[string]int64{}
+	event := func(calID int64, id string, year int, rule string) {
+		t.Helper()
+		start := time.Date(year, 3, 4, 9, 0, 0, 0, time.UTC)
+		eventID, err := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: id, RecurrenceRule: rule,
+			StartTime: sql.NullTime{Time: start, Valid: true}, EndTime: sql.NullTime{Time: start.Add(time.Hour), Valid: true}})
+		if err != nil {
+			t.Fatalf("upsert event: %v", err)
+		}
+		ids[id] = eventID
+	}
+	event(primary, "old", 2010, "")
+	event(primary, "new", 2024, "")
+	event(holidays, "old-holiday", 2010, "")
+	event(primary, "ongoing-series", 2010, "RRULE:FREQ=YEARLY")
+	event(primary, "ended-series", 2010, "RRULE:FREQ=YEARLY;COUNT=2")
+	event(primary, "unexpanded-series", 2010, "RRULE:FREQ=YEARLY")
+	if err := s.ReplaceAttendees(ids["old"], []*Attendee{{Email: "a@example.com"}}); err != nil {
+		t.Fatalf("replace attendees: %v", err)
+	}
+	instance := func(series string, year int) *EventInstance {
+		start := time.Date(year, 3, 4, 9, 0, 0, 0, time.UTC)
+		return &EventInstance{SourceID: src.ID, RecurringEventID: series, SeriesID: sql.NullInt64{Int64: ids[series], Valid: true},
+			EventID: ids[series], Start: sql.NullTime{Time: start, Valid: true}, End: sql.NullTime{Time: start.Add(time.Hour), Valid: true}, Expanded: true}
+	}
+	err := s.ReplaceEventInstances([]*EventInstance{
+		instance("ongoing-series", 2010), instance("ongoing-series", 2024),
+		instance("ended-series", 2010), instance("ended-series", 2011),
+	})
+	if err != nil {
+		t.Fatalf("replace instances: %v", err)
+	}
+
+	p := EventPurge{Before: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), Calendars: []string{"me@EXAMPLE.com"}}
+	if n, err := s.CountPurgeEvents(p); err != nil || n != 2 {
+		t.Errorf("count on primary = %d, %v; want 2 (old, ended-series)", n, err)
+	}
+	if _, err := s.CountPurgeEvents(EventPurge{Before: p.Before, Calendars: []string{"Work"}}); err == nil {
+		t.Error("count on unknown calendar succeeded, want error")
+	}
+
+	p.Calendars = nil
+	p.Keep, p.Command = 24*time.Hour, "purge"
+	var batches []int64
+	n, trashID, err := s.PurgeEvents(p, 2, func(deleted int64) { batches = append(batches, deleted) })
+	if err != nil || n != 3 || trashID == 0 {
+		t.Fatalf("purge = %d, trash %d, %v; want 3 in the trash", n, trashID, err)
+	}
+	if !reflect.DeepEqual(batches, []int64{2, 3}) {
+		t.Errorf("batches = %v, want [2 3]", batches)
+	}
+	for id, want := range map[string]bool{"old": false, "old-holiday": false, "ended-series": false,
+		"new": true, "ongoing-series": true, "unexpanded-series": true} {
+		if exists, _ := s.EventExists(src.ID, id); exists != want {
+			t.Errorf("event %s exists = %v, want %v", id, exists, want)
+		}
+	}
+	var attendees int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM attendees`).Scan(&attendees); err != nil || attendees != 0 {
+		t.Errorf("attendees left = %d, %v; want 0", attendees, err)
+	}
+
+	// Every batch went to one trash operation, which undo restores
+	ops, err := s.ListTrash()
+	if err != nil || len(ops) != 1 || ops[0].ID != trashID || ops[0].Command != "purge" {
+		t.Fatalf("trash = %+v, %v; want the purge", ops, err)
+	}
+	if _, err := s.RestoreTrash(trashID); err != nil {
+		t.Fatalf("restore purge: %v", err)
+	}
+	for _, id := range []string{"old", "old-holiday", "ended-series"} {
+		if exists, _ := s.EventExists(src.ID, id); !exists {
+			t.Errorf("event %s not restored", id)
+		}
+	}
+	var instances int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM event_instances WHERE series_id = ?`, ids["ended-series"]).Scan(&instances); err != nil || instances != 2 {
+		t.Errorf("restored instances = %d, %v; want 2", instances, err)
+	}
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM attendees`).Scan(&attendees); err != nil || attendees != 1 {
+		t.Errorf("restored attendees = %d, %v; want 1", attendees, err)
+	}
+
+	// Without Keep, nothing goes to the trash
+	if n, trashID, err := s.PurgeEvents(EventPurge{Before: p.Before}, 100, nil); err != nil || n != 3 || trashID != 0 {
+		t.Errorf("purge without trash = %d, trash %d, %v; want 3 and no trash", n, trashID, err)
+	}
+
+	if err := s.SetLegalHold(true); err != nil {
+		t.Fatalf("SetLegalHold: %v", err)
+	}
+	if _, _, err := s.PurgeEvents(EventPurge{Before: time.Now()}, 100, nil); !errors.Is(err, ErrLegalHold) {
+		t.Errorf("purge under legal hold error = %v, want ErrLegalHold", err)
+	}
+}
+
+func TestStore_LegalHold(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	_, _ = s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "evt", Summary: "Board meeting"})
+
+	if err := s.SetLegalHold(true); err != nil {
+		t.Fatalf("SetLegalHold: %v", err)
+	}
+	if err := s.SetLegalHold(true); err != nil {
+		t.Fatalf("SetLegalHold again: %v", err)
+	}
+	if held, err := s.LegalHold(); err != nil || !held {
+		t.Fatalf("LegalHold() = %v, %v; want true", held, err)
+	}
+
+	// Deletes leave the event and record one tombstone
+	for i := 0; i < 2; i++ {
+		if err := s.DeleteEvent(src.ID, "evt"); err != nil {
+			t.Fatalf("DeleteEvent: %v", err)
+		}
+	}
+	if exists, _ := s.EventExists(src.ID, "evt"); !exists {
+		t.Error("event deleted under legal hold")
+	}
+	if n, err := s.CountTombstones(); err != nil || n != 1 {
+		t.Errorf("CountTombstones() = %d, %v; want 1", n, err)
+	}
+
+	// A tombstoned event is kept but no longer listed or counted
+	if n, err := s.CountEvents(EventFilter{}); err != nil || n != 0 {
+		t.Errorf("CountEvents() = %d, %v; want 0", n, err)
+	}
+	if events, err := s.ListEvents(EventFilter{SourceID: src.ID}); err != nil || len(events) != 0 {
+		t.Errorf("ListEvents() = %d events, %v; want none", len(events), err)
+	}
+	if results, err := s.SearchEvents("Board", SearchOptions{SourceID: src.ID}); err != nil || len(results) != 0 {
+		t.Errorf("SearchEvents() = %d results, %v; want none", len(results), err)
+	}
+	if stats, err := s.GetStats(); err != nil || stats.EventCount != 0 {
+		t.Errorf("GetStats().EventCount = %v, %v; want 0", stats, err)
+	}
+
+	err := s.InTx(func(tx *Tx) error {
+		_, err := tx.DeleteSource(src.ID)
+		return err
+	})
+	if !errors.Is(err, ErrLegalHold) {
+		t.Errorf("DeleteSource error = %v, want ErrLegalHold", err)
+	}
+	if _, err := s.CleanupOrphans(); !errors.Is(err, ErrLegalHold) {
+		t.Errorf("CleanupOrphans error = %v, want ErrLegalHold", err)
+	}
+
+	// Lifting the hold lets deletes through again
+	if err := s.SetLegalHold(false); err != nil {
+		t.Fatalf("SetLegalHold(false): %v", err)
+	}
+	if err := s.DeleteEvent(src.ID, "evt"); err != nil {
+		t.Fatalf("DeleteEvent: %v", err)
+	}
+	if exists, _ := s.EventExists(src.ID, "evt"); exists {
+		t.Error("event kept after the hold was lifted")
+	}
+	if n, _ := s.CountTombstones(); n != 0 {
+		t.Errorf("tombstones = %d after the event was deleted, want 0", n)
+	}
+}
+
+func TestStore_DuplicateAttendees(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	eventID, _ := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "evt"})
+
+	// Copies in one write are merged rather than failing it
+	err := s.ReplaceAttendees(eventID, []*Attendee{
+		{Email: "dana@example.com", DisplayName: "Dana", ResponseStatus: "needsAction", IsOrganizer: true},
+		{Email: "Dana@Example.com", ResponseStatus: "accepted"},
+		{Email: "me@example.com", ResponseStatus: "accepted", IsSelf: true},
+		{Email: "me@example.com", ResponseStatus: "accepted"},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceAttendees: %v", err)
+	}
+	attendees, err := s.ListAttendees(eventID)
+	if err != nil {
+		t.Fatalf("ListAttendees: %v", err)
+	}
+	byEmail := make(map[string]*Attendee)
+	for _, a := range attendees {
+		byEmail[a.Email] = a
+	}
+	dana := byEmail["dana@example.com"]
+	if len(attendees) != 2 || dana == nil || byEmail["me@example.com"] == nil {
+		t.Fatalf("attendees = %+v, want dana and me once each", attendees)
+	}
+	if dana.DisplayName != "Dana" || dana.ResponseStatus != "accepted" || !dana.IsOrganizer {
+		t.Errorf("merged dana = %+v, want Dana, accepted, organizer", dana)
+	}
+	if !byEmail["me@example.com"].IsSelf {
+		t.Error("merged self attendee lost is_self")
+	}
+
+	// Duplicates stored before the unique index are merged on migration
+	db := s.DB()
+	if _, err := db.Exec(`DROP INDEX idx_attendees_event_email`); err != nil {
+		t.Fatalf("drop index: %v", err)
+	}
+	for _, a := range []struct {
+		email, name, status string
+		self                bool
+	}{
+		{"DANA@example.com", "", "declined", false},
+		{"ME@example.com", "Me", "needsAction", false},
+	} {
+		if _, err := db.Exec(`INSERT INTO attendees (event_id, email, canonical_email, display_name, response_status, is_self) VALUES (?, ?, ?, ?, ?, ?)`,
+			eventID, a.email, CanonicalEmail(a.email), a.name, a.status, a.self); err != nil {
+			t.Fatalf("insert duplicate: %v", err)
+		}
+	}
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("InitSchema: %v", err)
+	}
+	attendees, _ = s.ListAttendees(eventID)
+	byEmail = make(map[string]*Attendee)
+	for _, a := range attendees {
+		byEmail[a.Email] = a
+	}
+	if len(attendees) != 2 {
+		t.Fatalf("after migration attendees = %+v, want 2", attendees)
+	}
+	if dana := byEmail["dana@example.com"]; dana == nil || dana.ResponseStatus != "declined" || !dana.IsOrganizer || dana.DisplayName != "Dana" {
+		t.Errorf("deduplicated dana = %+v, want the latest response, organizer and name kept", dana)
+	}
+	if me := byEmail["me@example.com"]; me == nil || !me.IsSelf || me.ResponseStatus != "accepted" || me.DisplayName != "Me" {
+		t.Errorf("deduplicated me = %+v, want self, accepted over needsAction, name Me", me)
+	}
+	if _, err := db.Exec(`INSERT INTO attendees (event_id, email) VALUES (?, 'DaNa@example.com')`, eventID); err == nil {
+		t.Error("case-variant duplicate inserted after migration")
+	}
+}
+
+func TestStore_Reminders(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{
+		GoogleCalendarID: "primary",
+		DefaultReminders: []*Reminder{{Method: "popup", Minutes: 10}, {Method: "email", Minutes: 60}},
+	})
+	put := func(id string, r *EventReminders) int64 {
+		t.Helper()
+		w := &EventWrite{Event: &Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: id}, Reminders: r}
+		if err := s.InTx(func(tx *Tx) error {
+			_, err := tx.UpsertEvents(src.ID, []*EventWrite{w})
+			return err
+		}); err != nil {
+			t.Fatalf("store %s: %v", id, err)
+		}
+		return w.Event.ID
+	}
+	list := func(eventID int64) string {
+		t.Helper()
+		reminders, err := s.ListReminders(eventID)
+		if err != nil {
+			t.Fatalf("ListReminders: %v", err)
+		}
+		var got []string
+		for _, r := range reminders {
+			got = append(got, fmt.Sprint(r.Method, " ", r.Minutes, " ", r.IsDefault))
+		}
+		return fmt.Sprint(got)
+	}
+
+	defaults := put("defaults", &EventReminders{UseDefault: true})
+	own := put("own", &EventReminders{Overrides: []*Reminder{{Method: "popup", Minutes: 5}}})
+	none := put("none", &EventReminders{})
+	untouched := put("untouched", nil)
+
+	if got, want := list(defaults), "[email 60 true popup 10 true]"; got != want {
+		t.Errorf("default reminders = %s, want %s", got, want)
+	}
+	if got, want := list(own), "[popup 5 false]"; got != want {
+		t.Errorf("own reminders = %s, want %s", got, want)
+	}
+	if got := list(none); got != "[]" {
+		t.Errorf("reminders of an event without any = %s", got)
+	}
+	if got := list(untouched); got != "[]" {
+		t.Errorf("reminders of an event without settings = %s", got)
+	}
+
+	// Calendar defaults change for every event using them
+	_, _ = s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary", DefaultReminders: []*Reminder{}})
+	if got := list(defaults); got != "[]" {
+		t.Errorf("after clearing defaults, reminders = %s", got)
+	}
+	// Re-storing an event replaces its reminders; nil leaves them alone
+	put("own", &EventReminders{Overrides: []*Reminder{{Method: "popup", Minutes: 15}}})
+	put("own", nil)
+	if got, want := list(own), "[popup 15 false]"; got != want {
+		t.Errorf("replaced reminders = %s, want %s", got, want)
+	}
+}
+
+func TestStore_Colors(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary", ColorID: "15", BackgroundColor: "#9fc6e7"})
+	_, _ = s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "focus", Summary: "Focus", ColorID: "5"})
+	_, _ = s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "plain", Summary: "Plain"})
+
+	cals, err := s.GetCalendars(src.ID)
+	if err != nil || len(cals) != 1 || cals[0].BackgroundColor != "#9fc6e7" || cals[0].ColorID != "15" {
+		t.Fatalf("calendars = %+v (%v), want the colored calendar", cals, err)
+	}
+
+	rows, err := s.DB().Query(`
+		SELECT e.google_event_id, COALESCE(c.color_name, ''), c.color_hex, c.calendar_color
+		FROM event_colors c JOIN events e ON e.id = c.event_id ORDER BY 1`)
+	if err != nil {
+		t.Fatalf("query event_colors: %v", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var got []string
+	for rows.Next() {
+		var id, name, hex string
+		var fromCalendar bool
+		if err := rows.Scan(&id, &name, &hex, &fromCalendar); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		got = append(got, fmt.Sprint(id, " ", name, " ", hex, " ", fromCalendar))
+	}
+	want := []string{"focus Banana #fbd75b false", "plain  #9fc6e7 true"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("event colors = %q, want %q", got, want)
+	}
+
+	// Reopening keeps the views
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema again: %v", err)
+	}
+	var n int
+	_ = s.DB().QueryRow(`SELECT COUNT(*) FROM color_names`).Scan(&n)
+	if n != len(EventColors) {
+		t.Errorf("color_names has %d rows, want %d", n, len(EventColors))
+	}
+}
+
+func TestEventQuality(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(t time.Time) sql.NullTime { return sql.NullTime{Time: t, Valid: true} }
+	start := now.AddDate(0, 0, -1)
+
+	tests := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{"plausible", Event{StartTime: at(start), EndTime: at(start.Add(time.Hour))}, ""},
+		{"no times", Event{}, ""},
+		{"unix epoch", Event{StartTime: at(time.Unix(0, 0)), EndTime: at(time.Unix(3600, 0))}, QualityDateTooEarly},
+		{"before 1900", Event{StartTime: at(time.Date(1899, 12, 31, 0, 0, 0, 0, time.UTC))}, QualityDateTooEarly},
+		{"year 3000", Event{StartTime: at(time.Date(3000, 1, 1, 9, 0, 0, 0, time.UTC))}, QualityDateTooLate},
+		{"ends in 3000", Event{StartTime: at(start), EndTime: at(time.Date(3000, 1, 1, 9, 0, 0, 0, time.UTC))}, QualityDateTooLate},
+		{"end before start", Event{StartTime: at(start), EndTime: at(start.Add(-time.Hour))}, QualityEndBeforeStart},
+		{"updated tomorrow", Event{StartTime: at(start), UpdatedAt: at(now.Add(2 * time.Hour))}, ""},
+		{"updated next week", Event{StartTime: at(start), UpdatedAt: at(now.AddDate(0, 0, 7))}, QualityFutureTimestamp},
+		{"created next year", Event{CreatedAt: at(now.AddDate(1, 0, 0))}, QualityFutureTimestamp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EventQuality(&tt.event, now); got != tt.want {
+				t.Errorf("EventQuality() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_FlaggedEvents(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	at := func(t time.Time) sql.NullTime { return sql.NullTime{Time: t, Valid: true} }
+	_, _ = s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "ok", StartTime: at(start), EndTime: at(start.Add(time.Hour))})
+	_, _ = s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "epoch", StartTime: at(time.Unix(0, 0)), EndTime: at(time.Unix(3600, 0))})
+	_, _ = s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "backwards", StartTime: at(start), EndTime: at(start.Add(-time.Hour))})
+
+	events, err := s.FindEvents("backwards")
+	if err != nil || len(events) != 1 || events[0].Quality != QualityEndBeforeStart {
+		t.Fatalf("backwards events = %+v (%v), want one flagged %s", events, err, QualityEndBeforeStart)
+	}
+
+	stats, err := s.GetStats()
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if stats.EventCount != 3 {
+		t.Errorf("EventCount = %d, want 3 (flagged events are kept)", stats.EventCount)
+	}
+	if !stats.EarliestEvent.Equal(start) {
+		t.Errorf("EarliestEvent = %v, want %v (epoch event left out)", stats.EarliestEvent, start)
+	}
+	want := map[string]int{QualityDateTooEarly: 1, QualityEndBeforeStart: 1}
+	if fmt.Sprint(stats.Flagged) != fmt.Sprint(want) {
+		t.Errorf("Flagged = %v, want %v", stats.Flagged, want)
+	}
+
+	report, err := s.GetDurationReport(time.Time{})
+	if err != nil {
+		t.Fatalf("GetDurationReport: %v", err)
+	}
+	if n := report.Recurring.Count + report.AdHoc.Count; n != 1 {
+		t.Errorf("duration report counts %d events, want 1", n)
+	}
+
+	// Fixing the dates clears the flag
+	_, _ = s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "backwards", StartTime: at(start), EndTime: at(start.Add(time.Hour))})
+	counts, err := s.FlaggedEventCounts()
+	if err != nil || len(counts) != 1 || counts[QualityDateTooEarly] != 1 {
+		t.Errorf("FlaggedEventCounts = %v (%v), want only the epoch event", counts, err)
+	}
+}
+
+func TestStore_SchemaIntrospection(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	objects, err := s.SchemaObjects()
+	if err != nil {
+		t.Fatalf("schema objects: %v", err)
+	}
+	seen := make(map[string]string)
+	lastType := ""
+	for _, o := range objects {
+		seen[o.Name] = o.Type
+		if o.Type == "table" && lastType != "" && lastType != "table" {
+			t.Errorf("table %s listed after a %s", o.Name, lastType)
+		}
+		lastType = o.Type
+	}
+	for name, typ := range map[string]string{
+		"events":                      "table",
+		"idx_events_start":            "index",
+		"trg_events_analytics_insert": "trigger",
+	} {
+		if seen[name] != typ {
+			t.Errorf("%s: type = %q, want %q", name, seen[name], typ)
+		}
+	}
+
+	tables, err := s.Tables()
+	if err != nil {
+		t.Fatalf("tables: %v", err)
+	}
+	var events *TableInfo
+	for _, tbl := range tables {
+		if tbl.Name == "events" {
+			events = tbl
+		}
+	}
+	if events == nil {
+		t.Fatal("events table not described")
+	}
+	if c := events.Columns[0]; c.Name != "id" || !c.PrimaryKey || c.Type != "INTEGER" {
+		t.Errorf("first events column = %+v, want INTEGER id primary key", c)
+	}
+	want := ForeignKeyInfo{From: "calendar_id", Table: "calendars", To: "id", OnDelete: "CASCADE"}
+	found := false
+	for _, fk := range events.ForeignKeys {
+		found = found || fk == want
+	}
+	if !found {
+		t.Errorf("events foreign keys = %+v, want %+v among them", events.ForeignKeys, want)
+	}
+	var start, unique bool
+	for _, idx := range events.Indexes {
+		if idx.Name == "idx_events_start" {
+			start = !idx.Unique && len(idx.Columns) == 1 && idx.Columns[0] == "start_time"
+		}
+		unique = unique || idx.Unique && strings.Join(idx.Columns, ",") == "source_id,google_event_id"
+	}
+	if !start || !unique {
+		t.Errorf("events indexes = %+v, want idx_events_start and the unique event key", events.Indexes)
+	}
+}
+
+func TestStore_CopyTo(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("copy@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	if _, err := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "evt1"}); err != nil {
+		t.Fatalf("upsert event: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "copy.db")
+	if err := s.CopyTo(path); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	if err := s.CopyTo(path); err == nil {
+		t.Error("copy over an existing file succeeded, want error")
+	}
+
+	c, err := Open(path)
+	if err != nil {
+		t.Fatalf("open copy: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	count, err := c.GetEventCount(src.ID)
+	if err != nil || count != 1 {
+		t.Errorf("copied event count = %d, %v; want 1", count, err)
+	}
+}
+
+func TestStore_Team(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	alice, _ := s.GetOrCreateSource("alice@example.com")
+	bob, _ := s.GetOrCreateSource("bob@example.com")
+	if err := s.ClaimSource(alice.ID, "alice"); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if err := s.ClaimSource(alice.ID, "alice"); err != nil {
+		t.Errorf("reclaim own account: %v", err)
+	}
+	if err := s.ClaimSource(alice.ID, "bob"); err == nil || !strings.Contains(err.Error(), "belongs to alice") {
+		t.Errorf("claim other's account error = %v, want belongs to alice", err)
+	}
+	if err := s.SetSourceOwner(bob.ID, "bob"); err != nil {
+		t.Fatalf("set owner: %v", err)
+	}
+	if src, _ := s.GetSourceByIdentifier("bob@example.com"); src.Owner != "bob" {
+		t.Errorf("owner = %q, want bob", src.Owner)
+	}
+
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	meeting := func(src *Source, calID int64, id, visibility string, hours int) {
+		t.Helper()
+		_, err := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: id, Visibility: visibility,
+			StartTime: sql.NullTime{Time: start, Valid: true}, EndTime: sql.NullTime{Time: start.Add(time.Duration(hours) * time.Hour), Valid: true}})
+		if err != nil {
+			t.Fatalf("upsert event: %v", err)
+		}
+	}
+	aliceWork, _ := s.UpsertCalendar(alice.ID, &Calendar{GoogleCalendarID: "primary", Summary: "alice@example.com"})
+	alicePersonal, _ := s.UpsertCalendar(alice.ID, &Calendar{GoogleCalendarID: "p@group.calendar.google.com", Summary: "Personal"})
+	bobWork, _ := s.UpsertCalendar(bob.ID, &Calendar{GoogleCalendarID: "primary", Summary: "bob@example.com"})
+	meeting(alice, aliceWork, "a1", "", 1)
+	meeting(alice, alicePersonal, "a2", "", 5)
+	meeting(bob, bobWork, "b1", "", 2)
+	meeting(bob, bobWork, "b2", "private", 3)
+
+	n, err := s.MarkPrivateCalendars(alice.ID, func(id, name string) bool { return name == "Personal" })
+	if err != nil || n != 1 {
+		t.Fatalf("mark private = %d, %v; want 1", n, err)
+	}
+	cals, _ := s.GetCalendars(alice.ID)
+	for _, c := range cals {
+		if c.Private != (c.Summary == "Personal") {
+			t.Errorf("calendar %s private = %v", c.Summary, c.Private)
+		}
+	}
+
+	loads, err := s.GetTeamLoad(start.AddDate(0, 0, -1), start.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("team load: %v", err)
+	}
+	if len(loads) != 2 {
+		t.Fatalf("got %d members, want 2", len(loads))
+	}
+	if l := loads[0]; l.Owner != "bob" || l.Meetings != 1 || math.Abs(l.Hours-2) > 0.001 || l.Accounts != 1 {
+		t.Errorf("first member = %+v, want bob with 1 meeting, 2h", l)
+	}
+	if l := loads[1]; l.Owner != "alice" || l.Meetings != 1 || math.Abs(l.Hours-1) > 0.001 {
+		t.Errorf("second member = %+v, want alice with 1 meeting, 1h", l)
+	}
+}
+
+func TestStore_GetTeamCapacity(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	calendars := map[string]int64{}
+	for _, member := range []string{"alice", "bob", "carol", "dave"} {
+		src, _ := s.GetOrCreateSource(member + "@example.com")
+		if err := s.SetSourceOwner(src.ID, member); err != nil {
+			t.Fatalf("set owner: %v", err)
+		}
+		calendars[member], _ = s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	}
+	_, _ = s.GetOrCreateSource("unassigned@example.com")
+
+	meeting := func(member, id, uid, visibility string, day, hours int) {
+		t.Helper()
+		src, _ := s.GetSourceByIdentifier(member + "@example.com")
+		start := time.Date(2024, 3, day, 10, 0, 0, 0, time.UTC)
+		_, err := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calendars[member], GoogleEventID: id, ICalUID: uid,
+			Visibility: visibility, StartTime: sql.NullTime{Time: start, Valid: true},
+			EndTime: sql.NullTime{Time: start.Add(time.Duration(hours) * time.Hour), Valid: true}})
+		if err != nil {
+			t.Fatalf("upsert event: %v", err)
+		}
+	}
+	// Monday's standup is on three calendars; Wednesday is meeting-free.
+	meeting("alice", "s1", "standup", "", 4, 1)
+	meeting("bob", "s2", "standup", "", 4, 1)
+	meeting("carol", "s3", "standup", "", 4, 1)
+	meeting("alice", "w1", "", "", 6, 2)
+	meeting("carol", "p1", "", "private", 6, 3)
+
+	groups := map[string]string{"alice": "platform", "bob": "platform", "carol": "design"}
+	report, err := s.GetTeamCapacity(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC),
+		CapacityOptions{
+			GroupOf:         func(m string) string { return groups[m] },
+			MeetingFreeDays: [7]bool{time.Wednesday: true},
+			MinGroupSize:    2,
+		})
+	if err != nil {
+		t.Fatalf("team capacity: %v", err)
+	}
+
+	want := GroupCapacity{Members: 4, Meetings: 2, Hours: 5, Days: 4, FreeDays: 3}
+	if report.Total != want {
+		t.Errorf("total = %+v, want %+v", report.Total, want)
+	}
+	wantGroups := []GroupCapacity{
+		{Group: "design", Members: 1, Hidden: true},
+		{Group: "platform", Members: 2, Meetings: 2, Hours: 4, Days: 2, FreeDays: 1},
+		{Group: "", Members: 1, Hidden: true},
+	}
+	if len(report.Groups) != len(wantGroups) {
+		t.Fatalf("got %d groups, want %d", len(report.Groups), len(wantGroups))
+	}
+	for i, g := range report.Groups {
+		if *g != wantGroups[i] {
+			t.Errorf("group %d = %+v, want %+v", i, *g, wantGroups[i])
+		}
+	}
+	if got := report.Groups[1].Adherence(); got != 0.5 {
+		t.Errorf("platform adherence = %v, want 0.5", got)
+	}
+	wantShared := map[[2]string]float64{{"platform", "platform"}: 1}
+	if !reflect.DeepEqual(report.Shared, wantShared) {
+		t.Errorf("shared = %v, want %v", report.Shared, wantShared)
+	}
+
+	report, err = s.GetTeamCapacity(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC),
+		CapacityOptions{MinGroupSize: 5})
+	if err != nil {
+		t.Fatalf("team capacity: %v", err)
+	}
+	if !report.Total.Hidden || report.Total.Hours != 0 {
+		t.Errorf("total = %+v, want hidden below the minimum group size", report.Total)
+	}
+}
+
+func TestStore_GetRoomReport(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	boardroom, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "c_1@resource.calendar.google.com", Summary: "Boardroom"})
+	_, _ = s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "c_2@resource.calendar.google.com"})
+	primary, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+
+	at := func(day, hour int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2024, 3, day, hour, 0, 0, 0, time.UTC), Valid: true}
+	}
+	bookings := []struct {
+		calID    int64
+		id       string
+		start    sql.NullTime
+		end      sql.NullTime
+		status   string
+		declined bool
+	}{
+		{boardroom, "mon", at(4, 10), at(4, 11), "", false},
+		{boardroom, "tue", at(5, 10), at(5, 12), "", true},
+		{boardroom, "evening", at(4, 18), at(4, 19), "", false},
+		{boardroom, "cancelled", at(6, 10), at(6, 11), "cancelled", false},
+		{boardroom, "next-week", at(11, 10), at(11, 11), "", false},
+		{primary, "not-a-room", at(4, 10), at(4, 11), "", false},
+	}
+	for _, b := range bookings {
+		id, err := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: b.calID, GoogleEventID: b.id,
+			StartTime: b.start, EndTime: b.end, Status: b.status})
+		if err != nil {
+			t.Fatalf("upsert %s: %v", b.id, err)
+		}
+		response := "accepted"
+		if b.declined {
+			response = "declined"
+		}
+		_ = s.ReplaceAttendees(id, []*Attendee{{Email: "org@example.com", IsOrganizer: true, ResponseStatus: response}})
+	}
+
+	isRoom := func(id, name string) bool { return strings.HasSuffix(id, "@resource.calendar.google.com") }
+	report, err := s.GetRoomReport(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), DefaultWorkingHours, isRoom)
+	if err != nil {
+		t.Fatalf("room report: %v", err)
+	}
+	if len(report) != 2 {
+		t.Fatalf("got %d rooms, want 2", len(report))
+	}
+	room := report[0]
+	if room.Name != "Boardroom" || room.Bookings != 3 || room.BookedHours != 4 || room.NoShows != 1 {
+		t.Errorf("boardroom = %+v, want 3 bookings, 4 hours, 1 no-show", room)
+	}
+	if math.Abs(room.BookingRate-3.0/40) > 0.0001 {
+		t.Errorf("booking rate = %v, want %v", room.BookingRate, 3.0/40)
+	}
+	if peak := room.PeakHours(2); len(peak) != 2 || peak[0] != 10 || peak[1] != 11 {
+		t.Errorf("peak hours = %v, want [10 11]", peak)
+	}
+	if empty := report[1]; empty.Name != "c_2@resource.calendar.google.com" || empty.Bookings != 0 {
+		t.Errorf("unnamed room = %+v, want no bookings named by ID", empty)
+	}
+}
+
+func TestStore_GetInterviewReport(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	me, _ := s.GetOrCreateSource("me@company.com")
+	dana, _ := s.GetOrCreateSource("dana@company.com")
+	myCal, _ := s.UpsertCalendar(me.ID, &Calendar{GoogleCalendarID: "primary"})
+	danaCal, _ := s.UpsertCalendar(dana.ID, &Calendar{GoogleCalendarID: "primary"})
+
+	at := func(day, hour, min int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2024, 3, day, hour, min, 0, 0, time.UTC), Valid: true}
+	}
+	events := []struct {
+		src       *Source
+		calID     int64
+		id, title string
+		start     sql.NullTime
+		end       sql.NullTime
+		attendees []*Attendee
+	}{
+		{me, myCal, "loop", "Interview: Backend", at(4, 10, 0), at(4, 11, 0), []*Attendee{
+			{Email: "me@company.com", IsSelf: true, ResponseStatus: "accepted"},
+			{Email: "dana@company.com", DisplayName: "Dana", ResponseStatus: "accepted"},
+			{Email: "candidate@gmail.com"},
+		}},
+		// The same interview synced from Dana's account
+		{dana, danaCal, "loop", "Interview: Backend", at(4, 10, 0), at(4, 11, 0), []*Attendee{
+			{Email: "dana@company.com", IsSelf: true, ResponseStatus: "accepted"},
+		}},
+		{dana, danaCal, "screen", "Chat", at(12, 14, 0), at(12, 14, 30), []*Attendee{
+			{Email: "dana@company.com", IsSelf: true, ResponseStatus: "accepted"},
+			{Email: "scheduler@ats.example"},
+		}},
+		{me, myCal, "one-on-one", "1:1", at(5, 9, 0), at(5, 9, 30), []*Attendee{
+			{Email: "me@company.com", IsSelf: true},
+		}},
+		{me, myCal, "declined", "Interview: Frontend", at(6, 9, 0), at(6, 10, 0), []*Attendee{
+			{Email: "me@company.com", IsSelf: true, ResponseStatus: "declined"},
+		}},
+	}
+	for _, e := range events {
+		id, err := s.UpsertEvent(&Event{SourceID: e.src.ID, CalendarID: e.calID, GoogleEventID: e.id, Summary: e.title,
+			StartTime: e.start, EndTime: e.end})
+		if err != nil {
+			t.Fatalf("upsert %s: %v", e.id, err)
+		}
+		if err := s.ReplaceAttendees(id, e.attendees); err != nil {
+			t.Fatalf("attendees %s: %v", e.id, err)
+		}
+	}
+
+	rules := InterviewRules{
+		IsInterview: func(title string, attendees []string) bool {
+			for _, a := range attendees {
+				if strings.HasSuffix(a, "@ats.example") {
+					return true
+				}
+			}
+			return strings.HasPrefix(title, "Interview")
+		},
+		IsInterviewer: func(email, account string) bool { return strings.HasSuffix(email, "@company.com") },
+	}
+	report, err := s.GetInterviewReport(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 18, 0, 0, 0, 0, time.UTC), time.Monday, rules)
+	if err != nil {
+		t.Fatalf("interview report: %v", err)
+	}
+	if report.Interviews != 2 || report.Hours != 1.5 {
+		t.Errorf("total = %d interviews, %v hours, want 2, 1.5", report.Interviews, report.Hours)
+	}
+	if len(report.Weeks) != 2 || report.Weeks[0].Interviews != 1 || report.Weeks[1].Interviews != 1 || report.Weeks[1].Hours != 0.5 {
+		for _, w := range report.Weeks {
+			t.Logf("week %s: %d, %v", w.Start, w.Interviews, w.Hours)
+		}
+		t.Error("weekly tallies wrong, want one interview in each of 2 weeks")
+	}
+	want := []struct {
+		email      string
+		interviews int
+	}{
+		{"dana@company.com", 2},
+		{"me@company.com", 1},
+	}
+	if len(report.Interviewers) != len(want) {
+		t.Fatalf("got %d interviewers, want %d", len(report.Interviewers), len(want))
+	}
+	for i, w := range want {
+		if got := report.Interviewers[i]; got.Email != w.email || got.Interviews != w.interviews {
+			t.Errorf("interviewer %d = %s (%d), want %s (%d)", i, got.Email, got.Interviews, w.email, w.interviews)
+		}
+	}
+	if report.Interviewers[0].Name != "Dana" {
+		t.Errorf("name = %q, want Dana", report.Interviewers[0].Name)
+	}
+}
+
+func TestStore_GetOffHoursReport(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary", Timezone: "Europe/London"})
+	at := func(month time.Month, day, hour, min int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2024, month, day, hour, min, 0, 0, time.UTC), Valid: true}
+	}
+	meetings := []struct {
+		id         string
+		start, end sql.NullTime
+		organizer  string
+		timezone   string
+	}{
+		{"within", at(3, 4, 10, 0), at(3, 4, 11, 0), "a@example.com", ""},
+		{"late", at(3, 4, 16, 30), at(3, 4, 17, 30), "b@example.com", "America/New_York"},
+		{"early", at(3, 5, 7, 0), at(3, 5, 8, 0), "b@example.com", "America/New_York"},
+		{"saturday", at(3, 9, 10, 0), at(3, 9, 11, 0), "a@example.com", ""},
+		{"april", at(4, 1, 20, 0), at(4, 1, 21, 0), "c@example.com", ""},
+	}
+	for _, m := range meetings {
+		_, err := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: m.id,
+			StartTime: m.start, EndTime: m.end, OrganizerEmail: m.organizer, OriginalTimezone: m.timezone})
+		if err != nil {
+			t.Fatalf("upsert %s: %v", m.id, err)
+		}
+	}
+
+	report, err := s.GetOffHoursReport(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), DefaultWorkingHours)
+	if err != nil {
+		t.Fatalf("off-hours report: %v", err)
+	}
+	if got := report.Total; got.Meetings != 5 || got.OffHours != 3 || got.Weekend != 1 || got.Hours != 3.5 {
+		t.Errorf("total = %+v, want 5 meetings, 3 off-hours, 1 weekend, 3.5 hours", got)
+	}
+
+	tests := []struct {
+		name    string
+		tallies []*OffHoursTally
+		want    []string // Key:Outside
+	}{
+		{"months", report.Months, []string{"2024-03:3", "2024-04:1"}},
+		{"organizers", report.Organizers, []string{"b@example.com:2", "a@example.com:1", "c@example.com:1"}},
+		{"timezones", report.Timezones, []string{"America/New_York:2", "Europe/London:2"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, tally := range tt.tallies {
+			got = append(got, fmt.Sprintf("%s:%d", tally.Key, tally.Outside()))
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStore_GetPeopleReport(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	work, _ := s.GetOrCreateSource("me@example.com")
+	home, _ := s.GetOrCreateSource("me@home.example.com")
+	workCal, _ := s.UpsertCalendar(work.ID, &Calendar{GoogleCalendarID: "primary"})
+	homeCal, _ := s.UpsertCalendar(home.ID, &Calendar{GoogleCalendarID: "primary"})
+	at := func(month time.Month, day, hour int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2024, month, day, hour, 0, 0, 0, time.UTC), Valid: true}
+	}
+	meetings := []struct {
+		source, cal int64
+		id, icalUID string
+		start, end  sql.NullTime
+		status      string
+		attendees   []*Attendee
+	}{
+		{work.ID, workCal, "1:1", "one@x", at(3, 4, 10), at(3, 4, 11), "", []*Attendee{
+			{Email: "Ann@example.com", DisplayName: "Ann"}, {Email: "dan@googlemail.com"}, {Email: "me@example.com", IsSelf: true}}},
+		// The same meeting, also in the home account
+		{home.ID, homeCal, "1:1-copy", "one@x", at(3, 4, 10), at(3, 4, 11), "", []*Attendee{
+			{Email: "ann@example.com"}, {Email: "me@home.example.com", IsSelf: true}}},
+		{work.ID, workCal, "review", "", at(4, 2, 14), at(4, 2, 14), "", nil},
+		{work.ID, workCal, "planning", "", at(4, 8, 9), at(4, 8, 11), "", []*Attendee{
+			{Email: "ann@example.com", DisplayName: "Ann Smith"}, {Email: "Bob+work@example.com"}, {Email: "Dan+cal@gmail.com"},
+			{Email: "c_1@resource.calendar.google.com", DisplayName: "Boardroom"}}},
+		{work.ID, workCal, "cancelled", "", at(4, 9, 9), at(4, 9, 10), "cancelled", []*Attendee{{Email: "bob@example.com"}}},
+		{work.ID, workCal, "before", "", at(2, 1, 9), at(2, 1, 10), "", []*Attendee{{Email: "bob@example.com"}}},
+	}
+	for _, m := range meetings {
+		id, err := s.UpsertEvent(&Event{SourceID: m.source, CalendarID: m.cal, GoogleEventID: m.id, ICalUID: m.icalUID,
+			StartTime: m.start, EndTime: m.end, Status: m.status})
+		if err != nil {
+			t.Fatalf("upsert %s: %v", m.id, err)
+		}
+		if err := s.ReplaceAttendees(id, m.attendees); err != nil {
+			t.Fatalf("attendees %s: %v", m.id, err)
+		}
+	}
+
+	report, err := s.GetPeopleReport(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("people report: %v", err)
+	}
+	if got := strings.Join(report.Months, " "); got != "2024-03 2024-04" {
+		t.Errorf("months = %s, want 2024-03 2024-04", got)
+	}
+	var got []string
+	for _, p := range report.People {
+		got = append(got, fmt.Sprintf("%s (%s): %d meetings, %.0fh, %v, last %s",
+			p.Email, p.Name, p.Meetings, p.Hours, p.Months, p.LastSeen.Format("01-02")))
+	}
+	want := []string{
+		"ann@example.com (Ann Smith): 2 meetings, 3h, [1 1], last 04-08",
+		"dan@gmail.com (): 2 meetings, 3h, [1 1], last 04-08",
+		"bob@example.com (): 1 meetings, 2h, [0 1], last 04-08",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("people =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// The attendee keeps the address as given
+	var email, canonical string
+	err = s.db.QueryRow(`SELECT email, canonical_email FROM attendees WHERE email LIKE 'Dan+%'`).Scan(&email, &canonical)
+	if err != nil || email != "Dan+cal@gmail.com" || canonical != "dan@gmail.com" {
+		t.Errorf("attendee email = %q, canonical %q (%v), want Dan+cal@gmail.com, dan@gmail.com", email, canonical, err)
+	}
+}
+
+func TestStore_SetIdentities(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@gmail.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	meeting := func(id string, day int, attendees ...*Attendee) {
+		t.Helper()
+		start := time.Date(2024, 3, day, 10, 0, 0, 0, time.UTC)
+		eventID, err := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: id,
+			StartTime: sql.NullTime{Time: start, Valid: true}, EndTime: sql.NullTime{Time: start.Add(time.Hour), Valid: true}})
+		if err != nil {
+			t.Fatalf("upsert %s: %v", id, err)
+		}
+		if err := s.ReplaceAttendees(eventID, attendees); err != nil {
+			t.Fatalf("attendees %s: %v", id, err)
+		}
+	}
+	meeting("standup", 4, &Attendee{Email: "me@gmail.com", IsSelf: true}, &Attendee{Email: "M.Surname@corp.com"},
+		&Attendee{Email: "pat@corp.com", DisplayName: "P. Jones"})
+	meeting("lunch", 5, &Attendee{Email: "me@corp.com"}, &Attendee{Email: "pat@gmail.com"})
+
+	attendees := func() []string {
+		t.Helper()
+		rows, err := s.db.Query(`SELECT email, canonical_email, is_self FROM attendees ORDER BY id`)
+		if err != nil {
+			t.Fatalf("query attendees: %v", err)
+		}
+		defer func() { _ = rows.Close() }()
+		var got []string
+		for rows.Next() {
+			var email, canonical string
+			var self bool
+			if err := rows.Scan(&email, &canonical, &self); err != nil {
+				t.Fatalf("scan attendee: %v", err)
+			}
+			got = append(got, fmt.Sprintf("%s=%s self=%v", email, canonical, self))
+		}
+		return got
+	}
+
+	identities := []Identity{
+		{Name: "Me", Emails: []string{"me@gmail.com", "me@corp.com", "m.surname@corp.com"}},
+		{Name: "Pat Jones", Emails: []string{"pat@corp.com", "Pat@gmail.com"}},
+	}
+	if err := s.SetIdentities(identities); err != nil {
+		t.Fatalf("SetIdentities: %v", err)
+	}
+	// Attendees written later are mapped as they're stored
+	meeting("review", 6, &Attendee{Email: "me+cal@corp.com"}, &Attendee{Email: "pat@gmail.com"})
+	want := []string{
+		"me@gmail.com=me@gmail.com self=true",
+		"M.Surname@corp.com=me@gmail.com self=true",
+		"pat@corp.com=pat@corp.com self=false",
+		"me@corp.com=me@gmail.com self=true",
+		"pat@gmail.com=pat@corp.com self=false",
+		"me+cal@corp.com=me@gmail.com self=true",
+		"pat@gmail.com=pat@corp.com self=false",
+	}
+	if got := attendees(); !reflect.DeepEqual(got, want) {
+		t.Errorf("attendees =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	report, err := s.GetPeopleReport(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("people report: %v", err)
+	}
+	if len(report.People) != 1 || report.People[0].Email != "pat@corp.com" || report.People[0].Name != "Pat Jones" || report.People[0].Meetings != 3 {
+		t.Errorf("people = %+v, want Pat Jones at pat@corp.com with 3 meetings", report.People)
+	}
+	if _, err := s.RefreshAnalyticsTables(); err != nil {
+		t.Fatalf("refresh analytics: %v", err)
+	}
+	var counted int
+	if err := s.db.QueryRow(`SELECT meeting_count FROM person_meeting_counts WHERE email = 'pat@corp.com'`).Scan(&counted); err != nil || counted != 3 {
+		t.Errorf("person_meeting_counts for pat@corp.com = %d, %v; want 3", counted, err)
+	}
+
+	// Removing the identities restores the addresses' own canonical emails
+	if err := s.SetIdentities(nil); err != nil {
+		t.Fatalf("SetIdentities(nil): %v", err)
+	}
+	var people, mapped int
+	_ = s.db.QueryRow(`SELECT COUNT(*) FROM people`).Scan(&people)
+	_ = s.db.QueryRow(`SELECT COUNT(*) FROM attendees WHERE canonical_email <> lower(email)`).Scan(&mapped)
+	if people != 0 || mapped != 1 {
+		t.Errorf("after clearing: people = %d, attendees mapped = %d; want 0, 1 (me+cal)", people, mapped)
+	}
+}
+
+func TestStore_SetIdentitiesCanonicalAccount(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	// The account is named by an address people holds in another form
+	src, _ := s.GetOrCreateSource("Me+cal@googlemail.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	meeting := func(id string) {
+		t.Helper()
+		eventID, err := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: id})
+		if err != nil {
+			t.Fatalf("upsert %s: %v", id, err)
+		}
+		if err := s.ReplaceAttendees(eventID, []*Attendee{{Email: "me@corp.com"}, {Email: "pat@corp.com"}}); err != nil {
+			t.Fatalf("attendees %s: %v", id, err)
+		}
+	}
+
+	meeting("before")
+	if err := s.SetIdentities([]Identity{{Name: "Me", Emails: []string{"me@gmail.com", "me@corp.com"}}}); err != nil {
+		t.Fatalf("SetIdentities: %v", err)
+	}
+	meeting("after")
+
+	var self []string
+	rows, err := s.db.Query(`
+		SELECT e.google_event_id || ' ' || a.email FROM attendees a JOIN events e ON e.id = a.event_id
+		WHERE a.is_self ORDER BY a.id`)
+	if err != nil {
+		t.Fatalf("query attendees: %v", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var row string
+		if err := rows.Scan(&row); err != nil {
+			t.Fatalf("scan attendee: %v", err)
+		}
+		self = append(self, row)
+	}
+	if want := []string{"before me@corp.com", "after me@corp.com"}; !reflect.DeepEqual(self, want) {
+		t.Errorf("is_self attendees = %v, want %v", self, want)
+	}
+}
+
+func TestCanonicalEmail(t *testing.T) {
+	tests := []struct {
+		email, want string
+	}{
+		{"ann@example.com", "ann@example.com"},
+		{" Ann@Example.COM ", "ann@example.com"},
+		{"ann+newsletter@example.com", "ann@example.com"},
+		{"ann+a+b@example.com", "ann@example.com"},
+		{"Ann.Smith@GoogleMail.com", "ann.smith@gmail.com"},
+		{"ann+x@googlemail.com", "ann@gmail.com"},
+		{"+ann@example.com", "+ann@example.com"},
+		{"c_1@resource.calendar.google.com", "c_1@resource.calendar.google.com"},
+		{"not-an-email", "not-an-email"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := CanonicalEmail(tt.email); got != tt.want {
+			t.Errorf("CanonicalEmail(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestStore_MigrateCanonicalEmails(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	start := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	var ids []int64
+	for i, email := range []string{"Ann@example.com", "ann+1@example.com"} {
+		id, _ := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: fmt.Sprint("m", i),
+			StartTime: sql.NullTime{Time: start, Valid: true}, EndTime: sql.NullTime{Time: start.Add(time.Hour), Valid: true}})
+		if err := s.ReplaceAttendees(id, []*Attendee{{Email: email}}); err != nil {
+			t.Fatalf("attendees: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	// Simulate a database from before canonical emails, its people keyed
+	// by lowercased email
+	for _, name := range personTriggers {
+		if _, err := s.db.Exec(`DROP TRIGGER ` + name); err != nil {
+			t.Fatalf("drop %s: %v", name, err)
+		}
+	}
+	for _, stmt := range []string{
+		`DROP INDEX idx_attendees_canonical_email`,
+		`ALTER TABLE attendees DROP COLUMN canonical_email`,
+		`DELETE FROM person_meeting_counts`,
+		`INSERT INTO person_meeting_counts (source_id, email, meeting_count, meeting_minutes, first_seen, last_seen)
+		 SELECT e.source_id, lower(a.email), 1, 60, e.start_time, e.start_time
+		 FROM attendees a JOIN events e ON e.id = a.event_id`,
+	} {
+		if _, err := s.db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("re-init schema: %v", err)
+	}
+
+	var got []string
+	rows, err := s.db.Query(`SELECT email, meeting_count FROM person_meeting_counts ORDER BY email`)
+	if err != nil {
+		t.Fatalf("query people: %v", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var email string
+		var n int
+		if err := rows.Scan(&email, &n); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		got = append(got, fmt.Sprint(email, " ", n))
+	}
+	if want := []string{"ann@example.com 2"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("people after migration = %q, want %q", got, want)
+	}
+
+	// The recreated triggers key new attendees canonically
+	if err := s.ReplaceAttendees(ids[0], nil); err != nil {
+		t.Fatalf("clear attendees: %v", err)
+	}
+	var dirty int
+	_ = s.db.QueryRow(`SELECT COUNT(*) FROM analytics_dirty WHERE kind = 'person' AND key = 'ann@example.com'`).Scan(&dirty)
+	if dirty != 1 {
+		t.Errorf("dirty people keyed ann@example.com = %d, want 1", dirty)
+	}
+}
+
+func TestStore_GetSeriesReport(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	work, _ := s.GetOrCreateSource("me@example.com")
+	home, _ := s.GetOrCreateSource("me@home.example.com")
+	workCal, _ := s.UpsertCalendar(work.ID, &Calendar{GoogleCalendarID: "primary"})
+	homeCal, _ := s.UpsertCalendar(home.ID, &Calendar{GoogleCalendarID: "primary"})
+	at := func(month time.Month, day, hour, min int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2024, month, day, hour, min, 0, 0, time.UTC), Valid: true}
+	}
+	team := []*Attendee{{Email: "me@example.com", IsSelf: true, ResponseStatus: "accepted"},
+		{Email: "ann@example.com", ResponseStatus: "accepted"}, {Email: "bob@example.com", ResponseStatus: "accepted"},
+		{Email: "carol@example.com", ResponseStatus: "needsAction"}, {Email: "c_1@resource.calendar.google.com", ResponseStatus: "accepted"}}
+	pair := []*Attendee{{Email: "me@example.com", IsSelf: true, ResponseStatus: "accepted"}, {Email: "ann@example.com", ResponseStatus: "accepted"}}
+	events := []struct {
+		source, cal int64
+		id, icalUID string
+		title       string
+		start, end  sql.NullTime
+		recurring   string
+		status      string
+		attendees   []*Attendee
+	}{
+		{work.ID, workCal, "sync", "sync@google.com", "Team sync", at(3, 4, 10, 0), at(3, 4, 11, 0), "", "", team},
+		{work.ID, workCal, "sync_20240318T100000Z", "sync@google.com", "", sql.NullTime{}, sql.NullTime{}, "sync", "cancelled", nil},
+		{work.ID, workCal, "sync_20240401T100000Z", "sync@google.com", "Team sync (short)", at(4, 1, 10, 0), at(4, 1, 11, 0), "sync", "", pair},
+		// Split by editing "this and following" into a smaller series
+		{work.ID, workCal, "sync_R20240408T100000", "sync_R20240408T100000@google.com", "Team sync", at(4, 8, 10, 0), at(4, 8, 11, 0), "", "", pair},
+		{home.ID, homeCal, "sync-copy", "sync@google.com", "Team sync", at(3, 4, 10, 0), at(3, 4, 11, 0), "", "", team},
+		{work.ID, workCal, "1:1", "one@google.com", "1:1", at(3, 5, 9, 0), at(3, 5, 9, 30), "", "", []*Attendee{
+			{Email: "me@example.com", IsSelf: true, ResponseStatus: "declined"}, {Email: "dan@example.com", ResponseStatus: "accepted"}}},
+		{work.ID, workCal, "offsite", "offsite@google.com", "Offsite", at(3, 6, 0, 0), at(3, 7, 0, 0), "", "", nil},
+	}
+	ids := map[string]int64{}
+	for _, e := range events {
+		id, err := s.UpsertEvent(&Event{SourceID: e.source, CalendarID: e.cal, GoogleEventID: e.id, ICalUID: e.icalUID, Summary: e.title,
+			StartTime: e.start, EndTime: e.end, RecurringEventID: e.recurring, Status: e.status, AllDay: e.id == "offsite"})
+		if err != nil {
+			t.Fatalf("upsert %s: %v", e.id, err)
+		}
+		if err := s.ReplaceAttendees(id, e.attendees); err != nil {
+			t.Fatalf("attendees %s: %v", e.id, err)
+		}
+		ids[e.id] = id
+	}
+	occurrence := func(source int64, series, event string, day sql.NullTime, minutes int, expanded, allDay bool) *EventInstance {
+		return &EventInstance{SourceID: source, RecurringEventID: series, SeriesID: sql.NullInt64{Int64: ids[series], Valid: true},
+			EventID: ids[event], Start: day, End: sql.NullTime{Time: day.Time.Add(time.Duration(minutes) * time.Minute), Valid: true},
+			Expanded: expanded, AllDay: allDay}
+	}
+	err := s.ReplaceEventInstances([]*EventInstance{
+		occurrence(work.ID, "sync", "sync", at(3, 4, 10, 0), 60, true, false),
+		occurrence(work.ID, "sync", "sync", at(3, 11, 10, 0), 60, true, false),
+		occurrence(work.ID, "sync", "sync", at(3, 25, 10, 0), 60, true, false),
+		occurrence(work.ID, "sync", "sync_20240401T100000Z", at(4, 1, 10, 0), 60, false, false),
+		occurrence(work.ID, "sync_R20240408T100000", "sync_R20240408T100000", at(4, 8, 10, 0), 60, true, false),
+		occurrence(work.ID, "sync_R20240408T100000", "sync_R20240408T100000", at(4, 15, 10, 0), 60, true, false),
+		occurrence(home.ID, "sync-copy", "sync-copy", at(3, 4, 10, 0), 60, true, false),
+		occurrence(work.ID, "1:1", "1:1", at(3, 5, 9, 0), 30, true, false),
+		occurrence(work.ID, "1:1", "1:1", at(4, 2, 9, 0), 30, true, false),
+		occurrence(work.ID, "offsite", "offsite", at(3, 6, 0, 0), 1440, true, true),
+		// Before the report's range
+		occurrence(work.ID, "1:1", "1:1", at(2, 27, 9, 0), 30, true, false),
+	})
+	if err != nil {
+		t.Fatalf("instances: %v", err)
+	}
+
+	report, err := s.GetSeriesReport(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("series report: %v", err)
+	}
+	var got []string
+	for _, h := range report.Series {
+		got = append(got, fmt.Sprintf("%s %q: %d held, %d cancelled (%.0f%%), %.1fh, %.1f attendees (%+.0f), %.0f%% accepted, %.0f%% declined, %v, %s-%s",
+			h.Key, h.Title, h.Held, h.Cancelled, 100*h.CancelRate(), h.Hours, h.AverageAttendees(), h.AttendeeChange(),
+			100*h.AcceptRate(), 100*h.DeclineRate(), h.Months, h.First.Format("01-02"), h.Last.Format("01-02")))
+	}
+	want := []string{
+		`sync@google.com "Team sync": 6 held, 1 cancelled (14%), 6.0h, 3.0 attendees (-2), 83% accepted, 0% declined, [{3 1 12 9} {3 0 6 6}], 03-04-04-15`,
+		`one@google.com "1:1": 2 held, 0 cancelled (0%), 1.0h, 2.0 attendees (+0), 50% accepted, 100% declined, [{1 0 2 1} {1 0 2 1}], 03-05-04-02`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("series =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestStore_GetLoadReport(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	at := func(day, hour, min int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2024, 3, day, hour, min, 0, 0, time.UTC), Valid: true}
+	}
+	meetings := []struct {
+		id, icalUID string
+		start, end  sql.NullTime
+	}{
+		// Week of Monday 4 March: two overlapping meetings, one partly after hours
+		{"a", "", at(4, 10, 0), at(4, 11, 0)},
+		{"b", "b@x", at(4, 10, 30), at(4, 11, 30)},
+		{"b-copy", "b@x", at(4, 10, 30), at(4, 11, 30)},
+		{"c", "", at(5, 16, 0), at(5, 18, 0)},
+		// Week of 11 March: a Saturday meeting outside working days
+		{"d", "", at(16, 10, 0), at(16, 10, 30)},
+	}
+	for _, m := range meetings {
+		_, err := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: m.id, ICalUID: m.icalUID,
+			StartTime: m.start, EndTime: m.end})
+		if err != nil {
+			t.Fatalf("upsert %s: %v", m.id, err)
+		}
+	}
+
+	monday := func(day time.Time) time.Time { return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)) }
+	report, err := s.GetLoadReport(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC), DefaultWorkingHours, monday)
+	if err != nil {
+		t.Fatalf("load report: %v", err)
+	}
+	var got []string
+	for _, p := range report {
+		got = append(got, fmt.Sprintf("%s: %d meetings, %.1fh, avg %.0fm, %.1f of %.0fh busy",
+			p.Start.Format("01-02"), p.Meetings, p.Hours, p.AverageMinutes(), p.BusyHours, p.WorkingHours))
+	}
+	want := []string{
+		"03-04: 3 meetings, 4.0h, avg 80m, 2.5 of 40h busy",
+		"03-11: 1 meetings, 0.5h, avg 30m, 0.0 of 40h busy",
+		"03-18: 0 meetings, 0.0h, avg 0m, 0.0 of 40h busy",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("load =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestStore_EventNotes(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	cal, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	at := func(day, hour int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2024, 6, day, hour, 0, 0, 0, time.UTC), Valid: true}
+	}
+	standup, _ := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: cal, GoogleEventID: "standup", Summary: "Daily Standup",
+		StartTime: at(10, 9), RecurrenceRule: "RRULE:FREQ=DAILY"})
+	review, _ := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: cal, GoogleEventID: "review", Summary: "Design review", StartTime: at(12, 14)})
+	_, _ = s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: cal, GoogleEventID: "dropped", Summary: "Dropped", StartTime: at(12, 15), Status: "cancelled"})
+	err := s.ReplaceEventInstances([]*EventInstance{
+		{SourceID: src.ID, RecurringEventID: "standup", SeriesID: sql.NullInt64{Int64: standup, Valid: true}, EventID: standup, Start: at(12, 9), Expanded: true},
+	})
+	if err != nil {
+		t.Fatalf("instances: %v", err)
+	}
+
+	for _, ref := range []string{"review", fmt.Sprint(review)} {
+		found, err := s.FindEvents(ref)
+		if err != nil {
+			t.Fatalf("find %s: %v", ref, err)
+		}
+		if len(found) != 1 || found[0].ID != review {
+			t.Errorf("find %s = %d events, want the review", ref, len(found))
+		}
+	}
+
+	candidates, err := s.NoteCandidates(time.Date(2024, 6, 12, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 13, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("candidates: %v", err)
+	}
+	var got []string
+	for _, c := range candidates {
+		got = append(got, fmt.Sprintf("%s %s %v", c.Summary, c.Start.UTC().Format("15:04"), c.Occurrence))
+	}
+	if want := "Daily Standup 09:00 true|Design review 14:00 false"; strings.Join(got, "|") != want {
+		t.Errorf("candidates = %v, want %s", got, want)
+	}
+
+	note := &EventNote{EventID: standup, Path: "/notes/2024-06-12-standup.md", Occurrence: at(12, 9), Matched: true}
+	for i, want := range []bool{true, false} {
+		created, err := s.LinkNote(note)
+		if err != nil {
+			t.Fatalf("link: %v", err)
+		}
+		if created != want {
+			t.Errorf("link #%d created = %v, want %v", i+1, created, want)
+		}
+	}
+	if linked, _ := s.NoteLinked(note.Path); !linked {
+		t.Error("note not reported linked")
+	}
+	notes, err := s.ListNotes(standup)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(notes) != 1 || notes[0].Path != note.Path || !notes[0].Matched || !notes[0].Occurrence.Time.Equal(at(12, 9).Time) {
+		t.Errorf("notes = %+v, want the standup note", notes)
+	}
+
+	removed, err := s.UnlinkNote(standup, note.Path)
+	if err != nil || !removed {
+		t.Errorf("unlink = %v, %v; want true", removed, err)
+	}
+	if removed, _ := s.UnlinkNote(standup, note.Path); removed {
+		t.Error("second unlink removed a link")
+	}
+}
+
+func TestStore_GetConflictReport(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	work, _ := s.GetOrCreateSource("me@example.com")
+	home, _ := s.GetOrCreateSource("me@home.example.com")
+	workCal, _ := s.UpsertCalendar(work.ID, &Calendar{GoogleCalendarID: "primary"})
+	homeCal, _ := s.UpsertCalendar(home.ID, &Calendar{GoogleCalendarID: "primary"})
+	at := func(day, hour, min int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2024, 3, day, hour, min, 0, 0, time.UTC), Valid: true}
+	}
+	meetings := []struct {
+		cal         int64
+		id, icalUID string
+		start, end  sql.NullTime
+		status      string
+		declined    bool
+	}{
+		// Week of Monday 4 March
+		{workCal, "a", "", at(4, 9, 0), at(4, 10, 0), "", false},
+		{homeCal, "b", "", at(4, 9, 30), at(4, 10, 30), "", false},
+		{workCal, "c", "", at(4, 10, 30), at(4, 11, 0), "", false}, // Back to back with b
+		{workCal, "d", "d@x", at(4, 14, 0), at(4, 15, 0), "", false},
+		{homeCal, "d-copy", "d@x", at(4, 14, 0), at(4, 15, 0), "", false}, // Same meeting, other account
+		{workCal, "declined", "", at(4, 14, 0), at(4, 15, 0), "", true},
+		{workCal, "cancelled", "", at(4, 14, 0), at(4, 15, 0), "cancelled", false},
+		// Week of 11 March: three in a row, each overlapping the next
+		{workCal, "x", "", at(12, 14, 0), at(12, 15, 0), "", false},
+		{workCal, "y", "", at(12, 14, 30), at(12, 16, 0), "", false},
+		{homeCal, "z", "", at(12, 15, 30), at(12, 16, 30), "", false},
+	}
+	for _, m := range meetings {
+		src := work.ID
+		if m.cal == homeCal {
+			src = home.ID
+		}
+		id, err := s.UpsertEvent(&Event{SourceID: src, CalendarID: m.cal, GoogleEventID: m.id, ICalUID: m.icalUID,
+			Summary: m.id, StartTime: m.start, EndTime: m.end, Status: m.status})
+		if err != nil {
+			t.Fatalf("upsert %s: %v", m.id, err)
+		}
+		if m.declined {
+			if err := s.ReplaceAttendees(id, []*Attendee{{Email: "me@example.com", IsSelf: true, ResponseStatus: "declined"}}); err != nil {
+				t.Fatalf("attendees: %v", err)
+			}
+		}
+	}
+	// A weekly series whose 5 March occurrence clashes with a one-off
+	series, _ := s.UpsertEvent(&Event{SourceID: work.ID, CalendarID: workCal, GoogleEventID: "weekly", Summary: "weekly",
+		StartTime: at(5, 11, 0), EndTime: at(5, 12, 0), RecurrenceRule: "RRULE:FREQ=WEEKLY"})
+	_, _ = s.UpsertEvent(&Event{SourceID: work.ID, CalendarID: workCal, GoogleEventID: "one-off", Summary: "one-off",
+		StartTime: at(5, 11, 45), EndTime: at(5, 12, 15)})
+	err := s.ReplaceEventInstances([]*EventInstance{
+		{SourceID: work.ID, RecurringEventID: "weekly", SeriesID: sql.NullInt64{Int64: series, Valid: true}, EventID: series, Start: at(5, 11, 0), End: at(5, 12, 0), Expanded: true},
+		{SourceID: work.ID, RecurringEventID: "weekly", SeriesID: sql.NullInt64{Int64: series, Valid: true}, EventID: series, Start: at(12, 11, 0), End: at(12, 12, 0), Expanded: true},
+	})
+	if err != nil {
+		t.Fatalf("instances: %v", err)
+	}
+
+	monday := func(day time.Time) time.Time { return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)) }
+	report, err := s.GetConflictReport(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC), monday)
+	if err != nil {
+		t.Fatalf("conflict report: %v", err)
+	}
+	var got []string
+	for _, c := range report.Conflicts {
+		var names []string
+		for _, e := range c.Events {
+			names = append(names, e.Summary)
+		}
+		got = append(got, fmt.Sprintf("%s-%s %s", c.Start.Format("02 15:04"), c.End.Format("15:04"), strings.Join(names, ",")))
+	}
+	want := []string{
+		"04 09:30-10:00 a,b",
+		"05 11:45-12:00 weekly,one-off",
+		"12 14:30-15:00 x,y",
+		"12 15:30-16:00 y,z",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("conflicts:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	got = nil
+	for _, p := range report.Periods {
+		got = append(got, fmt.Sprintf("%s: %d conflicts, %.2fh, %d events", p.Start.Format("01-02"), p.Conflicts, p.Hours, p.Events))
+	}
+	want = []string{
+		"03-04: 2 conflicts, 0.75h, 4 events",
+		"03-11: 2 conflicts, 1.00h, 3 events",
+		"03-18: 0 conflicts, 0.00h, 0 events",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("periods:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestStore_Backup(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	for i := 0; i < 3; i++ {
+		if _, err := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: fmt.Sprintf("e%d", i)}); err != nil {
+			t.Fatalf("upsert event: %v", err)
+		}
+	}
+
+	dir := t.TempDir()
+	countEvents := func(path string) int {
+		t.Helper()
+		b, err := Open(path)
+		if err != nil {
+			t.Fatalf("open backup: %v", err)
+		}
+		defer func() { _ = b.Close() }()
+		var n int
+		if err := b.db.QueryRow(`SELECT COUNT(*) FROM events`).Scan(&n); err != nil {
+			t.Fatalf("count backup events: %v", err)
+		}
+		return n
+	}
+
+	plain := filepath.Join(dir, "calvault.db")
+	if _, err := s.Backup(plain, false); err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if n := countEvents(plain); n != 3 {
+		t.Errorf("backup has %d events, want 3", n)
+	}
+	if _, err := s.Backup(plain, false); err == nil {
+		t.Error("backup over an existing file succeeded")
+	}
+
+	compressed := filepath.Join(dir, "calvault.db.gz")
+	size, err := s.Backup(compressed, true)
+	if err != nil {
+		t.Fatalf("compressed backup: %v", err)
+	}
+	if info, _ := os.Stat(plain); size >= info.Size() {
+		t.Errorf("compressed backup is %d bytes, not smaller than %d", size, info.Size())
+	}
+	f, err := os.Open(compressed)
+	if err != nil {
+		t.Fatalf("open compressed backup: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("read compressed backup: %v", err)
+	}
+	unpacked := filepath.Join(dir, "unpacked.db")
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("decompress backup: %v", err)
+	}
+	if err := os.WriteFile(unpacked, data, 0o600); err != nil {
+		t.Fatalf("write unpacked backup: %v", err)
+	}
+	if n := countEvents(unpacked); n != 3 {
+		t.Errorf("compressed backup has %d events, want 3", n)
+	}
+
+	// Nothing is left behind but the backups
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got, want := strings.Join(names, " "), "calvault.db calvault.db.gz unpacked.db"; got != want {
+		t.Errorf("backup directory holds %s, want %s", got, want)
+	}
+}
+
+func TestStore_Jobs(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	job, err := s.StartJob("enrich", "enrich platform", map[string]string{"stages": "platform"}, 0)
+	if err != nil {
+		t.Fatalf("start job: %v", err)
+	}
+	if err := s.UpdateJobProgress(job.ID, 40, 100); err != nil {
+		t.Fatalf("update progress: %v", err)
+	}
+	if err := s.UpdateJobProgress(job.ID, 50, -1); err != nil {
+		t.Fatalf("update progress: %v", err)
+	}
+	got, err := s.GetJob(job.ID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if got.Status != JobRunning || got.Done != 50 || got.Total.Int64 != 100 || got.Params["stages"] != "platform" {
+		t.Errorf("job = %+v, want running with 50/100 done and its params", got)
+	}
+
+	// The process running the job is gone
+	n, err := s.MarkDeadJobs(func(int) bool { return false })
+	if err != nil || n != 1 {
+		t.Fatalf("MarkDeadJobs = %d, %v; want 1", n, err)
+	}
+	if got, _ := s.GetJob(job.ID); got.Status != JobInterrupted || !got.FinishedAt.Valid {
+		t.Errorf("dead job status = %s, want interrupted and finished", got.Status)
+	}
+
+	resumed, err := s.StartJob("enrich", "enrich platform", map[string]string{"stages": "platform"}, job.ID)
+	if err != nil {
+		t.Fatalf("resume job: %v", err)
+	}
+	if resumed.Done != 50 || resumed.ResumedFrom.Int64 != job.ID {
+		t.Errorf("resumed job = %+v, want 50 done, resumed from %d", resumed, job.ID)
+	}
+	if got, _ := s.GetJob(job.ID); got.Status != JobResumed {
+		t.Errorf("original job status = %s, want resumed", got.Status)
+	}
+	if n, _ := s.MarkDeadJobs(func(int) bool { return true }); n != 0 {
+		t.Errorf("MarkDeadJobs marked %d jobs of a live process", n)
+	}
+
+	if err := s.FinishJob(resumed.ID, JobCompleted, 100, nil); err != nil {
+		t.Fatalf("finish job: %v", err)
+	}
+	jobs, err := s.ListJobs(0)
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != resumed.ID || jobs[0].Status != JobCompleted || jobs[0].Done != 100 {
+		t.Errorf("ListJobs = %+v, want the completed job first", jobs)
+	}
+	if _, err := s.GetJob(999); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("GetJob(999) error = %v, want ErrJobNotFound", err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "calvault.db")
+	s, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	if _, err := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "kept"}); err != nil {
+		t.Fatalf("upsert event: %v", err)
+	}
+	backup := filepath.Join(dir, "backup.db.gz")
+	if _, err := s.Backup(backup, true); err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if _, err := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: "after-backup"}); err != nil {
+		t.Fatalf("upsert event: %v", err)
+	}
+	_ = s.Close()
+
+	countEvents := func() int {
+		t.Helper()
+		s, err := Open(dbPath)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		defer func() { _ = s.Close() }()
+		var n int
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM events`).Scan(&n); err != nil {
+			t.Fatalf("count events: %v", err)
+		}
+		return n
+	}
+
+	restored, err := Restore(backup, dbPath, false)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if restored.SchemaVersion != SchemaVersion || restored.Replaced != dbPath+".pre-restore" {
+		t.Errorf("restored = %+v", restored)
+	}
+	if n := countEvents(); n != 1 {
+		t.Errorf("restored database has %d events, want 1", n)
+	}
+	if _, err := os.Stat(restored.Replaced); err != nil {
+		t.Errorf("replaced database not kept: %v", err)
+	}
+
+	// Without hard links, the replaced database is copied instead
+	linkFile = func(string, string) error { return errors.New("hard links not supported") }
+	restored, err = Restore(backup, dbPath, false)
+	linkFile = os.Link
+	if err != nil {
+		t.Fatalf("restore without hard links: %v", err)
+	}
+	if err := checkDatabase(restored.Replaced, "integrity_check"); err != nil {
+		t.Errorf("copied replaced database: %v", err)
+	}
+
+	// A database with a newer schema is only replaced with force
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := db.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, SchemaVersion+1)); err != nil {
+		t.Fatalf("set version: %v", err)
+	}
+	_ = db.Close()
+	if _, err := Restore(backup, dbPath, false); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("restore over newer schema error = %v, want ErrNewerSchema", err)
+	}
+	if _, err := Restore(backup, dbPath, true); err != nil {
+		t.Errorf("forced restore: %v", err)
+	}
+
+	// Files that aren't intact calvault databases are refused
+	junk := filepath.Join(dir, "junk.db")
+	if err := os.WriteFile(junk, []byte("not a database"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Restore(junk, dbPath, false); err == nil {
+		t.Error("restore of a non-database succeeded")
+	}
+	if n := countEvents(); n != 1 {
+		t.Errorf("failed restore left %d events, want 1", n)
+	}
+
+	// A hold set in the database refuses a restore, whatever the config says
+	s, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := s.SetLegalHold(true); err != nil {
+		t.Fatalf("SetLegalHold: %v", err)
+	}
+	_ = s.Close()
+	if _, err := Restore(backup, dbPath, true); !errors.Is(err, ErrLegalHold) {
+		t.Errorf("restore under legal hold error = %v, want ErrLegalHold", err)
+	}
+}
+
+func TestStore_Maintain(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "calvault.db")
+	s, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	calID, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary"})
+	description := strings.Repeat("agenda ", 500)
+	for i := 0; i < 500; i++ {
+		if _, err := s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: calID, GoogleEventID: fmt.Sprintf("e%d", i), Description: description}); err != nil {
+			t.Fatalf("upsert event: %v", err)
+		}
+	}
+	if _, err := s.db.Exec(`DELETE FROM events WHERE google_event_id != 'e0'`); err != nil {
+		t.Fatalf("delete events: %v", err)
+	}
+
+	// Without VACUUM the deleted rows' pages stay in the file
+	m, err := s.Maintain(dbPath, MaintainOptions{})
+	if err != nil {
+		t.Fatalf("maintain: %v", err)
+	}
+	if m.Vacuumed || m.FreePages == 0 {
+		t.Errorf("maintain without vacuum = %+v", m)
+	}
+	var analyzed int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'sqlite_stat1'`).Scan(&analyzed); err != nil || analyzed != 1 {
+		t.Errorf("ANALYZE didn't run: %v", err)
+	}
+
+	m, err = s.Maintain(dbPath, MaintainOptions{Vacuum: true})
+	if err != nil {
+		t.Fatalf("maintain with vacuum: %v", err)
+	}
+	if m.Reclaimed() < 500*1024 {
+		t.Errorf("reclaimed %d bytes (%d -> %d), want the deleted events' space", m.Reclaimed(), m.SizeBefore, m.SizeAfter)
+	}
+	if info, err := os.Stat(dbPath + "-wal"); err == nil && info.Size() != 0 {
+		t.Errorf("write-ahead log not truncated: %d bytes", info.Size())
+	}
+	var free int
+	if err := s.db.QueryRow(`PRAGMA freelist_count`).Scan(&free); err != nil || free != 0 {
+		t.Errorf("free pages after vacuum = %d (%v)", free, err)
+	}
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM events`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("events after maintain = %d (%v)", n, err)
+	}
+}
+
+func TestStore_StatsHistory(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	src, _ := s.GetOrCreateSource("me@example.com")
+	work, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "primary", Summary: "Work"})
+	home, _ := s.UpsertCalendar(src.ID, &Calendar{GoogleCalendarID: "home@group.calendar.google.com"})
+	for i := 0; i < 3; i++ {
+		_, _ = s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: work, GoogleEventID: fmt.Sprintf("w%d", i)})
+	}
+
+	record := func(want bool) {
+		t.Helper()
+		recorded, err := s.RecordStatsSnapshot("sync", 4096)
+		if err != nil {
+			t.Fatalf("record snapshot: %v", err)
+		}
+		if recorded != want {
+			t.Errorf("recorded = %v, want %v", recorded, want)
+		}
+	}
+	record(true)
+	// Nothing changed since the last snapshot
+	record(false)
+	_, _ = s.UpsertEvent(&Event{SourceID: src.ID, CalendarID: home, GoogleEventID: "h1"})
+	record(true)
+
+	history, err := s.ListStatsHistory(time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("list history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("got %d snapshots, want 2", len(history))
+	}
+	first, latest := history[0], history[1]
+	if first.Events != 3 || latest.Events != 4 || latest.Accounts != 1 || latest.Calendars != 2 || latest.DBSizeBytes != 4096 || latest.Trigger != "sync" {
+		t.Errorf("snapshots = %+v, %+v", first, latest)
+	}
+	want := []CalendarEventCount{
+		{CalendarID: work, Account: "me@example.com", Calendar: "Work", Events: 3},
+		{CalendarID: home, Account: "me@example.com", Calendar: "home@group.calendar.google.com", Events: 1},
+	}
+	if !reflect.DeepEqual(latest.ByCalendar, want) {
+		t.Errorf("by calendar = %+v, want %+v", latest.ByCalendar, want)
+	}
+
+	latestOnly, err := s.ListStatsHistory(time.Time{}, 1)
+	if err != nil || len(latestOnly) != 1 || latestOnly[0].ID != latest.ID {
+		t.Errorf("history limited to 1 = %v (%v)", latestOnly, err)
+	}
+	recent, err := s.ListStatsHistory(time.Now().Add(time.Hour), 0)
+	if err != nil || len(recent) != 0 {
+		t.Errorf("history since an hour ahead = %v (%v)", recent, err)
+	}
 }
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Sync run statuses. Imports only complete or fail.
+const (
+	SyncRunning     = "running"
+	SyncCompleted   = "completed"
+	SyncInterrupted = "interrupted" // Stopped by Ctrl+C or shutdown; completed pages were kept
+	SyncDeferred    = "deferred"    // Stopped when the daily request budget was spent
+	SyncFailed      = "failed"
+)
+
+// SyncRun is an account sync or calendar import recorded in sync_runs.
+type SyncRun struct {
+	ID          int64
+	Account     string
+	Calendar    string // Calendar name for imports, which run per calendar
+	Kind        string // full, incremental or import
+	Status      string
+	StartedAt   time.Time
+	CompletedAt sql.NullTime // NULL while running
+	Added       int
+	Updated     int
+	Deleted     int
+	Error       string
+}
+
+// Duration returns how long the run took, or 0 if it hasn't finished.
+func (r *SyncRun) Duration() time.Duration {
+	if !r.CompletedAt.Valid {
+		return 0
+	}
+	return r.CompletedAt.Time.Sub(r.StartedAt)
+}
+
+// RecordSyncRun stores a finished account sync. Account syncs are recorded
+// once they end, since a first sync creates its account as it starts.
+// Times are stored in UTC, like CURRENT_TIMESTAMP, so runs sort by them.
+func (s *Store) RecordSyncRun(sourceID int64, r *SyncRun) error {
+	var errMsg any
+	if r.Error != "" {
+		errMsg = r.Error
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO sync_runs (source_id, kind, status, started_at, completed_at,
+		                       events_added, events_updated, events_deleted, error_message)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, sourceID, r.Kind, r.Status, r.StartedAt.UTC(), r.CompletedAt.Time.UTC(), r.Added, r.Updated, r.Deleted, errMsg)
+	if err != nil {
+		return fmt.Errorf("record sync run: %w", err)
+	}
+	return nil
+}
+
+// ListSyncRuns returns the most recent sync runs, newest first, of one
+// account if sourceID is non-zero; limit 0 returns all of them. Runs
+// without a kind are imports, which were the only runs recorded before
+// account syncs were.
+func (s *Store) ListSyncRuns(sourceID int64, limit int) ([]*SyncRun, error) {
+	query := `
+		SELECT r.id, src.identifier, COALESCE(c.summary, ''), COALESCE(r.kind, 'import'),
+		       COALESCE(r.status, ''), r.started_at, r.completed_at,
+		       COALESCE(r.events_added, 0), COALESCE(r.events_updated, 0), COALESCE(r.events_deleted, 0),
+		       COALESCE(r.error_message, '')
+		FROM sync_runs r
+		JOIN sources src ON src.id = r.source_id
+		LEFT JOIN calendars c ON c.id = r.calendar_id
+		WHERE ? = 0 OR r.source_id = ?
+		ORDER BY r.started_at DESC, r.id DESC`
+	args := []any{sourceID, sourceID}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list sync runs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var runs []*SyncRun
+	for rows.Next() {
+		r := &SyncRun{}
+		if err := rows.Scan(&r.ID, &r.Account, &r.Calendar, &r.Kind, &r.Status, &r.StartedAt, &r.CompletedAt,
+			&r.Added, &r.Updated, &r.Deleted, &r.Error); err != nil {
+			return nil, fmt.Errorf("scan sync run: %w", err)
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ClaimSource records owner as the team member an account belongs to, unless
+// it already belongs to someone else.
+func (s *Store) ClaimSource(sourceID int64, owner string) error {
+	result, err := s.db.Exec(`
+		UPDATE sources SET owner = ?
+		WHERE id = ? AND (COALESCE(owner, '') = '' OR owner = ?)
+	`, owner, sourceID, owner)
+	if err != nil {
+		return fmt.Errorf("claim source: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	var identifier, current string
+	err = s.db.QueryRow(`SELECT identifier, COALESCE(owner, '') FROM sources WHERE id = ?`, sourceID).
+		Scan(&identifier, &current)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("source %d not found", sourceID)
+	}
+	if err != nil {
+		return fmt.Errorf("get source owner: %w", err)
+	}
+	return fmt.Errorf("account %s belongs to %s", identifier, current)
+}
+
+// SetSourceOwner assigns an account to a team member; "" unassigns it.
+func (s *Store) SetSourceOwner(sourceID int64, owner string) error {
+	if _, err := s.db.Exec(`UPDATE sources SET owner = ? WHERE id = ?`, nullString(owner), sourceID); err != nil {
+		return fmt.Errorf("set source owner: %w", err)
+	}
+	return nil
+}
+
+// MarkPrivateCalendars sets which of a source's calendars are private, as
+// decided by isPrivate from the calendar's ID and name. It returns the
+// number of private calendars.
+func (s *Store) MarkPrivateCalendars(sourceID int64, isPrivate func(id, name string) bool) (int, error) {
+	calendars, err := s.GetCalendars(sourceID)
+	if err != nil {
+		return 0, err
+	}
+	private := 0
+	err = s.InTx(func(tx *Tx) error {
+		for _, cal := range calendars {
+			p := isPrivate(cal.GoogleCalendarID, cal.Summary)
+			if p {
+				private++
+			}
+			if p == cal.Private {
+				continue
+			}
+			if _, err := tx.tx.Exec(`UPDATE calendars SET private = ? WHERE id = ?`, p, cal.ID); err != nil {
+				return fmt.Errorf("mark calendar private: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return private, nil
+}
+
+// MemberLoad is one team member's meeting load over a period.
+type MemberLoad struct {
+	Owner    string // "" for accounts not assigned to anyone
+	Accounts int
+	Meetings int
+	Hours    float64
+}
+
+// GetTeamLoad returns each member's meeting load for meetings starting in
+// [since, until), by hours descending. Meetings count as in the analytics
+// tables; those on private calendars and private events are left out.
+func (s *Store) GetTeamLoad(since, until time.Time) ([]*MemberLoad, error) {
+	inRange, inRangeArgs := startsIn("e", since, until)
+	rows, err := s.db.Query(`
+		SELECT COALESCE(src.owner, '') AS member,
+		       (SELECT COUNT(*) FROM sources o WHERE COALESCE(o.owner, '') = COALESCE(src.owner, '')),
+		       COUNT(e.id),
+		       COALESCE(SUM(`+meetingMinutes+`), 0) / 60
+		FROM sources src
+		LEFT JOIN calendars c ON c.source_id = src.id AND NOT COALESCE(c.private, FALSE)
+		LEFT JOIN events e ON e.calendar_id = c.id
+		     AND `+inRange+`
+		     AND COALESCE(e.visibility, '') != 'private'
+		     AND `+meetingCondition+`
+		GROUP BY member
+		ORDER BY 4 DESC, member
+	`, inRangeArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("query team load: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var loads []*MemberLoad
+	for rows.Next() {
+		l := &MemberLoad{}
+		if err := rows.Scan(&l.Owner, &l.Accounts, &l.Meetings, &l.Hours); err != nil {
+			return nil, fmt.Errorf("scan team load: %w", err)
+		}
+		loads = append(loads, l)
+	}
+	return loads, rows.Err()
+}
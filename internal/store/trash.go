@@ -0,0 +1,357 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrTrashNotFound is returned by RestoreTrash for operations that aren't
+// in the trash: never recorded, already restored, or expired.
+var ErrTrashNotFound = errors.New("not in the trash")
+
+// TrashOperation is a destructive command whose removed rows are kept in
+// the trash until ExpiresAt.
+type TrashOperation struct {
+	ID          int64
+	Command     string // e.g. remove-account
+	Description string // What was removed
+	Rows        int64
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// trashSkipTables aren't kept in the trash: the analytics tables are
+// rebuilt from restored events, stats history outlives the accounts it
+// counted, and the trash doesn't hold itself.
+var trashSkipTables = map[string]bool{
+	"daily_meeting_minutes":   true,
+	"person_meeting_counts":   true,
+	"analytics_dirty":         true,
+	"stats_history_calendars": true,
+	"trash_operations":        true,
+	"trash_rows":              true,
+}
+
+// trashRestoreOrder lists the tables restored before all others, parents
+// first, so foreign keys hold.
+var trashRestoreOrder = []string{"sources", "calendars", "events"}
+
+// TrashSource copies every row DeleteSource removes, directly or by
+// cascade, into the trash as a new operation kept for keep, and returns
+// the operation's ID. Call it in the same transaction, before DeleteSource.
+func (t *Tx) TrashSource(sourceID int64, command, description string, keep time.Duration) (int64, error) {
+	opID, err := t.createTrashOperation(command, description, keep)
+	if err != nil {
+		return 0, err
+	}
+
+	tables, err := trashTables(t.tx)
+	if err != nil {
+		return 0, err
+	}
+	for _, table := range tables {
+		var conds []string
+		var args []interface{}
+		switch {
+		case table.name == "sources":
+			conds, args = append(conds, "id = ?"), append(args, sourceID)
+		default:
+			if table.columns["source_id"] {
+				conds, args = append(conds, "source_id = ?"), append(args, sourceID)
+			}
+			if table.columns["calendar_id"] {
+				conds, args = append(conds, "calendar_id IN (SELECT id FROM calendars WHERE source_id = ?)"), append(args, sourceID)
+			}
+			if table.columns["event_id"] {
+				conds, args = append(conds, "event_id IN (SELECT id FROM events WHERE source_id = ?)"), append(args, sourceID)
+			}
+		}
+		if len(conds) == 0 {
+			continue
+		}
+		if err := trashRows(t.tx, opID, table, strings.Join(conds, " OR "), args); err != nil {
+			return 0, err
+		}
+	}
+	return opID, nil
+}
+
+// createTrashOperation records a new operation in the trash, kept for keep,
+// and returns its ID.
+func (t *Tx) createTrashOperation(command, description string, keep time.Duration) (int64, error) {
+	now := time.Now()
+	res, err := t.tx.Exec(
+		`INSERT INTO trash_operations (command, description, created_at, expires_at) VALUES (?, ?, ?, ?)`,
+		command, description, now, now.Add(keep),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("create trash operation: %w", err)
+	}
+	opID, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("create trash operation: %w", err)
+	}
+	return opID, nil
+}
+
+// trashEvents copies the events with the given IDs, and every row that
+// cascades from them, into operation opID. in is the placeholder list for
+// ids, such as "?,?,?".
+func (t *Tx) trashEvents(opID int64, in string, ids []interface{}) error {
+	tables, err := trashTables(t.tx)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		var conds []string
+		var args []interface{}
+		switch {
+		case table.name == "events":
+			conds, args = append(conds, "id IN ("+in+")"), append(args, ids...)
+		default:
+			if table.columns["event_id"] {
+				conds, args = append(conds, "event_id IN ("+in+")"), append(args, ids...)
+			}
+			if table.columns["series_id"] {
+				conds, args = append(conds, "series_id IN ("+in+")"), append(args, ids...)
+			}
+		}
+		if len(conds) == 0 {
+			continue
+		}
+		if err := trashRows(t.tx, opID, table, strings.Join(conds, " OR "), args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trashTable is a table the trash can keep rows of.
+type trashTable struct {
+	name    string
+	columns map[string]bool
+	stored  []string // Columns other than generated ones, in order
+}
+
+// trashTables lists the tables the trash keeps rows of, in restore order.
+func trashTables(db execer) ([]*trashTable, error) {
+	rows, err := db.Query(`
+		SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND sql NOT LIKE 'CREATE VIRTUAL%'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("list tables: %w", err)
+		}
+		if !trashSkipTables[name] {
+			names = append(names, name)
+		}
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+
+	rank := func(name string) int {
+		for i, n := range trashRestoreOrder {
+			if n == name {
+				return i
+			}
+		}
+		return len(trashRestoreOrder)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if ri, rj := rank(names[i]), rank(names[j]); ri != rj {
+			return ri < rj
+		}
+		return names[i] < names[j]
+	})
+
+	var tables []*trashTable
+	for _, name := range names {
+		table := &trashTable{name: name, columns: map[string]bool{}}
+		// table_info leaves out generated columns, which aren't copied
+		cols, err := db.Query(`SELECT name FROM pragma_table_info(?)`, name)
+		if err != nil {
+			return nil, fmt.Errorf("list columns of %s: %w", name, err)
+		}
+		for cols.Next() {
+			var col string
+			if err := cols.Scan(&col); err != nil {
+				_ = cols.Close()
+				return nil, fmt.Errorf("list columns of %s: %w", name, err)
+			}
+			table.columns[col] = true
+			table.stored = append(table.stored, col)
+		}
+		_ = cols.Close()
+		if err := cols.Err(); err != nil {
+			return nil, fmt.Errorf("list columns of %s: %w", name, err)
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// trashRows copies the rows of table matching where into the trash.
+func trashRows(db execer, opID int64, table *trashTable, where string, args []interface{}) error {
+	var pairs []string
+	for _, col := range table.stored {
+		pairs = append(pairs, fmt.Sprintf("'%s', %s", col, col))
+	}
+	_, err := db.Exec(`
+		INSERT INTO trash_rows (operation_id, table_name, row_data)
+		SELECT ?, ?, json_object(`+strings.Join(pairs, ", ")+`)
+		FROM `+table.name+`
+		WHERE `+where,
+		append([]interface{}{opID, table.name}, args...)...)
+	if err != nil {
+		return fmt.Errorf("trash %s: %w", table.name, err)
+	}
+	return nil
+}
+
+// ListTrash returns the operations in the trash that haven't expired,
+// newest first.
+func (s *Store) ListTrash() ([]*TrashOperation, error) {
+	rows, err := s.db.Query(`
+		SELECT o.id, o.command, o.description, o.created_at, o.expires_at,
+		       (SELECT COUNT(*) FROM trash_rows r WHERE r.operation_id = o.id)
+		FROM trash_operations o
+		WHERE o.expires_at > ?
+		ORDER BY o.created_at DESC, o.id DESC
+	`, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("list trash: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var ops []*TrashOperation
+	for rows.Next() {
+		op := &TrashOperation{}
+		if err := rows.Scan(&op.ID, &op.Command, &op.Description, &op.CreatedAt, &op.ExpiresAt, &op.Rows); err != nil {
+			return nil, fmt.Errorf("scan trash operation: %w", err)
+		}
+		ops = append(ops, op)
+	}
+	return ops, rows.Err()
+}
+
+// RestoreTrash puts an operation's rows back where they were removed from
+// and takes it out of the trash. It fails, restoring nothing, if a row
+// would clash with one stored since - an account added again, say.
+func (s *Store) RestoreTrash(id int64) (*TrashOperation, error) {
+	var op *TrashOperation
+	err := s.InTx(func(tx *Tx) error {
+		op = &TrashOperation{ID: id}
+		err := tx.tx.QueryRow(`
+			SELECT command, description, created_at, expires_at FROM trash_operations
+			WHERE id = ? AND expires_at > ?
+		`, id, time.Now()).Scan(&op.Command, &op.Description, &op.CreatedAt, &op.ExpiresAt)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("operation %d: %w", id, ErrTrashNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("get trash operation: %w", err)
+		}
+
+		tables, err := trashTables(tx.tx)
+		if err != nil {
+			return err
+		}
+		for _, table := range tables {
+			n, err := restoreRows(tx.tx, id, table)
+			if err != nil {
+				return err
+			}
+			op.Rows += n
+		}
+
+		var left int64
+		if err := tx.tx.QueryRow(`SELECT COUNT(*) FROM trash_rows WHERE operation_id = ?`, id).Scan(&left); err != nil {
+			return fmt.Errorf("count trash rows: %w", err)
+		}
+		if left > op.Rows {
+			return fmt.Errorf("operation %d holds rows of tables that no longer exist", id)
+		}
+		if _, err := tx.tx.Exec(`DELETE FROM trash_operations WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("delete trash operation: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// restoreRows inserts an operation's rows of one table, setting the
+// columns they were trashed with that the table still has.
+func restoreRows(db execer, opID int64, table *trashTable) (int64, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT j.key FROM trash_rows r, json_each(r.row_data) j
+		WHERE r.operation_id = ? AND r.table_name = ?
+	`, opID, table.name)
+	if err != nil {
+		return 0, fmt.Errorf("restore %s: %w", table.name, err)
+	}
+	var cols []string
+	for rows.Next() {
+		var col string
+		if err := rows.Scan(&col); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("restore %s: %w", table.name, err)
+		}
+		if table.columns[col] {
+			cols = append(cols, col)
+		}
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("restore %s: %w", table.name, err)
+	}
+	if len(cols) == 0 {
+		return 0, nil
+	}
+	sort.Strings(cols)
+
+	var names, values []string
+	for _, col := range cols {
+		names = append(names, col)
+		values = append(values, fmt.Sprintf("json_extract(row_data, '$.\"%s\"')", col))
+	}
+	res, err := db.Exec(`
+		INSERT INTO `+table.name+` (`+strings.Join(names, ", ")+`)
+		SELECT `+strings.Join(values, ", ")+`
+		FROM trash_rows WHERE operation_id = ? AND table_name = ?
+		ORDER BY id
+	`, opID, table.name)
+	if err != nil {
+		return 0, fmt.Errorf("restore %s: %w", table.name, err)
+	}
+	return res.RowsAffected()
+}
+
+// PurgeExpiredTrash deletes the operations whose restore window has passed
+// and returns how many there were. Under legal hold it keeps them and
+// returns 0.
+func (s *Store) PurgeExpiredTrash() (int64, error) {
+	if held, err := legalHold(s.db); err != nil || held {
+		return 0, err
+	}
+	res, err := s.db.Exec(`DELETE FROM trash_operations WHERE expires_at <= ?`, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("purge trash: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Tx groups writes so they are committed together or not at all. Sync uses
+// it to store a fetched page of events, their attendees, and the page's
+// checkpoint atomically.
+type Tx struct {
+	tx *sql.Tx
+}
+
+// InTx runs fn in a transaction, committing if fn returns nil and rolling
+// back otherwise.
+func (s *Store) InTx(fn func(tx *Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(&Tx{tx: tx}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// UpsertEvent inserts or updates an event.
+func (t *Tx) UpsertEvent(event *Event) (int64, error) {
+	return upsertEvent(t.tx, event)
+}
+
+// EventExists reports whether an event with the given google_event_id is stored.
+func (t *Tx) EventExists(sourceID int64, googleEventID string) (bool, error) {
+	return eventExists(t.tx, sourceID, googleEventID)
+}
+
+// DeleteEvent deletes an event by google_event_id.
+func (t *Tx) DeleteEvent(sourceID int64, googleEventID string) error {
+	return deleteEvent(t.tx, sourceID, googleEventID)
+}
+
+// ReplaceAttendees replaces all attendees for an event.
+func (t *Tx) ReplaceAttendees(eventID int64, attendees []*Attendee) error {
+	return replaceAttendees(t.tx, eventID, attendees)
+}
+
+// UpdateCalendarSyncToken saves the sync token at the end of a sync and
+// clears the page checkpoint.
+func (t *Tx) UpdateCalendarSyncToken(calID int64, token string) error {
+	return updateCalendarSyncToken(t.tx, calID, token)
+}
+
+// SetCalendarPageToken checkpoints a full sync: the next page to fetch if the
+// sync is interrupted. An empty token clears the checkpoint.
+func (t *Tx) SetCalendarPageToken(calID int64, token string) error {
+	if _, err := t.tx.Exec(`UPDATE calendars SET page_token = ? WHERE id = ?`, nullString(token), calID); err != nil {
+		return fmt.Errorf("set page token: %w", err)
+	}
+	return nil
+}
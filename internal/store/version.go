@@ -0,0 +1,82 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// unversionedTables are written without bumping data_version: the audit log
+// gains a row for every query, cached or not, jobs records progress while
+// the data changes are counted by the tables the job writes, and the stats
+// history describes the data rather than being part of it.
+var unversionedTables = map[string]bool{
+	"query_audit":             true,
+	"data_version":            true,
+	"jobs":                    true,
+	"stats_history":           true,
+	"stats_history_calendars": true,
+}
+
+// initVersionTriggers makes every write to a table bump data_version,
+// including tables added after the database was created. Virtual tables
+// and their shadow tables are left out; their contents follow events.
+func (s *Store) initVersionTriggers() error {
+	rows, err := s.db.Query(`
+		SELECT name, COALESCE(sql, '') FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		ORDER BY name
+	`)
+	if err != nil {
+		return fmt.Errorf("list tables: %w", err)
+	}
+	var tables, virtual []string
+	for rows.Next() {
+		var name, def string
+		if err := rows.Scan(&name, &def); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("scan table: %w", err)
+		}
+		if strings.HasPrefix(strings.ToUpper(def), "CREATE VIRTUAL TABLE") {
+			virtual = append(virtual, name)
+			continue
+		}
+		tables = append(tables, name)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("list tables: %w", err)
+	}
+
+tables:
+	for _, table := range tables {
+		if unversionedTables[table] {
+			continue
+		}
+		for _, v := range virtual {
+			if strings.HasPrefix(table, v+"_") {
+				continue tables
+			}
+		}
+		for _, op := range []string{"INSERT", "UPDATE", "DELETE"} {
+			_, err := s.db.Exec(fmt.Sprintf(`
+				CREATE TRIGGER IF NOT EXISTS trg_%s_version_%s AFTER %s ON %s
+				BEGIN
+					UPDATE data_version SET version = version + 1 WHERE id = 1;
+				END`, table, strings.ToLower(op), op, table))
+			if err != nil {
+				return fmt.Errorf("create %s version trigger: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// DataVersion returns the count of writes to the archive's tables, which
+// changes whenever their data does.
+func (s *Store) DataVersion() (int64, error) {
+	var version int64
+	if err := s.db.QueryRow(`SELECT version FROM data_version WHERE id = 1`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("read data version: %w", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WatchChannel is a push notification channel for one calendar.
+type WatchChannel struct {
+	ID         int64
+	SourceID   int64
+	CalendarID int64
+	ChannelID  string
+	ResourceID string
+	Token      string
+	Address    string
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+}
+
+const watchChannelColumns = `
+	id, source_id, calendar_id, channel_id, resource_id, token, address, expires_at, created_at`
+
+// scanWatchChannel scans a row selected with watchChannelColumns.
+func scanWatchChannel(row scanner) (*WatchChannel, error) {
+	var w WatchChannel
+	if err := row.Scan(&w.ID, &w.SourceID, &w.CalendarID, &w.ChannelID, &w.ResourceID,
+		&w.Token, &w.Address, &w.ExpiresAt, &w.CreatedAt); err != nil {
+		return nil, fmt.Errorf("scan watch channel: %w", err)
+	}
+	return &w, nil
+}
+
+// SaveWatchChannel records a newly created channel.
+func (s *Store) SaveWatchChannel(w *WatchChannel) (int64, error) {
+	createdAt := w.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	result, err := s.db.Exec(`
+		INSERT INTO watch_channels (source_id, calendar_id, channel_id, resource_id, token, address, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, w.SourceID, w.CalendarID, w.ChannelID, w.ResourceID, w.Token, w.Address, w.ExpiresAt, createdAt)
+	if err != nil {
+		return 0, fmt.Errorf("save watch channel: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// GetWatchChannel returns the channel with the given channel ID, or nil.
+func (s *Store) GetWatchChannel(channelID string) (*WatchChannel, error) {
+	w, err := scanWatchChannel(s.db.QueryRow(`
+		SELECT `+watchChannelColumns+` FROM watch_channels WHERE channel_id = ?
+	`, channelID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return w, err
+}
+
+// ListWatchChannels returns the channels for a source, soonest to expire first.
+func (s *Store) ListWatchChannels(sourceID int64) ([]*WatchChannel, error) {
+	rows, err := s.db.Query(`
+		SELECT `+watchChannelColumns+` FROM watch_channels
+		WHERE source_id = ?
+		ORDER BY expires_at
+	`, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("list watch channels: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var channels []*WatchChannel
+	for rows.Next() {
+		w, err := scanWatchChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, w)
+	}
+	return channels, rows.Err()
+}
+
+// DeleteWatchChannel removes a channel record.
+func (s *Store) DeleteWatchChannel(channelID string) error {
+	if _, err := s.db.Exec(`DELETE FROM watch_channels WHERE channel_id = ?`, channelID); err != nil {
+		return fmt.Errorf("delete watch channel: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package store
+
+import "time"
+
+// WorkingHours describes the working week.
+type WorkingHours struct {
+	Start, End time.Duration // Working day, as offsets from midnight
+	Days       [7]bool       // Working days, indexed by time.Weekday
+}
+
+// DefaultWorkingHours is 09:00-17:00, Monday to Friday.
+var DefaultWorkingHours = WorkingHours{
+	Start: 9 * time.Hour,
+	End:   17 * time.Hour,
+	Days:  [7]bool{time.Monday: true, time.Tuesday: true, time.Wednesday: true, time.Thursday: true, time.Friday: true},
+}
+
+// Within returns how much of [start, end) falls within working hours, in
+// start's location.
+func (w WorkingHours) Within(start, end time.Time) time.Duration {
+	var total time.Duration
+	y, m, d := start.Date()
+	for day := time.Date(y, m, d, 0, 0, 0, 0, start.Location()); day.Before(end); day = day.AddDate(0, 0, 1) {
+		if !w.Days[day.Weekday()] {
+			continue
+		}
+		from := time.Date(day.Year(), day.Month(), day.Day(), 0, int(w.Start/time.Minute), 0, 0, day.Location())
+		to := time.Date(day.Year(), day.Month(), day.Day(), 0, int(w.End/time.Minute), 0, 0, day.Location())
+		if start.After(from) {
+			from = start
+		}
+		if end.Before(to) {
+			to = end
+		}
+		if to.After(from) {
+			total += to.Sub(from)
+		}
+	}
+	return total
+}
@@ -0,0 +1,66 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/salman1993/calvault/internal/calendar"
+	"github.com/salman1993/calvault/internal/store"
+	"google.golang.org/api/googleapi"
+)
+
+// NewCalendars checks the account's calendar list for changes since the
+// last check and returns the calendars that were added and haven't been
+// synced yet. Calendars excluded by opts are left out. The first check, or
+// one after the list token expired, compares the whole list. Returns nil if
+// the account has never been synced.
+func (s *Syncer) NewCalendars(ctx context.Context, email string, opts Options) ([]*calendar.CalendarEntry, error) {
+	source, err := s.store.GetSourceByIdentifier(email)
+	if err != nil {
+		return nil, fmt.Errorf("get source: %w", err)
+	}
+	if source == nil {
+		return nil, nil
+	}
+
+	token, err := s.store.GetCalendarListToken(source.ID)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := s.client.ListCalendarChanges(ctx, token)
+	var apiErr *googleapi.Error
+	if token != "" && errors.As(err, &apiErr) && apiErr.Code == 410 {
+		s.logger.Info("calendar list token expired, relisting calendars")
+		changes, err = s.client.ListCalendarChanges(ctx, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.store.GetCalendars(source.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get calendars: %w", err)
+	}
+	added := newCalendars(changes.Calendars, stored, opts)
+
+	if err := s.store.SetCalendarListToken(source.ID, changes.NextSyncToken); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// newCalendars returns the entries opts includes that aren't in stored.
+func newCalendars(entries []*calendar.CalendarEntry, stored []*store.Calendar, opts Options) []*calendar.CalendarEntry {
+	known := make(map[string]bool, len(stored))
+	for _, cal := range stored {
+		known[cal.GoogleCalendarID] = true
+	}
+	var added []*calendar.CalendarEntry
+	for _, entry := range entries {
+		if !known[entry.ID] && opts.includes(entry.ID, entry.Summary) {
+			added = append(added, entry)
+		}
+	}
+	return added
+}
@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"fmt"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// getSource returns the account's source, creating it unless dryRun. A dry
+// run of an account that was never synced gets an unsaved source, so every
+// event counts as new.
+func getSource(s *store.Store, sourceType, identifier string, dryRun bool) (*store.Source, error) {
+	if !dryRun {
+		return s.GetOrCreateSourceOfType(sourceType, identifier)
+	}
+	source, err := s.GetSourceByIdentifier(identifier)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return &store.Source{SourceType: sourceType, Identifier: identifier}, nil
+	}
+	if source.SourceType != sourceType {
+		return nil, fmt.Errorf("source %q already exists with type %s", identifier, source.SourceType)
+	}
+	return source, nil
+}
+
+// getCalendar stores the calendar's metadata and returns the stored row
+// with its sync state. With dryRun nothing is written; a calendar that was
+// never synced is returned as given, with no sync state.
+func getCalendar(s *store.Store, sourceID int64, cal *store.Calendar, dryRun bool) (*store.Calendar, error) {
+	if !dryRun {
+		if _, err := s.UpsertCalendar(sourceID, cal); err != nil {
+			return nil, fmt.Errorf("upsert calendar: %w", err)
+		}
+	}
+
+	stored, err := s.GetCalendars(sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("get calendars: %w", err)
+	}
+	for _, c := range stored {
+		if c.GoogleCalendarID == cal.GoogleCalendarID {
+			return c, nil
+		}
+	}
+	if !dryRun {
+		return nil, fmt.Errorf("calendar %s not found after upsert", cal.GoogleCalendarID)
+	}
+	return cal, nil
+}
+
+// countPage counts what storing a page would change without writing it:
+// stored events are updated, others added, and only stored events can be
+// deleted.
+func countPage(s *store.Store, sourceID int64, deleted []string, writes []*store.EventWrite) (*Summary, error) {
+	ids := append([]string(nil), deleted...)
+	for _, w := range writes {
+		ids = append(ids, w.Event.GoogleEventID)
+	}
+	existing, err := s.ExistingEvents(sourceID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("count page: %w", err)
+	}
+
+	summary := &Summary{}
+	for _, id := range deleted {
+		if existing[id] {
+			summary.EventsDeleted++
+			delete(existing, id)
+		}
+	}
+	for _, w := range writes {
+		if existing[w.Event.GoogleEventID] {
+			summary.EventsUpdated++
+		} else {
+			summary.EventsAdded++
+			// Later copies in the page would be updates
+			existing[w.Event.GoogleEventID] = true
+		}
+	}
+	return summary, nil
+}
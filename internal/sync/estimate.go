@@ -0,0 +1,93 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/salman1993/calvault/internal/calendar"
+)
+
+// storeTimePerEvent is a rough cost of upserting one event and its
+// attendees, used for ETAs.
+const storeTimePerEvent = 2 * time.Millisecond
+
+// CalendarEstimate is the pre-flight count for one calendar.
+type CalendarEstimate struct {
+	ID       string
+	Name     string
+	Primary  bool
+	Events   int
+	Requests int // Requests a full sync of this calendar would make
+}
+
+// Estimate summarizes how much work a full sync would be.
+type Estimate struct {
+	Calendars     []CalendarEstimate
+	Events        int
+	Requests      int           // Requests a full sync would make, including the calendar list
+	CountRequests int           // Requests made by the estimate itself
+	CountDuration time.Duration // Time taken by the estimate
+}
+
+// ETA estimates the duration of a full sync at the given request rate. Each
+// request takes at least as long as the rate limit allows and as long as the
+// count pass's requests did; storing events adds a per-event cost.
+func (e *Estimate) ETA(qps float64) time.Duration {
+	perRequest := time.Duration(0)
+	if qps > 0 {
+		perRequest = time.Duration(float64(time.Second) / qps)
+	}
+	if e.CountRequests > 0 {
+		measured := e.CountDuration / time.Duration(e.CountRequests)
+		perRequest = max(perRequest, measured)
+	}
+	return time.Duration(e.Requests)*perRequest + time.Duration(e.Events)*storeTimePerEvent
+}
+
+// Estimate counts the events a full sync would fetch without storing
+// anything, applying the same per-calendar options as SyncAccount.
+func (s *Syncer) Estimate(ctx context.Context, opts Options) (*Estimate, error) {
+	start := time.Now()
+
+	calendars, err := s.client.ListCalendars(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list calendars: %w", err)
+	}
+
+	calendars = filterCalendars(s.logger, opts, calendars, func(c *calendar.CalendarEntry) (string, string) {
+		return c.ID, c.Summary
+	})
+
+	est := &Estimate{Requests: 1, CountRequests: 1}
+	for _, cal := range calendars {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		calOpts := opts.calendarOptions(cal.ID, cal.Summary)
+		events, requests, err := s.client.CountEvents(ctx, cal.ID, calendar.ListEventsOptions{
+			SingleEvents: calOpts.SingleEvents,
+			TimeMin:      calOpts.TimeMin,
+			TimeMax:      calOpts.TimeMax,
+		})
+		est.CountRequests += requests
+		if err != nil {
+			return nil, fmt.Errorf("count %s: %w", cal.Summary, err)
+		}
+
+		// Full syncs use the same page size, so they make as many requests.
+		est.Calendars = append(est.Calendars, CalendarEstimate{
+			ID:       cal.ID,
+			Name:     cal.Summary,
+			Primary:  cal.IsPrimary,
+			Events:   events,
+			Requests: requests,
+		})
+		est.Events += events
+		est.Requests += requests
+	}
+
+	est.CountDuration = time.Since(start)
+	return est, nil
+}
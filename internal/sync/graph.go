@@ -0,0 +1,396 @@
+package sync
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	stdsync "sync"
+	"time"
+
+	"github.com/salman1993/calvault/internal/graph"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// GraphSyncer synchronizes Microsoft 365 / Outlook calendars via Microsoft
+// Graph delta queries. The delta link for each calendar is stored in
+// calendars.sync_token.
+type GraphSyncer struct {
+	client   *graph.Client
+	store    *store.Store
+	logger   *slog.Logger
+	progress Progress
+	start    time.Time
+	end      time.Time
+}
+
+// NewGraph creates a syncer for a Microsoft account. Graph calendar views
+// require a bounded window; by default it spans 10 years back and 2 ahead.
+func NewGraph(client *graph.Client, store *store.Store) *GraphSyncer {
+	now := time.Now().UTC()
+	return &GraphSyncer{
+		client: client,
+		store:  store,
+		logger: slog.Default(),
+		start:  now.AddDate(-10, 0, 0),
+		end:    now.AddDate(2, 0, 0),
+	}
+}
+
+// WithLogger sets the logger. Logs don't name the account; pass a logger
+// With an "account" attribute to tell accounts apart.
+func (s *GraphSyncer) WithLogger(logger *slog.Logger) *GraphSyncer {
+	s.logger = logger
+	return s
+}
+
+// WithProgress sets the progress reporter.
+func (s *GraphSyncer) WithProgress(p Progress) *GraphSyncer {
+	s.progress = p
+	return s
+}
+
+// WithWindow sets the time window covered by full syncs.
+func (s *GraphSyncer) WithWindow(start, end time.Time) *GraphSyncer {
+	s.start = start
+	s.end = end
+	return s
+}
+
+// SyncAccount syncs all calendars for a Microsoft account.
+func (s *GraphSyncer) SyncAccount(ctx context.Context, email string, opts Options) (*Summary, error) {
+	startTime := time.Now()
+	summary := &Summary{}
+
+	source, err := getSource(s.store, store.SourceTypeMicrosoft, email, opts.DryRun)
+	if err != nil {
+		return nil, fmt.Errorf("get source: %w", err)
+	}
+
+	calendars, err := s.client.ListCalendars(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list calendars: %w", err)
+	}
+
+	s.logger.Info("found calendars", "count", len(calendars))
+	included := filterCalendars(s.logger, opts, calendars, func(c *graph.CalendarEntry) (string, string) {
+		return c.ID, c.Name
+	})
+	summary.CalendarsSkipped = len(calendars) - len(included)
+	calendars = included
+
+	var mu stdsync.Mutex
+	results := make([]*CalendarResult, len(calendars))
+	runParallel(ctx, opts.Concurrency, len(calendars), func(i int) {
+		cal := calendars[i]
+		calStart := time.Now()
+		calSummary, err := s.syncCalendar(ctx, source, cal, email, opts)
+		r := calendarResult(cal.ID, cal.Name, calSummary, err, calStart)
+		results[i] = &r
+		if err != nil {
+			s.logger.Error("failed to sync calendar", "calendar", cal.Name, "error", err)
+			mu.Lock()
+			summary.CalendarsFailed++
+			mu.Unlock()
+			return
+		}
+
+		mu.Lock()
+		summary.CalendarsSynced++
+		summary.add(calSummary)
+		mu.Unlock()
+	})
+
+	summary.addResults(results)
+	summary.Duration = time.Since(startTime)
+	return summary, nil
+}
+
+// syncCalendar stores a calendar's metadata and syncs its events.
+func (s *GraphSyncer) syncCalendar(ctx context.Context, source *store.Source, cal *graph.CalendarEntry, email string, opts Options) (*Summary, error) {
+	storedCal, err := getCalendar(s.store, source.ID, &store.Calendar{
+		GoogleCalendarID: cal.ID,
+		Summary:          cal.Name,
+		IsPrimary:        cal.IsDefault,
+		AccessRole:       graphAccessRole(cal, email),
+		SubscriptionKind: graphCalendarKind(cal, email),
+	}, opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	calID, deltaLink := storedCal.ID, storedCal.SyncToken.String
+
+	calOpts := opts.calendarOptions(cal.ID, cal.Name)
+	var calSummary *Summary
+	incremental := opts.Incremental && deltaLink != ""
+	if s.progress != nil {
+		s.progress.OnCalendarStart(cal.Name, expectedEvents(s.store, source.ID, calID, 0, incremental, false))
+	}
+	if incremental {
+		calSummary, err = s.syncPages(ctx, source, calID, calOpts, func() (*graph.EventsPage, error) {
+			return s.client.EventsPage(ctx, deltaLink)
+		})
+		if errors.Is(err, graph.ErrDeltaExpired) {
+			s.logger.Info("delta link expired, falling back to full sync", "calendar", cal.Name)
+			if !opts.DryRun {
+				if clearErr := s.store.ClearCalendarSyncToken(calID); clearErr != nil {
+					s.logger.Error("failed to clear sync token", "error", clearErr)
+				}
+			}
+			calSummary, err = s.syncFull(ctx, source, calID, cal.ID, calOpts)
+		}
+	} else {
+		calSummary, err = s.syncFull(ctx, source, calID, cal.ID, calOpts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.progress != nil {
+		s.progress.OnCalendarDone(cal.Name, calSummary.EventsAdded, calSummary.EventsUpdated, calSummary.EventsDeleted)
+	}
+	return calSummary, nil
+}
+
+// graphOwnedBy reports whether the account owns the calendar. Graph leaves
+// owner empty for some built-in calendars, which always belong to the user.
+func graphOwnedBy(cal *graph.CalendarEntry, email string) bool {
+	return cal.Owner == "" || strings.EqualFold(cal.Owner, email)
+}
+
+// graphAccessRole maps Graph's ownership and canEdit flags onto Google's
+// accessRole vocabulary.
+func graphAccessRole(cal *graph.CalendarEntry, email string) string {
+	switch {
+	case graphOwnedBy(cal, email):
+		return "owner"
+	case cal.CanEdit:
+		return "writer"
+	default:
+		return "reader"
+	}
+}
+
+// graphCalendarKind classifies a Microsoft calendar. Graph does not expose
+// subscribed internet calendars separately, so non-owned ones count as shared.
+func graphCalendarKind(cal *graph.CalendarEntry, email string) string {
+	switch {
+	case cal.IsDefault:
+		return store.CalendarKindPrimary
+	case graphOwnedBy(cal, email):
+		return store.CalendarKindOwned
+	default:
+		return store.CalendarKindShared
+	}
+}
+
+// syncFull starts a new delta round over the sync window, narrowed by the
+// calendar's TimeMin/TimeMax when set.
+func (s *GraphSyncer) syncFull(ctx context.Context, source *store.Source, calID int64, graphCalID string, calOpts CalendarOptions) (*Summary, error) {
+	start, end := s.start, s.end
+	if !calOpts.TimeMin.IsZero() {
+		start = calOpts.TimeMin
+	}
+	if !calOpts.TimeMax.IsZero() {
+		end = calOpts.TimeMax
+	}
+	return s.syncPages(ctx, source, calID, calOpts, func() (*graph.EventsPage, error) {
+		return s.client.CalendarViewDelta(ctx, graphCalID, start, end)
+	})
+}
+
+// syncPages processes delta pages starting with first, following next links
+// and saving the final delta link. Each page is stored in one transaction,
+// the last one together with the delta link. A dry run only counts them.
+func (s *GraphSyncer) syncPages(ctx context.Context, source *store.Source, calID int64, calOpts CalendarOptions, first func() (*graph.EventsPage, error)) (*Summary, error) {
+	summary := &Summary{}
+
+	if err := calOpts.wait(ctx); err != nil {
+		return summary, err
+	}
+	page, err := first()
+	for {
+		if err != nil {
+			return summary, err
+		}
+
+		var deleted []string
+		writes := make([]*store.EventWrite, 0, len(page.Events))
+		for _, event := range page.Events {
+			if event.Removed != nil {
+				deleted = append(deleted, event.ID)
+				continue
+			}
+			w := convertGraphEvent(source, calID, event, calOpts)
+			applyProfile(w, calOpts.profile)
+			writes = append(writes, w)
+		}
+
+		var pageSummary *Summary
+		if calOpts.dryRun {
+			pageSummary, err = countPage(s.store, source.ID, deleted, writes)
+		} else {
+			pageSummary, err = s.storePage(source.ID, calID, page, deleted, writes, calOpts)
+		}
+		if err != nil {
+			return summary, err
+		}
+		summary.add(pageSummary)
+
+		if s.progress != nil {
+			for _, w := range writes {
+				if w.Event.Summary != "" {
+					s.progress.OnEvent(w.Event.Summary)
+				}
+			}
+			s.progress.OnPage(calOpts.name, len(page.Events))
+		}
+
+		if page.NextLink == "" {
+			return summary, nil
+		}
+
+		if err := calOpts.wait(ctx); err != nil {
+			return summary, err
+		}
+		page, err = s.client.EventsPage(ctx, page.NextLink)
+	}
+}
+
+// storePage deletes and upserts a page's events in one transaction, saving
+// the delta link with the last page.
+func (s *GraphSyncer) storePage(sourceID, calID int64, page *graph.EventsPage, deleted []string, writes []*store.EventWrite, calOpts CalendarOptions) (*Summary, error) {
+	summary := &Summary{EventsDeleted: len(deleted), EventsFlagged: flagImplausible(s.logger, writes)}
+	var isNew []bool
+	err := s.store.InTx(func(tx *store.Tx) error {
+		for _, id := range deleted {
+			if err := tx.DeleteEvent(sourceID, id); err != nil {
+				return err
+			}
+		}
+		var err error
+		if isNew, err = tx.UpsertEvents(sourceID, writes); err != nil {
+			return err
+		}
+		for _, n := range isNew {
+			if n {
+				summary.EventsAdded++
+			} else {
+				summary.EventsUpdated++
+			}
+		}
+
+		if page.NextLink == "" && page.DeltaLink != "" {
+			return tx.UpdateCalendarSyncToken(calID, page.DeltaLink)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("store page: %w", err)
+	}
+	if calOpts.onChanges != nil {
+		calOpts.onChanges(changes(calOpts.id, writes, isNew, deleted))
+	}
+	return summary, nil
+}
+
+// convertGraphEvent converts a Graph event for storage.
+func convertGraphEvent(source *store.Source, calID int64, ge *graph.Event, calOpts CalendarOptions) *store.EventWrite {
+	event := &store.Event{
+		SourceID:         source.ID,
+		CalendarID:       calID,
+		GoogleEventID:    ge.ID,
+		Summary:          ge.Subject,
+		Description:      ge.Text(),
+		Status:           "confirmed",
+		Visibility:       graph.Visibility(ge.Sensitivity),
+		AllDay:           ge.IsAllDay,
+		OriginalTimezone: ge.OriginalStartTimeZone,
+		RecurringEventID: ge.SeriesMasterID,
+		RecurrenceRule:   graph.RecurrenceRule(ge.Recurrence),
+	}
+	if ge.IsCancelled {
+		event.Status = "cancelled"
+	}
+	if ge.Location != nil {
+		event.Location = ge.Location.DisplayName
+	}
+
+	if t, err := graph.ParseDateTime(ge.Start); err == nil {
+		event.StartTime = sql.NullTime{Time: eventTime(t, ge.IsAllDay, ge.OriginalStartTimeZone), Valid: true}
+	}
+	if t, err := graph.ParseDateTime(ge.End); err == nil {
+		event.EndTime = sql.NullTime{Time: eventTime(t, ge.IsAllDay, ge.OriginalEndTimeZone), Valid: true}
+	}
+
+	var organizerEmail string
+	if ge.Organizer != nil {
+		organizerEmail = ge.Organizer.EmailAddress.Address
+		event.OrganizerEmail = organizerEmail
+		event.OrganizerName = ge.Organizer.EmailAddress.Name
+		event.CreatorEmail = organizerEmail
+	}
+
+	if t, err := time.Parse(time.RFC3339, ge.CreatedDateTime); err == nil {
+		event.CreatedAt = sql.NullTime{Time: t, Valid: true}
+	}
+	if t, err := time.Parse(time.RFC3339, ge.LastModifiedDateTime); err == nil {
+		event.UpdatedAt = sql.NullTime{Time: t, Valid: true}
+	}
+
+	write := &store.EventWrite{Event: event}
+	if calOpts.SkipAttendees {
+		return write
+	}
+
+	// Graph lists the organizer separately; add them as an attendee to
+	// match how Google reports meetings.
+	var attendees []*store.Attendee
+	hasOrganizer := false
+	for _, a := range ge.Attendees {
+		email := a.EmailAddress.Address
+		if email == "" {
+			continue
+		}
+		isOrganizer := strings.EqualFold(email, organizerEmail)
+		hasOrganizer = hasOrganizer || isOrganizer
+		attendees = append(attendees, &store.Attendee{
+			Email:          email,
+			DisplayName:    a.EmailAddress.Name,
+			ResponseStatus: graph.ResponseStatus(a.Status.Response),
+			IsOrganizer:    isOrganizer,
+			IsSelf:         strings.EqualFold(email, source.Identifier),
+		})
+	}
+	if len(attendees) > 0 && !hasOrganizer && organizerEmail != "" {
+		attendees = append(attendees, &store.Attendee{
+			Email:          organizerEmail,
+			DisplayName:    event.OrganizerName,
+			ResponseStatus: "accepted",
+			IsOrganizer:    true,
+			IsSelf:         strings.EqualFold(organizerEmail, source.Identifier),
+		})
+	}
+	if len(attendees) > 0 {
+		write.Attendees = attendees
+	}
+	return write
+}
+
+// eventTime normalizes a parsed start or end time. All-day events are
+// midnight in the event's zone, which Graph shifts when returning UTC, and
+// are stored at UTC midnight of that date. The date is read in zone when
+// it's an IANA name; otherwise rounding to the nearest day recovers it for
+// offsets within 12 hours.
+func eventTime(t time.Time, allDay bool, zone string) time.Time {
+	if !allDay {
+		return t
+	}
+	if loc, err := time.LoadLocation(zone); err == nil && zone != "" {
+		y, m, d := t.In(loc).Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	return t.Add(12 * time.Hour).Truncate(24 * time.Hour)
+}
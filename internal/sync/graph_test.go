@@ -0,0 +1,408 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/salman1993/calvault/internal/graph"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// fakeGraph serves a single calendar with a scripted delta sequence.
+type fakeGraph struct {
+	server       *httptest.Server
+	deltaExpired bool
+}
+
+func newFakeGraph(t *testing.T) *fakeGraph {
+	t.Helper()
+	f := &fakeGraph{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/me/calendars", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"value":[{"id":"cal-1","name":"Calendar","isDefaultCalendar":true,"owner":{"address":"me@example.com"}}]}`)
+	})
+	mux.HandleFunc("/me/calendars/cal-1/calendarView/delta", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("$deltatoken") == "d1" && f.deltaExpired:
+			w.WriteHeader(http.StatusGone)
+			fmt.Fprint(w, `{"error":{"code":"SyncStateNotFound","message":"gone"}}`)
+		case r.URL.Query().Get("$deltatoken") == "d1":
+			fmt.Fprintf(w, `{"value":[
+				{"id":"ev-2","@removed":{"reason":"deleted"}},
+				{"id":"ev-3","subject":"New","start":{"dateTime":"2024-03-06T09:00:00.0000000","timeZone":"UTC"},"end":{"dateTime":"2024-03-06T09:30:00.0000000","timeZone":"UTC"}}
+			],"@odata.deltaLink":"%s/me/calendars/cal-1/calendarView/delta?$deltatoken=d2"}`, f.server.URL)
+		case r.URL.Query().Get("$skiptoken") == "p2":
+			fmt.Fprintf(w, `{"value":[
+				{"id":"ev-2","subject":"Offsite","isAllDay":true,"start":{"dateTime":"2024-03-07T08:00:00.0000000","timeZone":"UTC"},"end":{"dateTime":"2024-03-08T08:00:00.0000000","timeZone":"UTC"}}
+			],"@odata.deltaLink":"%s/me/calendars/cal-1/calendarView/delta?$deltatoken=d1"}`, f.server.URL)
+		default:
+			if r.URL.Query().Get("startDateTime") == "" {
+				t.Errorf("full sync missing startDateTime: %s", r.URL)
+			}
+			fmt.Fprintf(w, `{"value":[
+				{"id":"ev-1","subject":"Planning","sensitivity":"private","seriesMasterId":"series-1",
+				 "start":{"dateTime":"2024-03-05T09:00:00.0000000","timeZone":"UTC"},
+				 "end":{"dateTime":"2024-03-05T10:00:00.0000000","timeZone":"UTC"},
+				 "organizer":{"emailAddress":{"name":"Boss","address":"boss@example.com"}},
+				 "attendees":[
+				   {"emailAddress":{"name":"Me","address":"ME@example.com"},"status":{"response":"tentativelyAccepted"}},
+				   {"emailAddress":{"name":"Pat","address":"pat@example.com"},"status":{"response":"declined"}}
+				 ]}
+			],"@odata.nextLink":"%s/me/calendars/cal-1/calendarView/delta?$skiptoken=p2"}`, f.server.URL)
+		}
+	})
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func TestEventTime(t *testing.T) {
+	utc := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		name   string
+		t      time.Time
+		allDay bool
+		zone   string
+		want   time.Time
+	}{
+		{"timed", utc("2024-03-07T08:30:00Z"), false, "America/Los_Angeles", utc("2024-03-07T08:30:00Z")},
+		{"negative offset", utc("2024-03-07T08:00:00Z"), true, "America/Los_Angeles", utc("2024-03-07T00:00:00Z")},
+		{"start before DST", utc("2024-03-10T08:00:00Z"), true, "America/Los_Angeles", utc("2024-03-10T00:00:00Z")},
+		{"end after DST", utc("2024-03-11T07:00:00Z"), true, "America/Los_Angeles", utc("2024-03-11T00:00:00Z")},
+		{"UTC+14", utc("2024-03-06T10:00:00Z"), true, "Pacific/Kiritimati", utc("2024-03-07T00:00:00Z")},
+		{"Windows zone rounds", utc("2024-03-07T08:00:00Z"), true, "Pacific Standard Time", utc("2024-03-07T00:00:00Z")},
+		{"no zone rounds", utc("2024-03-06T23:00:00Z"), true, "", utc("2024-03-07T00:00:00Z")},
+	}
+	for _, tt := range tests {
+		if got := eventTime(tt.t, tt.allDay, tt.zone); !got.Equal(tt.want) {
+			t.Errorf("%s: eventTime(%v) = %v, want %v", tt.name, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestConvertGraphEvent_EndTimeZone(t *testing.T) {
+	// An all-day trip entered in Los Angeles and ending in Tokyo: each date
+	// is read in its own zone
+	ge := &graph.Event{
+		ID:                    "trip",
+		IsAllDay:              true,
+		Start:                 &graph.DateTimeTimeZone{DateTime: "2024-03-05T08:00:00.0000000", TimeZone: "UTC"},
+		End:                   &graph.DateTimeTimeZone{DateTime: "2024-03-07T15:00:00.0000000", TimeZone: "UTC"},
+		OriginalStartTimeZone: "America/Los_Angeles",
+		OriginalEndTimeZone:   "Asia/Tokyo",
+	}
+	event := convertGraphEvent(&store.Source{ID: 1}, 1, ge, CalendarOptions{}).Event
+	if got := event.StartTime.Time.Format("2006-01-02"); got != "2024-03-05" {
+		t.Errorf("start = %s, want 2024-03-05", got)
+	}
+	if got := event.EndTime.Time.Format("2006-01-02"); got != "2024-03-08" {
+		t.Errorf("end = %s, want 2024-03-08", got)
+	}
+}
+
+func TestGraphSyncer_DeltaSync(t *testing.T) {
+	f := newFakeGraph(t)
+
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	ctx := context.Background()
+	client := graph.NewClient(ctx, nil, graph.WithBaseURL(f.server.URL), graph.WithHTTPClient(f.server.Client()))
+	syncer := NewGraph(client, s)
+
+	// Full sync follows the next link and stores the delta link
+	summary, err := syncer.SyncAccount(ctx, "me@example.com", Options{})
+	if err != nil {
+		t.Fatalf("full sync: %v", err)
+	}
+	if summary.CalendarsSynced != 1 || summary.EventsAdded != 2 {
+		t.Fatalf("full sync summary = %+v, want 1 calendar, 2 added", summary)
+	}
+	if len(summary.Calendars) != 1 {
+		t.Fatalf("calendar results = %+v, want 1", summary.Calendars)
+	}
+	if r := summary.Calendars[0]; r.ID != "cal-1" || r.Name != "Calendar" || r.Status != CalendarSynced || r.EventsAdded != 2 {
+		t.Errorf("calendar result = %+v, want cal-1 synced with 2 added", r)
+	}
+
+	source, err := s.GetSourceByIdentifier("me@example.com")
+	if err != nil || source == nil {
+		t.Fatalf("get source: %v, %v", source, err)
+	}
+	if source.SourceType != store.SourceTypeMicrosoft {
+		t.Errorf("source type = %q, want %q", source.SourceType, store.SourceTypeMicrosoft)
+	}
+
+	events, err := s.ListEvents(store.EventFilter{SourceID: source.ID})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	planning := events[0]
+	if planning.Summary != "Planning" || planning.Visibility != "private" || planning.RecurringEventID != "series-1" {
+		t.Errorf("unexpected event: %+v", planning)
+	}
+	if got := planning.EndTime.Time.Sub(planning.StartTime.Time).Minutes(); got != 60 {
+		t.Errorf("duration = %v minutes, want 60", got)
+	}
+	offsite := events[1]
+	if !offsite.AllDay || offsite.StartTime.Time.Format("2006-01-02 15:04") != "2024-03-07 00:00" {
+		t.Errorf("all-day event start = %v (all_day=%v), want 2024-03-07 midnight", offsite.StartTime.Time, offsite.AllDay)
+	}
+
+	attendees, err := s.ListAttendees(planning.ID)
+	if err != nil {
+		t.Fatalf("list attendees: %v", err)
+	}
+	if len(attendees) != 3 {
+		t.Fatalf("got %d attendees, want 3 (including organizer)", len(attendees))
+	}
+	if !attendees[0].IsSelf || attendees[0].ResponseStatus != "tentative" {
+		t.Errorf("self attendee = %+v", attendees[0])
+	}
+	if !attendees[2].IsOrganizer || attendees[2].Email != "boss@example.com" {
+		t.Errorf("organizer attendee = %+v", attendees[2])
+	}
+
+	cals, err := s.GetCalendars(source.ID)
+	if err != nil {
+		t.Fatalf("get calendars: %v", err)
+	}
+	if !strings.HasSuffix(cals[0].SyncToken.String, "$deltatoken=d1") {
+		t.Errorf("sync token = %q, want delta link", cals[0].SyncToken.String)
+	}
+
+	// Incremental sync applies removals and additions
+	summary, err = syncer.SyncAccount(ctx, "me@example.com", Options{Incremental: true})
+	if err != nil {
+		t.Fatalf("incremental sync: %v", err)
+	}
+	if summary.EventsAdded != 1 || summary.EventsDeleted != 1 {
+		t.Errorf("incremental summary = %+v, want 1 added, 1 deleted", summary)
+	}
+	if exists, _ := s.EventExists(source.ID, "ev-2"); exists {
+		t.Error("removed event still present")
+	}
+
+	// An expired delta link falls back to a full sync
+	if err := s.UpdateCalendarSyncToken(cals[0].ID, f.server.URL+"/me/calendars/cal-1/calendarView/delta?$deltatoken=d1"); err != nil {
+		t.Fatalf("reset sync token: %v", err)
+	}
+	f.deltaExpired = true
+	summary, err = syncer.SyncAccount(ctx, "me@example.com", Options{Incremental: true})
+	if err != nil {
+		t.Fatalf("fallback sync: %v", err)
+	}
+	if summary.CalendarsSynced != 1 || summary.EventsAdded != 1 || summary.EventsUpdated != 1 {
+		t.Errorf("fallback summary = %+v, want full resync (1 added, 1 updated)", summary)
+	}
+}
+
+func TestGraphSyncer_CalendarOptions(t *testing.T) {
+	f := newFakeGraph(t)
+
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	ctx := context.Background()
+	client := graph.NewClient(ctx, nil, graph.WithBaseURL(f.server.URL), graph.WithHTTPClient(f.server.Client()))
+	var gotID, gotName string
+	_, err = NewGraph(client, s).SyncAccount(ctx, "me@example.com", Options{
+		ForCalendar: func(id, name string) CalendarOptions {
+			gotID, gotName = id, name
+			return CalendarOptions{SkipAttendees: true}
+		},
+	})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if gotID != "cal-1" || gotName != "Calendar" {
+		t.Errorf("ForCalendar called with (%q, %q), want (cal-1, Calendar)", gotID, gotName)
+	}
+
+	source, _ := s.GetSourceByIdentifier("me@example.com")
+	events, err := s.ListEvents(store.EventFilter{SourceID: source.ID})
+	if err != nil || len(events) != 2 {
+		t.Fatalf("list events = %d, %v; want 2", len(events), err)
+	}
+	attendees, err := s.ListAttendees(events[0].ID)
+	if err != nil {
+		t.Fatalf("list attendees: %v", err)
+	}
+	if len(attendees) != 0 {
+		t.Errorf("got %d attendees, want none with SkipAttendees", len(attendees))
+	}
+}
+
+func TestGraphSyncer_DryRun(t *testing.T) {
+	f := newFakeGraph(t)
+
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	ctx := context.Background()
+	client := graph.NewClient(ctx, nil, graph.WithBaseURL(f.server.URL), graph.WithHTTPClient(f.server.Client()))
+	syncer := NewGraph(client, s)
+
+	// A dry run of a new account counts everything as added and stores nothing
+	summary, err := syncer.SyncAccount(ctx, "me@example.com", Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if summary.CalendarsSynced != 1 || summary.EventsAdded != 2 {
+		t.Errorf("dry run summary = %+v, want 1 calendar, 2 added", summary)
+	}
+	if source, _ := s.GetSourceByIdentifier("me@example.com"); source != nil {
+		t.Fatal("dry run created a source")
+	}
+
+	if _, err := syncer.SyncAccount(ctx, "me@example.com", Options{}); err != nil {
+		t.Fatalf("full sync: %v", err)
+	}
+	source, _ := s.GetSourceByIdentifier("me@example.com")
+	before, _ := s.GetCalendars(source.ID)
+
+	// An incremental dry run reports the delta without applying it
+	summary, err = syncer.SyncAccount(ctx, "me@example.com", Options{Incremental: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("incremental dry run: %v", err)
+	}
+	if summary.EventsAdded != 1 || summary.EventsDeleted != 1 {
+		t.Errorf("incremental dry run summary = %+v, want 1 added, 1 deleted", summary)
+	}
+	if exists, _ := s.EventExists(source.ID, "ev-2"); !exists {
+		t.Error("dry run deleted an event")
+	}
+	if exists, _ := s.EventExists(source.ID, "ev-3"); exists {
+		t.Error("dry run stored an event")
+	}
+	after, _ := s.GetCalendars(source.ID)
+	if after[0].SyncToken != before[0].SyncToken {
+		t.Errorf("dry run changed sync token from %q to %q", before[0].SyncToken.String, after[0].SyncToken.String)
+	}
+}
+
+// recordingProgress records the Progress calls of a sync.
+type recordingProgress struct {
+	calls []string
+}
+
+func (p *recordingProgress) OnCalendarStart(calendarName string, expectedEvents int) {
+	p.calls = append(p.calls, fmt.Sprintf("start %s ~%d", calendarName, expectedEvents))
+}
+
+func (p *recordingProgress) OnPage(calendarName string, events int) {
+	p.calls = append(p.calls, fmt.Sprintf("page %s %d", calendarName, events))
+}
+
+func (p *recordingProgress) OnCalendarDone(calendarName string, added, updated, deleted int) {
+	p.calls = append(p.calls, fmt.Sprintf("done %s +%d ~%d -%d", calendarName, added, updated, deleted))
+}
+
+func (p *recordingProgress) OnEvent(eventSummary string) {}
+
+func TestGraphSyncer_Progress(t *testing.T) {
+	f := newFakeGraph(t)
+
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	ctx := context.Background()
+	client := graph.NewClient(ctx, nil, graph.WithBaseURL(f.server.URL), graph.WithHTTPClient(f.server.Client()))
+	progress := &recordingProgress{}
+	syncer := NewGraph(client, s).WithProgress(progress)
+
+	// A first full sync doesn't know the calendar's size; a second one
+	// expects the events stored by the first, and incremental ones don't
+	// guess
+	for _, opts := range []Options{{}, {}, {Incremental: true}} {
+		if _, err := syncer.SyncAccount(ctx, "me@example.com", opts); err != nil {
+			t.Fatalf("sync: %v", err)
+		}
+	}
+	want := []string{
+		"start Calendar ~0", "page Calendar 1", "page Calendar 1", "done Calendar +2 ~0 -0",
+		"start Calendar ~2", "page Calendar 1", "page Calendar 1", "done Calendar +0 ~2 -0",
+		"start Calendar ~0", "page Calendar 2", "done Calendar +1 ~0 -1",
+	}
+	if got := strings.Join(progress.calls, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("progress calls =\n%s\nwant\n%s", got, strings.Join(want, "\n"))
+	}
+}
+
+func TestGraphSyncer_OnChanges(t *testing.T) {
+	f := newFakeGraph(t)
+
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	ctx := context.Background()
+	client := graph.NewClient(ctx, nil, graph.WithBaseURL(f.server.URL), graph.WithHTTPClient(f.server.Client()))
+	syncer := NewGraph(client, s)
+
+	var pages []string
+	record := func(c *PageChanges) {
+		var added, updated []string
+		for _, e := range c.Added {
+			added = append(added, e.GoogleEventID)
+		}
+		for _, e := range c.Updated {
+			updated = append(updated, e.GoogleEventID)
+		}
+		pages = append(pages, fmt.Sprintf("%s +%v ~%v -%v", c.CalendarID, added, updated, c.Deleted))
+	}
+	for _, opts := range []Options{{OnChanges: record}, {Incremental: true, OnChanges: record}, {DryRun: true, OnChanges: record}} {
+		if _, err := syncer.SyncAccount(ctx, "me@example.com", opts); err != nil {
+			t.Fatalf("sync: %v", err)
+		}
+	}
+	want := []string{"cal-1 +[ev-1] ~[] -[]", "cal-1 +[ev-2] ~[] -[]", "cal-1 +[ev-3] ~[] -[ev-2]"}
+	if got := strings.Join(pages, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("changes =\n%s\nwant (no dry run changes)\n%s", got, strings.Join(want, "\n"))
+	}
+}
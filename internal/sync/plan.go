@@ -0,0 +1,131 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/salman1993/calvault/internal/calendar"
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// ErrBudgetExhausted is returned once the day's API request budget is spent.
+var ErrBudgetExhausted = errors.New("daily API request budget spent")
+
+// Budget caps the API requests syncs make per local day, across runs and
+// accounts. Usage is kept in the database, so a full sync that runs out
+// stops at its last checkpoint and the next day's runs carry on.
+type Budget struct {
+	store *store.Store
+	limit int
+	now   func() time.Time
+}
+
+// NewBudget returns a budget of limit requests a day.
+func NewBudget(s *store.Store, limit int) *Budget {
+	return &Budget{store: s, limit: limit, now: time.Now}
+}
+
+// Limit returns the number of requests allowed per day.
+func (b *Budget) Limit() int {
+	return b.limit
+}
+
+// Used returns the number of requests counted today.
+func (b *Budget) Used() (int, error) {
+	return b.store.APIUsage(b.day())
+}
+
+// Spend counts n requests made outside a sync, such as by an estimate,
+// even if that goes over the budget.
+func (b *Budget) Spend(n int) error {
+	return b.store.AddAPIUsage(b.day(), n)
+}
+
+// take counts one request, or returns ErrBudgetExhausted. A nil budget
+// allows every request.
+func (b *Budget) take(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ok, err := b.store.TakeAPIRequest(b.day(), b.limit)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrBudgetExhausted
+	}
+	return nil
+}
+
+func (b *Budget) day() string {
+	return b.now().Format("2006-01-02")
+}
+
+// PlanEntries turns an estimate into sync plan entries: the primary
+// calendar first, then the rest from cheapest to most expensive, so most
+// calendars are complete early on.
+func PlanEntries(est *Estimate) []*store.SyncPlanEntry {
+	calendars := append([]CalendarEstimate(nil), est.Calendars...)
+	sort.SliceStable(calendars, func(i, j int) bool {
+		if calendars[i].Primary != calendars[j].Primary {
+			return calendars[i].Primary
+		}
+		return calendars[i].Requests < calendars[j].Requests
+	})
+	entries := make([]*store.SyncPlanEntry, len(calendars))
+	for i, c := range calendars {
+		entries[i] = &store.SyncPlanEntry{
+			GoogleCalendarID:  c.ID,
+			Summary:           c.Name,
+			EstimatedEvents:   c.Events,
+			EstimatedRequests: c.Requests,
+		}
+	}
+	return entries
+}
+
+// PlanDays returns for each entry the day, counted from today, on which its
+// full sync should finish when limit requests are allowed per day and used
+// have been made today. Entries are synced in order; a limit of 0 means
+// everything finishes today.
+func PlanDays(entries []*store.SyncPlanEntry, limit, used int) []int {
+	days := make([]int, len(entries))
+	if limit <= 0 {
+		return days
+	}
+	total := used
+	for i, e := range entries {
+		if e.Done {
+			continue
+		}
+		total += e.Remaining()
+		days[i] = (total - 1) / limit
+	}
+	return days
+}
+
+// orderByPlan sorts calendars in the account's plan order; calendars not in
+// the plan keep their order after the planned ones.
+func orderByPlan(calendars []*calendar.CalendarEntry, plan []*store.SyncPlanEntry) {
+	if len(plan) == 0 {
+		return
+	}
+	position := make(map[string]int, len(plan))
+	for i, e := range plan {
+		position[e.GoogleCalendarID] = i
+	}
+	rank := func(id string) int {
+		if p, ok := position[id]; ok {
+			return p
+		}
+		return len(plan)
+	}
+	sort.SliceStable(calendars, func(i, j int) bool {
+		return rank(calendars[i].ID) < rank(calendars[j].ID)
+	})
+}
@@ -0,0 +1,39 @@
+package sync
+
+import (
+	"context"
+	stdsync "sync"
+)
+
+// runParallel calls fn for each index in [0, n) using up to workers
+// goroutines, returning once all calls have finished. No new calls start
+// after ctx is cancelled.
+func runParallel(ctx context.Context, workers, n int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	indexes := make(chan int)
+	var wg stdsync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(indexes)
+	wg.Wait()
+}
@@ -0,0 +1,44 @@
+package sync
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunParallel(t *testing.T) {
+	var running, peak, calls atomic.Int32
+	runParallel(context.Background(), 3, 10, func(i int) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		calls.Add(1)
+	})
+
+	if calls.Load() != 10 {
+		t.Errorf("calls = %d, want 10", calls.Load())
+	}
+	if p := peak.Load(); p < 2 || p > 3 {
+		t.Errorf("peak concurrency = %d, want 2-3", p)
+	}
+}
+
+func TestRunParallel_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	runParallel(ctx, 1, 10, func(i int) {
+		if calls.Add(1) == 2 {
+			cancel()
+		}
+	})
+	if c := calls.Load(); c > 3 {
+		t.Errorf("calls = %d after cancel, want at most 3", c)
+	}
+}
@@ -0,0 +1,39 @@
+package sync
+
+import "github.com/salman1993/calvault/internal/store"
+
+// Sync profiles choose which event details a sync stores, trading detail
+// against database size and privacy per account.
+const (
+	// ProfileMinimal stores titles and times, with each event's status and
+	// recurrence.
+	ProfileMinimal = "minimal"
+	// ProfileStandard adds locations, organizers, visibility, colors and
+	// reminders.
+	ProfileStandard = "standard"
+	// ProfileFull adds descriptions, attendees and conference details. It
+	// is the default.
+	ProfileFull = "full"
+)
+
+// applyProfile strips the details profile leaves out of a converted event.
+// They are cleared in the database too, so an account moved to a leaner
+// profile sheds them as its events are synced again.
+func applyProfile(w *store.EventWrite, profile string) {
+	if profile == "" || profile == ProfileFull {
+		return
+	}
+	w.Event.Description = ""
+	w.Attendees = []*store.Attendee{}
+	w.Conference = &store.Conference{}
+	if profile == ProfileStandard {
+		return
+	}
+
+	e := w.Event
+	e.Location = ""
+	e.OrganizerEmail, e.OrganizerName, e.CreatorEmail = "", "", ""
+	e.Visibility = ""
+	e.ColorID = ""
+	w.Reminders = &store.EventReminders{}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	stdsync "sync"
 	"time"
 
 	"github.com/salman1993/calvault/internal/calendar"
@@ -19,25 +20,204 @@ import (
 // ErrSyncTokenExpired indicates the sync token is no longer valid.
 var ErrSyncTokenExpired = errors.New("sync token expired (410 Gone)")
 
-// Progress reports sync progress.
+// Progress reports sync progress. Calendars may sync concurrently, so
+// implementations must be safe for concurrent use.
 type Progress interface {
-	OnCalendarStart(calendarName string)
+	// OnCalendarStart is called as a calendar starts syncing, with the
+	// number of events it is expected to fetch, or 0 if unknown.
+	OnCalendarStart(calendarName string, expectedEvents int)
+	// OnPage is called after each page of events is stored (or counted,
+	// in a dry run), with the number of events on it.
+	OnPage(calendarName string, events int)
 	OnCalendarDone(calendarName string, added, updated, deleted int)
 	OnEvent(eventSummary string)
 }
 
 // Summary contains sync run statistics.
 type Summary struct {
-	CalendarsSynced int
-	EventsAdded     int
-	EventsUpdated   int
-	EventsDeleted   int
-	Duration        time.Duration
+	CalendarsSynced   int
+	CalendarsSkipped  int // Excluded by Options.IncludeCalendar
+	CalendarsDeferred int // Left for a later run once Options.Budget was spent
+	CalendarsFailed   int // Logged and left for the next sync
+	EventsAdded       int
+	EventsUpdated     int
+	EventsDeleted     int
+	EventsMerged      int // ICS-imported copies folded into synced events
+	EventsFlagged     int // Stored with implausible dates (store.EventQuality)
+	Duration          time.Duration
+
+	// Calendars has a result per calendar synced, failed or deferred, in
+	// the order they were listed; excluded calendars are left out.
+	Calendars []CalendarResult
+}
+
+// Calendar result statuses.
+const (
+	CalendarSynced   = "synced"
+	CalendarFailed   = "failed"
+	CalendarDeferred = "deferred"
+)
+
+// CalendarResult is how one calendar's sync went.
+type CalendarResult struct {
+	ID            string // The provider's calendar ID
+	Name          string
+	Status        string // CalendarSynced, CalendarFailed or CalendarDeferred
+	Error         string // Why it failed
+	EventsAdded   int
+	EventsUpdated int
+	EventsDeleted int
+	EventsMerged  int
+	EventsFlagged int
+	Duration      time.Duration
+}
+
+// calendarResult returns a calendar's result from what syncCalendar
+// returned.
+func calendarResult(id, name string, calSummary *Summary, err error, start time.Time) CalendarResult {
+	r := CalendarResult{ID: id, Name: name, Status: CalendarSynced, Duration: time.Since(start)}
+	switch {
+	case errors.Is(err, ErrBudgetExhausted):
+		r.Status = CalendarDeferred
+	case err != nil:
+		r.Status, r.Error = CalendarFailed, err.Error()
+	default:
+		r.EventsAdded = calSummary.EventsAdded
+		r.EventsUpdated = calSummary.EventsUpdated
+		r.EventsDeleted = calSummary.EventsDeleted
+		r.EventsMerged = calSummary.EventsMerged
+		r.EventsFlagged = calSummary.EventsFlagged
+	}
+	return r
+}
+
+// PageChanges are the event changes a committed page made.
+type PageChanges struct {
+	CalendarID string         // The provider's calendar ID
+	Added      []*store.Event // As stored
+	Updated    []*store.Event
+	Deleted    []string // The provider's event IDs
+}
+
+// changes sorts a page's writes into a PageChanges by whether they were
+// new.
+func changes(calendarID string, writes []*store.EventWrite, isNew []bool, deleted []string) *PageChanges {
+	c := &PageChanges{CalendarID: calendarID, Deleted: deleted}
+	for i, w := range writes {
+		if isNew[i] {
+			c.Added = append(c.Added, w.Event)
+		} else {
+			c.Updated = append(c.Updated, w.Event)
+		}
+	}
+	return c
 }
 
 // Options configures sync behavior.
 type Options struct {
 	Incremental bool
+
+	// DryRun fetches events and counts what would change without writing
+	// anything. Interrupted full syncs are counted from the beginning.
+	DryRun bool
+
+	// Profile chooses which event details are stored: ProfileMinimal,
+	// ProfileStandard or ProfileFull (the default if empty).
+	Profile string
+
+	// Concurrency is the number of calendars synced at once (default 1).
+	// All workers share the client's rate limiter.
+	Concurrency int
+
+	// IncludeCalendar reports whether a calendar should be synced; nil
+	// syncs all of them.
+	IncludeCalendar func(id, name string) bool
+
+	// ForCalendar returns per-calendar overrides; nil means defaults for all.
+	ForCalendar func(id, name string) CalendarOptions
+
+	// OnChanges, if set, is called with each page's event changes once the
+	// page is committed; dry runs don't call it. Calendars may sync
+	// concurrently, so it must be safe for concurrent use.
+	OnChanges func(*PageChanges)
+
+	// Budget, if set, caps the day's API requests; dry runs ignore it.
+	// Calendars are synced in the account's sync plan order, if it has one.
+	Budget *Budget
+}
+
+// CalendarOptions overrides sync behavior for a single calendar.
+type CalendarOptions struct {
+	// TimeMin and TimeMax bound full syncs; zero means unbounded (Google) or
+	// the syncer's default window (Microsoft).
+	TimeMin time.Time
+	TimeMax time.Time
+
+	// SingleEvents expands recurring events into instances (Google only).
+	// Changing it requires a full sync, since sync tokens carry it.
+	SingleEvents bool
+
+	// SkipAttendees stores events without their attendee lists.
+	SkipAttendees bool
+
+	// RateLimiter, if set, is waited on before each page request in addition
+	// to the client's own limiter.
+	RateLimiter *calendar.RateLimiter
+
+	dryRun  bool    // Copied from Options.DryRun
+	profile string  // Copied from Options.Profile
+	budget  *Budget // Copied from Options.Budget, nil for dry runs
+	id      string  // The calendar's ID and name, for Progress and OnChanges
+	name    string
+
+	onChanges func(*PageChanges) // Copied from Options.OnChanges, nil for dry runs
+}
+
+// calendarOptions returns the overrides for a calendar.
+func (o Options) calendarOptions(id, name string) CalendarOptions {
+	var calOpts CalendarOptions
+	if o.ForCalendar != nil {
+		calOpts = o.ForCalendar(id, name)
+	}
+	calOpts.dryRun = o.DryRun
+	calOpts.profile = o.Profile
+	calOpts.id, calOpts.name = id, name
+	if !o.DryRun {
+		calOpts.budget = o.Budget
+		calOpts.onChanges = o.OnChanges
+	}
+	return calOpts
+}
+
+// includes reports whether a calendar passes the IncludeCalendar filter.
+func (o Options) includes(id, name string) bool {
+	return o.IncludeCalendar == nil || o.IncludeCalendar(id, name)
+}
+
+// filterCalendars returns the calendars opts includes, logging the rest.
+func filterCalendars[T any](logger *slog.Logger, opts Options, calendars []*T, idName func(*T) (string, string)) []*T {
+	var included []*T
+	for _, cal := range calendars {
+		id, name := idName(cal)
+		if !opts.includes(id, name) {
+			logger.Debug("skipping excluded calendar", "calendar", name, "calendar_id", id)
+			continue
+		}
+		included = append(included, cal)
+	}
+	return included
+}
+
+// wait counts a request against the budget and applies the per-calendar
+// rate limit, if any.
+func (o CalendarOptions) wait(ctx context.Context) error {
+	if err := o.budget.take(ctx); err != nil {
+		return err
+	}
+	if o.RateLimiter == nil {
+		return nil
+	}
+	return o.RateLimiter.Wait(ctx)
 }
 
 // Syncer orchestrates calendar synchronization.
@@ -57,7 +237,8 @@ func New(client *calendar.Client, store *store.Store) *Syncer {
 	}
 }
 
-// WithLogger sets the logger.
+// WithLogger sets the logger. Logs don't name the account; pass a logger
+// With an "account" attribute to tell accounts apart.
 func (s *Syncer) WithLogger(logger *slog.Logger) *Syncer {
 	s.logger = logger
 	return s
@@ -75,135 +256,204 @@ func (s *Syncer) SyncAccount(ctx context.Context, email string, opts Options) (*
 	summary := &Summary{}
 
 	// Get or create source
-	source, err := s.store.GetOrCreateSource(email)
+	source, err := getSource(s.store, store.SourceTypeGoogle, email, opts.DryRun)
 	if err != nil {
 		return nil, fmt.Errorf("get source: %w", err)
 	}
 
 	// List calendars from API
+	if !opts.DryRun {
+		if err := opts.Budget.take(ctx); err != nil {
+			return nil, err
+		}
+	}
 	calendars, err := s.client.ListCalendars(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("list calendars: %w", err)
 	}
 
-	s.logger.Info("found calendars", "count", len(calendars), "email", email)
+	s.logger.Info("found calendars", "count", len(calendars))
+	included := filterCalendars(s.logger, opts, calendars, func(c *calendar.CalendarEntry) (string, string) {
+		return c.ID, c.Summary
+	})
+	summary.CalendarsSkipped = len(calendars) - len(included)
+	calendars = included
 
-	// Sync each calendar
-	for _, cal := range calendars {
-		if ctx.Err() != nil {
-			break
-		}
-
-		// Store/update calendar metadata
-		storeCal := &store.Calendar{
-			GoogleCalendarID: cal.ID,
-			Summary:          cal.Summary,
-			Description:      cal.Description,
-			Timezone:         cal.TimeZone,
-			IsPrimary:        cal.IsPrimary,
-		}
+	plan, err := s.store.GetSyncPlan(source.ID)
+	if err != nil {
+		return nil, err
+	}
+	orderByPlan(calendars, plan)
+	planned := make(map[string]int, len(plan))
+	for _, e := range plan {
+		planned[e.GoogleCalendarID] = e.EstimatedEvents
+	}
 
-		calID, err := s.store.UpsertCalendar(source.ID, storeCal)
-		if err != nil {
-			s.logger.Error("failed to upsert calendar", "calendar", cal.Summary, "error", err)
-			continue
+	// Sync calendars, several at once if configured
+	var mu stdsync.Mutex
+	results := make([]*CalendarResult, len(calendars))
+	runParallel(ctx, opts.Concurrency, len(calendars), func(i int) {
+		cal := calendars[i]
+		calStart := time.Now()
+		calSummary, err := s.syncCalendar(ctx, source, cal, planned[cal.ID], opts)
+		r := calendarResult(cal.ID, cal.Summary, calSummary, err, calStart)
+		results[i] = &r
+		if errors.Is(err, ErrBudgetExhausted) {
+			s.logger.Info("daily request budget spent, deferring calendar", "calendar", cal.Summary)
+			mu.Lock()
+			summary.CalendarsDeferred++
+			mu.Unlock()
+			return
 		}
-
-		// Get stored calendar with sync token
-		storedCals, err := s.store.GetCalendars(source.ID)
 		if err != nil {
-			s.logger.Error("failed to get calendars", "error", err)
-			continue
+			s.logger.Error("failed to sync calendar", "calendar", cal.Summary, "error", err)
+			mu.Lock()
+			summary.CalendarsFailed++
+			mu.Unlock()
+			return
 		}
 
-		var storedCal *store.Calendar
-		for _, c := range storedCals {
-			if c.ID == calID {
-				storedCal = c
-				break
-			}
-		}
+		mu.Lock()
+		summary.CalendarsSynced++
+		summary.add(calSummary)
+		mu.Unlock()
+	})
 
-		if s.progress != nil {
-			s.progress.OnCalendarStart(cal.Summary)
-		}
+	summary.addResults(results)
+	summary.Duration = time.Since(startTime)
+	return summary, nil
+}
 
-		// Sync events
-		var calSummary *Summary
-		if opts.Incremental && storedCal.SyncToken.Valid && storedCal.SyncToken.String != "" {
-			calSummary, err = s.syncCalendarIncremental(ctx, source.ID, calID, cal.ID, storedCal.SyncToken.String)
-			if errors.Is(err, ErrSyncTokenExpired) {
-				// Clear token and fall back to full sync
-				s.logger.Info("sync token expired, falling back to full sync", "calendar", cal.Summary)
+// syncCalendar stores a calendar's metadata and syncs its events. planned
+// is the sync plan's estimate of the calendar's events, or 0.
+func (s *Syncer) syncCalendar(ctx context.Context, source *store.Source, cal *calendar.CalendarEntry, planned int, opts Options) (*Summary, error) {
+	// Store/update calendar metadata
+	storeCal := &store.Calendar{
+		GoogleCalendarID: cal.ID,
+		Summary:          cal.Summary,
+		Description:      cal.Description,
+		Timezone:         cal.TimeZone,
+		IsPrimary:        cal.IsPrimary,
+		AccessRole:       cal.AccessRole,
+		SubscriptionKind: calendarKind(cal),
+		ColorID:          cal.ColorID,
+		BackgroundColor:  cal.Color,
+		DefaultReminders: convertReminders(cal.DefaultReminders),
+	}
+	storedCal, err := getCalendar(s.store, source.ID, storeCal, opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	calID := storedCal.ID
+
+	// Sync events
+	calOpts := opts.calendarOptions(cal.ID, cal.Summary)
+	var calSummary *Summary
+	incremental := opts.Incremental && storedCal.SyncToken.Valid && storedCal.SyncToken.String != ""
+	if s.progress != nil {
+		resumed := storedCal.PageToken.String != "" && !opts.DryRun
+		s.progress.OnCalendarStart(cal.Summary, expectedEvents(s.store, source.ID, calID, planned, incremental, resumed))
+	}
+	if incremental {
+		calSummary, err = s.syncCalendarIncremental(ctx, source.ID, calID, cal.ID, storedCal.SyncToken.String, calOpts)
+		if errors.Is(err, ErrSyncTokenExpired) {
+			// Clear token and fall back to full sync
+			s.logger.Info("sync token expired, falling back to full sync", "calendar", cal.Summary)
+			if !opts.DryRun {
 				if clearErr := s.store.ClearCalendarSyncToken(calID); clearErr != nil {
 					s.logger.Error("failed to clear sync token", "error", clearErr)
 				}
-				calSummary, err = s.syncCalendarFull(ctx, source.ID, calID, cal.ID)
 			}
-		} else {
-			calSummary, err = s.syncCalendarFull(ctx, source.ID, calID, cal.ID)
+			calSummary, err = s.syncCalendarFull(ctx, source.ID, calID, cal.ID, "", calOpts)
 		}
-
-		if err != nil {
-			s.logger.Error("failed to sync calendar", "calendar", cal.Summary, "error", err)
-			continue
+	} else {
+		resumeToken := storedCal.PageToken.String
+		if opts.DryRun {
+			resumeToken = ""
 		}
-
-		summary.CalendarsSynced++
-		summary.EventsAdded += calSummary.EventsAdded
-		summary.EventsUpdated += calSummary.EventsUpdated
-		summary.EventsDeleted += calSummary.EventsDeleted
-
-		if s.progress != nil {
-			s.progress.OnCalendarDone(cal.Summary, calSummary.EventsAdded, calSummary.EventsUpdated, calSummary.EventsDeleted)
+		if resumeToken != "" {
+			s.logger.Info("resuming interrupted full sync", "calendar", cal.Summary)
 		}
+		calSummary, err = s.syncCalendarFull(ctx, source.ID, calID, cal.ID, resumeToken, calOpts)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	summary.Duration = time.Since(startTime)
-	return summary, nil
+	if s.progress != nil {
+		s.progress.OnCalendarDone(cal.Summary, calSummary.EventsAdded, calSummary.EventsUpdated, calSummary.EventsDeleted)
+	}
+	return calSummary, nil
 }
 
-// syncCalendarFull performs a full sync of a calendar.
-func (s *Syncer) syncCalendarFull(ctx context.Context, sourceID, calID int64, googleCalID string) (*Summary, error) {
+// calendarKind classifies a calendar-list entry so queries can tell the
+// user's own calendars apart from feeds they follow. Google serves public
+// feeds (holidays, sports, birthdays) from group.v.calendar.google.com and
+// calendars subscribed by URL from import.calendar.google.com.
+func calendarKind(cal *calendar.CalendarEntry) string {
+	switch {
+	case cal.IsPrimary:
+		return store.CalendarKindPrimary
+	case strings.HasSuffix(cal.ID, "@group.v.calendar.google.com"),
+		strings.HasSuffix(cal.ID, "@import.calendar.google.com"):
+		return store.CalendarKindSubscription
+	case cal.AccessRole == "owner":
+		return store.CalendarKindOwned
+	default:
+		return store.CalendarKindShared
+	}
+}
+
+// syncCalendarFull performs a full sync of a calendar. Each page is stored in
+// one transaction together with the token of the next page, so an
+// interrupted full sync resumes from resumeToken rather than the beginning.
+func (s *Syncer) syncCalendarFull(ctx context.Context, sourceID, calID int64, googleCalID, resumeToken string, calOpts CalendarOptions) (*Summary, error) {
 	summary := &Summary{}
-	pageToken := ""
+	pageToken := resumeToken
 
 	for {
+		if err := calOpts.wait(ctx); err != nil {
+			return summary, err
+		}
 		page, err := s.client.ListEvents(ctx, googleCalID, calendar.ListEventsOptions{
 			PageToken:    pageToken,
 			ShowDeleted:  false,
-			SingleEvents: false, // Keep recurring event structure
+			SingleEvents: calOpts.SingleEvents, // Default keeps recurring event structure
+			TimeMin:      calOpts.TimeMin,
+			TimeMax:      calOpts.TimeMax,
 		})
+		if err != nil && pageToken != "" && pageToken == resumeToken && ctx.Err() == nil {
+			// The checkpoint may have expired or no longer match the
+			// calendar's options; start over.
+			s.logger.Warn("cannot resume full sync, restarting", "calendar_id", googleCalID, "error", err)
+			pageToken, resumeToken = "", ""
+			continue
+		}
 		if err != nil {
 			return summary, fmt.Errorf("list events: %w", err)
 		}
 
-		for _, event := range page.Events {
-			isNew, err := s.processEvent(ctx, sourceID, calID, event)
-			if err != nil {
-				s.logger.Error("failed to process event", "event", event.Id, "error", err)
-				continue
+		// Save the next page as a checkpoint, or the sync token for future
+		// incremental syncs after the last page.
+		pageSummary, err := s.storePage(ctx, sourceID, calID, page.Events, false, calOpts, func(tx *store.Tx) error {
+			if err := tx.AddSyncPlanRequest(sourceID, googleCalID); err != nil {
+				return err
 			}
-
-			if isNew {
-				summary.EventsAdded++
-			} else {
-				summary.EventsUpdated++
+			if page.NextPageToken != "" {
+				return tx.SetCalendarPageToken(calID, page.NextPageToken)
 			}
-
-			if s.progress != nil && event.Summary != "" {
-				s.progress.OnEvent(event.Summary)
+			if page.NextSyncToken != "" {
+				return tx.UpdateCalendarSyncToken(calID, page.NextSyncToken)
 			}
+			return tx.SetCalendarPageToken(calID, "")
+		})
+		if err != nil {
+			return summary, err
 		}
+		summary.add(pageSummary)
 
 		pageToken = page.NextPageToken
 		if pageToken == "" {
-			// Save sync token for future incremental syncs
-			if page.NextSyncToken != "" {
-				if err := s.store.UpdateCalendarSyncToken(calID, page.NextSyncToken); err != nil {
-					s.logger.Error("failed to save sync token", "error", err)
-				}
-			}
 			break
 		}
 	}
@@ -212,15 +462,23 @@ func (s *Syncer) syncCalendarFull(ctx context.Context, sourceID, calID int64, go
 }
 
 // syncCalendarIncremental performs an incremental sync using sync token.
-func (s *Syncer) syncCalendarIncremental(ctx context.Context, sourceID, calID int64, googleCalID, syncToken string) (*Summary, error) {
+// Pages are stored atomically; the new sync token is saved with the last
+// page, so an interrupted sync replays from the old token.
+func (s *Syncer) syncCalendarIncremental(ctx context.Context, sourceID, calID int64, googleCalID, syncToken string, calOpts CalendarOptions) (*Summary, error) {
 	summary := &Summary{}
 	pageToken := ""
 	currentSyncToken := syncToken
 
 	for {
+		if err := calOpts.wait(ctx); err != nil {
+			return summary, err
+		}
+		// The time window can't be combined with a sync token; the token
+		// already covers the window of the full sync that produced it.
 		opts := calendar.ListEventsOptions{
-			PageToken:   pageToken,
-			ShowDeleted: true, // Need to see deleted events
+			PageToken:    pageToken,
+			ShowDeleted:  true, // Need to see deleted events
+			SingleEvents: calOpts.SingleEvents,
 		}
 		if pageToken == "" {
 			opts.SyncToken = currentSyncToken
@@ -236,60 +494,164 @@ func (s *Syncer) syncCalendarIncremental(ctx context.Context, sourceID, calID in
 			return summary, fmt.Errorf("list events: %w", err)
 		}
 
-		for _, event := range page.Events {
-			// Handle deleted events
-			if event.Status == "cancelled" {
-				if err := s.store.DeleteEvent(sourceID, event.Id); err != nil {
-					s.logger.Error("failed to delete event", "event", event.Id, "error", err)
-				} else {
-					summary.EventsDeleted++
-				}
-				continue
+		pageSummary, err := s.storePage(ctx, sourceID, calID, page.Events, true, calOpts, func(tx *store.Tx) error {
+			if page.NextPageToken == "" && page.NextSyncToken != "" {
+				return tx.UpdateCalendarSyncToken(calID, page.NextSyncToken)
 			}
+			return nil
+		})
+		if err != nil {
+			return summary, err
+		}
+		summary.add(pageSummary)
 
-			isNew, err := s.processEvent(ctx, sourceID, calID, event)
-			if err != nil {
-				s.logger.Error("failed to process event", "event", event.Id, "error", err)
-				continue
-			}
+		pageToken = page.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
 
-			if isNew {
+	return summary, nil
+}
+
+// storePage stores a page of events in one transaction, finishing with
+// checkpoint. With deleteCancelled, cancelled events are removed rather than
+// stored. Events are written as one batch; any failure discards the whole
+// page, which is fetched again by the next sync.
+func (s *Syncer) storePage(ctx context.Context, sourceID, calID int64, events []*gcalendar.Event, deleteCancelled bool, calOpts CalendarOptions, checkpoint func(tx *store.Tx) error) (*Summary, error) {
+	summary := &Summary{}
+	var deleted []string
+	writes := make([]*store.EventWrite, 0, len(events))
+	for _, event := range events {
+		if deleteCancelled && event.Status == "cancelled" {
+			deleted = append(deleted, event.Id)
+			continue
+		}
+		w := convertEvent(sourceID, calID, event, calOpts)
+		applyProfile(w, calOpts.profile)
+		writes = append(writes, w)
+	}
+
+	if calOpts.dryRun {
+		return countPage(s.store, sourceID, deleted, writes)
+	}
+	summary.EventsFlagged = flagImplausible(s.logger, writes)
+
+	var isNew []bool
+	err := s.store.InTx(func(tx *store.Tx) error {
+		for _, id := range deleted {
+			if err := tx.DeleteEvent(sourceID, id); err != nil {
+				return err
+			}
+		}
+		var err error
+		if isNew, err = tx.UpsertEvents(sourceID, writes); err != nil {
+			return err
+		}
+		for _, n := range isNew {
+			if n {
 				summary.EventsAdded++
 			} else {
 				summary.EventsUpdated++
 			}
+		}
+		stored := make([]*store.Event, len(writes))
+		for i, w := range writes {
+			stored[i] = w.Event
+		}
+		if summary.EventsMerged, err = tx.MergeImportedCopies(stored); err != nil {
+			return err
+		}
+		return checkpoint(tx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("store page: %w", err)
+	}
+	summary.EventsDeleted = len(deleted)
+	if calOpts.onChanges != nil {
+		calOpts.onChanges(changes(calOpts.id, writes, isNew, deleted))
+	}
 
-			if s.progress != nil && event.Summary != "" {
-				s.progress.OnEvent(event.Summary)
+	if s.progress != nil {
+		for _, w := range writes {
+			if w.Event.Summary != "" {
+				s.progress.OnEvent(w.Event.Summary)
 			}
 		}
+		s.progress.OnPage(calOpts.name, len(events))
+	}
+	return summary, nil
+}
 
-		pageToken = page.NextPageToken
-		if pageToken == "" {
-			// Save new sync token
-			if page.NextSyncToken != "" {
-				if err := s.store.UpdateCalendarSyncToken(calID, page.NextSyncToken); err != nil {
-					s.logger.Error("failed to save sync token", "error", err)
-				}
-			}
-			break
+// expectedEvents estimates the events a calendar's sync will fetch, for
+// Progress: for a full sync, the larger of its sync plan estimate and the
+// events stored from earlier syncs; for a resumed one, what the plan
+// expects beyond the events already stored. Incremental syncs and resumed
+// syncs without a plan are unknown (0).
+func expectedEvents(s *store.Store, sourceID, calID int64, planned int, incremental, resumed bool) int {
+	if incremental {
+		return 0
+	}
+	if calID == 0 { // A calendar a dry run hasn't stored
+		return planned
+	}
+	stored, err := s.CountEvents(store.EventFilter{SourceID: sourceID, CalendarID: calID})
+	if err != nil {
+		return planned
+	}
+	if resumed {
+		return max(planned-int(stored), 0)
+	}
+	return max(planned, int(stored))
+}
+
+// add accumulates another summary's event counts.
+func (sum *Summary) add(other *Summary) {
+	sum.EventsAdded += other.EventsAdded
+	sum.EventsUpdated += other.EventsUpdated
+	sum.EventsDeleted += other.EventsDeleted
+	sum.EventsMerged += other.EventsMerged
+	sum.EventsFlagged += other.EventsFlagged
+}
+
+// addResults adds the results of the calendars that ran; calendars an
+// interrupted sync didn't reach have none.
+func (sum *Summary) addResults(results []*CalendarResult) {
+	for _, r := range results {
+		if r != nil {
+			sum.Calendars = append(sum.Calendars, *r)
 		}
 	}
+}
 
-	return summary, nil
+// flagImplausible counts and logs the events of a page whose dates can't be
+// right. They are stored anyway, with a quality flag that keeps them out of
+// analytics.
+func flagImplausible(logger *slog.Logger, writes []*store.EventWrite) int {
+	now := time.Now()
+	n := 0
+	for _, w := range writes {
+		if q := store.EventQuality(w.Event, now); q != "" {
+			logger.Warn("implausible event dates", "google_event_id", w.Event.GoogleEventID, "quality", q)
+			n++
+		}
+	}
+	return n
 }
 
-// processEvent converts and stores a Google Calendar event.
-func (s *Syncer) processEvent(_ context.Context, sourceID, calID int64, ge *gcalendar.Event) (bool, error) {
+// convertEvent converts a Google Calendar event for storage.
+func convertEvent(sourceID, calID int64, ge *gcalendar.Event, calOpts CalendarOptions) *store.EventWrite {
 	event := &store.Event{
 		SourceID:      sourceID,
 		CalendarID:    calID,
 		GoogleEventID: ge.Id,
+		ICalUID:       ge.ICalUID,
 		Summary:       ge.Summary,
 		Description:   ge.Description,
 		Location:      ge.Location,
 		Status:        ge.Status,
 		Visibility:    ge.Visibility,
+		ColorID:       ge.ColorId,
 	}
 
 	// Parse start time
@@ -355,24 +717,20 @@ func (s *Syncer) processEvent(_ context.Context, sourceID, calID int64, ge *gcal
 		}
 	}
 
-	// Check if event exists (to determine if it's new)
-	var existingID int64
-	err := s.store.DB().QueryRow(
-		`SELECT id FROM events WHERE source_id = ? AND google_event_id = ?`,
-		sourceID, ge.Id,
-	).Scan(&existingID)
-	isNew := err == sql.ErrNoRows
-
-	// Upsert event
-	eventID, err := s.store.UpsertEvent(event)
-	if err != nil {
-		return false, fmt.Errorf("upsert event: %w", err)
+	write := &store.EventWrite{Event: event, Conference: convertConference(ge)}
+	if ge.Reminders != nil {
+		write.Reminders = &store.EventReminders{
+			UseDefault: ge.Reminders.UseDefault,
+			Overrides:  convertReminders(ge.Reminders.Overrides),
+		}
+	}
+	if calOpts.SkipAttendees {
+		return write
 	}
 
-	// Store attendees
-	var attendees []*store.Attendee
+	// Attendees
 	for _, a := range ge.Attendees {
-		attendees = append(attendees, &store.Attendee{
+		write.Attendees = append(write.Attendees, &store.Attendee{
 			Email:          a.Email,
 			DisplayName:    a.DisplayName,
 			ResponseStatus: a.ResponseStatus,
@@ -380,11 +738,47 @@ func (s *Syncer) processEvent(_ context.Context, sourceID, calID int64, ge *gcal
 			IsSelf:         a.Self,
 		})
 	}
-	if len(attendees) > 0 {
-		if err := s.store.ReplaceAttendees(eventID, attendees); err != nil {
-			s.logger.Warn("failed to store attendees", "event", ge.Id, "error", err)
+	return write
+}
+
+// convertConference converts an event's conference data and Meet link,
+// returning an empty Conference for events without one so that a removed
+// conference is removed from the store too.
+func convertConference(ge *gcalendar.Event) *store.Conference {
+	c := &store.Conference{HangoutLink: ge.HangoutLink}
+	cd := ge.ConferenceData
+	if cd == nil {
+		return c
+	}
+	c.ConferenceID = cd.ConferenceId
+	if cd.ConferenceSolution != nil {
+		c.SolutionName = cd.ConferenceSolution.Name
+		if cd.ConferenceSolution.Key != nil {
+			c.SolutionType = cd.ConferenceSolution.Key.Type
 		}
 	}
+	for _, ep := range cd.EntryPoints {
+		c.EntryPoints = append(c.EntryPoints, &store.ConferenceEntryPoint{
+			Type:        ep.EntryPointType,
+			URI:         ep.Uri,
+			Label:       ep.Label,
+			MeetingCode: ep.MeetingCode,
+		})
+		if ep.EntryPointType == "video" && c.VideoURI == "" {
+			c.VideoURI = ep.Uri
+			c.MeetingCode = ep.MeetingCode
+		}
+	}
+	return c
+}
 
-	return isNew, nil
+// convertReminders converts Google reminders, returning an empty, non-nil
+// slice when there are none so that removed reminders are removed from the
+// store too.
+func convertReminders(reminders []*gcalendar.EventReminder) []*store.Reminder {
+	converted := make([]*store.Reminder, 0, len(reminders))
+	for _, r := range reminders {
+		converted = append(converted, &store.Reminder{Method: r.Method, Minutes: int(r.Minutes)})
+	}
+	return converted
 }
@@ -0,0 +1,258 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/salman1993/calvault/internal/calendar"
+	"github.com/salman1993/calvault/internal/store"
+	gcalendar "google.golang.org/api/calendar/v3"
+)
+
+func TestCalendarKind(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry calendar.CalendarEntry
+		want  string
+	}{
+		{"primary", calendar.CalendarEntry{ID: "me@example.com", IsPrimary: true, AccessRole: "owner"}, store.CalendarKindPrimary},
+		{"holidays", calendar.CalendarEntry{ID: "en.usa#holiday@group.v.calendar.google.com", AccessRole: "reader"}, store.CalendarKindSubscription},
+		{"secondary", calendar.CalendarEntry{ID: "ht3jlfaac5lfd6263ulfh4tql8@group.calendar.google.com", AccessRole: "owner"}, store.CalendarKindOwned},
+		{"url feed", calendar.CalendarEntry{ID: "abc123@import.calendar.google.com", AccessRole: "reader"}, store.CalendarKindSubscription},
+		{"colleague", calendar.CalendarEntry{ID: "pat@example.com", AccessRole: "freeBusyReader"}, store.CalendarKindShared},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calendarKind(&tt.entry); got != tt.want {
+				t.Errorf("calendarKind(%q) = %q, want %q", tt.entry.ID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimate_ETA(t *testing.T) {
+	est := &Estimate{Events: 5000, Requests: 3, CountRequests: 3, CountDuration: 300 * time.Millisecond}
+
+	// Rate limit dominates: 3 requests at 1 QPS, plus 5000 events to store.
+	if got, want := est.ETA(1), 3*time.Second+5000*storeTimePerEvent; got != want {
+		t.Errorf("ETA(1) = %v, want %v", got, want)
+	}
+	// Measured latency dominates: 100ms per request.
+	if got, want := est.ETA(100), 300*time.Millisecond+5000*storeTimePerEvent; got != want {
+		t.Errorf("ETA(100) = %v, want %v", got, want)
+	}
+}
+
+func TestConvertConference(t *testing.T) {
+	tests := []struct {
+		name      string
+		event     *gcalendar.Event
+		wantEmpty bool
+		wantType  string
+		wantVideo string
+		wantCode  string
+		wantPts   int
+	}{
+		{"none", &gcalendar.Event{}, true, "", "", "", 0},
+		{"meet", &gcalendar.Event{
+			HangoutLink: "https://meet.google.com/abc-defg-hij",
+			ConferenceData: &gcalendar.ConferenceData{
+				ConferenceId:       "abc-defg-hij",
+				ConferenceSolution: &gcalendar.ConferenceSolution{Name: "Google Meet", Key: &gcalendar.ConferenceSolutionKey{Type: "hangoutsMeet"}},
+				EntryPoints: []*gcalendar.EntryPoint{
+					{EntryPointType: "video", Uri: "https://meet.google.com/abc-defg-hij", MeetingCode: "abc-defg-hij"},
+					{EntryPointType: "phone", Uri: "tel:+1-555-0100", Pin: "123456"},
+				},
+			},
+		}, false, "hangoutsMeet", "https://meet.google.com/abc-defg-hij", "abc-defg-hij", 2},
+		{"zoom add-on", &gcalendar.Event{
+			ConferenceData: &gcalendar.ConferenceData{
+				ConferenceSolution: &gcalendar.ConferenceSolution{Name: "Zoom Meeting", Key: &gcalendar.ConferenceSolutionKey{Type: "addOn"}},
+				EntryPoints: []*gcalendar.EntryPoint{
+					{EntryPointType: "more", Uri: "https://zoom.us/help"},
+					{EntryPointType: "video", Uri: "https://zoom.us/j/123", MeetingCode: "123", Passcode: "secret"},
+				},
+			},
+		}, false, "addOn", "https://zoom.us/j/123", "123", 2},
+		{"meet link only", &gcalendar.Event{HangoutLink: "https://meet.google.com/xyz"}, false, "", "", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := convertConference(tt.event)
+			if c.IsEmpty() != tt.wantEmpty {
+				t.Fatalf("IsEmpty = %v, want %v", c.IsEmpty(), tt.wantEmpty)
+			}
+			if c.SolutionType != tt.wantType || c.VideoURI != tt.wantVideo || c.MeetingCode != tt.wantCode || len(c.EntryPoints) != tt.wantPts {
+				t.Errorf("conference = %+v, want type %q, video %q, code %q, %d entry points", c, tt.wantType, tt.wantVideo, tt.wantCode, tt.wantPts)
+			}
+		})
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	ge := &gcalendar.Event{
+		Id:          "evt",
+		Summary:     "Therapy",
+		Description: "Bring the forms",
+		Location:    "12 Main St",
+		Status:      "confirmed",
+		Start:       &gcalendar.EventDateTime{DateTime: "2024-03-04T09:00:00Z"},
+		End:         &gcalendar.EventDateTime{DateTime: "2024-03-04T10:00:00Z"},
+		Organizer:   &gcalendar.EventOrganizer{Email: "dr@example.com"},
+		Attendees:   []*gcalendar.EventAttendee{{Email: "dr@example.com"}, {Email: "me@example.com", Self: true}},
+		HangoutLink: "https://meet.google.com/abc",
+		Reminders:   &gcalendar.EventReminders{UseDefault: true},
+	}
+	tests := []struct {
+		profile                       string
+		wantDescription, wantLocation bool
+		wantAttendees, wantReminders  bool
+		wantConference, wantOrganizer bool
+	}{
+		{"", true, true, true, true, true, true},
+		{ProfileFull, true, true, true, true, true, true},
+		{ProfileStandard, false, true, false, true, false, true},
+		{ProfileMinimal, false, false, false, false, false, false},
+	}
+	for _, tt := range tests {
+		w := convertEvent(1, 1, ge, CalendarOptions{})
+		applyProfile(w, tt.profile)
+		e := w.Event
+		if e.Summary != "Therapy" || !e.StartTime.Valid || !e.EndTime.Valid || e.Status != "confirmed" {
+			t.Errorf("%q: lost the title, times or status: %+v", tt.profile, e)
+		}
+		got := []bool{e.Description != "", e.Location != "", len(w.Attendees) > 0,
+			w.Reminders != nil && w.Reminders.UseDefault, !w.Conference.IsEmpty(), e.OrganizerEmail != ""}
+		want := []bool{tt.wantDescription, tt.wantLocation, tt.wantAttendees, tt.wantReminders, tt.wantConference, tt.wantOrganizer}
+		for i, field := range []string{"description", "location", "attendees", "reminders", "conference", "organizer"} {
+			if got[i] != want[i] {
+				t.Errorf("%q: has %s = %v, want %v", tt.profile, field, got[i], want[i])
+			}
+		}
+		if tt.profile != "" && tt.profile != ProfileFull && w.Attendees == nil {
+			t.Errorf("%q: attendees nil, want empty to clear stored ones", tt.profile)
+		}
+	}
+}
+
+func TestFilterCalendars(t *testing.T) {
+	calendars := []*calendar.CalendarEntry{
+		{ID: "primary", Summary: "Me"},
+		{ID: "en.usa#holiday@group.v.calendar.google.com", Summary: "Holidays in United States"},
+		{ID: "team@group.calendar.google.com", Summary: "Team"},
+	}
+	idName := func(c *calendar.CalendarEntry) (string, string) { return c.ID, c.Summary }
+
+	if got := filterCalendars(slog.Default(), Options{}, calendars, idName); len(got) != 3 {
+		t.Errorf("no filter kept %d calendars, want 3", len(got))
+	}
+
+	opts := Options{IncludeCalendar: func(id, name string) bool {
+		return !strings.Contains(id, "#holiday")
+	}}
+	got := filterCalendars(slog.Default(), opts, calendars, idName)
+	if len(got) != 2 || got[0].ID != "primary" || got[1].ID != "team@group.calendar.google.com" {
+		t.Errorf("filtered = %v, want primary and team", got)
+	}
+}
+
+func TestNewCalendars(t *testing.T) {
+	entries := []*calendar.CalendarEntry{
+		{ID: "primary", Summary: "Me"},
+		{ID: "team@group.calendar.google.com", Summary: "Team"},
+		{ID: "en.usa#holiday@group.v.calendar.google.com", Summary: "Holidays in United States"},
+	}
+	stored := []*store.Calendar{{GoogleCalendarID: "primary"}}
+	opts := Options{IncludeCalendar: func(id, name string) bool {
+		return !strings.Contains(id, "#holiday")
+	}}
+
+	got := newCalendars(entries, stored, opts)
+	if len(got) != 1 || got[0].ID != "team@group.calendar.google.com" {
+		t.Errorf("new calendars = %v, want team only", got)
+	}
+	if got := newCalendars(nil, stored, Options{}); len(got) != 0 {
+		t.Errorf("no changes gave %d new calendars", len(got))
+	}
+}
+
+func TestBudget(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.InitSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	now := time.Date(2024, 3, 5, 23, 0, 0, 0, time.Local)
+	b := NewBudget(s, 2)
+	b.now = func() time.Time { return now }
+	opts := CalendarOptions{budget: b}
+	ctx := context.Background()
+	if err := opts.wait(ctx); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	if err := b.Spend(1); err != nil {
+		t.Fatalf("spend: %v", err)
+	}
+	if err := opts.wait(ctx); !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("request over budget: %v, want ErrBudgetExhausted", err)
+	}
+	if used, _ := b.Used(); used != 2 {
+		t.Errorf("used = %d, want 2", used)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err := opts.wait(ctx); err != nil {
+		t.Errorf("request on the next day: %v", err)
+	}
+	if err := (CalendarOptions{}).wait(ctx); err != nil {
+		t.Errorf("request without a budget: %v", err)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	est := &Estimate{Calendars: []CalendarEstimate{
+		{ID: "big", Requests: 9},
+		{ID: "small", Requests: 1},
+		{ID: "me", Primary: true, Requests: 4},
+		{ID: "medium", Requests: 3},
+	}}
+	entries := PlanEntries(est)
+	var ids []string
+	for _, e := range entries {
+		ids = append(ids, e.GoogleCalendarID)
+	}
+	if got := strings.Join(ids, ","); got != "me,small,medium,big" {
+		t.Errorf("plan order = %s, want me,small,medium,big", got)
+	}
+
+	// 5 requests a day with 2 spent today: me ends on request 6 (tomorrow),
+	// medium on 9 (tomorrow) and big on 18 (in three days); small is done
+	entries[1].Done = true
+	days := PlanDays(entries, 5, 2)
+	if want := []int{1, 0, 1, 3}; fmt.Sprint(days) != fmt.Sprint(want) {
+		t.Errorf("plan days = %v, want %v", days, want)
+	}
+	if days := PlanDays(entries, 0, 2); fmt.Sprint(days) != "[0 0 0 0]" {
+		t.Errorf("unlimited plan days = %v, want all today", days)
+	}
+
+	calendars := []*calendar.CalendarEntry{{ID: "new"}, {ID: "big"}, {ID: "me"}, {ID: "other"}}
+	orderByPlan(calendars, entries)
+	ids = nil
+	for _, c := range calendars {
+		ids = append(ids, c.ID)
+	}
+	if got := strings.Join(ids, ","); got != "me,big,new,other" {
+		t.Errorf("sync order = %s, want me,big,new,other", got)
+	}
+}
@@ -0,0 +1,115 @@
+package sync
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/salman1993/calvault/internal/store"
+)
+
+// WatchOptions configures push notification channels.
+type WatchOptions struct {
+	Address     string        // Public HTTPS webhook URL
+	TTL         time.Duration // Requested channel lifetime
+	RenewBefore time.Duration // Replace channels expiring within this window
+
+	// IncludeCalendar reports whether a calendar should be watched; nil
+	// watches all of them. Channels of excluded calendars are stopped.
+	IncludeCalendar func(id, name string) bool
+}
+
+// RenewWatches makes sure every stored calendar of the account has a live
+// push notification channel pointing at opts.Address. Channels that are
+// close to expiry or point elsewhere are replaced: the new channel is
+// created before the old one is stopped so no changes are missed. Calendars
+// that don't support push (some public feeds) are logged and skipped.
+func (s *Syncer) RenewWatches(ctx context.Context, email string, opts WatchOptions) (int, error) {
+	source, err := s.store.GetSourceByIdentifier(email)
+	if err != nil {
+		return 0, fmt.Errorf("get source: %w", err)
+	}
+	if source == nil {
+		return 0, nil
+	}
+
+	calendars, err := s.store.GetCalendars(source.ID)
+	if err != nil {
+		return 0, fmt.Errorf("get calendars: %w", err)
+	}
+	existing, err := s.store.ListWatchChannels(source.ID)
+	if err != nil {
+		return 0, err
+	}
+	byCalendar := make(map[int64][]*store.WatchChannel)
+	for _, w := range existing {
+		byCalendar[w.CalendarID] = append(byCalendar[w.CalendarID], w)
+	}
+
+	renewAt := time.Now().Add(opts.RenewBefore)
+	created := 0
+	for _, cal := range calendars {
+		if ctx.Err() != nil {
+			return created, ctx.Err()
+		}
+
+		// Excluded calendars keep no channels.
+		include := opts.IncludeCalendar == nil || opts.IncludeCalendar(cal.GoogleCalendarID, cal.Summary)
+		var stale []*store.WatchChannel
+		live := false
+		for _, w := range byCalendar[cal.ID] {
+			if include && w.Address == opts.Address && w.ExpiresAt.After(renewAt) {
+				live = true
+			} else {
+				stale = append(stale, w)
+			}
+		}
+
+		if include && !live {
+			channelID, token := randomID(), randomID()
+			ch, err := s.client.WatchEvents(ctx, cal.GoogleCalendarID, channelID, token, opts.Address, opts.TTL)
+			if err != nil {
+				s.logger.Warn("failed to watch calendar", "calendar", cal.Summary, "error", err)
+				continue
+			}
+			if _, err := s.store.SaveWatchChannel(&store.WatchChannel{
+				SourceID:   source.ID,
+				CalendarID: cal.ID,
+				ChannelID:  ch.ID,
+				ResourceID: ch.ResourceID,
+				Token:      token,
+				Address:    opts.Address,
+				ExpiresAt:  ch.Expiration,
+			}); err != nil {
+				return created, err
+			}
+			created++
+			s.logger.Info("watching calendar", "calendar", cal.Summary, "expires", ch.Expiration.Format(time.RFC3339))
+		}
+
+		for _, w := range stale {
+			if w.ExpiresAt.After(time.Now()) {
+				if err := s.client.StopChannel(ctx, w.ChannelID, w.ResourceID); err != nil {
+					s.logger.Warn("failed to stop channel", "channel", w.ChannelID, "error", err)
+				}
+			}
+			if err := s.store.DeleteWatchChannel(w.ChannelID); err != nil {
+				return created, err
+			}
+		}
+	}
+
+	return created, nil
+}
+
+// randomID returns 16 random bytes, hex-encoded; valid as both a channel ID
+// and a channel token.
+func randomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
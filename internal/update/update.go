@@ -0,0 +1,333 @@
+// Package update checks GitHub releases for a newer calvault, verifies the
+// downloaded binary, and replaces the running executable.
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultRepo is the GitHub repository releases are published to.
+const DefaultRepo = "salman1993/calvault"
+
+// Release assets besides the per-platform binaries.
+const (
+	ChecksumsAsset = "checksums.txt"     // sha256sum output for every binary
+	SignatureAsset = "checksums.txt.sig" // Base64 ed25519 signature of checksums.txt
+)
+
+// PublicKey is the base64 ed25519 key that release checksums are signed
+// with, set at build time with
+// -ldflags "-X github.com/salman1993/calvault/internal/update.PublicKey=...".
+// Builds without it refuse to download releases unless the client is
+// Insecure.
+var PublicKey = ""
+
+// ErrNoPublicKey is returned by Download when there is no public key to
+// verify the release signature with and the client isn't Insecure.
+var ErrNoPublicKey = errors.New("this build has no release public key to verify the signature with")
+
+// maxDownload bounds any single download.
+const maxDownload = 256 << 20
+
+// Release is a published GitHub release.
+type Release struct {
+	Version string            // Tag, e.g. "v1.4.0"
+	URL     string            // Release page
+	Assets  map[string]string // Asset name -> download URL
+}
+
+// Client talks to the GitHub releases API.
+type Client struct {
+	HTTP      *http.Client // http.DefaultClient if nil
+	BaseURL   string       // API root; https://api.github.com if empty
+	Repo      string       // owner/name; DefaultRepo if empty
+	PublicKey string       // Base64 ed25519 key
+	// Insecure lets Download verify checksums only when PublicKey is
+	// empty; without it, Download fails with ErrNoPublicKey.
+	Insecure bool
+}
+
+// NewClient returns a client for the default repository using the key
+// compiled into this build.
+func NewClient() *Client {
+	return &Client{PublicKey: PublicKey}
+}
+
+// Latest returns the newest non-prerelease release.
+func (c *Client) Latest(ctx context.Context) (*Release, error) {
+	base := c.BaseURL
+	if base == "" {
+		base = "https://api.github.com"
+	}
+	repo := c.Repo
+	if repo == "" {
+		repo = DefaultRepo
+	}
+
+	body, err := c.get(ctx, base+"/repos/"+repo+"/releases/latest", "application/vnd.github+json")
+	if err != nil {
+		return nil, fmt.Errorf("get latest release: %w", err)
+	}
+	var resp struct {
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+		Assets  []struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("parse latest release: %w", err)
+	}
+	if resp.TagName == "" {
+		return nil, fmt.Errorf("latest release has no tag")
+	}
+
+	rel := &Release{Version: resp.TagName, URL: resp.HTMLURL, Assets: make(map[string]string)}
+	for _, a := range resp.Assets {
+		rel.Assets[a.Name] = a.URL
+	}
+	return rel, nil
+}
+
+// AssetName is the release asset holding the binary for a platform.
+func AssetName(goos, goarch string) string {
+	name := "calvault_" + goos + "_" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Download fetches the binary for a platform and verifies it against the
+// release checksums, and the checksums against their signature. Without a
+// public key it fails with ErrNoPublicKey, unless the client is Insecure
+// and verifies checksums only.
+func (c *Client) Download(ctx context.Context, rel *Release, goos, goarch string) ([]byte, error) {
+	if c.PublicKey == "" && !c.Insecure {
+		return nil, ErrNoPublicKey
+	}
+	name := AssetName(goos, goarch)
+	binURL, ok := rel.Assets[name]
+	if !ok {
+		return nil, fmt.Errorf("release %s has no binary for %s/%s", rel.Version, goos, goarch)
+	}
+	sumsURL, ok := rel.Assets[ChecksumsAsset]
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s", rel.Version, ChecksumsAsset)
+	}
+
+	sums, err := c.get(ctx, sumsURL, "")
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", ChecksumsAsset, err)
+	}
+	if c.PublicKey != "" {
+		sigURL, ok := rel.Assets[SignatureAsset]
+		if !ok {
+			return nil, fmt.Errorf("release %s is not signed (no %s)", rel.Version, SignatureAsset)
+		}
+		sig, err := c.get(ctx, sigURL, "")
+		if err != nil {
+			return nil, fmt.Errorf("download %s: %w", SignatureAsset, err)
+		}
+		if err := VerifySignature(c.PublicKey, sums, sig); err != nil {
+			return nil, err
+		}
+	}
+
+	want, err := checksumFor(sums, name)
+	if err != nil {
+		return nil, err
+	}
+	bin, err := c.get(ctx, binURL, "")
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", name, err)
+	}
+	got := sha256.Sum256(bin)
+	if hex.EncodeToString(got[:]) != want {
+		return nil, fmt.Errorf("checksum mismatch for %s", name)
+	}
+	return bin, nil
+}
+
+func (c *Client) get(ctx context.Context, url, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownload+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDownload {
+		return nil, fmt.Errorf("%s: larger than %d bytes", url, maxDownload)
+	}
+	return data, nil
+}
+
+// checksumFor finds a file's SHA-256 in sha256sum output.
+func checksumFor(sums []byte, name string) (string, error) {
+	sc := bufio.NewScanner(bytes.NewReader(sums))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		// "<hex>  <name>", or "<hex> *<name>" in binary mode
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %s", name)
+}
+
+// VerifySignature checks a base64 ed25519 signature of data.
+func VerifySignature(publicKey string, data, sig []byte) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid release public key")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, raw) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+// Newer reports whether latest is a newer version than current. Versions
+// are vMAJOR.MINOR.PATCH; anything after a '-' (prerelease or git describe
+// suffix) is ignored. A current version that doesn't parse, such as "dev",
+// is treated as older than any release.
+func Newer(latest, current string) bool {
+	l, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	c, ok := parseVersion(current)
+	if !ok {
+		return true
+	}
+	for i := range l {
+		if l[i] != c[i] {
+			return l[i] > c[i]
+		}
+	}
+	return false
+}
+
+// IsRelease reports whether v is a release version rather than a
+// development build ("dev" or a bare commit hash).
+func IsRelease(v string) bool {
+	_, ok := parseVersion(v)
+	return ok
+}
+
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	v = strings.TrimPrefix(v, "v")
+	v, _, _ = strings.Cut(v, "-")
+	fields := strings.Split(v, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// ManagedBy names the package manager that installed the executable at
+// path, or returns "" for a standalone install. Such installs should be
+// upgraded through the package manager rather than replaced in place.
+func ManagedBy(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	p := filepath.ToSlash(path)
+	switch {
+	case strings.Contains(p, "/Cellar/") || strings.Contains(p, "/homebrew/") || strings.Contains(p, "/linuxbrew/"):
+		return "Homebrew"
+	case strings.HasPrefix(p, "/nix/store/"):
+		return "Nix"
+	case strings.Contains(p, "/scoop/apps/"):
+		return "Scoop"
+	case strings.HasPrefix(p, "/usr/bin/") || strings.HasPrefix(p, "/usr/sbin/"):
+		return "your system package manager"
+	}
+	return ""
+}
+
+// Install replaces the executable at path with data. The new binary is
+// written next to it and renamed into place, so a failure leaves the old
+// one working. The old binary is moved aside first because Windows can't
+// overwrite a running executable.
+func Install(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat executable: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, ".calvault-update-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	_, werr := tmp.Write(data)
+	cerr := tmp.Close()
+	if err := errors.Join(werr, cerr); err != nil {
+		return fmt.Errorf("write new binary: %w", err)
+	}
+	if err := os.Chmod(tmpPath, info.Mode().Perm()|0o111); err != nil {
+		return fmt.Errorf("chmod new binary: %w", err)
+	}
+
+	old := path + ".old"
+	_ = os.Remove(old) // Left behind by a previous update on Windows
+	if err := os.Rename(path, old); err != nil {
+		return fmt.Errorf("move old binary aside: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Rename(old, path)
+		return fmt.Errorf("install new binary: %w", err)
+	}
+	// Fails on Windows while the old binary is running; removed next time
+	_ = os.Remove(old)
+	return nil
+}
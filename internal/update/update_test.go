@@ -0,0 +1,170 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"v1.2.0", "v1.1.9", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v2.0.0", "v1.99.99", true},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.2.0", "v1.3.0", false},
+		{"v1.2.0", "v1.2.0-3-gabc123-dirty", false},
+		{"v1.2.1", "v1.2.0-3-gabc123", true},
+		{"v1.2", "v1.2.0", false},
+		{"v1.2.0", "dev", true},
+		{"nightly", "v1.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.latest, tt.current); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.latest, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestIsRelease(t *testing.T) {
+	for v, want := range map[string]bool{"v1.2.0": true, "1.2": true, "v1.2.0-3-gabc123": true, "dev": false, "bb4f934": false, "": false} {
+		if got := IsRelease(v); got != want {
+			t.Errorf("IsRelease(%q) = %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestManagedBy(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/opt/homebrew/Cellar/calvault/1.2.0/bin/calvault", "Homebrew"},
+		{"/home/linuxbrew/.linuxbrew/bin/calvault", "Homebrew"},
+		{"/nix/store/abc-calvault-1.2.0/bin/calvault", "Nix"},
+		{"/usr/bin/calvault", "your system package manager"},
+		{"/home/me/.local/bin/calvault", ""},
+	}
+	for _, tt := range tests {
+		if got := ManagedBy(tt.path); got != tt.want {
+			t.Errorf("ManagedBy(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+// releaseServer serves a latest release with a binary for linux/amd64.
+func releaseServer(t *testing.T, bin, sums, sig []byte) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/salman1993/calvault/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		assets := []string{fmt.Sprintf(`{"name": "calvault_linux_amd64", "browser_download_url": %q}`, srv.URL+"/dl/bin"),
+			fmt.Sprintf(`{"name": "checksums.txt", "browser_download_url": %q}`, srv.URL+"/dl/sums")}
+		if sig != nil {
+			assets = append(assets, fmt.Sprintf(`{"name": "checksums.txt.sig", "browser_download_url": %q}`, srv.URL+"/dl/sig"))
+		}
+		fmt.Fprintf(w, `{"tag_name": "v1.2.0", "html_url": "https://example.com/r", "assets": [%s]}`, strings.Join(assets, ","))
+	})
+	mux.HandleFunc("/dl/bin", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write(bin) })
+	mux.HandleFunc("/dl/sums", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write(sums) })
+	mux.HandleFunc("/dl/sig", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write(sig) })
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDownload(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey := base64.StdEncoding.EncodeToString(pub)
+
+	bin := []byte("new calvault binary")
+	sum := sha256.Sum256(bin)
+	sums := []byte(hex.EncodeToString(sum[:]) + "  calvault_linux_amd64\n")
+	badSums := []byte(strings.Repeat("0", 64) + "  calvault_linux_amd64\n")
+	sig := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, sums)))
+
+	tests := []struct {
+		name      string
+		sums, sig []byte
+		publicKey string
+		insecure  bool
+		goos      string
+		wantErr   string
+	}{
+		{name: "no public key", sums: sums, wantErr: "no release public key"},
+		{name: "checksum only when insecure", sums: sums, insecure: true},
+		{name: "signed", sums: sums, sig: sig, publicKey: pubKey},
+		{name: "checksum mismatch", sums: badSums, insecure: true, wantErr: "checksum mismatch"},
+		{name: "bad signature", sums: badSums, sig: sig, publicKey: pubKey, wantErr: "signature verification failed"},
+		{name: "unsigned release", sums: sums, publicKey: pubKey, wantErr: "not signed"},
+		{name: "no binary for platform", sums: sums, publicKey: pubKey, goos: "plan9", wantErr: "no binary for plan9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := releaseServer(t, bin, tt.sums, tt.sig)
+			c := &Client{BaseURL: srv.URL, PublicKey: tt.publicKey, Insecure: tt.insecure}
+			rel, err := c.Latest(context.Background())
+			if err != nil {
+				t.Fatalf("latest: %v", err)
+			}
+			if rel.Version != "v1.2.0" {
+				t.Errorf("version = %q, want v1.2.0", rel.Version)
+			}
+
+			goos := tt.goos
+			if goos == "" {
+				goos = "linux"
+			}
+			got, err := c.Download(context.Background(), rel, goos, "amd64")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("download error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("download: %v", err)
+			}
+			if string(got) != string(bin) {
+				t.Errorf("downloaded %q, want %q", got, bin)
+			}
+		})
+	}
+}
+
+func TestInstall(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calvault")
+	if err := os.WriteFile(path, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Install(path, []byte("new")); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "new" {
+		t.Errorf("executable = %q, %v; want new", data, err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm()&0o111 == 0 {
+		t.Errorf("mode = %v, want executable", info.Mode())
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want only the executable", len(entries))
+	}
+}
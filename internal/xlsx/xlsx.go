@@ -0,0 +1,282 @@
+// Package xlsx writes simple Excel workbooks (Office Open XML
+// spreadsheets): sheets of rows under a bold, frozen header row, with
+// numbers, dates and percentages formatted.
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Workbook is a list of sheets.
+type Workbook struct {
+	Sheets []*Sheet
+}
+
+// Sheet is a table: a header row and rows of cells. A cell is a string,
+// an int, int64 or float64, a bool, a time.Time (date and time), a Date,
+// a Percent or nil (empty).
+type Sheet struct {
+	Name   string // At most 31 characters, none of []:*?/\
+	Header []string
+	Rows   [][]interface{}
+}
+
+// Date is a cell shown as a date without the time.
+type Date time.Time
+
+// Percent is a fraction (0.25) shown as a percentage (25%).
+type Percent float64
+
+// AddSheet adds a sheet with the given header row.
+func (wb *Workbook) AddSheet(name string, header ...string) *Sheet {
+	sheet := &Sheet{Name: name, Header: header}
+	wb.Sheets = append(wb.Sheets, sheet)
+	return sheet
+}
+
+// Row appends a row of cells.
+func (s *Sheet) Row(cells ...interface{}) {
+	s.Rows = append(s.Rows, cells)
+}
+
+// Style indexes into cellXfs in styles.xml.
+const (
+	styleDefault = iota
+	styleHeader
+	styleDateTime
+	styleDate
+	styleDecimal
+	stylePercent
+)
+
+const stylesXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="2"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm"/><numFmt numFmtId="165" formatCode="yyyy-mm-dd"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="3"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill><fill><patternFill patternType="solid"><fgColor rgb="FFD9E1F2"/></patternFill></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="6">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="0" fontId="1" fillId="2" borderId="0" xfId="0" applyFont="1" applyFill="1"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="2" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="9" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+</cellXfs>
+<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>
+</styleSheet>`
+
+// maxWidth caps a column's width, in characters.
+const maxWidth = 60
+
+// Write writes the workbook as an .xlsx file.
+func (wb *Workbook) Write(w io.Writer) error {
+	if len(wb.Sheets) == 0 {
+		return fmt.Errorf("write xlsx: workbook has no sheets")
+	}
+	names := map[string]bool{}
+	for _, s := range wb.Sheets {
+		if s.Name == "" || utf8.RuneCountInString(s.Name) > 31 || strings.ContainsAny(s.Name, `[]:*?/\`) {
+			return fmt.Errorf("write xlsx: invalid sheet name %q", s.Name)
+		}
+		if names[strings.ToLower(s.Name)] {
+			return fmt.Errorf("write xlsx: duplicate sheet name %q", s.Name)
+		}
+		names[strings.ToLower(s.Name)] = true
+	}
+
+	var contentTypes, workbook, rels strings.Builder
+	contentTypes.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+`)
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rIdStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+`)
+	for i, s := range wb.Sheets {
+		n := i + 1
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`+"\n", n)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(s.Name), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`+"\n", n, n)
+	}
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	rels.WriteString(`</Relationships>`)
+
+	files := []struct{ name, body string }{
+		{"[Content_Types].xml", contentTypes.String()},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", rels.String()},
+		{"xl/styles.xml", stylesXML},
+	}
+	for i, s := range wb.Sheets {
+		body, err := sheetXML(s)
+		if err != nil {
+			return fmt.Errorf("write xlsx: sheet %s: %w", s.Name, err)
+		}
+		files = append(files, struct{ name, body string }{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), body})
+	}
+
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return fmt.Errorf("write xlsx: %w", err)
+		}
+		if _, err := io.WriteString(fw, f.body); err != nil {
+			return fmt.Errorf("write xlsx: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("write xlsx: %w", err)
+	}
+	return nil
+}
+
+// sheetXML renders a worksheet: column widths fitted to the contents, the
+// header row frozen and filterable.
+func sheetXML(s *Sheet) (string, error) {
+	columns := len(s.Header)
+	for _, row := range s.Rows {
+		columns = max(columns, len(row))
+	}
+	widths := make([]int, columns)
+
+	var data strings.Builder
+	data.WriteString(`<sheetData>`)
+	if len(s.Header) > 0 {
+		data.WriteString(`<row r="1">`)
+		for c, h := range s.Header {
+			fmt.Fprintf(&data, `<c r="%s1" t="inlineStr" s="%d"><is><t xml:space="preserve">%s</t></is></c>`, cellRef(c), styleHeader, escape(h))
+			widths[c] = max(widths[c], utf8.RuneCountInString(h)+2)
+		}
+		data.WriteString(`</row>`)
+	}
+	for r, row := range s.Rows {
+		rowNum := r + 1
+		if len(s.Header) > 0 {
+			rowNum++
+		}
+		fmt.Fprintf(&data, `<row r="%d">`, rowNum)
+		for c, v := range row {
+			cell, width, err := cellXML(fmt.Sprintf("%s%d", cellRef(c), rowNum), v)
+			if err != nil {
+				return "", err
+			}
+			data.WriteString(cell)
+			widths[c] = max(widths[c], width)
+		}
+		data.WriteString(`</row>`)
+	}
+	data.WriteString(`</sheetData>`)
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(s.Header) > 0 {
+		b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	}
+	if columns > 0 {
+		b.WriteString(`<cols>`)
+		for c, w := range widths {
+			fmt.Fprintf(&b, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, c+1, c+1, min(max(w, 8), maxWidth))
+		}
+		b.WriteString(`</cols>`)
+	}
+	b.WriteString(data.String())
+	if len(s.Header) > 0 && len(s.Rows) > 0 {
+		fmt.Fprintf(&b, `<autoFilter ref="A1:%s%d"/>`, cellRef(columns-1), len(s.Rows)+1)
+	}
+	b.WriteString(`</worksheet>`)
+	return b.String(), nil
+}
+
+// cellXML renders one cell and the width, in characters, it needs.
+func cellXML(ref string, v interface{}) (string, int, error) {
+	number := func(f float64, style int) (string, int, error) {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Sprintf(`<c r="%s"/>`, ref), 0, nil
+		}
+		s := strconv.FormatFloat(f, 'f', -1, 64)
+		return fmt.Sprintf(`<c r="%s" s="%d"><v>%s</v></c>`, ref, style, s), len(s) + 2, nil
+	}
+	switch v := v.(type) {
+	case nil:
+		return "", 0, nil
+	case string:
+		width := 0
+		for _, line := range strings.Split(v, "\n") {
+			width = max(width, utf8.RuneCountInString(line)+2)
+		}
+		return fmt.Sprintf(`<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, escape(v)), width, nil
+	case int:
+		return number(float64(v), styleDefault)
+	case int64:
+		return number(float64(v), styleDefault)
+	case float64:
+		return number(v, styleDecimal)
+	case Percent:
+		return number(float64(v), stylePercent)
+	case bool:
+		b := 0
+		if v {
+			b = 1
+		}
+		return fmt.Sprintf(`<c r="%s" t="b"><v>%d</v></c>`, ref, b), 7, nil
+	case time.Time:
+		cell, _, err := number(serial(v), styleDateTime)
+		return cell, 18, err
+	case Date:
+		cell, _, err := number(math.Floor(serial(time.Time(v))), styleDate)
+		return cell, 12, err
+	default:
+		return "", 0, fmt.Errorf("unsupported cell type %T", v)
+	}
+}
+
+// excelEpoch is day 0 of Excel's 1900 date system (as it counts, with its
+// 1900 leap year bug).
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// serial converts t's wall clock time to an Excel date serial: days since
+// the epoch, with the time of day as the fraction.
+func serial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return wall.Sub(excelEpoch).Hours() / 24
+}
+
+// cellRef returns the column letters for a zero-based column: A, ..., Z,
+// AA, ...
+func cellRef(col int) string {
+	var ref []byte
+	for col++; col > 0; col = (col - 1) / 26 {
+		ref = append([]byte{byte('A' + (col-1)%26)}, ref...)
+	}
+	return string(ref)
+}
+
+func escape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
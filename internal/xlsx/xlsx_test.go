@@ -0,0 +1,127 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCellRef(t *testing.T) {
+	tests := []struct {
+		col  int
+		want string
+	}{
+		{0, "A"},
+		{25, "Z"},
+		{26, "AA"},
+		{27, "AB"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+	for _, tt := range tests {
+		if got := cellRef(tt.col); got != tt.want {
+			t.Errorf("cellRef(%d) = %s, want %s", tt.col, got, tt.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	wb := &Workbook{}
+	events := wb.AddSheet("Events", "Start", "Title", "Hours", "All day")
+	events.Row(time.Date(2024, 6, 12, 9, 0, 0, 0, time.FixedZone("EDT", -4*3600)), "Standup & <retro>", 0.25, false)
+	events.Row(Date(time.Date(2024, 6, 13, 0, 0, 0, 0, time.UTC)), "Offsite", nil, true)
+	months := wb.AddSheet("Months", "Month", "Meetings", "Busy")
+	months.Row("2024-06", 12, Percent(0.4))
+
+	var buf bytes.Buffer
+	if err := wb.Write(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		b, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		files[f.Name] = string(b)
+
+		// Every part must be well-formed XML
+		dec := xml.NewDecoder(bytes.NewReader(b))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: %v", f.Name, err)
+			}
+		}
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("missing %s", name)
+		}
+	}
+	if !strings.Contains(files["xl/workbook.xml"], `<sheet name="Months" sheetId="2" r:id="rId2"/>`) {
+		t.Errorf("workbook.xml doesn't list the Months sheet:\n%s", files["xl/workbook.xml"])
+	}
+
+	sheet1 := files["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" t="inlineStr" s="1"><is><t xml:space="preserve">Start</t></is></c>`,
+		`<c r="A2" s="2"><v>45455.375</v></c>`, // Wall clock time, not UTC
+		`<t xml:space="preserve">Standup &amp; &lt;retro&gt;</t>`,
+		`<c r="C2" s="4"><v>0.25</v></c>`,
+		`<c r="D2" t="b"><v>0</v></c>`,
+		`<c r="A3" s="3"><v>45456</v></c>`,
+		`<c r="D3" t="b"><v>1</v></c>`,
+		`state="frozen"`,
+		`<autoFilter ref="A1:D3"/>`,
+	} {
+		if !strings.Contains(sheet1, want) {
+			t.Errorf("sheet1.xml missing %s", want)
+		}
+	}
+	if strings.Contains(sheet1, `r="C3"`) {
+		t.Error("nil cell was written")
+	}
+	sheet2 := files["xl/worksheets/sheet2.xml"]
+	for _, want := range []string{`<c r="B2" s="0"><v>12</v></c>`, `<c r="C2" s="5"><v>0.4</v></c>`} {
+		if !strings.Contains(sheet2, want) {
+			t.Errorf("sheet2.xml missing %s", want)
+		}
+	}
+}
+
+func TestWrite_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		sheets []string
+		cell   interface{}
+	}{
+		{"no sheets", nil, nil},
+		{"invalid name", []string{"Q1/Q2"}, nil},
+		{"long name", []string{strings.Repeat("x", 32)}, nil},
+		{"duplicate name", []string{"People", "people"}, nil},
+		{"unsupported cell", []string{"Events"}, struct{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wb := &Workbook{}
+			for _, name := range tt.sheets {
+				wb.AddSheet(name, "A").Row(tt.cell)
+			}
+			if err := wb.Write(io.Discard); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}